@@ -13,10 +13,23 @@ var DB *sql.DB
 
 const defaultDBPath = "./sentinent.db"
 
+// InitDB opens the database named by DATABASE_URL, falling back to the SQLite
+// file at DATABASE_PATH, and applies pending migrations.
 func InitDB() error {
+	if databaseURL := strings.TrimSpace(os.Getenv("DATABASE_URL")); databaseURL != "" {
+		return InitDBWithURL(databaseURL)
+	}
 	return InitDBWithPath(strings.TrimSpace(os.Getenv("DATABASE_PATH")))
 }
 
+// OpenDB opens the configured database without applying migrations.
+func OpenDB() error {
+	if databaseURL := strings.TrimSpace(os.Getenv("DATABASE_URL")); databaseURL != "" {
+		return OpenDBWithURL(databaseURL)
+	}
+	return OpenDBWithPath(strings.TrimSpace(os.Getenv("DATABASE_PATH")))
+}
+
 func buildDSN(path string) string {
 	// Embed SQLite pragmas in the DSN so they apply to every connection in the
 	// pool, not just the one used during initialisation.  Without this,
@@ -32,214 +45,89 @@ func InitDBWithPath(path string) error {
 	if strings.TrimSpace(path) == "" {
 		path = defaultDBPath
 	}
+	return initDB(DialectSQLite, "sqlite3", buildDSN(path))
+}
 
-	db, err := sql.Open("sqlite3", buildDSN(path))
+// InitDBWithURL opens the database described by a DATABASE_URL and applies
+// pending migrations.
+func InitDBWithURL(databaseURL string) error {
+	dialect, driverName, dsn, err := ParseDatabaseURL(databaseURL)
 	if err != nil {
-		return fmt.Errorf("open database: %w", err)
+		return err
 	}
+	return initDB(dialect, driverName, dsn)
+}
 
-	previousDB := DB
-	DB = db
-	if err := configureSQLite(); err != nil {
-		DB = previousDB
-		_ = db.Close()
+func initDB(dialect Dialect, driverName, dsn string) error {
+	previousDB, previousDialect := DB, currentDialect
+	if err := openDB(dialect, driverName, dsn); err != nil {
 		return err
 	}
+	db := DB
 
-	statements := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL,
-			full_name TEXT DEFAULT '',
-			job_title TEXT DEFAULT '',
-			organization TEXT DEFAULT '',
-			timezone TEXT DEFAULT '',
-			bio TEXT DEFAULT '',
-			role_label TEXT DEFAULT ''
-		);`,
-		`CREATE TABLE IF NOT EXISTS workspaces (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			description TEXT DEFAULT '',
-			owner_id INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (owner_id) REFERENCES users(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS decisions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			workspace_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			description TEXT,
-			status TEXT NOT NULL CHECK (status IN ('DRAFT', 'OPEN', 'CLOSED')),
-			due_date DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS workspace_members (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			workspace_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			role TEXT NOT NULL CHECK (role IN ('owner', 'member', 'viewer')),
-			joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(workspace_id, user_id),
-			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS invitations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			workspace_id INTEGER NOT NULL,
-			email TEXT NOT NULL,
-			token TEXT NOT NULL UNIQUE,
-			role TEXT NOT NULL CHECK (role IN ('member', 'viewer')),
-			expires_at DATETIME NOT NULL,
-			created_by INTEGER NOT NULL,
-			accepted_at DATETIME,
-			accepted_by INTEGER,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
-			FOREIGN KEY (created_by) REFERENCES users(id),
-			FOREIGN KEY (accepted_by) REFERENCES users(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS external_integrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			provider TEXT NOT NULL,
-			access_token TEXT NOT NULL,
-			refresh_token TEXT,
-			expires_at DATETIME,
-			metadata TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (workspace_id) REFERENCES workspaces(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS signals (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			source_type TEXT NOT NULL,
-			source_id TEXT NOT NULL,
-			external_id TEXT,
-			title TEXT NOT NULL,
-			content TEXT,
-			author TEXT,
-			body TEXT,
-			url TEXT,
-			status TEXT DEFAULT 'unread',
-			source_metadata TEXT,
-			received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (workspace_id) REFERENCES workspaces(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS signal_status (
-			signal_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (signal_id, user_id),
-			FOREIGN KEY (signal_id) REFERENCES signals(id),
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			token_hash TEXT NOT NULL UNIQUE,
-			expires_at DATETIME NOT NULL,
-			used_at DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users(id)
-		);`,
-		`CREATE UNIQUE INDEX IF NOT EXISTS idx_external_integrations_user_provider_workspace
-			ON external_integrations(user_id, provider, COALESCE(workspace_id, 0));`,
-		`CREATE UNIQUE INDEX IF NOT EXISTS idx_signals_user_source
-			ON signals(user_id, source_type, source_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_signals_user_id ON signals(user_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_signals_workspace_id ON signals(workspace_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_signals_received_at ON signals(received_at);`,
-		`CREATE INDEX IF NOT EXISTS idx_integrations_user_id ON external_integrations(user_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_integrations_workspace_id ON external_integrations(workspace_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_workspace_members_workspace_id ON workspace_members(workspace_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_invitations_workspace_id ON invitations(workspace_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations(email);`,
-		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);`,
+	restore := func() {
+		DB, currentDialect = previousDB, previousDialect
+		_ = db.Close()
 	}
 
-	for _, statement := range statements {
-		if _, err := DB.Exec(statement); err != nil {
-			DB = previousDB
-			_ = db.Close()
-			return fmt.Errorf("run schema statement: %w", err)
-		}
+	if _, err := MigrateUp(); err != nil {
+		restore()
+		return err
 	}
 
-	columns := []struct {
-		table      string
-		name       string
-		definition string
-	}{
-		{"external_integrations", "workspace_id", "INTEGER"},
-		{"external_integrations", "refresh_token", "TEXT"},
-		{"external_integrations", "expires_at", "DATETIME"},
-		{"external_integrations", "metadata", "TEXT"},
-		{"workspaces", "description", "TEXT DEFAULT ''"},
-		{"users", "full_name", "TEXT DEFAULT ''"},
-		{"users", "job_title", "TEXT DEFAULT ''"},
-		{"users", "organization", "TEXT DEFAULT ''"},
-		{"users", "timezone", "TEXT DEFAULT ''"},
-		{"users", "bio", "TEXT DEFAULT ''"},
-		{"users", "role_label", "TEXT DEFAULT ''"},
-		{"signals", "workspace_id", "INTEGER"},
-		{"signals", "external_id", "TEXT"},
-		{"signals", "content", "TEXT"},
-		{"signals", "author", "TEXT"},
-		{"signals", "body", "TEXT"},
-		{"signals", "url", "TEXT"},
-		{"signals", "source_metadata", "TEXT"},
-		{"signals", "received_at", "DATETIME DEFAULT CURRENT_TIMESTAMP"},
-		{"signals", "updated_at", "DATETIME DEFAULT CURRENT_TIMESTAMP"},
+	if err := ensureWorkspaceOwnerMemberships(); err != nil {
+		restore()
+		return err
 	}
-	for _, column := range columns {
-		if err := ensureColumn(column.table, column.name, column.definition); err != nil {
-			DB = previousDB
-			_ = db.Close()
-			return err
-		}
+	if err := cleanupOrphanIntegrations(); err != nil {
+		restore()
+		return err
 	}
-
-	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_decisions_workspace_id ON decisions(workspace_id);`); err != nil {
-		DB = previousDB
-		_ = db.Close()
-		return fmt.Errorf("create decisions workspace index: %w", err)
+	if err := EnsureSearchIndex(); err != nil {
+		restore()
+		return fmt.Errorf("ensure search index: %w", err)
 	}
-	if _, err := DB.Exec(`CREATE INDEX IF NOT EXISTS idx_decisions_user_id ON decisions(user_id);`); err != nil {
-		DB = previousDB
-		_ = db.Close()
-		return fmt.Errorf("create decisions user index: %w", err)
+
+	return nil
+}
+
+// OpenDBWithPath opens and configures the database without applying migrations.
+// It is used by the migrate subcommand to inspect the schema before changing it.
+func OpenDBWithPath(path string) error {
+	if strings.TrimSpace(path) == "" {
+		path = defaultDBPath
 	}
+	return openDB(DialectSQLite, "sqlite3", buildDSN(path))
+}
 
-	if err := ensureWorkspaceOwnerMemberships(); err != nil {
-		DB = previousDB
-		_ = db.Close()
+// OpenDBWithURL is OpenDBWithPath for a DATABASE_URL.
+func OpenDBWithURL(databaseURL string) error {
+	dialect, driverName, dsn, err := ParseDatabaseURL(databaseURL)
+	if err != nil {
 		return err
 	}
-	if err := cleanupOrphanIntegrations(); err != nil {
-		DB = previousDB
-		_ = db.Close()
-		return err
+	return openDB(dialect, driverName, dsn)
+}
+
+func openDB(dialect Dialect, driverName, dsn string) error {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
 	}
 
+	previousDB, previousDialect := DB, currentDialect
+	DB, currentDialect = db, dialect
+
+	if dialect == DialectSQLite {
+		err = configureSQLite()
+	} else {
+		err = db.Ping()
+	}
+	if err != nil {
+		DB, currentDialect = previousDB, previousDialect
+		_ = db.Close()
+		return fmt.Errorf("connect database: %w", err)
+	}
 	return nil
 }
 
@@ -257,45 +145,6 @@ func configureSQLite() error {
 	return nil
 }
 
-func ensureColumn(tableName, columnName, columnDefinition string) error {
-	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
-	if err != nil {
-		return fmt.Errorf("inspect table %s: %w", tableName, err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var (
-			cid        int
-			name       string
-			columnType string
-			notNull    int
-			defaultVal sql.NullString
-			pk         int
-		)
-		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &pk); err != nil {
-			return fmt.Errorf("scan table %s column info: %w", tableName, err)
-		}
-		if name == columnName {
-			return nil
-		}
-	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("iterate table %s columns: %w", tableName, err)
-	}
-
-	statement := fmt.Sprintf(
-		"ALTER TABLE %s ADD COLUMN %s %s",
-		tableName,
-		columnName,
-		columnDefinition,
-	)
-	if _, err := DB.Exec(statement); err != nil {
-		return fmt.Errorf("add column %s.%s: %w", tableName, columnName, err)
-	}
-	return nil
-}
-
 func ensureWorkspaceOwnerMemberships() error {
 	if _, err := DB.Exec(`
 		INSERT INTO workspace_members (workspace_id, user_id, role, updated_at)
@@ -0,0 +1,160 @@
+// Package dbtest opens migrated databases for tests. Tests run against SQLite,
+// or against PostgreSQL when TEST_DATABASE_URL is set, so the same tests cover
+// both dialects in CI.
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sentinent-backend/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+// PostgresURL returns TEST_DATABASE_URL, or "" when PostgreSQL tests are off.
+func PostgresURL() string {
+	return strings.TrimSpace(os.Getenv("TEST_DATABASE_URL"))
+}
+
+// Open swaps database.DB for a freshly migrated database: a throwaway schema
+// in TEST_DATABASE_URL when it is set, a temporary SQLite file otherwise. The
+// previous database is restored when the test ends.
+func Open(t testing.TB) {
+	t.Helper()
+
+	if postgresURL := PostgresURL(); postgresURL != "" {
+		OpenURL(t, postgresSchemaURL(t, postgresURL))
+		return
+	}
+	OpenSQLite(t)
+}
+
+// OpenSQLite is Open for a temporary SQLite file, whatever TEST_DATABASE_URL
+// says.
+func OpenSQLite(t testing.TB) {
+	t.Helper()
+	OpenURL(t, "sqlite://"+filepath.Join(t.TempDir(), "test.db"))
+}
+
+// OpenPostgres is Open for a throwaway schema in TEST_DATABASE_URL. It skips
+// the test when the variable is unset.
+func OpenPostgres(t testing.TB) {
+	t.Helper()
+
+	postgresURL := PostgresURL()
+	if postgresURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	OpenURL(t, postgresSchemaURL(t, postgresURL))
+}
+
+// OpenURL migrates the database at databaseURL and makes it database.DB until
+// the test ends.
+func OpenURL(t testing.TB, databaseURL string) {
+	t.Helper()
+
+	originalDB := database.DB
+	if err := database.InitDBWithURL(databaseURL); err != nil {
+		t.Fatalf("InitDBWithURL returned error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = database.DB.Close()
+		database.DB = originalDB
+	})
+}
+
+// Seed runs statements against database.DB. Rows may be inserted with explicit
+// ids: on PostgreSQL the id sequences are then moved past them, so rows the
+// code under test inserts afterwards get fresh ids as they would on SQLite.
+func Seed(t testing.TB, statements ...string) {
+	t.Helper()
+
+	for _, statement := range statements {
+		if _, err := database.DB.Exec(statement); err != nil {
+			t.Fatalf("failed to seed %q: %v", statement, err)
+		}
+	}
+	if database.CurrentDialect() == database.DialectPostgres {
+		syncSequences(t)
+	}
+}
+
+// Exec is Seed for a single statement with arguments.
+func Exec(t testing.TB, statement string, args ...any) {
+	t.Helper()
+
+	if _, err := database.DB.Exec(statement, args...); err != nil {
+		t.Fatalf("failed to seed %q: %v", statement, err)
+	}
+	if database.CurrentDialect() == database.DialectPostgres {
+		syncSequences(t)
+	}
+}
+
+// syncSequences moves every serial sequence in the current schema past the
+// largest id in its table.
+func syncSequences(t testing.TB) {
+	t.Helper()
+
+	rows, err := database.DB.Query(
+		`SELECT table_name, column_name FROM information_schema.columns
+		 WHERE table_schema = current_schema() AND column_default LIKE 'nextval%'`,
+	)
+	if err != nil {
+		t.Fatalf("failed to list sequences: %v", err)
+	}
+	type serialColumn struct{ table, column string }
+	var columns []serialColumn
+	for rows.Next() {
+		var column serialColumn
+		if err := rows.Scan(&column.table, &column.column); err != nil {
+			_ = rows.Close()
+			t.Fatalf("failed to scan sequence: %v", err)
+		}
+		columns = append(columns, column)
+	}
+	_ = rows.Close()
+
+	for _, column := range columns {
+		query := fmt.Sprintf(
+			`SELECT setval(pg_get_serial_sequence('%[1]s', '%[2]s'), COALESCE(MAX(%[2]s), 0) + 1, false) FROM %[1]s`,
+			column.table, column.column,
+		)
+		if _, err := database.DB.Exec(query); err != nil {
+			t.Fatalf("failed to advance %s.%s sequence: %v", column.table, column.column, err)
+		}
+	}
+}
+
+// postgresSchemaURL creates an empty schema for the test and returns a URL
+// whose search_path points at it. The schema is dropped when the test ends.
+func postgresSchemaURL(t testing.TB, baseURL string) string {
+	t.Helper()
+
+	admin, err := sql.Open("postgres", baseURL)
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		_ = admin.Close()
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		_ = admin.Close()
+	})
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
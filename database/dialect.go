@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect identifies the SQL backend behind DB.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+var currentDialect = DialectSQLite
+
+// CurrentDialect reports which backend DB was opened with. Tests that assign a
+// SQLite handle to DB directly get the SQLite default.
+func CurrentDialect() Dialect {
+	return currentDialect
+}
+
+// ParseDatabaseURL maps a DATABASE_URL to a dialect, driver name and DSN.
+// Supported forms are postgres://, postgresql://, sqlite://<path> and file:<path>.
+func ParseDatabaseURL(rawURL string) (Dialect, string, string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	switch {
+	case strings.HasPrefix(rawURL, "postgres://"), strings.HasPrefix(rawURL, "postgresql://"):
+		return DialectPostgres, postgresDriverName, rawURL, nil
+	case strings.HasPrefix(rawURL, "sqlite://"):
+		path := strings.TrimPrefix(rawURL, "sqlite://")
+		if path == "" {
+			path = defaultDBPath
+		}
+		return DialectSQLite, "sqlite3", buildDSN(path), nil
+	case strings.HasPrefix(rawURL, "file:"):
+		return DialectSQLite, "sqlite3", rawURL, nil
+	default:
+		scheme := rawURL
+		if index := strings.Index(rawURL, ":"); index >= 0 {
+			scheme = rawURL[:index]
+		}
+		return "", "", "", fmt.Errorf("unsupported DATABASE_URL scheme %q", scheme)
+	}
+}
+
+// Rebind rewrites `?` placeholders to PostgreSQL's `$n` form. Question marks
+// inside quoted strings and identifiers are left alone.
+func Rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(query) + 8)
+	argIndex := 0
+	var quote rune
+	for _, char := range query {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '?':
+			argIndex++
+			fmt.Fprintf(&builder, "$%d", argIndex)
+			continue
+		}
+		builder.WriteRune(char)
+	}
+	return builder.String()
+}
+
+// translateDDL adapts schema statements written for SQLite to the current
+// dialect, so migrations can be written once.
+func translateDDL(statement string) string {
+	if currentDialect != DialectPostgres {
+		return statement
+	}
+	replacer := strings.NewReplacer(
+		"INTEGER PRIMARY KEY AUTOINCREMENT", "SERIAL PRIMARY KEY",
+		"DATETIME", "TIMESTAMPTZ",
+	)
+	return replacer.Replace(statement)
+}
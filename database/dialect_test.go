@@ -0,0 +1,50 @@
+package database
+
+import "testing"
+
+func TestParseDatabaseURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		dialect    Dialect
+		driverName string
+		dsn        string
+	}{
+		{"postgres://app:secret@db:5432/sentinent?sslmode=disable", DialectPostgres, postgresDriverName, "postgres://app:secret@db:5432/sentinent?sslmode=disable"},
+		{"postgresql://db/sentinent", DialectPostgres, postgresDriverName, "postgresql://db/sentinent"},
+		{"sqlite:///var/lib/sentinent.db", DialectSQLite, "sqlite3", "/var/lib/sentinent.db?_busy_timeout=5000&_journal_mode=wal&_foreign_keys=on"},
+		{"file:test.db?mode=memory", DialectSQLite, "sqlite3", "file:test.db?mode=memory"},
+	}
+	for _, test := range tests {
+		dialect, driverName, dsn, err := ParseDatabaseURL(test.url)
+		if err != nil {
+			t.Fatalf("ParseDatabaseURL(%q) returned error: %v", test.url, err)
+		}
+		if dialect != test.dialect || driverName != test.driverName || dsn != test.dsn {
+			t.Fatalf("ParseDatabaseURL(%q) = %q, %q, %q", test.url, dialect, driverName, dsn)
+		}
+	}
+
+	if _, _, _, err := ParseDatabaseURL("mysql://db/sentinent"); err == nil {
+		t.Fatal("expected an unsupported scheme to be rejected")
+	}
+}
+
+func TestRebindSkipsQuotedQuestionMarks(t *testing.T) {
+	query := `SELECT id FROM signals WHERE title = 'why?' AND user_id = ? AND "odd?" = ?`
+	want := `SELECT id FROM signals WHERE title = 'why?' AND user_id = $1 AND "odd?" = $2`
+	if got := Rebind(query); got != want {
+		t.Fatalf("Rebind returned %q, want %q", got, want)
+	}
+}
+
+func TestTranslateDDLForPostgres(t *testing.T) {
+	originalDialect := currentDialect
+	currentDialect = DialectPostgres
+	t.Cleanup(func() { currentDialect = originalDialect })
+
+	got := translateDDL(`CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, seen_at DATETIME)`)
+	want := `CREATE TABLE t (id SERIAL PRIMARY KEY, seen_at TIMESTAMPTZ)`
+	if got != want {
+		t.Fatalf("translateDDL returned %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,842 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Migration is a numbered schema change. Each migration runs in its own
+// transaction together with the schema_migrations row that records it, so a
+// failure leaves the database at the previous version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// MigrationState reports whether a known migration has been applied.
+type MigrationState struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer build.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build supports")
+
+// migrations must stay ordered by version. Never edit a migration that has
+// shipped; add a new one instead. Statements are written for SQLite and run
+// through translateDDL, so they must stick to types it knows how to map.
+var migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: migrateBaseline},
+	{Version: 2, Name: "sessions", Up: migrateSessions},
+	{Version: 3, Name: "mfa", Up: migrateMFA},
+	{Version: 4, Name: "search_index", Up: migrateSearchIndex},
+	{Version: 5, Name: "decision_signals", Up: migrateDecisionSignals},
+	{Version: 6, Name: "decision_workflow", Up: migrateDecisionWorkflow},
+	{Version: 7, Name: "sync_jobs", Up: migrateSyncJobs},
+	{Version: 8, Name: "sync_runs", Up: migrateSyncRuns},
+	{Version: 9, Name: "integration_reauth", Up: migrateIntegrationReauth},
+	{Version: 10, Name: "jira_site_source_ids", Up: migrateJiraSiteSourceIDs},
+	{Version: 11, Name: "shared_workspace_signals", Up: migrateSharedWorkspaceSignals},
+	{Version: 12, Name: "signal_triage", Up: migrateSignalTriage},
+	{Version: 13, Name: "signal_rules", Up: migrateSignalRules},
+	{Version: 14, Name: "outgoing_webhooks", Up: migrateOutgoingWebhooks},
+	{Version: 15, Name: "mfa_lockout", Up: migrateMFALockout},
+	{Version: 16, Name: "sync_job_leases", Up: migrateSyncJobLeases},
+	{Version: 17, Name: "sync_run_failures", Up: migrateSyncRunFailures},
+	{Version: 18, Name: "owner_mfa_deadline", Up: migrateOwnerMFADeadline},
+}
+
+// LatestSchemaVersion returns the highest migration version known to this build.
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the highest migration version applied to the database.
+func SchemaVersion() (int, error) {
+	if err := ensureSchemaMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	if err := DB.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// CheckSchemaVersion refuses to continue when the database is ahead of this build.
+func CheckSchemaVersion() (int, error) {
+	version, err := SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version > LatestSchemaVersion() {
+		return version, fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, version, LatestSchemaVersion())
+	}
+	return version, nil
+}
+
+// MigrationStatus lists every known migration and when it was applied.
+func MigrationStatus() ([]MigrationState, error) {
+	if err := ensureSchemaMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate schema migrations: %w", err)
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, migration := range migrations {
+		state := MigrationState{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			state.AppliedAt = &appliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// MigrateUp applies all pending migrations in order and returns the ones it ran.
+// Databases created before versioning have no schema_migrations rows; the
+// baseline migration is idempotent, so they are adopted as version 1.
+func MigrateUp() ([]Migration, error) {
+	current, err := CheckSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0)
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		ran, err := applyMigration(migration)
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// migrationLockID is the PostgreSQL advisory lock that serialises migrations
+// when several replicas start at once.
+const migrationLockID = 7405112
+
+func applyMigration(migration Migration) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("begin migration %d: %w", migration.Version, err)
+	}
+
+	if currentDialect == DialectPostgres {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID); err != nil {
+			_ = tx.Rollback()
+			return false, fmt.Errorf("lock migrations: %w", err)
+		}
+	}
+
+	// Another replica may have applied this migration while we waited.
+	var alreadyApplied int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = ?", migration.Version).Scan(&alreadyApplied); err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("check migration %d: %w", migration.Version, err)
+	}
+	if alreadyApplied > 0 {
+		return false, tx.Rollback()
+	}
+
+	if err := migration.Up(tx); err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("apply migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now().UTC(),
+	); err != nil {
+		_ = tx.Rollback()
+		return false, fmt.Errorf("record migration %d: %w", migration.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit migration %d: %w", migration.Version, err)
+	}
+	return true, nil
+}
+
+func ensureSchemaMigrationsTable() error {
+	if _, err := DB.Exec(translateDDL(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	);`)); err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func execStatements(tx *sql.Tx, statements []string) error {
+	for _, statement := range statements {
+		if _, err := tx.Exec(translateDDL(statement)); err != nil {
+			return fmt.Errorf("run schema statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateBaseline creates the schema as it stood before versioned migrations.
+// It also adds columns that older unversioned databases may be missing.
+func migrateBaseline(tx *sql.Tx) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL UNIQUE,
+			password TEXT NOT NULL,
+			full_name TEXT DEFAULT '',
+			job_title TEXT DEFAULT '',
+			organization TEXT DEFAULT '',
+			timezone TEXT DEFAULT '',
+			bio TEXT DEFAULT '',
+			role_label TEXT DEFAULT ''
+		);`,
+		`CREATE TABLE IF NOT EXISTS workspaces (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			owner_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (owner_id) REFERENCES users(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS decisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			workspace_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT,
+			status TEXT NOT NULL CHECK (status IN ('DRAFT', 'OPEN', 'CLOSED')),
+			due_date DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS workspace_members (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			workspace_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL CHECK (role IN ('owner', 'member', 'viewer')),
+			joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(workspace_id, user_id),
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS invitations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			workspace_id INTEGER NOT NULL,
+			email TEXT NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			role TEXT NOT NULL CHECK (role IN ('member', 'viewer')),
+			expires_at DATETIME NOT NULL,
+			created_by INTEGER NOT NULL,
+			accepted_at DATETIME,
+			accepted_by INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
+			FOREIGN KEY (created_by) REFERENCES users(id),
+			FOREIGN KEY (accepted_by) REFERENCES users(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS external_integrations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			workspace_id INTEGER,
+			provider TEXT NOT NULL,
+			access_token TEXT NOT NULL,
+			refresh_token TEXT,
+			expires_at DATETIME,
+			metadata TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS signals (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			workspace_id INTEGER,
+			source_type TEXT NOT NULL,
+			source_id TEXT NOT NULL,
+			external_id TEXT,
+			title TEXT NOT NULL,
+			content TEXT,
+			author TEXT,
+			body TEXT,
+			url TEXT,
+			status TEXT DEFAULT 'unread',
+			source_metadata TEXT,
+			received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS signal_status (
+			signal_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (signal_id, user_id),
+			FOREIGN KEY (signal_id) REFERENCES signals(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+	}
+	if err := execStatements(tx, statements); err != nil {
+		return err
+	}
+
+	columns := []struct {
+		table      string
+		name       string
+		definition string
+	}{
+		{"external_integrations", "workspace_id", "INTEGER"},
+		{"external_integrations", "refresh_token", "TEXT"},
+		{"external_integrations", "expires_at", "DATETIME"},
+		{"external_integrations", "metadata", "TEXT"},
+		{"workspaces", "description", "TEXT DEFAULT ''"},
+		{"users", "full_name", "TEXT DEFAULT ''"},
+		{"users", "job_title", "TEXT DEFAULT ''"},
+		{"users", "organization", "TEXT DEFAULT ''"},
+		{"users", "timezone", "TEXT DEFAULT ''"},
+		{"users", "bio", "TEXT DEFAULT ''"},
+		{"users", "role_label", "TEXT DEFAULT ''"},
+		{"signals", "workspace_id", "INTEGER"},
+		{"signals", "external_id", "TEXT"},
+		{"signals", "content", "TEXT"},
+		{"signals", "author", "TEXT"},
+		{"signals", "body", "TEXT"},
+		{"signals", "url", "TEXT"},
+		{"signals", "source_metadata", "TEXT"},
+		{"signals", "received_at", "DATETIME DEFAULT CURRENT_TIMESTAMP"},
+		{"signals", "updated_at", "DATETIME DEFAULT CURRENT_TIMESTAMP"},
+	}
+	for _, column := range columns {
+		if err := addMissingColumn(tx, column.table, column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	return execStatements(tx, []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_external_integrations_user_provider_workspace
+			ON external_integrations(user_id, provider, COALESCE(workspace_id, 0));`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_signals_user_source
+			ON signals(user_id, source_type, source_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_signals_user_id ON signals(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_signals_workspace_id ON signals(workspace_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_signals_received_at ON signals(received_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_integrations_user_id ON external_integrations(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_integrations_workspace_id ON external_integrations(workspace_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_workspace_members_workspace_id ON workspace_members(workspace_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_invitations_workspace_id ON invitations(workspace_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations(email);`,
+		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires_at ON password_reset_tokens(expires_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_decisions_workspace_id ON decisions(workspace_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_decisions_user_id ON decisions(user_id);`,
+	})
+}
+
+// addMissingColumn adds a column to a table if it does not exist yet. Only the
+// baseline migration needs this; later migrations know the exact schema they
+// start from.
+func addMissingColumn(tx *sql.Tx, tableName, columnName, columnDefinition string) error {
+	if currentDialect == DialectPostgres {
+		statement := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", tableName, columnName, columnDefinition)
+		if _, err := tx.Exec(translateDDL(statement)); err != nil {
+			return fmt.Errorf("add column %s.%s: %w", tableName, columnName, err)
+		}
+		return nil
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
+	if err != nil {
+		return fmt.Errorf("inspect table %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &pk); err != nil {
+			return fmt.Errorf("scan table %s column info: %w", tableName, err)
+		}
+		if name == columnName {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate table %s columns: %w", tableName, err)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	statement := fmt.Sprintf(
+		"ALTER TABLE %s ADD COLUMN %s %s",
+		tableName,
+		columnName,
+		columnDefinition,
+	)
+	if _, err := tx.Exec(statement); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", tableName, columnName, err)
+	}
+	return nil
+}
+
+// migrateSessions adds server-side sessions backing refresh tokens, and records
+// when a user's password last changed so older access tokens can be rejected.
+func migrateSessions(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			refresh_token_hash TEXT NOT NULL UNIQUE,
+			previous_refresh_token_hash TEXT,
+			user_agent TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME,
+			expires_at DATETIME NOT NULL,
+			revoked_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_previous_refresh_token_hash ON sessions(previous_refresh_token_hash);`,
+		`ALTER TABLE users ADD COLUMN password_changed_at DATETIME;`,
+	})
+}
+
+// migrateMFA adds TOTP enrollment state to users, one-time recovery codes, and
+// a per-workspace switch that makes MFA mandatory for its members.
+func migrateMFA(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`ALTER TABLE users ADD COLUMN mfa_secret TEXT;`,
+		`ALTER TABLE users ADD COLUMN mfa_enabled_at DATETIME;`,
+		`ALTER TABLE users ADD COLUMN mfa_last_used_step INTEGER;`,
+		`CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			UNIQUE(user_id, code_hash)
+		);`,
+		`ALTER TABLE workspaces ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT FALSE;`,
+	})
+}
+
+// migrateSearchIndex builds the FTS5 indexes for workspace search. It is a
+// no-op on PostgreSQL and on SQLite builds without FTS5; initDB creates the
+// indexes later if the binary gains FTS5.
+func migrateSearchIndex(tx *sql.Tx) error {
+	_, err := ensureSearchIndex(tx)
+	return err
+}
+
+// migrateDecisionSignals links signals to the decisions they are evidence for.
+func migrateDecisionSignals(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`CREATE TABLE IF NOT EXISTS decision_signals (
+			decision_id INTEGER NOT NULL,
+			signal_id INTEGER NOT NULL,
+			linked_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (decision_id, signal_id),
+			FOREIGN KEY (decision_id) REFERENCES decisions(id),
+			FOREIGN KEY (signal_id) REFERENCES signals(id),
+			FOREIGN KEY (linked_by) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_decision_signals_signal_id ON decision_signals(signal_id);`,
+	})
+}
+
+// migrateDecisionWorkflow adds candidate options to decisions, ranked ballots
+// cast on them while a decision is open, and the outcome recorded on closing.
+func migrateDecisionWorkflow(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`CREATE TABLE IF NOT EXISTS decision_options (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			decision_id INTEGER NOT NULL,
+			label TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			position INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (decision_id) REFERENCES decisions(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_decision_options_decision_id ON decision_options(decision_id);`,
+		`CREATE TABLE IF NOT EXISTS decision_votes (
+			decision_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			option_id INTEGER NOT NULL,
+			preference INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (decision_id, user_id, option_id),
+			UNIQUE(decision_id, user_id, preference),
+			FOREIGN KEY (decision_id) REFERENCES decisions(id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (option_id) REFERENCES decision_options(id)
+		);`,
+		`ALTER TABLE decisions ADD COLUMN outcome_option_id INTEGER;`,
+		`ALTER TABLE decisions ADD COLUMN outcome_rationale TEXT;`,
+		`ALTER TABLE decisions ADD COLUMN closed_by INTEGER;`,
+		`ALTER TABLE decisions ADD COLUMN closed_at DATETIME;`,
+	})
+}
+
+// migrateSyncJobs adds the durable queue of integration syncs. The partial
+// unique index allows at most one pending or running job per integration.
+// Jobs are kept as history after their integration is disconnected, so
+// integration_id has no foreign key.
+func migrateSyncJobs(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`CREATE TABLE IF NOT EXISTS sync_jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			integration_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			workspace_id INTEGER,
+			provider TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL,
+			last_error TEXT,
+			run_at DATETIME NOT NULL,
+			started_at DATETIME,
+			finished_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sync_jobs_active_integration
+			ON sync_jobs(integration_id) WHERE status IN ('pending', 'running');`,
+		`CREATE INDEX IF NOT EXISTS idx_sync_jobs_status_run_at ON sync_jobs(status, run_at);`,
+	})
+}
+
+// migrateSyncRuns adds the history of sync executions behind the integration
+// health API.
+func migrateSyncRuns(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`CREATE TABLE IF NOT EXISTS sync_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			integration_id INTEGER NOT NULL,
+			job_id INTEGER,
+			user_id INTEGER NOT NULL,
+			workspace_id INTEGER,
+			provider TEXT NOT NULL,
+			status TEXT NOT NULL,
+			items_fetched INTEGER NOT NULL DEFAULT 0,
+			items_upserted INTEGER NOT NULL DEFAULT 0,
+			error TEXT,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME,
+			FOREIGN KEY (job_id) REFERENCES sync_jobs(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_sync_runs_integration ON sync_runs(integration_id, id);`,
+	})
+}
+
+// migrateIntegrationReauth records when a provider rejected an integration's
+// credentials, so the scheduler can skip it until the user reconnects.
+func migrateIntegrationReauth(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`ALTER TABLE external_integrations ADD COLUMN reauth_required_at DATETIME;`,
+		`ALTER TABLE external_integrations ADD COLUMN reauth_reason TEXT;`,
+	})
+}
+
+// migrateJiraSiteSourceIDs prefixes Jira signal source IDs with the cloud ID of
+// their site, because issue IDs are only unique within one Atlassian site and
+// a workspace may now sync several. Integrations that never recorded their
+// site are left alone.
+func migrateJiraSiteSourceIDs(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT user_id, workspace_id, metadata FROM external_integrations WHERE provider = 'jira'")
+	if err != nil {
+		return err
+	}
+
+	type jiraSite struct {
+		userID      int
+		workspaceID sql.NullInt64
+		cloudID     string
+	}
+	var sites []jiraSite
+	for rows.Next() {
+		var site jiraSite
+		var metadataJSON sql.NullString
+		if err := rows.Scan(&site.userID, &site.workspaceID, &metadataJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		var metadata struct {
+			CloudID string `json:"cloud_id"`
+		}
+		if metadataJSON.Valid && metadataJSON.String != "" {
+			_ = json.Unmarshal([]byte(metadataJSON.String), &metadata)
+		}
+		if metadata.CloudID != "" {
+			site.cloudID = metadata.CloudID
+			sites = append(sites, site)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, site := range sites {
+		if _, err := tx.Exec(
+			`UPDATE signals SET source_id = CAST(? AS TEXT) || ':' || source_id
+			 WHERE user_id = ? AND COALESCE(workspace_id, 0) = ? AND source_type = 'jira' AND source_id NOT LIKE '%:%'`,
+			site.cloudID, site.userID, site.workspaceID.Int64,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSharedWorkspaceSignals makes a workspace's signals shared by its
+// members. Rows that members imported separately for the same item are merged
+// into the oldest one, which takes over their decision links and each
+// member's read state, and uniqueness moves from the importing user to the
+// workspace. Signals outside a workspace (Gmail) stay unique per user.
+func migrateSharedWorkspaceSignals(tx *sql.Tx) error {
+	rows, err := tx.Query(
+		`SELECT s.id, keep.id
+		 FROM signals s
+		 JOIN (SELECT workspace_id, source_type, source_id, MIN(id) AS id
+		       FROM signals
+		       WHERE workspace_id IS NOT NULL
+		       GROUP BY workspace_id, source_type, source_id
+		       HAVING COUNT(*) > 1) keep
+		   ON s.workspace_id = keep.workspace_id AND s.source_type = keep.source_type AND s.source_id = keep.source_id
+		 WHERE s.id <> keep.id`,
+	)
+	if err != nil {
+		return err
+	}
+
+	type duplicate struct{ id, keepID int64 }
+	var duplicates []duplicate
+	for rows.Next() {
+		var d duplicate
+		if err := rows.Scan(&d.id, &d.keepID); err != nil {
+			rows.Close()
+			return err
+		}
+		duplicates = append(duplicates, d)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, d := range duplicates {
+		statements := []string{
+			`UPDATE decision_signals SET signal_id = ?
+			 WHERE signal_id = ? AND decision_id NOT IN (SELECT decision_id FROM decision_signals WHERE signal_id = ?)`,
+			`UPDATE signal_status SET signal_id = ?
+			 WHERE signal_id = ? AND user_id NOT IN (SELECT user_id FROM signal_status WHERE signal_id = ?)`,
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement, d.keepID, d.id, d.keepID); err != nil {
+				return err
+			}
+		}
+		for _, statement := range []string{
+			`DELETE FROM decision_signals WHERE signal_id = ?`,
+			`DELETE FROM signal_status WHERE signal_id = ?`,
+			`DELETE FROM signals WHERE id = ?`,
+		} {
+			if _, err := tx.Exec(statement, d.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return execStatements(tx, []string{
+		`DROP INDEX IF EXISTS idx_signals_user_source;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_signals_workspace_source
+			ON signals(workspace_id, source_type, source_id) WHERE workspace_id IS NOT NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_signals_personal_source
+			ON signals(user_id, source_type, source_id) WHERE workspace_id IS NULL;`,
+	})
+}
+
+// migrateSignalTriage adds a shared assignee to signals and a per-member
+// snooze deadline to signal_status. The index serves the scheduler that wakes
+// expired snoozes.
+func migrateSignalTriage(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`ALTER TABLE signals ADD COLUMN assignee_id INTEGER REFERENCES users(id);`,
+		`ALTER TABLE signal_status ADD COLUMN snoozed_until DATETIME;`,
+		`CREATE INDEX IF NOT EXISTS idx_signal_status_snoozed ON signal_status(status, snoozed_until);`,
+	})
+}
+
+// migrateSignalRules adds workspace rules and the labels and priority they
+// set on signals. signal_rule_matches records which rules already acted on a
+// signal, so a later sync of the same item does not act again.
+func migrateSignalRules(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`CREATE TABLE IF NOT EXISTS signal_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			workspace_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			conditions TEXT NOT NULL,
+			actions TEXT NOT NULL,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
+			FOREIGN KEY (created_by) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_signal_rules_workspace ON signal_rules(workspace_id, position, id);`,
+		`CREATE TABLE IF NOT EXISTS signal_rule_matches (
+			rule_id INTEGER NOT NULL,
+			signal_id INTEGER NOT NULL,
+			matched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (rule_id, signal_id),
+			FOREIGN KEY (rule_id) REFERENCES signal_rules(id),
+			FOREIGN KEY (signal_id) REFERENCES signals(id)
+		);`,
+		`ALTER TABLE signals ADD COLUMN labels TEXT;`,
+		`ALTER TABLE signals ADD COLUMN priority TEXT;`,
+	})
+}
+
+// migrateOutgoingWebhooks adds the endpoints owners register to hear about
+// workspace events, and the log of deliveries to them. A delivery stays
+// pending, with its next attempt scheduled, until it succeeds or runs out of
+// attempts.
+func migrateOutgoingWebhooks(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`CREATE TABLE IF NOT EXISTS outgoing_webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			workspace_id INTEGER NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id),
+			FOREIGN KEY (created_by) REFERENCES users(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_outgoing_webhooks_workspace ON outgoing_webhooks(workspace_id);`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME,
+			last_attempt_at DATETIME,
+			response_status INTEGER,
+			last_error TEXT,
+			redelivery_of INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			delivered_at DATETIME,
+			FOREIGN KEY (webhook_id) REFERENCES outgoing_webhooks(id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);`,
+	})
+}
+
+// migrateMFALockout counts each user's consecutive wrong second factors, so a
+// lockout holds across sign-ins, restarts and instances, and their completed
+// MFA logins, which retire the challenges issued before them.
+func migrateMFALockout(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`ALTER TABLE users ADD COLUMN mfa_failed_attempts INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE users ADD COLUMN mfa_locked_until DATETIME;`,
+		`ALTER TABLE users ADD COLUMN mfa_logins INTEGER NOT NULL DEFAULT 0;`,
+	})
+}
+
+// migrateSyncJobLeases gives running sync jobs a lease that their worker keeps
+// renewing, so only jobs whose worker stopped are queued again. Jobs running
+// when it is applied were started by a build without heartbeats and get a
+// lease that has already run out.
+func migrateSyncJobLeases(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`ALTER TABLE sync_jobs ADD COLUMN lease_expires_at DATETIME;`,
+		`UPDATE sync_jobs SET lease_expires_at = started_at WHERE status = 'running';`,
+	})
+}
+
+// migrateSyncRunFailures counts the items a finished run could not fetch or
+// store, so a run that only partly succeeded shows up in the integration's
+// health.
+func migrateSyncRunFailures(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`ALTER TABLE sync_runs ADD COLUMN items_failed INTEGER NOT NULL DEFAULT 0;`,
+	})
+}
+
+// migrateOwnerMFADeadline records when a workspace owner's grace period for
+// enrolling in MFA runs out. It is set the first time the user is checked as an
+// owner.
+func migrateOwnerMFADeadline(tx *sql.Tx) error {
+	return execStatements(tx, []string{
+		`ALTER TABLE users ADD COLUMN mfa_required_by DATETIME;`,
+	})
+}
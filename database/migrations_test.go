@@ -0,0 +1,267 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	originalDB := DB
+	if err := OpenDBWithPath(filepath.Join(t.TempDir(), "migrations.db")); err != nil {
+		t.Fatalf("OpenDBWithPath returned error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = DB.Close()
+		DB = originalDB
+	})
+}
+
+func TestMigrateUpRecordsVersionsOnFreshDatabase(t *testing.T) {
+	openTestDB(t)
+
+	applied, err := MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp returned error: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("expected %d migrations to be applied, got %d", len(migrations), len(applied))
+	}
+
+	version, err := SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion returned error: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Fatalf("expected schema version %d, got %d", LatestSchemaVersion(), version)
+	}
+
+	applied, err = MigrateUp()
+	if err != nil {
+		t.Fatalf("second MigrateUp returned error: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Fatalf("expected no pending migrations, got %d", len(applied))
+	}
+
+	states, err := MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus returned error: %v", err)
+	}
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			t.Fatalf("expected migration %d to be applied", state.Version)
+		}
+	}
+}
+
+func TestMigrateUpAdoptsUnversionedDatabase(t *testing.T) {
+	openTestDB(t)
+
+	// A database from before versioning: tables exist, some columns are missing,
+	// and there is no schema_migrations table.
+	if _, err := DB.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT NOT NULL UNIQUE, password TEXT NOT NULL);`); err != nil {
+		t.Fatalf("failed to create legacy users table: %v", err)
+	}
+	if _, err := DB.Exec(`INSERT INTO users (email, password) VALUES ('legacy@example.com', 'hash')`); err != nil {
+		t.Fatalf("failed to seed legacy user: %v", err)
+	}
+
+	if _, err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp returned error: %v", err)
+	}
+
+	var email, fullName string
+	if err := DB.QueryRow("SELECT email, full_name FROM users").Scan(&email, &fullName); err != nil {
+		t.Fatalf("expected legacy user with backfilled columns: %v", err)
+	}
+	if email != "legacy@example.com" || fullName != "" {
+		t.Fatalf("unexpected legacy user row: email=%q full_name=%q", email, fullName)
+	}
+
+	version, err := SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion returned error: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Fatalf("expected adopted database at version %d, got %d", LatestSchemaVersion(), version)
+	}
+}
+
+func TestMigrateUpRefusesNewerSchema(t *testing.T) {
+	openTestDB(t)
+
+	if _, err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp returned error: %v", err)
+	}
+	if _, err := DB.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from_the_future', CURRENT_TIMESTAMP)",
+		LatestSchemaVersion()+1,
+	); err != nil {
+		t.Fatalf("failed to record future migration: %v", err)
+	}
+
+	if _, err := MigrateUp(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestMigrateUpRollsBackFailedMigration(t *testing.T) {
+	openTestDB(t)
+
+	originalMigrations := migrations
+	migrations = append(append([]Migration{}, originalMigrations...), Migration{
+		Version: LatestSchemaVersion() + 1,
+		Name:    "broken",
+		Up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("THIS IS NOT SQL")
+			return err
+		},
+	})
+	t.Cleanup(func() { migrations = originalMigrations })
+
+	applied, err := MigrateUp()
+	if err == nil {
+		t.Fatal("expected broken migration to fail")
+	}
+	if len(applied) != len(originalMigrations) {
+		t.Fatalf("expected earlier migrations to stay applied, got %d", len(applied))
+	}
+
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&count); err != nil {
+		t.Fatalf("failed to inspect schema: %v", err)
+	}
+	if count != 0 {
+		t.Fatal("expected failed migration to be rolled back")
+	}
+
+	version, err := SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion returned error: %v", err)
+	}
+	if version != len(originalMigrations) {
+		t.Fatalf("expected schema version %d after failure, got %d", len(originalMigrations), version)
+	}
+}
+
+func TestMigrateUpPrefixesJiraSourceIDsWithSite(t *testing.T) {
+	openTestDB(t)
+
+	originalMigrations := migrations
+	migrations = originalMigrations[:9]
+	if _, err := MigrateUp(); err != nil {
+		migrations = originalMigrations
+		t.Fatalf("MigrateUp to version 9 returned error: %v", err)
+	}
+	migrations = originalMigrations
+
+	statements := []string{
+		`INSERT INTO users (id, email, password) VALUES (1, 'jira@example.com', 'hash')`,
+		`INSERT INTO workspaces (id, name, owner_id) VALUES (5, 'Ops', 1)`,
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (1, 5, 'jira', 'token', '{"cloud_id":"cloud-1"}')`,
+		`INSERT INTO signals (user_id, workspace_id, source_type, source_id, title) VALUES
+		 (1, 5, 'jira', '10001', 'Jira issue'),
+		 (1, 5, 'slack', 'C1:1710000000.000100', 'Slack message')`,
+	}
+	for _, statement := range statements {
+		if _, err := DB.Exec(statement); err != nil {
+			t.Fatalf("failed to seed %q: %v", statement, err)
+		}
+	}
+
+	if _, err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp returned error: %v", err)
+	}
+
+	var jiraSourceID, slackSourceID string
+	if err := DB.QueryRow("SELECT source_id FROM signals WHERE source_type = 'jira'").Scan(&jiraSourceID); err != nil {
+		t.Fatalf("failed to load Jira signal: %v", err)
+	}
+	if err := DB.QueryRow("SELECT source_id FROM signals WHERE source_type = 'slack'").Scan(&slackSourceID); err != nil {
+		t.Fatalf("failed to load Slack signal: %v", err)
+	}
+	if jiraSourceID != "cloud-1:10001" || slackSourceID != "C1:1710000000.000100" {
+		t.Fatalf("unexpected source IDs after migration: jira=%q slack=%q", jiraSourceID, slackSourceID)
+	}
+}
+
+func TestMigrateUpMergesDuplicateWorkspaceSignals(t *testing.T) {
+	openTestDB(t)
+
+	originalMigrations := migrations
+	migrations = originalMigrations[:10]
+	if _, err := MigrateUp(); err != nil {
+		migrations = originalMigrations
+		t.Fatalf("MigrateUp to version 10 returned error: %v", err)
+	}
+	migrations = originalMigrations
+
+	statements := []string{
+		`INSERT INTO users (id, email, password) VALUES (1, 'first@example.com', 'hash'), (2, 'second@example.com', 'hash')`,
+		`INSERT INTO workspaces (id, name, owner_id) VALUES (5, 'Ops', 1)`,
+		`INSERT INTO signals (id, user_id, workspace_id, source_type, source_id, title) VALUES
+		 (1, 1, 5, 'slack', 'C1:1710000000.000100', 'Deploy tonight?'),
+		 (2, 2, 5, 'slack', 'C1:1710000000.000100', 'Deploy tonight?'),
+		 (3, 2, NULL, 'gmail', 'msg-1', 'Personal mail')`,
+		`INSERT INTO decisions (id, workspace_id, user_id, title, status) VALUES (1, 5, 2, 'Ship it', 'OPEN')`,
+		`INSERT INTO decision_signals (decision_id, signal_id, linked_by) VALUES (1, 2, 2)`,
+		`INSERT INTO signal_status (signal_id, user_id, status) VALUES (1, 1, 'read'), (2, 2, 'archived'), (2, 1, 'unread')`,
+	}
+	for _, statement := range statements {
+		if _, err := DB.Exec(statement); err != nil {
+			t.Fatalf("failed to seed %q: %v", statement, err)
+		}
+	}
+
+	if _, err := MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp returned error: %v", err)
+	}
+
+	var signalCount int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM signals").Scan(&signalCount); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if signalCount != 2 {
+		t.Fatalf("expected the duplicate Slack signal to be merged, got %d signals", signalCount)
+	}
+
+	var linkedSignalID int
+	if err := DB.QueryRow("SELECT signal_id FROM decision_signals WHERE decision_id = 1").Scan(&linkedSignalID); err != nil {
+		t.Fatalf("failed to load decision link: %v", err)
+	}
+	if linkedSignalID != 1 {
+		t.Fatalf("expected decision link to move to the kept signal, got %d", linkedSignalID)
+	}
+
+	statuses := map[int]string{}
+	rows, err := DB.Query("SELECT user_id, status FROM signal_status WHERE signal_id = 1")
+	if err != nil {
+		t.Fatalf("failed to load signal statuses: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int
+		var status string
+		if err := rows.Scan(&userID, &status); err != nil {
+			t.Fatalf("failed to scan signal status: %v", err)
+		}
+		statuses[userID] = status
+	}
+	if len(statuses) != 2 || statuses[1] != "read" || statuses[2] != "archived" {
+		t.Fatalf("expected each member to keep their own status, got %+v", statuses)
+	}
+
+	if _, err := DB.Exec(
+		`INSERT INTO signals (user_id, workspace_id, source_type, source_id, title) VALUES (2, 5, 'slack', 'C1:1710000000.000100', 'Again')`,
+	); err == nil {
+		t.Fatal("expected a second workspace signal for the same source item to be rejected")
+	}
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// searchIndexes describes the FTS5 tables behind workspace search. Each one is
+// an external-content index over its base table, kept in sync by triggers so
+// that every write path, including ON CONFLICT upserts, updates it.
+var searchIndexes = []struct {
+	table   string
+	columns []string
+}{
+	{table: "signals", columns: []string{"title", "content", "body", "author"}},
+	{table: "decisions", columns: []string{"title", "description"}},
+}
+
+type schemaExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// EnsureSearchIndex creates the full-text indexes when SQLite was built with
+// FTS5 (the sqlite_fts5 build tag) and they are missing, and backfills them.
+// Without FTS5 it drops the sync triggers instead, since they would make every
+// write fail with "no such module"; search then falls back to substring
+// matching until a build with FTS5 recreates them.
+func EnsureSearchIndex() error {
+	if currentDialect != DialectSQLite {
+		return nil
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	available, err := ensureSearchIndex(tx)
+	if err != nil {
+		return err
+	}
+	if !available {
+		log.Printf("SQLite was built without FTS5; workspace search uses substring matching")
+	}
+	return tx.Commit()
+}
+
+// ensureSearchIndex reports whether FTS5 is available.
+func ensureSearchIndex(conn schemaExecer) (bool, error) {
+	if currentDialect != DialectSQLite {
+		return false, nil
+	}
+
+	available, err := fts5Available(conn)
+	if err != nil {
+		return false, err
+	}
+
+	for _, index := range searchIndexes {
+		if !available {
+			if err := dropSearchTriggers(conn, index.table); err != nil {
+				return false, err
+			}
+			continue
+		}
+
+		var triggers int
+		if err := conn.QueryRow(
+			"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?",
+			index.table+"_fts_insert",
+		).Scan(&triggers); err != nil {
+			return false, fmt.Errorf("inspect search index for %s: %w", index.table, err)
+		}
+		if triggers > 0 {
+			continue
+		}
+
+		if err := createSearchIndex(conn, index.table, index.columns); err != nil {
+			return false, fmt.Errorf("create search index for %s: %w", index.table, err)
+		}
+	}
+	return available, nil
+}
+
+func createSearchIndex(conn schemaExecer, table string, columns []string) error {
+	ftsTable := table + "_fts"
+	columnList := joinColumns(columns, "")
+	newValues := joinColumns(columns, "new.")
+	oldValues := joinColumns(columns, "old.")
+
+	statements := []string{
+		fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(%s, content='%s', content_rowid='id')`,
+			ftsTable, columnList, table),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_fts_insert AFTER INSERT ON %[1]s BEGIN
+			INSERT INTO %[2]s(rowid, %[3]s) VALUES (new.id, %[4]s);
+		END`, table, ftsTable, columnList, newValues),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_fts_delete AFTER DELETE ON %[1]s BEGIN
+			INSERT INTO %[2]s(%[2]s, rowid, %[3]s) VALUES ('delete', old.id, %[4]s);
+		END`, table, ftsTable, columnList, oldValues),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_fts_update AFTER UPDATE OF %[3]s ON %[1]s BEGIN
+			INSERT INTO %[2]s(%[2]s, rowid, %[3]s) VALUES ('delete', old.id, %[4]s);
+			INSERT INTO %[2]s(rowid, %[3]s) VALUES (new.id, %[5]s);
+		END`, table, ftsTable, columnList, oldValues, newValues),
+		// Rows written while the triggers were missing are picked up here.
+		fmt.Sprintf(`INSERT INTO %[1]s(%[1]s) VALUES ('rebuild')`, ftsTable),
+	}
+	for _, statement := range statements {
+		if _, err := conn.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropSearchTriggers(conn schemaExecer, table string) error {
+	for _, suffix := range []string{"_fts_insert", "_fts_delete", "_fts_update"} {
+		if _, err := conn.Exec("DROP TRIGGER IF EXISTS " + table + suffix); err != nil {
+			return fmt.Errorf("drop search trigger %s%s: %w", table, suffix, err)
+		}
+	}
+	return nil
+}
+
+func fts5Available(conn schemaExecer) (bool, error) {
+	var enabled bool
+	if err := conn.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return false, fmt.Errorf("check FTS5 support: %w", err)
+	}
+	return enabled, nil
+}
+
+func joinColumns(columns []string, prefix string) string {
+	prefixed := make([]string, len(columns))
+	for i, column := range columns {
+		prefixed[i] = prefix + column
+	}
+	return strings.Join(prefixed, ", ")
+}
@@ -10,4 +10,6 @@ require (
 
 require golang.org/x/oauth2 v0.36.0
 
-require github.com/joho/godotenv v1.5.1 // indirect
+require github.com/joho/godotenv v1.5.1
+
+require github.com/lib/pq v1.10.9
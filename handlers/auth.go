@@ -3,22 +3,20 @@ package handlers
 import (
 	"crypto/rand"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"sentinent-backend/database"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"sentinent-backend/services"
 	"sentinent-backend/utils"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -79,18 +77,7 @@ func Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = database.DB.Exec(
-		`INSERT INTO users (email, password, full_name, job_title, organization, timezone, bio, role_label)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		user.Email,
-		string(hashedPassword),
-		user.FullName,
-		user.JobTitle,
-		user.Organization,
-		user.Timezone,
-		user.Bio,
-		user.RoleLabel,
-	)
+	_, err = repository.Default().Users.Create(user, string(hashedPassword))
 	if err != nil {
 		http.Error(w, "Email already exists", http.StatusConflict)
 		return
@@ -118,9 +105,8 @@ func Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var storedUser models.User
-	err = database.DB.QueryRow("SELECT id, email, password FROM users WHERE email = ?", creds.Email).Scan(&storedUser.ID, &storedUser.Email, &storedUser.Password)
-	if err == sql.ErrNoRows {
+	storedUser, err := repository.Default().Users.GetCredentials(creds.Email)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	} else if err != nil {
@@ -134,39 +120,22 @@ func Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &models.Claims{
-		UserID: storedUser.ID,
-		Email:  creds.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	if len(utils.JwtKey) == 0 {
-		http.Error(w, "Server configuration error", http.StatusInternalServerError)
-		return
-	}
-	tokenString, err := token.SignedString(utils.JwtKey)
+	mfa, err := repository.Default().MFA.Get(storedUser.ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if mfa.Enabled() {
+		// No challenge is issued while wrong codes have the user locked out.
+		if now := time.Now(); mfa.Locked(now) {
+			writeMFALocked(w, *mfa.LockedUntil, now)
+			return
+		}
+		writeMFAChallenge(w, storedUser.ID, creds.Email, mfa.Logins)
+		return
+	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    tokenString,
-		Expires:  expirationTime,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   isProductionEnv(),
-	})
-
-	// Also return JSON for non-browser clients
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+	startSession(w, r, storedUser.ID, creds.Email)
 }
 
 func Logout(w http.ResponseWriter, r *http.Request) {
@@ -175,16 +144,11 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   -1,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   isProductionEnv(),
-	})
+	if err := revokeRequestSession(r); err != nil {
+		log.Printf("logout: failed to revoke session: %v", err)
+	}
 
+	clearSessionCookies(w)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -195,7 +159,7 @@ func ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	emailDeliveryConfigured := services.PasswordResetEmailDeliveryConfigured()
-	if isProductionEnv() && !emailDeliveryConfigured {
+	if utils.IsProductionEnv() && !emailDeliveryConfigured {
 		http.Error(w, "Failed to process reset request", http.StatusInternalServerError)
 		return
 	}
@@ -212,9 +176,8 @@ func ForgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var userID int
-	err := database.DB.QueryRow("SELECT id FROM users WHERE email = ?", req.Email).Scan(&userID)
-	if err == sql.ErrNoRows {
+	userID, err := repository.Default().Users.IDByEmail(req.Email)
+	if err == repository.ErrNotFound {
 		writeForgotPasswordResponse(w, "")
 		return
 	}
@@ -232,31 +195,7 @@ func ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	expiresAt := time.Now().Add(passwordResetTTL)
 	tokenHash := hashPasswordResetToken(resetToken)
 
-	tx, err := database.DB.Begin()
-	if err != nil {
-		http.Error(w, "Failed to process reset request", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec(
-		"DELETE FROM password_reset_tokens WHERE user_id = ? OR expires_at <= ? OR used_at IS NOT NULL",
-		userID, time.Now(),
-	); err != nil {
-		http.Error(w, "Failed to process reset request", http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := tx.Exec(
-		`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
-		 VALUES (?, ?, ?)`,
-		userID, tokenHash, expiresAt,
-	); err != nil {
-		http.Error(w, "Failed to process reset request", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
+	if err := repository.Default().PasswordResets.Replace(userID, tokenHash, expiresAt, time.Now()); err != nil {
 		http.Error(w, "Failed to process reset request", http.StatusInternalServerError)
 		return
 	}
@@ -269,7 +208,7 @@ func ForgotPassword(w http.ResponseWriter, r *http.Request) {
 
 	if err := sendPasswordResetEmailFunc(req.Email, resetURL); err != nil {
 		log.Printf("failed to send password reset email for %s: %v", req.Email, err)
-		if _, cleanupErr := database.DB.Exec("DELETE FROM password_reset_tokens WHERE token_hash = ?", tokenHash); cleanupErr != nil {
+		if cleanupErr := repository.Default().PasswordResets.Delete(tokenHash); cleanupErr != nil {
 			log.Printf("failed to clean up password reset token after email delivery error: %v", cleanupErr)
 		}
 		http.Error(w, "Failed to process reset request", http.StatusInternalServerError)
@@ -328,27 +267,8 @@ func ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := database.DB.Begin()
-	if err != nil {
-		http.Error(w, "Failed to update password", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	if _, err := tx.Exec("UPDATE users SET password = ? WHERE id = ?", string(hashedPassword), record.UserID); err != nil {
-		http.Error(w, "Failed to update password", http.StatusInternalServerError)
-		return
-	}
-
-	if _, err := tx.Exec(
-		"UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
-		time.Now(), record.UserID,
-	); err != nil {
-		http.Error(w, "Failed to update password", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
+	// A password reset ends every existing login.
+	if err := repository.Default().PasswordResets.Redeem(record.UserID, string(hashedPassword), time.Now()); err != nil {
 		http.Error(w, "Failed to update password", http.StatusInternalServerError)
 		return
 	}
@@ -374,22 +294,8 @@ func getProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
-	err := database.DB.QueryRow(
-		`SELECT id, email, full_name, job_title, organization, timezone, bio, role_label
-		 FROM users WHERE id = ?`,
-		userID,
-	).Scan(
-		&user.ID,
-		&user.Email,
-		&user.FullName,
-		&user.JobTitle,
-		&user.Organization,
-		&user.Timezone,
-		&user.Bio,
-		&user.RoleLabel,
-	)
-	if err == sql.ErrNoRows {
+	user, err := repository.Default().Users.GetProfile(userID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
@@ -427,18 +333,14 @@ func updateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := database.DB.Exec(
-		`UPDATE users
-		 SET full_name = ?, job_title = ?, organization = ?, timezone = ?, bio = ?, role_label = ?
-		 WHERE id = ?`,
-		req.FullName,
-		req.JobTitle,
-		req.Organization,
-		req.Timezone,
-		req.Bio,
-		req.RoleLabel,
-		userID,
-	)
+	err := repository.Default().Users.UpdateProfile(userID, models.User{
+		FullName:     req.FullName,
+		JobTitle:     req.JobTitle,
+		Organization: req.Organization,
+		Timezone:     req.Timezone,
+		Bio:          req.Bio,
+		RoleLabel:    req.RoleLabel,
+	})
 	if err != nil {
 		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
 		return
@@ -447,50 +349,26 @@ func updateProfile(w http.ResponseWriter, r *http.Request) {
 	getProfile(w, r)
 }
 
-func isProductionEnv() bool {
-	switch strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV"))) {
-	case "production", "prod":
-		return true
-	default:
-		return false
-	}
-}
-
-type passwordResetRecord struct {
-	ID     int
-	UserID int
-	Email  string
-}
-
-func lookupPasswordResetRecord(token string) (*passwordResetRecord, int, error) {
+func lookupPasswordResetRecord(token string) (*models.PasswordReset, int, error) {
 	if token == "" {
 		return nil, http.StatusBadRequest, fmt.Errorf("Invalid reset token")
 	}
 
-	var record passwordResetRecord
-	var expiresAt time.Time
-	var usedAt sql.NullTime
-	err := database.DB.QueryRow(
-		`SELECT prt.id, prt.user_id, u.email, prt.expires_at, prt.used_at
-		 FROM password_reset_tokens prt
-		 JOIN users u ON u.id = prt.user_id
-		 WHERE prt.token_hash = ?`,
-		hashPasswordResetToken(token),
-	).Scan(&record.ID, &record.UserID, &record.Email, &expiresAt, &usedAt)
-	if err == sql.ErrNoRows {
+	record, err := repository.Default().PasswordResets.GetByTokenHash(hashPasswordResetToken(token))
+	if err == repository.ErrNotFound {
 		return nil, http.StatusNotFound, fmt.Errorf("Invalid reset token")
 	}
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to validate reset token")
 	}
-	if usedAt.Valid {
+	if record.UsedAt != nil {
 		return nil, http.StatusGone, fmt.Errorf("Reset token has already been used")
 	}
-	if time.Now().After(expiresAt) {
+	if time.Now().After(record.ExpiresAt) {
 		return nil, http.StatusGone, fmt.Errorf("Reset token has expired")
 	}
 
-	return &record, http.StatusOK, nil
+	return record, http.StatusOK, nil
 }
 
 func writeForgotPasswordResponse(w http.ResponseWriter, resetURL string) {
@@ -2,95 +2,30 @@ package handlers
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/middleware"
 	"sentinent-backend/models"
 	"sentinent-backend/utils"
 	"strings"
 	"testing"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-func setupTestDB() {
-	var err error
-	database.DB, err = sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		panic(err)
-	}
-
-	createTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		email TEXT NOT NULL UNIQUE,
-		password TEXT NOT NULL,
-		full_name TEXT DEFAULT '',
-		job_title TEXT DEFAULT '',
-		organization TEXT DEFAULT '',
-		timezone TEXT DEFAULT '',
-		bio TEXT DEFAULT '',
-		role_label TEXT DEFAULT ''
-	);`
-
-	_, err = database.DB.Exec(createTable)
-	if err != nil {
-		panic(err)
-	}
-
-	resetTable := `
-	CREATE TABLE IF NOT EXISTS password_reset_tokens (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		token_hash TEXT NOT NULL UNIQUE,
-		expires_at DATETIME NOT NULL,
-		used_at DATETIME,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-	_, err = database.DB.Exec(resetTable)
-	if err != nil {
-		panic(err)
-	}
-
-	workspaceTable := `
-	CREATE TABLE IF NOT EXISTS workspaces (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		description TEXT DEFAULT '',
-		owner_id INTEGER NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-	_, err = database.DB.Exec(workspaceTable)
-	if err != nil {
-		panic(err)
-	}
-
-	workspaceMembersTable := `
-	CREATE TABLE IF NOT EXISTS workspace_members (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		workspace_id INTEGER NOT NULL,
-		user_id INTEGER NOT NULL,
-		role TEXT NOT NULL,
-		joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(workspace_id, user_id)
-	);`
-	_, err = database.DB.Exec(workspaceMembersTable)
-	if err != nil {
-		panic(err)
-	}
+func setupTestDB(t *testing.T) {
+	t.Helper()
 
+	dbtest.Open(t)
 	utils.JwtKey = []byte("test-jwt-secret")
 }
 
 func TestSignup(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 
 	user := models.User{
 		Email:    "test@example.com",
@@ -120,8 +55,7 @@ func TestSignup(t *testing.T) {
 }
 
 func TestSignin(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 	t.Setenv("APP_ENV", "development")
 
 	// Create user first
@@ -166,8 +100,7 @@ func TestSignin(t *testing.T) {
 }
 
 func TestSigninCookieSecureInProduction(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 	t.Setenv("APP_ENV", "production")
 
 	Signup(httptest.NewRecorder(), httptest.NewRequest("POST", "/signup", bytes.NewBuffer([]byte(`{"email":"test@example.com","password":"password123"}`))))
@@ -197,8 +130,7 @@ func TestSigninCookieSecureInProduction(t *testing.T) {
 }
 
 func TestSignupInvalidEmail(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 
 	user := models.User{
 		Email:    "invalid-email",
@@ -229,8 +161,7 @@ func TestSignupRejectsUnsupportedMethod(t *testing.T) {
 }
 
 func TestSignupRejectsShortPassword(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 
 	req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBufferString(`{"email":"test@example.com","password":"short"}`))
 	rr := httptest.NewRecorder()
@@ -243,8 +174,7 @@ func TestSignupRejectsShortPassword(t *testing.T) {
 }
 
 func TestSignupTrimsEmailBeforeValidation(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 
 	req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBufferString(`{"email":" test@example.com ","password":"password123"}`))
 	rr := httptest.NewRecorder()
@@ -265,8 +195,7 @@ func TestSignupTrimsEmailBeforeValidation(t *testing.T) {
 }
 
 func TestSigninInvalidEmail(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 
 	user := models.User{
 		Email:    "invalid-email",
@@ -297,8 +226,7 @@ func TestSigninRejectsUnsupportedMethod(t *testing.T) {
 }
 
 func TestSigninTrimsEmailBeforeLookup(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 	t.Setenv("APP_ENV", "development")
 
 	Signup(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBufferString(`{"email":"test@example.com","password":"password123"}`)))
@@ -314,8 +242,7 @@ func TestSigninTrimsEmailBeforeLookup(t *testing.T) {
 }
 
 func TestForgotPasswordGeneratesResetTokenForExistingUser(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 	t.Setenv("FRONTEND_BASE_URL", "http://localhost:4200")
 
 	Signup(httptest.NewRecorder(), httptest.NewRequest("POST", "/signup", bytes.NewBuffer([]byte(`{"email":"test@example.com","password":"password123"}`))))
@@ -347,8 +274,7 @@ func TestForgotPasswordGeneratesResetTokenForExistingUser(t *testing.T) {
 }
 
 func TestForgotPasswordSendsResetEmailWhenMailerConfigured(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 	t.Setenv("FRONTEND_BASE_URL", "https://app.example.com")
 	t.Setenv("SMTP_HOST", "smtp.example.com")
 	t.Setenv("SMTP_PORT", "587")
@@ -404,8 +330,7 @@ func TestForgotPasswordSendsResetEmailWhenMailerConfigured(t *testing.T) {
 }
 
 func TestForgotPasswordDoesNotRevealMissingEmail(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 
 	req, _ := http.NewRequest("POST", "/forgot-password", bytes.NewBuffer([]byte(`{"email":"missing@example.com"}`)))
 	rr := httptest.NewRecorder()
@@ -426,8 +351,7 @@ func TestForgotPasswordDoesNotRevealMissingEmail(t *testing.T) {
 }
 
 func TestForgotPasswordFailsInProductionWithoutMailer(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 	t.Setenv("APP_ENV", "production")
 
 	Signup(httptest.NewRecorder(), httptest.NewRequest("POST", "/signup", bytes.NewBuffer([]byte(`{"email":"test@example.com","password":"password123"}`))))
@@ -451,8 +375,7 @@ func TestForgotPasswordFailsInProductionWithoutMailer(t *testing.T) {
 }
 
 func TestForgotPasswordDeletesTokenWhenEmailDeliveryFails(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 	t.Setenv("SMTP_HOST", "smtp.example.com")
 	t.Setenv("SMTP_PORT", "587")
 	t.Setenv("SMTP_USERNAME", "mailer")
@@ -489,8 +412,7 @@ func TestForgotPasswordDeletesTokenWhenEmailDeliveryFails(t *testing.T) {
 }
 
 func TestValidateAndResetPassword(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	setupTestDB(t)
 
 	Signup(httptest.NewRecorder(), httptest.NewRequest("POST", "/signup", bytes.NewBuffer([]byte(`{"email":"test@example.com","password":"password123"}`))))
 
@@ -539,3 +461,129 @@ func TestValidateAndResetPassword(t *testing.T) {
 		t.Fatalf("expected used token to be rejected, got %d", validateAgainRR.Code)
 	}
 }
+
+func signinForTest(t *testing.T) (accessToken, refreshToken string) {
+	t.Helper()
+
+	Signup(httptest.NewRecorder(), httptest.NewRequest("POST", "/signup", bytes.NewBuffer([]byte(`{"email":"test@example.com","password":"password123"}`))))
+
+	rr := httptest.NewRecorder()
+	Signin(rr, httptest.NewRequest("POST", "/signin", bytes.NewBuffer([]byte(`{"email":"test@example.com","password":"password123"}`))))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected signin status 200, got %d", rr.Code)
+	}
+
+	var response map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode signin response: %v", err)
+	}
+	if response["token"] == "" || response["refresh_token"] == "" {
+		t.Fatalf("expected access and refresh tokens, got %v", response)
+	}
+	return response["token"], response["refresh_token"]
+}
+
+func refreshForTest(refreshToken string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/token/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: refreshTokenCookieName, Value: refreshToken})
+	rr := httptest.NewRecorder()
+	RefreshToken(rr, req)
+	return rr
+}
+
+func protectedStatusForTest(accessToken string) int {
+	req := httptest.NewRequest(http.MethodGet, "/api/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rr := httptest.NewRecorder()
+	middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestRefreshTokenRotatesAndRevokesOnReuse(t *testing.T) {
+	setupTestDB(t)
+
+	accessToken, refreshToken := signinForTest(t)
+	if status := protectedStatusForTest(accessToken); status != http.StatusOK {
+		t.Fatalf("expected fresh access token to be accepted, got %d", status)
+	}
+
+	rr := refreshForTest(refreshToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected refresh status 200, got %d", rr.Code)
+	}
+	var refreshed map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&refreshed); err != nil {
+		t.Fatalf("failed to decode refresh response: %v", err)
+	}
+	if refreshed["refresh_token"] == "" || refreshed["refresh_token"] == refreshToken {
+		t.Fatalf("expected a rotated refresh token, got %q", refreshed["refresh_token"])
+	}
+
+	// Replaying the rotated token ends the session, including the new token.
+	if rr := refreshForTest(refreshToken); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected reused refresh token to be rejected, got %d", rr.Code)
+	}
+	if rr := refreshForTest(refreshed["refresh_token"]); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected session to be revoked after reuse, got %d", rr.Code)
+	}
+	if status := protectedStatusForTest(refreshed["token"]); status != http.StatusUnauthorized {
+		t.Fatalf("expected access token of revoked session to be rejected, got %d", status)
+	}
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	setupTestDB(t)
+
+	firstAccessToken, _ := signinForTest(t)
+	secondAccessToken, secondRefreshToken := signinForTest(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/logout/all", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+	LogoutAll(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected logout-all status 204, got %d", rr.Code)
+	}
+
+	for _, accessToken := range []string{firstAccessToken, secondAccessToken} {
+		if status := protectedStatusForTest(accessToken); status != http.StatusUnauthorized {
+			t.Fatalf("expected revoked access token to be rejected, got %d", status)
+		}
+	}
+	if rr := refreshForTest(secondRefreshToken); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked refresh token to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestResetPasswordRejectsOlderAccessTokens(t *testing.T) {
+	setupTestDB(t)
+
+	accessToken, refreshToken := signinForTest(t)
+
+	resetToken, err := generatePasswordResetToken()
+	if err != nil {
+		t.Fatalf("failed to create reset token: %v", err)
+	}
+	if _, err := database.DB.Exec(
+		`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		 VALUES (1, ?, ?)`,
+		hashPasswordResetToken(resetToken), time.Now().Add(time.Hour),
+	); err != nil {
+		t.Fatalf("failed to seed reset token: %v", err)
+	}
+
+	resetRR := httptest.NewRecorder()
+	ResetPassword(resetRR, httptest.NewRequest("POST", "/api/reset-password/"+resetToken, bytes.NewBuffer([]byte(`{"password":"newsecret123"}`))))
+	if resetRR.Code != http.StatusNoContent {
+		t.Fatalf("expected reset status 204, got %d", resetRR.Code)
+	}
+
+	if status := protectedStatusForTest(accessToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected access token issued before the reset to be rejected, got %d", status)
+	}
+	if rr := refreshForTest(refreshToken); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected refresh token issued before the reset to be rejected, got %d", rr.Code)
+	}
+}
@@ -3,19 +3,15 @@ package handlers
 import (
 	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
-	"sentinent-backend/utils"
+	"sentinent-backend/services"
 	"testing"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func TestWorkspaceReadUpdateAndMemberRoutes(t *testing.T) {
@@ -106,7 +102,6 @@ func TestWorkspaceReadUpdateAndMemberRoutes(t *testing.T) {
 
 func TestSignalLifecycleHandlers(t *testing.T) {
 	setupSignalsTestDB(t)
-	defer database.DB.Close()
 
 	getReq := signalRequestWithUser(http.MethodGet, "/api/signals/1")
 	getRR := httptest.NewRecorder()
@@ -152,44 +147,81 @@ func TestSignalLifecycleHandlers(t *testing.T) {
 	}
 }
 
-func TestIntegrationMetadataRoutesAndDisconnectHandlers(t *testing.T) {
+func TestSlackChannelSelectionStoresBackfillAndForgetsDeselectedChannels(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
-	originalSlackClientID := slackClientID
-	originalSlackClientSecret := slackClientSecret
-	originalTokenEncryptor := tokenEncryptor
 	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	t.Setenv("SLACK_CLIENT_ID", "slack-client")
+	t.Setenv("SLACK_CLIENT_SECRET", "slack-secret")
+	slack, _ := services.LookupProvider("slack")
+	if err := slack.Init(); err != nil {
+		t.Fatalf("failed to initialize Slack provider: %v", err)
+	}
 
-	encryptor, err := utils.NewTokenEncryptor()
-	if err != nil {
-		t.Fatalf("failed to create encryptor: %v", err)
+	dbtest.Seed(t,
+		`INSERT INTO external_integrations (id, user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (11, 1, 9, 'slack', 'slack-token', '{"selected_channels":["C1","C2"],"channel_checkpoints":{"C1":"1710000000.000100","C2":"1710000000.000200"}}')`,
+	)
+
+	negativeReq := integrationRequestWithBody(http.MethodPatch, "/api/integrations/slack/channels?workspace_id=9", "reader@example.com",
+		[]byte(`{"channel_ids":["C1"],"backfill_days":-1}`))
+	negativeRR := httptest.NewRecorder()
+	IntegrationsRouter(negativeRR, negativeReq)
+	if negativeRR.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative backfill_days, got %d", negativeRR.Code)
 	}
 
-	slackClientID = "slack-client"
-	slackClientSecret = "slack-secret"
-	tokenEncryptor = encryptor
-	t.Cleanup(func() {
-		slackClientID = originalSlackClientID
-		slackClientSecret = originalSlackClientSecret
-		tokenEncryptor = originalTokenEncryptor
-	})
+	req := integrationRequestWithBody(http.MethodPatch, "/api/integrations/slack/channels?workspace_id=9", "reader@example.com",
+		[]byte(`{"channel_ids":["C1","C3"],"backfill_days":7}`))
+	rr := httptest.NewRecorder()
+	IntegrationsRouter(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var metadataJSON string
+	if err := database.DB.QueryRow("SELECT metadata FROM external_integrations WHERE id = 11").Scan(&metadataJSON); err != nil {
+		t.Fatalf("failed to fetch metadata: %v", err)
+	}
+	var metadata struct {
+		BackfillDays int               `json:"backfill_days"`
+		Checkpoints  map[string]string `json:"channel_checkpoints"`
+	}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata: %v", err)
+	}
+	if metadata.BackfillDays != 7 {
+		t.Fatalf("expected backfill_days 7, got %s", metadataJSON)
+	}
+	if len(metadata.Checkpoints) != 1 || metadata.Checkpoints["C1"] != "1710000000.000100" {
+		t.Fatalf("expected only the still-selected channel's checkpoint, got %s", metadataJSON)
+	}
+}
+
+func TestIntegrationMetadataRoutesAndDisconnectHandlers(t *testing.T) {
+	setupIntegrationsTestDB(t)
+
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	t.Setenv("SLACK_CLIENT_ID", "slack-client")
+	t.Setenv("SLACK_CLIENT_SECRET", "slack-secret")
+	slack, _ := services.LookupProvider("slack")
+	if err := slack.Init(); err != nil {
+		t.Fatalf("failed to initialize Slack provider: %v", err)
+	}
 
-	if _, err := database.DB.Exec(
+	dbtest.Seed(t,
 		`INSERT INTO external_integrations
 			(id, user_id, workspace_id, provider, access_token, metadata)
 		 VALUES
 			(11, 1, 9, 'slack', 'slack-token', '{"existing":true}'),
 			(12, 1, 9, 'github', 'github-token', '{"existing":true}'),
 			(13, 1, NULL, 'gmail', 'gmail-token', '{"existing":true}')`,
-	); err != nil {
-		t.Fatalf("failed to seed integrations: %v", err)
-	}
+	)
 
 	slackUpdateBody := []byte(`{"channel_ids":["C1","C2"]}`)
 	slackUpdateReq := integrationRequestWithBody(http.MethodPatch, "/api/integrations/slack/channels?workspace_id=9", "reader@example.com", slackUpdateBody)
 	slackUpdateRR := httptest.NewRecorder()
-	GetSlackChannels(slackUpdateRR, slackUpdateReq)
+	IntegrationsRouter(slackUpdateRR, slackUpdateReq)
 
 	if slackUpdateRR.Code != http.StatusNoContent {
 		t.Fatalf("expected 204 from Slack metadata update, got %d: %s", slackUpdateRR.Code, slackUpdateRR.Body.String())
@@ -198,7 +230,7 @@ func TestIntegrationMetadataRoutesAndDisconnectHandlers(t *testing.T) {
 	githubUpdateBody := []byte(`{"repo_ids":[101,202]}`)
 	githubUpdateReq := integrationRequestWithBody(http.MethodPatch, "/api/integrations/github/repos?workspace_id=9", "reader@example.com", githubUpdateBody)
 	githubUpdateRR := httptest.NewRecorder()
-	GitHubReposHandler(githubUpdateRR, githubUpdateReq)
+	IntegrationsRouter(githubUpdateRR, githubUpdateReq)
 
 	if githubUpdateRR.Code != http.StatusNoContent {
 		t.Fatalf("expected 204 from GitHub metadata update, got %d: %s", githubUpdateRR.Code, githubUpdateRR.Body.String())
@@ -233,26 +265,26 @@ func TestIntegrationMetadataRoutesAndDisconnectHandlers(t *testing.T) {
 
 	syncReq := integrationRequestWithUser(http.MethodPost, "/api/integrations/github/sync?workspace_id=bad", "reader@example.com")
 	syncRR := httptest.NewRecorder()
-	GitHubSyncHandler(syncRR, syncReq)
+	IntegrationsRouter(syncRR, syncReq)
 
 	if syncRR.Code != http.StatusBadRequest {
-		t.Fatalf("expected 400 from GitHubSyncHandler validation path, got %d: %s", syncRR.Code, syncRR.Body.String())
+		t.Fatalf("expected 400 from GitHub sync validation path, got %d: %s", syncRR.Code, syncRR.Body.String())
 	}
 
 	githubDisconnectReq := integrationRequestWithUser(http.MethodDelete, "/api/integrations/github?workspace_id=9", "reader@example.com")
 	githubDisconnectRR := httptest.NewRecorder()
-	GitHubDisconnectHandler(githubDisconnectRR, githubDisconnectReq)
+	IntegrationsRouter(githubDisconnectRR, githubDisconnectReq)
 
 	if githubDisconnectRR.Code != http.StatusOK {
-		t.Fatalf("expected 200 from GitHubDisconnectHandler, got %d: %s", githubDisconnectRR.Code, githubDisconnectRR.Body.String())
+		t.Fatalf("expected 200 from GitHub disconnect, got %d: %s", githubDisconnectRR.Code, githubDisconnectRR.Body.String())
 	}
 
 	gmailDisconnectReq := integrationRequestWithUser(http.MethodDelete, "/api/integrations/gmail", "reader@example.com")
 	gmailDisconnectRR := httptest.NewRecorder()
-	GmailDisconnectHandler(gmailDisconnectRR, gmailDisconnectReq)
+	IntegrationsRouter(gmailDisconnectRR, gmailDisconnectReq)
 
 	if gmailDisconnectRR.Code != http.StatusOK {
-		t.Fatalf("expected 200 from GmailDisconnectHandler, got %d: %s", gmailDisconnectRR.Code, gmailDisconnectRR.Body.String())
+		t.Fatalf("expected 200 from Gmail disconnect, got %d: %s", gmailDisconnectRR.Code, gmailDisconnectRR.Body.String())
 	}
 
 	var remaining int
@@ -274,71 +306,6 @@ func TestIntegrationMetadataRoutesAndDisconnectHandlers(t *testing.T) {
 	}
 }
 
-func TestGitHubWebhookHandlerHandlesIssueAndPullRequestEvents(t *testing.T) {
-	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
-
-	issueReq := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewBufferString(`{"action":"opened","issue":{"id":1,"title":"Issue"}}`))
-	issueReq.Header.Set("X-GitHub-Event", "issues")
-	issueRR := httptest.NewRecorder()
-	GitHubWebhookHandler(issueRR, issueReq)
-
-	if issueRR.Code != http.StatusOK {
-		t.Fatalf("expected 200 from issues webhook, got %d: %s", issueRR.Code, issueRR.Body.String())
-	}
-
-	prReq := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewBufferString(`{"action":"opened","pull_request":{"id":2,"title":"PR"}}`))
-	prReq.Header.Set("X-GitHub-Event", "pull_request")
-	prRR := httptest.NewRecorder()
-	GitHubWebhookHandler(prRR, prReq)
-
-	if prRR.Code != http.StatusOK {
-		t.Fatalf("expected 200 from pull request webhook, got %d: %s", prRR.Code, prRR.Body.String())
-	}
-}
-
-func TestGitHubWebhookHandlerVerifiesConfiguredSignature(t *testing.T) {
-	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
-	t.Setenv(githubWebhookSecretEnv, "webhook-secret")
-
-	body := []byte(`{"action":"opened","issue":{"id":1,"title":"Issue"}}`)
-	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewReader(body))
-	req.Header.Set("X-GitHub-Event", "issues")
-	req.Header.Set("X-Hub-Signature-256", "sha256="+signGitHubWebhookBody(body, "webhook-secret"))
-
-	rr := httptest.NewRecorder()
-	GitHubWebhookHandler(rr, req)
-
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected 200 from signed webhook, got %d: %s", rr.Code, rr.Body.String())
-	}
-}
-
-func TestGitHubWebhookHandlerRejectsInvalidSignature(t *testing.T) {
-	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
-	t.Setenv(githubWebhookSecretEnv, "webhook-secret")
-
-	body := []byte(`{"action":"opened","issue":{"id":1,"title":"Issue"}}`)
-	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewReader(body))
-	req.Header.Set("X-GitHub-Event", "issues")
-	req.Header.Set("X-Hub-Signature-256", "sha256="+signGitHubWebhookBody(body, "wrong-secret"))
-
-	rr := httptest.NewRecorder()
-	GitHubWebhookHandler(rr, req)
-
-	if rr.Code != http.StatusUnauthorized {
-		t.Fatalf("expected 401 from invalid webhook signature, got %d: %s", rr.Code, rr.Body.String())
-	}
-}
-
-func signGitHubWebhookBody(body []byte, secret string) string {
-	mac := hmac.New(sha256.New, []byte(secret))
-	_, _ = mac.Write(body)
-	return hex.EncodeToString(mac.Sum(nil))
-}
-
 func integrationRequestWithBody(method, target, email string, body []byte) *http.Request {
 	req := httptest.NewRequest(method, target, bytes.NewReader(body))
 	return req.WithContext(context.WithValue(req.Context(), middleware.UserEmailKey, email))
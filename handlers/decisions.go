@@ -1,17 +1,21 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
-	"sentinent-backend/database"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
 	"strconv"
 	"strings"
+	"time"
 )
 
+const maxDecisionOptions = 20
+
 func ListDecisions(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserID(r.Context())
 	if !ok {
@@ -35,28 +39,11 @@ func ListDecisions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(
-		`SELECT id, workspace_id, user_id, title, COALESCE(description, ''), status, due_date, created_at, updated_at
-		 FROM decisions
-		 WHERE workspace_id = ?
-		 ORDER BY updated_at DESC, id DESC`,
-		workspaceID,
-	)
+	decisions, err := repository.Default().Decisions.List(workspaceID)
 	if err != nil {
 		http.Error(w, "Failed to fetch decisions", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	decisions := make([]models.Decision, 0)
-	for rows.Next() {
-		decision, err := scanDecision(rows)
-		if err != nil {
-			http.Error(w, "Failed to scan decision", http.StatusInternalServerError)
-			return
-		}
-		decisions = append(decisions, *decision)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(decisions)
@@ -90,34 +77,41 @@ func CreateDecision(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	result, err := database.DB.Exec(
-		`INSERT INTO decisions (workspace_id, user_id, title, description, status, due_date, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
-		workspaceID, userID, req.Title, req.Description, req.Status, req.DueDate,
-	)
-	if err != nil {
-		http.Error(w, "Failed to create decision", http.StatusInternalServerError)
+	if status, err := checkDecisionChange(nil, req); err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	decisionID64, err := result.LastInsertId()
+	store := repository.Default()
+	decisionID, err := store.Decisions.Create(workspaceID, userID, *req)
 	if err != nil {
 		http.Error(w, "Failed to create decision", http.StatusInternalServerError)
 		return
 	}
 
-	decision, err := getDecisionByID(workspaceID, int(decisionID64))
+	decision, err := store.Decisions.Get(workspaceID, decisionID)
+	if err == nil {
+		err = store.Decisions.LoadVoting(decision, userID)
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
 		return
 	}
+	services.EnqueueWebhookEvent(workspaceID, models.WebhookEventDecisionCreated, webhookDecision(decision))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(decision)
 }
 
+// webhookDecision is the decision as webhooks see it, without the ranking of
+// the member who made the change.
+func webhookDecision(decision *models.Decision) models.Decision {
+	payload := *decision
+	payload.MyRanking = nil
+	return payload
+}
+
 func GetDecision(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserID(r.Context())
 	if !ok {
@@ -141,11 +135,15 @@ func GetDecision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	decision, err := getDecisionByID(workspaceID, decisionID)
-	if err == sql.ErrNoRows {
+	store := repository.Default()
+	decision, err := store.Decisions.Get(workspaceID, decisionID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Decision not found", http.StatusNotFound)
 		return
 	}
+	if err == nil {
+		err = store.Decisions.LoadVoting(decision, userID)
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
 		return
@@ -184,27 +182,66 @@ func UpdateDecision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec(
-		`UPDATE decisions
-		 SET title = ?, description = ?, status = ?, due_date = ?, updated_at = CURRENT_TIMESTAMP
-		 WHERE id = ? AND workspace_id = ?`,
-		req.Title, req.Description, req.Status, req.DueDate, decisionID, workspaceID,
-	)
+	store := repository.Default()
+	current, err := store.Decisions.Get(workspaceID, decisionID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Decision not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = store.Decisions.LoadVoting(current, userID)
+	}
 	if err != nil {
-		http.Error(w, "Failed to update decision", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
+		return
+	}
+	if status, err := checkDecisionChange(current, req); err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+
+	// Resubmitting the same options must not throw away the ballots cast on them.
+	if req.Options != nil && sameDecisionOptions(current.Options, req.Options) {
+		req.Options = nil
+	}
+	// Closing records the outcome in the same transaction as the rest of the
+	// update.
+	closing := req.Status == models.DecisionStatusClosed
+	var outcome *models.DecisionOutcome
+	if closing {
+		outcome = &models.DecisionOutcome{
+			OptionID:  req.OutcomeOptionID,
+			Rationale: req.Rationale,
+			ClosedBy:  userID,
+			ClosedAt:  time.Now().UTC(),
+		}
+	}
+
+	err = store.Decisions.Update(workspaceID, decisionID, *req, outcome)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Decision not found", http.StatusNotFound)
 		return
 	}
+	if err == repository.ErrDecisionClosed {
+		http.Error(w, "Decision is already closed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update decision", http.StatusInternalServerError)
+		return
+	}
 
-	decision, err := getDecisionByID(workspaceID, decisionID)
+	decision, err := store.Decisions.Get(workspaceID, decisionID)
+	if err == nil {
+		err = store.Decisions.LoadVoting(decision, userID)
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
 		return
 	}
+	if closing {
+		services.EnqueueWebhookEvent(workspaceID, models.WebhookEventDecisionClosed, webhookDecision(decision))
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(decision)
@@ -233,19 +270,333 @@ func DeleteDecision(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec(
-		`DELETE FROM decisions WHERE id = ? AND workspace_id = ?`,
-		decisionID, workspaceID,
-	)
+	err = repository.Default().Decisions.Delete(workspaceID, decisionID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Decision not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to delete decision", http.StatusInternalServerError)
 		return
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VoteOnDecision records the caller's ballot on an open decision:
+// PUT /api/workspaces/{id}/decisions/{decisionId}/vote {"option_ids": [3, 1]}
+//
+// Options are ranked most preferred first; a single option is a plain vote.
+// Voting again replaces the earlier ballot. Viewers cannot vote.
+func VoteOnDecision(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, decisionID, err := extractDecisionIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or decision ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+	if role != models.RoleOwner && role != models.RoleMember {
+		http.Error(w, "Forbidden: Viewers cannot vote", http.StatusForbidden)
+		return
+	}
+
+	var req models.DecisionVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	store := repository.Default()
+	decision, err := store.Decisions.Get(workspaceID, decisionID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Decision not found", http.StatusNotFound)
 		return
 	}
+	if err == nil {
+		err = store.Decisions.LoadVoting(decision, userID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
+		return
+	}
+	if decision.Status != models.DecisionStatusOpen {
+		http.Error(w, "Voting is only allowed while the decision is OPEN", http.StatusConflict)
+		return
+	}
+	if err := validateBallot(decision.Options, req.OptionIDs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = store.Decisions.Vote(decisionID, userID, req.OptionIDs)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Voting is only allowed while the decision is OPEN", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to record vote", http.StatusInternalServerError)
+		return
+	}
+
+	decision, err = store.Decisions.Get(workspaceID, decisionID)
+	if err == nil {
+		err = store.Decisions.LoadVoting(decision, userID)
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(decision)
+}
+
+// WithdrawDecisionVote removes the caller's ballot from an open decision:
+// DELETE /api/workspaces/{id}/decisions/{decisionId}/vote
+func WithdrawDecisionVote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, decisionID, err := extractDecisionIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or decision ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+	if role != models.RoleOwner && role != models.RoleMember {
+		http.Error(w, "Forbidden: Viewers cannot vote", http.StatusForbidden)
+		return
+	}
+
+	store := repository.Default()
+	decision, err := store.Decisions.Get(workspaceID, decisionID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Decision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
+		return
+	}
+	if decision.Status != models.DecisionStatusOpen {
+		http.Error(w, "Voting is only allowed while the decision is OPEN", http.StatusConflict)
+		return
+	}
+
+	err = store.Decisions.ClearVote(decisionID, userID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "No vote to withdraw", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to withdraw vote", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDecisionSignals lists the signals linked to a decision as evidence:
+// GET /api/workspaces/{id}/decisions/{decisionId}/signals
+func ListDecisionSignals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, decisionID, err := extractDecisionIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or decision ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+
+	store := repository.Default()
+	if _, err := store.Decisions.Get(workspaceID, decisionID); err == repository.ErrNotFound {
+		http.Error(w, "Decision not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
+		return
+	}
+
+	signals, err := store.Decisions.LinkedSignals(userID, decisionID)
+	if err != nil {
+		http.Error(w, "Failed to fetch linked signals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(signals)
+}
+
+// LinkDecisionSignal attaches one of the caller's signals to a decision:
+// POST /api/workspaces/{id}/decisions/{decisionId}/signals {"signal_id": 1}
+//
+// Only signals the caller can see may be linked, and only if they belong to
+// the decision's workspace or to no workspace at all.
+func LinkDecisionSignal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, decisionID, err := extractDecisionIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or decision ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role != models.RoleOwner && role != models.RoleMember {
+		http.Error(w, "Forbidden: Only members can manage decisions", http.StatusForbidden)
+		return
+	}
+
+	var req models.DecisionSignalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.SignalID <= 0 {
+		http.Error(w, "Signal ID is required", http.StatusBadRequest)
+		return
+	}
+
+	store := repository.Default()
+	if _, err := store.Decisions.Get(workspaceID, decisionID); err == repository.ErrNotFound {
+		http.Error(w, "Decision not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
+		return
+	}
+
+	signal, err := store.Signals.Get(userID, req.SignalID)
+	if err == repository.ErrNotFound || (err == nil && signal.WorkspaceID != 0 && signal.WorkspaceID != workspaceID) {
+		http.Error(w, "Signal not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch signal", http.StatusInternalServerError)
+		return
+	}
+
+	linkedAt := time.Now().UTC()
+	linked, err := store.Decisions.LinkSignal(decisionID, signal.ID, userID, linkedAt)
+	if err != nil {
+		http.Error(w, "Failed to link signal", http.StatusInternalServerError)
+		return
+	}
+	if !linked {
+		http.Error(w, "Signal is already linked to this decision", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(models.LinkedSignal{
+		Signal:   *signal,
+		LinkedBy: userID,
+		LinkedAt: linkedAt,
+	})
+}
+
+// UnlinkDecisionSignal detaches a signal from a decision:
+// DELETE /api/workspaces/{id}/decisions/{decisionId}/signals/{signalId}
+func UnlinkDecisionSignal(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, decisionID, err := extractDecisionIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or decision ID", http.StatusBadRequest)
+		return
+	}
+	parts := splitPath(r.URL.Path)
+	if len(parts) < 7 {
+		http.Error(w, "Invalid signal ID", http.StatusBadRequest)
+		return
+	}
+	signalID, err := strconv.Atoi(parts[6])
+	if err != nil {
+		http.Error(w, "Invalid signal ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role != models.RoleOwner && role != models.RoleMember {
+		http.Error(w, "Forbidden: Only members can manage decisions", http.StatusForbidden)
+		return
+	}
+
+	store := repository.Default()
+	if _, err := store.Decisions.Get(workspaceID, decisionID); err == repository.ErrNotFound {
+		http.Error(w, "Decision not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to fetch decision", http.StatusInternalServerError)
+		return
+	}
+
+	err = store.Decisions.UnlinkSignal(decisionID, signalID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Signal is not linked to this decision", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to unlink signal", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -270,47 +621,110 @@ func decodeDecisionRequest(r *http.Request) (*models.DecisionRequest, error) {
 		return nil, errors.New("Invalid decision status")
 	}
 
+	if len(req.Options) > maxDecisionOptions {
+		return nil, fmt.Errorf("A decision can have at most %d options", maxDecisionOptions)
+	}
+	labels := make(map[string]bool, len(req.Options))
+	for i := range req.Options {
+		req.Options[i].Label = strings.TrimSpace(req.Options[i].Label)
+		req.Options[i].Description = strings.TrimSpace(req.Options[i].Description)
+		if req.Options[i].Label == "" {
+			return nil, errors.New("Option label is required")
+		}
+		key := strings.ToLower(req.Options[i].Label)
+		if labels[key] {
+			return nil, errors.New("Option labels must be unique")
+		}
+		labels[key] = true
+	}
+	req.Rationale = strings.TrimSpace(req.Rationale)
+
 	return &req, nil
 }
 
-func getDecisionByID(workspaceID, decisionID int) (*models.Decision, error) {
-	row := database.DB.QueryRow(
-		`SELECT id, workspace_id, user_id, title, COALESCE(description, ''), status, due_date, created_at, updated_at
-		 FROM decisions
-		 WHERE workspace_id = ? AND id = ?`,
-		workspaceID, decisionID,
-	)
-	return scanDecision(row)
+// checkDecisionChange enforces the decision workflow: DRAFT, then OPEN for
+// voting, then CLOSED for good. Options are fixed once voting opens, and the
+// outcome is recorded only when closing. current is nil for a new decision and
+// must have its voting loaded otherwise. The returned status goes with the error.
+func checkDecisionChange(current *models.Decision, req *models.DecisionRequest) (int, error) {
+	closing := req.Status == models.DecisionStatusClosed
+	if !closing && (req.OutcomeOptionID != nil || req.Rationale != "") {
+		return http.StatusBadRequest, errors.New("Outcome can only be recorded when closing a decision")
+	}
+
+	if current == nil {
+		if closing {
+			return http.StatusBadRequest, errors.New("New decisions must be DRAFT or OPEN")
+		}
+		return 0, nil
+	}
+
+	if current.Status == models.DecisionStatusClosed {
+		return http.StatusConflict, errors.New("Closed decisions cannot be changed")
+	}
+	if current.Status == models.DecisionStatusOpen && req.Status == models.DecisionStatusDraft {
+		return http.StatusConflict, errors.New("Open decisions cannot return to draft")
+	}
+	if req.Options != nil && !sameDecisionOptions(current.Options, req.Options) {
+		if current.Status != models.DecisionStatusDraft {
+			return http.StatusConflict, errors.New("Options can only be changed while the decision is a draft")
+		}
+		if closing {
+			return http.StatusBadRequest, errors.New("Options cannot be changed while closing a decision")
+		}
+	}
+
+	if closing {
+		if req.Rationale == "" {
+			return http.StatusBadRequest, errors.New("A rationale is required to close a decision")
+		}
+		if req.OutcomeOptionID == nil && len(current.Options) > 0 {
+			return http.StatusBadRequest, errors.New("Choose the winning option to close this decision")
+		}
+		if req.OutcomeOptionID != nil && !hasDecisionOption(current.Options, *req.OutcomeOptionID) {
+			return http.StatusBadRequest, errors.New("Outcome option does not belong to this decision")
+		}
+	}
+	return 0, nil
+}
+
+func sameDecisionOptions(current []models.DecisionOption, requested []models.DecisionOptionRequest) bool {
+	if len(current) != len(requested) {
+		return false
+	}
+	for i := range current {
+		if current[i].Label != requested[i].Label || current[i].Description != requested[i].Description {
+			return false
+		}
+	}
+	return true
 }
 
-type decisionScanner interface {
-	Scan(dest ...interface{}) error
+func hasDecisionOption(options []models.DecisionOption, optionID int) bool {
+	for _, option := range options {
+		if option.ID == optionID {
+			return true
+		}
+	}
+	return false
 }
 
-func scanDecision(scanner decisionScanner) (*models.Decision, error) {
-	var (
-		decision models.Decision
-		dueDate  sql.NullTime
-	)
-
-	err := scanner.Scan(
-		&decision.ID,
-		&decision.WorkspaceID,
-		&decision.UserID,
-		&decision.Title,
-		&decision.Description,
-		&decision.Status,
-		&dueDate,
-		&decision.CreatedAt,
-		&decision.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
+// validateBallot checks a ranking names each of the decision's options at most once.
+func validateBallot(options []models.DecisionOption, optionIDs []int) error {
+	if len(optionIDs) == 0 {
+		return errors.New("Choose at least one option")
 	}
-	if dueDate.Valid {
-		decision.DueDate = &dueDate.Time
+	seen := make(map[int]bool, len(optionIDs))
+	for _, optionID := range optionIDs {
+		if !hasDecisionOption(options, optionID) {
+			return errors.New("Option does not belong to this decision")
+		}
+		if seen[optionID] {
+			return errors.New("Each option can only be ranked once")
+		}
+		seen[optionID] = true
 	}
-	return &decision, nil
+	return nil
 }
 
 func extractDecisionIDs(path string) (workspaceID int, decisionID int, err error) {
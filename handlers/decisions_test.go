@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
 	"sentinent-backend/models"
 	"testing"
 )
@@ -14,13 +15,10 @@ func TestUpdateDecision(t *testing.T) {
 	seedWorkspaceCollaborationData(t)
 
 	// 1. Create a decision first
-	_, err := database.DB.Exec(`
+	dbtest.Seed(t, `
 		INSERT INTO decisions (id, workspace_id, user_id, title, description, status)
 		VALUES (1, 10, 3, 'Initial Title', 'Initial Description', 'DRAFT')
 	`)
-	if err != nil {
-		t.Fatalf("failed to seed decision: %v", err)
-	}
 
 	// 2. Update the decision as the owner (user 3 is a member, but we'll assume they own this decision)
 	updateBody := []byte(`{"title":"Updated Title","description":"Updated Description","status":"OPEN"}`)
@@ -43,7 +41,7 @@ func TestUpdateDecision(t *testing.T) {
 
 	// 3. Verify in DB
 	var title, status string
-	err = database.DB.QueryRow("SELECT title, status FROM decisions WHERE id = 1").Scan(&title, &status)
+	err := database.DB.QueryRow("SELECT title, status FROM decisions WHERE id = 1").Scan(&title, &status)
 	if err != nil {
 		t.Fatalf("failed to query decision: %v", err)
 	}
@@ -57,9 +55,9 @@ func TestUpdateDecisionForbidden(t *testing.T) {
 	seedWorkspaceCollaborationData(t)
 
 	// Create user 4 who is NOT in workspace 10
-	_, _ = database.DB.Exec("INSERT INTO users (id, email, password) VALUES (4, 'stranger@example.com', 'pw')")
+	dbtest.Seed(t, "INSERT INTO users (id, email, password) VALUES (4, 'stranger@example.com', 'pw')")
 
-	_, _ = database.DB.Exec(`
+	dbtest.Seed(t, `
 		INSERT INTO decisions (id, workspace_id, user_id, title, description, status)
 		VALUES (1, 10, 3, 'Initial Title', 'Initial Description', 'DRAFT')
 	`)
@@ -73,3 +71,184 @@ func TestUpdateDecisionForbidden(t *testing.T) {
 		t.Fatalf("expected 403, got %d", updateRR.Code)
 	}
 }
+
+func TestDecisionSignalLinks(t *testing.T) {
+	setupSignalsTestDB(t)
+
+	seed := []string{
+		`INSERT INTO users (id, email, password) VALUES (2, 'viewer@example.com', 'hashed-password')`,
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (7, 2, 'viewer')`,
+		`INSERT INTO decisions (id, workspace_id, user_id, title, status) VALUES (1, 7, 1, 'Ship it', 'OPEN')`,
+		`INSERT INTO signals (id, user_id, workspace_id, source_type, source_id, external_id, title, status)
+		 VALUES (3, 1, 8, 'github', 'sig-3', 'external-3', 'Other workspace', 'unread'),
+		        (4, 2, NULL, 'gmail', 'sig-4', 'external-4', 'Someone else''s', 'unread'),
+		        (5, 2, 7, 'github', 'sig-5', 'external-5', 'Teammate''s import', 'unread')`,
+	}
+	dbtest.Seed(t, seed...)
+
+	link := func(userID int, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		WorkspacesRouter(rr, requestWithUser(http.MethodPost, "/api/workspaces/7/decisions/1/signals", []byte(body), userID, "reader@example.com"))
+		return rr
+	}
+
+	if rr := link(1, `{"signal_id":1}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201 from LinkDecisionSignal, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := link(1, `{"signal_id":1}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected duplicate link to conflict, got %d", rr.Code)
+	}
+	if rr := link(1, `{"signal_id":3}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected signal from another workspace to be refused, got %d", rr.Code)
+	}
+	if rr := link(1, `{"signal_id":4}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected another user's personal signal to be refused, got %d", rr.Code)
+	}
+	if rr := link(1, `{"signal_id":5}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected a teammate's workspace signal to be linkable, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := link(2, `{"signal_id":4}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected viewer to be forbidden, got %d", rr.Code)
+	}
+
+	listRR := httptest.NewRecorder()
+	WorkspacesRouter(listRR, requestWithUser(http.MethodGet, "/api/workspaces/7/decisions/1/signals", nil, 2, "viewer@example.com"))
+	if listRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from ListDecisionSignals, got %d: %s", listRR.Code, listRR.Body.String())
+	}
+	var evidence []models.LinkedSignal
+	if err := json.Unmarshal(listRR.Body.Bytes(), &evidence); err != nil {
+		t.Fatalf("failed to decode linked signals: %v", err)
+	}
+	if len(evidence) != 2 {
+		t.Fatalf("unexpected linked signals: %+v", evidence)
+	}
+	for _, linked := range evidence {
+		if (linked.ID != 1 && linked.ID != 5) || linked.LinkedBy != 1 {
+			t.Fatalf("unexpected linked signals: %+v", evidence)
+		}
+	}
+
+	getRR := httptest.NewRecorder()
+	GetSignal(getRR, signalRequestWithUser(http.MethodGet, "/api/signals/1"))
+	var signal models.Signal
+	if err := json.Unmarshal(getRR.Body.Bytes(), &signal); err != nil {
+		t.Fatalf("failed to decode signal: %v", err)
+	}
+	if len(signal.Decisions) != 1 || signal.Decisions[0].ID != 1 || signal.Decisions[0].Title != "Ship it" {
+		t.Fatalf("expected signal to reference the decision, got %+v", signal.Decisions)
+	}
+
+	unlink := func() int {
+		rr := httptest.NewRecorder()
+		WorkspacesRouter(rr, requestWithUser(http.MethodDelete, "/api/workspaces/7/decisions/1/signals/1", nil, 1, "reader@example.com"))
+		return rr.Code
+	}
+	if code := unlink(); code != http.StatusNoContent {
+		t.Fatalf("expected 204 from UnlinkDecisionSignal, got %d", code)
+	}
+	if code := unlink(); code != http.StatusNotFound {
+		t.Fatalf("expected missing link to return 404, got %d", code)
+	}
+}
+
+func TestDecisionVotingWorkflow(t *testing.T) {
+	setupCollaborationTestDB(t)
+	seedWorkspaceCollaborationData(t)
+	if _, err := database.DB.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (10, 2, 'viewer')"); err != nil {
+		t.Fatalf("failed to seed viewer: %v", err)
+	}
+
+	send := func(method, target, body string, userID int) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		WorkspacesRouter(rr, requestWithUser(method, target, []byte(body), userID, ""))
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) models.Decision {
+		t.Helper()
+		var decision models.Decision
+		if err := json.Unmarshal(rr.Body.Bytes(), &decision); err != nil {
+			t.Fatalf("failed to parse decision: %v", err)
+		}
+		return decision
+	}
+
+	createRR := send(http.MethodPost, "/api/workspaces/10/decisions",
+		`{"title":"Pick a queue","options":[{"label":"SQS"},{"label":"Kafka"},{"label":"Postgres"}]}`, 3)
+	if createRR.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", createRR.Code, createRR.Body.String())
+	}
+	created := decode(createRR)
+	if len(created.Options) != 3 || created.Options[0].Label != "SQS" {
+		t.Fatalf("expected three options in order, got %+v", created.Options)
+	}
+	sqs, kafka, postgres := created.Options[0].ID, created.Options[1].ID, created.Options[2].ID
+	decisionPath := "/api/workspaces/10/decisions/" + strconvFormatInt(int64(created.ID))
+	votePath := decisionPath + "/vote"
+
+	if rr := send(http.MethodPut, votePath, `{"option_ids":[`+strconvFormatInt(int64(sqs))+`]}`, 3); rr.Code != http.StatusConflict {
+		t.Fatalf("expected voting on a draft to conflict, got %d", rr.Code)
+	}
+	if rr := send(http.MethodPatch, decisionPath, `{"title":"Pick a queue","status":"OPEN"}`, 3); rr.Code != http.StatusOK {
+		t.Fatalf("expected decision to open, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send(http.MethodPatch, decisionPath, `{"title":"Pick a queue","status":"OPEN","options":[{"label":"SQS"}]}`, 3); rr.Code != http.StatusConflict {
+		t.Fatalf("expected option changes on an open decision to conflict, got %d", rr.Code)
+	}
+
+	ballot := func(ids ...int) string {
+		body := `{"option_ids":[`
+		for i, id := range ids {
+			if i > 0 {
+				body += ","
+			}
+			body += strconvFormatInt(int64(id))
+		}
+		return body + `]}`
+	}
+	if rr := send(http.MethodPut, votePath, ballot(kafka, sqs), 3); rr.Code != http.StatusOK {
+		t.Fatalf("expected member vote to be recorded, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send(http.MethodPut, votePath, ballot(kafka, kafka), 1); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected duplicate ranking to be rejected, got %d", rr.Code)
+	}
+	voted := decode(send(http.MethodPut, votePath, ballot(kafka, postgres, sqs), 1))
+	if voted.Ballots != 2 || len(voted.MyRanking) != 3 || voted.MyRanking[0] != kafka {
+		t.Fatalf("unexpected ballot state: ballots=%d ranking=%v", voted.Ballots, voted.MyRanking)
+	}
+	if rr := send(http.MethodPut, votePath, ballot(sqs), 2); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected viewer vote to be forbidden, got %d", rr.Code)
+	}
+
+	viewerRR := send(http.MethodGet, decisionPath, "", 2)
+	if viewerRR.Code != http.StatusOK {
+		t.Fatalf("expected viewer to see the decision, got %d", viewerRR.Code)
+	}
+	tally := decode(viewerRR).Options
+	// Kafka: two first preferences (3 points each). SQS: 2 + 1. Postgres: 2.
+	if tally[1].Votes != 2 || tally[1].Points != 6 || tally[0].Points != 3 || tally[2].Points != 2 {
+		t.Fatalf("unexpected tally: %+v", tally)
+	}
+
+	if rr := send(http.MethodPatch, decisionPath, `{"title":"Pick a queue","status":"CLOSED","rationale":"Team consensus"}`, 3); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected closing without an outcome to be rejected, got %d", rr.Code)
+	}
+	closeBody := `{"title":"Pick a queue","status":"CLOSED","outcome_option_id":` + strconvFormatInt(int64(kafka)) + `,"rationale":"Team consensus"}`
+	closeRR := send(http.MethodPatch, decisionPath, closeBody, 1)
+	if closeRR.Code != http.StatusOK {
+		t.Fatalf("expected decision to close, got %d: %s", closeRR.Code, closeRR.Body.String())
+	}
+	closed := decode(closeRR)
+	if closed.Status != models.DecisionStatusClosed || closed.Outcome == nil ||
+		closed.Outcome.OptionID == nil || *closed.Outcome.OptionID != kafka ||
+		closed.Outcome.ClosedBy != 1 || closed.Outcome.Rationale != "Team consensus" {
+		t.Fatalf("unexpected closed decision: %+v outcome=%+v", closed, closed.Outcome)
+	}
+
+	if rr := send(http.MethodPut, votePath, ballot(sqs), 3); rr.Code != http.StatusConflict {
+		t.Fatalf("expected voting on a closed decision to conflict, got %d", rr.Code)
+	}
+	if rr := send(http.MethodPatch, decisionPath, `{"title":"Pick a queue","status":"OPEN"}`, 3); rr.Code != http.StatusConflict {
+		t.Fatalf("expected closed decision to stay closed, got %d", rr.Code)
+	}
+}
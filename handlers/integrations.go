@@ -1,114 +1,103 @@
 package handlers
 
 import (
-	"context"
-	"crypto/hmac"
-	"crypto/sha256"
 	"crypto/subtle"
-	"database/sql"
-	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
+	"html"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	"sentinent-backend/database"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"sentinent-backend/services"
 	"sentinent-backend/utils"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/oauth2"
 )
 
 var (
-	slackClient           = services.NewSlackClient()
-	tokenEncryptor        *utils.TokenEncryptor
-	slackClientID         string
-	slackClientSecret     string
-	gmailClientID         string
-	gmailClientSecret     string
-	slackExchangeCodeFunc = func(clientID, clientSecret, code, redirectURI string) (*services.SlackOAuthResponse, error) {
-		return slackClient.ExchangeCodeForToken(clientID, clientSecret, code, redirectURI)
-	}
-	githubAuthURLFunc         = services.GetGitHubAuthURL
-	githubExchangeCodeFunc    = services.ExchangeGitHubCode
-	githubSaveIntegrationFunc = services.SaveGitHubIntegration
-	githubSyncSignalsFunc     = services.SyncGitHubSignals
-	gmailExchangeCodeFunc     = exchangeGmailCode
-	gmailFetchProfileFunc     = fetchGmailProfile
+	// lookupIntegrationProvider and integrationProviders read the provider
+	// registry; tests replace them to route to stub providers.
+	lookupIntegrationProvider = services.LookupProvider
+	integrationProviders      = services.Providers
 )
 
-const (
-	githubOAuthStateCookieName = "github_oauth_state"
-	gmailOAuthStateCookieName  = "gmail_oauth_state"
-	githubOAuthStateTTL        = 10 * time.Minute
-	gmailOAuthStateTTL         = 10 * time.Minute
-	slackOAuthStateTTL         = 10 * time.Minute
-	githubWebhookSecretEnv     = "GITHUB_WEBHOOK_SECRET"
-)
-
-var errGitHubWebhookSecretMissing = errors.New("github webhook secret is not configured")
-
-type githubOAuthStateClaims struct {
-	Email       string `json:"email"`
-	WorkspaceID int    `json:"workspace_id"`
-	RedirectURL string `json:"redirect_url,omitempty"`
-	jwt.RegisteredClaims
-}
-
-type slackOAuthStateClaims struct {
-	UserID      int `json:"user_id"`
-	WorkspaceID int `json:"workspace_id"`
-	jwt.RegisteredClaims
-}
+const integrationOAuthStateTTL = 10 * time.Minute
 
-type gmailOAuthStateClaims struct {
-	Email       string `json:"email"`
+type integrationOAuthStateClaims struct {
+	Provider    string `json:"provider"`
+	UserID      int    `json:"user_id"`
+	WorkspaceID int    `json:"workspace_id,omitempty"`
 	RedirectURL string `json:"redirect_url,omitempty"`
 	jwt.RegisteredClaims
 }
 
-type gmailProfile struct {
-	Email         string `json:"email"`
-	Name          string `json:"name"`
-	VerifiedEmail bool   `json:"verified_email"`
-}
+// IntegrationsRouter serves the protected /api/integrations/ routes for every
+// registered provider:
+//
+//	GET    /api/integrations/status
+//	DELETE /api/integrations/{id}
+//	GET    /api/integrations/{id}/health
+//	DELETE /api/integrations/{provider}
+//	GET    /api/integrations/{provider}/auth
+//	POST   /api/integrations/{provider}/sync
+//	GET    /api/integrations/jobs/{id}
+//	*      /api/integrations/{provider}/{action...}
+func IntegrationsRouter(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/integrations/"), "/")
+	name, rest, _ := strings.Cut(path, "/")
 
-func InitIntegrationHandlers() error {
-	slackClientID = os.Getenv("SLACK_CLIENT_ID")
-	slackClientSecret = os.Getenv("SLACK_CLIENT_SECRET")
-	gmailClientID = strings.TrimSpace(os.Getenv("GOOGLE_CLIENT_ID"))
-	gmailClientSecret = strings.TrimSpace(os.Getenv("GOOGLE_CLIENT_SECRET"))
+	if name == "status" && rest == "" {
+		IntegrationStatusHandler(w, r)
+		return
+	}
+	if name == "jobs" && rest != "" {
+		syncJobStatus(w, r, rest)
+		return
+	}
+	if integrationID, err := strconv.Atoi(name); err == nil {
+		switch rest {
+		case "":
+			DeleteIntegration(w, r)
+		case "health":
+			integrationHealth(w, r, integrationID)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+		return
+	}
 
-	needsTokenEncryptor := (slackClientID != "" && slackClientSecret != "") || (gmailClientID != "" && gmailClientSecret != "")
-	if !needsTokenEncryptor {
-		return nil
+	provider, ok := lookupIntegrationProvider(name)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
 	}
 
-	encryptor, err := utils.NewTokenEncryptor()
-	if err != nil {
-		return err
+	switch rest {
+	case "":
+		integrationDisconnect(w, r, provider)
+	case "auth":
+		integrationAuth(w, r, provider)
+	case "sync":
+		integrationSync(w, r, provider)
+	default:
+		integrationAction(w, r, provider, rest)
 	}
-	tokenEncryptor = encryptor
-	return nil
 }
 
-func SlackAuth(w http.ResponseWriter, r *http.Request) {
+func integrationAuth(w http.ResponseWriter, r *http.Request, provider services.Provider) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if !isSlackConfigured() {
-		http.Error(w, "Slack integration not configured", http.StatusServiceUnavailable)
+	if !provider.Configured() {
+		http.Error(w, provider.DisplayName()+" integration not configured", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -118,220 +107,183 @@ func SlackAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	workspaceIDStr := r.URL.Query().Get("workspace_id")
-	if workspaceIDStr == "" {
-		http.Error(w, "workspace_id is required", http.StatusBadRequest)
-		return
+	var workspaceID int
+	if provider.Scope() == services.ScopeWorkspace {
+		var statusCode int
+		workspaceID, statusCode, err = getAuthorizedWorkspaceID(r, userID)
+		if err != nil {
+			http.Error(w, err.Error(), statusCode)
+			return
+		}
 	}
 
-	workspaceID, err := strconv.Atoi(workspaceIDStr)
+	redirectURL := sanitizeRedirectURL(r.URL.Query().Get("redirect_url"))
+	state, err := createIntegrationOAuthState(provider.Name(), userID, workspaceID, redirectURL, time.Now())
 	if err != nil {
-		http.Error(w, "Invalid workspace_id", http.StatusBadRequest)
+		http.Error(w, "Failed to create OAuth state", http.StatusInternalServerError)
 		return
 	}
 
-	state, err := createSlackOAuthState(userID, workspaceID, time.Now())
-	if err != nil {
-		http.Error(w, "Failed to create OAuth state", http.StatusInternalServerError)
+	authURL := provider.AuthURL(state, integrationCallbackURI(r, provider))
+	if authURL == "" {
+		http.Error(w, provider.DisplayName()+" integration not configured", http.StatusServiceUnavailable)
 		return
 	}
-	redirectURI := getSlackRedirectURI(r)
-	authURL := "https://slack.com/oauth/v2/authorize?" +
-		"client_id=" + slackClientID +
-		"&scope=channels:history,channels:read,chat:write,users:read,groups:read,im:history,groups:history,channels:join" +
-		"&redirect_uri=" + redirectURI +
-		"&state=" + state
 
 	http.SetCookie(w, &http.Cookie{
-		Name:     "slack_oauth_state",
+		Name:     integrationOAuthStateCookieName(provider),
 		Value:    state,
-		Expires:  time.Now().Add(10 * time.Minute),
+		Expires:  time.Now().Add(integrationOAuthStateTTL),
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
+		Secure:   utils.IsProductionEnv(),
 	})
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{"auth_url": authURL})
 }
 
-func SlackCallback(w http.ResponseWriter, r *http.Request) {
+// IntegrationCallback completes the OAuth flow started by
+// /api/integrations/{provider}/auth. It is public, so the caller is identified
+// by the signed state alone.
+func IntegrationCallback(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if !isSlackConfigured() {
-		http.Error(w, "Slack integration not configured", http.StatusServiceUnavailable)
+
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/integrations/"), "/callback")
+	provider, ok := lookupIntegrationProvider(name)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if !provider.Configured() {
+		http.Error(w, provider.DisplayName()+" integration not configured", http.StatusServiceUnavailable)
 		return
 	}
 
 	state := r.URL.Query().Get("state")
-	userID, workspaceID, err := validateSlackOAuthState(state)
+	if remote, ok := provider.(services.RemoteCallbackProvider); !ok || !remote.RemoteCallback() {
+		stateCookie, err := r.Cookie(integrationOAuthStateCookieName(provider))
+		if err != nil || subtle.ConstantTimeCompare([]byte(state), []byte(stateCookie.Value)) != 1 {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+	}
+
+	claims, err := validateIntegrationOAuthState(provider.Name(), state)
 	if err != nil {
 		http.Error(w, "Invalid state", http.StatusBadRequest)
 		return
 	}
+	fail := func(message string, statusCode int) {
+		if redirectOAuthResultIfPossible(w, r, claims.RedirectURL, provider.Name(), "failed") {
+			return
+		}
+		http.Error(w, message, statusCode)
+	}
 
 	code := r.URL.Query().Get("code")
 	if code == "" {
-		http.Error(w, "Authorization code not provided", http.StatusBadRequest)
+		fail("Authorization code not provided", http.StatusBadRequest)
 		return
 	}
 
-	redirectURI := getSlackRedirectURI(r)
-	oauthResp, err := slackExchangeCodeFunc(slackClientID, slackClientSecret, code, redirectURI)
+	grant, err := provider.Exchange(r.Context(), code, integrationCallbackURI(r, provider))
 	if err != nil {
-		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
+		fail("Failed to exchange code: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	encryptedAccessToken, err := tokenEncryptor.Encrypt(oauthResp.AccessToken)
-	if err != nil {
-		http.Error(w, "Failed to encrypt token", http.StatusInternalServerError)
-		return
+	if provider.Scope() == services.ScopeWorkspace {
+		role, err := middleware.GetWorkspaceRole(claims.UserID, claims.WorkspaceID)
+		if err != nil {
+			fail("Failed to verify workspace access", http.StatusInternalServerError)
+			return
+		}
+		if role == "" {
+			fail("Forbidden: Not a member of this workspace", http.StatusForbidden)
+			return
+		}
 	}
 
-	metadata := map[string]interface{}{
-		"team_id":     oauthResp.Team.ID,
-		"team_name":   oauthResp.Team.Name,
-		"bot_user_id": oauthResp.BotUserID,
-		"app_id":      oauthResp.AppID,
-		"scope":       oauthResp.Scope,
-	}
-	metadataJSON, _ := json.Marshal(metadata)
-
-	var existingID int
-	err = database.DB.QueryRow(
-		`SELECT id FROM external_integrations
-		 WHERE user_id = ? AND workspace_id = ? AND provider = 'slack'`,
-		userID, workspaceID,
-	).Scan(&existingID)
-
-	switch err {
-	case sql.ErrNoRows:
-		_, err = database.DB.Exec(
-			`INSERT INTO external_integrations
-			 (user_id, workspace_id, provider, access_token, metadata, updated_at)
-			 VALUES (?, ?, 'slack', ?, ?, CURRENT_TIMESTAMP)`,
-			userID, workspaceID, encryptedAccessToken, string(metadataJSON),
-		)
-	case nil:
-		_, err = database.DB.Exec(
-			`UPDATE external_integrations
-			 SET access_token = ?, metadata = ?, updated_at = CURRENT_TIMESTAMP
-			 WHERE id = ?`,
-			encryptedAccessToken, string(metadataJSON), existingID,
-		)
-	}
-	if err != nil {
-		http.Error(w, "Failed to save integration", http.StatusInternalServerError)
+	if err := services.SaveIntegration(provider, claims.UserID, claims.WorkspaceID, grant); err != nil {
+		fail("Failed to save integration: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:     "slack_oauth_state",
+		Name:     integrationOAuthStateCookieName(provider),
 		Value:    "",
 		Expires:  time.Unix(0, 0),
 		Path:     "/",
 		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   utils.IsProductionEnv(),
 	})
 
+	if integration, err := services.GetIntegration(provider, claims.UserID, claims.WorkspaceID); err != nil {
+		log.Printf("Failed to load new %s integration for initial sync: %v", provider.DisplayName(), err)
+	} else if _, err := services.EnqueueSync(*integration); err != nil {
+		log.Printf("Failed to queue initial %s sync: %v", provider.DisplayName(), err)
+	}
+
+	if redirectOAuthResultIfPossible(w, r, claims.RedirectURL, provider.Name(), "connected") {
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(`<html><body style="font-family: sans-serif; text-align: center; margin-top: 50px;"><h2>Slack Connected Successfully!</h2><p>You can close this window to return to Sentinent.</p><script>setTimeout(function() { window.close(); }, 1000);</script></body></html>`))
+	w.Write([]byte(`<html><body style="font-family: sans-serif; text-align: center; margin-top: 50px;"><h2>` +
+		html.EscapeString(provider.DisplayName()) +
+		` Connected Successfully!</h2><p>You can close this window to return to Sentinent.</p><script>setTimeout(function() { window.close(); }, 1000);</script></body></html>`))
 }
 
-func GetIntegrations(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+func integrationSync(w http.ResponseWriter, r *http.Request, provider services.Provider) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	userID, workspaceID, ok := integrationScopeFromRequest(w, r, provider)
+	if !ok {
 		return
 	}
-
-	query := `SELECT id, user_id, workspace_id, provider, metadata, created_at, updated_at
-		FROM external_integrations WHERE user_id = ?`
-	args := []interface{}{userID}
-
-	if workspaceIDStr := r.URL.Query().Get("workspace_id"); workspaceIDStr != "" {
-		workspaceID, convErr := strconv.Atoi(workspaceIDStr)
-		if convErr != nil {
-			http.Error(w, "Invalid workspace_id", http.StatusBadRequest)
-			return
-		}
-		query += " AND (workspace_id = ? OR workspace_id IS NULL)"
-		args = append(args, workspaceID)
+	if !provider.Configured() {
+		http.Error(w, provider.DisplayName()+" integration not configured", http.StatusServiceUnavailable)
+		return
 	}
 
-	log.Printf("[GetIntegrations] userID=%d query=%s args=%v", userID, query, args)
-	rows, err := database.DB.Query(query, args...)
+	integration, err := services.GetIntegration(provider, userID, workspaceID)
 	if err != nil {
-		http.Error(w, "Failed to fetch integrations", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var integrations []models.ExternalIntegration
-	for rows.Next() {
-		var integration models.ExternalIntegration
-		var workspaceID sql.NullInt64
-		var metadata sql.NullString
-		if err := rows.Scan(
-			&integration.ID,
-			&integration.UserID,
-			&workspaceID,
-			&integration.Provider,
-			&metadata,
-			&integration.CreatedAt,
-			&integration.UpdatedAt,
-		); err != nil {
-			continue
-		}
-		if workspaceID.Valid {
-			integration.WorkspaceID = int(workspaceID.Int64)
-		}
-		if metadata.Valid {
-			integration.Metadata = metadata.String
+		if err == repository.ErrNotFound {
+			http.Error(w, "Integration not found", http.StatusNotFound)
+			return
 		}
-		integrations = append(integrations, integration)
+		http.Error(w, "Failed to fetch integration", http.StatusInternalServerError)
+		return
 	}
 
-	// Deduplicate: if both a workspace-scoped and NULL-workspace record exist for
-	// the same provider, prefer the workspace-scoped one. This prevents duplicate
-	// integration entries from confusing the frontend's connection state logic.
-	seen := make(map[string]int) // provider -> index of best record
-	for i, integration := range integrations {
-		if prev, exists := seen[integration.Provider]; exists {
-			// Keep the one with a non-zero workspace_id
-			if integrations[prev].WorkspaceID == 0 && integration.WorkspaceID != 0 {
-				seen[integration.Provider] = i
-			}
-		} else {
-			seen[integration.Provider] = i
-		}
+	job, err := services.EnqueueSync(*integration)
+	if err == services.ErrIntegrationNeedsReauth {
+		http.Error(w, provider.DisplayName()+" integration needs to be reconnected", http.StatusConflict)
+		return
 	}
-	deduped := make([]models.ExternalIntegration, 0, len(seen))
-	for _, idx := range seen {
-		deduped = append(deduped, integrations[idx])
+	if err != nil {
+		http.Error(w, "Failed to queue sync", http.StatusInternalServerError)
+		return
 	}
 
-	log.Printf("[GetIntegrations] returning %d integrations (from %d raw), providers: %v", len(deduped), len(integrations), func() []string {
-		ps := make([]string, len(deduped))
-		for i, d := range deduped {
-			ps[i] = fmt.Sprintf("%s(ws=%d)", d.Provider, d.WorkspaceID)
-		}
-		return ps
-	}())
-
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(deduped)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"job_id": job.ID, "status": job.Status})
 }
 
-func DeleteIntegration(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
+// syncJobStatus returns a queued sync job so clients can poll the result of
+// POST /api/integrations/{provider}/sync.
+func syncJobStatus(w http.ResponseWriter, r *http.Request, rawJobID string) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -342,48 +294,33 @@ func DeleteIntegration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	integrationIDStr := r.URL.Path[len("/api/integrations/"):]
-	integrationID, err := strconv.Atoi(integrationIDStr)
+	jobID, err := strconv.Atoi(rawJobID)
 	if err != nil {
-		http.Error(w, "Invalid integration ID", http.StatusBadRequest)
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
 		return
 	}
 
-	var ownerID int
-	err = database.DB.QueryRow(
-		"SELECT user_id FROM external_integrations WHERE id = ?",
-		integrationID,
-	).Scan(&ownerID)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Integration not found", http.StatusNotFound)
+	job, err := repository.Default().SyncJobs.Get(jobID)
+	if err == repository.ErrNotFound || (err == nil && job.UserID != userID) {
+		http.Error(w, "Sync job not found", http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		http.Error(w, "Failed to fetch integration", http.StatusInternalServerError)
-		return
-	}
-	if ownerID != userID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	if _, err := database.DB.Exec("DELETE FROM external_integrations WHERE id = ?", integrationID); err != nil {
-		http.Error(w, "Failed to delete integration", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch sync job", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job)
 }
 
-func GetSlackChannels(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+// integrationHealth reports the sync health of one of the caller's
+// integrations.
+func integrationHealth(w http.ResponseWriter, r *http.Request, integrationID int) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if !isSlackConfigured() {
-		http.Error(w, "Slack integration not configured", http.StatusServiceUnavailable)
-		return
-	}
 
 	userID, err := getUserIDFromContext(r)
 	if err != nil {
@@ -391,30 +328,8 @@ func GetSlackChannels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Method == http.MethodPatch {
-		updateSlackChannelSelection(w, r, userID)
-		return
-	}
-
-	integrationIDStr := r.URL.Query().Get("integration_id")
-	if integrationIDStr == "" {
-		http.Error(w, "integration_id is required", http.StatusBadRequest)
-		return
-	}
-
-	integrationID, err := strconv.Atoi(integrationIDStr)
-	if err != nil {
-		http.Error(w, "Invalid integration_id", http.StatusBadRequest)
-		return
-	}
-
-	var encryptedToken string
-	err = database.DB.QueryRow(
-		`SELECT access_token FROM external_integrations
-		 WHERE id = ? AND user_id = ? AND provider = 'slack'`,
-		integrationID, userID,
-	).Scan(&encryptedToken)
-	if err == sql.ErrNoRows {
+	integration, err := repository.Default().Integrations.GetByID(integrationID)
+	if err == repository.ErrNotFound || (err == nil && integration.UserID != userID) {
 		http.Error(w, "Integration not found", http.StatusNotFound)
 		return
 	}
@@ -423,194 +338,173 @@ func GetSlackChannels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, err := tokenEncryptor.Decrypt(encryptedToken)
-	if err != nil {
-		http.Error(w, "Failed to decrypt token", http.StatusInternalServerError)
-		return
-	}
-
-	channels, rateLimit, err := slackClient.GetChannels(accessToken)
+	health, err := services.IntegrationHealth(integration)
 	if err != nil {
-		if rateLimit != nil && rateLimit.IsRateLimited() {
-			http.Error(w, "Rate limited by Slack API", http.StatusTooManyRequests)
-			return
-		}
-		http.Error(w, "Failed to fetch channels: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch integration health", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{"channels": channels})
+	_ = json.NewEncoder(w).Encode(health)
 }
 
-func SlackWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+func integrationDisconnect(w http.ResponseWriter, r *http.Request, provider services.Provider) {
+	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Invalid body", http.StatusBadRequest)
-		return
-	}
-
-	// Verify signature if secret is configured
-	signingSecret := os.Getenv("SLACK_SIGNING_SECRET")
-	if signingSecret != "" {
-		signature := r.Header.Get("X-Slack-Signature")
-		timestamp := r.Header.Get("X-Slack-Request-Timestamp")
-		if err := slackClient.ValidateWebhookRequest(body, signature, timestamp, signingSecret); err != nil {
-			log.Printf("Slack webhook signature validation failed: %v", err)
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
-			return
-		}
-	}
-
-	var event services.SlackWebhookEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	userID, workspaceID, ok := integrationScopeFromRequest(w, r, provider)
+	if !ok {
 		return
 	}
 
-	// Handle URL verification challenge
-	if event.Type == "url_verification" {
-		w.Header().Set("Content-Type", "text/plain")
-		w.Write([]byte(event.Challenge))
+	if err := services.DeleteIntegration(provider, userID, workspaceID); err != nil {
+		http.Error(w, "Failed to disconnect "+provider.DisplayName(), http.StatusInternalServerError)
 		return
 	}
 
-	// Handle event callbacks
-	if event.Type == "event_callback" {
-		var innerEvent services.SlackMessageEvent
-		if err := json.Unmarshal(event.Event, &innerEvent); err == nil {
-			if innerEvent.Type == "message" && innerEvent.User != "" {
-				go handleSlackMessageEvent(event.TeamID, innerEvent)
-			}
-		}
-	}
-
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "disconnected"})
 }
 
-func handleSlackMessageEvent(teamID string, event services.SlackMessageEvent) {
-	// Find integrations for this team
-	rows, err := database.DB.Query(
-		"SELECT user_id, workspace_id, metadata FROM external_integrations WHERE provider = 'slack'",
-	)
-	if err != nil {
-		log.Printf("Failed to query integrations for Slack webhook: %v", err)
+func integrationAction(w http.ResponseWriter, r *http.Request, provider services.Provider, path string) {
+	actions, ok := provider.(services.ActionProvider)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var userID, workspaceID int
-		var metadataStr string
-		if err := rows.Scan(&userID, &workspaceID, &metadataStr); err != nil {
+	for _, action := range actions.Actions() {
+		matched := path == action.Path
+		if strings.HasSuffix(action.Path, "/") {
+			matched = strings.HasPrefix(path, action.Path)
+		}
+		if !matched {
 			continue
 		}
 
-		var metadata map[string]interface{}
-		json.Unmarshal([]byte(metadataStr), &metadata)
-
-		if metadata["team_id"] == teamID {
-			// Check if channel is selected
-			selectedChannels, ok := metadata["selected_channels"].([]interface{})
-			isChannelSelected := false
-			if !ok || len(selectedChannels) == 0 {
-				isChannelSelected = true // Default to all if none selected (as per sync.go)
-			} else {
-				for _, ch := range selectedChannels {
-					if chStr, ok := ch.(string); ok && chStr == event.Channel {
-						isChannelSelected = true
-						break
-					}
-				}
-			}
-
-			if isChannelSelected {
-				msg := services.SlackMessage{
-					User: event.User,
-					Text: event.Text,
-					TS:   event.TS,
-				}
-				// Parse timestamp
-				parts := strings.Split(event.TS, ".")
-				if len(parts) > 0 {
-					sec, _ := strconv.ParseInt(parts[0], 10, 64)
-					msg.Timestamp = sec
-				}
-
-				if err := services.SaveSlackSignal(database.DB, userID, workspaceID, msg, event.Channel, event.User); err != nil {
-					log.Printf("Failed to save Slack webhook signal: %v", err)
-				}
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		actionContext := services.ActionContext{UserID: userID}
+		if r.URL.Query().Get("workspace_id") != "" {
+			workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
+			if err != nil {
+				http.Error(w, err.Error(), statusCode)
+				return
 			}
+			actionContext.WorkspaceID = workspaceID
 		}
+
+		action.Handle(w, r, actionContext)
+		return
 	}
+
+	http.Error(w, "Not found", http.StatusNotFound)
 }
 
-func SlackReplyHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// IntegrationWebhook dispatches /api/webhooks/{provider} to providers that
+// accept webhooks. Each provider verifies its own deliveries.
+func IntegrationWebhook(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/webhooks/"), "/")
+	provider, ok := lookupIntegrationProvider(name)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
+	webhooks, ok := provider.(services.WebhookProvider)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	webhooks.HandleWebhook(w, r)
+}
 
+// integrationScopeFromRequest resolves the caller and, for workspace-scoped
+// providers, the workspace they are a member of. It writes the error response
+// and returns false on failure.
+func integrationScopeFromRequest(w http.ResponseWriter, r *http.Request, provider services.Provider) (int, int, bool) {
 	userID, err := getUserIDFromContext(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+		return 0, 0, false
+	}
+	if provider.Scope() != services.ScopeWorkspace {
+		return userID, 0, true
 	}
 
 	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
 	if err != nil {
 		http.Error(w, err.Error(), statusCode)
-		return
+		return 0, 0, false
 	}
+	return userID, workspaceID, true
+}
 
-	var req struct {
-		ChannelID string `json:"channel_id"`
-		ThreadTS  string `json:"thread_ts"`
-		Text      string `json:"text"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+func GetIntegrations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Fetch token
-	var encryptedToken string
-	err = database.DB.QueryRow(
-		`SELECT access_token FROM external_integrations 
-		 WHERE user_id = ? AND workspace_id = ? AND provider = 'slack'`,
-		userID, workspaceID,
-	).Scan(&encryptedToken)
+	userID, err := getUserIDFromContext(r)
 	if err != nil {
-		http.Error(w, "Integration not found", http.StatusNotFound)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	accessToken, err := tokenEncryptor.Decrypt(encryptedToken)
+	var workspaceID *int
+	if workspaceIDStr := r.URL.Query().Get("workspace_id"); workspaceIDStr != "" {
+		value, convErr := strconv.Atoi(workspaceIDStr)
+		if convErr != nil {
+			http.Error(w, "Invalid workspace_id", http.StatusBadRequest)
+			return
+		}
+		workspaceID = &value
+	}
+
+	log.Printf("[GetIntegrations] userID=%d workspaceID=%v", userID, workspaceID)
+	integrations, err := repository.Default().Integrations.ListForUser(userID, workspaceID)
 	if err != nil {
-		http.Error(w, "Failed to decrypt token", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch integrations", http.StatusInternalServerError)
 		return
 	}
 
-	rateLimit, err := slackClient.PostMessage(accessToken, req.ChannelID, req.Text, req.ThreadTS)
-	if err != nil {
-		if rateLimit != nil && rateLimit.IsRateLimited() {
-			http.Error(w, "Rate limited", http.StatusTooManyRequests)
-			return
+	// Deduplicate: if both a workspace-scoped and NULL-workspace record exist for
+	// the same provider, prefer the workspace-scoped one. This prevents duplicate
+	// integration entries from confusing the frontend's connection state logic.
+	seen := make(map[string]int) // provider -> index of best record
+	for i, integration := range integrations {
+		if prev, exists := seen[integration.Provider]; exists {
+			// Keep the one with a non-zero workspace_id
+			if integrations[prev].WorkspaceID == 0 && integration.WorkspaceID != 0 {
+				seen[integration.Provider] = i
+			}
+		} else {
+			seen[integration.Provider] = i
 		}
-		http.Error(w, "Failed to post message: "+err.Error(), http.StatusInternalServerError)
-		return
 	}
+	deduped := make([]models.ExternalIntegration, 0, len(seen))
+	for _, idx := range seen {
+		deduped = append(deduped, integrations[idx])
+	}
+
+	log.Printf("[GetIntegrations] returning %d integrations (from %d raw), providers: %v", len(deduped), len(integrations), func() []string {
+		ps := make([]string, len(deduped))
+		for i, d := range deduped {
+			ps[i] = fmt.Sprintf("%s(ws=%d)", d.Provider, d.WorkspaceID)
+		}
+		return ps
+	}())
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(deduped)
 }
 
-func SlackDisconnectHandler(w http.ResponseWriter, r *http.Request) {
+func DeleteIntegration(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -622,561 +516,36 @@ func SlackDisconnectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
+	integrationIDStr := r.URL.Path[len("/api/integrations/"):]
+	integrationID, err := strconv.Atoi(integrationIDStr)
 	if err != nil {
-		http.Error(w, err.Error(), statusCode)
+		http.Error(w, "Invalid integration ID", http.StatusBadRequest)
 		return
 	}
 
-	if _, err := database.DB.Exec(
-		"DELETE FROM external_integrations WHERE user_id = ? AND workspace_id = ? AND provider = 'slack'",
-		userID, workspaceID,
-	); err != nil {
-		http.Error(w, "Failed to disconnect Slack", http.StatusInternalServerError)
+	store := repository.Default()
+	ownerID, err := store.Integrations.OwnerID(integrationID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Integration not found", http.StatusNotFound)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "disconnected"})
-}
-
-func SlackSyncHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if err != nil {
+		http.Error(w, "Failed to fetch integration", http.StatusInternalServerError)
 		return
 	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
+	if ownerID != userID {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
-	if err != nil {
-		http.Error(w, err.Error(), statusCode)
-		return
-	}
-
-	go func() {
-		if err := services.SyncSlackSignals(userID, workspaceID); err != nil {
-			log.Printf("Manual Slack sync error: %v", err)
-		}
-	}()
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "sync_started"})
-}
-
-func GmailAuthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	if !isGmailConfigured() {
-		http.Error(w, "Gmail integration not configured", http.StatusServiceUnavailable)
-		return
-	}
-
-	email, ok := middleware.GetUserEmail(r.Context())
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	redirectURL := sanitizeRedirectURL(r.URL.Query().Get("redirect_url"))
-	state, err := createGmailOAuthState(email, redirectURL, time.Now())
-	if err != nil {
-		http.Error(w, "Gmail integration not configured", http.StatusServiceUnavailable)
-		return
-	}
-
-	authURL := buildGmailAuthURL(state, getGmailRedirectURI(r))
-	http.SetCookie(w, &http.Cookie{
-		Name:     gmailOAuthStateCookieName,
-		Value:    state,
-		Expires:  time.Now().Add(gmailOAuthStateTTL),
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   isProductionEnv(),
-	})
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"auth_url": authURL})
-}
-
-func GmailCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	stateCookie, err := r.Cookie(gmailOAuthStateCookieName)
-	if err != nil {
-		http.Error(w, "Invalid state", http.StatusBadRequest)
-		return
-	}
-
-	state := r.URL.Query().Get("state")
-	if subtle.ConstantTimeCompare([]byte(state), []byte(stateCookie.Value)) != 1 {
-		http.Error(w, "Invalid state", http.StatusBadRequest)
-		return
-	}
-
-	userEmail, redirectURL, err := validateGmailOAuthState(state)
-	if err != nil {
-		http.Error(w, "Invalid state", http.StatusBadRequest)
-		return
-	}
-
-	code := r.URL.Query().Get("code")
-	if code == "" {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "gmail", "failed") {
-			return
-		}
-		http.Error(w, "Authorization code not provided", http.StatusBadRequest)
-		return
-	}
-
-	token, err := gmailExchangeCodeFunc(code, getGmailRedirectURI(r))
-	if err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "gmail", "failed") {
-			return
-		}
-		http.Error(w, "Failed to exchange code: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	profile, err := gmailFetchProfileFunc(token)
-	if err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "gmail", "failed") {
-			return
-		}
-		http.Error(w, "Failed to fetch Gmail profile: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	var userID int
-	err = database.DB.QueryRow("SELECT id FROM users WHERE email = ?", userEmail).Scan(&userID)
-	if err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "gmail", "failed") {
-			return
-		}
-		http.Error(w, "User not found", http.StatusUnauthorized)
-		return
-	}
-
-	if err := saveGmailIntegration(userID, token, profile); err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "gmail", "failed") {
-			return
-		}
-		http.Error(w, "Failed to save integration: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     gmailOAuthStateCookieName,
-		Value:    "",
-		Expires:  time.Unix(0, 0),
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   isProductionEnv(),
-	})
-
-	if redirectOAuthResultIfPossible(w, r, redirectURL, "gmail", "connected") {
-		return
-	}
-
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(`<html><body style="font-family: sans-serif; text-align: center; margin-top: 50px;"><h2>Google Connected Successfully!</h2><p>You can close this window to return to Sentinent.</p><script>setTimeout(function() { window.close(); }, 1000);</script></body></html>`))
-}
-
-func GitHubAuthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := r.Context().Value(middleware.UserEmailKey).(string)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
-	if err != nil {
-		http.Error(w, err.Error(), statusCode)
-		return
-	}
-
-	redirectURL := sanitizeRedirectURL(r.URL.Query().Get("redirect_url"))
-
-	state, err := createGitHubOAuthState(email, workspaceID, redirectURL, time.Now())
-	if err != nil {
-		http.Error(w, "GitHub integration not configured", http.StatusServiceUnavailable)
-		return
-	}
-
-	authURL := githubAuthURLFunc(state)
-	if authURL == "" {
-		http.Error(w, "GitHub integration not configured", http.StatusServiceUnavailable)
-		return
-	}
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     githubOAuthStateCookieName,
-		Value:    state,
-		Expires:  time.Now().Add(githubOAuthStateTTL),
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   isProductionEnv(),
-	})
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"auth_url": authURL})
-}
-
-func GitHubCallbackHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	stateCookie, err := r.Cookie(githubOAuthStateCookieName)
-	if err != nil {
-		http.Error(w, "Invalid state", http.StatusBadRequest)
-		return
-	}
-
-	state := r.URL.Query().Get("state")
-	if subtle.ConstantTimeCompare([]byte(state), []byte(stateCookie.Value)) != 1 {
-		http.Error(w, "Invalid state", http.StatusBadRequest)
-		return
-	}
-
-	userEmail, workspaceID, redirectURL, err := validateGitHubOAuthState(state)
-	if err != nil {
-		http.Error(w, "Invalid state", http.StatusBadRequest)
-		return
-	}
-
-	code := r.URL.Query().Get("code")
-	if code == "" {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "github", "failed") {
-			return
-		}
-		http.Error(w, "Authorization code not provided", http.StatusBadRequest)
-		return
-	}
-
-	token, err := githubExchangeCodeFunc(code)
-	if err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "github", "failed") {
-			return
-		}
-		http.Error(w, "Failed to exchange code: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	var userID int
-	err = database.DB.QueryRow("SELECT id FROM users WHERE email = ?", userEmail).Scan(&userID)
-	if err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "github", "failed") {
-			return
-		}
-		http.Error(w, "User not found", http.StatusUnauthorized)
-		return
-	}
-
-	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
-	if err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "github", "failed") {
-			return
-		}
-		http.Error(w, "Failed to verify workspace access", http.StatusInternalServerError)
-		return
-	}
-	if role == "" {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "github", "failed") {
-			return
-		}
-		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
-		return
-	}
-
-	if err := githubSaveIntegrationFunc(userID, workspaceID, token); err != nil {
-		if redirectOAuthResultIfPossible(w, r, redirectURL, "github", "failed") {
-			return
-		}
-		http.Error(w, "Failed to save integration: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     githubOAuthStateCookieName,
-		Value:    "",
-		Expires:  time.Unix(0, 0),
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		Secure:   isProductionEnv(),
-	})
-
-	go func() {
-		if err := githubSyncSignalsFunc(userID, workspaceID); err != nil {
-			log.Printf("Sync error: %v", err)
-		}
-	}()
-
-	if redirectOAuthResultIfPossible(w, r, redirectURL, "github", "connected") {
-		return
-	}
-
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(`<html><body style="font-family: sans-serif; text-align: center; margin-top: 50px;"><h2>GitHub Connected Successfully!</h2><p>You can close this window to return to Sentinent.</p><script>setTimeout(function() { window.close(); }, 1000);</script></body></html>`))
-}
-
-func GitHubReposHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
-	if err != nil {
-		http.Error(w, err.Error(), statusCode)
-		return
-	}
-
-	if r.Method == http.MethodPatch {
-		updateGitHubRepoSelection(w, r, userID, workspaceID)
-		return
-	}
-
-	repos, err := services.ListAccessibleRepos(userID, workspaceID)
-	if err != nil {
-		http.Error(w, "Failed to fetch repos: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(repos)
-}
-
-func GitHubSyncHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
-	if err != nil {
-		http.Error(w, err.Error(), statusCode)
-		return
-	}
-
-	go func() {
-		if err := services.SyncGitHubSignals(userID, workspaceID); err != nil {
-			log.Printf("Sync error: %v", err)
-		}
-	}()
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "sync_started"})
-}
-
-func GitHubDisconnectHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
-	if err != nil {
-		http.Error(w, err.Error(), statusCode)
-		return
-	}
-
-	if err := services.DeleteGitHubIntegration(userID, workspaceID); err != nil {
-		http.Error(w, "Failed to disconnect: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "disconnected"})
-}
-
-func GitHubAddCommentHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
-	if err != nil {
-		http.Error(w, err.Error(), statusCode)
-		return
-	}
-
-	// URL format: /api/integrations/github/issues/{number}/comments
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 6 {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
-		return
-	}
-	number, err := strconv.Atoi(pathParts[5])
-	if err != nil || number <= 0 {
-		http.Error(w, "Invalid issue number", http.StatusBadRequest)
-		return
-	}
-
-	var req struct {
-		Repo string `json:"repo"`
-		Body string `json:"body"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-	req.Repo = strings.TrimSpace(req.Repo)
-	req.Body = strings.TrimSpace(req.Body)
-	if !isValidGitHubRepoFullName(req.Repo) {
-		http.Error(w, "Invalid GitHub repository", http.StatusBadRequest)
-		return
-	}
-	if req.Body == "" {
-		http.Error(w, "Comment body is required", http.StatusBadRequest)
-		return
-	}
-
-	if err := services.AddGitHubComment(userID, workspaceID, req.Repo, number, req.Body); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusCreated)
-}
-
-func GitHubUpdateStateHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPatch {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	workspaceID, statusCode, err := getAuthorizedWorkspaceID(r, userID)
-	if err != nil {
-		http.Error(w, err.Error(), statusCode)
-		return
-	}
-
-	// URL format: /api/integrations/github/issues/{number}/state
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 6 {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
-		return
-	}
-	number, err := strconv.Atoi(pathParts[5])
-	if err != nil || number <= 0 {
-		http.Error(w, "Invalid issue number", http.StatusBadRequest)
-		return
-	}
-
-	var req struct {
-		Repo  string `json:"repo"`
-		State string `json:"state"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-	req.Repo = strings.TrimSpace(req.Repo)
-	req.State = strings.TrimSpace(req.State)
-	if !isValidGitHubRepoFullName(req.Repo) {
-		http.Error(w, "Invalid GitHub repository", http.StatusBadRequest)
-		return
-	}
-	if req.State != "open" && req.State != "closed" {
-		http.Error(w, "Invalid GitHub issue state", http.StatusBadRequest)
-		return
-	}
-
-	if err := services.UpdateGitHubIssueState(userID, workspaceID, req.Repo, number, req.State); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := store.Integrations.Delete(integrationID); err != nil {
+		http.Error(w, "Failed to delete integration", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func isValidGitHubRepoFullName(repo string) bool {
-	owner, name, ok := strings.Cut(repo, "/")
-	return ok && strings.TrimSpace(owner) != "" && strings.TrimSpace(name) != "" &&
-		!strings.ContainsAny(owner, " \t\r\n") && !strings.ContainsAny(name, " \t\r\n/")
-}
-
-func GmailDisconnectHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	userID, err := getUserIDFromContext(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	if _, err := database.DB.Exec(
-		"DELETE FROM external_integrations WHERE user_id = ? AND provider = 'gmail' AND workspace_id IS NULL",
-		userID,
-	); err != nil {
-		http.Error(w, "Failed to disconnect Gmail", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "disconnected"})
-}
-
 func SignalsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1189,118 +558,13 @@ func SignalsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filter := &models.SignalFilter{SourceType: r.URL.Query().Get("source_type")}
-	if status := r.URL.Query().Get("status"); status != "" {
-		filter.Status = status
-	}
-
-	signals, err := services.GetUserSignals(userID, filter)
-	if err != nil {
-		http.Error(w, "Failed to fetch signals: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(signals)
-}
-
-func GitHubWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	eventType := r.Header.Get("X-GitHub-Event")
-	if eventType == "" {
-		http.Error(w, "Missing event type", http.StatusBadRequest)
-		return
-	}
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return
-	}
-
-	if err := validateGitHubWebhookSignature(r, body); err != nil {
-		if errors.Is(err, errGitHubWebhookSecretMissing) {
-			http.Error(w, "GitHub webhook secret not configured", http.StatusServiceUnavailable)
-			return
-		}
-		http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
-		return
-	}
-
-	var payload map[string]interface{}
-	if err := json.Unmarshal(body, &payload); err != nil {
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return
-	}
-
-	switch eventType {
-	case "issues":
-		handleIssuesWebhook(payload)
-	case "pull_request":
-		handlePullRequestWebhook(payload)
-	}
-
-	w.WriteHeader(http.StatusOK)
-}
-
-func validateGitHubWebhookSignature(r *http.Request, body []byte) error {
-	secret := strings.TrimSpace(os.Getenv(githubWebhookSecretEnv))
-	if secret == "" {
-		if isProductionEnv() {
-			return errGitHubWebhookSecretMissing
-		}
-		return nil
-	}
-
-	signature := strings.TrimSpace(r.Header.Get("X-Hub-Signature-256"))
-	if !strings.HasPrefix(signature, "sha256=") {
-		return errors.New("missing sha256 signature")
-	}
-
-	actual, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	filter, err := parseSignalFilter(r.URL.Query())
 	if err != nil {
-		return err
-	}
-
-	mac := hmac.New(sha256.New, []byte(secret))
-	if _, err := mac.Write(body); err != nil {
-		return err
-	}
-
-	if !hmac.Equal(actual, mac.Sum(nil)) {
-		return errors.New("signature mismatch")
-	}
-	return nil
-}
-
-func handleIssuesWebhook(payload map[string]interface{}) {
-	issue, ok := payload["issue"].(map[string]interface{})
-	if !ok {
-		return
-	}
-
-	action, _ := payload["action"].(string)
-	if action != "opened" && action != "closed" && action != "reopened" && action != "edited" {
-		return
-	}
-
-	_ = issue
-}
-
-func handlePullRequestWebhook(payload map[string]interface{}) {
-	pr, ok := payload["pull_request"].(map[string]interface{})
-	if !ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	action, _ := payload["action"].(string)
-	if action == "opened" || action == "closed" || action == "reopened" || action == "edited" {
-		_ = pr
-	}
+	listSignals(w, userID, filter)
 }
 
 func IntegrationStatusHandler(w http.ResponseWriter, r *http.Request) {
@@ -1325,11 +589,14 @@ func IntegrationStatusHandler(w http.ResponseWriter, r *http.Request) {
 		workspaceID = &value
 	}
 
-	statuses := []models.IntegrationStatus{
-		buildIntegrationStatus(userID, "slack", isSlackConfigured(), workspaceID),
-		buildIntegrationStatus(userID, "github", services.IsGitHubConfigured(), workspaceID),
-		buildIntegrationStatus(userID, "gmail", isGmailConfigured(), nil),
-		buildIntegrationStatus(userID, "jira", services.IsJiraConfigured(), workspaceID),
+	providers := integrationProviders()
+	statuses := make([]models.IntegrationStatus, 0, len(providers))
+	for _, provider := range providers {
+		scope := workspaceID
+		if provider.Scope() == services.ScopeUser {
+			scope = nil
+		}
+		statuses = append(statuses, buildIntegrationStatus(userID, provider.Name(), provider.Configured(), scope))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1340,150 +607,24 @@ func buildIntegrationStatus(userID int, provider string, configured bool, worksp
 	status := models.IntegrationStatus{
 		Provider:   provider,
 		Configured: configured,
+		Status:     models.IntegrationDisconnected,
 	}
 
-	var (
-		query string
-		args  []interface{}
-	)
-
-	switch {
-	case workspaceID != nil:
-		query = `SELECT updated_at FROM external_integrations
-			WHERE user_id = ? AND provider = ? AND workspace_id = ?
-			ORDER BY updated_at DESC LIMIT 1`
-		args = []interface{}{userID, provider, *workspaceID}
-	default:
-		query = `SELECT updated_at FROM external_integrations
-			WHERE user_id = ? AND provider = ?
-			ORDER BY updated_at DESC LIMIT 1`
-		args = []interface{}{userID, provider}
-	}
-
-	if err := database.DB.QueryRow(query, args...).Scan(&status.UpdatedAt); err == nil {
-		status.Connected = true
-	}
-
-	return status
-}
-
-func updateSlackChannelSelection(w http.ResponseWriter, r *http.Request, userID int) {
-	workspaceIDStr := r.URL.Query().Get("workspace_id")
-	if workspaceIDStr == "" {
-		http.Error(w, "workspace_id is required", http.StatusBadRequest)
-		return
-	}
-
-	workspaceID, err := strconv.Atoi(workspaceIDStr)
+	integration, err := repository.Default().Integrations.Newest(userID, provider, workspaceID)
 	if err != nil {
-		http.Error(w, "Invalid workspace_id", http.StatusBadRequest)
-		return
-	}
-
-	var req struct {
-		ChannelIDs []string `json:"channel_ids"`
+		return status
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if err := updateIntegrationMetadata(
-		userID,
-		"slack",
-		&workspaceID,
-		func(metadata map[string]interface{}) {
-			metadata["selected_channels"] = req.ChannelIDs
-		},
-	); err != nil {
-		writeIntegrationUpdateError(w, err)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func updateGitHubRepoSelection(w http.ResponseWriter, r *http.Request, userID, workspaceID int) {
-	var req struct {
-		RepoIDs []int `json:"repo_ids"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if err := updateIntegrationMetadata(
-		userID,
-		"github",
-		&workspaceID,
-		func(metadata map[string]interface{}) {
-			metadata["selected_repo_ids"] = req.RepoIDs
-		},
-	); err != nil {
-		writeIntegrationUpdateError(w, err)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func updateIntegrationMetadata(
-	userID int,
-	provider string,
-	workspaceID *int,
-	mutate func(metadata map[string]interface{}),
-) error {
-	var (
-		query    string
-		args     []interface{}
-		rowID    int
-		metadata sql.NullString
-	)
-
-	switch {
-	case workspaceID != nil:
-		query = `SELECT id, metadata FROM external_integrations
-			WHERE user_id = ? AND provider = ? AND workspace_id = ?`
-		args = []interface{}{userID, provider, *workspaceID}
-	default:
-		query = `SELECT id, metadata FROM external_integrations
-			WHERE user_id = ? AND provider = ? AND workspace_id IS NULL`
-		args = []interface{}{userID, provider}
-	}
-
-	if err := database.DB.QueryRow(query, args...).Scan(&rowID, &metadata); err != nil {
-		return err
-	}
-
-	payload := map[string]interface{}{}
-	if metadata.Valid && metadata.String != "" {
-		if err := json.Unmarshal([]byte(metadata.String), &payload); err != nil {
-			return err
-		}
-	}
-	mutate(payload)
+	status.Connected = true
+	status.UpdatedAt = integration.UpdatedAt
 
-	metadataJSON, err := json.Marshal(payload)
+	health, err := services.IntegrationHealth(integration)
 	if err != nil {
-		return err
-	}
-
-	_, err = database.DB.Exec(
-		`UPDATE external_integrations
-		 SET metadata = ?, updated_at = CURRENT_TIMESTAMP
-		 WHERE id = ?`,
-		string(metadataJSON), rowID,
-	)
-	return err
-}
-
-func writeIntegrationUpdateError(w http.ResponseWriter, err error) {
-	switch err {
-	case sql.ErrNoRows:
-		http.Error(w, "Integration not found", http.StatusNotFound)
-	default:
-		http.Error(w, "Failed to update integration", http.StatusInternalServerError)
+		log.Printf("Failed to compute %s integration health: %v", provider, err)
+		status.Status = models.IntegrationHealthy
+		return status
 	}
+	status.Status = health.Status
+	return status
 }
 
 func getUserIDFromContext(r *http.Request) (int, error) {
@@ -1495,13 +636,7 @@ func getUserIDFromContext(r *http.Request) (int, error) {
 	if !ok {
 		return 0, http.ErrNoCookie
 	}
-	var userID int
-	err := database.DB.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&userID)
-	if err != nil {
-		return 0, err
-	}
-
-	return userID, nil
+	return repository.Default().Users.IDByEmail(email)
 }
 
 func getAuthorizedWorkspaceID(r *http.Request, userID int) (int, int, error) {
@@ -1523,134 +658,43 @@ func getAuthorizedWorkspaceID(r *http.Request, userID int) (int, int, error) {
 		return 0, http.StatusForbidden, fmt.Errorf("forbidden: not a member of this workspace")
 	}
 
-	return workspaceID, 0, nil
-}
-
-func getSlackRedirectURI(r *http.Request) string {
-	if uri := os.Getenv("SLACK_REDIRECT_URI"); uri != "" {
-		return uri
+	allowed, err := middleware.SatisfiesWorkspaceMFA(userID, workspaceID)
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("failed to verify workspace access")
 	}
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
+	if !allowed {
+		return 0, http.StatusForbidden, fmt.Errorf("forbidden: multi-factor authentication required")
 	}
-	return scheme + "://" + r.Host + "/api/integrations/slack/callback"
-}
 
-func isSlackConfigured() bool {
-	return slackClientID != "" && slackClientSecret != "" && tokenEncryptor != nil
+	return workspaceID, 0, nil
 }
 
-func getGmailRedirectURI(r *http.Request) string {
-	if uri := strings.TrimSpace(os.Getenv("GOOGLE_REDIRECT_URI")); uri != "" {
-		return uri
-	}
+// integrationCallbackURI is the callback URL on this API for the request's
+// host; providers may override it with configured redirect URIs.
+func integrationCallbackURI(r *http.Request, provider services.Provider) string {
 	scheme := "http"
 	if r.TLS != nil {
 		scheme = "https"
 	}
-	return scheme + "://" + r.Host + "/api/integrations/gmail/callback"
-}
-
-func isGmailConfigured() bool {
-	return gmailClientID != "" && gmailClientSecret != "" && tokenEncryptor != nil
-}
-
-func createGitHubOAuthState(email string, workspaceID int, redirectURL string, now time.Time) (string, error) {
-	if len(utils.JwtKey) == 0 {
-		return "", http.ErrNoCookie
-	}
-
-	claims := &githubOAuthStateClaims{
-		Email:       email,
-		WorkspaceID: workspaceID,
-		RedirectURL: sanitizeRedirectURL(redirectURL),
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   email,
-			ExpiresAt: jwt.NewNumericDate(now.Add(githubOAuthStateTTL)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(utils.JwtKey)
-}
-
-func validateGitHubOAuthState(state string) (string, int, string, error) {
-	if state == "" || len(utils.JwtKey) == 0 {
-		return "", 0, "", http.ErrNoCookie
-	}
-
-	claims := &githubOAuthStateClaims{}
-	token, err := jwt.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, http.ErrNoCookie
-		}
-		return utils.JwtKey, nil
-	})
-	if err != nil {
-		return "", 0, "", err
-	}
-	if !token.Valid || claims.Subject == "" {
-		return "", 0, "", http.ErrNoCookie
-	}
-
-	return claims.Subject, claims.WorkspaceID, sanitizeRedirectURL(claims.RedirectURL), nil
-}
-
-func createGmailOAuthState(email, redirectURL string, now time.Time) (string, error) {
-	if len(utils.JwtKey) == 0 {
-		return "", http.ErrNoCookie
-	}
-
-	claims := &gmailOAuthStateClaims{
-		Email:       email,
-		RedirectURL: sanitizeRedirectURL(redirectURL),
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   email,
-			ExpiresAt: jwt.NewNumericDate(now.Add(gmailOAuthStateTTL)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(utils.JwtKey)
+	return scheme + "://" + r.Host + "/api/integrations/" + provider.Name() + "/callback"
 }
 
-func validateGmailOAuthState(state string) (string, string, error) {
-	if state == "" || len(utils.JwtKey) == 0 {
-		return "", "", http.ErrNoCookie
-	}
-
-	claims := &gmailOAuthStateClaims{}
-	token, err := jwt.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, http.ErrNoCookie
-		}
-		return utils.JwtKey, nil
-	})
-	if err != nil {
-		return "", "", err
-	}
-	if !token.Valid || claims.Subject == "" {
-		return "", "", http.ErrNoCookie
-	}
-
-	return claims.Subject, sanitizeRedirectURL(claims.RedirectURL), nil
+func integrationOAuthStateCookieName(provider services.Provider) string {
+	return provider.Name() + "_oauth_state"
 }
 
-func createSlackOAuthState(userID, workspaceID int, now time.Time) (string, error) {
+func createIntegrationOAuthState(provider string, userID, workspaceID int, redirectURL string, now time.Time) (string, error) {
 	if len(utils.JwtKey) == 0 {
 		return "", http.ErrNoCookie
 	}
 
-	claims := &slackOAuthStateClaims{
+	claims := &integrationOAuthStateClaims{
+		Provider:    provider,
 		UserID:      userID,
 		WorkspaceID: workspaceID,
+		RedirectURL: sanitizeRedirectURL(redirectURL),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(slackOAuthStateTTL)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(integrationOAuthStateTTL)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 		},
@@ -1660,12 +704,15 @@ func createSlackOAuthState(userID, workspaceID int, now time.Time) (string, erro
 	return token.SignedString(utils.JwtKey)
 }
 
-func validateSlackOAuthState(state string) (int, int, error) {
+// validateIntegrationOAuthState parses a state issued for the provider. A state
+// issued for another provider is rejected so it cannot be replayed against a
+// different callback.
+func validateIntegrationOAuthState(provider, state string) (*integrationOAuthStateClaims, error) {
 	if state == "" || len(utils.JwtKey) == 0 {
-		return 0, 0, http.ErrNoCookie
+		return nil, http.ErrNoCookie
 	}
 
-	claims := &slackOAuthStateClaims{}
+	claims := &integrationOAuthStateClaims{}
 	token, err := jwt.ParseWithClaims(state, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, http.ErrNoCookie
@@ -1673,138 +720,14 @@ func validateSlackOAuthState(state string) (int, int, error) {
 		return utils.JwtKey, nil
 	})
 	if err != nil {
-		return 0, 0, err
-	}
-	if !token.Valid {
-		return 0, 0, http.ErrNoCookie
-	}
-
-	return claims.UserID, claims.WorkspaceID, nil
-}
-
-func buildGmailAuthURL(state, redirectURI string) string {
-	config := &oauth2.Config{
-		ClientID:     gmailClientID,
-		ClientSecret: gmailClientSecret,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://accounts.google.com/o/oauth2/auth",
-			TokenURL: "https://oauth2.googleapis.com/token",
-		},
-		RedirectURL: redirectURI,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/gmail.readonly",
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-	}
-	return config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
-}
-
-func exchangeGmailCode(code, redirectURI string) (*oauth2.Token, error) {
-	config := &oauth2.Config{
-		ClientID:     gmailClientID,
-		ClientSecret: gmailClientSecret,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://accounts.google.com/o/oauth2/auth",
-			TokenURL: "https://oauth2.googleapis.com/token",
-		},
-		RedirectURL: redirectURI,
-		Scopes: []string{
-			"https://www.googleapis.com/auth/gmail.readonly",
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-	}
-	return config.Exchange(context.Background(), code)
-}
-
-func fetchGmailProfile(token *oauth2.Token) (*gmailProfile, error) {
-	if token == nil || token.AccessToken == "" {
-		return nil, fmt.Errorf("missing access token")
-	}
-
-	client := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(token))
-	req, err := http.NewRequest(http.MethodGet, "https://www.googleapis.com/oauth2/v2/userinfo", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("gmail userinfo request failed with status %d", resp.StatusCode)
-	}
-
-	var profile gmailProfile
-	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
 		return nil, err
 	}
-	if strings.TrimSpace(profile.Email) == "" {
-		return nil, fmt.Errorf("gmail profile did not include an email address")
-	}
-	return &profile, nil
-}
-
-func saveGmailIntegration(userID int, token *oauth2.Token, profile *gmailProfile) error {
-	if tokenEncryptor == nil {
-		return fmt.Errorf("token encryption is not configured")
-	}
-
-	encryptedAccessToken, err := tokenEncryptor.Encrypt(token.AccessToken)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt access token: %w", err)
-	}
-
-	var encryptedRefreshToken string
-	if token.RefreshToken != "" {
-		encryptedRefreshToken, err = tokenEncryptor.Encrypt(token.RefreshToken)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt refresh token: %w", err)
-		}
-	}
-
-	metadata := map[string]interface{}{
-		"email":          profile.Email,
-		"name":           profile.Name,
-		"verified_email": profile.VerifiedEmail,
-	}
-	if scope, ok := token.Extra("scope").(string); ok && scope != "" {
-		metadata["scope"] = scope
-	}
-	metadataJSON, err := json.Marshal(metadata)
-	if err != nil {
-		return err
-	}
-
-	result, err := database.DB.Exec(
-		`UPDATE external_integrations
-		 SET access_token = ?, refresh_token = ?, expires_at = ?, metadata = ?, updated_at = CURRENT_TIMESTAMP
-		 WHERE user_id = ? AND provider = 'gmail' AND workspace_id IS NULL`,
-		encryptedAccessToken, encryptedRefreshToken, token.Expiry, string(metadataJSON), userID,
-	)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected > 0 {
-		return nil
+	if !token.Valid || claims.Provider != provider || claims.UserID <= 0 {
+		return nil, http.ErrNoCookie
 	}
 
-	_, err = database.DB.Exec(
-		`INSERT INTO external_integrations
-		 (user_id, workspace_id, provider, access_token, refresh_token, expires_at, metadata, updated_at)
-		 VALUES (?, NULL, 'gmail', ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
-		userID, encryptedAccessToken, encryptedRefreshToken, token.Expiry, string(metadataJSON),
-	)
-	return err
+	claims.RedirectURL = sanitizeRedirectURL(claims.RedirectURL)
+	return claims, nil
 }
 
 func sanitizeRedirectURL(raw string) string {
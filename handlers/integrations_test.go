@@ -8,10 +8,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
 	"sentinent-backend/services"
+	"sentinent-backend/services/providers/jira"
 	"sentinent-backend/utils"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -19,631 +22,595 @@ import (
 	"golang.org/x/oauth2"
 )
 
-func TestSlackAuthHandlerSetsSignedState(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+// stubIntegrationProvider is a workspace- or user-scoped provider whose OAuth
+// exchange is recorded instead of calling out.
+type stubIntegrationProvider struct {
+	name         string
+	scope        services.ProviderScope
+	unconfigured bool
+	redirectURIs []string
+}
 
-	if _, err := database.DB.Exec(
-		"INSERT INTO users (email, password) VALUES (?, ?)",
-		"octo@example.com", "hashed-password",
-	); err != nil {
-		t.Fatalf("failed to seed user: %v", err)
-	}
+func (p *stubIntegrationProvider) Name() string                  { return p.name }
+func (p *stubIntegrationProvider) DisplayName() string           { return "Stub" }
+func (p *stubIntegrationProvider) Scope() services.ProviderScope { return p.scope }
+func (p *stubIntegrationProvider) Init() error                   { return nil }
+func (p *stubIntegrationProvider) Configured() bool              { return !p.unconfigured }
 
-	originalSlackClientID := slackClientID
-	originalSlackClientSecret := slackClientSecret
-	originalTokenEncryptor := tokenEncryptor
-	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+func (p *stubIntegrationProvider) AuthURL(state, redirectURI string) string {
+	p.redirectURIs = append(p.redirectURIs, redirectURI)
+	return "https://provider.example.com/authorize?state=" + url.QueryEscape(state)
+}
 
-	encryptor, err := utils.NewTokenEncryptor()
-	if err != nil {
-		t.Fatalf("failed to create encryptor: %v", err)
+func (p *stubIntegrationProvider) Exchange(ctx context.Context, code, redirectURI string) (*services.Grant, error) {
+	p.redirectURIs = append(p.redirectURIs, redirectURI)
+	return &services.Grant{
+		Token:    &oauth2.Token{AccessToken: "access-" + code, RefreshToken: "refresh-" + code},
+		Metadata: map[string]interface{}{"account": "stub"},
+	}, nil
+}
+
+func (p *stubIntegrationProvider) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	return token, nil
+}
+
+func (p *stubIntegrationProvider) Sync(userID, workspaceID int) (services.SyncStats, error) {
+	return services.SyncStats{}, nil
+}
+
+// remoteStubIntegrationProvider accepts callbacks without the state cookie.
+type remoteStubIntegrationProvider struct {
+	*stubIntegrationProvider
+}
+
+func (remoteStubIntegrationProvider) RemoteCallback() bool { return true }
+
+func useStubIntegrationProvider(t *testing.T, provider services.Provider) {
+	t.Helper()
+
+	original := lookupIntegrationProvider
+	lookupIntegrationProvider = func(name string) (services.Provider, bool) {
+		if name == provider.Name() {
+			return provider, true
+		}
+		return original(name)
 	}
+	t.Cleanup(func() { lookupIntegrationProvider = original })
+}
 
-	slackClientID = "client-id"
-	slackClientSecret = "client-secret"
-	tokenEncryptor = encryptor
-	t.Cleanup(func() {
-		slackClientID = originalSlackClientID
-		slackClientSecret = originalSlackClientSecret
-		tokenEncryptor = originalTokenEncryptor
-	})
+func TestIntegrationAuthSetsSignedStateCookie(t *testing.T) {
+	setupIntegrationsTestDB(t)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/integrations/slack/auth?workspace_id=7", nil)
-	req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmailKey, "octo@example.com"))
+	provider := &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace}
+	useStubIntegrationProvider(t, provider)
+
+	req := integrationRequestWithUser(
+		http.MethodGet,
+		"/api/integrations/stub/auth?workspace_id=9&redirect_url="+url.QueryEscape("http://localhost:4200/workspaces/9/settings/integrations"),
+		"reader@example.com",
+	)
 	rr := httptest.NewRecorder()
 
-	SlackAuth(rr, req)
+	IntegrationsRouter(rr, req)
 
 	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rr.Code)
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
 	}
 
 	var payload map[string]string
 	if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
 		t.Fatalf("expected JSON response: %v", err)
 	}
-
 	authURL, err := url.Parse(payload["auth_url"])
 	if err != nil {
 		t.Fatalf("failed to parse auth URL: %v", err)
 	}
-	if !strings.Contains(authURL.Query().Get("scope"), "channels:join") {
-		t.Fatalf("expected Slack auth scope to include channels:join, got %q", authURL.Query().Get("scope"))
-	}
+	state := authURL.Query().Get("state")
 
-	userID, workspaceID, err := validateSlackOAuthState(authURL.Query().Get("state"))
+	claims, err := validateIntegrationOAuthState("stub", state)
 	if err != nil {
 		t.Fatalf("expected signed state to validate: %v", err)
 	}
-	if userID != 1 || workspaceID != 7 {
-		t.Fatalf("expected user 1 workspace 7, got user %d workspace %d", userID, workspaceID)
+	if claims.UserID != 1 || claims.WorkspaceID != 9 {
+		t.Fatalf("expected user 1 workspace 9, got user %d workspace %d", claims.UserID, claims.WorkspaceID)
 	}
-}
-
-func TestValidateSlackOAuthStateRejectsExpiredState(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
-
-	state, err := createSlackOAuthState(1, 7, time.Now().Add(-slackOAuthStateTTL-time.Minute))
-	if err != nil {
-		t.Fatalf("failed to create expired state: %v", err)
+	if claims.RedirectURL != "http://localhost:4200/workspaces/9/settings/integrations" {
+		t.Fatalf("unexpected redirect URL in state: %q", claims.RedirectURL)
 	}
-
-	if _, _, err := validateSlackOAuthState(state); err == nil {
-		t.Fatal("expected expired state to be rejected")
+	if len(provider.redirectURIs) != 1 || provider.redirectURIs[0] != "http://example.com/api/integrations/stub/callback" {
+		t.Fatalf("unexpected callback URI passed to provider: %v", provider.redirectURIs)
 	}
-}
-
-func TestSlackCallbackHandlerAcceptsValidStateWithoutCookie(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
 
-	if _, err := database.DB.Exec(`
-		CREATE TABLE external_integrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			provider TEXT NOT NULL,
-			access_token TEXT NOT NULL,
-			refresh_token TEXT,
-			expires_at DATETIME,
-			metadata TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`); err != nil {
-		t.Fatalf("failed to create external_integrations table: %v", err)
+	var stateCookie *http.Cookie
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == "stub_oauth_state" {
+			stateCookie = cookie
+			break
+		}
 	}
-
-	if _, err := database.DB.Exec(
-		"INSERT INTO users (email, password) VALUES (?, ?)",
-		"octo@example.com", "hashed-password",
-	); err != nil {
-		t.Fatalf("failed to seed user: %v", err)
+	if stateCookie == nil {
+		t.Fatal("expected OAuth state cookie to be set")
 	}
-
-	originalSlackClientID := slackClientID
-	originalSlackClientSecret := slackClientSecret
-	originalTokenEncryptor := tokenEncryptor
-	originalSlackExchangeCodeFunc := slackExchangeCodeFunc
-	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
-	t.Setenv("SLACK_REDIRECT_URI", "https://example.ngrok.app/api/integrations/slack/callback")
-
-	encryptor, err := utils.NewTokenEncryptor()
-	if err != nil {
-		t.Fatalf("failed to create encryptor: %v", err)
+	if stateCookie.Value != state {
+		t.Fatal("expected cookie state to match auth URL state")
 	}
-
-	slackClientID = "client-id"
-	slackClientSecret = "client-secret"
-	tokenEncryptor = encryptor
-	slackExchangeCodeFunc = func(clientID, clientSecret, code, redirectURI string) (*services.SlackOAuthResponse, error) {
-		if clientID != "client-id" || clientSecret != "client-secret" {
-			t.Fatalf("unexpected Slack client credentials: %q %q", clientID, clientSecret)
-		}
-		if code != "test-code" {
-			t.Fatalf("expected code test-code, got %q", code)
-		}
-		if redirectURI != "https://example.ngrok.app/api/integrations/slack/callback" {
-			t.Fatalf("unexpected redirect URI: %q", redirectURI)
-		}
-
-		resp := &services.SlackOAuthResponse{
-			OK:          true,
-			AccessToken: "slack-test-token",
-			Scope:       "channels:read,groups:read",
-			BotUserID:   "B123",
-			AppID:       "A123",
-		}
-		resp.Team.ID = "T123"
-		resp.Team.Name = "Workspace"
-		return resp, nil
-	}
-	t.Cleanup(func() {
-		slackClientID = originalSlackClientID
-		slackClientSecret = originalSlackClientSecret
-		tokenEncryptor = originalTokenEncryptor
-		slackExchangeCodeFunc = originalSlackExchangeCodeFunc
-	})
-
-	state, err := createSlackOAuthState(1, 7, time.Now())
-	if err != nil {
-		t.Fatalf("failed to create state: %v", err)
+	if !stateCookie.HttpOnly {
+		t.Fatal("expected OAuth state cookie to be HttpOnly")
 	}
+	if stateCookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected SameSite=Lax, got %v", stateCookie.SameSite)
+	}
+}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/integrations/slack/callback?code=test-code&state="+url.QueryEscape(state), nil)
-	rr := httptest.NewRecorder()
+func TestIntegrationAuthChecksWorkspaceForWorkspaceScopedProviders(t *testing.T) {
+	setupIntegrationsTestDB(t)
 
-	SlackCallback(rr, req)
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "mailbox", scope: services.ScopeUser})
 
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rr.Code)
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "missing workspace", path: "/api/integrations/stub/auth", status: http.StatusBadRequest},
+		{name: "foreign workspace", path: "/api/integrations/stub/auth?workspace_id=11", status: http.StatusForbidden},
+		{name: "user scoped", path: "/api/integrations/mailbox/auth", status: http.StatusOK},
 	}
 
-	var count int
-	if err := database.DB.QueryRow(
-		"SELECT count(*) FROM external_integrations WHERE user_id = ? AND workspace_id = ? AND provider = 'slack'",
-		1, 7,
-	).Scan(&count); err != nil {
-		t.Fatalf("failed to query saved integration: %v", err)
-	}
-	if count != 1 {
-		t.Fatalf("expected 1 saved slack integration, got %d", count)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			IntegrationsRouter(rr, integrationRequestWithUser(http.MethodGet, tt.path, "reader@example.com"))
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
 	}
 }
 
-func TestGitHubAuthHandlerSetsSignedStateCookie(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+func TestIntegrationAuthRejectsUnconfiguredProvider(t *testing.T) {
+	setupIntegrationsTestDB(t)
 
-	if _, err := database.DB.Exec(
-		"INSERT INTO users (id, email, password) VALUES (?, ?, ?)",
-		1, "octo@example.com", "hashed-password",
-	); err != nil {
-		t.Fatalf("failed to seed user: %v", err)
-	}
-	if _, err := database.DB.Exec(
-		"INSERT INTO workspaces (id, name, owner_id) VALUES (?, ?, ?)",
-		7, "GitHub", 1,
-	); err != nil {
-		t.Fatalf("failed to seed workspace: %v", err)
-	}
-	if _, err := database.DB.Exec(
-		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
-		7, 1, models.RoleOwner,
-	); err != nil {
-		t.Fatalf("failed to seed workspace membership: %v", err)
-	}
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace, unconfigured: true})
 
-	originalGitHubAuthURLFunc := githubAuthURLFunc
-	t.Cleanup(func() {
-		githubAuthURLFunc = originalGitHubAuthURLFunc
-	})
+	rr := httptest.NewRecorder()
+	IntegrationsRouter(rr, integrationRequestWithUser(http.MethodGet, "/api/integrations/stub/auth?workspace_id=9", "reader@example.com"))
 
-	var capturedState string
-	githubAuthURLFunc = func(state string) string {
-		capturedState = state
-		return "https://github.com/login/oauth/authorize?state=" + url.QueryEscape(state)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", rr.Code)
 	}
+}
 
-	req := httptest.NewRequest(
-		http.MethodGet,
-		"/api/integrations/github/auth?workspace_id=7&redirect_url="+url.QueryEscape("http://localhost:4200/workspaces/7/settings/integrations"),
-		nil,
-	)
-	req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmailKey, "octo@example.com"))
-	rr := httptest.NewRecorder()
+func TestIntegrationsRouterRejectsUnknownProvider(t *testing.T) {
+	setupIntegrationsTestDB(t)
 
-	GitHubAuthHandler(rr, req)
+	rr := httptest.NewRecorder()
+	IntegrationsRouter(rr, integrationRequestWithUser(http.MethodGet, "/api/integrations/unknown/auth", "reader@example.com"))
 
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rr.Code)
-	}
-	if capturedState == "" {
-		t.Fatal("expected GitHub auth URL to receive a state value")
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rr.Code)
 	}
+}
 
-	email, workspaceID, redirectURL, err := validateGitHubOAuthState(capturedState)
+func TestValidateIntegrationOAuthStateRejectsExpiredState(t *testing.T) {
+	setupTestDB(t)
+
+	state, err := createIntegrationOAuthState("stub", 1, 7, "", time.Now().Add(-integrationOAuthStateTTL-time.Minute))
 	if err != nil {
-		t.Fatalf("expected signed state to validate: %v", err)
-	}
-	if email != "octo@example.com" {
-		t.Fatalf("expected state to be bound to octo@example.com, got %q", email)
-	}
-	if workspaceID != 7 {
-		t.Fatalf("expected workspace id 7 in state, got %d", workspaceID)
-	}
-	if redirectURL != "http://localhost:4200/workspaces/7/settings/integrations" {
-		t.Fatalf("unexpected redirect URL in state: %q", redirectURL)
+		t.Fatalf("failed to create expired state: %v", err)
 	}
 
-	var stateCookie *http.Cookie
-	for _, cookie := range rr.Result().Cookies() {
-		if cookie.Name == githubOAuthStateCookieName {
-			stateCookie = cookie
-			break
-		}
-	}
-	if stateCookie == nil {
-		t.Fatal("expected GitHub OAuth state cookie to be set")
-	}
-	if stateCookie.Value != capturedState {
-		t.Fatal("expected cookie state to match auth URL state")
-	}
-	if !stateCookie.HttpOnly {
-		t.Fatal("expected GitHub OAuth state cookie to be HttpOnly")
-	}
-	if stateCookie.SameSite != http.SameSiteLaxMode {
-		t.Fatalf("expected SameSite=Lax, got %v", stateCookie.SameSite)
+	if _, err := validateIntegrationOAuthState("stub", state); err == nil {
+		t.Fatal("expected expired state to be rejected")
 	}
+}
 
-	var payload map[string]string
-	if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
-		t.Fatalf("expected JSON response: %v", err)
+func TestValidateIntegrationOAuthStateRejectsOtherProvider(t *testing.T) {
+	setupTestDB(t)
+
+	state, err := createIntegrationOAuthState("github", 1, 7, "", time.Now())
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
 	}
-	if payload["auth_url"] == "" {
-		t.Fatal("expected auth_url in response")
+
+	if _, err := validateIntegrationOAuthState("jira", state); err == nil {
+		t.Fatal("expected state issued for another provider to be rejected")
 	}
 }
 
-func TestGitHubCallbackHandlerRejectsMissingStateCookie(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+func TestIntegrationCallbackRejectsMissingStateCookie(t *testing.T) {
+	setupIntegrationsTestDB(t)
+
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
+
+	state, err := createIntegrationOAuthState("stub", 1, 9, "", time.Now())
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/integrations/github/callback?code=test-code&state=test-state", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/stub/callback?code=test-code&state="+url.QueryEscape(state), nil)
 	rr := httptest.NewRecorder()
 
-	GitHubCallbackHandler(rr, req)
+	IntegrationCallback(rr, req)
 
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected status 400, got %d", rr.Code)
 	}
 }
 
-func TestGitHubCallbackHandlerRejectsTamperedState(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+func TestIntegrationCallbackRejectsTamperedState(t *testing.T) {
+	setupIntegrationsTestDB(t)
+
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
 
-	state, err := createGitHubOAuthState("octo@example.com", 7, "", time.Now())
+	state, err := createIntegrationOAuthState("stub", 1, 9, "", time.Now())
 	if err != nil {
 		t.Fatalf("failed to create state: %v", err)
 	}
+	tampered := state + "tampered"
 
-	req := httptest.NewRequest(http.MethodGet, "/api/integrations/github/callback?code=test-code&state="+url.QueryEscape(state+"tampered"), nil)
-	req.AddCookie(&http.Cookie{Name: githubOAuthStateCookieName, Value: state})
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/stub/callback?code=test-code&state="+url.QueryEscape(tampered), nil)
+	req.AddCookie(&http.Cookie{Name: "stub_oauth_state", Value: tampered})
 	rr := httptest.NewRecorder()
 
-	GitHubCallbackHandler(rr, req)
+	IntegrationCallback(rr, req)
 
 	if rr.Code != http.StatusBadRequest {
 		t.Fatalf("expected status 400, got %d", rr.Code)
 	}
 }
 
-func TestValidateGitHubOAuthStateRejectsExpiredState(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+func TestIntegrationCallbackSavesEncryptedGrantAndQueuesSync(t *testing.T) {
+	setupIntegrationsTestDB(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
 
-	state, err := createGitHubOAuthState("octo@example.com", 7, "", time.Now().Add(-githubOAuthStateTTL-time.Minute))
+	state, err := createIntegrationOAuthState("stub", 1, 9, "", time.Now())
 	if err != nil {
-		t.Fatalf("failed to create expired state: %v", err)
+		t.Fatalf("failed to create state: %v", err)
 	}
 
-	if _, _, _, err := validateGitHubOAuthState(state); err == nil {
-		t.Fatal("expected expired state to be rejected")
-	}
-}
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/stub/callback?code=test-code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: "stub_oauth_state", Value: state})
+	rr := httptest.NewRecorder()
 
-func TestGitHubCallbackHandlerAcceptsValidState(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
+	IntegrationCallback(rr, req)
 
-	if _, err := database.DB.Exec(
-		"INSERT INTO users (id, email, password) VALUES (?, ?, ?)",
-		1, "octo@example.com", "hashed-password",
-	); err != nil {
-		t.Fatalf("failed to seed user: %v", err)
-	}
-	if _, err := database.DB.Exec(
-		"INSERT INTO workspaces (id, name, owner_id) VALUES (?, ?, ?)",
-		7, "GitHub", 1,
-	); err != nil {
-		t.Fatalf("failed to seed workspace: %v", err)
-	}
-	if _, err := database.DB.Exec(
-		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
-		7, 1, models.RoleOwner,
-	); err != nil {
-		t.Fatalf("failed to seed workspace membership: %v", err)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
 	}
 
-	originalGitHubExchangeCodeFunc := githubExchangeCodeFunc
-	originalGitHubSaveIntegrationFunc := githubSaveIntegrationFunc
-	originalGitHubSyncSignalsFunc := githubSyncSignalsFunc
-	t.Cleanup(func() {
-		githubExchangeCodeFunc = originalGitHubExchangeCodeFunc
-		githubSaveIntegrationFunc = originalGitHubSaveIntegrationFunc
-		githubSyncSignalsFunc = originalGitHubSyncSignalsFunc
-	})
-
-	githubExchangeCodeFunc = func(code string) (*oauth2.Token, error) {
-		if code != "test-code" {
-			t.Fatalf("expected code test-code, got %q", code)
-		}
-		return &oauth2.Token{AccessToken: "test-token"}, nil
+	var accessToken, refreshToken, metadata string
+	if err := database.DB.QueryRow(
+		`SELECT access_token, refresh_token, metadata FROM external_integrations
+		 WHERE user_id = ? AND provider = 'stub' AND workspace_id = ?`,
+		1, 9,
+	).Scan(&accessToken, &refreshToken, &metadata); err != nil {
+		t.Fatalf("failed to query saved integration: %v", err)
 	}
 
-	var savedUserID int
-	var savedWorkspaceID int
-	githubSaveIntegrationFunc = func(userID, workspaceID int, token *oauth2.Token) error {
-		savedUserID = userID
-		savedWorkspaceID = workspaceID
-		if token.AccessToken != "test-token" {
-			t.Fatalf("expected access token test-token, got %q", token.AccessToken)
-		}
-		return nil
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		t.Fatalf("failed to create encryptor: %v", err)
+	}
+	if decrypted, err := encryptor.Decrypt(accessToken); err != nil || decrypted != "access-test-code" {
+		t.Fatalf("unexpected decrypted access token %q: %v", decrypted, err)
+	}
+	if decrypted, err := encryptor.Decrypt(refreshToken); err != nil || decrypted != "refresh-test-code" {
+		t.Fatalf("unexpected decrypted refresh token %q: %v", decrypted, err)
 	}
 
-	type syncInvocation struct {
-		userID      int
-		workspaceID int
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(metadata), &payload); err != nil {
+		t.Fatalf("failed to decode integration metadata: %v", err)
 	}
-	syncCalls := make(chan syncInvocation, 1)
-	githubSyncSignalsFunc = func(userID, workspaceID int) error {
-		syncCalls <- syncInvocation{userID: userID, workspaceID: workspaceID}
-		return nil
+	if payload["account"] != "stub" {
+		t.Fatalf("expected grant metadata to be stored, got %v", payload)
 	}
 
-	state, err := createGitHubOAuthState("octo@example.com", 7, "", time.Now())
+	assertSyncJobQueued(t, "stub", 1, 9)
+}
+
+func TestIntegrationCallbackStoresUserScopedIntegrationWithoutWorkspace(t *testing.T) {
+	setupIntegrationsTestDB(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "mailbox", scope: services.ScopeUser})
+
+	state, err := createIntegrationOAuthState("mailbox", 1, 0, "", time.Now())
 	if err != nil {
 		t.Fatalf("failed to create state: %v", err)
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/integrations/github/callback?code=test-code&state="+url.QueryEscape(state), nil)
-	req.AddCookie(&http.Cookie{Name: githubOAuthStateCookieName, Value: state})
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/mailbox/callback?code=test-code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: "mailbox_oauth_state", Value: state})
 	rr := httptest.NewRecorder()
 
-	GitHubCallbackHandler(rr, req)
+	IntegrationCallback(rr, req)
 
 	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rr.Code)
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
 	}
-	if savedUserID == 0 {
-		t.Fatal("expected integration save to use a real user ID")
+
+	var count int
+	if err := database.DB.QueryRow(
+		"SELECT COUNT(*) FROM external_integrations WHERE user_id = 1 AND provider = 'mailbox' AND workspace_id IS NULL",
+	).Scan(&count); err != nil {
+		t.Fatalf("failed to query saved integration: %v", err)
 	}
-	if savedWorkspaceID != 7 {
-		t.Fatalf("expected integration save to use workspace 7, got %d", savedWorkspaceID)
+	if count != 1 {
+		t.Fatalf("expected 1 user-scoped integration, got %d", count)
 	}
 
-	select {
-	case syncCall := <-syncCalls:
-		if syncCall.userID != savedUserID {
-			t.Fatalf("expected sync user ID %d, got %d", savedUserID, syncCall.userID)
-		}
-		if syncCall.workspaceID != 7 {
-			t.Fatalf("expected sync workspace ID 7, got %d", syncCall.workspaceID)
-		}
-	case <-time.After(100 * time.Millisecond):
-		t.Fatal("expected GitHub sync to be triggered")
-	}
+	assertSyncJobQueued(t, "mailbox", 1, 0)
 }
 
-func TestGmailAuthHandlerSetsSignedStateCookie(t *testing.T) {
-	setupTestDB()
-	defer database.DB.Close()
-
-	originalGmailClientID := gmailClientID
-	originalGmailClientSecret := gmailClientSecret
-	originalTokenEncryptor := tokenEncryptor
+func TestIntegrationCallbackAcceptsRemoteCallbackWithoutCookie(t *testing.T) {
+	setupIntegrationsTestDB(t)
 	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
 
-	encryptor, err := utils.NewTokenEncryptor()
+	useStubIntegrationProvider(t, remoteStubIntegrationProvider{
+		&stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace},
+	})
+
+	state, err := createIntegrationOAuthState("stub", 1, 9, "", time.Now())
 	if err != nil {
-		t.Fatalf("failed to create encryptor: %v", err)
+		t.Fatalf("failed to create state: %v", err)
 	}
 
-	gmailClientID = "gmail-client"
-	gmailClientSecret = "gmail-secret"
-	tokenEncryptor = encryptor
-	t.Cleanup(func() {
-		gmailClientID = originalGmailClientID
-		gmailClientSecret = originalGmailClientSecret
-		tokenEncryptor = originalTokenEncryptor
-	})
-
-	req := httptest.NewRequest(http.MethodGet, "/api/integrations/gmail/auth?redirect_url="+url.QueryEscape("http://localhost:4200/workspaces/7/settings/integrations"), nil)
-	req = req.WithContext(context.WithValue(req.Context(), middleware.UserEmailKey, "octo@example.com"))
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/stub/callback?code=test-code&state="+url.QueryEscape(state), nil)
 	rr := httptest.NewRecorder()
 
-	GmailAuthHandler(rr, req)
+	IntegrationCallback(rr, req)
 
 	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rr.Code)
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
 	}
+}
 
-	var payload map[string]string
-	if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
-		t.Fatalf("expected JSON response: %v", err)
-	}
+func TestIntegrationCallbackRejectsRemovedWorkspaceMember(t *testing.T) {
+	setupIntegrationsTestDB(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
 
-	authURL, err := url.Parse(payload["auth_url"])
-	if err != nil {
-		t.Fatalf("failed to parse auth URL: %v", err)
-	}
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
 
-	email, redirectURL, err := validateGmailOAuthState(authURL.Query().Get("state"))
+	state, err := createIntegrationOAuthState("stub", 1, 10, "", time.Now())
 	if err != nil {
-		t.Fatalf("expected signed state to validate: %v", err)
+		t.Fatalf("failed to create state: %v", err)
 	}
-	if email != "octo@example.com" {
-		t.Fatalf("expected state to be bound to octo@example.com, got %q", email)
+	if _, err := database.DB.Exec("DELETE FROM workspace_members WHERE workspace_id = 10 AND user_id = 1"); err != nil {
+		t.Fatalf("failed to remove membership: %v", err)
 	}
-	if redirectURL != "http://localhost:4200/workspaces/7/settings/integrations" {
-		t.Fatalf("unexpected redirect URL in state: %q", redirectURL)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/stub/callback?code=test-code&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: "stub_oauth_state", Value: state})
+	rr := httptest.NewRecorder()
+
+	IntegrationCallback(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d: %s", rr.Code, rr.Body.String())
 	}
+}
 
-	var stateCookie *http.Cookie
-	for _, cookie := range rr.Result().Cookies() {
-		if cookie.Name == gmailOAuthStateCookieName {
-			stateCookie = cookie
-			break
+func TestIntegrationActionsRequireWorkspaceMFA(t *testing.T) {
+	setupIntegrationsTestDB(t)
+
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
+
+	if _, err := database.DB.Exec("UPDATE workspaces SET require_mfa = TRUE WHERE id = 9"); err != nil {
+		t.Fatalf("failed to require MFA: %v", err)
+	}
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api/integrations/stub/auth?workspace_id=9"},
+		{http.MethodPost, "/api/integrations/stub/sync?workspace_id=9"},
+	} {
+		rr := httptest.NewRecorder()
+		IntegrationsRouter(rr, integrationRequestWithUser(tc.method, tc.path, "reader@example.com"))
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected status 403 without MFA, got %d", tc.path, rr.Code)
 		}
 	}
-	if stateCookie == nil {
-		t.Fatal("expected Gmail OAuth state cookie to be set")
+
+	if _, err := database.DB.Exec("UPDATE users SET mfa_enabled_at = CURRENT_TIMESTAMP WHERE id = 1"); err != nil {
+		t.Fatalf("failed to enable MFA: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	IntegrationsRouter(rr, integrationRequestWithUser(http.MethodPost, "/api/integrations/stub/sync?workspace_id=9", "reader@example.com"))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without integration once MFA is enabled, got %d", rr.Code)
 	}
 }
 
-func TestGmailCallbackHandlerAcceptsValidState(t *testing.T) {
+func TestIntegrationSyncQueuesJobForStoredIntegration(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
-	originalGmailClientID := gmailClientID
-	originalGmailClientSecret := gmailClientSecret
-	originalTokenEncryptor := tokenEncryptor
-	originalGmailExchangeCodeFunc := gmailExchangeCodeFunc
-	originalGmailFetchProfileFunc := gmailFetchProfileFunc
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
 
-	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
-	t.Setenv("GOOGLE_REDIRECT_URI", "https://example.ngrok.app/api/integrations/gmail/callback")
+	missingRR := httptest.NewRecorder()
+	IntegrationsRouter(missingRR, integrationRequestWithUser(http.MethodPost, "/api/integrations/stub/sync?workspace_id=9", "reader@example.com"))
+	if missingRR.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 without integration, got %d", missingRR.Code)
+	}
 
-	encryptor, err := utils.NewTokenEncryptor()
-	if err != nil {
-		t.Fatalf("failed to create encryptor: %v", err)
+	if _, err := database.DB.Exec(
+		"INSERT INTO external_integrations (user_id, workspace_id, provider, access_token) VALUES (1, 9, 'stub', 'token')",
+	); err != nil {
+		t.Fatalf("failed to seed integration: %v", err)
 	}
 
-	gmailClientID = "gmail-client"
-	gmailClientSecret = "gmail-secret"
-	tokenEncryptor = encryptor
-	gmailExchangeCodeFunc = func(code, redirectURI string) (*oauth2.Token, error) {
-		if code != "test-code" {
-			t.Fatalf("expected code test-code, got %q", code)
+	var jobIDs []int
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		IntegrationsRouter(rr, integrationRequestWithUser(http.MethodPost, "/api/integrations/stub/sync?workspace_id=9", "reader@example.com"))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected status 202, got %d: %s", rr.Code, rr.Body.String())
 		}
-		if redirectURI != "https://example.ngrok.app/api/integrations/gmail/callback" {
-			t.Fatalf("unexpected redirect URI: %q", redirectURI)
+		var payload struct {
+			JobID  int    `json:"job_id"`
+			Status string `json:"status"`
 		}
-		return &oauth2.Token{
-			AccessToken:  "gmail-access-token",
-			RefreshToken: "gmail-refresh-token",
-			Expiry:       time.Now().Add(time.Hour),
-		}, nil
-	}
-	gmailFetchProfileFunc = func(token *oauth2.Token) (*gmailProfile, error) {
-		if token.AccessToken != "gmail-access-token" {
-			t.Fatalf("expected gmail access token, got %q", token.AccessToken)
+		if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+			t.Fatalf("failed to decode sync response: %v", err)
 		}
-		return &gmailProfile{
-			Email:         "mailbox@example.com",
-			Name:          "Mailbox User",
-			VerifiedEmail: true,
-		}, nil
-	}
-	t.Cleanup(func() {
-		gmailClientID = originalGmailClientID
-		gmailClientSecret = originalGmailClientSecret
-		tokenEncryptor = originalTokenEncryptor
-		gmailExchangeCodeFunc = originalGmailExchangeCodeFunc
-		gmailFetchProfileFunc = originalGmailFetchProfileFunc
-	})
+		if payload.JobID == 0 || payload.Status != models.SyncJobPending {
+			t.Fatalf("expected a pending job, got %+v", payload)
+		}
+		jobIDs = append(jobIDs, payload.JobID)
+	}
+	if jobIDs[0] != jobIDs[1] {
+		t.Fatalf("expected repeated syncs to share the in-flight job, got %v", jobIDs)
+	}
 
-	state, err := createGmailOAuthState("reader@example.com", "", time.Now())
-	if err != nil {
-		t.Fatalf("failed to create state: %v", err)
+	jobPath := "/api/integrations/jobs/" + strconv.Itoa(jobIDs[0])
+	pollRR := httptest.NewRecorder()
+	IntegrationsRouter(pollRR, integrationRequestWithUser(http.MethodGet, jobPath, "reader@example.com"))
+	if pollRR.Code != http.StatusOK {
+		t.Fatalf("expected status 200 polling the job, got %d: %s", pollRR.Code, pollRR.Body.String())
+	}
+	var job models.SyncJob
+	if err := json.NewDecoder(pollRR.Body).Decode(&job); err != nil {
+		t.Fatalf("failed to decode job: %v", err)
+	}
+	if job.ID != jobIDs[0] || job.Provider != "stub" || job.WorkspaceID != 9 {
+		t.Fatalf("unexpected job: %+v", job)
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/integrations/gmail/callback?code=test-code&state="+url.QueryEscape(state), nil)
-	req.AddCookie(&http.Cookie{Name: gmailOAuthStateCookieName, Value: state})
-	rr := httptest.NewRecorder()
+	otherRR := httptest.NewRecorder()
+	IntegrationsRouter(otherRR, integrationRequestWithUser(http.MethodGet, jobPath, "other@example.com"))
+	if otherRR.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 polling another user's job, got %d", otherRR.Code)
+	}
+}
+
+func TestIntegrationSyncRejectsIntegrationThatNeedsReauth(t *testing.T) {
+	setupIntegrationsTestDB(t)
 
-	GmailCallbackHandler(rr, req)
+	useStubIntegrationProvider(t, &stubIntegrationProvider{name: "stub", scope: services.ScopeWorkspace})
 
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rr.Code)
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, reauth_required_at, reauth_reason)
+		 VALUES (1, 9, 'stub', 'token', CURRENT_TIMESTAMP, 'token_revoked')`,
+	); err != nil {
+		t.Fatalf("failed to seed integration: %v", err)
 	}
 
-	var accessToken, refreshToken, metadata string
+	rr := httptest.NewRecorder()
+	IntegrationsRouter(rr, integrationRequestWithUser(http.MethodPost, "/api/integrations/stub/sync?workspace_id=9", "reader@example.com"))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM sync_jobs").Scan(&count); err != nil {
+		t.Fatalf("failed to count sync jobs: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no sync job to be queued, got %d", count)
+	}
+}
+
+func assertSyncJobQueued(t *testing.T, provider string, userID, workspaceID int) {
+	t.Helper()
+
+	var count int
 	if err := database.DB.QueryRow(
-		`SELECT access_token, refresh_token, metadata FROM external_integrations
-		 WHERE user_id = ? AND provider = 'gmail' AND workspace_id IS NULL`,
-		1,
-	).Scan(&accessToken, &refreshToken, &metadata); err != nil {
-		t.Fatalf("failed to query saved gmail integration: %v", err)
+		"SELECT COUNT(*) FROM sync_jobs WHERE provider = ? AND user_id = ? AND COALESCE(workspace_id, 0) = ? AND status = 'pending'",
+		provider, userID, workspaceID,
+	).Scan(&count); err != nil {
+		t.Fatalf("failed to query sync jobs: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected one queued %s sync job, got %d", provider, count)
 	}
+}
 
-	decryptedAccessToken, err := tokenEncryptor.Decrypt(accessToken)
-	if err != nil {
-		t.Fatalf("failed to decrypt saved access token: %v", err)
+func TestJiraAuthRouteSetsSignedStateCookie(t *testing.T) {
+	setupIntegrationsTestDB(t)
+
+	t.Setenv("JIRA_CLIENT_ID", "jira-client")
+	t.Setenv("JIRA_CLIENT_SECRET", "jira-secret")
+	t.Setenv("API_BASE_URL", "https://api.example.com")
+	if err := jira.InitJiraService(); err != nil {
+		t.Fatalf("failed to initialize Jira service: %v", err)
 	}
-	if decryptedAccessToken != "gmail-access-token" {
-		t.Fatalf("unexpected decrypted access token %q", decryptedAccessToken)
+
+	req := integrationRequestWithUser(http.MethodGet, "/api/integrations/jira/auth?workspace_id=9&redirect_url=https://app.example.com/settings", "reader@example.com")
+	rr := httptest.NewRecorder()
+
+	IntegrationsRouter(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
 	}
 
-	decryptedRefreshToken, err := tokenEncryptor.Decrypt(refreshToken)
+	var payload map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode Jira auth response: %v", err)
+	}
+	authURL, err := url.Parse(payload["auth_url"])
 	if err != nil {
-		t.Fatalf("failed to decrypt saved refresh token: %v", err)
+		t.Fatalf("failed to parse auth URL: %v", err)
 	}
-	if decryptedRefreshToken != "gmail-refresh-token" {
-		t.Fatalf("unexpected decrypted refresh token %q", decryptedRefreshToken)
+	if authURL.Host != "auth.atlassian.com" {
+		t.Fatalf("expected Atlassian consent URL, got %q", payload["auth_url"])
 	}
 
-	var payload map[string]interface{}
-	if err := json.Unmarshal([]byte(metadata), &payload); err != nil {
-		t.Fatalf("failed to decode integration metadata: %v", err)
+	claims, err := validateIntegrationOAuthState("jira", authURL.Query().Get("state"))
+	if err != nil {
+		t.Fatalf("expected Jira state to validate: %v", err)
 	}
-	if payload["email"] != "mailbox@example.com" {
-		t.Fatalf("expected connected gmail email in metadata, got %#v", payload["email"])
+	if claims.UserID != 1 || claims.WorkspaceID != 9 || claims.RedirectURL != "https://app.example.com/settings" {
+		t.Fatalf("unexpected state values: %+v", claims)
 	}
-}
-
-func setupIntegrationsTestDB(t *testing.T) {
-	t.Helper()
 
-	setupTestDB()
+	cookie := rr.Result().Cookies()[0]
+	if cookie.Name != "jira_oauth_state" || !cookie.HttpOnly {
+		t.Fatalf("expected HttpOnly Jira state cookie, got %+v", cookie)
+	}
+}
 
-	if _, err := database.DB.Exec(`
-		CREATE TABLE IF NOT EXISTS external_integrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			provider TEXT NOT NULL,
-			access_token TEXT NOT NULL,
-			refresh_token TEXT,
-			expires_at DATETIME,
-			metadata TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`); err != nil {
-		t.Fatalf("failed to create external_integrations table: %v", err)
+func TestIntegrationWebhookRoutesToProvider(t *testing.T) {
+	rr := httptest.NewRecorder()
+	IntegrationWebhook(rr, httptest.NewRequest(http.MethodPost, "/api/webhooks/unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for unknown provider, got %d", rr.Code)
 	}
 
-	if _, err := database.DB.Exec(
-		"INSERT INTO users (id, email, password) VALUES (1, 'reader@example.com', 'hashed-password')",
-	); err != nil {
-		t.Fatalf("failed to seed primary integration user: %v", err)
+	rr = httptest.NewRecorder()
+	IntegrationWebhook(rr, httptest.NewRequest(http.MethodPost, "/api/webhooks/gmail", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for provider without webhooks, got %d", rr.Code)
 	}
 
-	if _, err := database.DB.Exec(
-		"INSERT INTO users (id, email, password) VALUES (2, 'other@example.com', 'hashed-password')",
-	); err != nil {
-		t.Fatalf("failed to seed secondary integration user: %v", err)
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "webhook-secret")
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", strings.NewReader(`{}`))
+	req.Header.Set("X-GitHub-Event", "issues")
+	rr = httptest.NewRecorder()
+	IntegrationWebhook(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected GitHub to reject the unsigned delivery, got %d", rr.Code)
 	}
+}
 
-	if _, err := database.DB.Exec(
+func setupIntegrationsTestDB(t *testing.T) {
+	t.Helper()
+
+	setupTestDB(t)
+	dbtest.Seed(t,
+		"INSERT INTO users (id, email, password) VALUES (1, 'reader@example.com', 'hashed-password')",
+		"INSERT INTO users (id, email, password) VALUES (2, 'other@example.com', 'hashed-password')",
 		`INSERT INTO workspaces (id, name, owner_id) VALUES
 			(9, 'Operations', 1),
 			(10, 'Engineering', 2)`,
-	); err != nil {
-		t.Fatalf("failed to seed workspaces: %v", err)
-	}
-
-	if _, err := database.DB.Exec(
 		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES
 			(9, 1, 'owner'),
 			(10, 1, 'viewer'),
 			(10, 2, 'owner')`,
-	); err != nil {
-		t.Fatalf("failed to seed workspace memberships: %v", err)
-	}
+	)
 }
 
 func integrationRequestWithUser(method, target, email string) *http.Request {
@@ -653,9 +620,8 @@ func integrationRequestWithUser(method, target, email string) *http.Request {
 
 func TestGetIntegrationsFiltersByWorkspaceAndIncludesGlobalIntegrations(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
-	if _, err := database.DB.Exec(
+	dbtest.Seed(t,
 		`INSERT INTO external_integrations
 			(id, user_id, workspace_id, provider, access_token, metadata)
 		 VALUES
@@ -664,9 +630,7 @@ func TestGetIntegrationsFiltersByWorkspaceAndIncludesGlobalIntegrations(t *testi
 			(3, 1, NULL, 'gmail', 'token-3', '{"email":"mailbox@example.com"}'),
 			(4, 1, 10, 'slack', 'token-4', '{"team":"workspace-10"}'),
 			(5, 2, 9, 'slack', 'token-5', '{"team":"other-user"}')`,
-	); err != nil {
-		t.Fatalf("failed to seed integrations: %v", err)
-	}
+	)
 
 	req := integrationRequestWithUser(http.MethodGet, "/api/integrations?workspace_id=9", "reader@example.com")
 	rr := httptest.NewRecorder()
@@ -706,15 +670,12 @@ func TestGetIntegrationsFiltersByWorkspaceAndIncludesGlobalIntegrations(t *testi
 
 func TestDeleteIntegrationDeletesOwnedIntegration(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
-	if _, err := database.DB.Exec(
+	dbtest.Seed(t,
 		`INSERT INTO external_integrations
 			(id, user_id, workspace_id, provider, access_token, metadata)
 		 VALUES (5, 1, 9, 'slack', 'token-5', '{"team":"workspace-9"}')`,
-	); err != nil {
-		t.Fatalf("failed to seed integration: %v", err)
-	}
+	)
 
 	req := integrationRequestWithUser(http.MethodDelete, "/api/integrations/5", "reader@example.com")
 	rr := httptest.NewRecorder()
@@ -736,15 +697,12 @@ func TestDeleteIntegrationDeletesOwnedIntegration(t *testing.T) {
 
 func TestDeleteIntegrationRejectsDifferentOwner(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
-	if _, err := database.DB.Exec(
+	dbtest.Seed(t,
 		`INSERT INTO external_integrations
 			(id, user_id, workspace_id, provider, access_token, metadata)
 		 VALUES (6, 2, 9, 'slack', 'token-6', '{"team":"workspace-9"}')`,
-	); err != nil {
-		t.Fatalf("failed to seed foreign integration: %v", err)
-	}
+	)
 
 	req := integrationRequestWithUser(http.MethodDelete, "/api/integrations/6", "reader@example.com")
 	rr := httptest.NewRecorder()
@@ -758,9 +716,8 @@ func TestDeleteIntegrationRejectsDifferentOwner(t *testing.T) {
 
 func TestIntegrationStatusHandlerReturnsConnectionState(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
-	if _, err := database.DB.Exec(
+	dbtest.Seed(t,
 		`INSERT INTO external_integrations
 			(id, user_id, workspace_id, provider, access_token, metadata)
 		 VALUES
@@ -768,38 +725,37 @@ func TestIntegrationStatusHandlerReturnsConnectionState(t *testing.T) {
 			(8, 1, 9, 'github', 'token-8', '{"scope":"workspace"}'),
 			(9, 1, NULL, 'gmail', 'token-9', '{"email":"mailbox@example.com"}'),
 			(10, 1, 9, 'jira', 'token-10', '{}')`,
+	)
+	if _, err := database.DB.Exec(
+		"UPDATE external_integrations SET expires_at = ? WHERE id = 10",
+		time.Now().Add(-time.Hour).UTC(),
 	); err != nil {
-		t.Fatalf("failed to seed integration statuses: %v", err)
-	}
-
-	originalSlackClientID := slackClientID
-	originalSlackClientSecret := slackClientSecret
-	originalGmailClientID := gmailClientID
-	originalGmailClientSecret := gmailClientSecret
-	originalTokenEncryptor := tokenEncryptor
-	t.Cleanup(func() {
-		slackClientID = originalSlackClientID
-		slackClientSecret = originalSlackClientSecret
-		gmailClientID = originalGmailClientID
-		gmailClientSecret = originalGmailClientSecret
-		tokenEncryptor = originalTokenEncryptor
-	})
+		t.Fatalf("failed to expire jira token: %v", err)
+	}
+	if _, err := database.DB.Exec(
+		`INSERT INTO sync_runs (integration_id, user_id, workspace_id, provider, status, error, started_at, finished_at)
+		 VALUES
+			(7, 1, 9, 'slack', 'failed', 'rate limited', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+			(9, 1, NULL, 'gmail', 'succeeded', NULL, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+	); err != nil {
+		t.Fatalf("failed to seed sync runs: %v", err)
+	}
 
-	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
-	encryptor, err := utils.NewTokenEncryptor()
-	if err != nil {
-		t.Fatalf("failed to create token encryptor: %v", err)
+	originalIntegrationProviders := integrationProviders
+	integrationProviders = func() []services.Provider {
+		return []services.Provider{
+			&stubIntegrationProvider{name: "github", scope: services.ScopeWorkspace, unconfigured: true},
+			&stubIntegrationProvider{name: "gmail", scope: services.ScopeUser},
+			&stubIntegrationProvider{name: "jira", scope: services.ScopeWorkspace},
+			&stubIntegrationProvider{name: "slack", scope: services.ScopeWorkspace},
+		}
 	}
-	slackClientID = "slack-client"
-	slackClientSecret = "slack-secret"
-	gmailClientID = "gmail-client"
-	gmailClientSecret = "gmail-secret"
-	tokenEncryptor = encryptor
+	t.Cleanup(func() { integrationProviders = originalIntegrationProviders })
 
 	req := integrationRequestWithUser(http.MethodGet, "/api/integrations/status?workspace_id=9", "reader@example.com")
 	rr := httptest.NewRecorder()
 
-	IntegrationStatusHandler(rr, req)
+	IntegrationsRouter(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rr.Code)
@@ -828,23 +784,80 @@ func TestIntegrationStatusHandlerReturnsConnectionState(t *testing.T) {
 		}
 	}
 
-	if slackStatus == nil || !slackStatus.Configured || !slackStatus.Connected {
-		t.Fatalf("expected configured and connected slack status, got %+v", slackStatus)
+	if slackStatus == nil || !slackStatus.Configured || !slackStatus.Connected || slackStatus.Status != models.IntegrationDegraded {
+		t.Fatalf("expected configured, connected and degraded slack status, got %+v", slackStatus)
+	}
+	if githubStatus == nil || githubStatus.Configured || !githubStatus.Connected || githubStatus.Status != models.IntegrationNeverSynced {
+		t.Fatalf("expected unconfigured but connected github status, got %+v", githubStatus)
 	}
-	if githubStatus == nil || !githubStatus.Connected {
-		t.Fatalf("expected connected github status, got %+v", githubStatus)
+	if gmailStatus == nil || !gmailStatus.Configured || !gmailStatus.Connected || gmailStatus.Status != models.IntegrationHealthy {
+		t.Fatalf("expected configured, connected and healthy gmail status, got %+v", gmailStatus)
 	}
-	if gmailStatus == nil || !gmailStatus.Configured || !gmailStatus.Connected {
-		t.Fatalf("expected configured and connected gmail status, got %+v", gmailStatus)
+	if jiraStatus == nil || !jiraStatus.Connected || jiraStatus.Status != models.IntegrationReauthRequired {
+		t.Fatalf("expected connected jira status that needs reauth, got %+v", jiraStatus)
 	}
-	if jiraStatus == nil || !jiraStatus.Connected {
-		t.Fatalf("expected connected jira status, got %+v", jiraStatus)
+}
+
+func TestIntegrationHealthRouteReportsSyncRuns(t *testing.T) {
+	setupIntegrationsTestDB(t)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	dbtest.Exec(t,
+		`INSERT INTO external_integrations (id, user_id, workspace_id, provider, access_token, refresh_token, expires_at)
+		 VALUES (4, 1, 9, 'github', 'token-4', 'refresh-4', ?)`,
+		expiresAt,
+	)
+	if _, err := database.DB.Exec(
+		`INSERT INTO sync_runs (integration_id, user_id, workspace_id, provider, status, items_fetched, items_upserted, error, started_at, finished_at)
+		 VALUES
+			(4, 1, 9, 'github', 'succeeded', 12, 10, NULL, '2026-01-01 10:00:00', '2026-01-01 10:01:00'),
+			(4, 1, 9, 'github', 'failed', 3, 0, 'rate limited', '2026-01-01 11:00:00', '2026-01-01 11:01:00'),
+			(4, 1, 9, 'github', 'failed', 0, 0, 'timeout', '2026-01-01 12:00:00', '2026-01-01 12:01:00')`,
+	); err != nil {
+		t.Fatalf("failed to seed sync runs: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	IntegrationsRouter(rr, integrationRequestWithUser(http.MethodGet, "/api/integrations/4/health", "reader@example.com"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var health models.IntegrationHealth
+	if err := json.NewDecoder(rr.Body).Decode(&health); err != nil {
+		t.Fatalf("failed to decode health: %v", err)
+	}
+	if health.Status != models.IntegrationDegraded || health.ConsecutiveFailures != 2 || health.LastError != "timeout" {
+		t.Fatalf("expected a degraded integration with 2 failures, got %+v", health)
+	}
+	if health.LastSuccessAt == nil || !health.LastSuccessAt.Equal(time.Date(2026, 1, 1, 10, 1, 0, 0, time.UTC)) {
+		t.Fatalf("expected the last success time, got %v", health.LastSuccessAt)
+	}
+	if health.LastErrorAt == nil || !health.LastErrorAt.Equal(time.Date(2026, 1, 1, 12, 1, 0, 0, time.UTC)) {
+		t.Fatalf("expected the last error time, got %v", health.LastErrorAt)
+	}
+	if health.ItemsFetched != 0 || health.ItemsUpserted != 0 || health.LastRun == nil || health.LastRun.Error != "timeout" {
+		t.Fatalf("expected item counts from the latest run, got %+v", health)
+	}
+	if health.TokenExpiresAt == nil || !health.TokenExpiresAt.Equal(expiresAt) {
+		t.Fatalf("expected token expiry %v, got %v", expiresAt, health.TokenExpiresAt)
+	}
+
+	otherRR := httptest.NewRecorder()
+	IntegrationsRouter(otherRR, integrationRequestWithUser(http.MethodGet, "/api/integrations/4/health", "other@example.com"))
+	if otherRR.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for another user's integration, got %d", otherRR.Code)
+	}
+
+	missingRR := httptest.NewRecorder()
+	IntegrationsRouter(missingRR, integrationRequestWithUser(http.MethodGet, "/api/integrations/99/health", "reader@example.com"))
+	if missingRR.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for a missing integration, got %d", missingRR.Code)
 	}
 }
 
 func TestGitHubAddCommentHandlerRejectsInvalidRequest(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
 	tests := []struct {
 		name string
@@ -874,7 +887,7 @@ func TestGitHubAddCommentHandlerRejectsInvalidRequest(t *testing.T) {
 			req.Body = io.NopCloser(strings.NewReader(tt.body))
 			rr := httptest.NewRecorder()
 
-			GitHubAddCommentHandler(rr, req)
+			IntegrationsRouter(rr, req)
 
 			if rr.Code != http.StatusBadRequest {
 				t.Fatalf("expected status 400, got %d: %s", rr.Code, rr.Body.String())
@@ -885,7 +898,6 @@ func TestGitHubAddCommentHandlerRejectsInvalidRequest(t *testing.T) {
 
 func TestGitHubUpdateStateHandlerRejectsInvalidRequest(t *testing.T) {
 	setupIntegrationsTestDB(t)
-	defer database.DB.Close()
 
 	tests := []struct {
 		name string
@@ -907,7 +919,7 @@ func TestGitHubUpdateStateHandlerRejectsInvalidRequest(t *testing.T) {
 			req.Body = io.NopCloser(strings.NewReader(tt.body))
 			rr := httptest.NewRecorder()
 
-			GitHubUpdateStateHandler(rr, req)
+			IntegrationsRouter(rr, req)
 
 			if rr.Code != http.StatusBadRequest {
 				t.Fatalf("expected status 400, got %d: %s", rr.Code, rr.Body.String())
@@ -2,16 +2,15 @@ package handlers
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"sentinent-backend/database"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"sentinent-backend/services"
 	"sentinent-backend/utils"
 	"strconv"
@@ -63,39 +62,24 @@ func CreateInvitation(w http.ResponseWriter, r *http.Request) {
 		req.Role = models.RoleMember
 	}
 
-	var existingCount int
-	err = database.DB.QueryRow(
-		`SELECT COUNT(*)
-		 FROM workspace_members wm
-		 JOIN users u ON u.id = wm.user_id
-		 WHERE wm.workspace_id = ? AND lower(u.email) = lower(?)`,
-		workspaceID, req.Email,
-	).Scan(&existingCount)
+	store := repository.Default()
+	isMember, err := store.Members.IsMemberByEmail(workspaceID, req.Email)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	if existingCount > 0 {
+	if isMember {
 		http.Error(w, "User is already a member of this workspace", http.StatusConflict)
 		return
 	}
 
-	var existingInvitationID int
-	err = database.DB.QueryRow(
-		`SELECT id
-		 FROM invitations
-		 WHERE workspace_id = ?
-		   AND lower(email) = lower(?)
-		   AND expires_at > ?
-		   AND accepted_at IS NULL`,
-		workspaceID, req.Email, time.Now(),
-	).Scan(&existingInvitationID)
-	if err == nil {
-		http.Error(w, "Active invitation already exists for this email", http.StatusConflict)
+	hasActiveInvitation, err := store.Invitations.HasActive(workspaceID, req.Email, time.Now())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	if err != nil && err != sql.ErrNoRows {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if hasActiveInvitation {
+		http.Error(w, "Active invitation already exists for this email", http.StatusConflict)
 		return
 	}
 
@@ -106,18 +90,19 @@ func CreateInvitation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	expiresAt := time.Now().AddDate(0, 0, invitationExpirationDays)
-	result, err := database.DB.Exec(
-		`INSERT INTO invitations (workspace_id, email, token, role, expires_at, created_by)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		workspaceID, req.Email, token, req.Role, expiresAt, userID,
-	)
+	invitationID, err := store.Invitations.Create(models.Invitation{
+		WorkspaceID: workspaceID,
+		Email:       req.Email,
+		Token:       token,
+		Role:        req.Role,
+		ExpiresAt:   expiresAt,
+		CreatedBy:   userID,
+	})
 	if err != nil {
 		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
 		return
 	}
 
-	invitationID, _ := result.LastInsertId()
-
 	// Send invitation email — look up workspace name and inviter email for the message body.
 	go func() {
 		defer func() {
@@ -126,13 +111,13 @@ func CreateInvitation(w http.ResponseWriter, r *http.Request) {
 			}
 		}()
 
-		var workspaceName string
-		if err := database.DB.QueryRow("SELECT name FROM workspaces WHERE id = ?", workspaceID).Scan(&workspaceName); err != nil {
+		workspaceName, err := store.Workspaces.Name(workspaceID)
+		if err != nil {
 			log.Printf("invitation email: could not fetch workspace name for workspace %d: %v", workspaceID, err)
 			return
 		}
-		var inviterEmail string
-		if err := database.DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&inviterEmail); err != nil {
+		inviterEmail, err := store.Users.Email(userID)
+		if err != nil {
 			log.Printf("invitation email: could not fetch inviter email for user %d: %v", userID, err)
 			return
 		}
@@ -149,7 +134,7 @@ func CreateInvitation(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	response := models.InvitationResponse{
-		ID:          int(invitationID),
+		ID:          invitationID,
 		WorkspaceID: workspaceID,
 		Email:       req.Email,
 		Token:       token,
@@ -191,39 +176,11 @@ func ListInvitations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(
-		`SELECT id, workspace_id, email, token, role, expires_at, created_by, created_at, updated_at, accepted_at
-		 FROM invitations
-		 WHERE workspace_id = ?
-		 ORDER BY created_at DESC`,
-		workspaceID,
-	)
+	invitations, err := repository.Default().Invitations.ListForWorkspace(workspaceID)
 	if err != nil {
 		http.Error(w, "Failed to fetch invitations", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	invitations := make([]models.Invitation, 0)
-	for rows.Next() {
-		var invitation models.Invitation
-		if err := rows.Scan(
-			&invitation.ID,
-			&invitation.WorkspaceID,
-			&invitation.Email,
-			&invitation.Token,
-			&invitation.Role,
-			&invitation.ExpiresAt,
-			&invitation.CreatedBy,
-			&invitation.CreatedAt,
-			&invitation.UpdatedAt,
-			&invitation.AcceptedAt,
-		); err != nil {
-			http.Error(w, "Failed to scan invitation", http.StatusInternalServerError)
-			return
-		}
-		invitations = append(invitations, invitation)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(invitations)
@@ -241,22 +198,8 @@ func ValidateInvitation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var invitation models.Invitation
-	var createdBy int
-	err := database.DB.QueryRow(
-		`SELECT id, workspace_id, email, role, expires_at, created_at, created_by
-		 FROM invitations
-		 WHERE token = ? AND accepted_at IS NULL`,
-		token,
-	).Scan(
-		&invitation.ID,
-		&invitation.WorkspaceID,
-		&invitation.Email,
-		&invitation.Role,
-		&invitation.ExpiresAt,
-		&invitation.CreatedAt,
-		&createdBy,
-	)
+	store := repository.Default()
+	invitation, err := store.Invitations.GetPendingByToken(token)
 	if err != nil {
 		http.Error(w, "Invalid or expired invitation", http.StatusNotFound)
 		return
@@ -266,15 +209,14 @@ func ValidateInvitation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var workspaceName string
-	err = database.DB.QueryRow("SELECT name FROM workspaces WHERE id = ?", invitation.WorkspaceID).Scan(&workspaceName)
+	workspaceName, err := store.Workspaces.Name(invitation.WorkspaceID)
 	if err != nil {
 		http.Error(w, "Workspace not found", http.StatusNotFound)
 		return
 	}
 
-	var invitedByEmail string
-	if err := database.DB.QueryRow("SELECT email FROM users WHERE id = ?", createdBy).Scan(&invitedByEmail); err != nil {
+	invitedByEmail, err := store.Users.Email(invitation.CreatedBy)
+	if err != nil {
 		http.Error(w, "Invitation owner not found", http.StatusNotFound)
 		return
 	}
@@ -320,13 +262,8 @@ func AcceptInvitation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var invitation models.Invitation
-	err := database.DB.QueryRow(
-		`SELECT id, workspace_id, email, role, expires_at
-		 FROM invitations
-		 WHERE token = ? AND accepted_at IS NULL`,
-		token,
-	).Scan(&invitation.ID, &invitation.WorkspaceID, &invitation.Email, &invitation.Role, &invitation.ExpiresAt)
+	store := repository.Default()
+	invitation, err := store.Invitations.GetPendingByToken(token)
 	if err != nil {
 		http.Error(w, "Invalid or expired invitation", http.StatusNotFound)
 		return
@@ -340,45 +277,32 @@ func AcceptInvitation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var existingRole string
-	err = database.DB.QueryRow(
-		"SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?",
-		invitation.WorkspaceID, userID,
-	).Scan(&existingRole)
+	_, err = store.Members.Role(invitation.WorkspaceID, userID)
 	if err == nil {
 		http.Error(w, "You are already a member of this workspace", http.StatusConflict)
 		return
 	}
-	if err != nil && err != sql.ErrNoRows {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	tx, err := database.DB.Begin()
-	if err != nil {
+	if err != repository.ErrNotFound {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	defer tx.Rollback()
 
-	if _, err = tx.Exec(
-		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
-		invitation.WorkspaceID, userID, invitation.Role,
-	); err != nil {
+	acceptedAt := time.Now()
+	if err := store.Invitations.Accept(*invitation, userID, acceptedAt); err != nil {
 		http.Error(w, "Failed to add member to workspace", http.StatusInternalServerError)
 		return
 	}
-	if _, err = tx.Exec(
-		"UPDATE invitations SET accepted_at = ?, accepted_by = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		time.Now(), userID, invitation.ID,
-	); err != nil {
-		http.Error(w, "Failed to update invitation", http.StatusInternalServerError)
-		return
-	}
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to complete invitation acceptance", http.StatusInternalServerError)
-		return
-	}
+
+	accepted := *invitation
+	accepted.Token = ""
+	accepted.AcceptedAt = &acceptedAt
+	accepted.AcceptedBy = &userID
+	services.EnqueueWebhookEvent(invitation.WorkspaceID, models.WebhookEventInvitationAccepted, accepted)
+	services.EnqueueWebhookEvent(invitation.WorkspaceID, models.WebhookEventMemberJoined, models.WebhookMember{
+		UserID: userID,
+		Email:  userEmail,
+		Role:   invitation.Role,
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
@@ -405,12 +329,9 @@ func CancelInvitation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var workspaceID int
-	err = database.DB.QueryRow(
-		"SELECT workspace_id FROM invitations WHERE id = ? AND accepted_at IS NULL",
-		invitationID,
-	).Scan(&workspaceID)
-	if err == sql.ErrNoRows {
+	store := repository.Default()
+	workspaceID, err := store.Invitations.PendingWorkspaceID(invitationID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Invitation not found", http.StatusNotFound)
 		return
 	}
@@ -428,8 +349,17 @@ func CancelInvitation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Forbidden: Only owners can cancel invitations", http.StatusForbidden)
 		return
 	}
+	allowed, err := middleware.SatisfiesOwnerMFA(userID, workspaceID, time.Now())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "Forbidden: Workspace owners must enable multi-factor authentication", http.StatusForbidden)
+		return
+	}
 
-	if _, err := database.DB.Exec("DELETE FROM invitations WHERE id = ?", invitationID); err != nil {
+	if err := store.Invitations.Delete(invitationID); err != nil {
 		http.Error(w, "Failed to cancel invitation", http.StatusInternalServerError)
 		return
 	}
@@ -457,14 +387,8 @@ func ResendInvitation(w http.ResponseWriter, r *http.Request) {
 	}
 	token := parts[2]
 
-	var invitation models.Invitation
-	var createdBy int
-	err := database.DB.QueryRow(
-		`SELECT id, workspace_id, email, role, expires_at, created_by
-		 FROM invitations
-		 WHERE token = ? AND accepted_at IS NULL`,
-		token,
-	).Scan(&invitation.ID, &invitation.WorkspaceID, &invitation.Email, &invitation.Role, &invitation.ExpiresAt, &createdBy)
+	store := repository.Default()
+	invitation, err := store.Invitations.GetPendingByToken(token)
 	if err != nil {
 		http.Error(w, "Invalid or already accepted invitation", http.StatusNotFound)
 		return
@@ -479,6 +403,15 @@ func ResendInvitation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Forbidden: Only owners can resend invitations", http.StatusForbidden)
 		return
 	}
+	allowed, err := middleware.SatisfiesOwnerMFA(userID, invitation.WorkspaceID, time.Now())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "Forbidden: Workspace owners must enable multi-factor authentication", http.StatusForbidden)
+		return
+	}
 
 	go func() {
 		defer func() {
@@ -486,13 +419,13 @@ func ResendInvitation(w http.ResponseWriter, r *http.Request) {
 				log.Printf("resend invitation email: goroutine panic (recovered): %v", r)
 			}
 		}()
-		var workspaceName string
-		if err := database.DB.QueryRow("SELECT name FROM workspaces WHERE id = ?", invitation.WorkspaceID).Scan(&workspaceName); err != nil {
+		workspaceName, err := store.Workspaces.Name(invitation.WorkspaceID)
+		if err != nil {
 			log.Printf("resend invitation email: could not fetch workspace name: %v", err)
 			return
 		}
-		var inviterEmail string
-		if err := database.DB.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&inviterEmail); err != nil {
+		inviterEmail, err := store.Users.Email(userID)
+		if err != nil {
 			log.Printf("resend invitation email: could not fetch inviter email: %v", err)
 			return
 		}
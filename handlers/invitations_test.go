@@ -8,140 +8,33 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
 	"strconv"
 	"testing"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func setupCollaborationTestDB(t *testing.T) {
 	t.Helper()
 
-	var err error
-	database.DB, err = sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("failed to open db: %v", err)
-	}
-	database.DB.SetMaxOpenConns(1)
-
-	statements := []string{
-		`CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL
-		);`,
-		`CREATE TABLE workspaces (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			description TEXT DEFAULT '',
-			owner_id INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE decisions (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			workspace_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			title TEXT NOT NULL,
-			description TEXT,
-			status TEXT NOT NULL,
-			due_date DATETIME,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE external_integrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			provider TEXT NOT NULL,
-			access_token TEXT NOT NULL,
-			metadata TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE signals (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			source_type TEXT NOT NULL,
-			source_id TEXT NOT NULL,
-			title TEXT NOT NULL,
-			status TEXT NOT NULL DEFAULT 'unread',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE signal_status (
-			signal_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE workspace_members (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			workspace_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			role TEXT NOT NULL,
-			joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(workspace_id, user_id)
-		);`,
-		`CREATE TABLE invitations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			workspace_id INTEGER NOT NULL,
-			email TEXT NOT NULL,
-			token TEXT NOT NULL UNIQUE,
-			role TEXT NOT NULL,
-			expires_at DATETIME NOT NULL,
-			created_by INTEGER NOT NULL,
-			accepted_at DATETIME,
-			accepted_by INTEGER,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-	}
-
-	for _, statement := range statements {
-		if _, err := database.DB.Exec(statement); err != nil {
-			t.Fatalf("failed to create schema: %v", err)
-		}
-	}
-
-	t.Cleanup(func() {
-		_ = database.DB.Close()
-	})
+	dbtest.Open(t)
 }
 
 func seedWorkspaceCollaborationData(t *testing.T) {
 	t.Helper()
 
-	_, err := database.DB.Exec(`
-		INSERT INTO users (id, email, password) VALUES
+	dbtest.Seed(t,
+		`INSERT INTO users (id, email, password) VALUES
 			(1, 'owner@example.com', 'pw'),
 			(2, 'invitee@example.com', 'pw'),
-			(3, 'member@example.com', 'pw')
-	`)
-	if err != nil {
-		t.Fatalf("failed to seed users: %v", err)
-	}
-
-	_, err = database.DB.Exec(
+			(3, 'member@example.com', 'pw')`,
 		"INSERT INTO workspaces (id, name, owner_id) VALUES (10, 'Sentinent', 1)",
-	)
-	if err != nil {
-		t.Fatalf("failed to seed workspace: %v", err)
-	}
-
-	_, err = database.DB.Exec(`
-		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES
 			(10, 1, 'owner'),
-			(10, 3, 'member')
-	`)
-	if err != nil {
-		t.Fatalf("failed to seed members: %v", err)
-	}
+			(10, 3, 'member')`,
+	)
 }
 
 func requestWithUser(method, target string, body []byte, userID int, email string) *http.Request {
@@ -224,15 +117,14 @@ func TestListAndCancelInvitation(t *testing.T) {
 
 	expiresAt := time.Now().Add(24 * time.Hour)
 	acceptedAt := time.Now().Add(-time.Hour)
-	result, err := database.DB.Exec(
+	var invitationID int64
+	if err := database.DB.QueryRow(
 		`INSERT INTO invitations (workspace_id, email, token, role, expires_at, created_by)
-		 VALUES (10, 'invitee@example.com', 'token-1', 'member', ?, 1)`,
+		 VALUES (10, 'invitee@example.com', 'token-1', 'member', ?, 1) RETURNING id`,
 		expiresAt,
-	)
-	if err != nil {
+	).Scan(&invitationID); err != nil {
 		t.Fatalf("failed to seed invitation: %v", err)
 	}
-	invitationID, _ := result.LastInsertId()
 
 	if _, err := database.DB.Exec(
 		`INSERT INTO invitations (workspace_id, email, token, role, expires_at, created_by, accepted_at, accepted_by)
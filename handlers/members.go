@@ -1,12 +1,11 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
-	"sentinent-backend/database"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"strconv"
 )
 
@@ -28,6 +27,8 @@ func WorkspacesRouter(w http.ResponseWriter, r *http.Request) {
 		UpdateWorkspace(w, r)
 	case len(parts) == 3 && r.Method == http.MethodDelete:
 		DeleteWorkspace(w, r)
+	case len(parts) == 4 && parts[3] == "mfa-policy" && r.Method == http.MethodPut:
+		UpdateWorkspaceMFAPolicy(w, r)
 	case len(parts) == 4 && parts[3] == "decisions" && r.Method == http.MethodGet:
 		ListDecisions(w, r)
 	case len(parts) == 4 && parts[3] == "decisions" && r.Method == http.MethodPost:
@@ -38,8 +39,50 @@ func WorkspacesRouter(w http.ResponseWriter, r *http.Request) {
 		UpdateDecision(w, r)
 	case len(parts) == 5 && parts[3] == "decisions" && r.Method == http.MethodDelete:
 		DeleteDecision(w, r)
+	case len(parts) == 6 && parts[3] == "decisions" && parts[5] == "vote" && r.Method == http.MethodPut:
+		VoteOnDecision(w, r)
+	case len(parts) == 6 && parts[3] == "decisions" && parts[5] == "vote" && r.Method == http.MethodDelete:
+		WithdrawDecisionVote(w, r)
+	case len(parts) == 6 && parts[3] == "decisions" && parts[5] == "signals" && r.Method == http.MethodGet:
+		ListDecisionSignals(w, r)
+	case len(parts) == 6 && parts[3] == "decisions" && parts[5] == "signals" && r.Method == http.MethodPost:
+		LinkDecisionSignal(w, r)
+	case len(parts) == 7 && parts[3] == "decisions" && parts[5] == "signals" && r.Method == http.MethodDelete:
+		UnlinkDecisionSignal(w, r)
+	case len(parts) == 4 && parts[3] == "rules" && r.Method == http.MethodGet:
+		ListRules(w, r)
+	case len(parts) == 4 && parts[3] == "rules" && r.Method == http.MethodPost:
+		CreateRule(w, r)
+	case len(parts) == 5 && parts[3] == "rules" && parts[4] == "dry-run" && r.Method == http.MethodPost:
+		DryRunRule(w, r)
+	case len(parts) == 5 && parts[3] == "rules" && r.Method == http.MethodGet:
+		GetRule(w, r)
+	case len(parts) == 5 && parts[3] == "rules" && r.Method == http.MethodPatch:
+		UpdateRule(w, r)
+	case len(parts) == 5 && parts[3] == "rules" && r.Method == http.MethodDelete:
+		DeleteRule(w, r)
+	case len(parts) == 6 && parts[3] == "rules" && parts[5] == "dry-run" && r.Method == http.MethodPost:
+		DryRunRule(w, r)
+	case len(parts) == 4 && parts[3] == "webhooks" && r.Method == http.MethodGet:
+		ListWebhooks(w, r)
+	case len(parts) == 4 && parts[3] == "webhooks" && r.Method == http.MethodPost:
+		CreateWebhook(w, r)
+	case len(parts) == 5 && parts[3] == "webhooks" && r.Method == http.MethodGet:
+		GetWebhook(w, r)
+	case len(parts) == 5 && parts[3] == "webhooks" && r.Method == http.MethodPatch:
+		UpdateWebhook(w, r)
+	case len(parts) == 5 && parts[3] == "webhooks" && r.Method == http.MethodDelete:
+		DeleteWebhook(w, r)
+	case len(parts) == 6 && parts[3] == "webhooks" && parts[5] == "deliveries" && r.Method == http.MethodGet:
+		ListWebhookDeliveries(w, r)
+	case len(parts) == 8 && parts[3] == "webhooks" && parts[5] == "deliveries" && parts[7] == "redeliver" && r.Method == http.MethodPost:
+		RedeliverWebhookDelivery(w, r)
+	case len(parts) == 4 && parts[3] == "search" && r.Method == http.MethodGet:
+		SearchWorkspace(w, r)
 	case len(parts) == 4 && parts[3] == "signals" && r.Method == http.MethodGet:
 		GetSignals(w, r)
+	case len(parts) == 5 && parts[3] == "signals" && parts[4] == "stream" && r.Method == http.MethodGet:
+		StreamSignals(w, r)
 	case len(parts) == 4 && parts[3] == "invitations" && r.Method == http.MethodPost:
 		CreateInvitation(w, r)
 	case len(parts) == 4 && parts[3] == "invitations" && r.Method == http.MethodGet:
@@ -85,44 +128,11 @@ func ListMembers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(
-		`SELECT wm.id, wm.workspace_id, wm.user_id, wm.role, wm.joined_at, wm.updated_at, u.email
-		 FROM workspace_members wm
-		 JOIN users u ON u.id = wm.user_id
-		 WHERE wm.workspace_id = ?
-		 ORDER BY
-			CASE wm.role
-				WHEN 'owner' THEN 1
-				WHEN 'member' THEN 2
-				WHEN 'viewer' THEN 3
-				ELSE 4
-			END,
-			wm.joined_at`,
-		workspaceID,
-	)
+	members, err := repository.Default().Members.List(workspaceID)
 	if err != nil {
 		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	members := make([]models.WorkspaceMember, 0)
-	for rows.Next() {
-		var member models.WorkspaceMember
-		if err := rows.Scan(
-			&member.ID,
-			&member.WorkspaceID,
-			&member.UserID,
-			&member.Role,
-			&member.JoinedAt,
-			&member.UpdatedAt,
-			&member.Email,
-		); err != nil {
-			http.Error(w, "Failed to scan member", http.StatusInternalServerError)
-			return
-		}
-		members = append(members, member)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(members)
@@ -160,12 +170,9 @@ func RemoveMember(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var targetRole string
-	err = database.DB.QueryRow(
-		"SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?",
-		workspaceID, targetUserID,
-	).Scan(&targetRole)
-	if err == sql.ErrNoRows {
+	store := repository.Default()
+	targetRole, err := store.Members.Role(workspaceID, targetUserID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "User is not a member of this workspace", http.StatusNotFound)
 		return
 	}
@@ -173,15 +180,12 @@ func RemoveMember(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	if targetRole == string(models.RoleOwner) {
+	if targetRole == models.RoleOwner {
 		http.Error(w, "Cannot remove another owner", http.StatusForbidden)
 		return
 	}
 
-	if _, err := database.DB.Exec(
-		"DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?",
-		workspaceID, targetUserID,
-	); err != nil {
+	if err := store.Members.Remove(workspaceID, targetUserID); err != nil {
 		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
 		return
 	}
@@ -229,12 +233,9 @@ func UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var targetRole string
-	err = database.DB.QueryRow(
-		"SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?",
-		workspaceID, targetUserID,
-	).Scan(&targetRole)
-	if err == sql.ErrNoRows {
+	store := repository.Default()
+	targetRole, err := store.Members.Role(workspaceID, targetUserID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "User is not a member of this workspace", http.StatusNotFound)
 		return
 	}
@@ -248,59 +249,16 @@ func UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := database.DB.Begin()
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	previousOwnerID := 0
+	if req.Role == models.RoleOwner && targetRole != models.RoleOwner {
+		previousOwnerID = currentUserID
 	}
-	defer tx.Rollback()
-
-	if req.Role == models.RoleOwner && targetRole != string(models.RoleOwner) {
-		if _, err := tx.Exec(
-			"UPDATE workspaces SET owner_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-			targetUserID, workspaceID,
-		); err != nil {
-			http.Error(w, "Failed to transfer ownership", http.StatusInternalServerError)
-			return
-		}
-		if _, err := tx.Exec(
-			"UPDATE workspace_members SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE workspace_id = ? AND user_id = ?",
-			models.RoleMember, workspaceID, currentUserID,
-		); err != nil {
-			http.Error(w, "Failed to transfer ownership", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	if _, err := tx.Exec(
-		"UPDATE workspace_members SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE workspace_id = ? AND user_id = ?",
-		req.Role, workspaceID, targetUserID,
-	); err != nil {
-		http.Error(w, "Failed to update member role", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
+	if err := store.Members.UpdateRole(workspaceID, targetUserID, req.Role, previousOwnerID); err != nil {
 		http.Error(w, "Failed to update member role", http.StatusInternalServerError)
 		return
 	}
 
-	var member models.WorkspaceMember
-	err = database.DB.QueryRow(
-		`SELECT wm.id, wm.workspace_id, wm.user_id, wm.role, wm.joined_at, wm.updated_at, u.email
-		 FROM workspace_members wm
-		 JOIN users u ON u.id = wm.user_id
-		 WHERE wm.workspace_id = ? AND wm.user_id = ?`,
-		workspaceID, targetUserID,
-	).Scan(
-		&member.ID,
-		&member.WorkspaceID,
-		&member.UserID,
-		&member.Role,
-		&member.JoinedAt,
-		&member.UpdatedAt,
-		&member.Email,
-	)
+	member, err := store.Members.Get(workspaceID, targetUserID)
 	if err != nil {
 		http.Error(w, "Failed to fetch updated member", http.StatusInternalServerError)
 		return
@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sentinent-backend/middleware"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mfaIssuer         = "Sentinent"
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+	// mfaMaxFailedAttempts wrong codes in a row lock the user out for
+	// mfaLockoutBase, doubling with each further wrong code up to mfaLockoutMax,
+	// so the six-digit space cannot be brute forced by signing in again.
+	mfaMaxFailedAttempts = 5
+	mfaLockoutBase       = 15 * time.Minute
+	mfaLockoutMax        = 24 * time.Hour
+)
+
+// recoveryCodeEncoding avoids padding and ambiguous case so codes are easy to
+// type back in.
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// mfaChallengeClaims identify a user who passed the password check but still
+// owes a second factor. They are signed with a key derived from JwtKey, so a
+// challenge token can never be used as an access token. Logins is the user's
+// count of completed MFA logins when the challenge was issued; the challenge
+// is spent once that count moves on.
+type mfaChallengeClaims struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+	Logins int    `json:"logins"`
+	jwt.RegisteredClaims
+}
+
+// MFAStatus reports whether the current user has MFA enabled and, for a
+// workspace owner who has not, when they have to enroll by.
+func MFAStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	store := repository.Default()
+	settings, err := store.MFA.Get(userID)
+	if err != nil {
+		http.Error(w, "Failed to load MFA status", http.StatusInternalServerError)
+		return
+	}
+	remaining := 0
+	if settings.Enabled() {
+		remaining, err = store.MFA.RemainingRecoveryCodes(userID)
+		if err != nil {
+			http.Error(w, "Failed to load MFA status", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"enabled":                  settings.Enabled(),
+		"recovery_codes_remaining": remaining,
+	}
+	if !settings.Enabled() && settings.RequiredBy != nil {
+		response["required_by"] = settings.RequiredBy.UTC()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// SetupMFA starts TOTP enrollment by generating a secret and returning it with
+// a provisioning URI. MFA stays off until ConfirmMFA verifies a code.
+func SetupMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	store := repository.Default()
+	email, err := store.Users.Email(userID)
+	if err != nil {
+		http.Error(w, "Failed to start MFA setup", http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Failed to start MFA setup", http.StatusInternalServerError)
+		return
+	}
+	encryptedSecret, err := encryptor.Encrypt(secret)
+	if err != nil {
+		http.Error(w, "Failed to start MFA setup", http.StatusInternalServerError)
+		return
+	}
+
+	err = store.MFA.SetPendingSecret(userID, encryptedSecret)
+	if err == repository.ErrNotFound {
+		http.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to start MFA setup", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(secret, mfaIssuer, email),
+	})
+}
+
+// ConfirmMFA enables MFA once the user proves their authenticator produces
+// valid codes, and returns a fresh set of recovery codes. The codes are only
+// shown this once; just their hashes are stored.
+func ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	store := repository.Default()
+	settings, err := store.MFA.Get(userID)
+	if err != nil {
+		http.Error(w, "Failed to confirm MFA", http.StatusInternalServerError)
+		return
+	}
+	if settings.Enabled() {
+		http.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
+	if settings.EncryptedSecret == "" {
+		http.Error(w, "MFA setup has not been started", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := decryptMFASecret(settings)
+	if err != nil {
+		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		return
+	}
+	step, ok := utils.MatchTOTP(secret, req.Code, time.Now())
+	if !ok {
+		http.Error(w, "Invalid verification code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Failed to confirm MFA", http.StatusInternalServerError)
+		return
+	}
+	err = store.MFA.Enable(userID, step, hashes, time.Now())
+	if err == repository.ErrNotFound {
+		http.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to confirm MFA", http.StatusInternalServerError)
+		return
+	}
+
+	writeRecoveryCodes(w, codes)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current
+// TOTP code.
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	store := repository.Default()
+	settings, err := store.MFA.Get(userID)
+	if err != nil {
+		http.Error(w, "Failed to regenerate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	if !settings.Enabled() {
+		http.Error(w, "MFA is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	valid, err := verifyMFACode(settings, req.Code, false)
+	if err != nil {
+		http.Error(w, "Failed to regenerate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Invalid verification code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Failed to regenerate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	if err := store.MFA.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		http.Error(w, "Failed to regenerate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	writeRecoveryCodes(w, codes)
+}
+
+// VerifyMFALogin completes a login that Signin answered with an MFA challenge.
+// The code may be a TOTP code or an unused recovery code.
+func VerifyMFALogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req mfaLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := parseMFAChallenge(req.MFAToken)
+	if err == errJwtKeyMissing {
+		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid or expired MFA challenge", http.StatusUnauthorized)
+		return
+	}
+
+	store := repository.Default()
+	settings, err := store.MFA.Get(claims.UserID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Invalid or expired MFA challenge", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !settings.Enabled() || settings.Logins != claims.Logins {
+		http.Error(w, "Invalid or expired MFA challenge", http.StatusUnauthorized)
+		return
+	}
+	now := time.Now()
+	if settings.Locked(now) {
+		writeMFALocked(w, *settings.LockedUntil, now)
+		return
+	}
+
+	valid, err := verifyMFACode(settings, req.Code, true)
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		failures, err := store.MFA.RecordFailure(claims.UserID)
+		if err != nil {
+			http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+			return
+		}
+		if lockout := mfaLockoutDuration(failures); lockout > 0 {
+			if err := store.MFA.Lock(claims.UserID, now.Add(lockout)); err != nil {
+				http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+				return
+			}
+		}
+		http.Error(w, "Invalid verification code", http.StatusUnauthorized)
+		return
+	}
+
+	err = store.MFA.RecordLogin(claims.UserID, claims.Logins)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Invalid or expired MFA challenge", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	startSession(w, r, claims.UserID, claims.Email)
+}
+
+// mfaLockoutDuration is how long failures consecutive wrong codes lock a user
+// out: nothing below the limit, then the base lockout doubled for each wrong
+// code past it, capped at the maximum.
+func mfaLockoutDuration(failures int) time.Duration {
+	if failures < mfaMaxFailedAttempts {
+		return 0
+	}
+	return utils.Backoff(mfaLockoutBase, mfaLockoutMax, failures-mfaMaxFailedAttempts+1)
+}
+
+// writeMFALocked refuses a login while the user is locked out, saying when to
+// try again.
+func writeMFALocked(w http.ResponseWriter, lockedUntil, now time.Time) {
+	retryAfter := int(lockedUntil.Sub(now).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+}
+
+// writeMFAChallenge answers a correct password for an MFA user. No session is
+// created until VerifyMFALogin accepts a second factor.
+func writeMFAChallenge(w http.ResponseWriter, userID int, email string, logins int) {
+	if len(utils.JwtKey) == 0 {
+		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	challengeID, err := generateSecureToken()
+	if err != nil {
+		http.Error(w, "Failed to create MFA challenge", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(mfaChallengeTTL)
+	claims := &mfaChallengeClaims{
+		UserID: userID,
+		Email:  email,
+		Logins: logins,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        challengeID,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	challenge, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(mfaChallengeKey())
+	if err != nil {
+		http.Error(w, "Failed to create MFA challenge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"mfa_required": true,
+		"mfa_token":    challenge,
+		"expires_at":   expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func parseMFAChallenge(tokenString string) (*mfaChallengeClaims, error) {
+	if len(utils.JwtKey) == 0 {
+		return nil, errJwtKeyMissing
+	}
+
+	claims := &mfaChallengeClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return mfaChallengeKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func mfaChallengeKey() []byte {
+	mac := hmac.New(sha256.New, utils.JwtKey)
+	mac.Write([]byte("mfa-challenge"))
+	return mac.Sum(nil)
+}
+
+// verifyMFACode accepts a TOTP code that has not been used before and, when
+// allowRecovery is set, an unused recovery code.
+func verifyMFACode(settings *models.MFASettings, code string, allowRecovery bool) (bool, error) {
+	secret, err := decryptMFASecret(settings)
+	if err != nil {
+		return false, err
+	}
+
+	store := repository.Default()
+	if step, ok := utils.MatchTOTP(secret, code, time.Now()); ok {
+		err := store.MFA.UseStep(settings.UserID, step)
+		if err == repository.ErrNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	if !allowRecovery {
+		return false, nil
+	}
+	err = store.MFA.UseRecoveryCode(settings.UserID, hashRecoveryCode(code), time.Now())
+	if err == repository.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func decryptMFASecret(settings *models.MFASettings) (string, error) {
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		return "", err
+	}
+	return encryptor.Decrypt(settings.EncryptedSecret)
+}
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx together with
+// the hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))[:10]
+		code := encoded[:5] + "-" + encoded[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalizes case, spacing and dashes before hashing so codes
+// can be typed back loosely.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
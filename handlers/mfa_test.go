@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/middleware"
+	"sentinent-backend/utils"
+	"testing"
+	"time"
+)
+
+func withUserID(req *http.Request, userID int) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+}
+
+// enrollMFAForTest signs up test@example.com, enables MFA and returns the TOTP
+// secret and recovery codes.
+func enrollMFAForTest(t *testing.T) (string, []string) {
+	t.Helper()
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+
+	signinForTest(t)
+
+	setupRR := httptest.NewRecorder()
+	SetupMFA(setupRR, withUserID(httptest.NewRequest(http.MethodPost, "/api/mfa/setup", nil), 1))
+	if setupRR.Code != http.StatusOK {
+		t.Fatalf("expected setup status 200, got %d: %s", setupRR.Code, setupRR.Body.String())
+	}
+	var setup map[string]string
+	if err := json.NewDecoder(setupRR.Body).Decode(&setup); err != nil {
+		t.Fatalf("failed to decode setup response: %v", err)
+	}
+	if setup["secret"] == "" || setup["provisioning_uri"] == "" {
+		t.Fatalf("expected secret and provisioning URI, got %v", setup)
+	}
+
+	code, _ := utils.TOTPCode(setup["secret"], utils.TOTPStep(time.Now()))
+	confirmRR := httptest.NewRecorder()
+	ConfirmMFA(confirmRR, withUserID(httptest.NewRequest(http.MethodPost, "/api/mfa/confirm", bytes.NewBufferString(`{"code":"`+code+`"}`)), 1))
+	if confirmRR.Code != http.StatusOK {
+		t.Fatalf("expected confirm status 200, got %d: %s", confirmRR.Code, confirmRR.Body.String())
+	}
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := json.NewDecoder(confirmRR.Body).Decode(&confirmed); err != nil {
+		t.Fatalf("failed to decode confirm response: %v", err)
+	}
+	if len(confirmed.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", recoveryCodeCount, len(confirmed.RecoveryCodes))
+	}
+	return setup["secret"], confirmed.RecoveryCodes
+}
+
+func mfaChallengeForTest(t *testing.T) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	Signin(rr, httptest.NewRequest(http.MethodPost, "/api/login", bytes.NewBufferString(`{"email":"test@example.com","password":"password123"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected signin status 200, got %d", rr.Code)
+	}
+	if len(rr.Result().Cookies()) != 0 {
+		t.Fatal("expected no session cookies before the second factor")
+	}
+	var response map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode signin response: %v", err)
+	}
+	if response["mfa_required"] != true || response["token"] != nil {
+		t.Fatalf("expected an MFA challenge instead of a session, got %v", response)
+	}
+	return response["mfa_token"].(string)
+}
+
+func verifyMFALoginForTest(challenge, code string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"mfa_token":%q,"code":%q}`, challenge, code)
+	rr := httptest.NewRecorder()
+	VerifyMFALogin(rr, httptest.NewRequest(http.MethodPost, "/api/login/mfa", bytes.NewBufferString(body)))
+	return rr
+}
+
+func TestMFALoginRequiresSecondFactor(t *testing.T) {
+	setupTestDB(t)
+
+	secret, recoveryCodes := enrollMFAForTest(t)
+
+	// The confirmation code's step is spent, so use the next one.
+	nextCode, _ := utils.TOTPCode(secret, utils.TOTPStep(time.Now())+1)
+	rr := verifyMFALoginForTest(mfaChallengeForTest(t), nextCode)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected MFA login status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var session map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&session); err != nil {
+		t.Fatalf("failed to decode MFA login response: %v", err)
+	}
+	if status := protectedStatusForTest(session["token"]); status != http.StatusOK {
+		t.Fatalf("expected access token from MFA login to be accepted, got %d", status)
+	}
+
+	if rr := verifyMFALoginForTest(mfaChallengeForTest(t), nextCode); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected replayed TOTP code to be rejected, got %d", rr.Code)
+	}
+
+	if rr := verifyMFALoginForTest(mfaChallengeForTest(t), recoveryCodes[0]); rr.Code != http.StatusOK {
+		t.Fatalf("expected recovery code to be accepted, got %d", rr.Code)
+	}
+	if rr := verifyMFALoginForTest(mfaChallengeForTest(t), recoveryCodes[0]); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected used recovery code to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestMFAChallengeCannotBeUsedAsAccessToken(t *testing.T) {
+	setupTestDB(t)
+
+	enrollMFAForTest(t)
+	challenge := mfaChallengeForTest(t)
+
+	if status := protectedStatusForTest(challenge); status == http.StatusOK {
+		t.Fatal("expected MFA challenge token to be rejected by the auth middleware")
+	}
+}
+
+func TestVerifyMFALoginLocksOutAcrossChallenges(t *testing.T) {
+	setupTestDB(t)
+
+	_, recoveryCodes := enrollMFAForTest(t)
+
+	// Signing in again for a fresh challenge does not reset the count.
+	for i := 0; i < mfaMaxFailedAttempts; i++ {
+		if rr := verifyMFALoginForTest(mfaChallengeForTest(t), "000000"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected wrong code to be rejected, got %d", rr.Code)
+		}
+	}
+	var failures int
+	var lockedUntil time.Time
+	if err := database.DB.QueryRow(`SELECT mfa_failed_attempts, mfa_locked_until FROM users WHERE id = 1`).Scan(&failures, &lockedUntil); err != nil {
+		t.Fatalf("failed to read lockout: %v", err)
+	}
+	if failures != mfaMaxFailedAttempts || lockedUntil.Before(time.Now().Add(mfaLockoutBase-time.Minute)) {
+		t.Fatalf("expected the user to be locked out for %v, got %d failures until %v", mfaLockoutBase, failures, lockedUntil)
+	}
+
+	rr := httptest.NewRecorder()
+	Signin(rr, httptest.NewRequest(http.MethodPost, "/api/login", bytes.NewBufferString(`{"email":"test@example.com","password":"password123"}`)))
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected signin to be refused while locked out, got %d", rr.Code)
+	}
+
+	// The lockout is stored with the user, so a challenge issued before it
+	// cannot be used either, even with a valid code.
+	if _, err := database.DB.Exec(`UPDATE users SET mfa_locked_until = NULL WHERE id = 1`); err != nil {
+		t.Fatalf("failed to lift lockout: %v", err)
+	}
+	challenge := mfaChallengeForTest(t)
+	if _, err := database.DB.Exec(`UPDATE users SET mfa_locked_until = ? WHERE id = 1`, lockedUntil); err != nil {
+		t.Fatalf("failed to restore lockout: %v", err)
+	}
+	if rr := verifyMFALoginForTest(challenge, recoveryCodes[0]); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected a valid code to be refused while locked out, got %d", rr.Code)
+	}
+
+	if _, err := database.DB.Exec(`UPDATE users SET mfa_locked_until = ? WHERE id = 1`, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to expire lockout: %v", err)
+	}
+	if rr := verifyMFALoginForTest(challenge, recoveryCodes[0]); rr.Code != http.StatusOK {
+		t.Fatalf("expected login once the lockout expires, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := verifyMFALoginForTest(challenge, recoveryCodes[1]); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a spent challenge to be rejected, got %d", rr.Code)
+	}
+	if err := database.DB.QueryRow(`SELECT mfa_failed_attempts FROM users WHERE id = 1`).Scan(&failures); err != nil || failures != 0 {
+		t.Fatalf("expected a completed login to clear the failures, got %d (err=%v)", failures, err)
+	}
+	if rr := verifyMFALoginForTest(mfaChallengeForTest(t), recoveryCodes[1]); rr.Code != http.StatusOK {
+		t.Fatalf("expected a new challenge to be accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMFALockoutDuration(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  0,
+		4:  0,
+		5:  15 * time.Minute,
+		6:  30 * time.Minute,
+		8:  2 * time.Hour,
+		12: 24 * time.Hour,
+		40: 24 * time.Hour,
+	}
+	for failures, want := range cases {
+		if got := mfaLockoutDuration(failures); got != want {
+			t.Errorf("mfaLockoutDuration(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestUpdateWorkspaceMFAPolicyRequiresOwnerMFA(t *testing.T) {
+	setupTestDB(t)
+
+	signinForTest(t)
+	dbtest.Seed(t, "INSERT INTO workspaces (id, name, owner_id) VALUES (5, 'Secure', 1)")
+
+	updatePolicy := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/workspaces/5/mfa-policy", bytes.NewBufferString(`{"require_mfa":true}`))
+		WorkspacesRouter(rr, withUserID(req, 1))
+		return rr
+	}
+
+	if rr := updatePolicy(); rr.Code != http.StatusConflict {
+		t.Fatalf("expected owner without MFA to be refused, got %d", rr.Code)
+	}
+
+	enrollMFAForTest(t)
+	rr := updatePolicy()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected policy update status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var workspace map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&workspace); err != nil {
+		t.Fatalf("failed to decode workspace: %v", err)
+	}
+	if workspace["require_mfa"] != true {
+		t.Fatalf("expected workspace to require MFA, got %v", workspace)
+	}
+}
+
+func TestOwnerMFAGracePeriod(t *testing.T) {
+	setupCollaborationTestDB(t)
+	seedWorkspaceCollaborationData(t)
+
+	var invitationID int64
+	if err := database.DB.QueryRow(
+		`INSERT INTO invitations (workspace_id, email, token, role, expires_at, created_by)
+		 VALUES (10, 'invitee@example.com', 'token-1', 'member', ?, 1) RETURNING id`,
+		time.Now().Add(24*time.Hour),
+	).Scan(&invitationID); err != nil {
+		t.Fatalf("failed to seed invitation: %v", err)
+	}
+	deadline := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	if _, err := database.DB.Exec("UPDATE users SET mfa_required_by = ? WHERE id = 1", deadline); err != nil {
+		t.Fatalf("failed to expire the grace period: %v", err)
+	}
+
+	statusRR := httptest.NewRecorder()
+	MFAStatus(statusRR, withUserID(httptest.NewRequest(http.MethodGet, "/api/mfa", nil), 1))
+	var status struct {
+		Enabled    bool      `json:"enabled"`
+		RequiredBy time.Time `json:"required_by"`
+	}
+	if err := json.NewDecoder(statusRR.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode MFA status: %v", err)
+	}
+	if status.Enabled || !status.RequiredBy.Equal(deadline) {
+		t.Fatalf("expected status to report the owner's deadline %v, got %+v", deadline, status)
+	}
+
+	cancelRR := httptest.NewRecorder()
+	CancelInvitation(cancelRR, requestWithUser(http.MethodDelete, "/api/invitations/"+strconvFormatInt(invitationID), nil, 1, "owner@example.com"))
+	if cancelRR.Code != http.StatusForbidden {
+		t.Fatalf("expected owner without MFA to be refused after the grace period, got %d", cancelRR.Code)
+	}
+}
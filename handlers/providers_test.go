@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"sentinent-backend/services"
+	"testing"
+
+	_ "sentinent-backend/services/providers/github"
+	_ "sentinent-backend/services/providers/gmail"
+	_ "sentinent-backend/services/providers/jira"
+	_ "sentinent-backend/services/providers/slack"
+)
+
+func TestProvidersAreRegisteredInNameOrder(t *testing.T) {
+	var names []string
+	for _, provider := range services.Providers() {
+		names = append(names, provider.Name())
+	}
+
+	want := []string{"github", "gmail", "jira", "slack"}
+	if len(names) != len(want) {
+		t.Fatalf("expected providers %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected providers %v, got %v", want, names)
+		}
+	}
+
+	if gmail, _ := services.LookupProvider("gmail"); gmail.Scope() != services.ScopeUser {
+		t.Fatal("expected Gmail to be user-scoped")
+	}
+}
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sentinent-backend/middleware"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
+	"strconv"
+	"strings"
+)
+
+// ruleSourceTypes are the sources whose signals belong to a workspace, and so
+// can be matched by its rules.
+var ruleSourceTypes = []string{models.SourceTypeSlack, models.SourceTypeGitHub, models.SourceTypeJira}
+
+func ListRules(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := extractWorkspaceIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+
+	rules, err := repository.Default().Rules.List(workspaceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rules)
+}
+
+func CreateRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := extractWorkspaceIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role != models.RoleOwner && role != models.RoleMember {
+		http.Error(w, "Forbidden: Only members can manage rules", http.StatusForbidden)
+		return
+	}
+
+	req, err := decodeRuleRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, err := checkRuleReferences(workspaceID, req); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	store := repository.Default()
+	ruleID, err := store.Rules.Create(workspaceID, userID, *req)
+	if err != nil {
+		http.Error(w, "Failed to create rule", http.StatusInternalServerError)
+		return
+	}
+
+	rule, err := store.Rules.Get(workspaceID, ruleID)
+	if err != nil {
+		http.Error(w, "Failed to fetch rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+func GetRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, ruleID, err := extractRuleIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or rule ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+
+	rule, err := repository.Default().Rules.Get(workspaceID, ruleID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+// UpdateRule replaces a rule. Signals it already acted on are not revisited.
+func UpdateRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, ruleID, err := extractRuleIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or rule ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role != models.RoleOwner && role != models.RoleMember {
+		http.Error(w, "Forbidden: Only members can manage rules", http.StatusForbidden)
+		return
+	}
+
+	req, err := decodeRuleRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, err := checkRuleReferences(workspaceID, req); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	store := repository.Default()
+	err = store.Rules.Update(workspaceID, ruleID, *req)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update rule", http.StatusInternalServerError)
+		return
+	}
+
+	rule, err := store.Rules.Get(workspaceID, ruleID)
+	if err != nil {
+		http.Error(w, "Failed to fetch rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+func DeleteRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, ruleID, err := extractRuleIDs(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace or rule ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role != models.RoleOwner && role != models.RoleMember {
+		http.Error(w, "Forbidden: Only members can manage rules", http.StatusForbidden)
+		return
+	}
+
+	err = repository.Default().Rules.Delete(workspaceID, ruleID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DryRunRule reports which existing signals a rule would match. It serves
+// both /rules/dry-run, which takes conditions in the body, and
+// /rules/{ruleId}/dry-run, which uses a saved rule's conditions.
+func DryRunRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := splitPath(r.URL.Path)
+	workspaceID, err := extractWorkspaceIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+
+	var conditions models.RuleConditions
+	if len(parts) == 6 {
+		_, ruleID, err := extractRuleIDs(r.URL.Path)
+		if err != nil {
+			http.Error(w, "Invalid workspace or rule ID", http.StatusBadRequest)
+			return
+		}
+		rule, err := repository.Default().Rules.Get(workspaceID, ruleID)
+		if err == repository.ErrNotFound {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to fetch rule", http.StatusInternalServerError)
+			return
+		}
+		conditions = rule.Conditions
+	} else {
+		var req models.SignalRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if conditions, err = cleanRuleConditions(req.Conditions); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := services.DryRunSignalRule(userID, workspaceID, conditions)
+	if err != nil {
+		http.Error(w, "Failed to run rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func decodeRuleRequest(r *http.Request) (*models.SignalRuleRequest, error) {
+	var req models.SignalRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, errors.New("Rule name is required")
+	}
+
+	conditions, err := cleanRuleConditions(req.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	req.Conditions = conditions
+
+	var labels []string
+	for _, label := range cleanList(req.Actions.AddLabels) {
+		if !containsLabel(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	req.Actions.AddLabels = labels
+	req.Actions.Priority = strings.ToLower(strings.TrimSpace(req.Actions.Priority))
+	if req.Actions.Priority != "" && !models.IsSignalPriority(req.Actions.Priority) {
+		return nil, errors.New("Invalid priority")
+	}
+	if req.Actions.Empty() {
+		return nil, errors.New("A rule needs at least one action")
+	}
+	return &req, nil
+}
+
+// cleanRuleConditions trims the conditions and checks that they are usable:
+// at least one is set, source types are known and patterns compile.
+func cleanRuleConditions(conditions models.RuleConditions) (models.RuleConditions, error) {
+	conditions.SourceTypes = cleanList(conditions.SourceTypes)
+	for i, sourceType := range conditions.SourceTypes {
+		conditions.SourceTypes[i] = strings.ToLower(sourceType)
+		if !containsLabel(ruleSourceTypes, sourceType) {
+			return conditions, fmt.Errorf("Unknown source type: %s", sourceType)
+		}
+	}
+	conditions.Authors = cleanList(conditions.Authors)
+	conditions.GitHubLabels = cleanList(conditions.GitHubLabels)
+	conditions.JiraPriorities = cleanList(conditions.JiraPriorities)
+	conditions.SlackChannels = cleanList(conditions.SlackChannels)
+	if conditions.Empty() {
+		return conditions, errors.New("A rule needs at least one condition")
+	}
+	if _, err := services.NewRuleMatcher(conditions); err != nil {
+		return conditions, err
+	}
+	return conditions, nil
+}
+
+// checkRuleReferences checks that a rule's assignee and decision belong to
+// the workspace.
+func checkRuleReferences(workspaceID int, req *models.SignalRuleRequest) (int, error) {
+	if req.Actions.AssigneeID != nil {
+		role, err := middleware.GetWorkspaceRole(*req.Actions.AssigneeID, workspaceID)
+		if err != nil {
+			return http.StatusInternalServerError, errors.New("Internal server error")
+		}
+		if role == "" {
+			return http.StatusBadRequest, errors.New("Assignee must be a member of this workspace")
+		}
+	}
+	if req.Actions.DecisionID != nil {
+		decision, err := repository.Default().Decisions.Get(workspaceID, *req.Actions.DecisionID)
+		if err == repository.ErrNotFound {
+			return http.StatusBadRequest, errors.New("Decision not found in this workspace")
+		}
+		if err != nil {
+			return http.StatusInternalServerError, errors.New("Failed to fetch decision")
+		}
+		if decision.Status == models.DecisionStatusClosed {
+			return http.StatusBadRequest, errors.New("Decision is closed")
+		}
+	}
+	return 0, nil
+}
+
+// cleanList trims each value and drops blank ones.
+func cleanList(values []string) []string {
+	var cleaned []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			cleaned = append(cleaned, value)
+		}
+	}
+	return cleaned
+}
+
+func containsLabel(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func extractRuleIDs(path string) (workspaceID int, ruleID int, err error) {
+	parts := splitPath(path)
+	if len(parts) < 5 || parts[0] != "api" || parts[1] != "workspaces" || parts[3] != "rules" {
+		return 0, 0, strconv.ErrSyntax
+	}
+
+	workspaceID, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ruleID, err = strconv.Atoi(parts[4])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return workspaceID, ruleID, nil
+}
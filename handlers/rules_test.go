@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"strconv"
+	"testing"
+)
+
+func TestRuleHandlers(t *testing.T) {
+	setupSignalsTestDB(t)
+
+	for _, statement := range []string{
+		`INSERT INTO users (id, email, password) VALUES (2, 'viewer@example.com', 'x'), (3, 'outsider@example.com', 'x')`,
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (7, 2, 'viewer')`,
+		`INSERT INTO decisions (id, workspace_id, user_id, title, status) VALUES
+			(1, 7, 1, 'Ship v2?', 'OPEN'),
+			(2, 7, 1, 'Old question', 'CLOSED'),
+			(3, 8, 1, 'Elsewhere', 'OPEN')`,
+	} {
+		if _, err := database.DB.Exec(statement); err != nil {
+			t.Fatalf("failed to seed rule fixtures: %v", err)
+		}
+	}
+
+	send := func(method, target, body string, userID int) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		WorkspacesRouter(rr, requestWithUser(method, target, []byte(body), userID, "user@example.com"))
+		return rr
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing name", `{"conditions":{"source_types":["github"]},"actions":{"archive":true}}`, http.StatusBadRequest},
+		{"no conditions", `{"name":"All","actions":{"archive":true}}`, http.StatusBadRequest},
+		{"no actions", `{"name":"None","conditions":{"source_types":["github"]}}`, http.StatusBadRequest},
+		{"unknown source", `{"name":"Mail","conditions":{"source_types":["gmail"]},"actions":{"archive":true}}`, http.StatusBadRequest},
+		{"bad pattern", `{"name":"Bad","conditions":{"title_pattern":"("},"actions":{"archive":true}}`, http.StatusBadRequest},
+		{"bad priority", `{"name":"P","conditions":{"source_types":["jira"]},"actions":{"priority":"p0"}}`, http.StatusBadRequest},
+		{"outsider assignee", `{"name":"A","conditions":{"source_types":["jira"]},"actions":{"assignee_id":3}}`, http.StatusBadRequest},
+		{"closed decision", `{"name":"D","conditions":{"source_types":["jira"]},"actions":{"decision_id":2}}`, http.StatusBadRequest},
+		{"other workspace decision", `{"name":"D","conditions":{"source_types":["jira"]},"actions":{"decision_id":3}}`, http.StatusBadRequest},
+	} {
+		if rr := send(http.MethodPost, "/api/workspaces/7/rules", tc.body, 1); rr.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.name, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	valid := `{"name":" Signals ","conditions":{"source_types":["GitHub"],"title_pattern":"One$"},
+		"actions":{"add_labels":["triage"," Triage ",""],"priority":"HIGH","assignee_id":2,"decision_id":1}}`
+	if rr := send(http.MethodPost, "/api/workspaces/7/rules", valid, 2); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a viewer to be refused, got %d", rr.Code)
+	}
+	rr := send(http.MethodPost, "/api/workspaces/7/rules", valid, 1)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var rule models.SignalRule
+	if err := json.NewDecoder(rr.Body).Decode(&rule); err != nil {
+		t.Fatalf("failed to decode rule: %v", err)
+	}
+	if rule.Name != "Signals" || !rule.Enabled || rule.Conditions.SourceTypes[0] != models.SourceTypeGitHub {
+		t.Fatalf("expected the rule to be cleaned up, got %+v", rule)
+	}
+	if len(rule.Actions.AddLabels) != 1 || rule.Actions.Priority != models.SignalPriorityHigh {
+		t.Fatalf("expected deduplicated labels and a lowercase priority, got %+v", rule.Actions)
+	}
+	rulePath := "/api/workspaces/7/rules/" + strconv.Itoa(rule.ID)
+
+	if rr := send(http.MethodGet, "/api/workspaces/7/rules", "", 2); rr.Code != http.StatusOK {
+		t.Fatalf("expected a viewer to list rules, got %d", rr.Code)
+	} else {
+		var rules []models.SignalRule
+		if err := json.NewDecoder(rr.Body).Decode(&rules); err != nil || len(rules) != 1 {
+			t.Fatalf("expected one rule, got %+v (err=%v)", rules, err)
+		}
+	}
+	if rr := send(http.MethodGet, "/api/workspaces/7/rules", "", 3); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected an outsider to be refused, got %d", rr.Code)
+	}
+	if rr := send(http.MethodGet, "/api/workspaces/8/rules/"+strconv.Itoa(rule.ID), "", 1); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected another workspace's rule to be missing, got %d", rr.Code)
+	}
+
+	rr = send(http.MethodPost, rulePath+"/dry-run", "", 2)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from a saved dry run, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result models.RuleDryRunResponse
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode dry run: %v", err)
+	}
+	if result.Scanned != 2 || result.Matched != 1 || result.Signals[0].ID != 1 {
+		t.Fatalf("expected the rule to match signal 1 only, got %+v", result)
+	}
+	rr = send(http.MethodPost, "/api/workspaces/7/rules/dry-run", `{"conditions":{"title_pattern":"^Signal"}}`, 1)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from an ad hoc dry run, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil || result.Matched != 2 {
+		t.Fatalf("expected both signals to match, got %+v (err=%v)", result, err)
+	}
+	if rr := send(http.MethodPost, "/api/workspaces/7/rules/dry-run", `{"conditions":{}}`, 1); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected empty conditions to be rejected, got %d", rr.Code)
+	}
+
+	update := `{"name":"Signals","enabled":false,"conditions":{"authors":["octocat"]},"actions":{"archive":true}}`
+	rr = send(http.MethodPatch, rulePath, update, 1)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from update, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var updated models.SignalRule
+	if err := json.NewDecoder(rr.Body).Decode(&updated); err != nil || updated.Enabled || !updated.Actions.Archive || updated.Actions.AssigneeID != nil {
+		t.Fatalf("expected the update to replace the rule, got %+v (err=%v)", updated, err)
+	}
+
+	if rr := send(http.MethodDelete, rulePath, "", 2); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a viewer to be refused, got %d", rr.Code)
+	}
+	if rr := send(http.MethodDelete, rulePath, "", 1); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 from delete, got %d", rr.Code)
+	}
+	if rr := send(http.MethodGet, rulePath, "", 1); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the rule to be gone, got %d", rr.Code)
+	}
+}
+
+func TestSignalsHandlerFiltersByLabelAndPriority(t *testing.T) {
+	setupSignalsTestDB(t)
+
+	if _, err := database.DB.Exec(`UPDATE signals SET labels = '["triage"]', priority = 'high' WHERE id = 2`); err != nil {
+		t.Fatalf("failed to label signal: %v", err)
+	}
+
+	for _, target := range []string{"/api/signals?label=Triage", "/api/signals?priority=high"} {
+		rr := httptest.NewRecorder()
+		SignalsHandler(rr, signalRequestWithUser(http.MethodGet, target))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", target, rr.Code)
+		}
+		var page models.SignalListResponse
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatalf("failed to decode page: %v", err)
+		}
+		if page.Total != 1 || page.Signals[0].ID != 2 || page.Signals[0].Priority != models.SignalPriorityHigh {
+			t.Fatalf("%s: expected signal 2 only, got %+v", target, page)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	SignalsHandler(rr, signalRequestWithUser(http.MethodGet, "/api/signals?priority=p0"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown priority to be rejected, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sentinent-backend/middleware"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// SearchWorkspace runs a full-text search over the caller's signals and the
+// workspace's decisions: GET /api/workspaces/{id}/search?q=...
+//
+// The query is a list of words or "quoted phrases", all of which must match.
+// A trailing * makes a prefix match. Field prefixes narrow the search:
+// title:, author:, source: (a signal source type) and type: (signal or
+// decision).
+func SearchWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := extractWorkspaceIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+
+	rawQuery := strings.TrimSpace(r.URL.Query().Get("q"))
+	query := parseSearchQuery(rawQuery)
+	if !query.HasText() {
+		http.Error(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+	if query.Type != "" && query.Type != models.SearchTypeSignal && query.Type != models.SearchTypeDecision {
+		http.Error(w, "Invalid type filter", http.StatusBadRequest)
+		return
+	}
+
+	query.Limit = defaultSearchLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > maxSearchLimit {
+			limit = maxSearchLimit
+		}
+		query.Limit = limit
+	}
+
+	results, err := repository.Default().Search.Search(userID, workspaceID, query)
+	if err != nil {
+		http.Error(w, "Failed to search workspace", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   rawQuery,
+		"results": results,
+	})
+}
+
+// parseSearchQuery splits a search box string into terms and field filters.
+// Unknown prefixes are kept as ordinary terms.
+func parseSearchQuery(raw string) models.SearchQuery {
+	var query models.SearchQuery
+	for _, token := range splitSearchTokens(raw) {
+		field, value, hasField := strings.Cut(token, ":")
+		value = strings.TrimSpace(value)
+		if hasField && value != "" {
+			switch strings.ToLower(field) {
+			case "title":
+				query.TitleTerms = appendSearchTerm(query.TitleTerms, value)
+				continue
+			case "author":
+				query.AuthorTerms = appendSearchTerm(query.AuthorTerms, value)
+				continue
+			case "source":
+				query.Source = strings.ToLower(value)
+				continue
+			case "type":
+				query.Type = strings.TrimSuffix(strings.ToLower(value), "s")
+				continue
+			}
+		}
+		query.Terms = appendSearchTerm(query.Terms, token)
+	}
+	return query
+}
+
+func appendSearchTerm(terms []string, term string) []string {
+	if strings.TrimSpace(strings.TrimRight(term, "*")) == "" {
+		return terms
+	}
+	return append(terms, term)
+}
+
+// splitSearchTokens splits on whitespace outside double quotes and drops the
+// quotes, so `author:"Jane Doe" launch` yields `author:Jane Doe` and `launch`.
+func splitSearchTokens(raw string) []string {
+	tokens := make([]string, 0)
+	var current strings.Builder
+	inQuotes := false
+	for _, char := range raw {
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+		case unicode.IsSpace(char) && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(char)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
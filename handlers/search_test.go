@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/models"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	query := parseSearchQuery(`launch "release notes" author:"Jane Doe" source:GitHub title:roadmap type:decisions migr* foo:bar *`)
+
+	expected := models.SearchQuery{
+		Terms:       []string{"launch", "release notes", "migr*", "foo:bar"},
+		TitleTerms:  []string{"roadmap"},
+		AuthorTerms: []string{"Jane Doe"},
+		Source:      "github",
+		Type:        models.SearchTypeDecision,
+	}
+	if !reflect.DeepEqual(query, expected) {
+		t.Fatalf("unexpected parsed query:\n got %+v\nwant %+v", query, expected)
+	}
+}
+
+func TestSearchWorkspaceValidatesRequest(t *testing.T) {
+	setupTestDB(t)
+
+	dbtest.Seed(t,
+		"INSERT INTO users (id, email, password) VALUES (1, 'owner@example.com', 'x'), (2, 'outsider@example.com', 'x')",
+		"INSERT INTO workspaces (id, name, owner_id) VALUES (4, 'Team', 1)",
+	)
+
+	search := func(userID int, query string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/workspaces/4/search?q="+url.QueryEscape(query), nil)
+		rr := httptest.NewRecorder()
+		WorkspacesRouter(rr, withUserID(req, userID))
+		return rr.Code
+	}
+
+	if status := search(2, "launch"); status != http.StatusForbidden {
+		t.Fatalf("expected non-member to be rejected, got %d", status)
+	}
+	if status := search(1, "source:github"); status != http.StatusBadRequest {
+		t.Fatalf("expected filter-only query to be rejected, got %d", status)
+	}
+	if status := search(1, "launch type:comment"); status != http.StatusBadRequest {
+		t.Fatalf("expected unknown type filter to be rejected, got %d", status)
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sentinent-backend/middleware"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	// The refresh cookie is scoped to /api so it reaches both the refresh and
+	// logout endpoints.
+	refreshTokenCookieName = "refresh_token"
+	refreshTokenCookiePath = "/api"
+)
+
+var errJwtKeyMissing = errors.New("jwt signing key is not configured")
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token and rotates the
+// refresh token. Replaying a refresh token that was already rotated revokes the
+// whole session, since it means the token was copied.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	refreshToken := readRefreshToken(r)
+	if refreshToken == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	store := repository.Default()
+	tokenHash := hashRefreshToken(refreshToken)
+	session, err := store.Sessions.GetByRefreshTokenHash(tokenHash)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	if session.RefreshTokenHash != tokenHash {
+		_ = store.Sessions.Revoke(session.ID, now)
+		clearSessionCookies(w)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if !session.Active(now) {
+		clearSessionCookies(w)
+		http.Error(w, "Session expired", http.StatusUnauthorized)
+		return
+	}
+
+	newRefreshToken, err := generateSecureToken()
+	if err != nil {
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+	refreshExpiresAt := now.Add(refreshTokenTTL)
+	err = store.Sessions.Rotate(session.ID, tokenHash, hashRefreshToken(newRefreshToken), refreshExpiresAt, now)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+
+	email, err := store.Users.Email(session.UserID)
+	if err != nil {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	writeSessionTokens(w, session.UserID, email, session.ID, newRefreshToken, refreshExpiresAt)
+}
+
+// LogoutAll revokes every session of the current user.
+func LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := repository.Default().Sessions.RevokeAllForUser(userID, time.Now()); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	clearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession creates a session for a freshly authenticated user and writes
+// its tokens to the response.
+func startSession(w http.ResponseWriter, r *http.Request, userID int, email string) {
+	if len(utils.JwtKey) == 0 {
+		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	refreshToken, err := generateSecureToken()
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	refreshExpiresAt := time.Now().Add(refreshTokenTTL)
+	sessionID, err := repository.Default().Sessions.Create(models.Session{
+		UserID:           userID,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		UserAgent:        r.UserAgent(),
+		ExpiresAt:        refreshExpiresAt,
+	})
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	writeSessionTokens(w, userID, email, sessionID, refreshToken, refreshExpiresAt)
+}
+
+func writeSessionTokens(w http.ResponseWriter, userID int, email string, sessionID int, refreshToken string, refreshExpiresAt time.Time) {
+	accessToken, accessExpiresAt, err := signAccessToken(userID, email, sessionID)
+	if err == errJwtKeyMissing {
+		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    accessToken,
+		Expires:  accessExpiresAt,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   utils.IsProductionEnv(),
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookieName,
+		Value:    refreshToken,
+		Expires:  refreshExpiresAt,
+		Path:     refreshTokenCookiePath,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   utils.IsProductionEnv(),
+	})
+
+	// Also return JSON for non-browser clients
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_at":    accessExpiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func signAccessToken(userID int, email string, sessionID int) (string, time.Time, error) {
+	if len(utils.JwtKey) == 0 {
+		return "", time.Time{}, errJwtKeyMissing
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(accessTokenTTL)
+	claims := &models.Claims{
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(utils.JwtKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
+
+// revokeRequestSession revokes the session behind the request's refresh token
+// or, failing that, the session named in its access token.
+func revokeRequestSession(r *http.Request) error {
+	store := repository.Default()
+	if refreshToken := readRefreshTokenCookie(r); refreshToken != "" {
+		session, err := store.Sessions.GetByRefreshTokenHash(hashRefreshToken(refreshToken))
+		if err == nil {
+			return store.Sessions.Revoke(session.ID, time.Now())
+		}
+		if err != repository.ErrNotFound {
+			return err
+		}
+	}
+
+	tokenString := ""
+	if cookie, err := r.Cookie("token"); err == nil {
+		tokenString = cookie.Value
+	} else if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		tokenString = bearer
+	}
+	if tokenString == "" || len(utils.JwtKey) == 0 {
+		return nil
+	}
+
+	// An expired access token still identifies the session to end.
+	claims := &models.Claims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return utils.JwtKey, nil
+	}, jwt.WithoutClaimsValidation(), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()})); err != nil || claims.SessionID == 0 {
+		return nil
+	}
+	return store.Sessions.Revoke(claims.SessionID, time.Now())
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, cookie := range []struct{ name, path string }{
+		{"token", "/"},
+		{refreshTokenCookieName, refreshTokenCookiePath},
+	} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			HttpOnly: true,
+			MaxAge:   -1,
+			SameSite: http.SameSiteLaxMode,
+			Secure:   utils.IsProductionEnv(),
+		})
+	}
+}
+
+func readRefreshToken(r *http.Request) string {
+	if refreshToken := readRefreshTokenCookie(r); refreshToken != "" {
+		return refreshToken
+	}
+
+	var req refreshTokenRequest
+	if r.Body == nil || json.NewDecoder(r.Body).Decode(&req) != nil {
+		return ""
+	}
+	return strings.TrimSpace(req.RefreshToken)
+}
+
+func readRefreshTokenCookie(r *http.Request) string {
+	cookie, err := r.Cookie(refreshTokenCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sentinent-backend/middleware"
+	"sentinent-backend/models"
+	"sentinent-backend/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signalStreamHeartbeat is how often an idle stream sends a comment, so that
+// proxies keep it open. Membership and the caller's session are checked again
+// at the same pace.
+var signalStreamHeartbeat = 25 * time.Second
+
+// StreamSignals pushes the workspace's signal events to the caller as
+// Server-Sent Events. A client that reconnects with Last-Event-ID, or the
+// last_event_id query parameter, receives the events it missed. When those
+// are no longer available the stream starts with a reset event, and the
+// client should reload its signals.
+func StreamSignals(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := extractWorkspaceIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
+	}
+
+	lastEventID := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if lastEventID == "" {
+		lastEventID = strings.TrimSpace(r.URL.Query().Get("last_event_id"))
+	}
+	var resumeAfter int64 = -1
+	if lastEventID != "" {
+		resumeAfter, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || resumeAfter < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	bus := services.DefaultSignalBus()
+	sub := bus.Subscribe(workspaceID, userID)
+	defer bus.Unsubscribe(sub)
+
+	controller := http.NewResponseController(w)
+	// Streams outlive any server write timeout.
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if resumeAfter >= 0 {
+		missed, complete := bus.Replay(sub, resumeAfter)
+		if !complete {
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", sub.LastID); err != nil {
+				return
+			}
+		}
+		for _, event := range missed {
+			if err := writeSignalEvent(w, event); err != nil {
+				return
+			}
+		}
+	}
+	if err := controller.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(signalStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			// A closed channel means the stream fell behind; the client
+			// reconnects and resumes from its last event.
+			if !ok {
+				return
+			}
+			if err := writeSignalEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+			if err != nil || role == "" {
+				return
+			}
+			if valid, err := middleware.SessionStillValid(r.Context()); err != nil || !valid {
+				return
+			}
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeSignalEvent(w io.Writer, event models.SignalEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/middleware"
+	"sentinent-backend/models"
+	"sentinent-backend/services"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// readSSEEvent reads one event from a stream, skipping comments.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if event.event != "" {
+				return event
+			}
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamSignals(t *testing.T) {
+	setupSignalsTestDB(t)
+	database.DB.SetMaxOpenConns(1)
+	original := signalStreamHeartbeat
+	signalStreamHeartbeat = 10 * time.Millisecond
+	defer func() { signalStreamHeartbeat = original }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := strconv.Atoi(r.Header.Get("X-Test-User"))
+		ctx := context.WithValue(r.Context(), middleware.UserIDKey, userID)
+		WorkspacesRouter(w, r.WithContext(ctx))
+	}))
+	defer server.Close()
+
+	open := func(userID int, lastEventID string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/workspaces/7/signals/stream", nil)
+		if err != nil {
+			t.Fatalf("failed to build request: %v", err)
+		}
+		req.Header.Set("X-Test-User", strconv.Itoa(userID))
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		return resp
+	}
+
+	if resp := open(2, ""); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a non-member to be refused, got %d", resp.StatusCode)
+	} else {
+		resp.Body.Close()
+	}
+	if resp := open(1, "soon"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an invalid Last-Event-ID to be rejected, got %d", resp.StatusCode)
+	} else {
+		resp.Body.Close()
+	}
+
+	resp := open(1, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	bus := services.DefaultSignalBus()
+	bus.Publish(models.SignalEvent{Type: models.SignalEventStatusChanged, WorkspaceID: 7, SignalID: 1, UserID: 2, Status: "read"})
+	bus.Publish(models.SignalEvent{Type: models.SignalEventCreated, WorkspaceID: 8, SignalID: 3})
+	bus.Publish(models.SignalEvent{Type: models.SignalEventArchived, WorkspaceID: 7, SignalID: 2, Status: "archived"})
+
+	archived := readSSEEvent(t, bufio.NewReader(resp.Body))
+	resp.Body.Close()
+	var payload models.SignalEvent
+	if err := json.Unmarshal([]byte(archived.data), &payload); err != nil {
+		t.Fatalf("failed to decode event data: %v", err)
+	}
+	if archived.event != models.SignalEventArchived || archived.id != strconv.FormatInt(payload.ID, 10) || payload.SignalID != 2 {
+		t.Fatalf("expected only the workspace's shared archive event, got %+v", archived)
+	}
+
+	// Reconnecting just before the archive replays it.
+	resp = open(1, strconv.FormatInt(payload.ID-1, 10))
+	if replayed := readSSEEvent(t, bufio.NewReader(resp.Body)); replayed.id != archived.id {
+		t.Fatalf("expected the missed event to be replayed, got %+v", replayed)
+	}
+	resp.Body.Close()
+
+	resp = open(1, strconv.FormatInt(payload.ID+1000, 10))
+	if reset := readSSEEvent(t, bufio.NewReader(resp.Body)); reset.event != "reset" || reset.id != archived.id {
+		t.Fatalf("expected a reset for an unknown Last-Event-ID, got %+v", reset)
+	}
+	resp.Body.Close()
+
+	// The stream ends once the caller leaves the workspace.
+	resp = open(1, "")
+	defer resp.Body.Close()
+	if _, err := database.DB.Exec(`DELETE FROM workspace_members WHERE workspace_id = 7`); err != nil {
+		t.Fatalf("failed to remove member: %v", err)
+	}
+	dbtest.Seed(t, `INSERT INTO users (id, email, password) VALUES (99, 'new-owner@example.com', 'x')`)
+	if _, err := database.DB.Exec(`UPDATE workspaces SET owner_id = 99 WHERE id = 7`); err != nil {
+		t.Fatalf("failed to move ownership: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		reader := bufio.NewReader(resp.Body)
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the stream to close after the member left")
+	}
+}
+
+func TestStreamSignalsClosesAfterLogoutAll(t *testing.T) {
+	setupTestDB(t)
+	database.DB.SetMaxOpenConns(1)
+	original := signalStreamHeartbeat
+	signalStreamHeartbeat = 10 * time.Millisecond
+	defer func() { signalStreamHeartbeat = original }()
+
+	accessToken, _ := signinForTest(t)
+	dbtest.Seed(t, `INSERT INTO workspaces (id, name, owner_id) VALUES (7, 'Alpha', 1)`)
+
+	server := httptest.NewServer(middleware.AuthMiddleware(http.HandlerFunc(WorkspacesRouter)))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/workspaces/7/signals/stream", nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected an event stream, got %d", resp.StatusCode)
+	}
+
+	logout := httptest.NewRequest(http.MethodPost, "/api/logout/all", nil)
+	logout = logout.WithContext(context.WithValue(logout.Context(), middleware.UserIDKey, 1))
+	rr := httptest.NewRecorder()
+	LogoutAll(rr, logout)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected logout-all status 204, got %d", rr.Code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		reader := bufio.NewReader(resp.Body)
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the stream to close once its session was revoked")
+	}
+}
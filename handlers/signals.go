@@ -1,16 +1,26 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"sentinent-backend/database"
+	"net/url"
+	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	defaultSignalPageSize = 50
+	maxSignalPageSize     = 100
+	maxBulkSignalIDs      = 500
+)
+
 // GetSignals lists signals for a workspace with optional filtering
 func GetSignals(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserIDFromContext(r)
@@ -51,115 +61,123 @@ func GetSignals(w http.ResponseWriter, r *http.Request) {
 	}
 
 	workspaceID, err := strconv.Atoi(parts[0])
-	if err != nil {
+	if err != nil || workspaceID <= 0 {
 		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
 		return
 	}
 
-	// Parse query filters
-	filter := models.SignalFilter{
-		SourceType: r.URL.Query().Get("source_type"),
-		Status:     r.URL.Query().Get("status"),
-	}
-
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		filter.Limit, _ = strconv.Atoi(limitStr)
+	// Workspace signals are shared, so any member may list them.
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
-	if filter.Limit <= 0 || filter.Limit > 100 {
-		filter.Limit = 50 // Default limit
+	if role == "" {
+		http.Error(w, "Forbidden: Not a member of this workspace", http.StatusForbidden)
+		return
 	}
 
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		filter.Offset, _ = strconv.Atoi(offsetStr)
+	filter, err := parseSignalFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	filter.WorkspaceID = workspaceID
 
-	// Build query
-	query := `
-		SELECT s.id, s.user_id, s.workspace_id, s.source_type, s.source_id, s.external_id,
-		       s.title, s.content, s.author, COALESCE(ss.status, s.status) as status,
-		       s.received_at, s.created_at
-		FROM signals s
-		LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = ?
-		WHERE s.workspace_id = ? AND s.user_id = ?`
-	args := []interface{}{userID, workspaceID, userID}
-
-	if filter.SourceType != "" {
-		query += " AND s.source_type = ?"
-		args = append(args, filter.SourceType)
-	}
+	listSignals(w, userID, filter)
+}
 
-	if filter.Status != "" {
-		query += " AND COALESCE(ss.status, s.status) = ?"
-		args = append(args, filter.Status)
+// listSignals writes one page of signals, or a 400 for a stale or forged cursor.
+func listSignals(w http.ResponseWriter, userID int, filter models.SignalFilter) {
+	page, err := repository.Default().Signals.List(userID, filter)
+	if err == repository.ErrInvalidCursor {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
 	}
-
-	query += " ORDER BY s.received_at DESC LIMIT ? OFFSET ?"
-	args = append(args, filter.Limit, filter.Offset)
-
-	rows, err := database.DB.Query(query, args...)
 	if err != nil {
 		http.Error(w, "Failed to fetch signals", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	signals := make([]models.Signal, 0)
-	for rows.Next() {
-		var s models.Signal
-		var content sql.NullString
-		var author sql.NullString
-		var sourceID sql.NullString
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
 
-		err := rows.Scan(
-			&s.ID, &s.UserID, &s.WorkspaceID, &s.SourceType, &sourceID, &s.ExternalID,
-			&s.Title, &content, &author, &s.Status, &s.ReceivedAt, &s.CreatedAt,
-		)
-		if err != nil {
+// parseSignalFilter reads the signal listing query parameters. List values
+// are comma separated; since and until take RFC 3339 times or YYYY-MM-DD
+// dates, since inclusive and until exclusive.
+func parseSignalFilter(query url.Values) (models.SignalFilter, error) {
+	filter := models.SignalFilter{
+		SourceTypes:  splitListParam(query["source_type"]),
+		Status:       query.Get("status"),
+		Author:       strings.TrimSpace(query.Get("author")),
+		Repositories: splitListParam(query["repository"]),
+		ProjectKeys:  splitListParam(query["project_key"]),
+		Label:        strings.TrimSpace(query.Get("label")),
+		Priority:     strings.ToLower(strings.TrimSpace(query.Get("priority"))),
+		Cursor:       query.Get("cursor"),
+	}
+	if filter.Priority != "" && !models.IsSignalPriority(filter.Priority) {
+		return filter, errors.New("Invalid priority")
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		filter.Limit, _ = strconv.Atoi(limitStr)
+	}
+	if filter.Limit <= 0 || filter.Limit > maxSignalPageSize {
+		filter.Limit = defaultSignalPageSize
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		filter.Offset, _ = strconv.Atoi(offsetStr)
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := strings.TrimSpace(query.Get(bound.name))
+		if value == "" {
 			continue
 		}
-		s.Content = content.String
-		s.Author = author.String
-		s.SourceID = sourceID.String
-		signals = append(signals, s)
-	}
-
-	// Get total count
-	countQuery := "SELECT COUNT(*) FROM signals WHERE workspace_id = ? AND user_id = ?"
-	countArgs := []interface{}{workspaceID, userID}
-	if filter.Status != "" {
-		countQuery = `
-			SELECT COUNT(*)
-			FROM signals s
-			LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = ?
-			WHERE s.workspace_id = ? AND s.user_id = ?`
-		countArgs = []interface{}{userID, workspaceID, userID}
-	}
-
-	if filter.SourceType != "" {
-		if filter.Status != "" {
-			countQuery += " AND s.source_type = ?"
-		} else {
-			countQuery += " AND source_type = ?"
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			parsed, err = time.Parse("2006-01-02", value)
 		}
-		countArgs = append(countArgs, filter.SourceType)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s: use RFC 3339 or YYYY-MM-DD", bound.name)
+		}
+		*bound.target = &parsed
 	}
 
-	if filter.Status != "" {
-		countQuery += " AND COALESCE(ss.status, s.status) = ?"
-		countArgs = append(countArgs, filter.Status)
+	if value := query.Get("assignee_id"); value != "" {
+		assigneeID, err := strconv.Atoi(value)
+		if err != nil || assigneeID <= 0 {
+			return filter, errors.New("Invalid assignee_id")
+		}
+		filter.AssigneeID = assigneeID
 	}
 
-	var total int
-	err = database.DB.QueryRow(countQuery, countArgs...).Scan(&total)
-	if err != nil {
-		total = len(signals)
+	if value := query.Get("has_decision"); value != "" {
+		hasDecision, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("Invalid has_decision")
+		}
+		filter.HasDecision = &hasDecision
 	}
+	return filter, nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.SignalListResponse{
-		Signals: signals,
-		Total:   total,
-	})
+// splitListParam accepts both repeated and comma separated query values.
+func splitListParam(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
 }
 
 // GetSignal retrieves a single signal by ID
@@ -185,25 +203,9 @@ func GetSignal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var s models.Signal
-	var content sql.NullString
-	var author sql.NullString
-	var sourceID sql.NullString
-
-	err = database.DB.QueryRow(`
-		SELECT s.id, s.user_id, s.workspace_id, s.source_type, s.source_id, s.external_id,
-		       s.title, s.content, s.author, COALESCE(ss.status, s.status) as status,
-		       s.received_at, s.created_at
-		FROM signals s
-		LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = ?
-		WHERE s.id = ? AND s.user_id = ?`,
-		userID, signalID, userID,
-	).Scan(
-		&s.ID, &s.UserID, &s.WorkspaceID, &s.SourceType, &sourceID, &s.ExternalID,
-		&s.Title, &content, &author, &s.Status, &s.ReceivedAt, &s.CreatedAt,
-	)
-
-	if err == sql.ErrNoRows {
+	store := repository.Default()
+	s, err := store.Signals.Get(userID, signalID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Signal not found", http.StatusNotFound)
 		return
 	}
@@ -212,9 +214,11 @@ func GetSignal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.Content = content.String
-	s.Author = author.String
-	s.SourceID = sourceID.String
+	s.Decisions, err = store.Signals.LinkedDecisions(userID, signalID)
+	if err != nil {
+		http.Error(w, "Failed to fetch signal", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(s)
@@ -245,33 +249,17 @@ func MarkSignalAsRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify signal exists and belongs to user
-	var exists bool
-	err = database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM signals WHERE id = ? AND user_id = ?)",
-		signalID, userID,
-	).Scan(&exists)
-
-	if err != nil || !exists {
+	err = repository.Default().Signals.SetStatus(userID, signalID, models.SignalStatusRead)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Signal not found", http.StatusNotFound)
 		return
 	}
-
-	// Upsert signal status
-	_, err = database.DB.Exec(`
-		INSERT INTO signal_status (signal_id, user_id, status, updated_at)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(signal_id, user_id) DO UPDATE SET
-			status = excluded.status,
-			updated_at = excluded.updated_at`,
-		signalID, userID, models.SignalStatusRead, time.Now(),
-	)
-
 	if err != nil {
 		http.Error(w, "Failed to update signal status", http.StatusInternalServerError)
 		return
 	}
 
+	services.PublishSignalTriage(userID, []int{signalID}, models.SignalTriage{Action: models.SignalActionStatus, Status: models.SignalStatusRead})
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -300,32 +288,130 @@ func ArchiveSignal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify signal exists and belongs to user
-	var exists bool
-	err = database.DB.QueryRow(
-		"SELECT EXISTS(SELECT 1 FROM signals WHERE id = ? AND user_id = ?)",
-		signalID, userID,
-	).Scan(&exists)
-
-	if err != nil || !exists {
+	err = repository.Default().Signals.SetStatus(userID, signalID, models.SignalStatusArchived)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Signal not found", http.StatusNotFound)
 		return
 	}
-
-	// Upsert signal status
-	_, err = database.DB.Exec(`
-		INSERT INTO signal_status (signal_id, user_id, status, updated_at)
-		VALUES (?, ?, ?, ?)
-		ON CONFLICT(signal_id, user_id) DO UPDATE SET
-			status = excluded.status,
-			updated_at = excluded.updated_at`,
-		signalID, userID, models.SignalStatusArchived, time.Now(),
-	)
-
 	if err != nil {
 		http.Error(w, "Failed to archive signal", http.StatusInternalServerError)
 		return
 	}
 
+	services.PublishSignalTriage(userID, []int{signalID}, models.SignalTriage{Action: models.SignalActionStatus, Status: models.SignalStatusArchived})
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// TriageSignal handles POST /api/signals/:id/status, /snooze and /assign. The
+// body carries the matching field of models.SignalTriage.
+func TriageSignal(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Path is /api/signals/:id/:action
+	parts := splitPath(r.URL.Path)
+	if len(parts) != 4 {
+		http.Error(w, "Invalid signal ID", http.StatusBadRequest)
+		return
+	}
+	signalID, err := strconv.Atoi(parts[2])
+	if err != nil || signalID <= 0 {
+		http.Error(w, "Invalid signal ID", http.StatusBadRequest)
+		return
+	}
+
+	var triage models.SignalTriage
+	if err := json.NewDecoder(r.Body).Decode(&triage); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	triage.Action = parts[3]
+	if err := checkSignalTriage(triage, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	triageSignals(w, userID, []int{signalID}, triage)
+}
+
+// BulkTriageSignals applies one triage action to every signal in the request,
+// or to none of them if any is missing or the change is refused.
+func BulkTriageSignals(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.BulkSignalTriageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.SignalIDs) == 0 {
+		http.Error(w, "signal_ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.SignalIDs) > maxBulkSignalIDs {
+		http.Error(w, fmt.Sprintf("At most %d signals can be changed at once", maxBulkSignalIDs), http.StatusBadRequest)
+		return
+	}
+	signalIDs := make([]int, 0, len(req.SignalIDs))
+	seen := make(map[int]bool, len(req.SignalIDs))
+	for _, signalID := range req.SignalIDs {
+		if signalID <= 0 {
+			http.Error(w, "Invalid signal ID", http.StatusBadRequest)
+			return
+		}
+		if !seen[signalID] {
+			seen[signalID] = true
+			signalIDs = append(signalIDs, signalID)
+		}
+	}
+	if err := checkSignalTriage(req.SignalTriage, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	triageSignals(w, userID, signalIDs, req.SignalTriage)
+}
+
+func checkSignalTriage(triage models.SignalTriage, now time.Time) error {
+	switch triage.Action {
+	case models.SignalActionStatus:
+		if !models.IsSignalStatus(triage.Status) {
+			return errors.New("Invalid signal status")
+		}
+	case models.SignalActionSnooze:
+		if triage.SnoozedUntil == nil || !triage.SnoozedUntil.After(now) {
+			return errors.New("snoozed_until must be in the future")
+		}
+	case models.SignalActionAssign:
+		if triage.AssigneeID != nil && *triage.AssigneeID <= 0 {
+			return errors.New("Invalid assignee_id")
+		}
+	default:
+		return errors.New("Invalid action")
+	}
+	return nil
+}
+
+func triageSignals(w http.ResponseWriter, userID int, signalIDs []int, triage models.SignalTriage) {
+	err := repository.Default().Signals.Triage(userID, signalIDs, triage)
+	switch err {
+	case nil:
+		services.PublishSignalTriage(userID, signalIDs, triage)
+		w.WriteHeader(http.StatusNoContent)
+	case repository.ErrNotFound:
+		http.Error(w, "Signal not found", http.StatusNotFound)
+	case repository.ErrAssignForbidden:
+		http.Error(w, "Forbidden: Only members can assign signals", http.StatusForbidden)
+	case repository.ErrInvalidAssignee:
+		http.Error(w, "Assignee must be a member of the signal's workspace", http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to update signals", http.StatusInternalServerError)
+	}
+}
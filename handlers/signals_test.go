@@ -2,88 +2,33 @@ package handlers
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"strconv"
 	"testing"
-
-	_ "github.com/mattn/go-sqlite3"
+	"time"
 )
 
 func setupSignalsTestDB(t *testing.T) {
 	t.Helper()
 
-	var err error
-	database.DB, err = sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("failed to open test db: %v", err)
-	}
-
-	statements := []string{
-		`CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL
-		);`,
-		`CREATE TABLE signals (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			source_type TEXT NOT NULL,
-			source_id TEXT NOT NULL,
-			external_id TEXT,
-			title TEXT NOT NULL,
-			content TEXT,
-			author TEXT,
-			body TEXT,
-			url TEXT,
-			status TEXT DEFAULT 'unread',
-			source_metadata TEXT,
-			received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE signal_status (
-			signal_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (signal_id, user_id)
-		);`,
-	}
-
-	for _, statement := range statements {
-		if _, err := database.DB.Exec(statement); err != nil {
-			t.Fatalf("failed to prepare test schema: %v", err)
-		}
-	}
-
-	if _, err := database.DB.Exec(
+	dbtest.Open(t)
+	dbtest.Seed(t,
 		`INSERT INTO users (id, email, password) VALUES (1, 'reader@example.com', 'hashed-password')`,
-	); err != nil {
-		t.Fatalf("failed to seed user: %v", err)
-	}
-
-	if _, err := database.DB.Exec(
+		`INSERT INTO workspaces (id, name, owner_id) VALUES (7, 'Team', 1), (8, 'Elsewhere', 1)`,
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (7, 1, 'owner'), (8, 1, 'owner')`,
 		`INSERT INTO signals
 			(id, user_id, workspace_id, source_type, source_id, external_id, title, content, status)
 		 VALUES
 			(1, 1, 7, 'github', 'sig-1', 'external-1', 'Signal One', 'Body', 'unread'),
 			(2, 1, 7, 'github', 'sig-2', 'external-2', 'Signal Two', 'Body', 'unread')`,
-	); err != nil {
-		t.Fatalf("failed to seed signals: %v", err)
-	}
-
-	if _, err := database.DB.Exec(
-		`INSERT INTO signal_status (signal_id, user_id, status) VALUES (1, 1, ?)`,
-		models.SignalStatusRead,
-	); err != nil {
-		t.Fatalf("failed to seed signal status: %v", err)
-	}
+		`INSERT INTO signal_status (signal_id, user_id, status) VALUES (1, 1, '`+models.SignalStatusRead+`')`,
+	)
 }
 
 func signalRequestWithUser(method, target string) *http.Request {
@@ -93,7 +38,6 @@ func signalRequestWithUser(method, target string) *http.Request {
 
 func TestSignalsHandlerUsesResolvedSignalStatus(t *testing.T) {
 	setupSignalsTestDB(t)
-	defer database.DB.Close()
 
 	req := signalRequestWithUser(http.MethodGet, "/api/signals?status=read")
 	rr := httptest.NewRecorder()
@@ -104,10 +48,11 @@ func TestSignalsHandlerUsesResolvedSignalStatus(t *testing.T) {
 		t.Fatalf("expected status 200, got %d", rr.Code)
 	}
 
-	var signals []models.Signal
-	if err := json.NewDecoder(rr.Body).Decode(&signals); err != nil {
+	var response models.SignalListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
 		t.Fatalf("failed to decode response: %v", err)
 	}
+	signals := response.Signals
 
 	if len(signals) != 1 {
 		t.Fatalf("expected 1 signal, got %d", len(signals))
@@ -122,7 +67,6 @@ func TestSignalsHandlerUsesResolvedSignalStatus(t *testing.T) {
 
 func TestWorkspaceSignalsStatusFilterUsesResolvedStatusForResultsAndTotal(t *testing.T) {
 	setupSignalsTestDB(t)
-	defer database.DB.Close()
 
 	req := signalRequestWithUser(http.MethodGet, "/api/workspaces/7/signals?status=read")
 	rr := httptest.NewRecorder()
@@ -151,3 +95,244 @@ func TestWorkspaceSignalsStatusFilterUsesResolvedStatusForResultsAndTotal(t *tes
 		t.Fatalf("expected resolved status %q, got %q", models.SignalStatusRead, response.Signals[0].Status)
 	}
 }
+
+func TestSignalEndpointsDecodeSourceMetadataBySourceType(t *testing.T) {
+	setupSignalsTestDB(t)
+
+	dbtest.Seed(t,
+		`INSERT INTO signals (id, user_id, workspace_id, source_type, source_id, external_id, title, source_metadata) VALUES
+			(3, 1, 8, 'github', 'gh-1', '7', 'GitHub issue', '{"repository":"acme/api","number":7,"state":"open","type":"issue"}'),
+			(4, 1, 8, 'jira', 'cloud-1:10001', 'OPS-1', 'Jira issue', '{"project_key":"OPS","issue_type":"Bug","status":"To Do","issue_key":"OPS-1"}'),
+			(5, 1, 8, 'slack', 'C1:1.000100', '1.000100', 'Slack message', '{"channel_id":"C1","ts":"1.000100","user_id":"U1","reactions":{"eyes":2}}'),
+			(6, 1, 8, 'linear', 'lin-1', 'ENG-1', 'Linear issue', '{"team":"ENG"}'),
+			(7, 1, 8, 'jira', 'cloud-1:10002', 'OPS-2', 'Broken metadata', 'not json')`,
+	)
+
+	// Each metadata type has a field no other type has, so decoding a signal
+	// into the wrong type shows up as a missing value.
+	want := map[int]string{
+		3: `{"repository":"acme/api","number":7,"state":"open","type":"issue"}`,
+		4: `{"project_key":"OPS","issue_type":"Bug","status":"To Do","issue_key":"OPS-1"}`,
+		5: `{"channel_id":"C1","ts":"1.000100","user_id":"U1","reactions":{"eyes":2}}`,
+		6: `{"team":"ENG"}`,
+	}
+	type signalJSON struct {
+		ID             int             `json:"id"`
+		SourceMetadata json.RawMessage `json:"source_metadata"`
+	}
+	check := func(endpoint string, signals []signalJSON) {
+		t.Helper()
+		for _, signal := range signals {
+			expected, ok := want[signal.ID]
+			if !ok {
+				if signal.SourceMetadata != nil {
+					t.Fatalf("%s: expected no metadata for signal %d, got %s", endpoint, signal.ID, signal.SourceMetadata)
+				}
+				continue
+			}
+			if string(signal.SourceMetadata) != expected {
+				t.Fatalf("%s: signal %d metadata = %s, want %s", endpoint, signal.ID, signal.SourceMetadata, expected)
+			}
+		}
+	}
+
+	listRR := httptest.NewRecorder()
+	GetSignals(listRR, signalRequestWithUser(http.MethodGet, "/api/workspaces/8/signals"))
+	var list struct {
+		Signals []signalJSON `json:"signals"`
+	}
+	if err := json.NewDecoder(listRR.Body).Decode(&list); err != nil {
+		t.Fatalf("failed to decode workspace signals: %v", err)
+	}
+	if len(list.Signals) != 5 {
+		t.Fatalf("expected 5 workspace signals, got %d", len(list.Signals))
+	}
+	check("GetSignals", list.Signals)
+
+	allRR := httptest.NewRecorder()
+	SignalsHandler(allRR, signalRequestWithUser(http.MethodGet, "/api/signals"))
+	var all struct {
+		Signals []signalJSON `json:"signals"`
+	}
+	if err := json.NewDecoder(allRR.Body).Decode(&all); err != nil {
+		t.Fatalf("failed to decode signals: %v", err)
+	}
+	check("SignalsHandler", all.Signals)
+
+	for id := 3; id <= 6; id++ {
+		rr := httptest.NewRecorder()
+		GetSignal(rr, signalRequestWithUser(http.MethodGet, "/api/signals/"+strconv.Itoa(id)))
+		var signal signalJSON
+		if err := json.NewDecoder(rr.Body).Decode(&signal); err != nil {
+			t.Fatalf("failed to decode signal %d: %v", id, err)
+		}
+		check("GetSignal", []signalJSON{signal})
+	}
+}
+
+func TestSignalsHandlerPagesWithCursorsAndRejectsBadFilters(t *testing.T) {
+	setupSignalsTestDB(t)
+
+	get := func(target string) (*httptest.ResponseRecorder, models.SignalListResponse) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		SignalsHandler(rr, signalRequestWithUser(http.MethodGet, target))
+		var page models.SignalListResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode page: %v", err)
+			}
+		}
+		return rr, page
+	}
+
+	_, first := get("/api/signals?limit=1&source_type=github,slack")
+	if len(first.Signals) != 1 || first.Total != 2 || first.NextCursor == "" || first.PrevCursor != "" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	_, second := get("/api/signals?limit=1&source_type=github,slack&cursor=" + first.NextCursor)
+	if len(second.Signals) != 1 || second.Signals[0].ID == first.Signals[0].ID || second.NextCursor != "" || second.PrevCursor == "" {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+
+	for _, target := range []string{
+		"/api/signals?since=yesterday",
+		"/api/signals?has_decision=maybe",
+		"/api/signals?cursor=bogus",
+	} {
+		if rr, _ := get(target); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, rr.Code)
+		}
+	}
+}
+
+func TestSignalTriageHandlers(t *testing.T) {
+	setupSignalsTestDB(t)
+
+	dbtest.Seed(t,
+		`INSERT INTO users (id, email, password) VALUES (2, 'other@example.com', 'x')`,
+		`INSERT INTO signals (id, user_id, workspace_id, source_type, source_id, external_id, title) VALUES
+			(3, 2, NULL, 'gmail', 'thread-1', 'thread-1', 'Someone else''s mail')`,
+	)
+
+	send := func(target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := requestWithUser(http.MethodPost, target, []byte(body), 1, "reader@example.com")
+		if target == "/api/signals/bulk" {
+			BulkTriageSignals(rr, req)
+		} else {
+			TriageSignal(rr, req)
+		}
+		return rr
+	}
+	status := func(signalID int) models.Signal {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		GetSignal(rr, signalRequestWithUser(http.MethodGet, "/api/signals/"+strconv.Itoa(signalID)))
+		var signal models.Signal
+		if err := json.NewDecoder(rr.Body).Decode(&signal); err != nil {
+			t.Fatalf("failed to decode signal: %v", err)
+		}
+		return signal
+	}
+
+	if rr := send("/api/signals/2/status", `{"status":"needs_action"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 from status, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := status(2).Status; got != models.SignalStatusNeedsAction {
+		t.Fatalf("expected needs_action, got %q", got)
+	}
+
+	until := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if rr := send("/api/signals/2/snooze", `{"snoozed_until":"`+until+`"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 from snooze, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if signal := status(2); signal.Status != models.SignalStatusSnoozed || signal.SnoozedUntil == nil {
+		t.Fatalf("expected the signal to be snoozed, got %q until %v", signal.Status, signal.SnoozedUntil)
+	}
+
+	if rr := send("/api/signals/1/assign", `{"assignee_id":1}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 from assign, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if signal := status(1); signal.AssigneeID == nil || *signal.AssigneeID != 1 {
+		t.Fatalf("expected the signal to be assigned, got %v", signal.AssigneeID)
+	}
+
+	for _, tc := range []struct {
+		target, body string
+		want         int
+	}{
+		{"/api/signals/1/status", `{"status":"snoozed"}`, http.StatusBadRequest},
+		{"/api/signals/1/snooze", `{"snoozed_until":"2001-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"/api/signals/1/assign", `{"assignee_id":99}`, http.StatusBadRequest},
+		{"/api/signals/3/status", `{"status":"done"}`, http.StatusNotFound},
+		{"/api/signals/bulk", `{"signal_ids":[],"action":"status","status":"done"}`, http.StatusBadRequest},
+		{"/api/signals/bulk", `{"signal_ids":[1],"action":"delete"}`, http.StatusBadRequest},
+		{"/api/signals/bulk", `{"signal_ids":[1,2,3],"action":"status","status":"done"}`, http.StatusNotFound},
+	} {
+		if rr := send(tc.target, tc.body); rr.Code != tc.want {
+			t.Fatalf("%s %s: expected %d, got %d: %s", tc.target, tc.body, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+	if got := status(1).Status; got != models.SignalStatusRead {
+		t.Fatalf("expected the refused bulk change to leave signal 1 alone, got %q", got)
+	}
+
+	if rr := send("/api/signals/bulk", `{"signal_ids":[1,2,2],"action":"status","status":"done"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 from bulk, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, signalID := range []int{1, 2} {
+		if signal := status(signalID); signal.Status != models.SignalStatusDone || signal.SnoozedUntil != nil {
+			t.Fatalf("expected signal %d done, got %q until %v", signalID, signal.Status, signal.SnoozedUntil)
+		}
+	}
+}
+
+func TestSignalsHiddenFromMembersWithoutWorkspaceMFA(t *testing.T) {
+	setupSignalsTestDB(t)
+
+	for _, statement := range []string{
+		`INSERT INTO users (id, email, password) VALUES (2, 'member@example.com', 'hashed-password')`,
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (7, 2, 'member')`,
+		`UPDATE workspaces SET require_mfa = TRUE WHERE id = 7`,
+	} {
+		if _, err := database.DB.Exec(statement); err != nil {
+			t.Fatalf("failed to seed member: %v", err)
+		}
+	}
+
+	total := func() int {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		SignalsHandler(rr, requestWithUser(http.MethodGet, "/api/signals", nil, 2, "member@example.com"))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rr.Code)
+		}
+		var response models.SignalListResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return response.Total
+	}
+
+	if got := total(); got != 0 {
+		t.Fatalf("expected no signals from a workspace requiring MFA, got %d", got)
+	}
+	rr := httptest.NewRecorder()
+	GetSignal(rr, requestWithUser(http.MethodGet, "/api/signals/1", nil, 2, "member@example.com"))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for the signal, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	BulkTriageSignals(rr, requestWithUser(http.MethodPost, "/api/signals/bulk", []byte(`{"signal_ids":[1,2],"action":"status","status":"done"}`), 2, "member@example.com"))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 from bulk triage, got %d", rr.Code)
+	}
+
+	if _, err := database.DB.Exec(`UPDATE users SET mfa_enabled_at = CURRENT_TIMESTAMP WHERE id = 2`); err != nil {
+		t.Fatalf("failed to enable MFA: %v", err)
+	}
+	if got := total(); got != 2 {
+		t.Fatalf("expected the workspace's signals once MFA is enabled, got %d", got)
+	}
+}
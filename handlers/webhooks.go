@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sentinent-backend/middleware"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
+	"sentinent-backend/utils"
+	"strconv"
+	"strings"
+)
+
+// webhookDeliveryLogLimit is how many recent deliveries the delivery log shows.
+const webhookDeliveryLogLimit = 50
+
+// lookupWebhookHost resolves webhook hosts; tests replace it.
+var lookupWebhookHost = net.DefaultResolver.LookupIPAddr
+
+func ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	workspaceID, _, ok := webhookScopeFromRequest(w, r, false)
+	if !ok {
+		return
+	}
+
+	webhooks, err := repository.Default().Webhooks.List(workspaceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(webhooks)
+}
+
+// CreateWebhook registers an endpoint and returns it with its signing secret.
+// The secret is only ever shown here.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	workspaceID, _, ok := webhookScopeFromRequest(w, r, false)
+	if !ok {
+		return
+	}
+	userID, _ := middleware.GetUserID(r.Context())
+
+	req, err := decodeWebhookRequest(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		http.Error(w, "Server configuration error", http.StatusInternalServerError)
+		return
+	}
+	secret, err := generateSecureToken()
+	if err != nil {
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+	encryptedSecret, err := encryptor.Encrypt(secret)
+	if err != nil {
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	store := repository.Default()
+	webhookID, err := store.Webhooks.Create(workspaceID, userID, *req, encryptedSecret)
+	if err != nil {
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := store.Webhooks.Get(workspaceID, webhookID)
+	if err != nil {
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+	webhook.Secret = secret
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(webhook)
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	workspaceID, webhookID, ok := webhookScopeFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	webhook, err := repository.Default().Webhooks.Get(workspaceID, webhookID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(webhook)
+}
+
+// UpdateWebhook changes the fields the request sets. Deliveries already
+// queued still go to the webhook's URL at the time they are sent.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	workspaceID, webhookID, ok := webhookScopeFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	req, err := decodeWebhookRequest(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	store := repository.Default()
+	webhook, err := store.Webhooks.Get(workspaceID, webhookID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+	if req.URL != "" {
+		webhook.URL = req.URL
+	}
+	if req.Events != nil {
+		webhook.Events = req.Events
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+
+	err = store.Webhooks.Update(*webhook)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err = store.Webhooks.Get(workspaceID, webhookID)
+	if err != nil {
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(webhook)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	workspaceID, webhookID, ok := webhookScopeFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	err := repository.Default().Webhooks.Delete(workspaceID, webhookID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns the webhook's most recent deliveries, newest
+// first, with the payload each one sent.
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	workspaceID, webhookID, ok := webhookScopeFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+
+	store := repository.Default()
+	if _, err := store.Webhooks.Get(workspaceID, webhookID); err == repository.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+
+	deliveries, err := store.Webhooks.ListDeliveries(webhookID, webhookDeliveryLogLimit)
+	if err != nil {
+		http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(deliveries)
+}
+
+// RedeliverWebhookDelivery queues a delivery's payload to be sent again as a
+// new delivery. The event id is kept, so receivers can tell it is a repeat.
+func RedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	workspaceID, webhookID, ok := webhookScopeFromRequest(w, r, true)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(splitPath(r.URL.Path)[6])
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	store := repository.Default()
+	webhook, err := store.Webhooks.Get(workspaceID, webhookID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch webhook", http.StatusInternalServerError)
+		return
+	}
+	if !webhook.Enabled {
+		http.Error(w, "Webhook is disabled", http.StatusConflict)
+		return
+	}
+
+	delivery, err := store.Webhooks.GetDelivery(webhookID, deliveryID)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch delivery", http.StatusInternalServerError)
+		return
+	}
+
+	redeliveryID, err := services.RedeliverWebhook(*delivery)
+	if err != nil {
+		http.Error(w, "Failed to queue redelivery", http.StatusInternalServerError)
+		return
+	}
+	redelivery, err := store.Webhooks.GetDelivery(webhookID, redeliveryID)
+	if err != nil {
+		http.Error(w, "Failed to fetch delivery", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(redelivery)
+}
+
+// webhookScopeFromRequest reads the workspace, and the webhook when withID is
+// set, from the path and checks that the caller owns the workspace. It writes
+// the error response and returns false when they cannot go on.
+func webhookScopeFromRequest(w http.ResponseWriter, r *http.Request, withID bool) (int, int, bool) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+
+	parts := splitPath(r.URL.Path)
+	workspaceID, err := extractWorkspaceIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	var webhookID int
+	if withID {
+		if len(parts) < 5 {
+			http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		if webhookID, err = strconv.Atoi(parts[4]); err != nil {
+			http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+
+	role, err := middleware.GetWorkspaceRole(userID, workspaceID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return 0, 0, false
+	}
+	if role != models.RoleOwner {
+		http.Error(w, "Forbidden: Only owners can manage webhooks", http.StatusForbidden)
+		return 0, 0, false
+	}
+	return workspaceID, webhookID, true
+}
+
+// decodeWebhookRequest reads and checks a webhook request. When creating, the
+// URL and events are required; an update may leave either out.
+func decodeWebhookRequest(r *http.Request, creating bool) (*models.WebhookRequest, error) {
+	var req models.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.New("Invalid request body")
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" && creating {
+		return nil, errors.New("Webhook URL is required")
+	}
+	if req.URL != "" {
+		if err := checkWebhookURL(r.Context(), req.URL); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Events != nil || creating {
+		var events []string
+		for _, event := range cleanList(req.Events) {
+			event = strings.ToLower(event)
+			if !models.IsWebhookEvent(event) {
+				return nil, fmt.Errorf("Unknown webhook event: %s", event)
+			}
+			if !containsLabel(events, event) {
+				events = append(events, event)
+			}
+		}
+		if len(events) == 0 {
+			return nil, errors.New("A webhook needs at least one event")
+		}
+		req.Events = events
+	}
+	return &req, nil
+}
+
+// checkWebhookURL accepts absolute http and https URLs whose host resolves
+// only to addresses webhooks may be sent to. Production only sends over https,
+// so the signed payloads cannot be read in transit.
+func checkWebhookURL(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errors.New("Webhook URL must be an absolute http or https URL")
+	}
+	if parsed.Scheme != "https" && utils.IsProductionEnv() {
+		return errors.New("Webhook URL must use https")
+	}
+
+	var addresses []net.IP
+	if ip := net.ParseIP(parsed.Hostname()); ip != nil {
+		addresses = append(addresses, ip)
+	} else {
+		resolved, err := lookupWebhookHost(ctx, parsed.Hostname())
+		if err != nil || len(resolved) == 0 {
+			return errors.New("Webhook URL host could not be resolved")
+		}
+		for _, address := range resolved {
+			addresses = append(addresses, address.IP)
+		}
+	}
+	for _, ip := range addresses {
+		if !services.WebhookAddressAllowed(ip) {
+			return errors.New("Webhook URL must not point to a private or local address")
+		}
+	}
+	return nil
+}
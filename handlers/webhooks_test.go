@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubWebhookHosts resolves webhook hosts from hosts instead of DNS.
+func stubWebhookHosts(t *testing.T, hosts map[string]string) {
+	t.Helper()
+
+	original := lookupWebhookHost
+	lookupWebhookHost = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		address, ok := hosts[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return []net.IPAddr{{IP: net.ParseIP(address)}}, nil
+	}
+	t.Cleanup(func() { lookupWebhookHost = original })
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	setupCollaborationTestDB(t)
+	seedWorkspaceCollaborationData(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	stubWebhookHosts(t, map[string]string{
+		"hooks.example.com":    "93.184.216.34",
+		"localhost":            "127.0.0.1",
+		"internal.example.com": "10.0.0.12",
+	})
+
+	send := func(method, target, body string, userID int) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		WorkspacesRouter(rr, requestWithUser(method, target, []byte(body), userID, "user@example.com"))
+		return rr
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+	}{
+		{"missing url", `{"events":["decision.created"]}`},
+		{"relative url", `{"url":"/hooks","events":["decision.created"]}`},
+		{"other scheme", `{"url":"ftp://hooks.example.com","events":["decision.created"]}`},
+		{"no events", `{"url":"https://hooks.example.com"}`},
+		{"unknown event", `{"url":"https://hooks.example.com","events":["decision.deleted"]}`},
+		{"unresolvable host", `{"url":"https://missing.example.com","events":["decision.created"]}`},
+		{"loopback", `{"url":"http://127.0.0.1:6379","events":["decision.created"]}`},
+		{"localhost", `{"url":"http://localhost/hooks","events":["decision.created"]}`},
+		{"ipv6 loopback", `{"url":"http://[::1]/hooks","events":["decision.created"]}`},
+		{"metadata service", `{"url":"http://169.254.169.254/latest/meta-data","events":["decision.created"]}`},
+		{"private address", `{"url":"http://192.168.1.20/hooks","events":["decision.created"]}`},
+		{"private host", `{"url":"https://internal.example.com/hooks","events":["decision.created"]}`},
+		{"unspecified", `{"url":"http://0.0.0.0/hooks","events":["decision.created"]}`},
+	} {
+		if rr := send(http.MethodPost, "/api/workspaces/10/webhooks", tc.body, 1); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", tc.name, rr.Code, rr.Body.String())
+		}
+	}
+
+	valid := `{"url":"https://hooks.example.com/sentinent","events":["decision.created"," Decision.Created ","decision.closed"]}`
+	if rr := send(http.MethodPost, "/api/workspaces/10/webhooks", valid, 3); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a member to be refused, got %d", rr.Code)
+	}
+	rr := send(http.MethodPost, "/api/workspaces/10/webhooks", valid, 1)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var webhook models.Webhook
+	if err := json.NewDecoder(rr.Body).Decode(&webhook); err != nil {
+		t.Fatalf("failed to decode webhook: %v", err)
+	}
+	if webhook.Secret == "" || !webhook.Enabled || len(webhook.Events) != 2 || webhook.CreatedBy != 1 {
+		t.Fatalf("expected an enabled webhook with its secret and deduplicated events, got %+v", webhook)
+	}
+	var storedSecret string
+	if err := database.DB.QueryRow(`SELECT secret FROM outgoing_webhooks WHERE id = ?`, webhook.ID).Scan(&storedSecret); err != nil {
+		t.Fatalf("failed to read stored secret: %v", err)
+	}
+	if storedSecret == webhook.Secret {
+		t.Fatal("expected the secret to be stored encrypted")
+	}
+	webhookPath := "/api/workspaces/10/webhooks/" + strconv.Itoa(webhook.ID)
+
+	if rr := send(http.MethodGet, webhookPath, "", 1); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	} else {
+		var fetched models.Webhook
+		if err := json.NewDecoder(rr.Body).Decode(&fetched); err != nil || fetched.Secret != "" {
+			t.Fatalf("expected the secret to be shown only once, got %+v (err=%v)", fetched, err)
+		}
+	}
+	if rr := send(http.MethodGet, "/api/workspaces/10/webhooks", "", 3); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a member to be refused the list, got %d", rr.Code)
+	}
+
+	if rr := send(http.MethodPatch, webhookPath, `{"events":[]}`, 1); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected clearing the events to be rejected, got %d", rr.Code)
+	}
+	if rr := send(http.MethodPatch, webhookPath, `{"url":"http://169.254.169.254/"}`, 1); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected moving the webhook to a link-local address to be rejected, got %d", rr.Code)
+	}
+	t.Setenv("APP_ENV", "production")
+	if rr := send(http.MethodPatch, webhookPath, `{"url":"http://hooks.example.com/plain"}`, 1); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected production to require https, got %d", rr.Code)
+	}
+	t.Setenv("APP_ENV", "")
+	rr = send(http.MethodPatch, webhookPath, `{"enabled":false}`, 1)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var paused models.Webhook
+	if err := json.NewDecoder(rr.Body).Decode(&paused); err != nil {
+		t.Fatalf("failed to decode webhook: %v", err)
+	}
+	if paused.Enabled || paused.URL != webhook.URL || len(paused.Events) != 2 {
+		t.Fatalf("expected only enabled to change, got %+v", paused)
+	}
+
+	// A disabled webhook hears nothing.
+	if rr := send(http.MethodPost, "/api/workspaces/10/decisions", `{"title":"Pick a queue"}`, 1); rr.Code != http.StatusCreated {
+		t.Fatalf("expected decision to be created, got %d: %s", rr.Code, rr.Body.String())
+	}
+	deliveriesPath := webhookPath + "/deliveries"
+	var deliveries []models.WebhookDelivery
+	listDeliveries := func() {
+		t.Helper()
+		rr := send(http.MethodGet, deliveriesPath, "", 1)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		deliveries = nil
+		if err := json.NewDecoder(rr.Body).Decode(&deliveries); err != nil {
+			t.Fatalf("failed to decode deliveries: %v", err)
+		}
+	}
+	if listDeliveries(); len(deliveries) != 0 {
+		t.Fatalf("expected no deliveries while disabled, got %+v", deliveries)
+	}
+
+	if rr := send(http.MethodPatch, webhookPath, `{"enabled":true}`, 1); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	rr = send(http.MethodPost, "/api/workspaces/10/decisions", `{"title":"Pick a database","status":"OPEN"}`, 3)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected decision to be created, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var decision models.Decision
+	if err := json.NewDecoder(rr.Body).Decode(&decision); err != nil {
+		t.Fatalf("failed to decode decision: %v", err)
+	}
+	closeBody := `{"title":"Pick a database","status":"CLOSED","rationale":"Postgres it is"}`
+	if rr := send(http.MethodPatch, "/api/workspaces/10/decisions/"+strconv.Itoa(decision.ID), closeBody, 1); rr.Code != http.StatusOK {
+		t.Fatalf("expected decision to close, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	listDeliveries()
+	if len(deliveries) != 2 || deliveries[0].EventType != models.WebhookEventDecisionClosed ||
+		deliveries[1].EventType != models.WebhookEventDecisionCreated || deliveries[0].Status != models.WebhookDeliveryPending {
+		t.Fatalf("expected created and closed deliveries newest first, got %+v", deliveries)
+	}
+	var payload struct {
+		models.WebhookPayload
+		Data models.Decision `json:"data"`
+	}
+	if err := json.Unmarshal(deliveries[0].Payload, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if payload.ID != deliveries[0].EventID || payload.WorkspaceID != 10 || payload.Data.ID != decision.ID ||
+		payload.Data.Outcome == nil || payload.Data.Outcome.Rationale != "Postgres it is" {
+		t.Fatalf("expected the closed decision in the payload, got %+v", payload)
+	}
+
+	redeliverPath := deliveriesPath + "/" + strconv.Itoa(deliveries[0].ID) + "/redeliver"
+	if rr := send(http.MethodPost, deliveriesPath+"/999/redeliver", "", 1); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected a missing delivery to 404, got %d", rr.Code)
+	}
+	if rr := send(http.MethodPost, redeliverPath, "", 3); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected a member to be refused redelivery, got %d", rr.Code)
+	}
+	rr = send(http.MethodPost, redeliverPath, "", 1)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var redelivery models.WebhookDelivery
+	if err := json.NewDecoder(rr.Body).Decode(&redelivery); err != nil {
+		t.Fatalf("failed to decode redelivery: %v", err)
+	}
+	if redelivery.RedeliveryOf == nil || *redelivery.RedeliveryOf != deliveries[0].ID || redelivery.EventID != deliveries[0].EventID ||
+		redelivery.Status != models.WebhookDeliveryPending {
+		t.Fatalf("expected a pending copy of the delivery, got %+v", redelivery)
+	}
+
+	if rr := send(http.MethodDelete, webhookPath, "", 1); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := send(http.MethodGet, deliveriesPath, "", 1); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the deleted webhook to 404, got %d", rr.Code)
+	}
+
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "")
+	if rr := send(http.MethodPost, "/api/workspaces/10/webhooks", valid, 1); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected a missing encryption key to be reported, got %d", rr.Code)
+	}
+}
+
+func TestAcceptInvitationQueuesWebhookEvents(t *testing.T) {
+	setupCollaborationTestDB(t)
+	seedWorkspaceCollaborationData(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	stubWebhookHosts(t, map[string]string{"hooks.example.com": "93.184.216.34"})
+
+	body := `{"url":"https://hooks.example.com","events":["member.joined","invitation.accepted"]}`
+	rr := httptest.NewRecorder()
+	WorkspacesRouter(rr, requestWithUser(http.MethodPost, "/api/workspaces/10/webhooks", []byte(body), 1, "owner@example.com"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := database.DB.Exec(
+		`INSERT INTO invitations (workspace_id, email, token, role, expires_at, created_by)
+		 VALUES (10, 'invitee@example.com', 'token-1', 'viewer', ?, 1)`,
+		time.Now().Add(24*time.Hour),
+	); err != nil {
+		t.Fatalf("failed to seed invitation: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	AcceptInvitation(rr, requestWithUser(http.MethodPost, "/api/invitations/token-1/accept", nil, 2, "invitee@example.com"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rows, err := database.DB.Query(`SELECT event_type, payload FROM webhook_deliveries ORDER BY id`)
+	if err != nil {
+		t.Fatalf("failed to read deliveries: %v", err)
+	}
+	defer rows.Close()
+	payloads := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var eventType, raw string
+		if err := rows.Scan(&eventType, &raw); err != nil {
+			t.Fatalf("failed to scan delivery: %v", err)
+		}
+		var payload struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		payloads[eventType] = payload.Data
+	}
+
+	accepted, joined := payloads[models.WebhookEventInvitationAccepted], payloads[models.WebhookEventMemberJoined]
+	if len(payloads) != 2 || accepted["token"] != nil || accepted["accepted_by"] != float64(2) || accepted["email"] != "invitee@example.com" {
+		t.Fatalf("expected the accepted invitation without its token, got %+v", payloads)
+	}
+	if joined["user_id"] != float64(2) || joined["role"] != string(models.RoleViewer) {
+		t.Fatalf("expected the new member, got %+v", joined)
+	}
+}
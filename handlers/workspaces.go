@@ -1,13 +1,12 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
-	"sentinent-backend/database"
 	"sentinent-backend/middleware"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"strings"
 )
 
@@ -18,36 +17,11 @@ func ListWorkspaces(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := database.DB.Query(
-		`SELECT DISTINCT w.id, w.name, COALESCE(w.description, ''), w.owner_id, w.created_at, w.updated_at
-		 FROM workspaces w
-		 JOIN workspace_members wm ON wm.workspace_id = w.id
-		 WHERE wm.user_id = ?
-		 ORDER BY w.updated_at DESC, w.id DESC`,
-		userID,
-	)
+	workspaces, err := repository.Default().Workspaces.ListForUser(userID)
 	if err != nil {
 		http.Error(w, "Failed to fetch workspaces", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	workspaces := make([]models.Workspace, 0)
-	for rows.Next() {
-		var workspace models.Workspace
-		if err := rows.Scan(
-			&workspace.ID,
-			&workspace.Name,
-			&workspace.Description,
-			&workspace.OwnerID,
-			&workspace.CreatedAt,
-			&workspace.UpdatedAt,
-		); err != nil {
-			http.Error(w, "Failed to scan workspace", http.StatusInternalServerError)
-			return
-		}
-		workspaces = append(workspaces, workspace)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(workspaces)
@@ -73,50 +47,12 @@ func CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := database.DB.Begin()
-	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	result, err := tx.Exec(
-		`INSERT INTO workspaces (name, description, owner_id, updated_at)
-		 VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
-		req.Name, req.Description, userID,
-	)
+	workspace, err := repository.Default().Workspaces.Create(userID, req.Name, req.Description)
 	if err != nil {
 		http.Error(w, "Failed to create workspace", http.StatusInternalServerError)
 		return
 	}
 
-	workspaceID64, err := result.LastInsertId()
-	if err != nil {
-		http.Error(w, "Failed to create workspace", http.StatusInternalServerError)
-		return
-	}
-	workspaceID := int(workspaceID64)
-
-	if _, err := tx.Exec(
-		`INSERT INTO workspace_members (workspace_id, user_id, role)
-		 VALUES (?, ?, ?)`,
-		workspaceID, userID, models.RoleOwner,
-	); err != nil {
-		http.Error(w, "Failed to create workspace membership", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to create workspace", http.StatusInternalServerError)
-		return
-	}
-
-	workspace, err := getWorkspaceByID(workspaceID)
-	if err != nil {
-		http.Error(w, "Failed to fetch workspace", http.StatusInternalServerError)
-		return
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(workspace)
@@ -145,8 +81,8 @@ func GetWorkspace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	workspace, err := getWorkspaceByID(workspaceID)
-	if err == sql.ErrNoRows {
+	workspace, err := repository.Default().Workspaces.Get(workspaceID)
+	if err == repository.ErrNotFound {
 		http.Error(w, "Workspace not found", http.StatusNotFound)
 		return
 	}
@@ -196,23 +132,18 @@ func UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec(
-		`UPDATE workspaces
-		 SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP
-		 WHERE id = ?`,
-		req.Name, req.Description, workspaceID,
-	)
-	if err != nil {
-		http.Error(w, "Failed to update workspace", http.StatusInternalServerError)
+	store := repository.Default()
+	err = store.Workspaces.Update(workspaceID, req.Name, req.Description)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Workspace not found", http.StatusNotFound)
 		return
 	}
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Workspace not found", http.StatusNotFound)
+	if err != nil {
+		http.Error(w, "Failed to update workspace", http.StatusInternalServerError)
 		return
 	}
 
-	workspace, err := getWorkspaceByID(workspaceID)
+	workspace, err := store.Workspaces.Get(workspaceID)
 	if err != nil {
 		http.Error(w, "Failed to fetch workspace", http.StatusInternalServerError)
 		return
@@ -245,55 +176,74 @@ func DeleteWorkspace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := database.DB.Begin()
+	if err := repository.Default().Workspaces.Delete(workspaceID); err != nil {
+		http.Error(w, "Failed to delete workspace", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateWorkspaceMFAPolicy lets the owner require MFA for every member. The
+// owner must have MFA enabled before turning the requirement on.
+func UpdateWorkspaceMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceID, err := extractWorkspaceIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+
+	isOwner, err := middleware.IsWorkspaceOwner(userID, workspaceID)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	defer tx.Rollback()
+	if !isOwner {
+		http.Error(w, "Forbidden: Only owners can change the MFA policy", http.StatusForbidden)
+		return
+	}
 
-	statements := []string{
-		`DELETE FROM signal_status WHERE signal_id IN (SELECT id FROM signals WHERE workspace_id = ?)`,
-		`DELETE FROM signals WHERE workspace_id = ?`,
-		`DELETE FROM invitations WHERE workspace_id = ?`,
-		`DELETE FROM workspace_members WHERE workspace_id = ?`,
-		`DELETE FROM external_integrations WHERE workspace_id = ?`,
-		`DELETE FROM decisions WHERE workspace_id = ?`,
-		`DELETE FROM workspaces WHERE id = ?`,
+	var req models.WorkspaceMFAPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
 
-	for _, statement := range statements {
-		if _, err := tx.Exec(statement, workspaceID); err != nil {
-			http.Error(w, "Failed to delete workspace", http.StatusInternalServerError)
+	store := repository.Default()
+	if req.RequireMFA {
+		settings, err := store.MFA.Get(userID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !settings.Enabled() {
+			http.Error(w, "Enable MFA on your account before requiring it", http.StatusConflict)
 			return
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to delete workspace", http.StatusInternalServerError)
+	err = store.Workspaces.SetRequireMFA(workspaceID, req.RequireMFA)
+	if err == repository.ErrNotFound {
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update workspace", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func getWorkspaceByID(workspaceID int) (*models.Workspace, error) {
-	var workspace models.Workspace
-	err := database.DB.QueryRow(
-		`SELECT id, name, COALESCE(description, ''), owner_id, created_at, updated_at
-		 FROM workspaces
-		 WHERE id = ?`,
-		workspaceID,
-	).Scan(
-		&workspace.ID,
-		&workspace.Name,
-		&workspace.Description,
-		&workspace.OwnerID,
-		&workspace.CreatedAt,
-		&workspace.UpdatedAt,
-	)
+	workspace, err := store.Workspaces.Get(workspaceID)
 	if err != nil {
-		return nil, err
+		http.Error(w, "Failed to fetch workspace", http.StatusInternalServerError)
+		return
 	}
-	return &workspace, nil
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(workspace)
 }
@@ -13,6 +13,11 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+
+	_ "sentinent-backend/services/providers/github"
+	_ "sentinent-backend/services/providers/gmail"
+	_ "sentinent-backend/services/providers/jira"
+	_ "sentinent-backend/services/providers/slack"
 )
 
 func main() {
@@ -21,6 +26,13 @@ func main() {
 		log.Println("No .env file found, relying on system environment variables")
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
 	jwtSecret := strings.TrimSpace(os.Getenv("JWT_SECRET"))
 	if jwtSecret == "" {
 		log.Fatal("JWT_SECRET is required")
@@ -40,22 +52,25 @@ func main() {
 	}
 
 	// Initialize optional integration providers.
-	if err := handlers.InitIntegrationHandlers(); err != nil {
-		log.Printf("Some integrations are unavailable: %v", err)
-	}
-	if err := services.InitGitHubService(); err != nil {
-		log.Printf("GitHub integration not configured: %v", err)
-	}
-	if err := services.InitJiraService(); err != nil {
-		log.Printf("Jira integration not configured: %v", err)
+	for _, provider := range services.Providers() {
+		if err := provider.Init(); err != nil {
+			log.Printf("%s integration not configured: %v", provider.DisplayName(), err)
+		}
 	}
-	if tokenEncryptor, err := utils.NewTokenEncryptor(); err == nil {
-		syncService := services.NewSyncService(tokenEncryptor)
+	if _, err := utils.NewTokenEncryptor(); err == nil {
+		syncService := services.NewSyncService()
 		syncService.Start(5 * time.Minute)
 		defer syncService.Stop()
+
+		webhookService := services.NewWebhookService()
+		webhookService.Start(30 * time.Second)
+		defer webhookService.Stop()
 	} else {
-		log.Printf("Background integration sync disabled: %v", err)
+		log.Printf("Background integration sync and webhook delivery disabled: %v", err)
 	}
+	snoozeService := services.NewSnoozeService()
+	snoozeService.Start(time.Minute)
+	defer snoozeService.Stop()
 
 	// Create a new ServeMux for our application routes
 	mux := http.NewServeMux()
@@ -63,7 +78,9 @@ func main() {
 	// Public routes
 	mux.HandleFunc("/api/signup", handlers.Signup)
 	mux.HandleFunc("/api/login", handlers.Signin) // Frontend calls /login
+	mux.HandleFunc("/api/login/mfa", handlers.VerifyMFALogin)
 	mux.HandleFunc("/api/logout", handlers.Logout)
+	mux.HandleFunc("/api/token/refresh", handlers.RefreshToken)
 	mux.HandleFunc("/api/forgot-password", handlers.ForgotPassword)
 	mux.HandleFunc("/api/reset-password/", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -77,10 +94,9 @@ func main() {
 	})
 
 	// Provider callbacks (public)
-	mux.HandleFunc("/api/integrations/slack/callback", handlers.SlackCallback)
-	mux.HandleFunc("/api/integrations/github/callback", handlers.GitHubCallbackHandler)
-	mux.HandleFunc("/api/integrations/gmail/callback", handlers.GmailCallbackHandler)
-	mux.HandleFunc("/api/integrations/jira/callback", handlers.JiraCallbackHandler)
+	for _, provider := range services.Providers() {
+		mux.HandleFunc("/api/integrations/"+provider.Name()+"/callback", handlers.IntegrationCallback)
+	}
 
 	// Protected routes
 	protectedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -93,44 +109,21 @@ func main() {
 	})
 
 	mux.Handle("/api/protected", middleware.AuthMiddleware(protectedHandler))
+	mux.Handle("/api/logout/all", middleware.AuthMiddleware(http.HandlerFunc(handlers.LogoutAll)))
+	mux.Handle("/api/mfa", middleware.AuthMiddleware(http.HandlerFunc(handlers.MFAStatus)))
+	mux.Handle("/api/mfa/setup", middleware.AuthMiddleware(http.HandlerFunc(handlers.SetupMFA)))
+	mux.Handle("/api/mfa/confirm", middleware.AuthMiddleware(http.HandlerFunc(handlers.ConfirmMFA)))
+	mux.Handle("/api/mfa/recovery-codes", middleware.AuthMiddleware(http.HandlerFunc(handlers.RegenerateRecoveryCodes)))
 
 	// Integration routes (protected)
 	mux.Handle("/api/profile", middleware.AuthMiddleware(http.HandlerFunc(handlers.ProfileHandler)))
 	mux.Handle("/api/integrations", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetIntegrations)))
-	mux.Handle("/api/integrations/slack/auth", middleware.AuthMiddleware(http.HandlerFunc(handlers.SlackAuth)))
-	mux.Handle("/api/integrations/slack/channels", middleware.AuthMiddleware(http.HandlerFunc(handlers.GetSlackChannels)))
-	mux.Handle("/api/integrations/slack/sync", middleware.AuthMiddleware(http.HandlerFunc(handlers.SlackSyncHandler)))
-	mux.Handle("/api/integrations/github/auth", middleware.AuthMiddleware(http.HandlerFunc(handlers.GitHubAuthHandler)))
-	mux.Handle("/api/integrations/github/repos", middleware.AuthMiddleware(http.HandlerFunc(handlers.GitHubReposHandler)))
-	mux.Handle("/api/integrations/github/sync", middleware.AuthMiddleware(http.HandlerFunc(handlers.GitHubSyncHandler)))
-	mux.Handle("/api/integrations/github/issues/", middleware.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		switch {
-		case r.Method == http.MethodPost && strings.HasSuffix(path, "/comments"):
-			handlers.GitHubAddCommentHandler(w, r)
-		case r.Method == http.MethodPatch && strings.HasSuffix(path, "/state"):
-			handlers.GitHubUpdateStateHandler(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})))
-	mux.Handle("/api/integrations/github", middleware.AuthMiddleware(http.HandlerFunc(handlers.GitHubDisconnectHandler)))
-	mux.Handle("/api/integrations/gmail/auth", middleware.AuthMiddleware(http.HandlerFunc(handlers.GmailAuthHandler)))
-	mux.Handle("/api/integrations/gmail", middleware.AuthMiddleware(http.HandlerFunc(handlers.GmailDisconnectHandler)))
-	mux.Handle("/api/integrations/jira/auth", middleware.AuthMiddleware(http.HandlerFunc(handlers.JiraAuthHandler)))
-	mux.Handle("/api/integrations/jira/projects", middleware.AuthMiddleware(http.HandlerFunc(handlers.JiraProjectsHandler)))
-	mux.Handle("/api/integrations/jira/sync", middleware.AuthMiddleware(http.HandlerFunc(handlers.JiraSyncHandler)))
-	mux.Handle("/api/integrations/jira/issues/", middleware.AuthMiddleware(http.HandlerFunc(handlers.JiraIssueActionHandler)))
-	mux.Handle("/api/integrations/jira", middleware.AuthMiddleware(http.HandlerFunc(handlers.JiraDisconnectHandler)))
-	mux.Handle("/api/integrations/slack/reply", middleware.AuthMiddleware(http.HandlerFunc(handlers.SlackReplyHandler)))
-	mux.Handle("/api/integrations/slack", middleware.AuthMiddleware(http.HandlerFunc(handlers.SlackDisconnectHandler)))
-	mux.Handle("/api/integrations/status", middleware.AuthMiddleware(http.HandlerFunc(handlers.IntegrationStatusHandler)))
-	mux.Handle("/api/integrations/", middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteIntegration)))
+	mux.Handle("/api/integrations/", middleware.AuthMiddleware(http.HandlerFunc(handlers.IntegrationsRouter)))
 
 	// Signal routes (protected)
 	mux.Handle("/api/signals", middleware.AuthMiddleware(http.HandlerFunc(handlers.SignalsHandler)))
 	mux.Handle("/api/workspaces", middleware.AuthMiddleware(http.HandlerFunc(handlers.WorkspacesRouter)))
-	mux.Handle("/api/workspaces/", middleware.AuthMiddleware(http.HandlerFunc(handlers.WorkspacesRouter)))
+	mux.Handle("/api/workspaces/", middleware.AuthMiddleware(middleware.RequireWorkspaceMFA(http.HandlerFunc(handlers.WorkspacesRouter))))
 	mux.Handle("/api/invitations/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case r.Method == http.MethodGet:
@@ -154,14 +147,17 @@ func main() {
 			handlers.MarkSignalAsRead(w, r)
 		case r.Method == http.MethodPost && strings.HasSuffix(path, "/archive"):
 			handlers.ArchiveSignal(w, r)
+		case r.Method == http.MethodPost && path == "/api/signals/bulk":
+			handlers.BulkTriageSignals(w, r)
+		case r.Method == http.MethodPost && (strings.HasSuffix(path, "/status") || strings.HasSuffix(path, "/snooze") || strings.HasSuffix(path, "/assign")):
+			handlers.TriageSignal(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	})))
 
 	// Webhook routes (public, but should verify signature in production)
-	mux.HandleFunc("/api/webhooks/github", handlers.GitHubWebhookHandler)
-	mux.HandleFunc("/api/webhooks/slack", handlers.SlackWebhookHandler)
+	mux.HandleFunc("/api/webhooks/", handlers.IntegrationWebhook)
 
 	// Apply CORS and logging middleware
 	handler := loggingMiddleware(middleware.CorsMiddleware(mux))
@@ -2,13 +2,14 @@ package middleware
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"net/http"
 	"sentinent-backend/database"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"sentinent-backend/utils"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -17,6 +18,7 @@ type contextKey string
 
 const UserEmailKey contextKey = "userEmail"
 const UserIDKey contextKey = "userID"
+const claimsKey contextKey = "claims"
 
 var jwtMalformedErrors = []error{
 	jwt.ErrTokenMalformed,
@@ -84,19 +86,22 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		userID := claims.UserID
 		if database.DB != nil {
+			users := repository.Default().Users
 			if userID != 0 {
-				if err := database.DB.QueryRow("SELECT id FROM users WHERE id = ?", userID).Scan(&userID); err == sql.ErrNoRows {
-					// Recover from stale user IDs in older tokens by falling back to email lookup.
-					userID = 0
-				} else if err != nil {
+				exists, err := users.Exists(userID)
+				if err != nil {
 					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 					return
 				}
+				if !exists {
+					// Recover from stale user IDs in older tokens by falling back to email lookup.
+					userID = 0
+				}
 			}
 
 			if userID == 0 && claims.Email != "" {
-				err = database.DB.QueryRow("SELECT id FROM users WHERE email = ?", claims.Email).Scan(&userID)
-				if err == sql.ErrNoRows {
+				userID, err = users.IDByEmail(claims.Email)
+				if err == repository.ErrNotFound {
 					if claims.UserID != 0 {
 						http.Error(w, "Unauthorized", http.StatusUnauthorized)
 						return
@@ -106,9 +111,20 @@ func AuthMiddleware(next http.Handler) http.Handler {
 					return
 				}
 			}
+
+			status, err := checkTokenRevocation(claims, userID)
+			if err != nil {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if status != http.StatusOK {
+				http.Error(w, "Unauthorized", status)
+				return
+			}
 		}
 
 		ctx := context.WithValue(r.Context(), UserEmailKey, claims.Email)
+		ctx = context.WithValue(ctx, claimsKey, claims)
 		if userID != 0 {
 			ctx = context.WithValue(ctx, UserIDKey, userID)
 		}
@@ -116,6 +132,59 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// checkTokenRevocation rejects access tokens whose session has been revoked or
+// that were issued before the user's last password change. Tokens minted before
+// sessions existed carry no session ID and are only subject to the password check.
+func checkTokenRevocation(claims *models.Claims, userID int) (int, error) {
+	store := repository.Default()
+	if claims.SessionID != 0 {
+		session, err := store.Sessions.Get(claims.SessionID)
+		if err == repository.ErrNotFound {
+			return http.StatusUnauthorized, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if session.UserID != userID || !session.Active(time.Now()) {
+			return http.StatusUnauthorized, nil
+		}
+	}
+
+	if userID != 0 && claims.IssuedAt != nil {
+		changedAt, err := store.Users.PasswordChangedAt(userID)
+		if err != nil {
+			return 0, err
+		}
+		// JWT timestamps have second precision.
+		if changedAt != nil && changedAt.Truncate(time.Second).After(claims.IssuedAt.Time) {
+			return http.StatusUnauthorized, nil
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// SessionStillValid reports whether the access token that authenticated a
+// long-lived request has not expired and its session has not been revoked
+// since. Requests that did not pass through AuthMiddleware are left alone.
+func SessionStillValid(ctx context.Context) (bool, error) {
+	claims, ok := ctx.Value(claimsKey).(*models.Claims)
+	if !ok {
+		return true, nil
+	}
+	if claims.ExpiresAt != nil && !time.Now().Before(claims.ExpiresAt.Time) {
+		return false, nil
+	}
+	if database.DB == nil {
+		return true, nil
+	}
+	userID, _ := GetUserID(ctx)
+	status, err := checkTokenRevocation(claims, userID)
+	if err != nil {
+		return false, err
+	}
+	return status == http.StatusOK, nil
+}
+
 func GetUserID(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(UserIDKey).(int)
 	return userID, ok
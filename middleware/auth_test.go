@@ -1,17 +1,15 @@
 package middleware
 
 import (
-	"database/sql"
 	"net/http"
 	"net/http/httptest"
-	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
 	"sentinent-backend/models"
 	"sentinent-backend/utils"
 	"testing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func makeAuthTestToken(t *testing.T, email string) string {
@@ -123,28 +121,8 @@ func TestAuthMiddlewareRejectsMalformedBearerToken(t *testing.T) {
 }
 
 func TestAuthMiddlewareRecoversFromStaleTokenUserID(t *testing.T) {
-	var err error
-	database.DB, err = sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("failed to open in-memory db: %v", err)
-	}
-	t.Cleanup(func() {
-		_ = database.DB.Close()
-		database.DB = nil
-	})
-
-	if _, err := database.DB.Exec(`
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			email TEXT NOT NULL UNIQUE
-		);
-	`); err != nil {
-		t.Fatalf("failed to create users table: %v", err)
-	}
-
-	if _, err := database.DB.Exec("INSERT INTO users (id, email) VALUES (?, ?)", 42, "reader@example.com"); err != nil {
-		t.Fatalf("failed to seed user: %v", err)
-	}
+	dbtest.Open(t)
+	dbtest.Seed(t, `INSERT INTO users (id, email, password) VALUES (42, 'reader@example.com', 'x')`)
 
 	tokenString := makeAuthTestTokenWithUserID(t, "reader@example.com", 9999)
 
@@ -174,3 +152,35 @@ func TestAuthMiddlewareRecoversFromStaleTokenUserID(t *testing.T) {
 		t.Fatalf("expected email reader@example.com, got %q", gotEmail)
 	}
 }
+
+func TestAuthMiddlewareRejectsTokenIssuedBeforePasswordChange(t *testing.T) {
+	dbtest.Open(t)
+	dbtest.Exec(t,
+		"INSERT INTO users (id, email, password, password_changed_at) VALUES (?, ?, 'x', ?)",
+		7, "reader@example.com", time.Now(),
+	)
+
+	utils.JwtKey = []byte("middleware-test-secret")
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &models.Claims{
+		UserID: 7,
+		Email:  "reader@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString(utils.JwtKey)
+	if err != nil {
+		t.Fatalf("failed to sign JWT: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	rr := httptest.NewRecorder()
+	AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rr.Code)
+	}
+}
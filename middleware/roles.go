@@ -1,14 +1,18 @@
 package middleware
 
 import (
-	"database/sql"
 	"net/http"
-	"sentinent-backend/database"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// ownerMFAGracePeriod is how long a workspace owner has to enroll in MFA,
+// counted from the first time they are checked as an owner.
+const ownerMFAGracePeriod = 14 * 24 * time.Hour
+
 func RequireRole(roles ...models.WorkspaceMemberRole) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +40,7 @@ func RequireRole(roles ...models.WorkspaceMemberRole) func(http.Handler) http.Ha
 
 			for _, allowedRole := range roles {
 				if role == allowedRole {
-					next.ServeHTTP(w, r)
+					RequireWorkspaceMFA(next).ServeHTTP(w, r)
 					return
 				}
 			}
@@ -46,6 +50,94 @@ func RequireRole(roles ...models.WorkspaceMemberRole) func(http.Handler) http.Ha
 	}
 }
 
+// RequireWorkspaceMFA rejects requests to a workspace that requires MFA from
+// users who have not enabled it, and requests from the workspace's owner once
+// their grace period for enrolling has run out. Paths that do not name a
+// workspace pass through.
+func RequireWorkspaceMFA(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workspaceID, err := extractWorkspaceID(r.URL.Path)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, ok := GetUserID(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		allowed, err := SatisfiesWorkspaceMFA(userID, workspaceID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "Forbidden: Multi-factor authentication required", http.StatusForbidden)
+			return
+		}
+
+		allowed, err = SatisfiesOwnerMFA(userID, workspaceID, time.Now())
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "Forbidden: Workspace owners must enable multi-factor authentication", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SatisfiesWorkspaceMFA reports whether the user meets the workspace's MFA
+// policy. Unknown workspaces are left for the handler to report.
+func SatisfiesWorkspaceMFA(userID, workspaceID int) (bool, error) {
+	store := repository.Default()
+	required, err := store.Workspaces.RequiresMFA(workspaceID)
+	if err == repository.ErrNotFound {
+		return true, nil
+	}
+	if err != nil || !required {
+		return err == nil, err
+	}
+
+	settings, err := store.MFA.Get(userID)
+	if err == repository.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return settings.Enabled(), nil
+}
+
+// SatisfiesOwnerMFA reports whether the user may act on the workspace as far as
+// owner enrollment goes. Owners without MFA are allowed until their deadline,
+// which starts the first time they are checked; everyone else always is.
+func SatisfiesOwnerMFA(userID, workspaceID int, now time.Time) (bool, error) {
+	isOwner, err := IsWorkspaceOwner(userID, workspaceID)
+	if err != nil || !isOwner {
+		return err == nil, err
+	}
+
+	store := repository.Default()
+	settings, err := store.MFA.Get(userID)
+	if err != nil {
+		return false, err
+	}
+	if settings.Enabled() {
+		return true, nil
+	}
+	deadline, err := store.MFA.RequireBy(userID, now.Add(ownerMFAGracePeriod))
+	if err != nil {
+		return false, err
+	}
+	return now.Before(deadline), nil
+}
+
 func RequireOwner(next http.Handler) http.Handler {
 	return RequireRole(models.RoleOwner)(next)
 }
@@ -59,21 +151,17 @@ func RequireViewer(next http.Handler) http.Handler {
 }
 
 func GetWorkspaceRole(userID, workspaceID int) (models.WorkspaceMemberRole, error) {
-	var role string
-	err := database.DB.QueryRow(
-		"SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?",
-		workspaceID, userID,
-	).Scan(&role)
+	store := repository.Default()
+	role, err := store.Members.Role(workspaceID, userID)
 	if err == nil {
-		return models.WorkspaceMemberRole(role), nil
+		return role, nil
 	}
-	if err != sql.ErrNoRows {
+	if err != repository.ErrNotFound {
 		return "", err
 	}
 
-	var ownerID int
-	err = database.DB.QueryRow("SELECT owner_id FROM workspaces WHERE id = ?", workspaceID).Scan(&ownerID)
-	if err == sql.ErrNoRows {
+	ownerID, err := store.Workspaces.OwnerID(workspaceID)
+	if err == repository.ErrNotFound {
 		return "", nil
 	}
 	if err != nil {
@@ -86,9 +174,8 @@ func GetWorkspaceRole(userID, workspaceID int) (models.WorkspaceMemberRole, erro
 }
 
 func IsWorkspaceOwner(userID, workspaceID int) (bool, error) {
-	var ownerID int
-	err := database.DB.QueryRow("SELECT owner_id FROM workspaces WHERE id = ?", workspaceID).Scan(&ownerID)
-	if err == sql.ErrNoRows {
+	ownerID, err := repository.Default().Workspaces.OwnerID(workspaceID)
+	if err == repository.ErrNotFound {
 		return false, nil
 	}
 	if err != nil {
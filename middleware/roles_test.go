@@ -2,61 +2,24 @@ package middleware
 
 import (
 	"context"
-	"database/sql"
 	"net/http"
 	"net/http/httptest"
 	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
 	"sentinent-backend/models"
 	"testing"
-
-	_ "github.com/mattn/go-sqlite3"
+	"time"
 )
 
 func setupRolesTestDB(t *testing.T) {
 	t.Helper()
 
-	var err error
-	database.DB, err = sql.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("failed to open db: %v", err)
-	}
-
-	statements := []string{
-		`CREATE TABLE workspaces (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			owner_id INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE TABLE workspace_members (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			workspace_id INTEGER NOT NULL,
-			user_id INTEGER NOT NULL,
-			role TEXT NOT NULL,
-			joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(workspace_id, user_id)
-		);`,
-	}
-	for _, statement := range statements {
-		if _, err := database.DB.Exec(statement); err != nil {
-			t.Fatalf("failed to create schema: %v", err)
-		}
-	}
-
-	if _, err := database.DB.Exec("INSERT INTO workspaces (id, name, owner_id) VALUES (9, 'Sentinent', 1)"); err != nil {
-		t.Fatalf("failed to insert workspace: %v", err)
-	}
-	if _, err := database.DB.Exec(
-		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (9, 2, 'member'), (9, 3, 'viewer')",
-	); err != nil {
-		t.Fatalf("failed to insert members: %v", err)
-	}
-
-	t.Cleanup(func() {
-		_ = database.DB.Close()
-	})
+	dbtest.Open(t)
+	dbtest.Seed(t,
+		`INSERT INTO users (id, email, password) VALUES (1, 'owner@example.com', 'x'), (2, 'member@example.com', 'x'), (3, 'viewer@example.com', 'x')`,
+		`INSERT INTO workspaces (id, name, owner_id) VALUES (9, 'Sentinent', 1)`,
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (9, 2, 'member'), (9, 3, 'viewer')`,
+	)
 }
 
 func TestGetWorkspaceRoleFallsBackToOwnerID(t *testing.T) {
@@ -116,3 +79,75 @@ func TestRequireViewerAllowsViewer(t *testing.T) {
 		t.Fatal("expected downstream handler to be called")
 	}
 }
+
+func TestRequireWorkspaceMFABlocksMembersWithoutMFA(t *testing.T) {
+	setupRolesTestDB(t)
+
+	if _, err := database.DB.Exec("UPDATE users SET mfa_enabled_at = CURRENT_TIMESTAMP WHERE id = 1"); err != nil {
+		t.Fatalf("failed to enable MFA: %v", err)
+	}
+	if _, err := database.DB.Exec("UPDATE workspaces SET require_mfa = TRUE WHERE id = 9"); err != nil {
+		t.Fatalf("failed to require MFA: %v", err)
+	}
+
+	handler := RequireWorkspaceMFA(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	statusFor := func(userID int) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/workspaces/9/members", nil)
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, userID))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if status := statusFor(1); status != http.StatusNoContent {
+		t.Fatalf("expected user with MFA to pass, got %d", status)
+	}
+	if status := statusFor(2); status != http.StatusForbidden {
+		t.Fatalf("expected user without MFA to be rejected, got %d", status)
+	}
+}
+
+func TestRequireWorkspaceMFABlocksOwnersAfterGracePeriod(t *testing.T) {
+	setupRolesTestDB(t)
+
+	handler := RequireWorkspaceMFA(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	statusFor := func(userID int) int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/workspaces/9", nil)
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, userID))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if status := statusFor(1); status != http.StatusNoContent {
+		t.Fatalf("expected owner to pass during the grace period, got %d", status)
+	}
+	var requiredBy time.Time
+	if err := database.DB.QueryRow("SELECT mfa_required_by FROM users WHERE id = 1").Scan(&requiredBy); err != nil {
+		t.Fatalf("expected the owner's deadline to be set: %v", err)
+	}
+	if remaining := time.Until(requiredBy); remaining < ownerMFAGracePeriod-time.Minute || remaining > ownerMFAGracePeriod {
+		t.Fatalf("expected a deadline one grace period away, got %v", requiredBy)
+	}
+
+	if _, err := database.DB.Exec("UPDATE users SET mfa_required_by = ? WHERE id = 1", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to expire the grace period: %v", err)
+	}
+	if status := statusFor(1); status != http.StatusForbidden {
+		t.Fatalf("expected owner without MFA to be rejected after the grace period, got %d", status)
+	}
+	if status := statusFor(2); status != http.StatusNoContent {
+		t.Fatalf("expected member to be unaffected, got %d", status)
+	}
+
+	if _, err := database.DB.Exec("UPDATE users SET mfa_enabled_at = CURRENT_TIMESTAMP WHERE id = 1"); err != nil {
+		t.Fatalf("failed to enable MFA: %v", err)
+	}
+	if status := statusFor(1); status != http.StatusNoContent {
+		t.Fatalf("expected owner with MFA to pass, got %d", status)
+	}
+}
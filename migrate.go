@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sentinent-backend/database"
+)
+
+const migrateUsage = "usage: sentinent-backend migrate [status|up]"
+
+// runMigrateCommand implements the `migrate` subcommand. `status` lists known
+// migrations and whether they are applied; `up` applies pending ones.
+func runMigrateCommand(args []string, out io.Writer) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if len(args) > 1 || (action != "status" && action != "up") {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	if err := database.OpenDB(); err != nil {
+		return err
+	}
+	defer database.DB.Close()
+
+	switch action {
+	case "up":
+		applied, err := database.MigrateUp()
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return nil
+	default:
+		version, err := database.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		states, err := database.MigrationStatus()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "schema version %d (latest %d)\n", version, database.LatestSchemaVersion())
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%4d  %-24s %s\n", state.Version, state.Name, applied)
+		}
+		if version > database.LatestSchemaVersion() {
+			return database.ErrSchemaTooNew
+		}
+		return nil
+	}
+}
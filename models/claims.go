@@ -3,7 +3,8 @@ package models
 import "github.com/golang-jwt/jwt/v5"
 
 type Claims struct {
-	UserID int    `json:"user_id,omitempty"`
-	Email  string `json:"email"`
+	UserID    int    `json:"user_id,omitempty"`
+	Email     string `json:"email"`
+	SessionID int    `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
@@ -20,6 +20,31 @@ type Decision struct {
 	DueDate     *time.Time     `json:"due_date,omitempty"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
+
+	// Options, Ballots and MyRanking are only filled in for a single decision.
+	Options   []DecisionOption `json:"options,omitempty"`
+	Ballots   int              `json:"ballots,omitempty"`
+	MyRanking []int            `json:"my_ranking,omitempty"`
+	Outcome   *DecisionOutcome `json:"outcome,omitempty"`
+}
+
+// DecisionOption is a candidate choice with its tally: Votes counts first
+// preferences, Points is the Borda count over all ranked ballots.
+type DecisionOption struct {
+	ID          int    `json:"id"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	Position    int    `json:"position"`
+	Votes       int    `json:"votes"`
+	Points      int    `json:"points"`
+}
+
+// DecisionOutcome is recorded when a decision is closed.
+type DecisionOutcome struct {
+	OptionID  *int      `json:"option_id,omitempty"`
+	Rationale string    `json:"rationale"`
+	ClosedBy  int       `json:"closed_by"`
+	ClosedAt  time.Time `json:"closed_at"`
 }
 
 type DecisionRequest struct {
@@ -27,4 +52,41 @@ type DecisionRequest struct {
 	Description string         `json:"description"`
 	Status      DecisionStatus `json:"status"`
 	DueDate     *time.Time     `json:"due_date"`
+
+	// Options replaces the candidate options when set; nil leaves them alone.
+	Options []DecisionOptionRequest `json:"options"`
+	// OutcomeOptionID and Rationale are only accepted when closing.
+	OutcomeOptionID *int   `json:"outcome_option_id"`
+	Rationale       string `json:"rationale"`
+}
+
+type DecisionOptionRequest struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+}
+
+// DecisionVoteRequest is a ranked ballot, most preferred option first. A
+// single option is a plain vote.
+type DecisionVoteRequest struct {
+	OptionIDs []int `json:"option_ids"`
+}
+
+// LinkedSignal is a signal attached to a decision as evidence.
+type LinkedSignal struct {
+	Signal
+	LinkedBy int       `json:"linked_by"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// DecisionReference is the summary of a decision shown on a signal it cites.
+type DecisionReference struct {
+	ID          int            `json:"id"`
+	WorkspaceID int            `json:"workspace_id"`
+	Title       string         `json:"title"`
+	Status      DecisionStatus `json:"status"`
+	LinkedAt    time.Time      `json:"linked_at"`
+}
+
+type DecisionSignalRequest struct {
+	SignalID int `json:"signal_id"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	SignalEventCreated       = "signal.created"
+	SignalEventUpdated       = "signal.updated"
+	SignalEventStatusChanged = "signal.status_changed"
+	SignalEventArchived      = "signal.archived"
+)
+
+// SignalEvent is one change to a workspace signal, as pushed to the
+// workspace's signal stream. Created and updated events carry the signal with
+// its default status. Status changes are per member, so events with a UserID
+// only reach that member; archiving an item deleted at the source reaches
+// everyone.
+type SignalEvent struct {
+	ID           int64      `json:"id"`
+	Type         string     `json:"type"`
+	WorkspaceID  int        `json:"workspace_id"`
+	SignalID     int        `json:"signal_id"`
+	UserID       int        `json:"user_id,omitempty"`
+	Status       string     `json:"status,omitempty"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	Signal       *Signal    `json:"signal,omitempty"`
+	OccurredAt   time.Time  `json:"occurred_at"`
+}
@@ -11,13 +11,20 @@ type ExternalIntegration struct {
 	RefreshToken string     `json:"-"`
 	Metadata     string     `json:"metadata,omitempty"`
 	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	// ReauthRequiredAt is set when the provider rejected the stored
+	// credentials; the integration is not synced until the user reconnects.
+	ReauthRequiredAt *time.Time `json:"reauth_required_at,omitempty"`
+	ReauthReason     string     `json:"reauth_reason,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
+// IntegrationStatus is one provider's entry in the status list. Status is
+// "disconnected" or, for a connected integration, its health status.
 type IntegrationStatus struct {
 	Provider   string    `json:"provider"`
 	Configured bool      `json:"configured"`
 	Connected  bool      `json:"connected"`
+	Status     string    `json:"status"`
 	UpdatedAt  time.Time `json:"updated_at,omitempty"`
 }
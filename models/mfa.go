@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// MFASettings is a user's TOTP enrollment. EncryptedSecret is set as soon as
+// setup starts; EnabledAt only once the user has confirmed a code from it.
+// RequiredBy is when a workspace owner has to have enrolled by.
+type MFASettings struct {
+	UserID          int
+	EncryptedSecret string
+	EnabledAt       *time.Time
+	LastUsedStep    int64
+	FailedAttempts  int
+	LockedUntil     *time.Time
+	Logins          int
+	RequiredBy      *time.Time
+}
+
+func (m MFASettings) Enabled() bool {
+	return m.EnabledAt != nil
+}
+
+// Locked reports whether too many wrong codes keep the user from signing in.
+func (m MFASettings) Locked(now time.Time) bool {
+	return m.LockedUntil != nil && now.Before(*m.LockedUntil)
+}
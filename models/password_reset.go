@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PasswordReset is an outstanding password reset token. Only the token's hash
+// is stored.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
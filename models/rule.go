@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+const (
+	SignalPriorityLow    = "low"
+	SignalPriorityMedium = "medium"
+	SignalPriorityHigh   = "high"
+	SignalPriorityUrgent = "urgent"
+)
+
+// IsSignalPriority reports whether priority is one a rule can set.
+func IsSignalPriority(priority string) bool {
+	switch priority {
+	case SignalPriorityLow, SignalPriorityMedium, SignalPriorityHigh, SignalPriorityUrgent:
+		return true
+	}
+	return false
+}
+
+// SignalRule routes and labels a workspace's signals as they are synced.
+// Enabled rules run in Position order, then by ID, and each acts on a signal
+// at most once.
+type SignalRule struct {
+	ID          int            `json:"id"`
+	WorkspaceID int            `json:"workspace_id"`
+	Name        string         `json:"name"`
+	Position    int            `json:"position"`
+	Enabled     bool           `json:"enabled"`
+	Conditions  RuleConditions `json:"conditions"`
+	Actions     RuleActions    `json:"actions"`
+	CreatedBy   int            `json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// RuleConditions must all hold for a rule to match. A list matches when any of
+// its values does; string comparisons ignore case. TitlePattern and
+// ContentPattern are Go regular expressions, and ContentPattern is tried
+// against both content and body. The source-specific conditions only match
+// signals of that source.
+type RuleConditions struct {
+	SourceTypes    []string `json:"source_types,omitempty"`
+	Authors        []string `json:"authors,omitempty"`
+	TitlePattern   string   `json:"title_pattern,omitempty"`
+	ContentPattern string   `json:"content_pattern,omitempty"`
+	GitHubLabels   []string `json:"github_labels,omitempty"`
+	JiraPriorities []string `json:"jira_priorities,omitempty"`
+	SlackChannels  []string `json:"slack_channels,omitempty"`
+}
+
+// Empty reports whether no condition is set, which would match every signal.
+func (c RuleConditions) Empty() bool {
+	return len(c.SourceTypes) == 0 && len(c.Authors) == 0 && c.TitlePattern == "" && c.ContentPattern == "" &&
+		len(c.GitHubLabels) == 0 && len(c.JiraPriorities) == 0 && len(c.SlackChannels) == 0
+}
+
+// RuleActions are applied to each signal a rule matches. Archive sets the
+// signal's default status, so members who already triaged it keep their own.
+// DecisionID links the signal only while the decision is open.
+type RuleActions struct {
+	AddLabels  []string `json:"add_labels,omitempty"`
+	Priority   string   `json:"priority,omitempty"`
+	AssigneeID *int     `json:"assignee_id,omitempty"`
+	Archive    bool     `json:"archive,omitempty"`
+	DecisionID *int     `json:"decision_id,omitempty"`
+}
+
+// Empty reports whether the rule would do nothing.
+func (a RuleActions) Empty() bool {
+	return len(a.AddLabels) == 0 && a.Priority == "" && a.AssigneeID == nil && !a.Archive && a.DecisionID == nil
+}
+
+// SignalRuleRequest creates or replaces a rule. Enabled defaults to true.
+type SignalRuleRequest struct {
+	Name       string         `json:"name"`
+	Position   int            `json:"position"`
+	Enabled    *bool          `json:"enabled,omitempty"`
+	Conditions RuleConditions `json:"conditions"`
+	Actions    RuleActions    `json:"actions"`
+}
+
+// RuleDryRunResponse reports which of the workspace's existing signals a rule
+// would match. Signals holds the newest matches, up to the dry run's limit.
+type RuleDryRunResponse struct {
+	Matched int      `json:"matched"`
+	Scanned int      `json:"scanned"`
+	Signals []Signal `json:"signals"`
+}
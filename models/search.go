@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+const (
+	SearchTypeSignal   = "signal"
+	SearchTypeDecision = "decision"
+)
+
+// SearchQuery is a parsed workspace search. Terms match any indexed field,
+// TitleTerms and AuthorTerms only that field. A term ending in * matches as a
+// prefix. Source and Type narrow results without affecting ranking.
+type SearchQuery struct {
+	Terms       []string
+	TitleTerms  []string
+	AuthorTerms []string
+	Source      string
+	Type        string
+	Limit       int
+}
+
+// HasText reports whether the query has anything to match against.
+func (q SearchQuery) HasText() bool {
+	return len(q.Terms)+len(q.TitleTerms)+len(q.AuthorTerms) > 0
+}
+
+// SearchResult is one ranked hit. Snippet is HTML-escaped text with matches
+// wrapped in <mark> tags.
+type SearchResult struct {
+	Type       string    `json:"type"`
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Snippet    string    `json:"snippet"`
+	Score      float64   `json:"score"`
+	SourceType string    `json:"source_type,omitempty"`
+	Author     string    `json:"author,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Session backs a refresh token. Only hashes of the current and the previous
+// refresh token are stored; presenting the previous one revokes the session.
+type Session struct {
+	ID                       int        `json:"id"`
+	UserID                   int        `json:"user_id"`
+	RefreshTokenHash         string     `json:"-"`
+	PreviousRefreshTokenHash string     `json:"-"`
+	UserAgent                string     `json:"user_agent,omitempty"`
+	CreatedAt                time.Time  `json:"created_at"`
+	LastUsedAt               *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt                time.Time  `json:"expires_at"`
+	RevokedAt                *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the session can still mint access tokens.
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -3,14 +3,35 @@ package models
 import "time"
 
 const (
-	SourceTypeSlack      = "slack"
-	SourceTypeGitHub     = "github"
-	SourceTypeJira       = "jira"
-	SignalStatusUnread   = "unread"
-	SignalStatusRead     = "read"
-	SignalStatusArchived = "archived"
+	SourceTypeSlack         = "slack"
+	SourceTypeGitHub        = "github"
+	SourceTypeJira          = "jira"
+	SourceTypeGmail         = "gmail"
+	SignalStatusUnread      = "unread"
+	SignalStatusRead        = "read"
+	SignalStatusArchived    = "archived"
+	SignalStatusNeedsAction = "needs_action"
+	SignalStatusDone        = "done"
+	SignalStatusSnoozed     = "snoozed"
 )
 
+// Triage actions accepted by SignalTriage.Action.
+const (
+	SignalActionStatus = "status"
+	SignalActionSnooze = "snooze"
+	SignalActionAssign = "assign"
+)
+
+// IsSignalStatus reports whether status can be set directly on a signal.
+// Snoozed is reached only by snoozing, which needs a wake-up time.
+func IsSignalStatus(status string) bool {
+	switch status {
+	case SignalStatusUnread, SignalStatusRead, SignalStatusArchived, SignalStatusNeedsAction, SignalStatusDone:
+		return true
+	}
+	return false
+}
+
 type Signal struct {
 	ID             int         `json:"id"`
 	UserID         int         `json:"user_id"`
@@ -24,10 +45,16 @@ type Signal struct {
 	Body           string      `json:"body,omitempty"`
 	URL            string      `json:"url,omitempty"`
 	Status         string      `json:"status"`
+	SnoozedUntil   *time.Time  `json:"snoozed_until,omitempty"`
+	AssigneeID     *int        `json:"assignee_id,omitempty"`
+	Labels         []string    `json:"labels,omitempty"`
+	Priority       string      `json:"priority,omitempty"`
 	SourceMetadata interface{} `json:"source_metadata,omitempty"`
 	ReceivedAt     time.Time   `json:"received_at,omitempty"`
 	CreatedAt      time.Time   `json:"created_at"`
 	UpdatedAt      time.Time   `json:"updated_at"`
+
+	Decisions []DecisionReference `json:"decisions,omitempty"`
 }
 
 type GitHubMetadata struct {
@@ -39,22 +66,89 @@ type GitHubMetadata struct {
 }
 
 type JiraMetadata struct {
-	ProjectKey   string `json:"project_key"`
-	IssueType    string `json:"issue_type"`
-	Priority     string `json:"priority,omitempty"`
-	Status       string `json:"status"`
-	IssueKey     string `json:"issue_key"`
-	AssigneeName string `json:"assignee_name,omitempty"`
+	ProjectKey   string   `json:"project_key"`
+	IssueType    string   `json:"issue_type"`
+	Priority     string   `json:"priority,omitempty"`
+	Status       string   `json:"status"`
+	IssueKey     string   `json:"issue_key"`
+	AssigneeName string   `json:"assignee_name,omitempty"`
+	Labels       []string `json:"labels,omitempty"`
+}
+
+// SlackMetadata describes a Slack message signal. Replies holds the thread
+// under the message and Reactions counts each emoji reaction.
+type SlackMetadata struct {
+	ChannelID  string         `json:"channel_id"`
+	TS         string         `json:"ts"`
+	UserID     string         `json:"user_id"`
+	ReplyCount int            `json:"reply_count,omitempty"`
+	Replies    []SlackReply   `json:"replies,omitempty"`
+	Reactions  map[string]int `json:"reactions,omitempty"`
+	EditedTS   string         `json:"edited_ts,omitempty"`
 }
 
+// SlackReply is one reply in a Slack thread.
+type SlackReply struct {
+	TS       string `json:"ts"`
+	UserID   string `json:"user_id"`
+	Author   string `json:"author,omitempty"`
+	Text     string `json:"text"`
+	EditedTS string `json:"edited_ts,omitempty"`
+}
+
+type GmailMetadata struct {
+	ThreadID     string   `json:"thread_id"`
+	MessageID    string   `json:"message_id,omitempty"`
+	Labels       []string `json:"labels,omitempty"`
+	From         string   `json:"from"`
+	To           []string `json:"to,omitempty"`
+	MessageCount int      `json:"message_count,omitempty"`
+}
+
+// SignalFilter selects and pages signals. Zero values leave a filter off;
+// WorkspaceID 0 lists the user's signals across workspaces. Cursor is a value
+// from a previous SignalListResponse and takes precedence over Offset.
 type SignalFilter struct {
-	SourceType string `json:"source_type,omitempty"`
-	Status     string `json:"status,omitempty"`
-	Limit      int    `json:"limit,omitempty"`
-	Offset     int    `json:"offset,omitempty"`
+	WorkspaceID  int        `json:"workspace_id,omitempty"`
+	SourceTypes  []string   `json:"source_types,omitempty"`
+	Status       string     `json:"status,omitempty"`
+	Author       string     `json:"author,omitempty"`
+	Since        *time.Time `json:"since,omitempty"`
+	Until        *time.Time `json:"until,omitempty"`
+	Repositories []string   `json:"repositories,omitempty"`
+	ProjectKeys  []string   `json:"project_keys,omitempty"`
+	HasDecision  *bool      `json:"has_decision,omitempty"`
+	AssigneeID   int        `json:"assignee_id,omitempty"`
+	Label        string     `json:"label,omitempty"`
+	Priority     string     `json:"priority,omitempty"`
+	Cursor       string     `json:"cursor,omitempty"`
+	Limit        int        `json:"limit,omitempty"`
+	Offset       int        `json:"offset,omitempty"`
 }
 
+// SignalListResponse is one page of signals, newest first. Total counts every
+// signal matching the filter; the cursors fetch the neighbouring pages and are
+// omitted at either end.
 type SignalListResponse struct {
-	Signals []Signal `json:"signals"`
-	Total   int      `json:"total"`
+	Signals    []Signal `json:"signals"`
+	Total      int      `json:"total"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	PrevCursor string   `json:"prev_cursor,omitempty"`
+}
+
+// SignalTriage is one triage change. Status and snoozes are the caller's own,
+// like read and archived; the assignee is shared by the whole workspace.
+// AssigneeID nil unassigns.
+type SignalTriage struct {
+	Action       string     `json:"action"`
+	Status       string     `json:"status,omitempty"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	AssigneeID   *int       `json:"assignee_id,omitempty"`
+}
+
+// BulkSignalTriageRequest applies one triage change to several signals at
+// once. Either every signal is changed or none is.
+type BulkSignalTriageRequest struct {
+	SignalIDs []int `json:"signal_ids"`
+	SignalTriage
 }
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+var (
+	sourceMetadataMu    sync.RWMutex
+	sourceMetadataTypes = map[string]func() interface{}{
+		SourceTypeGitHub: func() interface{} { return &GitHubMetadata{} },
+		SourceTypeJira:   func() interface{} { return &JiraMetadata{} },
+		SourceTypeSlack:  func() interface{} { return &SlackMetadata{} },
+		SourceTypeGmail:  func() interface{} { return &GmailMetadata{} },
+	}
+)
+
+// RegisterSourceMetadata sets the metadata type decoded for a source type.
+// newMetadata must return a pointer for json.Unmarshal to fill in.
+func RegisterSourceMetadata(sourceType string, newMetadata func() interface{}) {
+	sourceMetadataMu.Lock()
+	defer sourceMetadataMu.Unlock()
+	sourceMetadataTypes[sourceType] = newMetadata
+}
+
+// DecodeSourceMetadata decodes a signal's stored source_metadata into the type
+// registered for its source type, for example *GitHubMetadata for "github".
+// Metadata of unregistered source types is returned as json.RawMessage, and
+// empty metadata as nil.
+func DecodeSourceMetadata(sourceType string, raw []byte) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	sourceMetadataMu.RLock()
+	newMetadata, ok := sourceMetadataTypes[sourceType]
+	sourceMetadataMu.RUnlock()
+	if !ok {
+		var passthrough json.RawMessage
+		if err := json.Unmarshal(raw, &passthrough); err != nil {
+			return nil, err
+		}
+		return passthrough, nil
+	}
+
+	metadata := newMetadata()
+	if err := json.Unmarshal(raw, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
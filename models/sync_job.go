@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	SyncJobPending   = "pending"
+	SyncJobRunning   = "running"
+	SyncJobSucceeded = "succeeded"
+	// SyncJobDead marks a job that failed on every attempt and will not be
+	// retried. The scheduler queues a new job for its integration once the
+	// dead job has cooled down.
+	SyncJobDead = "dead"
+)
+
+// SyncJob is one queued sync of an integration. Failed attempts are retried
+// with backoff by moving the job back to pending with a later RunAt.
+type SyncJob struct {
+	ID            int        `json:"id"`
+	IntegrationID int        `json:"integration_id"`
+	UserID        int        `json:"user_id"`
+	WorkspaceID   int        `json:"workspace_id,omitempty"`
+	Provider      string     `json:"provider"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	RunAt         time.Time  `json:"run_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	// LeaseExpiresAt is when a running job is given up on unless its worker
+	// renews the lease first.
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+const (
+	SyncRunRunning   = "running"
+	SyncRunSucceeded = "succeeded"
+	SyncRunFailed    = "failed"
+)
+
+// SyncRun records one execution of an integration sync.
+type SyncRun struct {
+	ID            int        `json:"id"`
+	IntegrationID int        `json:"integration_id"`
+	JobID         int        `json:"job_id,omitempty"`
+	UserID        int        `json:"user_id"`
+	WorkspaceID   int        `json:"workspace_id,omitempty"`
+	Provider      string     `json:"provider"`
+	Status        string     `json:"status"`
+	ItemsFetched  int        `json:"items_fetched"`
+	ItemsUpserted int        `json:"items_upserted"`
+	ItemsFailed   int        `json:"items_failed"`
+	Error         string     `json:"error,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+const (
+	IntegrationHealthy     = "healthy"
+	IntegrationNeverSynced = "never_synced"
+	IntegrationDegraded    = "degraded"
+	// IntegrationFailing means the latest sync job ran out of attempts. The
+	// scheduler tries again after a cooldown.
+	IntegrationFailing        = "failing"
+	IntegrationReauthRequired = "reauth_required"
+	IntegrationDisconnected   = "disconnected"
+)
+
+// IntegrationHealth summarizes an integration's recent sync runs and token.
+// ItemsFetched, ItemsUpserted and ItemsFailed are from the latest finished run.
+// DeadLetteredAt is set when the latest sync job ran out of attempts, and
+// NextRetryAt is when the scheduler queues the next one.
+type IntegrationHealth struct {
+	IntegrationID       int        `json:"integration_id"`
+	Provider            string     `json:"provider"`
+	Status              string     `json:"status"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DeadLetteredAt      *time.Time `json:"dead_lettered_at,omitempty"`
+	NextRetryAt         *time.Time `json:"next_retry_at,omitempty"`
+	ItemsFetched        int        `json:"items_fetched"`
+	ItemsUpserted       int        `json:"items_upserted"`
+	ItemsFailed         int        `json:"items_failed"`
+	TokenExpiresAt      *time.Time `json:"token_expires_at,omitempty"`
+	LastRun             *SyncRun   `json:"last_run,omitempty"`
+}
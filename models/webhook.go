@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookEventDecisionCreated    = "decision.created"
+	WebhookEventDecisionClosed     = "decision.closed"
+	WebhookEventSignalCreated      = SignalEventCreated
+	WebhookEventMemberJoined       = "member.joined"
+	WebhookEventInvitationAccepted = "invitation.accepted"
+)
+
+// IsWebhookEvent reports whether a webhook can subscribe to eventType.
+func IsWebhookEvent(eventType string) bool {
+	switch eventType {
+	case WebhookEventDecisionCreated, WebhookEventDecisionClosed, WebhookEventSignalCreated,
+		WebhookEventMemberJoined, WebhookEventInvitationAccepted:
+		return true
+	}
+	return false
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is an endpoint a workspace owner registered to receive the
+// workspace's events. Deliveries are signed with Secret, which is only
+// returned when the webhook is created.
+type Webhook struct {
+	ID          int       `json:"id"`
+	WorkspaceID int       `json:"workspace_id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Enabled     bool      `json:"enabled"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedBy   int       `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookRequest creates a webhook, or changes the fields it sets. Enabled
+// defaults to true.
+type WebhookRequest struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled,omitempty"`
+}
+
+// WebhookPayload is the body posted to a webhook. ID identifies the event, and
+// stays the same across retries and redeliveries.
+type WebhookPayload struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"`
+	WorkspaceID int         `json:"workspace_id"`
+	OccurredAt  time.Time   `json:"occurred_at"`
+	Data        interface{} `json:"data"`
+}
+
+// WebhookDelivery is one attempt, with its retries, to post an event to a
+// webhook. Payload is the exact body sent.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	RedeliveryOf   *int            `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookMember is the data of a member.joined event.
+type WebhookMember struct {
+	UserID int                 `json:"user_id"`
+	Email  string              `json:"email"`
+	Role   WorkspaceMemberRole `json:"role"`
+}
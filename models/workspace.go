@@ -7,6 +7,7 @@ type Workspace struct {
 	Name        string    `json:"name"`
 	Description string    `json:"description,omitempty"`
 	OwnerID     int       `json:"owner_id"`
+	RequireMFA  bool      `json:"require_mfa"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -15,3 +16,7 @@ type WorkspaceRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 }
+
+type WorkspaceMFAPolicyRequest struct {
+	RequireMFA bool `json:"require_mfa"`
+}
@@ -0,0 +1,443 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sentinent-backend/models"
+	"time"
+)
+
+// ErrDecisionClosed is returned when closing a decision that is already closed.
+var ErrDecisionClosed = errors.New("decision is already closed")
+
+type DecisionRepository struct {
+	*queries
+}
+
+const decisionColumns = `id, workspace_id, user_id, title, COALESCE(description, ''), status, due_date, created_at, updated_at,
+	outcome_option_id, COALESCE(outcome_rationale, ''), closed_by, closed_at`
+
+// decisionChildren removes the rows hanging off one decision. Each statement
+// takes the decision and workspace IDs.
+var decisionChildren = []string{
+	`DELETE FROM decision_votes WHERE decision_id IN (SELECT id FROM decisions WHERE id = ? AND workspace_id = ?)`,
+	`DELETE FROM decision_options WHERE decision_id IN (SELECT id FROM decisions WHERE id = ? AND workspace_id = ?)`,
+	`DELETE FROM decision_signals WHERE decision_id IN (SELECT id FROM decisions WHERE id = ? AND workspace_id = ?)`,
+}
+
+func (r *DecisionRepository) List(workspaceID int) ([]models.Decision, error) {
+	rows, err := r.db.Query(
+		`SELECT `+decisionColumns+`
+		 FROM decisions
+		 WHERE workspace_id = ?
+		 ORDER BY updated_at DESC, id DESC`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := make([]models.Decision, 0)
+	for rows.Next() {
+		decision, err := scanDecision(rows)
+		if err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, *decision)
+	}
+	return decisions, rows.Err()
+}
+
+func (r *DecisionRepository) Create(workspaceID, userID int, req models.DecisionRequest) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	decisionID, err := r.insertID(tx,
+		`INSERT INTO decisions (workspace_id, user_id, title, description, status, due_date, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		workspaceID, userID, req.Title, req.Description, req.Status, req.DueDate,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := insertDecisionOptions(tx, decisionID, req.Options); err != nil {
+		return 0, err
+	}
+	return decisionID, tx.Commit()
+}
+
+func (r *DecisionRepository) Get(workspaceID, decisionID int) (*models.Decision, error) {
+	return scanDecision(r.db.QueryRow(
+		`SELECT `+decisionColumns+`
+		 FROM decisions
+		 WHERE workspace_id = ? AND id = ?`,
+		workspaceID, decisionID,
+	))
+}
+
+// Update saves the decision's fields. When req.Options is set the options are
+// replaced, which discards every ballot cast on the old ones.
+// Update applies req to a decision. With an outcome, the decision is closed in
+// the same transaction, and ErrDecisionClosed is returned if it already was.
+func (r *DecisionRepository) Update(workspaceID, decisionID int, req models.DecisionRequest, outcome *models.DecisionOutcome) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Close first, so that of two requests closing the same decision only the
+	// one that finds it open goes through.
+	status := req.Status
+	if outcome != nil {
+		result, err := tx.Exec(
+			`UPDATE decisions
+			 SET status = ?, outcome_option_id = ?, outcome_rationale = ?, closed_by = ?, closed_at = ?
+			 WHERE id = ? AND workspace_id = ? AND status <> ?`,
+			models.DecisionStatusClosed, outcome.OptionID, outcome.Rationale, outcome.ClosedBy, outcome.ClosedAt,
+			decisionID, workspaceID, models.DecisionStatusClosed,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			var exists bool
+			err := tx.QueryRow(
+				`SELECT EXISTS (SELECT 1 FROM decisions WHERE id = ? AND workspace_id = ?)`,
+				decisionID, workspaceID,
+			).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrDecisionClosed
+			}
+			return ErrNotFound
+		}
+		status = models.DecisionStatusClosed
+	}
+
+	result, err := tx.Exec(
+		`UPDATE decisions
+		 SET title = ?, description = ?, status = ?, due_date = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND workspace_id = ?`,
+		req.Title, req.Description, status, req.DueDate, decisionID, workspaceID,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	if req.Options != nil {
+		for _, statement := range []string{
+			`DELETE FROM decision_votes WHERE decision_id = ?`,
+			`DELETE FROM decision_options WHERE decision_id = ?`,
+		} {
+			if _, err := tx.Exec(statement, decisionID); err != nil {
+				return err
+			}
+		}
+		if err := insertDecisionOptions(tx, decisionID, req.Options); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *DecisionRepository) Delete(workspaceID, decisionID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range decisionChildren {
+		if _, err := tx.Exec(statement, decisionID, workspaceID); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec(
+		`DELETE FROM decisions WHERE id = ? AND workspace_id = ?`,
+		decisionID, workspaceID,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// Vote replaces userID's ballot, most preferred option first. It returns
+// ErrNotFound unless the decision is OPEN; callers check the options belong
+// to the decision.
+func (r *DecisionRepository) Vote(decisionID, userID int, optionIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var open bool
+	if err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM decisions WHERE id = ? AND status = ?)",
+		decisionID, models.DecisionStatusOpen,
+	).Scan(&open); err != nil {
+		return err
+	}
+	if !open {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM decision_votes WHERE decision_id = ? AND user_id = ?`,
+		decisionID, userID,
+	); err != nil {
+		return err
+	}
+	for index, optionID := range optionIDs {
+		if _, err := tx.Exec(
+			`INSERT INTO decision_votes (decision_id, user_id, option_id, preference) VALUES (?, ?, ?, ?)`,
+			decisionID, userID, optionID, index+1,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ClearVote withdraws userID's ballot.
+func (r *DecisionRepository) ClearVote(decisionID, userID int) error {
+	return r.execAffecting(
+		`DELETE FROM decision_votes WHERE decision_id = ? AND user_id = ?`,
+		decisionID, userID,
+	)
+}
+
+// LoadVoting fills in the decision's options with their tallies, the number
+// of ballots cast and userID's own ranking.
+func (r *DecisionRepository) LoadVoting(decision *models.Decision, userID int) error {
+	rows, err := r.db.Query(
+		`SELECT id, label, COALESCE(description, ''), position
+		 FROM decision_options
+		 WHERE decision_id = ?
+		 ORDER BY position, id`,
+		decision.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	options := make([]models.DecisionOption, 0)
+	positions := make(map[int]int)
+	for rows.Next() {
+		var option models.DecisionOption
+		if err := rows.Scan(&option.ID, &option.Label, &option.Description, &option.Position); err != nil {
+			return err
+		}
+		positions[option.ID] = len(options)
+		options = append(options, option)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tally, err := r.db.Query(
+		`SELECT option_id, preference, COUNT(*)
+		 FROM decision_votes
+		 WHERE decision_id = ?
+		 GROUP BY option_id, preference`,
+		decision.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer tally.Close()
+
+	for tally.Next() {
+		var optionID, preference, count int
+		if err := tally.Scan(&optionID, &preference, &count); err != nil {
+			return err
+		}
+		index, ok := positions[optionID]
+		if !ok {
+			continue
+		}
+		if preference == 1 {
+			options[index].Votes += count
+		}
+		if points := len(options) - preference + 1; points > 0 {
+			options[index].Points += count * points
+		}
+	}
+	if err := tally.Err(); err != nil {
+		return err
+	}
+
+	if err := r.db.QueryRow(
+		`SELECT COUNT(DISTINCT user_id) FROM decision_votes WHERE decision_id = ?`,
+		decision.ID,
+	).Scan(&decision.Ballots); err != nil {
+		return err
+	}
+
+	ranking, err := r.db.Query(
+		`SELECT option_id FROM decision_votes WHERE decision_id = ? AND user_id = ? ORDER BY preference`,
+		decision.ID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	defer ranking.Close()
+
+	decision.MyRanking = nil
+	for ranking.Next() {
+		var optionID int
+		if err := ranking.Scan(&optionID); err != nil {
+			return err
+		}
+		decision.MyRanking = append(decision.MyRanking, optionID)
+	}
+	decision.Options = options
+	return ranking.Err()
+}
+
+// LinkSignal attaches a signal to a decision as evidence. It reports false if
+// the signal was already linked.
+func (r *DecisionRepository) LinkSignal(decisionID, signalID, userID int, linkedAt time.Time) (bool, error) {
+	result, err := r.db.Exec(
+		`INSERT INTO decision_signals (decision_id, signal_id, linked_by, created_at)
+		 VALUES (?, ?, ?, ?)
+		 ON CONFLICT (decision_id, signal_id) DO NOTHING`,
+		decisionID, signalID, userID, linkedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *DecisionRepository) UnlinkSignal(decisionID, signalID int) error {
+	return r.execAffecting(
+		`DELETE FROM decision_signals WHERE decision_id = ? AND signal_id = ?`,
+		decisionID, signalID,
+	)
+}
+
+// LinkedSignals lists the evidence attached to a decision, newest link first.
+// Linking shares a signal with the workspace, so every linked signal is
+// returned whoever owns it; status is resolved for userID.
+func (r *DecisionRepository) LinkedSignals(userID, decisionID int) ([]models.LinkedSignal, error) {
+	rows, err := r.db.Query(
+		`SELECT `+signalColumns+`, ds.linked_by, ds.created_at
+		 FROM decision_signals ds
+		 JOIN signals s ON s.id = ds.signal_id
+		 LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = ?
+		 WHERE ds.decision_id = ?
+		 ORDER BY ds.created_at DESC, s.id DESC`,
+		userID, decisionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	linked := make([]models.LinkedSignal, 0)
+	for rows.Next() {
+		var (
+			signal   models.LinkedSignal
+			linkedBy int
+			linkedAt time.Time
+		)
+		scanned, err := scanSignal(scanWithExtra(rows, &linkedBy, &linkedAt))
+		if err != nil {
+			return nil, err
+		}
+		signal.Signal = *scanned
+		signal.LinkedBy = linkedBy
+		signal.LinkedAt = linkedAt
+		linked = append(linked, signal)
+	}
+	return linked, rows.Err()
+}
+
+func insertDecisionOptions(tx *sql.Tx, decisionID int, options []models.DecisionOptionRequest) error {
+	for index, option := range options {
+		if _, err := tx.Exec(
+			`INSERT INTO decision_options (decision_id, label, description, position) VALUES (?, ?, ?, ?)`,
+			decisionID, option.Label, option.Description, index+1,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanDecision(scanner rowScanner) (*models.Decision, error) {
+	var (
+		decision        models.Decision
+		dueDate         sql.NullTime
+		outcomeOptionID sql.NullInt64
+		rationale       string
+		closedBy        sql.NullInt64
+		closedAt        sql.NullTime
+	)
+
+	err := scanner.Scan(
+		&decision.ID,
+		&decision.WorkspaceID,
+		&decision.UserID,
+		&decision.Title,
+		&decision.Description,
+		&decision.Status,
+		&dueDate,
+		&decision.CreatedAt,
+		&decision.UpdatedAt,
+		&outcomeOptionID,
+		&rationale,
+		&closedBy,
+		&closedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if dueDate.Valid {
+		decision.DueDate = &dueDate.Time
+	}
+	if closedAt.Valid {
+		decision.Outcome = &models.DecisionOutcome{
+			Rationale: rationale,
+			ClosedBy:  int(closedBy.Int64),
+			ClosedAt:  closedAt.Time,
+		}
+		if outcomeOptionID.Valid {
+			optionID := int(outcomeOptionID.Int64)
+			decision.Outcome.OptionID = &optionID
+		}
+	}
+	return &decision, nil
+}
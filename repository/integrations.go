@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sentinent-backend/models"
+	"time"
+)
+
+type IntegrationRepository struct {
+	*queries
+}
+
+// ListForUser returns the user's integrations. When workspaceID is set, only
+// integrations for that workspace or with no workspace are included.
+func (r *IntegrationRepository) ListForUser(userID int, workspaceID *int) ([]models.ExternalIntegration, error) {
+	query := `SELECT id, user_id, workspace_id, provider, metadata, reauth_required_at, COALESCE(reauth_reason, ''), created_at, updated_at
+		FROM external_integrations WHERE user_id = ?`
+	args := []interface{}{userID}
+	if workspaceID != nil {
+		query += " AND (workspace_id = ? OR workspace_id IS NULL)"
+		args = append(args, *workspaceID)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	integrations := make([]models.ExternalIntegration, 0)
+	for rows.Next() {
+		var integration models.ExternalIntegration
+		var workspaceID sql.NullInt64
+		var metadata sql.NullString
+		var reauthRequiredAt sql.NullTime
+		if err := rows.Scan(
+			&integration.ID,
+			&integration.UserID,
+			&workspaceID,
+			&integration.Provider,
+			&metadata,
+			&reauthRequiredAt,
+			&integration.ReauthReason,
+			&integration.CreatedAt,
+			&integration.UpdatedAt,
+		); err != nil {
+			continue
+		}
+		if workspaceID.Valid {
+			integration.WorkspaceID = int(workspaceID.Int64)
+		}
+		if metadata.Valid {
+			integration.Metadata = metadata.String
+		}
+		if reauthRequiredAt.Valid {
+			integration.ReauthRequiredAt = &reauthRequiredAt.Time
+		}
+		integrations = append(integrations, integration)
+	}
+	return integrations, rows.Err()
+}
+
+func (r *IntegrationRepository) OwnerID(integrationID int) (int, error) {
+	var ownerID int
+	err := r.db.QueryRow(
+		"SELECT user_id FROM external_integrations WHERE id = ?",
+		integrationID,
+	).Scan(&ownerID)
+	return ownerID, err
+}
+
+func (r *IntegrationRepository) Delete(integrationID int) error {
+	_, err := r.db.Exec("DELETE FROM external_integrations WHERE id = ?", integrationID)
+	return err
+}
+
+// Newest returns the user's most recently updated integration for the
+// provider, including its stored tokens, or ErrNotFound if there is none.
+// Without a workspaceID, integrations in any workspace are considered.
+func (r *IntegrationRepository) Newest(userID int, provider string, workspaceID *int) (*models.ExternalIntegration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM external_integrations
+		WHERE user_id = ? AND provider = ?`
+	args := []interface{}{userID, provider}
+	if workspaceID != nil {
+		query += " AND workspace_id = ?"
+		args = append(args, *workspaceID)
+	}
+	query += " ORDER BY updated_at DESC LIMIT 1"
+	return scanIntegration(r.db.QueryRow(query, args...))
+}
+
+// UpdateMetadata decodes the integration's metadata, applies mutate and writes
+// it back. A nil workspaceID targets the user-level integration.
+func (r *IntegrationRepository) UpdateMetadata(
+	userID int,
+	provider string,
+	workspaceID *int,
+	mutate func(metadata map[string]interface{}),
+) error {
+	var (
+		rowID    int
+		metadata sql.NullString
+	)
+	where, args := integrationScope(userID, provider, workspaceID)
+	if err := r.db.QueryRow(
+		"SELECT id, metadata FROM external_integrations WHERE "+where, args...,
+	).Scan(&rowID, &metadata); err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{}
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &payload); err != nil {
+			return err
+		}
+	}
+	mutate(payload)
+
+	metadataJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		`UPDATE external_integrations
+		 SET metadata = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		string(metadataJSON), rowID,
+	)
+	return err
+}
+
+// IntegrationTokens holds an integration's credentials exactly as stored,
+// that is, already encrypted.
+type IntegrationTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    *time.Time
+}
+
+const integrationColumns = `id, user_id, workspace_id, provider, access_token, refresh_token, expires_at, metadata,
+	reauth_required_at, COALESCE(reauth_reason, ''), created_at, updated_at`
+
+// Get returns the user's integration for the provider, including its stored
+// tokens. A nil workspaceID targets the user-level integration.
+func (r *IntegrationRepository) Get(userID int, provider string, workspaceID *int) (*models.ExternalIntegration, error) {
+	where, args := integrationScope(userID, provider, workspaceID)
+	return scanIntegration(r.db.QueryRow(
+		`SELECT `+integrationColumns+` FROM external_integrations WHERE `+where,
+		args...,
+	))
+}
+
+// GetByID returns an integration, including its stored tokens.
+func (r *IntegrationRepository) GetByID(integrationID int) (*models.ExternalIntegration, error) {
+	return scanIntegration(r.db.QueryRow(
+		`SELECT `+integrationColumns+` FROM external_integrations WHERE id = ?`,
+		integrationID,
+	))
+}
+
+func scanIntegration(scanner rowScanner) (*models.ExternalIntegration, error) {
+	var (
+		integration       models.ExternalIntegration
+		storedWorkspaceID sql.NullInt64
+		refreshToken      sql.NullString
+		metadata          sql.NullString
+		expiresAt         sql.NullTime
+		reauthRequiredAt  sql.NullTime
+	)
+	if err := scanner.Scan(
+		&integration.ID,
+		&integration.UserID,
+		&storedWorkspaceID,
+		&integration.Provider,
+		&integration.AccessToken,
+		&refreshToken,
+		&expiresAt,
+		&metadata,
+		&reauthRequiredAt,
+		&integration.ReauthReason,
+		&integration.CreatedAt,
+		&integration.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if storedWorkspaceID.Valid {
+		integration.WorkspaceID = int(storedWorkspaceID.Int64)
+	}
+	integration.RefreshToken = refreshToken.String
+	integration.Metadata = metadata.String
+	if expiresAt.Valid {
+		integration.ExpiresAt = &expiresAt.Time
+	}
+	if reauthRequiredAt.Valid {
+		integration.ReauthRequiredAt = &reauthRequiredAt.Time
+	}
+	return &integration, nil
+}
+
+// Upsert stores new tokens for the user's integration, creating it if needed.
+// The metadata is merged into what is already stored, and an empty refresh
+// token keeps the stored one, since most providers only issue it once. A
+// pending re-authorization is cleared, since the user just reconnected.
+func (r *IntegrationRepository) Upsert(
+	userID int,
+	provider string,
+	workspaceID *int,
+	tokens IntegrationTokens,
+	metadata map[string]interface{},
+) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if workspaceID != nil {
+		// Older code could store workspace integrations without a workspace;
+		// such rows would shadow this one in ListForUser.
+		if _, err := tx.Exec(
+			`DELETE FROM external_integrations
+			 WHERE user_id = ? AND provider = ? AND (workspace_id IS NULL OR workspace_id = 0)`,
+			userID, provider,
+		); err != nil {
+			return err
+		}
+	}
+
+	var (
+		rowID  int
+		stored sql.NullString
+	)
+	where, args := integrationScope(userID, provider, workspaceID)
+	err = tx.QueryRow("SELECT id, metadata FROM external_integrations WHERE "+where, args...).Scan(&rowID, &stored)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	exists := err == nil
+
+	merged := map[string]interface{}{}
+	if stored.Valid && stored.String != "" {
+		_ = json.Unmarshal([]byte(stored.String), &merged)
+	}
+	for key, value := range metadata {
+		merged[key] = value
+	}
+	metadataJSON, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		var storedWorkspaceID interface{}
+		if workspaceID != nil {
+			storedWorkspaceID = *workspaceID
+		}
+		_, err = tx.Exec(
+			`INSERT INTO external_integrations
+			 (user_id, workspace_id, provider, access_token, refresh_token, expires_at, metadata, updated_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+			userID, storedWorkspaceID, provider, tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresAt, string(metadataJSON),
+		)
+	} else {
+		if _, err = tx.Exec(
+			"UPDATE external_integrations SET metadata = ?, reauth_required_at = NULL, reauth_reason = NULL WHERE id = ?",
+			string(metadataJSON), rowID,
+		); err == nil {
+			err = updateIntegrationTokens(tx, rowID, tokens)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateTokens replaces the integration's tokens, keeping the stored refresh
+// token when tokens carries none.
+func (r *IntegrationRepository) UpdateTokens(integrationID int, tokens IntegrationTokens) error {
+	return updateIntegrationTokens(r.db, integrationID, tokens)
+}
+
+// MarkReauthRequired flags the integration as needing the user to reconnect.
+// It reports false if the integration was already flagged.
+func (r *IntegrationRepository) MarkReauthRequired(integrationID int, reason string, now time.Time) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE external_integrations SET reauth_required_at = ?, reauth_reason = ?
+		 WHERE id = ? AND reauth_required_at IS NULL`,
+		now, reason, integrationID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// DeleteFor removes the user's integration for the provider. A nil
+// workspaceID targets the user-level integration.
+func (r *IntegrationRepository) DeleteFor(userID int, provider string, workspaceID *int) error {
+	where, args := integrationScope(userID, provider, workspaceID)
+	_, err := r.db.Exec("DELETE FROM external_integrations WHERE "+where, args...)
+	return err
+}
+
+// All returns every stored integration without its tokens, for background
+// sync.
+func (r *IntegrationRepository) All() ([]models.ExternalIntegration, error) {
+	return r.listWithoutTokens("")
+}
+
+// ListByProvider returns the provider's integrations without their tokens, for
+// routing webhook deliveries.
+func (r *IntegrationRepository) ListByProvider(provider string) ([]models.ExternalIntegration, error) {
+	return r.listWithoutTokens("WHERE provider = ?", provider)
+}
+
+func (r *IntegrationRepository) listWithoutTokens(where string, args ...interface{}) ([]models.ExternalIntegration, error) {
+	rows, err := r.db.Query(
+		"SELECT id, user_id, workspace_id, provider, metadata, reauth_required_at FROM external_integrations "+where+" ORDER BY id",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	integrations := make([]models.ExternalIntegration, 0)
+	for rows.Next() {
+		var integration models.ExternalIntegration
+		var workspaceID sql.NullInt64
+		var metadata sql.NullString
+		var reauthRequiredAt sql.NullTime
+		if err := rows.Scan(
+			&integration.ID,
+			&integration.UserID,
+			&workspaceID,
+			&integration.Provider,
+			&metadata,
+			&reauthRequiredAt,
+		); err != nil {
+			return nil, err
+		}
+		if workspaceID.Valid {
+			integration.WorkspaceID = int(workspaceID.Int64)
+		}
+		integration.Metadata = metadata.String
+		if reauthRequiredAt.Valid {
+			integration.ReauthRequiredAt = &reauthRequiredAt.Time
+		}
+		integrations = append(integrations, integration)
+	}
+	return integrations, rows.Err()
+}
+
+func updateIntegrationTokens(conn execer, integrationID int, tokens IntegrationTokens) error {
+	if tokens.RefreshToken == "" {
+		_, err := conn.Exec(
+			`UPDATE external_integrations
+			 SET access_token = ?, expires_at = ?, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = ?`,
+			tokens.AccessToken, tokens.ExpiresAt, integrationID,
+		)
+		return err
+	}
+	_, err := conn.Exec(
+		`UPDATE external_integrations
+		 SET access_token = ?, refresh_token = ?, expires_at = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresAt, integrationID,
+	)
+	return err
+}
+
+// integrationScope builds the WHERE clause selecting one user's integration
+// for a provider, either in a workspace or at the user level.
+func integrationScope(userID int, provider string, workspaceID *int) (string, []interface{}) {
+	if workspaceID != nil {
+		return "user_id = ? AND provider = ? AND workspace_id = ?", []interface{}{userID, provider, *workspaceID}
+	}
+	return "user_id = ? AND provider = ? AND workspace_id IS NULL", []interface{}{userID, provider}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/models"
+	"strings"
+	"time"
+)
+
+type InvitationRepository struct {
+	*queries
+}
+
+// HasActive reports whether an unaccepted, unexpired invitation exists for the email.
+func (r *InvitationRepository) HasActive(workspaceID int, email string, now time.Time) (bool, error) {
+	var invitationID int
+	err := r.db.QueryRow(
+		`SELECT id
+		 FROM invitations
+		 WHERE workspace_id = ?
+		   AND lower(email) = ?
+		   AND expires_at > ?
+		   AND accepted_at IS NULL`,
+		workspaceID, strings.ToLower(email), now,
+	).Scan(&invitationID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *InvitationRepository) Create(invitation models.Invitation) (int, error) {
+	return r.insertID(r.db,
+		`INSERT INTO invitations (workspace_id, email, token, role, expires_at, created_by)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		invitation.WorkspaceID,
+		invitation.Email,
+		invitation.Token,
+		invitation.Role,
+		invitation.ExpiresAt,
+		invitation.CreatedBy,
+	)
+}
+
+func (r *InvitationRepository) ListForWorkspace(workspaceID int) ([]models.Invitation, error) {
+	rows, err := r.db.Query(
+		`SELECT id, workspace_id, email, token, role, expires_at, created_by, created_at, updated_at, accepted_at
+		 FROM invitations
+		 WHERE workspace_id = ?
+		 ORDER BY created_at DESC`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := make([]models.Invitation, 0)
+	for rows.Next() {
+		var invitation models.Invitation
+		if err := rows.Scan(
+			&invitation.ID,
+			&invitation.WorkspaceID,
+			&invitation.Email,
+			&invitation.Token,
+			&invitation.Role,
+			&invitation.ExpiresAt,
+			&invitation.CreatedBy,
+			&invitation.CreatedAt,
+			&invitation.UpdatedAt,
+			&invitation.AcceptedAt,
+		); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// GetPendingByToken returns an invitation that has not been accepted yet.
+// Expiry is left to the caller so it can answer with a distinct status.
+func (r *InvitationRepository) GetPendingByToken(token string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.QueryRow(
+		`SELECT id, workspace_id, email, token, role, expires_at, created_by, created_at
+		 FROM invitations
+		 WHERE token = ? AND accepted_at IS NULL`,
+		token,
+	).Scan(
+		&invitation.ID,
+		&invitation.WorkspaceID,
+		&invitation.Email,
+		&invitation.Token,
+		&invitation.Role,
+		&invitation.ExpiresAt,
+		&invitation.CreatedBy,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// PendingWorkspaceID returns the workspace of an unaccepted invitation.
+func (r *InvitationRepository) PendingWorkspaceID(invitationID int) (int, error) {
+	var workspaceID int
+	err := r.db.QueryRow(
+		"SELECT workspace_id FROM invitations WHERE id = ? AND accepted_at IS NULL",
+		invitationID,
+	).Scan(&workspaceID)
+	return workspaceID, err
+}
+
+// Accept adds the user to the workspace and marks the invitation accepted.
+func (r *InvitationRepository) Accept(invitation models.Invitation, userID int, acceptedAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
+		invitation.WorkspaceID, userID, invitation.Role,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE invitations SET accepted_at = ?, accepted_by = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		acceptedAt, userID, invitation.ID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *InvitationRepository) Delete(invitationID int) error {
+	_, err := r.db.Exec("DELETE FROM invitations WHERE id = ?", invitationID)
+	return err
+}
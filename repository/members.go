@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"sentinent-backend/models"
+	"strings"
+)
+
+type MemberRepository struct {
+	*queries
+}
+
+const memberColumns = `wm.id, wm.workspace_id, wm.user_id, wm.role, wm.joined_at, wm.updated_at, u.email`
+
+// Role returns the user's membership role, or ErrNotFound if they are not a member.
+func (r *MemberRepository) Role(workspaceID, userID int) (models.WorkspaceMemberRole, error) {
+	var role string
+	err := r.db.QueryRow(
+		"SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?",
+		workspaceID, userID,
+	).Scan(&role)
+	return models.WorkspaceMemberRole(role), err
+}
+
+// List returns the members of a workspace, owners first.
+func (r *MemberRepository) List(workspaceID int) ([]models.WorkspaceMember, error) {
+	rows, err := r.db.Query(
+		`SELECT `+memberColumns+`
+		 FROM workspace_members wm
+		 JOIN users u ON u.id = wm.user_id
+		 WHERE wm.workspace_id = ?
+		 ORDER BY
+			CASE wm.role
+				WHEN 'owner' THEN 1
+				WHEN 'member' THEN 2
+				WHEN 'viewer' THEN 3
+				ELSE 4
+			END,
+			wm.joined_at`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]models.WorkspaceMember, 0)
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *member)
+	}
+	return members, rows.Err()
+}
+
+func (r *MemberRepository) Get(workspaceID, userID int) (*models.WorkspaceMember, error) {
+	return scanMember(r.db.QueryRow(
+		`SELECT `+memberColumns+`
+		 FROM workspace_members wm
+		 JOIN users u ON u.id = wm.user_id
+		 WHERE wm.workspace_id = ? AND wm.user_id = ?`,
+		workspaceID, userID,
+	))
+}
+
+// IsMemberByEmail matches the email case-insensitively.
+func (r *MemberRepository) IsMemberByEmail(workspaceID int, email string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(
+		`SELECT COUNT(*)
+		 FROM workspace_members wm
+		 JOIN users u ON u.id = wm.user_id
+		 WHERE wm.workspace_id = ? AND lower(u.email) = ?`,
+		workspaceID, strings.ToLower(email),
+	).Scan(&count)
+	return count > 0, err
+}
+
+func (r *MemberRepository) Remove(workspaceID, userID int) error {
+	_, err := r.db.Exec(
+		"DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?",
+		workspaceID, userID,
+	)
+	return err
+}
+
+// UpdateRole sets a member's role. When previousOwnerID is non-zero the
+// workspace owner moves to userID and the previous owner becomes a member, all
+// in the same transaction.
+func (r *MemberRepository) UpdateRole(workspaceID, userID int, role models.WorkspaceMemberRole, previousOwnerID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if previousOwnerID != 0 {
+		if _, err := tx.Exec(
+			"UPDATE workspaces SET owner_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			userID, workspaceID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"UPDATE workspace_members SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE workspace_id = ? AND user_id = ?",
+			models.RoleMember, workspaceID, previousOwnerID,
+		); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE workspace_members SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE workspace_id = ? AND user_id = ?",
+		role, workspaceID, userID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanMember(scanner rowScanner) (*models.WorkspaceMember, error) {
+	var member models.WorkspaceMember
+	if err := scanner.Scan(
+		&member.ID,
+		&member.WorkspaceID,
+		&member.UserID,
+		&member.Role,
+		&member.JoinedAt,
+		&member.UpdatedAt,
+		&member.Email,
+	); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
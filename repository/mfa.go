@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/models"
+	"time"
+)
+
+type MFARepository struct {
+	*queries
+}
+
+func (r *MFARepository) Get(userID int) (*models.MFASettings, error) {
+	settings := models.MFASettings{UserID: userID}
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	var lastUsedStep sql.NullInt64
+	var lockedUntil sql.NullTime
+	var requiredBy sql.NullTime
+	err := r.db.QueryRow(
+		`SELECT mfa_secret, mfa_enabled_at, mfa_last_used_step, mfa_failed_attempts, mfa_locked_until, mfa_logins, mfa_required_by
+		 FROM users WHERE id = ?`,
+		userID,
+	).Scan(&secret, &enabledAt, &lastUsedStep, &settings.FailedAttempts, &lockedUntil, &settings.Logins, &requiredBy)
+	if err != nil {
+		return nil, err
+	}
+	settings.EncryptedSecret = secret.String
+	if enabledAt.Valid {
+		settings.EnabledAt = &enabledAt.Time
+	}
+	settings.LastUsedStep = lastUsedStep.Int64
+	if lockedUntil.Valid {
+		settings.LockedUntil = &lockedUntil.Time
+	}
+	if requiredBy.Valid {
+		settings.RequiredBy = &requiredBy.Time
+	}
+	return &settings, nil
+}
+
+// RequireBy sets when the user has to have enrolled in MFA, unless a deadline
+// is already set, and returns the deadline that applies. An earlier deadline
+// is kept, so checking again never extends it.
+func (r *MFARepository) RequireBy(userID int, deadline time.Time) (time.Time, error) {
+	if _, err := r.db.Exec(
+		"UPDATE users SET mfa_required_by = ? WHERE id = ? AND mfa_required_by IS NULL",
+		deadline, userID,
+	); err != nil {
+		return time.Time{}, err
+	}
+	var requiredBy time.Time
+	err := r.db.QueryRow("SELECT mfa_required_by FROM users WHERE id = ?", userID).Scan(&requiredBy)
+	return requiredBy, err
+}
+
+// RecordFailure counts a wrong second factor at login and returns the user's
+// consecutive failures.
+func (r *MFARepository) RecordFailure(userID int) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET mfa_failed_attempts = mfa_failed_attempts + 1 WHERE id = ?", userID); err != nil {
+		return 0, err
+	}
+	var failures int
+	if err := tx.QueryRow("SELECT mfa_failed_attempts FROM users WHERE id = ?", userID).Scan(&failures); err != nil {
+		return 0, err
+	}
+	return failures, tx.Commit()
+}
+
+// Lock keeps the user from signing in with MFA until the given time.
+func (r *MFARepository) Lock(userID int, until time.Time) error {
+	return r.execAffecting("UPDATE users SET mfa_locked_until = ? WHERE id = ?", until, userID)
+}
+
+// RecordLogin counts a completed MFA login and clears the user's failures.
+// logins is the count the login's challenge was issued at; it returns
+// ErrNotFound if another login has completed since, so each challenge is
+// used once.
+func (r *MFARepository) RecordLogin(userID, logins int) error {
+	return r.execAffecting(
+		`UPDATE users
+		 SET mfa_logins = mfa_logins + 1, mfa_failed_attempts = 0, mfa_locked_until = NULL
+		 WHERE id = ? AND mfa_logins = ?`,
+		userID, logins,
+	)
+}
+
+// SetPendingSecret stores a secret awaiting confirmation. It returns
+// ErrNotFound if the user already has MFA enabled.
+func (r *MFARepository) SetPendingSecret(userID int, encryptedSecret string) error {
+	return r.execAffecting(
+		`UPDATE users
+		 SET mfa_secret = ?, mfa_last_used_step = NULL
+		 WHERE id = ? AND mfa_enabled_at IS NULL`,
+		encryptedSecret, userID,
+	)
+}
+
+// Enable turns on MFA for the pending secret and replaces the user's recovery
+// codes. It returns ErrNotFound if MFA was enabled concurrently.
+func (r *MFARepository) Enable(userID int, step int64, recoveryCodeHashes []string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE users
+		 SET mfa_enabled_at = ?, mfa_last_used_step = ?
+		 WHERE id = ? AND mfa_enabled_at IS NULL AND mfa_secret IS NOT NULL`,
+		now, step, userID,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseStep records a TOTP step as consumed. It returns ErrNotFound if that step
+// or a later one was already used, which makes each code single-use.
+func (r *MFARepository) UseStep(userID int, step int64) error {
+	return r.execAffecting(
+		`UPDATE users
+		 SET mfa_last_used_step = ?
+		 WHERE id = ? AND (mfa_last_used_step IS NULL OR mfa_last_used_step < ?)`,
+		step, userID, step,
+	)
+}
+
+// UseRecoveryCode marks a recovery code as spent. It returns ErrNotFound if the
+// code does not exist or was already used.
+func (r *MFARepository) UseRecoveryCode(userID int, codeHash string, now time.Time) error {
+	return r.execAffecting(
+		`UPDATE mfa_recovery_codes
+		 SET used_at = ?
+		 WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		now, userID, codeHash,
+	)
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *MFARepository) RemainingRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(
+		"SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL",
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec(
+			"INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID, codeHash,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/models"
+	"time"
+)
+
+type PasswordResetRepository struct {
+	*queries
+}
+
+// Replace issues a new reset token for the user, dropping their earlier tokens
+// and any expired or used ones.
+func (r *PasswordResetRepository) Replace(userID int, tokenHash string, expiresAt, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"DELETE FROM password_reset_tokens WHERE user_id = ? OR expires_at <= ? OR used_at IS NOT NULL",
+		userID, now,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		 VALUES (?, ?, ?)`,
+		userID, tokenHash, expiresAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PasswordResetRepository) Delete(tokenHash string) error {
+	_, err := r.db.Exec("DELETE FROM password_reset_tokens WHERE token_hash = ?", tokenHash)
+	return err
+}
+
+// GetByTokenHash returns the reset token with the given hash, expired and used
+// ones included, together with its user's email.
+func (r *PasswordResetRepository) GetByTokenHash(tokenHash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	var usedAt sql.NullTime
+	err := r.db.QueryRow(
+		`SELECT prt.id, prt.user_id, u.email, prt.expires_at, prt.used_at
+		 FROM password_reset_tokens prt
+		 JOIN users u ON u.id = prt.user_id
+		 WHERE prt.token_hash = ?`,
+		tokenHash,
+	).Scan(&reset.ID, &reset.UserID, &reset.Email, &reset.ExpiresAt, &usedAt)
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		reset.UsedAt = &usedAt.Time
+	}
+	return &reset, nil
+}
+
+// Redeem sets the user's new password, ends every existing login and marks
+// their outstanding reset tokens used.
+func (r *PasswordResetRepository) Redeem(userID int, passwordHash string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		"UPDATE users SET password = ?, password_changed_at = ? WHERE id = ?",
+		passwordHash, now, userID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		now, userID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
+		now, userID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
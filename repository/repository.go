@@ -0,0 +1,135 @@
+// Package repository holds the SQL for the core entities behind the HTTP
+// handlers. Queries are written once with `?` placeholders; the SQLite and
+// PostgreSQL implementations differ only where the dialect requires it.
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/database"
+)
+
+// ErrNotFound is returned when a lookup or a targeted update matches no row.
+var ErrNotFound = sql.ErrNoRows
+
+type Store struct {
+	Users          *UserRepository
+	Workspaces     *WorkspaceRepository
+	Members        *MemberRepository
+	Invitations    *InvitationRepository
+	Decisions      *DecisionRepository
+	Integrations   *IntegrationRepository
+	Signals        *SignalRepository
+	Sessions       *SessionRepository
+	MFA            *MFARepository
+	Search         *SearchRepository
+	SyncJobs       *SyncJobRepository
+	SyncRuns       *SyncRunRepository
+	Rules          *RuleRepository
+	Webhooks       *WebhookRepository
+	PasswordResets *PasswordResetRepository
+}
+
+// New builds a store over db for the given dialect.
+func New(db *sql.DB, dialect database.Dialect) *Store {
+	base := &queries{db: db, dialect: dialect}
+	return &Store{
+		Users:          &UserRepository{base},
+		Workspaces:     &WorkspaceRepository{base},
+		Members:        &MemberRepository{base},
+		Invitations:    &InvitationRepository{base},
+		Decisions:      &DecisionRepository{base},
+		Integrations:   &IntegrationRepository{base},
+		Signals:        &SignalRepository{base},
+		Sessions:       &SessionRepository{base},
+		MFA:            &MFARepository{base},
+		Search:         &SearchRepository{base},
+		SyncJobs:       &SyncJobRepository{base},
+		SyncRuns:       &SyncRunRepository{base},
+		Rules:          &RuleRepository{base},
+		Webhooks:       &WebhookRepository{base},
+		PasswordResets: &PasswordResetRepository{base},
+	}
+}
+
+// Default returns a store over the process-wide database.DB. It is resolved on
+// every call so tests that swap database.DB are picked up.
+func Default() *Store {
+	return New(database.DB, database.CurrentDialect())
+}
+
+type queries struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// extraColumns feeds a row's leading columns to an entity's scan function and
+// its trailing columns to extra, so joins can reuse scanSignal and friends.
+type extraColumns struct {
+	rowScanner
+	extra []interface{}
+}
+
+func scanWithExtra(scanner rowScanner, extra ...interface{}) rowScanner {
+	return extraColumns{rowScanner: scanner, extra: extra}
+}
+
+func (s extraColumns) Scan(dest ...interface{}) error {
+	return s.rowScanner.Scan(append(dest, s.extra...)...)
+}
+
+// insertID runs an INSERT and returns the generated id. PostgreSQL has no
+// LastInsertId, so the id is read back with RETURNING there.
+func (q *queries) insertID(conn execer, query string, args ...interface{}) (int, error) {
+	if q.dialect == database.DialectPostgres {
+		var id int
+		if err := conn.QueryRow(query+" RETURNING id", args...).Scan(&id); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+
+	result, err := conn.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// chronological wraps a time column or placeholder so it compares and sorts
+// by instant. SQLite keeps times as text written in more than one format, so
+// they are compared as Julian day numbers there.
+func (q *queries) chronological(expr string) string {
+	if q.dialect == database.DialectSQLite {
+		return "julianday(" + expr + ")"
+	}
+	return expr
+}
+
+// execAffecting runs a targeted UPDATE or DELETE and maps "no rows" to ErrNotFound.
+func (q *queries) execAffecting(query string, args ...interface{}) error {
+	result, err := q.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,1516 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forEachBackend runs fn against a freshly migrated SQLite database and, when
+// TEST_DATABASE_URL points at PostgreSQL, against a throwaway schema there.
+func forEachBackend(t *testing.T, fn func(t *testing.T, store *Store)) {
+	t.Run("sqlite", func(t *testing.T) {
+		dbtest.OpenSQLite(t)
+		fn(t, Default())
+	})
+
+	if dbtest.PostgresURL() == "" {
+		return
+	}
+	t.Run("postgres", func(t *testing.T) {
+		dbtest.OpenPostgres(t)
+		fn(t, Default())
+	})
+}
+
+func createTestUser(t *testing.T, store *Store, email string) int {
+	t.Helper()
+
+	userID, err := store.Users.Create(models.User{Email: email, FullName: "Test User"}, "hash")
+	if err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return userID
+}
+
+func TestWorkspaceLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		otherID := createTestUser(t, store, "other@example.com")
+
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "Our work")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		if workspace.ID == 0 || workspace.OwnerID != ownerID || workspace.Description != "Our work" {
+			t.Fatalf("unexpected workspace: %+v", workspace)
+		}
+
+		role, err := store.Members.Role(workspace.ID, ownerID)
+		if err != nil || role != models.RoleOwner {
+			t.Fatalf("expected owner membership, got role=%q err=%v", role, err)
+		}
+		if _, err := store.Members.Role(workspace.ID, otherID); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound for non-member, got %v", err)
+		}
+
+		workspaces, err := store.Workspaces.ListForUser(ownerID)
+		if err != nil || len(workspaces) != 1 {
+			t.Fatalf("expected one workspace, got %d (err=%v)", len(workspaces), err)
+		}
+
+		if err := store.Workspaces.Update(workspace.ID, "Renamed", ""); err != nil {
+			t.Fatalf("Update returned error: %v", err)
+		}
+		if err := store.Workspaces.Update(workspace.ID+100, "Missing", ""); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound updating a missing workspace, got %v", err)
+		}
+
+		if err := store.Workspaces.Delete(workspace.ID); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		if _, err := store.Workspaces.Get(workspace.ID); err != ErrNotFound {
+			t.Fatalf("expected deleted workspace to be gone, got %v", err)
+		}
+	})
+}
+
+func TestInvitationAcceptanceAndOwnershipTransfer(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		inviteeID := createTestUser(t, store, "Invitee@Example.com")
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		invitationID, err := store.Invitations.Create(models.Invitation{
+			WorkspaceID: workspace.ID,
+			Email:       "invitee@example.com",
+			Token:       "token-1",
+			Role:        models.RoleMember,
+			ExpiresAt:   time.Now().Add(time.Hour),
+			CreatedBy:   ownerID,
+		})
+		if err != nil || invitationID == 0 {
+			t.Fatalf("Create invitation returned id=%d err=%v", invitationID, err)
+		}
+
+		active, err := store.Invitations.HasActive(workspace.ID, "INVITEE@example.com", time.Now())
+		if err != nil || !active {
+			t.Fatalf("expected active invitation, got active=%v err=%v", active, err)
+		}
+
+		invitation, err := store.Invitations.GetPendingByToken("token-1")
+		if err != nil {
+			t.Fatalf("GetPendingByToken returned error: %v", err)
+		}
+		if invitation.CreatedBy != ownerID {
+			t.Fatalf("expected invitation created by %d, got %d", ownerID, invitation.CreatedBy)
+		}
+		if err := store.Invitations.Accept(*invitation, inviteeID, time.Now()); err != nil {
+			t.Fatalf("Accept returned error: %v", err)
+		}
+		if _, err := store.Invitations.GetPendingByToken("token-1"); err != ErrNotFound {
+			t.Fatalf("expected accepted invitation to no longer be pending, got %v", err)
+		}
+
+		isMember, err := store.Members.IsMemberByEmail(workspace.ID, "invitee@example.com")
+		if err != nil || !isMember {
+			t.Fatalf("expected invitee to be a member, got %v (err=%v)", isMember, err)
+		}
+
+		if err := store.Members.UpdateRole(workspace.ID, inviteeID, models.RoleOwner, ownerID); err != nil {
+			t.Fatalf("UpdateRole returned error: %v", err)
+		}
+		newOwnerID, err := store.Workspaces.OwnerID(workspace.ID)
+		if err != nil || newOwnerID != inviteeID {
+			t.Fatalf("expected owner %d, got %d (err=%v)", inviteeID, newOwnerID, err)
+		}
+		members, err := store.Members.List(workspace.ID)
+		if err != nil || len(members) != 2 {
+			t.Fatalf("expected two members, got %d (err=%v)", len(members), err)
+		}
+		if members[0].UserID != inviteeID || members[1].Role != models.RoleMember {
+			t.Fatalf("unexpected members after transfer: %+v", members)
+		}
+	})
+}
+
+func TestDecisionCRUD(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		dueDate := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		decisionID, err := store.Decisions.Create(workspace.ID, ownerID, models.DecisionRequest{
+			Title:   "Pick a database",
+			Status:  models.DecisionStatusOpen,
+			DueDate: &dueDate,
+		})
+		if err != nil {
+			t.Fatalf("Create decision returned error: %v", err)
+		}
+
+		decision, err := store.Decisions.Get(workspace.ID, decisionID)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if decision.DueDate == nil || !decision.DueDate.Equal(dueDate) {
+			t.Fatalf("expected due date %v, got %v", dueDate, decision.DueDate)
+		}
+
+		if err := store.Decisions.Update(workspace.ID, decisionID, models.DecisionRequest{
+			Title:  "Pick a database",
+			Status: models.DecisionStatusClosed,
+		}, nil); err != nil {
+			t.Fatalf("Update returned error: %v", err)
+		}
+		decisions, err := store.Decisions.List(workspace.ID)
+		if err != nil || len(decisions) != 1 || decisions[0].Status != models.DecisionStatusClosed || decisions[0].DueDate != nil {
+			t.Fatalf("unexpected decisions after update: %+v (err=%v)", decisions, err)
+		}
+
+		if err := store.Decisions.Delete(workspace.ID, decisionID); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		if err := store.Decisions.Delete(workspace.ID, decisionID); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+		}
+	})
+}
+
+func TestSignalStatusAndIntegrationMetadata(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		otherID := createTestUser(t, store, "other@example.com")
+		workspace, err := store.Workspaces.Create(userID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		for index, sourceType := range []string{models.SourceTypeGitHub, models.SourceTypeJira} {
+			if _, err := database.DB.Exec(
+				`INSERT INTO signals (user_id, workspace_id, source_type, source_id, external_id, title, status, received_at)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				userID, workspace.ID, sourceType, fmt.Sprint(index+1), fmt.Sprint(index+1), "Signal", models.SignalStatusUnread, time.Now(),
+			); err != nil {
+				t.Fatalf("failed to seed signal: %v", err)
+			}
+		}
+
+		page, err := store.Signals.List(userID, models.SignalFilter{WorkspaceID: workspace.ID, Limit: 50})
+		if err != nil || len(page.Signals) != 2 || page.Total != 2 {
+			t.Fatalf("expected two signals, got %+v (err=%v)", page, err)
+		}
+
+		archivedID := page.Signals[0].ID
+		if err := store.Signals.SetStatus(userID, archivedID, models.SignalStatusArchived); err != nil {
+			t.Fatalf("SetStatus returned error: %v", err)
+		}
+		if err := store.Signals.SetStatus(otherID, archivedID, models.SignalStatusArchived); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound for another user's signal, got %v", err)
+		}
+
+		archived, err := store.Signals.List(userID, models.SignalFilter{WorkspaceID: workspace.ID, Status: models.SignalStatusArchived, Limit: 50})
+		if err != nil || len(archived.Signals) != 1 || archived.Total != 1 || archived.Signals[0].ID != archivedID {
+			t.Fatalf("expected the archived signal only, got %+v (err=%v)", archived, err)
+		}
+
+		if _, err := database.DB.Exec(
+			`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata)
+			 VALUES (?, ?, 'github', 'token', '{"login":"octo"}')`,
+			userID, workspace.ID,
+		); err != nil {
+			t.Fatalf("failed to seed integration: %v", err)
+		}
+		workspaceID := workspace.ID
+		if err := store.Integrations.UpdateMetadata(userID, "github", &workspaceID, func(metadata map[string]interface{}) {
+			metadata["selected_repo_ids"] = []int{7}
+		}); err != nil {
+			t.Fatalf("UpdateMetadata returned error: %v", err)
+		}
+		integrations, err := store.Integrations.ListForUser(userID, &workspaceID)
+		if err != nil || len(integrations) != 1 {
+			t.Fatalf("expected one integration, got %d (err=%v)", len(integrations), err)
+		}
+		if !strings.Contains(integrations[0].Metadata, `"login":"octo"`) || !strings.Contains(integrations[0].Metadata, `"selected_repo_ids":[7]`) {
+			t.Fatalf("unexpected metadata: %s", integrations[0].Metadata)
+		}
+	})
+}
+
+func TestWorkspaceSignalsAreSharedWithMembers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		viewerID := createTestUser(t, store, "viewer@example.com")
+		outsiderID := createTestUser(t, store, "outsider@example.com")
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		if _, err := database.DB.Exec(
+			"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
+			workspace.ID, viewerID, models.RoleViewer,
+		); err != nil {
+			t.Fatalf("failed to seed member: %v", err)
+		}
+
+		// The viewer's integration brought the signal in.
+		var signalID int
+		if err := database.DB.QueryRow(
+			`INSERT INTO signals (user_id, workspace_id, source_type, source_id, external_id, title, status, received_at)
+			 VALUES (?, ?, ?, 'C1:1.000100', '1.000100', 'Deploy tonight?', ?, ?)
+			 RETURNING id`,
+			viewerID, workspace.ID, models.SourceTypeSlack, models.SignalStatusUnread, time.Now(),
+		).Scan(&signalID); err != nil {
+			t.Fatalf("failed to seed signal: %v", err)
+		}
+
+		for _, userID := range []int{ownerID, viewerID} {
+			page, err := store.Signals.List(userID, models.SignalFilter{WorkspaceID: workspace.ID, Limit: 50})
+			if err != nil || page.Total != 1 || page.Signals[0].ID != signalID {
+				t.Fatalf("expected user %d to see the shared signal, got %+v (err=%v)", userID, page, err)
+			}
+		}
+		if page, err := store.Signals.List(outsiderID, models.SignalFilter{WorkspaceID: workspace.ID, Limit: 50}); err != nil || page.Total != 0 {
+			t.Fatalf("expected an outsider to see nothing, got %+v (err=%v)", page, err)
+		}
+		if err := store.Signals.SetStatus(outsiderID, signalID, models.SignalStatusRead); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound for an outsider, got %v", err)
+		}
+
+		if err := store.Signals.SetStatus(ownerID, signalID, models.SignalStatusRead); err != nil {
+			t.Fatalf("SetStatus returned error: %v", err)
+		}
+		status := func(userID int) string {
+			t.Helper()
+			signal, err := store.Signals.Get(userID, signalID)
+			if err != nil {
+				t.Fatalf("Get returned error: %v", err)
+			}
+			return signal.Status
+		}
+		if owner, viewer := status(ownerID), status(viewerID); owner != models.SignalStatusRead || viewer != models.SignalStatusUnread {
+			t.Fatalf("expected read state to stay per member, got owner=%q viewer=%q", owner, viewer)
+		}
+
+		if err := store.Signals.ArchiveForAll(signalID); err != nil {
+			t.Fatalf("ArchiveForAll returned error: %v", err)
+		}
+		if owner, viewer := status(ownerID), status(viewerID); owner != models.SignalStatusArchived || viewer != models.SignalStatusArchived {
+			t.Fatalf("expected the signal to be archived for everyone, got owner=%q viewer=%q", owner, viewer)
+		}
+	})
+}
+
+func TestSignalTriage(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		viewerID := createTestUser(t, store, "viewer@example.com")
+		outsiderID := createTestUser(t, store, "outsider@example.com")
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		if _, err := database.DB.Exec(
+			"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
+			workspace.ID, viewerID, models.RoleViewer,
+		); err != nil {
+			t.Fatalf("failed to seed member: %v", err)
+		}
+
+		seed := func(userID int, workspaceID interface{}, sourceID string) int {
+			t.Helper()
+			var signalID int
+			if err := database.DB.QueryRow(
+				`INSERT INTO signals (user_id, workspace_id, source_type, source_id, external_id, title, status, received_at)
+				 VALUES (?, ?, ?, ?, ?, 'Signal', ?, ?)
+				 RETURNING id`,
+				userID, workspaceID, models.SourceTypeGitHub, sourceID, sourceID, models.SignalStatusUnread, time.Now(),
+			).Scan(&signalID); err != nil {
+				t.Fatalf("failed to seed signal: %v", err)
+			}
+			return signalID
+		}
+		first := seed(ownerID, workspace.ID, "1")
+		second := seed(ownerID, workspace.ID, "2")
+		private := seed(outsiderID, nil, "3")
+
+		status := func(userID, signalID int) *models.Signal {
+			t.Helper()
+			signal, err := store.Signals.Get(userID, signalID)
+			if err != nil {
+				t.Fatalf("Get returned error: %v", err)
+			}
+			return signal
+		}
+
+		done := models.SignalTriage{Action: models.SignalActionStatus, Status: models.SignalStatusDone}
+		if err := store.Signals.Triage(ownerID, []int{first, private}, done); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound when one signal is not visible, got %v", err)
+		}
+		if got := status(ownerID, first).Status; got != models.SignalStatusUnread {
+			t.Fatalf("expected a refused bulk change to leave every signal alone, got %q", got)
+		}
+		if err := store.Signals.Triage(ownerID, []int{first, second}, done); err != nil {
+			t.Fatalf("Triage returned error: %v", err)
+		}
+		if a, b := status(ownerID, first).Status, status(ownerID, second).Status; a != models.SignalStatusDone || b != models.SignalStatusDone {
+			t.Fatalf("expected both signals done, got %q and %q", a, b)
+		}
+
+		assign := func(userID, signalID int, assigneeID *int) error {
+			return store.Signals.Triage(userID, []int{signalID}, models.SignalTriage{Action: models.SignalActionAssign, AssigneeID: assigneeID})
+		}
+		if err := assign(viewerID, first, &viewerID); err != ErrAssignForbidden {
+			t.Fatalf("expected a viewer to be refused, got %v", err)
+		}
+		if err := assign(ownerID, first, &outsiderID); err != ErrInvalidAssignee {
+			t.Fatalf("expected an outsider assignee to be refused, got %v", err)
+		}
+		if err := assign(outsiderID, private, &outsiderID); err != ErrInvalidAssignee {
+			t.Fatalf("expected a personal signal to be unassignable, got %v", err)
+		}
+		if err := assign(ownerID, first, &viewerID); err != nil {
+			t.Fatalf("assign returned error: %v", err)
+		}
+		if signal := status(viewerID, first); signal.AssigneeID == nil || *signal.AssigneeID != viewerID {
+			t.Fatalf("expected the assignee to be shared with the viewer, got %+v", signal.AssigneeID)
+		}
+		assigned, err := store.Signals.List(viewerID, models.SignalFilter{WorkspaceID: workspace.ID, AssigneeID: viewerID, Limit: 50})
+		if err != nil || assigned.Total != 1 || assigned.Signals[0].ID != first {
+			t.Fatalf("expected the assignee filter to match the assigned signal, got %+v (err=%v)", assigned, err)
+		}
+		if err := assign(ownerID, first, nil); err != nil {
+			t.Fatalf("unassign returned error: %v", err)
+		}
+		if signal := status(ownerID, first); signal.AssigneeID != nil {
+			t.Fatalf("expected the signal to be unassigned, got %d", *signal.AssigneeID)
+		}
+
+		now := time.Now().UTC()
+		soon, later := now.Add(time.Hour), now.Add(3*time.Hour)
+		if err := store.Signals.Triage(viewerID, []int{first}, models.SignalTriage{Action: models.SignalActionSnooze, SnoozedUntil: &soon}); err != nil {
+			t.Fatalf("snooze returned error: %v", err)
+		}
+		if err := store.Signals.Triage(viewerID, []int{second}, models.SignalTriage{Action: models.SignalActionSnooze, SnoozedUntil: &later}); err != nil {
+			t.Fatalf("snooze returned error: %v", err)
+		}
+		if signal := status(viewerID, first); signal.Status != models.SignalStatusSnoozed || signal.SnoozedUntil == nil || !signal.SnoozedUntil.Equal(soon) {
+			t.Fatalf("expected the signal snoozed until %v, got %q until %v", soon, signal.Status, signal.SnoozedUntil)
+		}
+		if got := status(ownerID, first).Status; got != models.SignalStatusDone {
+			t.Fatalf("expected the owner's status to be untouched by the viewer's snooze, got %q", got)
+		}
+
+		woken, err := store.Signals.WakeSnoozed(now.Add(2 * time.Hour))
+		if err != nil || len(woken) != 1 || woken[0] != (WokenSnooze{SignalID: first, UserID: viewerID}) {
+			t.Fatalf("expected the viewer's first snooze to expire, got %+v (err=%v)", woken, err)
+		}
+		if signal := status(viewerID, first); signal.Status != models.SignalStatusUnread || signal.SnoozedUntil != nil {
+			t.Fatalf("expected the expired snooze to return to unread, got %q until %v", signal.Status, signal.SnoozedUntil)
+		}
+		if got := status(viewerID, second).Status; got != models.SignalStatusSnoozed {
+			t.Fatalf("expected the later snooze to keep sleeping, got %q", got)
+		}
+	})
+}
+
+func TestSignalRules(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		memberID := createTestUser(t, store, "member@example.com")
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		if _, err := database.DB.Exec(
+			"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)",
+			workspace.ID, memberID, models.RoleMember,
+		); err != nil {
+			t.Fatalf("failed to seed member: %v", err)
+		}
+
+		disabled := false
+		late, err := store.Rules.Create(workspace.ID, ownerID, models.SignalRuleRequest{
+			Name:       "Later",
+			Position:   2,
+			Conditions: models.RuleConditions{SourceTypes: []string{models.SourceTypeJira}},
+			Actions:    models.RuleActions{Priority: models.SignalPriorityLow},
+		})
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		early, err := store.Rules.Create(workspace.ID, ownerID, models.SignalRuleRequest{
+			Name:       "Bugs",
+			Position:   1,
+			Enabled:    &disabled,
+			Conditions: models.RuleConditions{GitHubLabels: []string{"bug"}},
+			Actions:    models.RuleActions{AddLabels: []string{"triage"}, AssigneeID: &memberID},
+		})
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		rules, err := store.Rules.List(workspace.ID)
+		if err != nil || len(rules) != 2 || rules[0].ID != early || rules[1].ID != late {
+			t.Fatalf("expected rules in position order, got %+v (err=%v)", rules, err)
+		}
+		if rules[0].Enabled || rules[0].Conditions.GitHubLabels[0] != "bug" || *rules[0].Actions.AssigneeID != memberID {
+			t.Fatalf("expected the rule to round-trip, got %+v", rules[0])
+		}
+		enabled, err := store.Rules.ListEnabled(workspace.ID)
+		if err != nil || len(enabled) != 1 || enabled[0].ID != late {
+			t.Fatalf("expected only the enabled rule, got %+v (err=%v)", enabled, err)
+		}
+
+		if err := store.Rules.Update(workspace.ID, early, models.SignalRuleRequest{
+			Name:       "Bugs",
+			Position:   1,
+			Conditions: models.RuleConditions{GitHubLabels: []string{"bug"}},
+			Actions:    models.RuleActions{Priority: models.SignalPriorityHigh},
+		}); err != nil {
+			t.Fatalf("Update returned error: %v", err)
+		}
+		rule, err := store.Rules.Get(workspace.ID, early)
+		if err != nil || !rule.Enabled || rule.Actions.Priority != models.SignalPriorityHigh || rule.Actions.AssigneeID != nil {
+			t.Fatalf("expected the update to replace the rule, got %+v (err=%v)", rule, err)
+		}
+		if err := store.Rules.Update(workspace.ID+1, early, models.SignalRuleRequest{Name: "Elsewhere"}); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound for another workspace, got %v", err)
+		}
+
+		var signalID int
+		if err := database.DB.QueryRow(
+			`INSERT INTO signals (user_id, workspace_id, source_type, source_id, external_id, title, status, received_at)
+			 VALUES (?, ?, ?, 'acme/api#1', '1', 'Crash on login', ?, ?)
+			 RETURNING id`,
+			ownerID, workspace.ID, models.SourceTypeGitHub, models.SignalStatusUnread, time.Now(),
+		).Scan(&signalID); err != nil {
+			t.Fatalf("failed to seed signal: %v", err)
+		}
+
+		first, err := store.Rules.RecordMatch(early, signalID, time.Now())
+		if err != nil || !first {
+			t.Fatalf("expected the first match to be recorded, got %v (err=%v)", first, err)
+		}
+		if again, err := store.Rules.RecordMatch(early, signalID, time.Now()); err != nil || again {
+			t.Fatalf("expected a repeated match to be ignored, got %v (err=%v)", again, err)
+		}
+
+		signal, err := store.Signals.FindBySource(workspace.ID, models.SourceTypeGitHub, "acme/api#1")
+		if err != nil || signal.ID != signalID {
+			t.Fatalf("expected FindBySource to find the signal, got %+v (err=%v)", signal, err)
+		}
+		if err := store.Signals.AddLabels(signalID, []string{"Bug", "triage"}); err != nil {
+			t.Fatalf("AddLabels returned error: %v", err)
+		}
+		if err := store.Signals.AddLabels(signalID, []string{"bug", "backend"}); err != nil {
+			t.Fatalf("AddLabels returned error: %v", err)
+		}
+		if err := store.Signals.SetPriority(signalID, models.SignalPriorityUrgent); err != nil {
+			t.Fatalf("SetPriority returned error: %v", err)
+		}
+		if err := store.Signals.SetAssignee(signalID, ownerID+memberID+1); err != ErrInvalidAssignee {
+			t.Fatalf("expected a non-member assignee to be refused, got %v", err)
+		}
+		if err := store.Signals.SetStatus(memberID, signalID, models.SignalStatusRead); err != nil {
+			t.Fatalf("SetStatus returned error: %v", err)
+		}
+		if err := store.Signals.ArchiveByDefault(signalID); err != nil {
+			t.Fatalf("ArchiveByDefault returned error: %v", err)
+		}
+
+		owner, err := store.Signals.Get(ownerID, signalID)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if len(owner.Labels) != 3 || owner.Labels[0] != "Bug" || owner.Labels[2] != "backend" {
+			t.Fatalf("expected labels merged without case duplicates, got %v", owner.Labels)
+		}
+		if owner.Priority != models.SignalPriorityUrgent || owner.Status != models.SignalStatusArchived {
+			t.Fatalf("expected an urgent archived signal, got priority=%q status=%q", owner.Priority, owner.Status)
+		}
+		if member, err := store.Signals.Get(memberID, signalID); err != nil || member.Status != models.SignalStatusRead {
+			t.Fatalf("expected the member to keep their own status, got %+v (err=%v)", member, err)
+		}
+
+		for _, filter := range []models.SignalFilter{
+			{WorkspaceID: workspace.ID, Label: "BACKEND", Limit: 50},
+			{WorkspaceID: workspace.ID, Priority: models.SignalPriorityUrgent, Limit: 50},
+		} {
+			page, err := store.Signals.List(ownerID, filter)
+			if err != nil || page.Total != 1 {
+				t.Fatalf("expected filter %+v to match the signal, got %+v (err=%v)", filter, page, err)
+			}
+		}
+		if page, err := store.Signals.List(ownerID, models.SignalFilter{WorkspaceID: workspace.ID, Label: "back", Limit: 50}); err != nil || page.Total != 0 {
+			t.Fatalf("expected the label filter to match whole labels only, got %+v (err=%v)", page, err)
+		}
+
+		if err := store.Rules.Delete(workspace.ID, early); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		if _, err := store.Rules.Get(workspace.ID, early); err != ErrNotFound {
+			t.Fatalf("expected the rule to be gone, got %v", err)
+		}
+		if err := store.Rules.Delete(workspace.ID, early); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+		}
+	})
+}
+
+func TestOutgoingWebhooks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		disabled := false
+		closedOnly, err := store.Webhooks.Create(workspace.ID, ownerID, models.WebhookRequest{
+			URL:    "https://hooks.example.com/closed",
+			Events: []string{models.WebhookEventDecisionClosed},
+		}, "sealed-1")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		paused, err := store.Webhooks.Create(workspace.ID, ownerID, models.WebhookRequest{
+			URL:     "https://hooks.example.com/paused",
+			Events:  []string{models.WebhookEventDecisionClosed, models.WebhookEventSignalCreated},
+			Enabled: &disabled,
+		}, "sealed-2")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		webhooks, err := store.Webhooks.List(workspace.ID)
+		if err != nil || len(webhooks) != 2 || webhooks[0].ID != closedOnly || webhooks[1].Enabled || webhooks[0].Secret != "" {
+			t.Fatalf("expected both webhooks without secrets, got %+v (err=%v)", webhooks, err)
+		}
+		subscribed, err := store.Webhooks.ListSubscribed(workspace.ID, models.WebhookEventDecisionClosed)
+		if err != nil || len(subscribed) != 1 || subscribed[0].ID != closedOnly {
+			t.Fatalf("expected only the enabled subscriber, got %+v (err=%v)", subscribed, err)
+		}
+		if target, err := store.Webhooks.Target(paused); err != nil || target.Secret != "sealed-2" || target.Enabled {
+			t.Fatalf("expected the stored target, got %+v (err=%v)", target, err)
+		}
+
+		webhook := webhooks[1]
+		webhook.Enabled = true
+		webhook.Events = []string{models.WebhookEventMemberJoined}
+		if err := store.Webhooks.Update(webhook); err != nil {
+			t.Fatalf("Update returned error: %v", err)
+		}
+		if got, err := store.Webhooks.Get(workspace.ID, paused); err != nil || !got.Enabled || got.Events[0] != models.WebhookEventMemberJoined {
+			t.Fatalf("expected the update to stick, got %+v (err=%v)", got, err)
+		}
+		webhook.WorkspaceID++
+		if err := store.Webhooks.Update(webhook); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound for another workspace, got %v", err)
+		}
+
+		now := time.Now().UTC()
+		payload := []byte(`{"id":"evt"}`)
+		deliveryID, err := store.Webhooks.Enqueue(closedOnly, "evt", models.WebhookEventDecisionClosed, payload, nil, now)
+		if err != nil {
+			t.Fatalf("Enqueue returned error: %v", err)
+		}
+		due, err := store.Webhooks.DueDeliveries(now, 10)
+		if err != nil || len(due) != 1 || due[0].ID != deliveryID || string(due[0].Payload) != `{"id":"evt"}` {
+			t.Fatalf("expected the queued delivery to be due, got %+v (err=%v)", due, err)
+		}
+
+		if err := store.Webhooks.ClaimDelivery(deliveryID, now, now.Add(time.Minute)); err != nil {
+			t.Fatalf("ClaimDelivery returned error: %v", err)
+		}
+		if err := store.Webhooks.ClaimDelivery(deliveryID, now, now.Add(time.Minute)); err != ErrNotFound {
+			t.Fatalf("expected a claimed delivery to be held, got %v", err)
+		}
+		status := http.StatusBadGateway
+		retryAt := now.Add(time.Minute)
+		if err := store.Webhooks.MarkAttemptFailed(deliveryID, &status, "endpoint responded 502", &retryAt); err != nil {
+			t.Fatalf("MarkAttemptFailed returned error: %v", err)
+		}
+		if due, err := store.Webhooks.DueDeliveries(now, 10); err != nil || len(due) != 0 {
+			t.Fatalf("expected the retry to wait, got %+v (err=%v)", due, err)
+		}
+		delivery, err := store.Webhooks.GetDelivery(closedOnly, deliveryID)
+		if err != nil || delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 ||
+			*delivery.ResponseStatus != status || delivery.LastError == "" || delivery.LastAttemptAt == nil {
+			t.Fatalf("expected the failed attempt to be recorded, got %+v (err=%v)", delivery, err)
+		}
+
+		redeliveryID, err := store.Webhooks.Enqueue(closedOnly, "evt", models.WebhookEventDecisionClosed, payload, &deliveryID, now)
+		if err != nil {
+			t.Fatalf("Enqueue returned error: %v", err)
+		}
+		if err := store.Webhooks.MarkDelivered(redeliveryID, http.StatusOK, now); err != nil {
+			t.Fatalf("MarkDelivered returned error: %v", err)
+		}
+		if err := store.Webhooks.MarkAttemptFailed(deliveryID, nil, "gave up", nil); err != nil {
+			t.Fatalf("MarkAttemptFailed returned error: %v", err)
+		}
+		deliveries, err := store.Webhooks.ListDeliveries(closedOnly, 10)
+		if err != nil || len(deliveries) != 2 || deliveries[0].ID != redeliveryID || *deliveries[0].RedeliveryOf != deliveryID ||
+			deliveries[0].Status != models.WebhookDeliveryDelivered || deliveries[0].DeliveredAt == nil ||
+			deliveries[1].Status != models.WebhookDeliveryFailed || deliveries[1].NextAttemptAt != nil {
+			t.Fatalf("expected the delivery log newest first, got %+v (err=%v)", deliveries, err)
+		}
+
+		if err := store.Webhooks.Delete(workspace.ID, closedOnly); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		if _, err := store.Webhooks.GetDelivery(closedOnly, deliveryID); err != ErrNotFound {
+			t.Fatalf("expected the deliveries to go with the webhook, got %v", err)
+		}
+		if err := store.Webhooks.Delete(workspace.ID, closedOnly); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+		}
+		if err := store.Workspaces.Delete(workspace.ID); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		if webhooks, err := store.Webhooks.List(workspace.ID); err != nil || len(webhooks) != 0 {
+			t.Fatalf("expected the workspace's webhooks to be deleted, got %+v (err=%v)", webhooks, err)
+		}
+	})
+}
+
+func TestMFALockout(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "mfa@example.com")
+
+		for want := 1; want <= 3; want++ {
+			failures, err := store.MFA.RecordFailure(userID)
+			if err != nil || failures != want {
+				t.Fatalf("RecordFailure = %d (err=%v), want %d", failures, err, want)
+			}
+		}
+		until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		if err := store.MFA.Lock(userID, until); err != nil {
+			t.Fatalf("Lock returned error: %v", err)
+		}
+		settings, err := store.MFA.Get(userID)
+		if err != nil || settings.FailedAttempts != 3 || !settings.Locked(time.Now()) || settings.Locked(until) {
+			t.Fatalf("expected the user to be locked out after 3 failures, got %+v (err=%v)", settings, err)
+		}
+
+		// A login completes once per challenge.
+		if err := store.MFA.RecordLogin(userID, settings.Logins); err != nil {
+			t.Fatalf("RecordLogin returned error: %v", err)
+		}
+		if err := store.MFA.RecordLogin(userID, settings.Logins); err != ErrNotFound {
+			t.Fatalf("expected a second login from the same challenge to be refused, got %v", err)
+		}
+		settings, err = store.MFA.Get(userID)
+		if err != nil || settings.FailedAttempts != 0 || settings.LockedUntil != nil || settings.Logins != 1 {
+			t.Fatalf("expected the login to clear the lockout, got %+v (err=%v)", settings, err)
+		}
+	})
+}
+
+func TestMFARequireByKeepsFirstDeadline(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+
+		first := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		deadline, err := store.MFA.RequireBy(userID, first)
+		if err != nil || !deadline.Equal(first) {
+			t.Fatalf("RequireBy = %v (err=%v), want %v", deadline, err, first)
+		}
+		deadline, err = store.MFA.RequireBy(userID, first.Add(time.Hour))
+		if err != nil || !deadline.Equal(first) {
+			t.Fatalf("expected the first deadline to be kept, got %v (err=%v)", deadline, err)
+		}
+		settings, err := store.MFA.Get(userID)
+		if err != nil || settings.RequiredBy == nil || !settings.RequiredBy.Equal(first) {
+			t.Fatalf("expected Get to report the deadline, got %+v (err=%v)", settings, err)
+		}
+	})
+}
+
+func TestWorkspaceSearch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		otherID := createTestUser(t, store, "other@example.com")
+		workspace, err := store.Workspaces.Create(userID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		// Same upsert shape as the provider sync code, so the second write goes
+		// through the ON CONFLICT update path.
+		otherWorkspace, err := store.Workspaces.Create(otherID, "Elsewhere", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		upsertSignal := func(ownerID, workspaceID int, sourceType, sourceID, title, content, author string) {
+			t.Helper()
+			if _, err := database.DB.Exec(
+				`INSERT INTO signals (user_id, workspace_id, source_type, source_id, title, content, body, author, status, received_at)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'unread', ?)
+				 ON CONFLICT(workspace_id, source_type, source_id) WHERE workspace_id IS NOT NULL DO UPDATE SET
+					title = excluded.title,
+					content = excluded.content,
+					body = excluded.body,
+					author = excluded.author`,
+				ownerID, workspaceID, sourceType, sourceID, title, content, content, author, time.Now(),
+			); err != nil {
+				t.Fatalf("failed to upsert signal: %v", err)
+			}
+		}
+		upsertSignal(userID, workspace.ID, models.SourceTypeGitHub, "1", "Obsolete heading", "nothing yet", "")
+		upsertSignal(userID, workspace.ID, models.SourceTypeGitHub, "1", "Database migration plan", "Steps for the <cutover>", "Jane Doe")
+		upsertSignal(userID, workspace.ID, models.SourceTypeJira, "2", "Weekly sync", "We discussed the migration briefly", "Bob")
+		upsertSignal(otherID, otherWorkspace.ID, models.SourceTypeJira, "3", "Private migration notes", "", "")
+
+		decisionID, err := store.Decisions.Create(workspace.ID, userID, models.DecisionRequest{
+			Title:       "Adopt PostgreSQL",
+			Description: "Run the migration before the next release",
+			Status:      models.DecisionStatusOpen,
+		})
+		if err != nil {
+			t.Fatalf("Create decision returned error: %v", err)
+		}
+
+		search := func(query models.SearchQuery) []models.SearchResult {
+			t.Helper()
+			query.Limit = 20
+			results, err := store.Search.Search(userID, workspace.ID, query)
+			if err != nil {
+				t.Fatalf("Search returned error: %v", err)
+			}
+			return results
+		}
+
+		results := search(models.SearchQuery{Terms: []string{"migration"}})
+		if len(results) != 3 {
+			t.Fatalf("expected two signals and a decision, got %+v", results)
+		}
+		if results[0].Type != models.SearchTypeSignal || results[0].Title != "Database migration plan" {
+			t.Fatalf("expected the title match to rank first, got %+v", results[0])
+		}
+		for _, result := range results {
+			if !strings.Contains(result.Snippet, "<mark>") || strings.Contains(result.Snippet, "<cutover>") {
+				t.Fatalf("expected an escaped, highlighted snippet, got %q", result.Snippet)
+			}
+		}
+
+		if results := search(models.SearchQuery{Terms: []string{"obsolete"}}); len(results) != 0 {
+			t.Fatalf("expected overwritten title to be gone from search, got %+v", results)
+		}
+		if results := search(models.SearchQuery{AuthorTerms: []string{"jane doe"}}); len(results) != 1 || results[0].Author != "Jane Doe" {
+			t.Fatalf("expected author filter to match one signal, got %+v", results)
+		}
+		if results := search(models.SearchQuery{Terms: []string{"migr*"}, Source: models.SourceTypeJira}); len(results) != 1 || results[0].Title != "Weekly sync" {
+			t.Fatalf("expected prefix and source filters to match the Jira signal, got %+v", results)
+		}
+
+		if err := store.Decisions.Delete(workspace.ID, decisionID); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		if results := search(models.SearchQuery{Terms: []string{"migration"}, Type: models.SearchTypeDecision}); len(results) != 0 {
+			t.Fatalf("expected deleted decision to be gone from search, got %+v", results)
+		}
+	})
+}
+
+func TestDecisionSignalLinks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		outsiderID := createTestUser(t, store, "outsider@example.com")
+		workspace, err := store.Workspaces.Create(userID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		var signalID int
+		if err := database.DB.QueryRow(
+			`INSERT INTO signals (user_id, workspace_id, source_type, source_id, external_id, title, status, received_at)
+			 VALUES (?, ?, ?, '1', '1', 'Customer escalation', ?, ?)
+			 RETURNING id`,
+			userID, workspace.ID, models.SourceTypeJira, models.SignalStatusUnread, time.Now(),
+		).Scan(&signalID); err != nil {
+			t.Fatalf("failed to seed signal: %v", err)
+		}
+		decisionID, err := store.Decisions.Create(workspace.ID, userID, models.DecisionRequest{
+			Title:  "Ship the hotfix",
+			Status: models.DecisionStatusOpen,
+		})
+		if err != nil {
+			t.Fatalf("Create decision returned error: %v", err)
+		}
+
+		linked, err := store.Decisions.LinkSignal(decisionID, signalID, userID, time.Now())
+		if err != nil || !linked {
+			t.Fatalf("expected signal to be linked, got %v (err=%v)", linked, err)
+		}
+		if linked, err := store.Decisions.LinkSignal(decisionID, signalID, userID, time.Now()); err != nil || linked {
+			t.Fatalf("expected duplicate link to be ignored, got %v (err=%v)", linked, err)
+		}
+
+		evidence, err := store.Decisions.LinkedSignals(userID, decisionID)
+		if err != nil || len(evidence) != 1 || evidence[0].ID != signalID || evidence[0].LinkedBy != userID {
+			t.Fatalf("expected the linked signal, got %+v (err=%v)", evidence, err)
+		}
+
+		references, err := store.Signals.LinkedDecisions(userID, signalID)
+		if err != nil || len(references) != 1 || references[0].ID != decisionID || references[0].Title != "Ship the hotfix" {
+			t.Fatalf("expected the citing decision, got %+v (err=%v)", references, err)
+		}
+		if references, err := store.Signals.LinkedDecisions(outsiderID, signalID); err != nil || len(references) != 0 {
+			t.Fatalf("expected no decisions for a non-member, got %+v (err=%v)", references, err)
+		}
+
+		if err := store.Decisions.UnlinkSignal(decisionID, signalID); err != nil {
+			t.Fatalf("UnlinkSignal returned error: %v", err)
+		}
+		if err := store.Decisions.UnlinkSignal(decisionID, signalID); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound for a missing link, got %v", err)
+		}
+
+		if _, err := store.Decisions.LinkSignal(decisionID, signalID, userID, time.Now()); err != nil {
+			t.Fatalf("LinkSignal returned error: %v", err)
+		}
+		if err := store.Decisions.Delete(workspace.ID, decisionID); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		var remaining int
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM decision_signals").Scan(&remaining); err != nil || remaining != 0 {
+			t.Fatalf("expected links to be removed with the decision, got %d (err=%v)", remaining, err)
+		}
+	})
+}
+
+func TestDecisionVoting(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		ownerID := createTestUser(t, store, "owner@example.com")
+		memberID := createTestUser(t, store, "member@example.com")
+		workspace, err := store.Workspaces.Create(ownerID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		decisionID, err := store.Decisions.Create(workspace.ID, ownerID, models.DecisionRequest{
+			Title:   "Pick a region",
+			Status:  models.DecisionStatusOpen,
+			Options: []models.DecisionOptionRequest{{Label: "us-east"}, {Label: "eu-west", Description: "Closer to customers"}},
+		})
+		if err != nil {
+			t.Fatalf("Create decision returned error: %v", err)
+		}
+		decision, err := store.Decisions.Get(workspace.ID, decisionID)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if err := store.Decisions.LoadVoting(decision, ownerID); err != nil {
+			t.Fatalf("LoadVoting returned error: %v", err)
+		}
+		if len(decision.Options) != 2 || decision.Options[1].Description != "Closer to customers" {
+			t.Fatalf("expected two options, got %+v", decision.Options)
+		}
+		usEast, euWest := decision.Options[0].ID, decision.Options[1].ID
+
+		if err := store.Decisions.Vote(decisionID, ownerID, []int{euWest, usEast}); err != nil {
+			t.Fatalf("Vote returned error: %v", err)
+		}
+		if err := store.Decisions.Vote(decisionID, memberID, []int{usEast}); err != nil {
+			t.Fatalf("Vote returned error: %v", err)
+		}
+		if err := store.Decisions.Vote(decisionID, memberID, []int{euWest}); err != nil {
+			t.Fatalf("Vote returned error replacing a ballot: %v", err)
+		}
+		if err := store.Decisions.LoadVoting(decision, memberID); err != nil {
+			t.Fatalf("LoadVoting returned error: %v", err)
+		}
+		if decision.Ballots != 2 || decision.Options[1].Votes != 2 || decision.Options[1].Points != 4 || decision.Options[0].Points != 1 {
+			t.Fatalf("unexpected tally: ballots=%d options=%+v", decision.Ballots, decision.Options)
+		}
+		if len(decision.MyRanking) != 1 || decision.MyRanking[0] != euWest {
+			t.Fatalf("expected member's replaced ballot, got %v", decision.MyRanking)
+		}
+
+		if err := store.Decisions.ClearVote(decisionID, memberID); err != nil {
+			t.Fatalf("ClearVote returned error: %v", err)
+		}
+		if err := store.Decisions.ClearVote(decisionID, memberID); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound clearing twice, got %v", err)
+		}
+
+		closedAt := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+		if err := store.Decisions.Update(workspace.ID, decisionID, models.DecisionRequest{
+			Title:  "Pick a region",
+			Status: models.DecisionStatusOpen,
+		}, &models.DecisionOutcome{
+			OptionID:  &euWest,
+			Rationale: "Latency",
+			ClosedBy:  ownerID,
+			ClosedAt:  closedAt,
+		}); err != nil {
+			t.Fatalf("Update returned error closing the decision: %v", err)
+		}
+		if err := store.Decisions.Update(workspace.ID, decisionID, models.DecisionRequest{
+			Title:  "Renamed while closing again",
+			Status: models.DecisionStatusOpen,
+		}, &models.DecisionOutcome{ClosedBy: ownerID, ClosedAt: closedAt}); err != ErrDecisionClosed {
+			t.Fatalf("expected ErrDecisionClosed closing twice, got %v", err)
+		}
+		if err := store.Decisions.Vote(decisionID, memberID, []int{usEast}); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound voting on a closed decision, got %v", err)
+		}
+
+		closed, err := store.Decisions.Get(workspace.ID, decisionID)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if closed.Title != "Pick a region" {
+			t.Fatalf("expected the failed close to roll back the rename, got %q", closed.Title)
+		}
+		if closed.Status != models.DecisionStatusClosed || closed.Outcome == nil || closed.Outcome.OptionID == nil ||
+			*closed.Outcome.OptionID != euWest || closed.Outcome.ClosedBy != ownerID || !closed.Outcome.ClosedAt.Equal(closedAt) {
+			t.Fatalf("unexpected outcome: %+v", closed.Outcome)
+		}
+
+		if err := store.Decisions.Delete(workspace.ID, decisionID); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		var remaining int
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM decision_options").Scan(&remaining); err != nil || remaining != 0 {
+			t.Fatalf("expected options to be removed with the decision, got %d (err=%v)", remaining, err)
+		}
+	})
+}
+
+func TestSyncJobQueue(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		workspace, err := store.Workspaces.Create(userID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		workspaceID := workspace.ID
+		if err := store.Integrations.Upsert(userID, "github", &workspaceID, IntegrationTokens{AccessToken: "token"}, nil); err != nil {
+			t.Fatalf("Upsert returned error: %v", err)
+		}
+		integration, err := store.Integrations.Get(userID, "github", &workspaceID)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		job, created, err := store.SyncJobs.Enqueue(*integration, 3, now)
+		if err != nil || !created || job.Status != models.SyncJobPending || job.WorkspaceID != workspaceID {
+			t.Fatalf("expected a new pending job, got %+v created=%v (err=%v)", job, created, err)
+		}
+		again, created, err := store.SyncJobs.Enqueue(*integration, 3, now)
+		if err != nil || created || again.ID != job.ID {
+			t.Fatalf("expected the pending job to be reused, got %+v created=%v (err=%v)", again, created, err)
+		}
+
+		lease := func(at time.Time) time.Time { return at.Add(time.Minute) }
+		if _, err := store.SyncJobs.ClaimNext(now.Add(-time.Second), lease(now)); err != ErrNotFound {
+			t.Fatalf("expected no job due before run_at, got %v", err)
+		}
+		claimed, err := store.SyncJobs.ClaimNext(now, lease(now))
+		if err != nil || claimed.ID != job.ID || claimed.Status != models.SyncJobRunning || claimed.Attempts != 1 ||
+			claimed.LeaseExpiresAt == nil || !claimed.LeaseExpiresAt.Equal(lease(now)) {
+			t.Fatalf("expected to claim the job, got %+v (err=%v)", claimed, err)
+		}
+		if again, created, err := store.SyncJobs.Enqueue(*integration, 3, now); err != nil || created || again.ID != job.ID {
+			t.Fatalf("expected the running job to be reused, got %+v created=%v (err=%v)", again, created, err)
+		}
+
+		retryAt := now.Add(time.Minute)
+		if err := store.SyncJobs.Retry(job.ID, 1, "boom", retryAt, now); err != nil {
+			t.Fatalf("Retry returned error: %v", err)
+		}
+		if _, err := store.SyncJobs.ClaimNext(now, lease(now)); err != ErrNotFound {
+			t.Fatalf("expected the retry to wait for its backoff, got %v", err)
+		}
+		if _, err := store.SyncJobs.ClaimNext(retryAt, lease(retryAt)); err != nil {
+			t.Fatalf("ClaimNext returned error: %v", err)
+		}
+		if err := store.SyncJobs.Complete(job.ID, 1, retryAt); err != ErrNotFound {
+			t.Fatalf("expected a finished attempt not to complete the next one, got %v", err)
+		}
+		if err := store.SyncJobs.Bury(job.ID, 2, "boom again", retryAt); err != nil {
+			t.Fatalf("Bury returned error: %v", err)
+		}
+		dead, err := store.SyncJobs.Get(job.ID)
+		if err != nil || dead.Status != models.SyncJobDead || dead.Attempts != 2 || dead.LastError != "boom again" ||
+			dead.FinishedAt == nil || dead.LeaseExpiresAt != nil {
+			t.Fatalf("expected a dead job, got %+v (err=%v)", dead, err)
+		}
+		if latest, err := store.SyncJobs.Latest(integration.ID); err != nil || latest.ID != job.ID {
+			t.Fatalf("expected the dead job to be the latest, got %+v (err=%v)", latest, err)
+		}
+		if buried, err := store.SyncJobs.DeadLettered(retryAt.Add(-time.Second)); err != nil || !buried[integration.ID] {
+			t.Fatalf("expected the integration to be dead-lettered, got %v (err=%v)", buried, err)
+		}
+		if buried, err := store.SyncJobs.DeadLettered(retryAt); err != nil || buried[integration.ID] {
+			t.Fatalf("expected the dead letter to cool down, got %v (err=%v)", buried, err)
+		}
+
+		next, created, err := store.SyncJobs.Enqueue(*integration, 3, retryAt)
+		if err != nil || !created || next.ID == job.ID {
+			t.Fatalf("expected a new job after the dead one, got %+v created=%v (err=%v)", next, created, err)
+		}
+		if buried, err := store.SyncJobs.DeadLettered(retryAt.Add(-time.Second)); err != nil || buried[integration.ID] {
+			t.Fatalf("expected a newer job to clear the dead letter, got %v (err=%v)", buried, err)
+		}
+
+		// A heartbeat keeps the job; once the lease runs out it is requeued,
+		// and the lost attempt counts towards max_attempts.
+		if _, err := store.SyncJobs.ClaimNext(retryAt, lease(retryAt)); err != nil {
+			t.Fatalf("ClaimNext returned error: %v", err)
+		}
+		renewed := lease(retryAt).Add(time.Minute)
+		if err := store.SyncJobs.Heartbeat(next.ID, 1, renewed, lease(retryAt)); err != nil {
+			t.Fatalf("Heartbeat returned error: %v", err)
+		}
+		if requeued, buried, err := store.SyncJobs.RequeueExpired("lost", lease(retryAt).Add(time.Second)); err != nil || requeued != 0 || buried != 0 {
+			t.Fatalf("expected a renewed lease to keep the job, got %d requeued, %d buried (err=%v)", requeued, buried, err)
+		}
+		if requeued, buried, err := store.SyncJobs.RequeueExpired("lost", renewed.Add(time.Second)); err != nil || requeued != 1 || buried != 0 {
+			t.Fatalf("expected one expired job to be requeued, got %d requeued, %d buried (err=%v)", requeued, buried, err)
+		}
+		if err := store.SyncJobs.Heartbeat(next.ID, 1, renewed, renewed); err != ErrNotFound {
+			t.Fatalf("expected the requeued attempt to have lost its lease, got %v", err)
+		}
+		for attempt := 2; attempt <= 3; attempt++ {
+			at := renewed.Add(time.Duration(attempt) * time.Hour)
+			if _, err := store.SyncJobs.ClaimNext(at, lease(at)); err != nil {
+				t.Fatalf("ClaimNext returned error: %v", err)
+			}
+			requeued, buried, err := store.SyncJobs.RequeueExpired("lost", lease(at).Add(time.Second))
+			if err != nil || requeued+buried != 1 || (attempt == 3) != (buried == 1) {
+				t.Fatalf("attempt %d: got %d requeued, %d buried (err=%v)", attempt, requeued, buried, err)
+			}
+		}
+		expired, err := store.SyncJobs.Get(next.ID)
+		if err != nil || expired.Status != models.SyncJobDead || expired.Attempts != 3 || expired.LastError != "lost" {
+			t.Fatalf("expected the job to be dead-lettered after losing every attempt, got %+v (err=%v)", expired, err)
+		}
+	})
+}
+
+func TestSyncRunHistory(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		if err := store.Integrations.Upsert(userID, "gmail", nil, IntegrationTokens{AccessToken: "token"}, nil); err != nil {
+			t.Fatalf("Upsert returned error: %v", err)
+		}
+		integration, err := store.Integrations.Newest(userID, "gmail", nil)
+		if err != nil {
+			t.Fatalf("Newest returned error: %v", err)
+		}
+		byID, err := store.Integrations.GetByID(integration.ID)
+		if err != nil || byID.UserID != userID || byID.AccessToken != "token" {
+			t.Fatalf("expected GetByID to load the integration, got %+v (err=%v)", byID, err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		job, _, err := store.SyncJobs.Enqueue(*integration, 3, now)
+		if err != nil {
+			t.Fatalf("Enqueue returned error: %v", err)
+		}
+
+		if _, err := store.SyncRuns.Latest(integration.ID, ""); err != ErrNotFound {
+			t.Fatalf("expected no finished runs, got %v", err)
+		}
+
+		record := func(fetched, upserted, failed int, message string) {
+			t.Helper()
+			runID, err := store.SyncRuns.Start(*job, now)
+			if err != nil {
+				t.Fatalf("Start returned error: %v", err)
+			}
+			if err := store.SyncRuns.Finish(runID, fetched, upserted, failed, message, now.Add(time.Second)); err != nil {
+				t.Fatalf("Finish returned error: %v", err)
+			}
+		}
+		record(5, 4, 1, "")
+		record(1, 0, 0, "rate limited")
+		record(0, 0, 0, "timeout")
+
+		if failures, err := store.SyncRuns.ConsecutiveFailures(integration.ID); err != nil || failures != 2 {
+			t.Fatalf("expected 2 consecutive failures, got %d (err=%v)", failures, err)
+		}
+		latest, err := store.SyncRuns.Latest(integration.ID, "")
+		if err != nil || latest.Status != models.SyncRunFailed || latest.Error != "timeout" || latest.JobID != job.ID || latest.FinishedAt == nil {
+			t.Fatalf("expected the latest run to be the timeout, got %+v (err=%v)", latest, err)
+		}
+		success, err := store.SyncRuns.Latest(integration.ID, models.SyncRunSucceeded)
+		if err != nil || success.ItemsFetched != 5 || success.ItemsUpserted != 4 || success.ItemsFailed != 1 || success.Error != "" {
+			t.Fatalf("expected the successful run, got %+v (err=%v)", success, err)
+		}
+
+		record(2, 2, 0, "")
+		if failures, err := store.SyncRuns.ConsecutiveFailures(integration.ID); err != nil || failures != 0 {
+			t.Fatalf("expected failures to reset after a success, got %d (err=%v)", failures, err)
+		}
+		if err := store.SyncRuns.Finish(999, 0, 0, 0, "", now); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound finishing an unknown run, got %v", err)
+		}
+	})
+}
+
+func TestIntegrationReauthFlag(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		if err := store.Integrations.Upsert(userID, "gmail", nil, IntegrationTokens{AccessToken: "token"}, nil); err != nil {
+			t.Fatalf("Upsert returned error: %v", err)
+		}
+		integration, err := store.Integrations.Get(userID, "gmail", nil)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		if marked, err := store.Integrations.MarkReauthRequired(integration.ID, "invalid_grant", now); err != nil || !marked {
+			t.Fatalf("expected the integration to be flagged, got %v (err=%v)", marked, err)
+		}
+		if marked, err := store.Integrations.MarkReauthRequired(integration.ID, "invalid_grant", now.Add(time.Minute)); err != nil || marked {
+			t.Fatalf("expected an already flagged integration to stay as is, got %v (err=%v)", marked, err)
+		}
+
+		all, err := store.Integrations.All()
+		if err != nil || len(all) != 1 || all[0].ReauthRequiredAt == nil || !all[0].ReauthRequiredAt.Equal(now) {
+			t.Fatalf("expected All to report the flag, got %+v (err=%v)", all, err)
+		}
+		listed, err := store.Integrations.ListForUser(userID, nil)
+		if err != nil || len(listed) != 1 || listed[0].ReauthReason != "invalid_grant" {
+			t.Fatalf("expected ListForUser to report the reason, got %+v (err=%v)", listed, err)
+		}
+
+		if err := store.Integrations.UpdateTokens(integration.ID, IntegrationTokens{AccessToken: "refreshed"}); err != nil {
+			t.Fatalf("UpdateTokens returned error: %v", err)
+		}
+		if stored, err := store.Integrations.GetByID(integration.ID); err != nil || stored.ReauthRequiredAt == nil {
+			t.Fatalf("expected a token refresh to keep the flag, got %+v (err=%v)", stored, err)
+		}
+
+		if err := store.Integrations.Upsert(userID, "gmail", nil, IntegrationTokens{AccessToken: "reconnected"}, nil); err != nil {
+			t.Fatalf("Upsert returned error: %v", err)
+		}
+		stored, err := store.Integrations.GetByID(integration.ID)
+		if err != nil || stored.ReauthRequiredAt != nil || stored.ReauthReason != "" {
+			t.Fatalf("expected reconnecting to clear the flag, got %+v (err=%v)", stored, err)
+		}
+	})
+}
+
+func TestSignalListCursorsAndFilters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		workspace, err := store.Workspaces.Create(userID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		newest := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		seeds := []struct {
+			name, sourceType, author string
+			metadata                 interface{}
+			receivedAt               time.Time
+		}{
+			{"api", models.SourceTypeGitHub, "Ada", models.GitHubMetadata{Repository: "acme/api", Type: "issue"}, newest},
+			{"web", models.SourceTypeGitHub, "Bob", models.GitHubMetadata{Repository: "acme/webXapp", Type: "issue"}, newest},
+			{"ops", models.SourceTypeJira, "ada", models.JiraMetadata{ProjectKey: "OPS", IssueKey: "OPS-1"}, newest.Add(-time.Hour)},
+			{"eng", models.SourceTypeJira, "Cy", models.JiraMetadata{ProjectKey: "ENG", IssueKey: "ENG-1"}, newest.Add(-2 * time.Hour)},
+			{"chat", models.SourceTypeSlack, "Dee", models.SlackMetadata{ChannelID: "C1"}, newest.Add(-3 * time.Hour)},
+		}
+		ids := make(map[string]int)
+		for _, seed := range seeds {
+			metadata, _ := json.Marshal(seed.metadata)
+			var id int
+			if err := database.DB.QueryRow(
+				`INSERT INTO signals (user_id, workspace_id, source_type, source_id, external_id, title, author, status, source_metadata, received_at)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				 RETURNING id`,
+				userID, workspace.ID, seed.sourceType, seed.name, seed.name, seed.name, seed.author,
+				models.SignalStatusUnread, string(metadata), seed.receivedAt,
+			).Scan(&id); err != nil {
+				t.Fatalf("failed to seed signal: %v", err)
+			}
+			ids[seed.name] = id
+		}
+		if database.CurrentDialect() == database.DialectSQLite {
+			// Rows written with CURRENT_TIMESTAMP store a different text format
+			// for the same instant; cursors must still see them as a tie.
+			if _, err := database.DB.Exec("UPDATE signals SET received_at = '2024-03-01 10:00:00' WHERE id = ?", ids["web"]); err != nil {
+				t.Fatalf("failed to rewrite received_at: %v", err)
+			}
+		}
+
+		decisionID, err := store.Decisions.Create(workspace.ID, userID, models.DecisionRequest{Title: "Pick", Status: models.DecisionStatusOpen})
+		if err != nil {
+			t.Fatalf("Create decision returned error: %v", err)
+		}
+		if _, err := store.Decisions.LinkSignal(decisionID, ids["eng"], userID, time.Now()); err != nil {
+			t.Fatalf("LinkSignal returned error: %v", err)
+		}
+
+		names := func(page *models.SignalListResponse) string {
+			var out []string
+			for _, signal := range page.Signals {
+				out = append(out, signal.SourceID)
+			}
+			return strings.Join(out, ",")
+		}
+		list := func(filter models.SignalFilter) *models.SignalListResponse {
+			t.Helper()
+			filter.WorkspaceID = workspace.ID
+			if filter.Limit == 0 {
+				filter.Limit = 50
+			}
+			page, err := store.Signals.List(userID, filter)
+			if err != nil {
+				t.Fatalf("List(%+v) returned error: %v", filter, err)
+			}
+			return page
+		}
+
+		// Ties on received_at fall back to the id, newest first.
+		first := list(models.SignalFilter{Limit: 2})
+		if names(first) != "web,api" || first.Total != 5 || first.PrevCursor != "" || first.NextCursor == "" {
+			t.Fatalf("unexpected first page %q %+v", names(first), first)
+		}
+		second := list(models.SignalFilter{Limit: 2, Cursor: first.NextCursor})
+		if names(second) != "ops,eng" || second.Total != 5 || second.PrevCursor == "" || second.NextCursor == "" {
+			t.Fatalf("unexpected second page %q %+v", names(second), second)
+		}
+		last := list(models.SignalFilter{Limit: 2, Cursor: second.NextCursor})
+		if names(last) != "chat" || last.NextCursor != "" || last.PrevCursor == "" {
+			t.Fatalf("unexpected last page %q %+v", names(last), last)
+		}
+		back := list(models.SignalFilter{Limit: 2, Cursor: last.PrevCursor})
+		if names(back) != "ops,eng" || back.PrevCursor == "" || back.NextCursor == "" {
+			t.Fatalf("unexpected page going back %q %+v", names(back), back)
+		}
+		start := list(models.SignalFilter{Limit: 2, Cursor: back.PrevCursor})
+		if names(start) != "web,api" || start.PrevCursor != "" || start.NextCursor == "" {
+			t.Fatalf("unexpected page back at the start %q %+v", names(start), start)
+		}
+
+		since, until := newest.Add(-90*time.Minute), newest
+		hasDecision, noDecision := true, false
+		filters := []struct {
+			filter models.SignalFilter
+			want   string
+			total  int
+		}{
+			{models.SignalFilter{Author: "ADA"}, "api,ops", 2},
+			{models.SignalFilter{Since: &since, Until: &until}, "ops", 1},
+			{models.SignalFilter{SourceTypes: []string{models.SourceTypeJira, models.SourceTypeSlack}}, "ops,eng,chat", 3},
+			{models.SignalFilter{Repositories: []string{"acme/web_app"}}, "", 0},
+			{models.SignalFilter{Repositories: []string{"acme/webXapp"}}, "web", 1},
+			{models.SignalFilter{Repositories: []string{"acme/api"}, ProjectKeys: []string{"ENG"}}, "api,eng", 2},
+			{models.SignalFilter{HasDecision: &hasDecision}, "eng", 1},
+			{models.SignalFilter{HasDecision: &noDecision, Limit: 1}, "web", 4},
+		}
+		for _, test := range filters {
+			page := list(test.filter)
+			if names(page) != test.want || page.Total != test.total {
+				t.Fatalf("List(%+v) = %q (total %d), want %q (total %d)", test.filter, names(page), page.Total, test.want, test.total)
+			}
+		}
+
+		if _, err := store.Signals.List(userID, models.SignalFilter{WorkspaceID: workspace.ID, Limit: 2, Cursor: "not-a-cursor"}); err != ErrInvalidCursor {
+			t.Fatalf("expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
+
+func TestSignalSourceUpserts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		workspace, err := store.Workspaces.Create(userID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+
+		receivedAt := time.Now().UTC().Truncate(time.Second)
+		item := SourceSignal{
+			UserID:      userID,
+			WorkspaceID: workspace.ID,
+			SourceType:  models.SourceTypeJira,
+			SourceID:    "cloud-1:10001",
+			ExternalID:  "OPS-1",
+			Title:       "[OPS-1] Failover",
+			Body:        "first",
+			Status:      "To Do",
+			Metadata:    `{"number":7,"state":"open"}`,
+			ReceivedAt:  receivedAt,
+		}
+		created, err := store.Signals.UpsertSource(item, SourceTitle, SourceStatus, SourceMetadata)
+		if err != nil || !created {
+			t.Fatalf("expected the signal to be created, got %v (err=%v)", created, err)
+		}
+		stored, err := store.Signals.FindBySource(workspace.ID, models.SourceTypeJira, "cloud-1:10001")
+		if err != nil {
+			t.Fatalf("FindBySource returned error: %v", err)
+		}
+		if err := store.Signals.ArchiveForAll(stored.ID); err != nil {
+			t.Fatalf("ArchiveForAll returned error: %v", err)
+		}
+
+		sourceUpdatedAt := receivedAt.Add(time.Hour)
+		item.Title = "[OPS-1] Failover drill"
+		item.Body = "second"
+		item.Status = "In Progress"
+		item.UpdatedAt = sourceUpdatedAt
+		created, err = store.Signals.UpsertSource(item, SourceTitle, SourceStatus, SourceMetadata)
+		if err != nil || created {
+			t.Fatalf("expected the signal to be refreshed, got created=%v (err=%v)", created, err)
+		}
+		refreshed, err := store.Signals.Find(stored.ID)
+		if err != nil {
+			t.Fatalf("Find returned error: %v", err)
+		}
+		if refreshed.Title != "[OPS-1] Failover drill" || refreshed.Body != "first" || refreshed.Status != models.SignalStatusArchived {
+			t.Fatalf("expected only the refresh columns to change and the archive to stick, got %+v", refreshed)
+		}
+		if !refreshed.UpdatedAt.Equal(sourceUpdatedAt) {
+			t.Fatalf("expected updated_at %v, got %v", sourceUpdatedAt, refreshed.UpdatedAt)
+		}
+
+		// A personal signal with the same source is stored separately.
+		personal := item
+		personal.WorkspaceID = 0
+		if created, err := store.Signals.UpsertSource(personal, SourceTitle); err != nil || !created {
+			t.Fatalf("expected a personal signal to be created, got %v (err=%v)", created, err)
+		}
+		if created, err := store.Signals.UpsertSource(personal, SourceTitle); err != nil || created {
+			t.Fatalf("expected the personal signal to be refreshed, got created=%v (err=%v)", created, err)
+		}
+
+		sources, err := store.Signals.Sources(workspace.ID, models.SourceTypeJira)
+		if err != nil || len(sources) != 1 || sources[0].SignalID != stored.ID || sources[0].SourceID != "cloud-1:10001" {
+			t.Fatalf("expected the workspace signal's source, got %+v (err=%v)", sources, err)
+		}
+
+		matched, err := store.Signals.ReplaceInMetadata(workspace.ID, models.SourceTypeJira, `%"number":7%`, `"state":"open"`, `"state":"closed"`)
+		if err != nil || len(matched) != 1 || matched[0] != stored.ID {
+			t.Fatalf("expected the signal to match, got %v (err=%v)", matched, err)
+		}
+		if matched, err := store.Signals.ReplaceInMetadata(workspace.ID, models.SourceTypeJira, `%"number":8%`, `"state":"open"`, `"state":"closed"`); err != nil || len(matched) != 0 {
+			t.Fatalf("expected no signal to match another number, got %v (err=%v)", matched, err)
+		}
+
+		signalID, err := store.Signals.EditSource(workspace.ID, models.SourceTypeJira, "cloud-1:10001", func(body, metadata string) (string, string, string, error) {
+			if metadata != `{"number":7,"state":"closed"}` {
+				t.Fatalf("unexpected metadata passed to edit: %s", metadata)
+			}
+			return "Edited", body + " edited", metadata, nil
+		})
+		if err != nil || signalID != stored.ID {
+			t.Fatalf("EditSource returned %d (err=%v)", signalID, err)
+		}
+		if edited, err := store.Signals.Find(stored.ID); err != nil || edited.Title != "Edited" || edited.Body != "first edited" || edited.Content != "first edited" {
+			t.Fatalf("unexpected edited signal %+v (err=%v)", edited, err)
+		}
+		if _, err := store.Signals.EditSource(workspace.ID, models.SourceTypeJira, "missing", func(body, metadata string) (string, string, string, error) {
+			return "", "", "", nil
+		}); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound editing a missing item, got %v", err)
+		}
+
+		touchedAt := sourceUpdatedAt.Add(time.Hour)
+		if err := store.Signals.Touch(stored.ID, touchedAt); err != nil {
+			t.Fatalf("Touch returned error: %v", err)
+		}
+		if touched, err := store.Signals.Find(stored.ID); err != nil || !touched.UpdatedAt.Equal(touchedAt) {
+			t.Fatalf("expected updated_at %v, got %+v (err=%v)", touchedAt, touched, err)
+		}
+		if err := store.Signals.Touch(stored.ID+100, touchedAt); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound touching a missing signal, got %v", err)
+		}
+	})
+}
+
+func TestIntegrationsByProvider(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "owner@example.com")
+		workspace, err := store.Workspaces.Create(userID, "Team", "")
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		workspaceID := workspace.ID
+		if err := store.Integrations.Upsert(userID, "slack", &workspaceID, IntegrationTokens{AccessToken: "token"}, map[string]interface{}{"team_id": "T1"}); err != nil {
+			t.Fatalf("Upsert returned error: %v", err)
+		}
+		if err := store.Integrations.Upsert(userID, "gmail", nil, IntegrationTokens{AccessToken: "token"}, nil); err != nil {
+			t.Fatalf("Upsert returned error: %v", err)
+		}
+
+		slack, err := store.Integrations.ListByProvider("slack")
+		if err != nil || len(slack) != 1 {
+			t.Fatalf("expected one Slack integration, got %+v (err=%v)", slack, err)
+		}
+		if slack[0].WorkspaceID != workspace.ID || slack[0].AccessToken != "" || !strings.Contains(slack[0].Metadata, `"team_id":"T1"`) {
+			t.Fatalf("unexpected Slack integration %+v", slack[0])
+		}
+		if jira, err := store.Integrations.ListByProvider("jira"); err != nil || len(jira) != 0 {
+			t.Fatalf("expected no Jira integrations, got %+v (err=%v)", jira, err)
+		}
+	})
+}
+
+func TestPasswordResets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *Store) {
+		userID := createTestUser(t, store, "reader@example.com")
+		now := time.Now().UTC().Truncate(time.Second)
+
+		if err := store.PasswordResets.Replace(userID, "first", now.Add(time.Hour), now); err != nil {
+			t.Fatalf("Replace returned error: %v", err)
+		}
+		if err := store.PasswordResets.Replace(userID, "second", now.Add(time.Hour), now); err != nil {
+			t.Fatalf("Replace returned error: %v", err)
+		}
+		if _, err := store.PasswordResets.GetByTokenHash("first"); err != ErrNotFound {
+			t.Fatalf("expected a new token to replace the old one, got %v", err)
+		}
+		reset, err := store.PasswordResets.GetByTokenHash("second")
+		if err != nil || reset.UserID != userID || reset.Email != "reader@example.com" || reset.UsedAt != nil || !reset.ExpiresAt.Equal(now.Add(time.Hour)) {
+			t.Fatalf("unexpected reset %+v (err=%v)", reset, err)
+		}
+
+		sessionID, err := store.Sessions.Create(models.Session{UserID: userID, RefreshTokenHash: "refresh", ExpiresAt: now.Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Create session returned error: %v", err)
+		}
+		if err := store.PasswordResets.Redeem(userID, "new-hash", now); err != nil {
+			t.Fatalf("Redeem returned error: %v", err)
+		}
+		if reset, err := store.PasswordResets.GetByTokenHash("second"); err != nil || reset.UsedAt == nil {
+			t.Fatalf("expected the token to be used, got %+v (err=%v)", reset, err)
+		}
+		if session, err := store.Sessions.Get(sessionID); err != nil || session.RevokedAt == nil {
+			t.Fatalf("expected the session to be revoked, got %+v (err=%v)", session, err)
+		}
+		var password string
+		if err := database.DB.QueryRow("SELECT password FROM users WHERE id = ?", userID).Scan(&password); err != nil || password != "new-hash" {
+			t.Fatalf("expected the new password hash, got %q (err=%v)", password, err)
+		}
+
+		if err := store.PasswordResets.Delete("second"); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+		if _, err := store.PasswordResets.GetByTokenHash("second"); err != ErrNotFound {
+			t.Fatalf("expected the token to be deleted, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"encoding/json"
+	"sentinent-backend/models"
+	"time"
+)
+
+type RuleRepository struct {
+	*queries
+}
+
+const ruleColumns = `id, workspace_id, name, position, enabled, conditions, actions, created_by, created_at, updated_at`
+
+// List returns the workspace's rules in the order they run.
+func (r *RuleRepository) List(workspaceID int) ([]models.SignalRule, error) {
+	return r.list(`SELECT `+ruleColumns+` FROM signal_rules WHERE workspace_id = ? ORDER BY position, id`, workspaceID)
+}
+
+// ListEnabled returns the workspace's enabled rules in the order they run.
+func (r *RuleRepository) ListEnabled(workspaceID int) ([]models.SignalRule, error) {
+	return r.list(`SELECT `+ruleColumns+` FROM signal_rules WHERE workspace_id = ? AND enabled = ? ORDER BY position, id`, workspaceID, true)
+}
+
+func (r *RuleRepository) list(query string, args ...interface{}) ([]models.SignalRule, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]models.SignalRule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *RuleRepository) Get(workspaceID, ruleID int) (*models.SignalRule, error) {
+	return scanRule(r.db.QueryRow(
+		`SELECT `+ruleColumns+` FROM signal_rules WHERE workspace_id = ? AND id = ?`,
+		workspaceID, ruleID,
+	))
+}
+
+func (r *RuleRepository) Create(workspaceID, userID int, req models.SignalRuleRequest) (int, error) {
+	conditions, actions, err := encodeRule(req)
+	if err != nil {
+		return 0, err
+	}
+	return r.insertID(r.db,
+		`INSERT INTO signal_rules (workspace_id, name, position, enabled, conditions, actions, created_by, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		workspaceID, req.Name, req.Position, ruleEnabled(req), conditions, actions, userID,
+	)
+}
+
+// Update replaces a rule. Signals it already acted on are not revisited.
+func (r *RuleRepository) Update(workspaceID, ruleID int, req models.SignalRuleRequest) error {
+	conditions, actions, err := encodeRule(req)
+	if err != nil {
+		return err
+	}
+	return r.execAffecting(
+		`UPDATE signal_rules
+		 SET name = ?, position = ?, enabled = ?, conditions = ?, actions = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE workspace_id = ? AND id = ?`,
+		req.Name, req.Position, ruleEnabled(req), conditions, actions, workspaceID, ruleID,
+	)
+}
+
+func (r *RuleRepository) Delete(workspaceID, ruleID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`DELETE FROM signal_rule_matches WHERE rule_id IN (SELECT id FROM signal_rules WHERE workspace_id = ? AND id = ?)`,
+		workspaceID, ruleID,
+	); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM signal_rules WHERE workspace_id = ? AND id = ?`, workspaceID, ruleID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// RecordMatch claims a signal for a rule and reports whether this is the
+// first time the rule matched it. Only the first match should act.
+func (r *RuleRepository) RecordMatch(ruleID, signalID int, matchedAt time.Time) (bool, error) {
+	result, err := r.db.Exec(
+		`INSERT INTO signal_rule_matches (rule_id, signal_id, matched_at)
+		 VALUES (?, ?, ?)
+		 ON CONFLICT (rule_id, signal_id) DO NOTHING`,
+		ruleID, signalID, matchedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func ruleEnabled(req models.SignalRuleRequest) bool {
+	return req.Enabled == nil || *req.Enabled
+}
+
+func encodeRule(req models.SignalRuleRequest) (string, string, error) {
+	conditions, err := json.Marshal(req.Conditions)
+	if err != nil {
+		return "", "", err
+	}
+	actions, err := json.Marshal(req.Actions)
+	if err != nil {
+		return "", "", err
+	}
+	return string(conditions), string(actions), nil
+}
+
+func scanRule(scanner rowScanner) (*models.SignalRule, error) {
+	var rule models.SignalRule
+	var conditions, actions string
+	if err := scanner.Scan(
+		&rule.ID, &rule.WorkspaceID, &rule.Name, &rule.Position, &rule.Enabled,
+		&conditions, &actions, &rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(conditions), &rule.Conditions); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(actions), &rule.Actions); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
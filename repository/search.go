@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"html"
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"sort"
+	"strings"
+)
+
+type SearchRepository struct {
+	*queries
+}
+
+// Snippets are built with control characters as match markers and only turned
+// into <mark> tags after the surrounding text has been HTML-escaped.
+const (
+	snippetOpen     = "\x02"
+	snippetClose    = "\x03"
+	snippetEllipsis = "…"
+	snippetWords    = 16
+
+	// fallbackCandidates caps how many recent rows substring search scores.
+	fallbackCandidates = 200
+)
+
+// Search ranks the user's signals and the workspace's decisions against the
+// query, best match first. SQLite databases with FTS5 indexes use them; other
+// databases fall back to case-insensitive substring matching.
+func (r *SearchRepository) Search(userID, workspaceID int, query models.SearchQuery) ([]models.SearchResult, error) {
+	indexed, err := r.indexed()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SearchResult, 0)
+	if query.Type != models.SearchTypeDecision {
+		var signals []models.SearchResult
+		if indexed {
+			signals, err = r.matchSignals(userID, workspaceID, query)
+		} else {
+			signals, err = r.scanSignals(userID, workspaceID, query)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, signals...)
+	}
+
+	// Decisions have no author or source, so those filters exclude them.
+	if query.Type != models.SearchTypeSignal && query.Source == "" && len(query.AuthorTerms) == 0 {
+		var decisions []models.SearchResult
+		if indexed {
+			decisions, err = r.matchDecisions(workspaceID, query)
+		} else {
+			decisions, err = r.scanDecisions(workspaceID, query)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, decisions...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	for i := range results {
+		results[i].Snippet = renderSnippet(results[i].Snippet)
+	}
+	return results, nil
+}
+
+// indexed reports whether the FTS5 indexes exist and are being kept in sync.
+func (r *SearchRepository) indexed() (bool, error) {
+	if r.dialect != database.DialectSQLite {
+		return false, nil
+	}
+	var triggers int
+	err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master
+		 WHERE type = 'trigger' AND name IN ('signals_fts_insert', 'decisions_fts_insert')`,
+	).Scan(&triggers)
+	return triggers == 2, err
+}
+
+func (r *SearchRepository) matchSignals(userID, workspaceID int, query models.SearchQuery) ([]models.SearchResult, error) {
+	match := ftsMatch(query, true)
+	if match == "" {
+		return nil, nil
+	}
+
+	where := `WHERE signals_fts MATCH ? AND s.workspace_id = ?`
+	args := []interface{}{snippetOpen, snippetClose, snippetEllipsis, snippetWords, userID, match, workspaceID}
+	if query.Source != "" {
+		where += " AND s.source_type = ?"
+		args = append(args, query.Source)
+	}
+	args = append(args, query.Limit)
+
+	// bm25 weights: title 10, content 1, body 1, author 5. Lower is better.
+	rows, err := r.db.Query(
+		`SELECT s.id, s.title, s.source_type, COALESCE(s.author, ''), COALESCE(s.url, ''),
+		        COALESCE(ss.status, s.status, ''), s.received_at,
+		        snippet(signals_fts, -1, ?, ?, ?, ?),
+		        bm25(signals_fts, 10.0, 1.0, 1.0, 5.0) AS rank_score
+		 FROM signals_fts
+		 JOIN signals s ON s.id = signals_fts.rowid
+		 LEFT JOIN signal_status ss ON ss.signal_id = s.id AND ss.user_id = ?
+		 `+where+`
+		 ORDER BY rank_score
+		 LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.SearchResult, 0)
+	for rows.Next() {
+		result := models.SearchResult{Type: models.SearchTypeSignal}
+		var rank float64
+		if err := rows.Scan(
+			&result.ID, &result.Title, &result.SourceType, &result.Author, &result.URL,
+			&result.Status, &result.CreatedAt, &result.Snippet, &rank,
+		); err != nil {
+			return nil, err
+		}
+		result.Score = -rank
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (r *SearchRepository) matchDecisions(workspaceID int, query models.SearchQuery) ([]models.SearchResult, error) {
+	match := ftsMatch(query, false)
+	if match == "" {
+		return nil, nil
+	}
+
+	// bm25 weights: title 10, description 1.
+	rows, err := r.db.Query(
+		`SELECT d.id, d.title, d.status, d.created_at,
+		        snippet(decisions_fts, -1, ?, ?, ?, ?),
+		        bm25(decisions_fts, 10.0, 1.0) AS rank_score
+		 FROM decisions_fts
+		 JOIN decisions d ON d.id = decisions_fts.rowid
+		 WHERE decisions_fts MATCH ? AND d.workspace_id = ?
+		 ORDER BY rank_score
+		 LIMIT ?`,
+		snippetOpen, snippetClose, snippetEllipsis, snippetWords, match, workspaceID, query.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make([]models.SearchResult, 0)
+	for rows.Next() {
+		result := models.SearchResult{Type: models.SearchTypeDecision}
+		var rank float64
+		if err := rows.Scan(&result.ID, &result.Title, &result.Status, &result.CreatedAt, &result.Snippet, &rank); err != nil {
+			return nil, err
+		}
+		result.Score = -rank
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (r *SearchRepository) scanSignals(userID, workspaceID int, query models.SearchQuery) ([]models.SearchResult, error) {
+	where := `WHERE s.workspace_id = ?`
+	args := []interface{}{userID, workspaceID}
+	if query.Source != "" {
+		where += " AND s.source_type = ?"
+		args = append(args, query.Source)
+	}
+	for _, term := range query.Terms {
+		where += " AND (" + likeAny([]string{"s.title", "s.content", "s.body", "s.author"}) + ")"
+		pattern := likePattern(term)
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+	for _, term := range query.TitleTerms {
+		where += " AND " + likeAny([]string{"s.title"})
+		args = append(args, likePattern(term))
+	}
+	for _, term := range query.AuthorTerms {
+		where += " AND " + likeAny([]string{"s.author"})
+		args = append(args, likePattern(term))
+	}
+	args = append(args, fallbackCandidates)
+
+	rows, err := r.db.Query(
+		`SELECT s.id, s.title, s.source_type, COALESCE(s.author, ''), COALESCE(s.url, ''),
+		        COALESCE(ss.status, s.status, ''), s.received_at,
+		        COALESCE(s.content, ''), COALESCE(s.body, '')
+		 FROM signals s
+		 LEFT JOIN signal_status ss ON ss.signal_id = s.id AND ss.user_id = ?
+		 `+where+`
+		 ORDER BY s.received_at DESC
+		 LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := highlightWords(query)
+	results := make([]models.SearchResult, 0)
+	for rows.Next() {
+		result := models.SearchResult{Type: models.SearchTypeSignal}
+		var content, body string
+		if err := rows.Scan(
+			&result.ID, &result.Title, &result.SourceType, &result.Author, &result.URL,
+			&result.Status, &result.CreatedAt, &content, &body,
+		); err != nil {
+			return nil, err
+		}
+		result.Score = substringScore(words, weightedText{result.Title, 10}, weightedText{content, 1}, weightedText{body, 1}, weightedText{result.Author, 5})
+		result.Snippet = highlightSnippet(words, content, body, result.Title)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (r *SearchRepository) scanDecisions(workspaceID int, query models.SearchQuery) ([]models.SearchResult, error) {
+	where := `WHERE d.workspace_id = ?`
+	args := []interface{}{workspaceID}
+	for _, term := range query.Terms {
+		where += " AND (" + likeAny([]string{"d.title", "d.description"}) + ")"
+		pattern := likePattern(term)
+		args = append(args, pattern, pattern)
+	}
+	for _, term := range query.TitleTerms {
+		where += " AND " + likeAny([]string{"d.title"})
+		args = append(args, likePattern(term))
+	}
+	args = append(args, fallbackCandidates)
+
+	rows, err := r.db.Query(
+		`SELECT d.id, d.title, d.status, d.created_at, COALESCE(d.description, '')
+		 FROM decisions d
+		 `+where+`
+		 ORDER BY d.updated_at DESC
+		 LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := highlightWords(query)
+	results := make([]models.SearchResult, 0)
+	for rows.Next() {
+		result := models.SearchResult{Type: models.SearchTypeDecision}
+		var description string
+		if err := rows.Scan(&result.ID, &result.Title, &result.Status, &result.CreatedAt, &description); err != nil {
+			return nil, err
+		}
+		result.Score = substringScore(words, weightedText{result.Title, 10}, weightedText{description, 1})
+		result.Snippet = highlightSnippet(words, description, result.Title)
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// ftsMatch builds an FTS5 MATCH expression. Every term is quoted so user input
+// cannot inject FTS5 operators; a trailing * is kept as a prefix match.
+func ftsMatch(query models.SearchQuery, includeAuthor bool) string {
+	parts := make([]string, 0, len(query.Terms)+len(query.TitleTerms)+len(query.AuthorTerms))
+	for _, term := range query.Terms {
+		parts = append(parts, ftsPhrase(term))
+	}
+	for _, term := range query.TitleTerms {
+		parts = append(parts, "title : "+ftsPhrase(term))
+	}
+	if includeAuthor {
+		for _, term := range query.AuthorTerms {
+			parts = append(parts, "author : "+ftsPhrase(term))
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func ftsPhrase(term string) string {
+	phrase := `"` + strings.ReplaceAll(strings.TrimRight(term, "*"), `"`, `""`) + `"`
+	if strings.HasSuffix(term, "*") {
+		phrase += "*"
+	}
+	return phrase
+}
+
+func likeAny(columns []string) string {
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		conditions[i] = "LOWER(COALESCE(" + column + ", '')) LIKE ? ESCAPE '\\'"
+	}
+	return strings.Join(conditions, " OR ")
+}
+
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(strings.TrimRight(term, "*")))
+	return "%" + escaped + "%"
+}
+
+type weightedText struct {
+	text   string
+	weight float64
+}
+
+// substringScore approximates the FTS5 weighting for databases without it.
+func substringScore(words []string, fields ...weightedText) float64 {
+	score := 0.0
+	for _, field := range fields {
+		text := strings.ToLower(field.text)
+		for _, word := range words {
+			score += field.weight * float64(strings.Count(text, word))
+		}
+	}
+	return score
+}
+
+// highlightSnippet marks query words in a window of the first text that
+// contains one, mirroring what FTS5's snippet() returns.
+func highlightSnippet(words []string, texts ...string) string {
+	for _, text := range texts {
+		fields := strings.Fields(text)
+		first := -1
+		for i, field := range fields {
+			if containsAny(strings.ToLower(field), words) {
+				first = i
+				break
+			}
+		}
+		if first < 0 {
+			continue
+		}
+
+		start := first - snippetWords/2
+		if start < 0 {
+			start = 0
+		}
+		end := start + snippetWords
+		if end > len(fields) {
+			end = len(fields)
+		}
+
+		window := make([]string, 0, end-start)
+		for _, field := range fields[start:end] {
+			if containsAny(strings.ToLower(field), words) {
+				field = snippetOpen + field + snippetClose
+			}
+			window = append(window, field)
+		}
+		snippet := strings.Join(window, " ")
+		if start > 0 {
+			snippet = snippetEllipsis + snippet
+		}
+		if end < len(fields) {
+			snippet += snippetEllipsis
+		}
+		return snippet
+	}
+	return ""
+}
+
+func highlightWords(query models.SearchQuery) []string {
+	words := make([]string, 0)
+	for _, terms := range [][]string{query.Terms, query.TitleTerms, query.AuthorTerms} {
+		for _, term := range terms {
+			words = append(words, strings.Fields(strings.ToLower(strings.TrimRight(term, "*")))...)
+		}
+	}
+	return words
+}
+
+func containsAny(text string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderSnippet(snippet string) string {
+	return strings.NewReplacer(snippetOpen, "<mark>", snippetClose, "</mark>").Replace(html.EscapeString(snippet))
+}
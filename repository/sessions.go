@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/models"
+	"time"
+)
+
+type SessionRepository struct {
+	*queries
+}
+
+const sessionColumns = `id, user_id, refresh_token_hash, COALESCE(previous_refresh_token_hash, ''), COALESCE(user_agent, ''),
+		created_at, last_used_at, expires_at, revoked_at`
+
+func (r *SessionRepository) Create(session models.Session) (int, error) {
+	return r.insertID(r.db,
+		`INSERT INTO sessions (user_id, refresh_token_hash, user_agent, expires_at)
+		 VALUES (?, ?, ?, ?)`,
+		session.UserID, session.RefreshTokenHash, session.UserAgent, session.ExpiresAt,
+	)
+}
+
+func (r *SessionRepository) Get(sessionID int) (*models.Session, error) {
+	return scanSession(r.db.QueryRow(
+		`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`,
+		sessionID,
+	))
+}
+
+// GetByRefreshTokenHash finds the session whose current or previous refresh
+// token has the given hash. Callers compare RefreshTokenHash to tell a reused,
+// already rotated token apart from the current one.
+func (r *SessionRepository) GetByRefreshTokenHash(tokenHash string) (*models.Session, error) {
+	return scanSession(r.db.QueryRow(
+		`SELECT `+sessionColumns+`
+		 FROM sessions
+		 WHERE refresh_token_hash = ? OR previous_refresh_token_hash = ?`,
+		tokenHash, tokenHash,
+	))
+}
+
+// Rotate swaps in a new refresh token hash. It returns ErrNotFound if the
+// session was revoked or rotated by a concurrent request in the meantime.
+func (r *SessionRepository) Rotate(sessionID int, currentHash, newHash string, expiresAt, now time.Time) error {
+	return r.execAffecting(
+		`UPDATE sessions
+		 SET previous_refresh_token_hash = refresh_token_hash, refresh_token_hash = ?, expires_at = ?, last_used_at = ?
+		 WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL`,
+		newHash, expiresAt, now, sessionID, currentHash,
+	)
+}
+
+func (r *SessionRepository) Revoke(sessionID int, now time.Time) error {
+	_, err := r.db.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		now, sessionID,
+	)
+	return err
+}
+
+// RevokeAllForUser revokes every open session and returns how many there were.
+func (r *SessionRepository) RevokeAllForUser(userID int, now time.Time) (int, error) {
+	result, err := r.db.Exec(
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		now, userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+func scanSession(scanner rowScanner) (*models.Session, error) {
+	var session models.Session
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := scanner.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.RefreshTokenHash,
+		&session.PreviousRefreshTokenHash,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&lastUsedAt,
+		&session.ExpiresAt,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		session.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
@@ -0,0 +1,820 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sentinent-backend/models"
+	"strings"
+	"time"
+)
+
+type SignalRepository struct {
+	*queries
+}
+
+const signalColumns = `s.id, s.user_id, s.workspace_id, s.source_type, s.source_id, s.external_id,
+		       s.title, s.content, s.author, s.body, s.url, COALESCE(ss.status, s.status) as status,
+		       s.source_metadata, s.received_at, s.created_at, s.updated_at, ss.snoozed_until, s.assignee_id,
+		       s.labels, s.priority`
+
+// mfaLockedWorkspaces selects the workspaces whose MFA policy the user fails,
+// matching middleware.SatisfiesWorkspaceMFA. It takes the user ID once.
+const mfaLockedWorkspaces = `SELECT id FROM workspaces WHERE require_mfa
+		AND NOT EXISTS (SELECT 1 FROM users WHERE id = ? AND mfa_enabled_at IS NOT NULL)`
+
+// visibleSignal limits s to the signals a user can see: their personal
+// signals, and every signal in the workspaces they own or belong to, whoever's
+// integration brought it in, unless the workspace requires MFA the user has not
+// enabled. It takes the user ID four times.
+const visibleSignal = `((s.workspace_id IS NULL AND s.user_id = ?)
+		OR ((s.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)
+		     OR s.workspace_id IN (SELECT id FROM workspaces WHERE owner_id = ?))
+		    AND s.workspace_id NOT IN (` + mfaLockedWorkspaces + `)))`
+
+var (
+	// ErrInvalidCursor is returned when a signal page cursor cannot be decoded.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrAssignForbidden is returned when a viewer tries to assign a signal.
+	ErrAssignForbidden = errors.New("only workspace members can assign signals")
+	// ErrInvalidAssignee is returned when the assignee does not belong to the
+	// signal's workspace, or the signal has no workspace.
+	ErrInvalidAssignee = errors.New("assignee is not a member of the signal's workspace")
+)
+
+// signalCursor marks a signal's place in the newest-first ordering on
+// (received_at, id). Before selects the page ahead of the signal rather than
+// the one after it.
+type signalCursor struct {
+	ReceivedAt time.Time `json:"t"`
+	ID         int       `json:"id"`
+	Before     bool      `json:"b,omitempty"`
+}
+
+func encodeSignalCursor(signal models.Signal, before bool) string {
+	data, _ := json.Marshal(signalCursor{ReceivedAt: signal.ReceivedAt, ID: signal.ID, Before: before})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSignalCursor(value string) (*signalCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor signalCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// List returns one page of the signals the user can see, newest first, together with
+// the total number of signals matching the filter and cursors for the
+// neighbouring pages.
+func (r *SignalRepository) List(userID int, filter models.SignalFilter) (*models.SignalListResponse, error) {
+	var cursor *signalCursor
+	if filter.Cursor != "" {
+		var err error
+		if cursor, err = decodeSignalCursor(filter.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	where, args := r.signalFilter(userID, filter)
+
+	receivedAt := r.chronological("s.received_at")
+	pageWhere := where
+	pageArgs := append([]interface{}{}, args...)
+	order := receivedAt + " DESC, s.id DESC"
+	if cursor != nil {
+		comparison := "<"
+		if cursor.Before {
+			comparison = ">"
+			order = receivedAt + " ASC, s.id ASC"
+		}
+		at := r.chronological("?")
+		pageWhere += " AND (" + receivedAt + " " + comparison + " " + at +
+			" OR (" + receivedAt + " = " + at + " AND s.id " + comparison + " ?))"
+		pageArgs = append(pageArgs, cursor.ReceivedAt, cursor.ReceivedAt, cursor.ID)
+	}
+
+	// One extra row tells whether another page follows in this direction.
+	query := "SELECT " + signalColumns + pageWhere + " ORDER BY " + order + " LIMIT ?"
+	pageArgs = append(pageArgs, filter.Limit+1)
+	if cursor == nil && filter.Offset > 0 {
+		query += " OFFSET ?"
+		pageArgs = append(pageArgs, filter.Offset)
+	}
+
+	rows, err := r.db.Query(query, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	signals := make([]models.Signal, 0)
+	for rows.Next() {
+		signal, err := scanSignal(rows)
+		if err != nil {
+			continue
+		}
+		signals = append(signals, *signal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := len(signals) > filter.Limit
+	if more {
+		signals = signals[:filter.Limit]
+	}
+	page := &models.SignalListResponse{Signals: signals}
+	if len(signals) > 0 {
+		first, last := signals[0], signals[len(signals)-1]
+		if cursor != nil && cursor.Before {
+			for i, j := 0, len(signals)-1; i < j; i, j = i+1, j-1 {
+				signals[i], signals[j] = signals[j], signals[i]
+			}
+			first, last = signals[0], signals[len(signals)-1]
+			if more {
+				page.PrevCursor = encodeSignalCursor(first, true)
+			}
+			page.NextCursor = encodeSignalCursor(last, false)
+		} else {
+			if more {
+				page.NextCursor = encodeSignalCursor(last, false)
+			}
+			if cursor != nil || filter.Offset > 0 {
+				page.PrevCursor = encodeSignalCursor(first, true)
+			}
+		}
+	}
+
+	if err := r.db.QueryRow("SELECT COUNT(*)"+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// signalFilter builds the FROM and WHERE clauses shared by a signal page and
+// its count, so the total always matches the filtered rows.
+func (r *SignalRepository) signalFilter(userID int, filter models.SignalFilter) (string, []interface{}) {
+	where := ` FROM signals s
+		LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = ?
+		WHERE ` + visibleSignal
+	args := []interface{}{userID, userID, userID, userID, userID}
+
+	if filter.WorkspaceID != 0 {
+		where += " AND s.workspace_id = ?"
+		args = append(args, filter.WorkspaceID)
+	}
+	if filter.AssigneeID != 0 {
+		where += " AND s.assignee_id = ?"
+		args = append(args, filter.AssigneeID)
+	}
+	if filter.Label != "" {
+		where += " AND LOWER(s.labels) LIKE LOWER(?) ESCAPE '\\'"
+		encoded, _ := json.Marshal(filter.Label)
+		args = append(args, "%"+likeEscaper.Replace(string(encoded))+"%")
+	}
+	if filter.Priority != "" {
+		where += " AND s.priority = ?"
+		args = append(args, filter.Priority)
+	}
+	if len(filter.SourceTypes) > 0 {
+		where += " AND s.source_type IN (" + placeholders(len(filter.SourceTypes)) + ")"
+		for _, sourceType := range filter.SourceTypes {
+			args = append(args, sourceType)
+		}
+	}
+	if filter.Status != "" {
+		where += " AND COALESCE(ss.status, s.status) = ?"
+		args = append(args, filter.Status)
+	}
+	if filter.Author != "" {
+		where += " AND LOWER(s.author) = LOWER(?)"
+		args = append(args, filter.Author)
+	}
+	if filter.Since != nil {
+		where += " AND " + r.chronological("s.received_at") + " >= " + r.chronological("?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		where += " AND " + r.chronological("s.received_at") + " < " + r.chronological("?")
+		args = append(args, *filter.Until)
+	}
+
+	// Repositories and project keys live in the metadata JSON, which is
+	// matched the way it was encoded.
+	var sources []string
+	for _, repository := range filter.Repositories {
+		sources = append(sources, "(s.source_type = ? AND s.source_metadata LIKE ? ESCAPE '\\')")
+		args = append(args, models.SourceTypeGitHub, metadataPattern("repository", repository))
+	}
+	for _, projectKey := range filter.ProjectKeys {
+		sources = append(sources, "(s.source_type = ? AND s.source_metadata LIKE ? ESCAPE '\\')")
+		args = append(args, models.SourceTypeJira, metadataPattern("project_key", projectKey))
+	}
+	if len(sources) > 0 {
+		where += " AND (" + strings.Join(sources, " OR ") + ")"
+	}
+
+	if filter.HasDecision != nil {
+		exists := "EXISTS (SELECT 1 FROM decision_signals ds WHERE ds.signal_id = s.id)"
+		if !*filter.HasDecision {
+			exists = "NOT " + exists
+		}
+		where += " AND " + exists
+	}
+	return where, args
+}
+
+// metadataPattern is a LIKE pattern matching a string field in source
+// metadata encoded by encoding/json.
+func metadataPattern(field, value string) string {
+	encoded, _ := json.Marshal(value)
+	fragment := fmt.Sprintf(`"%s":%s`, field, encoded)
+	return "%" + likeEscaper.Replace(fragment) + "%"
+}
+
+// likeEscaper escapes LIKE wildcards for patterns declared with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Get returns a signal the user can see with their read/archive status applied.
+func (r *SignalRepository) Get(userID, signalID int) (*models.Signal, error) {
+	return scanSignal(r.db.QueryRow(
+		`SELECT `+signalColumns+`
+		FROM signals s
+		LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = ?
+		WHERE s.id = ? AND `+visibleSignal,
+		userID, signalID, userID, userID, userID, userID,
+	))
+}
+
+// SetStatus records the user's own status for a signal they can see. Other
+// members keep theirs. It returns ErrNotFound if the signal is not visible to
+// the user.
+func (r *SignalRepository) SetStatus(userID, signalID int, status string) error {
+	return r.Triage(userID, []int{signalID}, models.SignalTriage{Action: models.SignalActionStatus, Status: status})
+}
+
+// Triage applies one triage change to each of signalIDs in a single
+// transaction. Nothing is changed unless every signal is visible to the user
+// (ErrNotFound) and, for assignments, the user may assign it.
+func (r *SignalRepository) Triage(userID int, signalIDs []int, triage models.SignalTriage) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, signalID := range signalIDs {
+		var visible bool
+		if err := tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM signals s WHERE s.id = ? AND "+visibleSignal+")",
+			signalID, userID, userID, userID, userID,
+		).Scan(&visible); err != nil {
+			return err
+		}
+		if !visible {
+			return ErrNotFound
+		}
+
+		switch triage.Action {
+		case models.SignalActionStatus:
+			err = setSignalStatus(tx, userID, signalID, triage.Status, nil, now)
+		case models.SignalActionSnooze:
+			err = setSignalStatus(tx, userID, signalID, models.SignalStatusSnoozed, triage.SnoozedUntil, now)
+		case models.SignalActionAssign:
+			err = assignSignal(tx, userID, signalID, triage.AssigneeID)
+		default:
+			err = fmt.Errorf("unknown signal action %q", triage.Action)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func setSignalStatus(tx *sql.Tx, userID, signalID int, status string, snoozedUntil *time.Time, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO signal_status (signal_id, user_id, status, snoozed_until, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(signal_id, user_id) DO UPDATE SET
+			status = excluded.status,
+			snoozed_until = excluded.snoozed_until,
+			updated_at = excluded.updated_at`,
+		signalID, userID, status, snoozedUntil, now,
+	)
+	return err
+}
+
+// assignSignal sets the shared assignee. Owners and members of the signal's
+// workspace may assign it to anyone in that workspace.
+func assignSignal(tx *sql.Tx, userID, signalID int, assigneeID *int) error {
+	const inWorkspace = `EXISTS(SELECT 1 FROM signals s WHERE s.id = ? AND
+		(s.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?%s)
+		 OR s.workspace_id IN (SELECT id FROM workspaces WHERE owner_id = ?)))`
+
+	var canAssign bool
+	if err := tx.QueryRow(
+		"SELECT "+fmt.Sprintf(inWorkspace, " AND role IN (?, ?)"),
+		signalID, userID, models.RoleOwner, models.RoleMember, userID,
+	).Scan(&canAssign); err != nil {
+		return err
+	}
+	if !canAssign {
+		var personal bool
+		if err := tx.QueryRow("SELECT workspace_id IS NULL FROM signals WHERE id = ?", signalID).Scan(&personal); err != nil {
+			return err
+		}
+		if personal {
+			return ErrInvalidAssignee
+		}
+		return ErrAssignForbidden
+	}
+
+	if assigneeID != nil {
+		var isMember bool
+		if err := tx.QueryRow(
+			"SELECT "+fmt.Sprintf(inWorkspace, ""),
+			signalID, *assigneeID, *assigneeID,
+		).Scan(&isMember); err != nil {
+			return err
+		}
+		if !isMember {
+			return ErrInvalidAssignee
+		}
+	}
+
+	_, err := tx.Exec("UPDATE signals SET assignee_id = ? WHERE id = ?", assigneeID, signalID)
+	return err
+}
+
+// WokenSnooze is a member's snooze that expired and was returned to unread.
+type WokenSnooze struct {
+	SignalID int
+	UserID   int
+}
+
+// WakeSnoozed returns every snooze that expired by now to unread and reports
+// which were woken.
+func (r *SignalRepository) WakeSnoozed(now time.Time) ([]WokenSnooze, error) {
+	rows, err := r.db.Query(
+		`UPDATE signal_status SET status = ?, snoozed_until = NULL, updated_at = ?
+		 WHERE status = ? AND `+r.chronological("snoozed_until")+` <= `+r.chronological("?")+`
+		 RETURNING signal_id, user_id`,
+		models.SignalStatusUnread, now, models.SignalStatusSnoozed, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var woken []WokenSnooze
+	for rows.Next() {
+		var snooze WokenSnooze
+		if err := rows.Scan(&snooze.SignalID, &snooze.UserID); err != nil {
+			return nil, err
+		}
+		woken = append(woken, snooze)
+	}
+	return woken, rows.Err()
+}
+
+// ArchiveForAll archives a signal for everyone who can see it, including
+// members who already read it. It is used when the item is deleted at the
+// source.
+func (r *SignalRepository) ArchiveForAll(signalID int) error {
+	if _, err := r.db.Exec("UPDATE signals SET status = ? WHERE id = ?", models.SignalStatusArchived, signalID); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(
+		"UPDATE signal_status SET status = ?, updated_at = ? WHERE signal_id = ?",
+		models.SignalStatusArchived, time.Now(), signalID,
+	)
+	return err
+}
+
+// FindBySource returns a workspace's signal for a source item with its
+// default status, before any member's own status.
+func (r *SignalRepository) FindBySource(workspaceID int, sourceType, sourceID string) (*models.Signal, error) {
+	return r.findDefault(`s.workspace_id = ? AND s.source_type = ? AND s.source_id = ?`, workspaceID, sourceType, sourceID)
+}
+
+// SourceSignal is an item from an integration to be stored as a signal. A zero
+// WorkspaceID stores it as the user's personal signal.
+type SourceSignal struct {
+	UserID      int
+	WorkspaceID int
+	SourceType  string
+	SourceID    string
+	ExternalID  string
+	Title       string
+	Content     string
+	Body        string
+	URL         string
+	Author      string
+	Status      string
+	Metadata    string
+	ReceivedAt  time.Time
+	// UpdatedAt is when the item last changed at the source. It is written
+	// when an existing signal is refreshed; zero means now.
+	UpdatedAt time.Time
+}
+
+// SourceColumn is a signal column an integration refreshes when it stores an
+// item it already holds.
+type SourceColumn string
+
+const (
+	SourceExternalID SourceColumn = "external_id"
+	SourceTitle      SourceColumn = "title"
+	SourceContent    SourceColumn = "content"
+	SourceBody       SourceColumn = "body"
+	SourceURL        SourceColumn = "url"
+	SourceAuthor     SourceColumn = "author"
+	// SourceStatus refreshes the status unless the signal was archived.
+	SourceStatus     SourceColumn = "status"
+	SourceMetadata   SourceColumn = "source_metadata"
+	SourceReceivedAt SourceColumn = "received_at"
+)
+
+// UpsertSource stores an integration item, keyed on its workspace (or user,
+// for personal signals), source type and source ID. An item that is already
+// stored only has the refresh columns and updated_at rewritten. It reports
+// whether the signal was created.
+func (r *SignalRepository) UpsertSource(signal SourceSignal, refresh ...SourceColumn) (bool, error) {
+	var workspaceID interface{}
+	scope, scopeArgs := "workspace_id IS NULL AND user_id = ?", []interface{}{signal.UserID}
+	conflict := "(user_id, source_type, source_id) WHERE workspace_id IS NULL"
+	if signal.WorkspaceID != 0 {
+		workspaceID = signal.WorkspaceID
+		scope, scopeArgs = "workspace_id = ?", []interface{}{signal.WorkspaceID}
+		conflict = "(workspace_id, source_type, source_id) WHERE workspace_id IS NOT NULL"
+	}
+
+	sets := make([]string, 0, len(refresh)+1)
+	for _, column := range refresh {
+		if column == SourceStatus {
+			sets = append(sets, "status = CASE WHEN signals.status = 'archived' THEN signals.status ELSE excluded.status END")
+			continue
+		}
+		sets = append(sets, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+	args := []interface{}{
+		signal.UserID, workspaceID, signal.SourceType, signal.SourceID, signal.ExternalID,
+		signal.Title, signal.Content, signal.Body, signal.URL, signal.Author, signal.Status,
+		signal.Metadata, signal.ReceivedAt,
+	}
+	if signal.UpdatedAt.IsZero() {
+		sets = append(sets, "updated_at = CURRENT_TIMESTAMP")
+	} else {
+		sets = append(sets, "updated_at = ?")
+		args = append(args, signal.UpdatedAt)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRow(
+		`SELECT 1 FROM signals WHERE `+scope+` AND source_type = ? AND source_id = ?`,
+		append(scopeArgs, signal.SourceType, signal.SourceID)...,
+	).Scan(&existing)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	created := err == sql.ErrNoRows
+
+	if _, err := tx.Exec(
+		`INSERT INTO signals
+		(user_id, workspace_id, source_type, source_id, external_id, title, content, body, url, author, status, source_metadata, received_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT`+conflict+` DO UPDATE SET
+		`+strings.Join(sets, ",\n\t\t"),
+		args...,
+	); err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
+
+// StoredSource is the source ID and metadata a signal was stored with.
+type StoredSource struct {
+	SignalID int
+	SourceID string
+	Metadata string
+}
+
+// Sources returns the source IDs and metadata of a workspace's signals from
+// one integration, for lookups on metadata fields that SQL cannot match
+// portably.
+func (r *SignalRepository) Sources(workspaceID int, sourceType string) ([]StoredSource, error) {
+	rows, err := r.db.Query(
+		`SELECT id, source_id, COALESCE(source_metadata, '') FROM signals
+		 WHERE workspace_id = ? AND source_type = ?
+		 ORDER BY id`,
+		workspaceID, sourceType,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make([]StoredSource, 0)
+	for rows.Next() {
+		var source StoredSource
+		if err := rows.Scan(&source.SignalID, &source.SourceID, &source.Metadata); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
+
+// EditSource loads a workspace's signal for a source item, lets edit rewrite
+// its body and metadata, and saves the result with title as its new title.
+// It returns the signal's ID, or ErrNotFound if the item was never stored.
+func (r *SignalRepository) EditSource(
+	workspaceID int,
+	sourceType, sourceID string,
+	edit func(body, metadata string) (title, newBody, newMetadata string, err error),
+) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var signalID int
+	var body, metadata string
+	if err := tx.QueryRow(
+		`SELECT id, COALESCE(body, ''), COALESCE(source_metadata, '') FROM signals
+		 WHERE workspace_id = ? AND source_type = ? AND source_id = ?`,
+		workspaceID, sourceType, sourceID,
+	).Scan(&signalID, &body, &metadata); err != nil {
+		return 0, err
+	}
+
+	title, body, metadata, err := edit(body, metadata)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(
+		`UPDATE signals SET title = ?, content = ?, body = ?, source_metadata = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		title, body, body, metadata, signalID,
+	); err != nil {
+		return 0, err
+	}
+	return signalID, tx.Commit()
+}
+
+// ReplaceInMetadata swaps from for to in the metadata of a workspace's signals
+// from one integration whose metadata matches the LIKE pattern, and returns
+// the signals that matched.
+func (r *SignalRepository) ReplaceInMetadata(workspaceID int, sourceType, pattern, from, to string) ([]int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT id FROM signals WHERE workspace_id = ? AND source_type = ? AND source_metadata LIKE ?`,
+		workspaceID, sourceType, pattern,
+	)
+	if err != nil {
+		return nil, err
+	}
+	signalIDs := make([]int, 0)
+	for rows.Next() {
+		var signalID int
+		if err := rows.Scan(&signalID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		signalIDs = append(signalIDs, signalID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE signals
+		 SET source_metadata = replace(source_metadata, ?, ?), updated_at = CURRENT_TIMESTAMP
+		 WHERE workspace_id = ? AND source_type = ? AND source_metadata LIKE ?`,
+		from, to, workspaceID, sourceType, pattern,
+	); err != nil {
+		return nil, err
+	}
+	return signalIDs, tx.Commit()
+}
+
+// Touch records that a signal's item changed at the source without rewriting
+// the stored item.
+func (r *SignalRepository) Touch(signalID int, at time.Time) error {
+	return r.execAffecting(`UPDATE signals SET updated_at = ? WHERE id = ?`, at, signalID)
+}
+
+// Find returns a signal with its default status, before any member's own
+// status.
+func (r *SignalRepository) Find(signalID int) (*models.Signal, error) {
+	return r.findDefault(`s.id = ?`, signalID)
+}
+
+func (r *SignalRepository) findDefault(where string, args ...interface{}) (*models.Signal, error) {
+	// signal_status.user_id is never NULL, so the join only supplies the
+	// columns signalColumns expects.
+	return scanSignal(r.db.QueryRow(
+		`SELECT `+signalColumns+`
+		FROM signals s
+		LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id IS NULL
+		WHERE `+where,
+		args...,
+	))
+}
+
+// WorkspaceIDs maps each of signalIDs that belongs to a workspace to that
+// workspace. Personal signals are left out.
+func (r *SignalRepository) WorkspaceIDs(signalIDs []int) (map[int]int, error) {
+	workspaces := make(map[int]int, len(signalIDs))
+	if len(signalIDs) == 0 {
+		return workspaces, nil
+	}
+
+	args := make([]interface{}, len(signalIDs))
+	for i, signalID := range signalIDs {
+		args[i] = signalID
+	}
+	rows, err := r.db.Query(
+		`SELECT id, workspace_id FROM signals
+		 WHERE workspace_id IS NOT NULL AND id IN (`+placeholders(len(args))+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var signalID, workspaceID int
+		if err := rows.Scan(&signalID, &workspaceID); err != nil {
+			return nil, err
+		}
+		workspaces[signalID] = workspaceID
+	}
+	return workspaces, rows.Err()
+}
+
+// AddLabels adds labels a signal does not have yet, ignoring case.
+func (r *SignalRepository) AddLabels(signalID int, labels []string) error {
+	var stored sql.NullString
+	if err := r.db.QueryRow("SELECT labels FROM signals WHERE id = ?", signalID).Scan(&stored); err != nil {
+		return err
+	}
+	merged := decodeLabels(stored.String)
+	for _, label := range labels {
+		if !containsFold(merged, label) {
+			merged = append(merged, label)
+		}
+	}
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	return r.execAffecting("UPDATE signals SET labels = ? WHERE id = ?", string(encoded), signalID)
+}
+
+func (r *SignalRepository) SetPriority(signalID int, priority string) error {
+	return r.execAffecting("UPDATE signals SET priority = ? WHERE id = ?", priority, signalID)
+}
+
+// SetAssignee assigns a signal without checking who asked, for rules. It
+// returns ErrInvalidAssignee unless the assignee belongs to the signal's
+// workspace.
+func (r *SignalRepository) SetAssignee(signalID, assigneeID int) error {
+	err := r.execAffecting(
+		`UPDATE signals SET assignee_id = ?
+		 WHERE id = ?
+		   AND (workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)
+		        OR workspace_id IN (SELECT id FROM workspaces WHERE owner_id = ?))`,
+		assigneeID, signalID, assigneeID, assigneeID,
+	)
+	if err == ErrNotFound {
+		return ErrInvalidAssignee
+	}
+	return err
+}
+
+// ArchiveByDefault archives a signal for members who have not set their own
+// status on it.
+func (r *SignalRepository) ArchiveByDefault(signalID int) error {
+	return r.execAffecting("UPDATE signals SET status = ? WHERE id = ?", models.SignalStatusArchived, signalID)
+}
+
+// LinkedDecisions lists the decisions citing a signal, limited to workspaces
+// userID belongs to and meets the MFA policy of.
+func (r *SignalRepository) LinkedDecisions(userID, signalID int) ([]models.DecisionReference, error) {
+	rows, err := r.db.Query(
+		`SELECT d.id, d.workspace_id, d.title, d.status, ds.created_at
+		 FROM decision_signals ds
+		 JOIN decisions d ON d.id = ds.decision_id
+		 WHERE ds.signal_id = ?
+		   AND (d.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)
+		        OR d.workspace_id IN (SELECT id FROM workspaces WHERE owner_id = ?))
+		   AND d.workspace_id NOT IN (`+mfaLockedWorkspaces+`)
+		 ORDER BY ds.created_at DESC, d.id DESC`,
+		signalID, userID, userID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := make([]models.DecisionReference, 0)
+	for rows.Next() {
+		var decision models.DecisionReference
+		if err := rows.Scan(&decision.ID, &decision.WorkspaceID, &decision.Title, &decision.Status, &decision.LinkedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, rows.Err()
+}
+
+func scanSignal(scanner rowScanner) (*models.Signal, error) {
+	var signal models.Signal
+	var workspaceID sql.NullInt64
+	var content sql.NullString
+	var author sql.NullString
+	var sourceID sql.NullString
+	var externalID sql.NullString
+	var body sql.NullString
+	var url sql.NullString
+	var metadata sql.NullString
+	var updatedAt sql.NullTime
+	var snoozedUntil sql.NullTime
+	var assigneeID sql.NullInt64
+	var labels sql.NullString
+	var priority sql.NullString
+
+	if err := scanner.Scan(
+		&signal.ID, &signal.UserID, &workspaceID, &signal.SourceType, &sourceID, &externalID,
+		&signal.Title, &content, &author, &body, &url, &signal.Status, &metadata,
+		&signal.ReceivedAt, &signal.CreatedAt, &updatedAt, &snoozedUntil, &assigneeID,
+		&labels, &priority,
+	); err != nil {
+		return nil, err
+	}
+	signal.WorkspaceID = int(workspaceID.Int64)
+	signal.Content = content.String
+	signal.Author = author.String
+	signal.SourceID = sourceID.String
+	signal.ExternalID = externalID.String
+	signal.Body = body.String
+	signal.URL = url.String
+	signal.UpdatedAt = updatedAt.Time
+	if snoozedUntil.Valid {
+		signal.SnoozedUntil = &snoozedUntil.Time
+	}
+	if assigneeID.Valid {
+		id := int(assigneeID.Int64)
+		signal.AssigneeID = &id
+	}
+	signal.Labels = decodeLabels(labels.String)
+	signal.Priority = priority.String
+	// Unreadable metadata is dropped rather than hiding the signal.
+	signal.SourceMetadata, _ = models.DecodeSourceMetadata(signal.SourceType, []byte(metadata.String))
+	return &signal, nil
+}
+
+func decodeLabels(stored string) []string {
+	var labels []string
+	if stored != "" {
+		_ = json.Unmarshal([]byte(stored), &labels)
+	}
+	return labels
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/models"
+	"time"
+)
+
+type SyncJobRepository struct {
+	*queries
+}
+
+const syncJobColumns = `id, integration_id, user_id, COALESCE(workspace_id, 0), provider, status, attempts, max_attempts,
+		COALESCE(last_error, ''), run_at, started_at, lease_expires_at, finished_at, created_at, updated_at`
+
+// Enqueue queues a sync of the integration to run at now. If the integration
+// already has a pending or running job, that job is returned instead and
+// created is false.
+func (r *SyncJobRepository) Enqueue(integration models.ExternalIntegration, maxAttempts int, now time.Time) (job *models.SyncJob, created bool, err error) {
+	if job, err := r.activeFor(integration.ID); err != ErrNotFound {
+		return job, false, err
+	}
+
+	var workspaceID interface{}
+	if integration.WorkspaceID != 0 {
+		workspaceID = integration.WorkspaceID
+	}
+	jobID, err := r.insertID(r.db,
+		`INSERT INTO sync_jobs (integration_id, user_id, workspace_id, provider, status, max_attempts, run_at, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		integration.ID, integration.UserID, workspaceID, integration.Provider, models.SyncJobPending, maxAttempts, now, now, now,
+	)
+	if err != nil {
+		// A concurrent Enqueue may have won the unique index on active jobs.
+		if job, findErr := r.activeFor(integration.ID); findErr == nil {
+			return job, false, nil
+		}
+		return nil, false, err
+	}
+
+	job, err = r.Get(jobID)
+	return job, err == nil, err
+}
+
+func (r *SyncJobRepository) Get(jobID int) (*models.SyncJob, error) {
+	return scanSyncJob(r.db.QueryRow(`SELECT `+syncJobColumns+` FROM sync_jobs WHERE id = ?`, jobID))
+}
+
+func (r *SyncJobRepository) activeFor(integrationID int) (*models.SyncJob, error) {
+	return scanSyncJob(r.db.QueryRow(
+		`SELECT `+syncJobColumns+` FROM sync_jobs WHERE integration_id = ? AND status IN (?, ?)`,
+		integrationID, models.SyncJobPending, models.SyncJobRunning,
+	))
+}
+
+// ClaimNext marks the oldest pending job that is due as running, leased until
+// leaseUntil, and counts the attempt. It returns ErrNotFound when no job is
+// due.
+func (r *SyncJobRepository) ClaimNext(now, leaseUntil time.Time) (*models.SyncJob, error) {
+	for {
+		var jobID int
+		if err := r.db.QueryRow(
+			`SELECT id FROM sync_jobs WHERE status = ? AND run_at <= ? ORDER BY run_at, id LIMIT 1`,
+			models.SyncJobPending, now,
+		).Scan(&jobID); err != nil {
+			return nil, err
+		}
+
+		err := r.execAffecting(
+			`UPDATE sync_jobs SET status = ?, attempts = attempts + 1, started_at = ?, lease_expires_at = ?, updated_at = ?
+			 WHERE id = ? AND status = ?`,
+			models.SyncJobRunning, now, leaseUntil, now, jobID, models.SyncJobPending,
+		)
+		if err == ErrNotFound {
+			// Another worker claimed it first.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return r.Get(jobID)
+	}
+}
+
+// Heartbeat extends the lease of attempt of a running job to leaseUntil. It
+// returns ErrNotFound once the attempt has lost its lease to RequeueExpired.
+func (r *SyncJobRepository) Heartbeat(jobID, attempt int, leaseUntil, now time.Time) error {
+	return r.execAffecting(
+		`UPDATE sync_jobs SET lease_expires_at = ?, updated_at = ? WHERE id = ? AND status = ? AND attempts = ?`,
+		leaseUntil, now, jobID, models.SyncJobRunning, attempt,
+	)
+}
+
+// Complete records a successful attempt. Like Retry and Bury, it returns
+// ErrNotFound if the attempt lost its lease in the meantime.
+func (r *SyncJobRepository) Complete(jobID, attempt int, now time.Time) error {
+	return r.execAffecting(
+		`UPDATE sync_jobs SET status = ?, last_error = NULL, lease_expires_at = NULL, finished_at = ?, updated_at = ?
+		 WHERE id = ? AND status = ? AND attempts = ?`,
+		models.SyncJobSucceeded, now, now, jobID, models.SyncJobRunning, attempt,
+	)
+}
+
+// Retry records a failed attempt and schedules the job to run again at runAt.
+func (r *SyncJobRepository) Retry(jobID, attempt int, message string, runAt, now time.Time) error {
+	return r.execAffecting(
+		`UPDATE sync_jobs SET status = ?, last_error = ?, run_at = ?, lease_expires_at = NULL, updated_at = ?
+		 WHERE id = ? AND status = ? AND attempts = ?`,
+		models.SyncJobPending, message, runAt, now, jobID, models.SyncJobRunning, attempt,
+	)
+}
+
+// Bury records the final failed attempt and dead-letters the job.
+func (r *SyncJobRepository) Bury(jobID, attempt int, message string, now time.Time) error {
+	return r.execAffecting(
+		`UPDATE sync_jobs SET status = ?, last_error = ?, lease_expires_at = NULL, finished_at = ?, updated_at = ?
+		 WHERE id = ? AND status = ? AND attempts = ?`,
+		models.SyncJobDead, message, now, now, jobID, models.SyncJobRunning, attempt,
+	)
+}
+
+// RequeueExpired handles running jobs whose lease ran out before now, because
+// their worker died or stopped renewing it. The lost attempt counts: jobs with
+// attempts left go back to pending, the others are dead-lettered. It returns
+// how many jobs were requeued and how many were buried.
+func (r *SyncJobRepository) RequeueExpired(message string, now time.Time) (requeued, buried int, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE sync_jobs SET status = ?, last_error = ?, lease_expires_at = NULL, finished_at = ?, updated_at = ?
+		 WHERE status = ? AND lease_expires_at < ? AND attempts >= max_attempts`,
+		models.SyncJobDead, message, now, now, models.SyncJobRunning, now,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	buried = int(count)
+
+	result, err = tx.Exec(
+		`UPDATE sync_jobs SET status = ?, last_error = ?, run_at = ?, lease_expires_at = NULL, updated_at = ?
+		 WHERE status = ? AND lease_expires_at < ?`,
+		models.SyncJobPending, message, now, now, models.SyncJobRunning, now,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	if count, err = result.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+	requeued = int(count)
+	return requeued, buried, tx.Commit()
+}
+
+// Latest returns the integration's newest job.
+func (r *SyncJobRepository) Latest(integrationID int) (*models.SyncJob, error) {
+	return scanSyncJob(r.db.QueryRow(
+		`SELECT `+syncJobColumns+` FROM sync_jobs WHERE integration_id = ? ORDER BY id DESC LIMIT 1`,
+		integrationID,
+	))
+}
+
+// DeadLettered returns the integrations whose most recent job was
+// dead-lettered after buriedAfter. The scheduler leaves them alone until the
+// job is older than that; a manual sync or reconnecting queues one sooner.
+func (r *SyncJobRepository) DeadLettered(buriedAfter time.Time) (map[int]bool, error) {
+	rows, err := r.db.Query(
+		`SELECT j.integration_id FROM sync_jobs j
+		 WHERE j.status = ? AND j.finished_at > ?
+		   AND j.id = (SELECT MAX(id) FROM sync_jobs WHERE integration_id = j.integration_id)`,
+		models.SyncJobDead, buriedAfter,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dead := make(map[int]bool)
+	for rows.Next() {
+		var integrationID int
+		if err := rows.Scan(&integrationID); err != nil {
+			return nil, err
+		}
+		dead[integrationID] = true
+	}
+	return dead, rows.Err()
+}
+
+func scanSyncJob(scanner rowScanner) (*models.SyncJob, error) {
+	var job models.SyncJob
+	var startedAt, leaseExpiresAt, finishedAt sql.NullTime
+	if err := scanner.Scan(
+		&job.ID,
+		&job.IntegrationID,
+		&job.UserID,
+		&job.WorkspaceID,
+		&job.Provider,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.LastError,
+		&job.RunAt,
+		&startedAt,
+		&leaseExpiresAt,
+		&finishedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if leaseExpiresAt.Valid {
+		job.LeaseExpiresAt = &leaseExpiresAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/models"
+	"time"
+)
+
+type SyncRunRepository struct {
+	*queries
+}
+
+const syncRunColumns = `id, integration_id, COALESCE(job_id, 0), user_id, COALESCE(workspace_id, 0), provider, status,
+		items_fetched, items_upserted, items_failed, COALESCE(error, ''), started_at, finished_at`
+
+// Start records a run of the job as running and returns its id.
+func (r *SyncRunRepository) Start(job models.SyncJob, now time.Time) (int, error) {
+	var jobID, workspaceID interface{}
+	if job.ID != 0 {
+		jobID = job.ID
+	}
+	if job.WorkspaceID != 0 {
+		workspaceID = job.WorkspaceID
+	}
+	return r.insertID(r.db,
+		`INSERT INTO sync_runs (integration_id, job_id, user_id, workspace_id, provider, status, started_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		job.IntegrationID, jobID, job.UserID, workspaceID, job.Provider, models.SyncRunRunning, now,
+	)
+}
+
+// Finish records the outcome of a run. An empty message marks it succeeded,
+// even if some items failed.
+func (r *SyncRunRepository) Finish(runID, itemsFetched, itemsUpserted, itemsFailed int, message string, now time.Time) error {
+	status := models.SyncRunSucceeded
+	var runError interface{}
+	if message != "" {
+		status = models.SyncRunFailed
+		runError = message
+	}
+	return r.execAffecting(
+		`UPDATE sync_runs SET status = ?, items_fetched = ?, items_upserted = ?, items_failed = ?, error = ?, finished_at = ?
+		 WHERE id = ?`,
+		status, itemsFetched, itemsUpserted, itemsFailed, runError, now, runID,
+	)
+}
+
+// Latest returns the integration's newest finished run with the given status,
+// or with any finished status when status is empty.
+func (r *SyncRunRepository) Latest(integrationID int, status string) (*models.SyncRun, error) {
+	query := `SELECT ` + syncRunColumns + ` FROM sync_runs WHERE integration_id = ? AND status <> ?`
+	args := []interface{}{integrationID, models.SyncRunRunning}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	return scanSyncRun(r.db.QueryRow(query+` ORDER BY id DESC LIMIT 1`, args...))
+}
+
+// ConsecutiveFailures counts the failed runs since the integration's last
+// successful one.
+func (r *SyncRunRepository) ConsecutiveFailures(integrationID int) (int, error) {
+	var count int
+	err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM sync_runs
+		 WHERE integration_id = ? AND status = ?
+		   AND id > COALESCE((SELECT MAX(id) FROM sync_runs WHERE integration_id = ? AND status = ?), 0)`,
+		integrationID, models.SyncRunFailed, integrationID, models.SyncRunSucceeded,
+	).Scan(&count)
+	return count, err
+}
+
+func scanSyncRun(scanner rowScanner) (*models.SyncRun, error) {
+	var run models.SyncRun
+	var finishedAt sql.NullTime
+	if err := scanner.Scan(
+		&run.ID,
+		&run.IntegrationID,
+		&run.JobID,
+		&run.UserID,
+		&run.WorkspaceID,
+		&run.Provider,
+		&run.Status,
+		&run.ItemsFetched,
+		&run.ItemsUpserted,
+		&run.ItemsFailed,
+		&run.Error,
+		&run.StartedAt,
+		&finishedAt,
+	); err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"sentinent-backend/models"
+	"time"
+)
+
+type UserRepository struct {
+	*queries
+}
+
+// Create stores a new user with an already hashed password and returns its id.
+func (r *UserRepository) Create(user models.User, passwordHash string) (int, error) {
+	return r.insertID(r.db,
+		`INSERT INTO users (email, password, full_name, job_title, organization, timezone, bio, role_label)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.Email,
+		passwordHash,
+		user.FullName,
+		user.JobTitle,
+		user.Organization,
+		user.Timezone,
+		user.Bio,
+		user.RoleLabel,
+	)
+}
+
+// GetCredentials returns the id, email and password hash for a login.
+func (r *UserRepository) GetCredentials(email string) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRow("SELECT id, email, password FROM users WHERE email = ?", email).
+		Scan(&user.ID, &user.Email, &user.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetProfile returns a user without the password hash.
+func (r *UserRepository) GetProfile(userID int) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRow(
+		`SELECT id, email, full_name, job_title, organization, timezone, bio, role_label
+		 FROM users WHERE id = ?`,
+		userID,
+	).Scan(
+		&user.ID,
+		&user.Email,
+		&user.FullName,
+		&user.JobTitle,
+		&user.Organization,
+		&user.Timezone,
+		&user.Bio,
+		&user.RoleLabel,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) UpdateProfile(userID int, profile models.User) error {
+	_, err := r.db.Exec(
+		`UPDATE users
+		 SET full_name = ?, job_title = ?, organization = ?, timezone = ?, bio = ?, role_label = ?
+		 WHERE id = ?`,
+		profile.FullName,
+		profile.JobTitle,
+		profile.Organization,
+		profile.Timezone,
+		profile.Bio,
+		profile.RoleLabel,
+		userID,
+	)
+	return err
+}
+
+func (r *UserRepository) IDByEmail(email string) (int, error) {
+	var userID int
+	err := r.db.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&userID)
+	return userID, err
+}
+
+func (r *UserRepository) Email(userID int) (string, error) {
+	var email string
+	err := r.db.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email)
+	return email, err
+}
+
+func (r *UserRepository) Exists(userID int) (bool, error) {
+	var id int
+	err := r.db.QueryRow("SELECT id FROM users WHERE id = ?", userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PasswordChangedAt returns when the user last changed their password, or nil
+// if they never have.
+func (r *UserRepository) PasswordChangedAt(userID int) (*time.Time, error) {
+	var changedAt sql.NullTime
+	if err := r.db.QueryRow("SELECT password_changed_at FROM users WHERE id = ?", userID).Scan(&changedAt); err != nil {
+		return nil, err
+	}
+	if !changedAt.Valid {
+		return nil, nil
+	}
+	return &changedAt.Time, nil
+}
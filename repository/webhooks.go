@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sentinent-backend/models"
+	"time"
+)
+
+type WebhookRepository struct {
+	*queries
+}
+
+const webhookColumns = `id, workspace_id, url, events, enabled, created_by, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+		last_attempt_at, response_status, COALESCE(last_error, ''), redelivery_of, created_at, delivered_at`
+
+// WebhookTarget is where and how a delivery is sent. Secret is encrypted
+// exactly as stored.
+type WebhookTarget struct {
+	URL     string
+	Secret  string
+	Enabled bool
+}
+
+// List returns the workspace's webhooks, oldest first. Secrets are left out.
+func (r *WebhookRepository) List(workspaceID int) ([]models.Webhook, error) {
+	return r.list(`SELECT `+webhookColumns+` FROM outgoing_webhooks WHERE workspace_id = ? ORDER BY id`, workspaceID)
+}
+
+// ListSubscribed returns the workspace's enabled webhooks that subscribe to
+// eventType.
+func (r *WebhookRepository) ListSubscribed(workspaceID int, eventType string) ([]models.Webhook, error) {
+	webhooks, err := r.list(
+		`SELECT `+webhookColumns+` FROM outgoing_webhooks WHERE workspace_id = ? AND enabled = ? ORDER BY id`,
+		workspaceID, true,
+	)
+	if err != nil {
+		return nil, err
+	}
+	subscribed := webhooks[:0]
+	for _, webhook := range webhooks {
+		for _, event := range webhook.Events {
+			if event == eventType {
+				subscribed = append(subscribed, webhook)
+				break
+			}
+		}
+	}
+	return subscribed, nil
+}
+
+func (r *WebhookRepository) list(query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]models.Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *WebhookRepository) Get(workspaceID, webhookID int) (*models.Webhook, error) {
+	return scanWebhook(r.db.QueryRow(
+		`SELECT `+webhookColumns+` FROM outgoing_webhooks WHERE workspace_id = ? AND id = ?`,
+		workspaceID, webhookID,
+	))
+}
+
+// Create stores a webhook with its already encrypted signing secret.
+func (r *WebhookRepository) Create(workspaceID, userID int, req models.WebhookRequest, encryptedSecret string) (int, error) {
+	events, err := json.Marshal(req.Events)
+	if err != nil {
+		return 0, err
+	}
+	return r.insertID(r.db,
+		`INSERT INTO outgoing_webhooks (workspace_id, url, secret, events, enabled, created_by, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		workspaceID, req.URL, encryptedSecret, string(events), req.Enabled == nil || *req.Enabled, userID,
+	)
+}
+
+// Update saves a webhook's URL, events and enabled flag. The secret never
+// changes.
+func (r *WebhookRepository) Update(webhook models.Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	return r.execAffecting(
+		`UPDATE outgoing_webhooks SET url = ?, events = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE workspace_id = ? AND id = ?`,
+		webhook.URL, string(events), webhook.Enabled, webhook.WorkspaceID, webhook.ID,
+	)
+}
+
+// Delete removes a webhook together with its delivery log.
+func (r *WebhookRepository) Delete(workspaceID, webhookID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM outgoing_webhooks WHERE workspace_id = ? AND id = ?)`,
+		workspaceID, webhookID,
+	); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM outgoing_webhooks WHERE workspace_id = ? AND id = ?`, workspaceID, webhookID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// Target returns where a webhook's deliveries go.
+func (r *WebhookRepository) Target(webhookID int) (*WebhookTarget, error) {
+	var target WebhookTarget
+	if err := r.db.QueryRow(
+		`SELECT url, secret, enabled FROM outgoing_webhooks WHERE id = ?`,
+		webhookID,
+	).Scan(&target.URL, &target.Secret, &target.Enabled); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// Enqueue queues a delivery of payload to the webhook, due at now.
+// redeliveryOf names the delivery it repeats, if any.
+func (r *WebhookRepository) Enqueue(webhookID int, eventID, eventType string, payload []byte, redeliveryOf *int, now time.Time) (int, error) {
+	return r.insertID(r.db,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at, redelivery_of, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		webhookID, eventID, eventType, string(payload), models.WebhookDeliveryPending, now, redeliveryOf, now,
+	)
+}
+
+// ListDeliveries returns the webhook's most recent deliveries, newest first.
+func (r *WebhookRepository) ListDeliveries(webhookID, limit int) ([]models.WebhookDelivery, error) {
+	return r.listDeliveries(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`,
+		webhookID, limit,
+	)
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt is
+// due, oldest first.
+func (r *WebhookRepository) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return r.listDeliveries(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		 WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`,
+		models.WebhookDeliveryPending, now, limit,
+	)
+}
+
+func (r *WebhookRepository) listDeliveries(query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *WebhookRepository) GetDelivery(webhookID, deliveryID int) (*models.WebhookDelivery, error) {
+	return scanWebhookDelivery(r.db.QueryRow(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? AND id = ?`,
+		webhookID, deliveryID,
+	))
+}
+
+// ClaimDelivery counts an attempt at a due delivery and holds it until
+// leaseUntil, so no other worker sends it meanwhile. If the sender dies, the
+// delivery is retried once the lease runs out. It returns ErrNotFound when the
+// delivery is no longer due.
+func (r *WebhookRepository) ClaimDelivery(deliveryID int, now, leaseUntil time.Time) error {
+	return r.execAffecting(
+		`UPDATE webhook_deliveries SET attempts = attempts + 1, last_attempt_at = ?, next_attempt_at = ?
+		 WHERE id = ? AND status = ? AND next_attempt_at <= ?`,
+		now, leaseUntil, deliveryID, models.WebhookDeliveryPending, now,
+	)
+}
+
+func (r *WebhookRepository) MarkDelivered(deliveryID, responseStatus int, now time.Time) error {
+	return r.execAffecting(
+		`UPDATE webhook_deliveries
+		 SET status = ?, response_status = ?, last_error = NULL, next_attempt_at = NULL, delivered_at = ?
+		 WHERE id = ?`,
+		models.WebhookDeliveryDelivered, responseStatus, now, deliveryID,
+	)
+}
+
+// MarkAttemptFailed records a failed attempt. The delivery is retried at
+// nextAttemptAt, or marked failed when that is nil.
+func (r *WebhookRepository) MarkAttemptFailed(deliveryID int, responseStatus *int, message string, nextAttemptAt *time.Time) error {
+	status := models.WebhookDeliveryPending
+	if nextAttemptAt == nil {
+		status = models.WebhookDeliveryFailed
+	}
+	return r.execAffecting(
+		`UPDATE webhook_deliveries SET status = ?, response_status = ?, last_error = ?, next_attempt_at = ? WHERE id = ?`,
+		status, responseStatus, message, nextAttemptAt, deliveryID,
+	)
+}
+
+func scanWebhook(scanner rowScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events string
+	if err := scanner.Scan(
+		&webhook.ID, &webhook.WorkspaceID, &webhook.URL, &events, &webhook.Enabled,
+		&webhook.CreatedBy, &webhook.CreatedAt, &webhook.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(events), &webhook.Events); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func scanWebhookDelivery(scanner rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var payload string
+	var nextAttemptAt, lastAttemptAt, deliveredAt sql.NullTime
+	var responseStatus, redeliveryOf sql.NullInt64
+	if err := scanner.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&nextAttemptAt,
+		&lastAttemptAt,
+		&responseStatus,
+		&delivery.LastError,
+		&redeliveryOf,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	); err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	if nextAttemptAt.Valid {
+		delivery.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastAttemptAt.Valid {
+		delivery.LastAttemptAt = &lastAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		delivery.DeliveredAt = &deliveredAt.Time
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if redeliveryOf.Valid {
+		original := int(redeliveryOf.Int64)
+		delivery.RedeliveryOf = &original
+	}
+	return &delivery, nil
+}
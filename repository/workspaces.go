@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"sentinent-backend/models"
+)
+
+type WorkspaceRepository struct {
+	*queries
+}
+
+const workspaceColumns = `id, name, COALESCE(description, ''), owner_id, require_mfa, created_at, updated_at`
+
+// ListForUser returns every workspace the user is a member of, most recently updated first.
+func (r *WorkspaceRepository) ListForUser(userID int) ([]models.Workspace, error) {
+	rows, err := r.db.Query(
+		`SELECT DISTINCT w.id, w.name, COALESCE(w.description, ''), w.owner_id, w.require_mfa, w.created_at, w.updated_at
+		 FROM workspaces w
+		 JOIN workspace_members wm ON wm.workspace_id = w.id
+		 WHERE wm.user_id = ?
+		 ORDER BY w.updated_at DESC, w.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := make([]models.Workspace, 0)
+	for rows.Next() {
+		workspace, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, *workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+// Create inserts a workspace and its owner membership in one transaction.
+func (r *WorkspaceRepository) Create(ownerID int, name, description string) (*models.Workspace, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	workspaceID, err := r.insertID(tx,
+		`INSERT INTO workspaces (name, description, owner_id, updated_at)
+		 VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
+		name, description, ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO workspace_members (workspace_id, user_id, role)
+		 VALUES (?, ?, ?)`,
+		workspaceID, ownerID, models.RoleOwner,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.Get(workspaceID)
+}
+
+func (r *WorkspaceRepository) Get(workspaceID int) (*models.Workspace, error) {
+	return scanWorkspace(r.db.QueryRow(
+		`SELECT `+workspaceColumns+`
+		 FROM workspaces
+		 WHERE id = ?`,
+		workspaceID,
+	))
+}
+
+func (r *WorkspaceRepository) Update(workspaceID int, name, description string) error {
+	return r.execAffecting(
+		`UPDATE workspaces
+		 SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		name, description, workspaceID,
+	)
+}
+
+// Delete removes a workspace together with everything scoped to it.
+func (r *WorkspaceRepository) Delete(workspaceID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM decision_votes WHERE decision_id IN (SELECT id FROM decisions WHERE workspace_id = ?)`,
+		`DELETE FROM decision_options WHERE decision_id IN (SELECT id FROM decisions WHERE workspace_id = ?)`,
+		`DELETE FROM decision_signals WHERE decision_id IN (SELECT id FROM decisions WHERE workspace_id = ?)`,
+		`DELETE FROM decision_signals WHERE signal_id IN (SELECT id FROM signals WHERE workspace_id = ?)`,
+		`DELETE FROM signal_status WHERE signal_id IN (SELECT id FROM signals WHERE workspace_id = ?)`,
+		`DELETE FROM signal_rule_matches WHERE signal_id IN (SELECT id FROM signals WHERE workspace_id = ?)`,
+		`DELETE FROM signal_rule_matches WHERE rule_id IN (SELECT id FROM signal_rules WHERE workspace_id = ?)`,
+		`DELETE FROM signal_rules WHERE workspace_id = ?`,
+		`DELETE FROM signals WHERE workspace_id = ?`,
+		`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM outgoing_webhooks WHERE workspace_id = ?)`,
+		`DELETE FROM outgoing_webhooks WHERE workspace_id = ?`,
+		`DELETE FROM invitations WHERE workspace_id = ?`,
+		`DELETE FROM workspace_members WHERE workspace_id = ?`,
+		`DELETE FROM external_integrations WHERE workspace_id = ?`,
+		`DELETE FROM decisions WHERE workspace_id = ?`,
+		`DELETE FROM workspaces WHERE id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, workspaceID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetRequireMFA turns the workspace's MFA requirement on or off.
+func (r *WorkspaceRepository) SetRequireMFA(workspaceID int, required bool) error {
+	return r.execAffecting(
+		`UPDATE workspaces
+		 SET require_mfa = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		required, workspaceID,
+	)
+}
+
+func (r *WorkspaceRepository) RequiresMFA(workspaceID int) (bool, error) {
+	var required bool
+	err := r.db.QueryRow("SELECT require_mfa FROM workspaces WHERE id = ?", workspaceID).Scan(&required)
+	return required, err
+}
+
+func (r *WorkspaceRepository) OwnerID(workspaceID int) (int, error) {
+	var ownerID int
+	err := r.db.QueryRow("SELECT owner_id FROM workspaces WHERE id = ?", workspaceID).Scan(&ownerID)
+	return ownerID, err
+}
+
+func (r *WorkspaceRepository) Name(workspaceID int) (string, error) {
+	var name string
+	err := r.db.QueryRow("SELECT name FROM workspaces WHERE id = ?", workspaceID).Scan(&name)
+	return name, err
+}
+
+func scanWorkspace(scanner rowScanner) (*models.Workspace, error) {
+	var workspace models.Workspace
+	if err := scanner.Scan(
+		&workspace.ID,
+		&workspace.Name,
+		&workspace.Description,
+		&workspace.OwnerID,
+		&workspace.RequireMFA,
+		&workspace.CreatedAt,
+		&workspace.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &workspace, nil
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"log"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sync"
+	"time"
+)
+
+const (
+	// signalEventHistory is how many recent events the bus keeps for streams
+	// that reconnect with a Last-Event-ID.
+	signalEventHistory = 1024
+	// signalSubscriberBuffer is how far a stream may fall behind before it is
+	// dropped. The client then reconnects and resumes from the history.
+	signalSubscriberBuffer = 64
+)
+
+// SignalBus fans signal events out to the streams subscribed in this process.
+// Publishing never blocks: a subscriber that cannot keep up is closed.
+type SignalBus struct {
+	mu          sync.Mutex
+	lastID      int64
+	history     []models.SignalEvent
+	subscribers map[*SignalSubscription]struct{}
+}
+
+// SignalSubscription receives the events of one workspace that are meant for
+// one member.
+type SignalSubscription struct {
+	workspaceID int
+	userID      int
+	events      chan models.SignalEvent
+	// LastID is the newest event published before the subscription started.
+	// Later events arrive on Events.
+	LastID int64
+}
+
+var signalBus = NewSignalBus()
+
+// NewSignalBus creates an empty bus.
+func NewSignalBus() *SignalBus {
+	return &SignalBus{subscribers: make(map[*SignalSubscription]struct{})}
+}
+
+// DefaultSignalBus returns the bus every signal write path publishes to.
+func DefaultSignalBus() *SignalBus {
+	return signalBus
+}
+
+// Events delivers the subscription's events. It is closed when the
+// subscription ends, including when it fell too far behind.
+func (s *SignalSubscription) Events() <-chan models.SignalEvent {
+	return s.events
+}
+
+func (s *SignalSubscription) wants(event models.SignalEvent) bool {
+	return event.WorkspaceID == s.workspaceID && (event.UserID == 0 || event.UserID == s.userID)
+}
+
+// Publish numbers the event and sends it to every matching subscriber.
+func (b *SignalBus) Publish(event models.SignalEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+	if len(b.history) == signalEventHistory {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:len(b.history)-1]
+	}
+	b.history = append(b.history, event)
+
+	for sub := range b.subscribers {
+		if !sub.wants(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribe starts receiving a member's events for a workspace.
+func (b *SignalBus) Subscribe(workspaceID, userID int) *SignalSubscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &SignalSubscription{
+		workspaceID: workspaceID,
+		userID:      userID,
+		events:      make(chan models.SignalEvent, signalSubscriberBuffer),
+		LastID:      b.lastID,
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe ends a subscription. It is safe to call more than once.
+func (b *SignalBus) Unsubscribe(sub *SignalSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Replay returns the subscription's events published after afterID and up to
+// the start of the subscription. It reports false when the history no longer
+// reaches back that far, or afterID came from before a restart, in which case
+// the client must reload instead.
+func (b *SignalBus) Replay(sub *SignalSubscription, afterID int64) ([]models.SignalEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if afterID > sub.LastID {
+		return nil, false
+	}
+	if afterID == sub.LastID {
+		return nil, true
+	}
+	if len(b.history) == 0 || b.history[0].ID > afterID+1 {
+		return nil, false
+	}
+
+	var events []models.SignalEvent
+	for _, event := range b.history {
+		if event.ID > afterID && event.ID <= sub.LastID && sub.wants(event) {
+			events = append(events, event)
+		}
+	}
+	return events, true
+}
+
+// SignalUpserted runs after a provider stores a workspace signal: the
+// workspace's rules go first, so the event and any webhook delivery carry
+// what they changed.
+func SignalUpserted(workspaceID int, sourceType, sourceID string, created bool) {
+	logSignalRules(workspaceID, sourceType, sourceID)
+
+	signal, err := repository.Default().Signals.FindBySource(workspaceID, sourceType, sourceID)
+	if err != nil {
+		log.Printf("Failed to load %s signal %s for its event: %v", sourceType, sourceID, err)
+		return
+	}
+	eventType := models.SignalEventUpdated
+	if created {
+		eventType = models.SignalEventCreated
+	}
+	signalBus.Publish(models.SignalEvent{Type: eventType, WorkspaceID: workspaceID, SignalID: signal.ID, Signal: signal})
+	if created {
+		EnqueueWebhookEvent(workspaceID, models.WebhookEventSignalCreated, signal)
+	}
+}
+
+// PublishSignalUpdated announces a change to a stored signal's content.
+func PublishSignalUpdated(workspaceID, signalID int) {
+	signal, err := repository.Default().Signals.Find(signalID)
+	if err != nil {
+		log.Printf("Failed to load signal %d for its event: %v", signalID, err)
+		return
+	}
+	signalBus.Publish(models.SignalEvent{Type: models.SignalEventUpdated, WorkspaceID: workspaceID, SignalID: signalID, Signal: signal})
+}
+
+// PublishSignalArchivedForAll announces a signal archived for every member.
+func PublishSignalArchivedForAll(workspaceID, signalID int) {
+	signalBus.Publish(models.SignalEvent{
+		Type:        models.SignalEventArchived,
+		WorkspaceID: workspaceID,
+		SignalID:    signalID,
+		Status:      models.SignalStatusArchived,
+	})
+}
+
+// PublishSignalTriage announces a triage change the user made. Status
+// changes only reach the user; assignments are shared with the workspace.
+// Signals without a workspace have no stream and are skipped.
+func PublishSignalTriage(userID int, signalIDs []int, triage models.SignalTriage) {
+	store := repository.Default()
+	workspaces, err := store.Signals.WorkspaceIDs(signalIDs)
+	if err != nil {
+		log.Printf("Failed to load signal workspaces for events: %v", err)
+		return
+	}
+
+	for _, signalID := range signalIDs {
+		workspaceID, ok := workspaces[signalID]
+		if !ok {
+			continue
+		}
+		switch {
+		case triage.Action == models.SignalActionAssign:
+			PublishSignalUpdated(workspaceID, signalID)
+		case triage.Action == models.SignalActionSnooze:
+			signalBus.Publish(models.SignalEvent{
+				Type:         models.SignalEventStatusChanged,
+				WorkspaceID:  workspaceID,
+				SignalID:     signalID,
+				UserID:       userID,
+				Status:       models.SignalStatusSnoozed,
+				SnoozedUntil: triage.SnoozedUntil,
+			})
+		default:
+			eventType := models.SignalEventStatusChanged
+			if triage.Status == models.SignalStatusArchived {
+				eventType = models.SignalEventArchived
+			}
+			signalBus.Publish(models.SignalEvent{
+				Type:        eventType,
+				WorkspaceID: workspaceID,
+				SignalID:    signalID,
+				UserID:      userID,
+				Status:      triage.Status,
+			})
+		}
+	}
+}
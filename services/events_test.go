@@ -0,0 +1,112 @@
+package services
+
+import (
+	"sentinent-backend/models"
+	"sentinent-backend/services/internal/servicestest"
+	"testing"
+	"time"
+)
+
+func TestSignalBusFiltersAndReplays(t *testing.T) {
+	bus := NewSignalBus()
+	bus.Publish(models.SignalEvent{Type: models.SignalEventCreated, WorkspaceID: 1, SignalID: 1})
+
+	sub := bus.Subscribe(1, 7)
+	defer bus.Unsubscribe(sub)
+	if sub.LastID != 1 {
+		t.Fatalf("expected the subscription to start after event 1, got %d", sub.LastID)
+	}
+
+	bus.Publish(models.SignalEvent{Type: models.SignalEventCreated, WorkspaceID: 2, SignalID: 2})
+	bus.Publish(models.SignalEvent{Type: models.SignalEventStatusChanged, WorkspaceID: 1, SignalID: 1, UserID: 8, Status: "read"})
+	bus.Publish(models.SignalEvent{Type: models.SignalEventStatusChanged, WorkspaceID: 1, SignalID: 1, UserID: 7, Status: "done"})
+
+	select {
+	case event := <-sub.Events():
+		if event.ID != 4 || event.UserID != 7 || event.OccurredAt.IsZero() {
+			t.Fatalf("expected only the member's own workspace event, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected an event")
+	}
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("expected no more events, got %+v", event)
+	default:
+	}
+
+	if missed, complete := bus.Replay(sub, 0); !complete || len(missed) != 1 || missed[0].ID != 1 {
+		t.Fatalf("expected to replay event 1, got %+v (complete=%v)", missed, complete)
+	}
+	if missed, complete := bus.Replay(sub, 1); !complete || len(missed) != 0 {
+		t.Fatalf("expected nothing to replay, got %+v (complete=%v)", missed, complete)
+	}
+	if _, complete := bus.Replay(sub, 99); complete {
+		t.Fatal("expected an ID from before a restart to need a reset")
+	}
+}
+
+func TestSignalBusDropsSlowSubscribersAndTrimsHistory(t *testing.T) {
+	bus := NewSignalBus()
+	sub := bus.Subscribe(1, 7)
+	for i := 0; i < signalEventHistory+1; i++ {
+		bus.Publish(models.SignalEvent{Type: models.SignalEventUpdated, WorkspaceID: 1, SignalID: i})
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	if received != signalSubscriberBuffer {
+		t.Fatalf("expected the slow subscriber to be closed after %d events, got %d", signalSubscriberBuffer, received)
+	}
+	bus.Unsubscribe(sub)
+
+	late := bus.Subscribe(1, 7)
+	defer bus.Unsubscribe(late)
+	if _, complete := bus.Replay(late, 0); complete {
+		t.Fatal("expected trimmed history to need a reset")
+	}
+	if missed, complete := bus.Replay(late, 1); !complete || len(missed) != signalEventHistory {
+		t.Fatalf("expected the whole history to replay, got %d (complete=%v)", len(missed), complete)
+	}
+}
+
+func TestSignalWritesPublishEvents(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	sub := DefaultSignalBus().Subscribe(1, 2)
+	defer DefaultSignalBus().Unsubscribe(sub)
+	next := func() models.SignalEvent {
+		t.Helper()
+		select {
+		case event := <-sub.Events():
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("expected an event")
+		}
+		return models.SignalEvent{}
+	}
+
+	storeTestSignal(t, 10, "Crash on login")
+	created := next()
+	if created.Type != models.SignalEventCreated || created.Signal == nil || created.Signal.Title != "Crash on login" {
+		t.Fatalf("expected a created event with the signal, got %+v", created)
+	}
+
+	storeTestSignal(t, 10, "Crash on login (regression)")
+	if updated := next(); updated.Type != models.SignalEventUpdated || updated.SignalID != created.SignalID || updated.Signal.Title != "Crash on login (regression)" {
+		t.Fatalf("expected an updated event, got %+v", updated)
+	}
+
+	// Another member's status is theirs alone; an assignment is shared.
+	PublishSignalTriage(1, []int{created.SignalID}, models.SignalTriage{Action: models.SignalActionStatus, Status: models.SignalStatusDone})
+	PublishSignalTriage(2, []int{created.SignalID}, models.SignalTriage{Action: models.SignalActionStatus, Status: models.SignalStatusArchived})
+	if archived := next(); archived.Type != models.SignalEventArchived || archived.UserID != 2 {
+		t.Fatalf("expected the member's own archive event, got %+v", archived)
+	}
+	PublishSignalTriage(1, []int{created.SignalID}, models.SignalTriage{Action: models.SignalActionAssign})
+	if assigned := next(); assigned.Type != models.SignalEventUpdated || assigned.UserID != 0 {
+		t.Fatalf("expected a shared updated event for the assignment, got %+v", assigned)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"time"
+)
+
+// IntegrationHealth summarizes the integration's sync runs and token. The
+// integration must have been loaded with its tokens.
+func IntegrationHealth(integration *models.ExternalIntegration) (*models.IntegrationHealth, error) {
+	runs := repository.Default().SyncRuns
+	health := &models.IntegrationHealth{
+		IntegrationID:  integration.ID,
+		Provider:       integration.Provider,
+		TokenExpiresAt: integration.ExpiresAt,
+	}
+
+	lastRun, err := runs.Latest(integration.ID, "")
+	if err != nil && err != repository.ErrNotFound {
+		return nil, err
+	}
+	if lastRun != nil {
+		health.LastRun = lastRun
+		health.ItemsFetched = lastRun.ItemsFetched
+		health.ItemsUpserted = lastRun.ItemsUpserted
+		health.ItemsFailed = lastRun.ItemsFailed
+	}
+
+	lastSuccess, err := runs.Latest(integration.ID, models.SyncRunSucceeded)
+	if err != nil && err != repository.ErrNotFound {
+		return nil, err
+	}
+	if lastSuccess != nil {
+		health.LastSuccessAt = lastSuccess.FinishedAt
+	}
+
+	lastFailure, err := runs.Latest(integration.ID, models.SyncRunFailed)
+	if err != nil && err != repository.ErrNotFound {
+		return nil, err
+	}
+	if lastFailure != nil {
+		health.LastErrorAt = lastFailure.FinishedAt
+		health.LastError = lastFailure.Error
+	}
+
+	if health.ConsecutiveFailures, err = runs.ConsecutiveFailures(integration.ID); err != nil {
+		return nil, err
+	}
+
+	latestJob, err := repository.Default().SyncJobs.Latest(integration.ID)
+	if err != nil && err != repository.ErrNotFound {
+		return nil, err
+	}
+	if latestJob != nil && latestJob.Status == models.SyncJobDead && latestJob.FinishedAt != nil {
+		nextRetry := latestJob.FinishedAt.Add(syncRetryMaxDelay)
+		health.DeadLetteredAt = latestJob.FinishedAt
+		health.NextRetryAt = &nextRetry
+	}
+
+	health.Status = integrationHealthStatus(integration, health, time.Now())
+	return health, nil
+}
+
+// integrationHealthStatus rates the integration. Rejected credentials, or an
+// expired token that cannot be refreshed, need the user to reconnect; a sync
+// job that ran out of attempts marks it failing, and any other failure since
+// the last successful run, or items the latest run could not sync, marks it
+// degraded.
+func integrationHealthStatus(integration *models.ExternalIntegration, health *models.IntegrationHealth, now time.Time) string {
+	switch {
+	case integration.ReauthRequiredAt != nil:
+		return models.IntegrationReauthRequired
+	case integration.ExpiresAt != nil && integration.ExpiresAt.Before(now) && integration.RefreshToken == "":
+		return models.IntegrationReauthRequired
+	case health.DeadLetteredAt != nil:
+		return models.IntegrationFailing
+	case health.ConsecutiveFailures > 0 || health.ItemsFailed > 0:
+		return models.IntegrationDegraded
+	case health.LastRun == nil:
+		return models.IntegrationNeverSynced
+	default:
+		return models.IntegrationHealthy
+	}
+}
@@ -0,0 +1,39 @@
+// Package servicestest holds the database fixtures shared by the tests of
+// package services and of the integration providers.
+package servicestest
+
+import (
+	"fmt"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/repository"
+	"testing"
+)
+
+// SeededDB swaps database.DB for a freshly migrated database holding two
+// users, a workspace both belong to and an open decision, and restores the
+// previous database when the test ends. It runs on PostgreSQL when
+// TEST_DATABASE_URL is set.
+func SeededDB(t *testing.T) *repository.Store {
+	t.Helper()
+
+	dbtest.Open(t)
+	dbtest.Seed(t,
+		`INSERT INTO users (id, email, password) VALUES (1, 'owner@example.com', 'x'), (2, 'member@example.com', 'x')`,
+		`INSERT INTO workspaces (id, name, owner_id) VALUES (1, 'Team', 1)`,
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (1, 1, 'owner'), (1, 2, 'member')`,
+		`INSERT INTO decisions (id, workspace_id, user_id, title, status) VALUES (1, 1, 1, 'Ship v2?', 'OPEN')`,
+	)
+	return repository.Default()
+}
+
+// AddWorkspace seeds another workspace owned by ownerID, creating the owner
+// first when SeededDB did not.
+func AddWorkspace(t *testing.T, id, ownerID int) {
+	t.Helper()
+
+	dbtest.Seed(t,
+		fmt.Sprintf(`INSERT INTO users (id, email, password) VALUES (%d, 'user%d@example.com', 'x') ON CONFLICT (id) DO NOTHING`, ownerID, ownerID),
+		fmt.Sprintf(`INSERT INTO workspaces (id, name, owner_id) VALUES (%d, 'Workspace %d', %d)`, id, id, ownerID),
+		fmt.Sprintf(`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (%d, %d, 'owner')`, id, ownerID),
+	)
+}
@@ -159,3 +159,18 @@ func SendPasswordResetEmail(toEmail, resetURL string) error {
 
 	return sendSMTP(config, buildMessage(config, toEmail, subject, body), []string{toEmail})
 }
+
+func SendReauthRequiredEmail(toEmail, providerName, reconnectURL string) error {
+	config, err := LoadSMTPConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("Reconnect %s to Sentinent", providerName)
+	body := fmt.Sprintf(
+		"Hello,\r\n\r\n%s stopped accepting Sentinent's access, so we paused syncing it. This usually means the access was revoked or has expired.\r\n\r\nReconnect it here to resume syncing:\r\n%s\r\n",
+		providerName, reconnectURL,
+	)
+
+	return sendSMTP(config, buildMessage(config, toEmail, subject, body), []string{toEmail})
+}
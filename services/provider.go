@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/utils"
+
+	"golang.org/x/oauth2"
+)
+
+// ProviderScope says what an integration is connected for.
+type ProviderScope int
+
+const (
+	// ScopeWorkspace integrations are connected separately in each workspace.
+	ScopeWorkspace ProviderScope = iota
+	// ScopeUser integrations follow the user into every workspace and are
+	// stored without one.
+	ScopeUser
+)
+
+// Provider is an external service that users connect over OAuth and whose
+// items are synced into signals. Each provider registers itself with
+// RegisterProvider; the HTTP router and the SyncService only go through the
+// registry.
+type Provider interface {
+	// Name identifies the provider in routes, OAuth state and the provider
+	// column of external_integrations.
+	Name() string
+	// DisplayName is the human-readable name used in responses.
+	DisplayName() string
+	Scope() ProviderScope
+	// Init reads the provider's configuration from the environment.
+	Init() error
+	Configured() bool
+	// AuthURL returns the consent page URL. redirectURI is the default
+	// callback URL for the request; providers with a fixed or configured
+	// callback may ignore it.
+	AuthURL(state, redirectURI string) string
+	// Exchange trades an authorization code for a grant to store.
+	Exchange(ctx context.Context, code, redirectURI string) (*Grant, error)
+	// RefreshToken returns a usable token, refreshing it if it has expired.
+	RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error)
+	// Sync fetches the connected account's items into signals. workspaceID is
+	// zero for user-scoped providers.
+	Sync(userID, workspaceID int) (SyncStats, error)
+}
+
+// SyncStats counts the items a sync fetched from the provider and how many of
+// them were stored as signals. ItemsFailed counts the items, or whole batches
+// such as a channel's history, that could not be fetched or stored; a sync
+// that returns no error but some failures marks the integration degraded.
+type SyncStats struct {
+	ItemsFetched  int
+	ItemsUpserted int
+	ItemsFailed   int
+}
+
+// Grant is the result of a successful OAuth exchange.
+type Grant struct {
+	Token *oauth2.Token
+	// Metadata is merged into the integration's stored metadata.
+	Metadata map[string]interface{}
+}
+
+// HTTPDoer abstracts the HTTP client for testability.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// WebhookProvider is implemented by providers that receive push events at
+// /api/webhooks/{name}. The handler is responsible for verifying the sender.
+type WebhookProvider interface {
+	Provider
+	HandleWebhook(w http.ResponseWriter, r *http.Request)
+}
+
+// ActionProvider is implemented by providers with endpoints of their own under
+// /api/integrations/{name}/.
+type ActionProvider interface {
+	Provider
+	Actions() []ProviderAction
+}
+
+// ProviderAction is a provider-specific endpoint. Path is matched against the
+// request path after /api/integrations/{name}/; a trailing slash makes it a
+// prefix match.
+type ProviderAction struct {
+	Path   string
+	Handle func(w http.ResponseWriter, r *http.Request, action ActionContext)
+}
+
+// ActionContext identifies the caller of a provider action. WorkspaceID is set
+// when the request names a workspace_id, after the caller's membership has
+// been checked, and is zero otherwise.
+type ActionContext struct {
+	UserID      int
+	WorkspaceID int
+}
+
+// RemoteCallbackProvider is implemented by providers whose OAuth callback is
+// commonly served from another host than the API (for example a tunnel set as
+// the redirect URI), where the state cookie from the auth request is not sent.
+// Their callbacks rely on the signed state alone.
+type RemoteCallbackProvider interface {
+	Provider
+	RemoteCallback() bool
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// RegisterProvider makes a provider available to the router and the sync
+// service. It panics if the name is empty or already registered.
+func RegisterProvider(provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	name := provider.Name()
+	if name == "" {
+		panic("services: provider name is empty")
+	}
+	if _, exists := providers[name]; exists {
+		panic("services: provider " + name + " registered twice")
+	}
+	providers[name] = provider
+}
+
+// Providers returns the registered providers ordered by name.
+func Providers() []Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	list := make([]Provider, 0, len(providers))
+	for _, provider := range providers {
+		list = append(list, provider)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// LookupProvider returns the provider registered under name.
+func LookupProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// SaveIntegration stores a grant as the user's integration for the provider,
+// encrypting its tokens.
+func SaveIntegration(provider Provider, userID, workspaceID int, grant *Grant) error {
+	if grant == nil || grant.Token == nil || grant.Token.AccessToken == "" {
+		return fmt.Errorf("%s grant has no access token", provider.DisplayName())
+	}
+
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		return err
+	}
+	tokens, err := sealIntegrationToken(encryptor, grant.Token)
+	if err != nil {
+		return err
+	}
+
+	return repository.Default().Integrations.Upsert(
+		userID, provider.Name(), integrationWorkspace(provider, workspaceID), tokens, grant.Metadata,
+	)
+}
+
+// GetIntegration returns the user's stored integration for the provider.
+// workspaceID is ignored for user-scoped providers.
+func GetIntegration(provider Provider, userID, workspaceID int) (*models.ExternalIntegration, error) {
+	return repository.Default().Integrations.Get(userID, provider.Name(), integrationWorkspace(provider, workspaceID))
+}
+
+// DeleteIntegration disconnects the user's integration for the provider.
+func DeleteIntegration(provider Provider, userID, workspaceID int) error {
+	return repository.Default().Integrations.DeleteFor(userID, provider.Name(), integrationWorkspace(provider, workspaceID))
+}
+
+// IntegrationToken decrypts the integration's token and lets the provider
+// refresh it, storing the new token when it changed.
+func IntegrationToken(ctx context.Context, provider Provider, integration *models.ExternalIntegration) (*oauth2.Token, error) {
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := encryptor.Decrypt(integration.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt access token: %w", err)
+	}
+	token := &oauth2.Token{AccessToken: accessToken}
+	if integration.RefreshToken != "" {
+		token.RefreshToken, err = encryptor.Decrypt(integration.RefreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt refresh token: %w", err)
+		}
+	}
+	if integration.ExpiresAt != nil {
+		token.Expiry = *integration.ExpiresAt
+	}
+
+	refreshed, err := provider.RefreshToken(ctx, token)
+	if err != nil {
+		err = fmt.Errorf("failed to get/refresh token: %w", err)
+		if isTokenRefreshRejected(err) {
+			return nil, &ReauthRequiredError{Provider: provider.DisplayName(), Err: err}
+		}
+		return nil, err
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		tokens, err := sealIntegrationToken(encryptor, refreshed)
+		if err == nil {
+			err = repository.Default().Integrations.UpdateTokens(integration.ID, tokens)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to save refreshed %s token: %v", provider.DisplayName(), err)
+		}
+	}
+	return refreshed, nil
+}
+
+// IntegrationHTTPClient returns a client authorized as the integration, with
+// a timeout so a slow provider cannot hang a request or a sync.
+func IntegrationHTTPClient(provider Provider, userID, workspaceID int) (*http.Client, *oauth2.Token, error) {
+	integration, err := GetIntegration(provider, userID, workspaceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s integration not found: %w", provider.DisplayName(), err)
+	}
+
+	baseClient := &http.Client{Timeout: 30 * time.Second}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, baseClient)
+	token, err := IntegrationToken(ctx, provider, integration)
+	if err != nil {
+		return nil, nil, err
+	}
+	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)), token, nil
+}
+
+func integrationWorkspace(provider Provider, workspaceID int) *int {
+	if provider.Scope() == ScopeUser {
+		return nil
+	}
+	return &workspaceID
+}
+
+func sealIntegrationToken(encryptor *utils.TokenEncryptor, token *oauth2.Token) (repository.IntegrationTokens, error) {
+	var tokens repository.IntegrationTokens
+	var err error
+	tokens.AccessToken, err = encryptor.Encrypt(token.AccessToken)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	if token.RefreshToken != "" {
+		tokens.RefreshToken, err = encryptor.Encrypt(token.RefreshToken)
+		if err != nil {
+			return tokens, fmt.Errorf("failed to encrypt refresh token: %w", err)
+		}
+	}
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry
+		tokens.ExpiresAt = &expiry
+	}
+	return tokens, nil
+}
+
+// RequireActionWorkspace writes a 400 response and returns false when the
+// action was called without a workspace.
+func RequireActionWorkspace(w http.ResponseWriter, action ActionContext) bool {
+	if action.WorkspaceID == 0 {
+		http.Error(w, "workspace_id is required", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// UpdateActionMetadata applies mutate to the caller's integration metadata and
+// writes the response.
+func UpdateActionMetadata(w http.ResponseWriter, provider Provider, action ActionContext, mutate func(metadata map[string]interface{})) {
+	err := repository.Default().Integrations.UpdateMetadata(
+		action.UserID, provider.Name(), integrationWorkspace(provider, action.WorkspaceID), mutate,
+	)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case repository.ErrNotFound:
+		http.Error(w, "Integration not found", http.StatusNotFound)
+	default:
+		http.Error(w, "Failed to update integration", http.StatusInternalServerError)
+	}
+}
+
+// TokenEncryptionConfigured reports whether stored tokens can be encrypted.
+func TokenEncryptionConfigured() bool {
+	return strings.TrimSpace(os.Getenv("TOKEN_ENCRYPTION_KEY")) != ""
+}
+
+// StaticToken is the RefreshToken implementation for providers whose tokens
+// do not expire.
+func StaticToken(token *oauth2.Token) (*oauth2.Token, error) {
+	return token, nil
+}
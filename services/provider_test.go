@@ -0,0 +1,64 @@
+package services
+
+import "testing"
+
+type stubProvider struct {
+	Provider
+	name string
+}
+
+func (p stubProvider) Name() string { return p.name }
+
+// registerStubProviders swaps in an empty registry for the test.
+func registerStubProviders(t *testing.T, names ...string) {
+	providersMu.Lock()
+	saved := providers
+	providers = make(map[string]Provider)
+	providersMu.Unlock()
+	t.Cleanup(func() {
+		providersMu.Lock()
+		providers = saved
+		providersMu.Unlock()
+	})
+
+	for _, name := range names {
+		RegisterProvider(stubProvider{name: name})
+	}
+}
+
+func TestProvidersAreListedInNameOrder(t *testing.T) {
+	registerStubProviders(t, "slack", "github", "jira")
+
+	var names []string
+	for _, provider := range Providers() {
+		names = append(names, provider.Name())
+	}
+
+	want := []string{"github", "jira", "slack"}
+	if len(names) != len(want) {
+		t.Fatalf("expected providers %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected providers %v, got %v", want, names)
+		}
+	}
+
+	if provider, ok := LookupProvider("jira"); !ok || provider.Name() != "jira" {
+		t.Fatal("expected jira provider lookup to succeed")
+	}
+	if _, ok := LookupProvider("unknown"); ok {
+		t.Fatal("expected unknown provider lookup to fail")
+	}
+}
+
+func TestRegisterProviderRejectsDuplicateName(t *testing.T) {
+	registerStubProviders(t, "github")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected duplicate registration to panic")
+		}
+	}()
+	RegisterProvider(stubProvider{name: "github"})
+}
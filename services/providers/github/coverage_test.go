@@ -1,55 +1,22 @@
-package services
+package github
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"path/filepath"
 	"sentinent-backend/database"
+	"sentinent-backend/services"
+	"sentinent-backend/services/internal/servicestest"
+	"sentinent-backend/utils"
 	"testing"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/oauth2"
 )
 
-func setupGitHubCoverageTestDB(t *testing.T) func() {
-	t.Helper()
-
-	dbPath := filepath.Join(t.TempDir(), "github-coverage.db")
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		t.Fatalf("failed to open sqlite db: %v", err)
-	}
-
-	if _, err := db.Exec(`
-		CREATE TABLE external_integrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			provider TEXT NOT NULL,
-			access_token TEXT NOT NULL,
-			refresh_token TEXT,
-			expires_at DATETIME,
-			metadata TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`); err != nil {
-		t.Fatalf("failed to prepare schema: %v", err)
-	}
-
-	originalDB := database.DB
-	database.DB = db
-
-	return func() {
-		database.DB = originalDB
-		_ = db.Close()
-	}
-}
-
 type githubRewriteTransport struct {
 	base   http.RoundTripper
 	target *url.URL
@@ -63,46 +30,37 @@ func (t githubRewriteTransport) RoundTrip(req *http.Request) (*http.Response, er
 	return t.base.RoundTrip(clone)
 }
 
-func TestGitHubTokenEncryptionAndIntegrationCRUD(t *testing.T) {
-	cleanup := setupGitHubCoverageTestDB(t)
-	defer cleanup()
+func TestGitHubIntegrationSaveGetAndDelete(t *testing.T) {
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 7, 1)
 
 	originalConfig := githubOAuthConfig
-	originalKey := tokenEncryptionKey
 	t.Setenv("GITHUB_CLIENT_ID", "client-id")
 	t.Setenv("GITHUB_CLIENT_SECRET", "client-secret")
 	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
 	t.Cleanup(func() {
 		githubOAuthConfig = originalConfig
-		tokenEncryptionKey = originalKey
 	})
 
 	if err := InitGitHubService(); err != nil {
 		t.Fatalf("failed to initialize GitHub service: %v", err)
 	}
-
-	ciphertext, err := EncryptToken("github-access-token")
+	encryptor, err := utils.NewTokenEncryptor()
 	if err != nil {
-		t.Fatalf("failed to encrypt token: %v", err)
-	}
-	plaintext, err := DecryptToken(ciphertext)
-	if err != nil {
-		t.Fatalf("failed to decrypt token: %v", err)
-	}
-	if plaintext != "github-access-token" {
-		t.Fatalf("expected decrypted token to round-trip, got %q", plaintext)
+		t.Fatalf("failed to create encryptor: %v", err)
 	}
 
+	provider := githubProvider{}
 	firstToken := &oauth2.Token{
 		AccessToken:  "access-token-1",
 		RefreshToken: "refresh-token-1",
 		Expiry:       time.Now().Add(time.Hour),
 	}
-	if err := SaveGitHubIntegration(1, 7, firstToken); err != nil {
+	if err := services.SaveIntegration(provider, 1, 7, &services.Grant{Token: firstToken}); err != nil {
 		t.Fatalf("failed to save integration: %v", err)
 	}
 
-	integration, err := GetGitHubIntegration(1, 7)
+	integration, err := services.GetIntegration(provider, 1, 7)
 	if err != nil {
 		t.Fatalf("failed to read integration: %v", err)
 	}
@@ -110,7 +68,7 @@ func TestGitHubTokenEncryptionAndIntegrationCRUD(t *testing.T) {
 		t.Fatalf("unexpected saved integration: %+v", integration)
 	}
 
-	decryptedAccess, err := DecryptToken(integration.AccessToken)
+	decryptedAccess, err := encryptor.Decrypt(integration.AccessToken)
 	if err != nil {
 		t.Fatalf("failed to decrypt saved access token: %v", err)
 	}
@@ -118,7 +76,7 @@ func TestGitHubTokenEncryptionAndIntegrationCRUD(t *testing.T) {
 		t.Fatalf("expected access-token-1, got %q", decryptedAccess)
 	}
 
-	decryptedRefresh, err := DecryptToken(integration.RefreshToken)
+	decryptedRefresh, err := encryptor.Decrypt(integration.RefreshToken)
 	if err != nil {
 		t.Fatalf("failed to decrypt saved refresh token: %v", err)
 	}
@@ -126,12 +84,20 @@ func TestGitHubTokenEncryptionAndIntegrationCRUD(t *testing.T) {
 		t.Fatalf("expected refresh-token-1, got %q", decryptedRefresh)
 	}
 
+	token, err := services.IntegrationToken(context.Background(), provider, integration)
+	if err != nil {
+		t.Fatalf("failed to load integration token: %v", err)
+	}
+	if token.AccessToken != "access-token-1" {
+		t.Fatalf("expected access-token-1 from IntegrationToken, got %q", token.AccessToken)
+	}
+
 	updatedToken := &oauth2.Token{
 		AccessToken:  "access-token-2",
 		RefreshToken: "refresh-token-2",
 		Expiry:       time.Now().Add(2 * time.Hour),
 	}
-	if err := SaveGitHubIntegration(1, 7, updatedToken); err != nil {
+	if err := services.SaveIntegration(provider, 1, 7, &services.Grant{Token: updatedToken}); err != nil {
 		t.Fatalf("failed to update integration: %v", err)
 	}
 
@@ -146,7 +112,7 @@ func TestGitHubTokenEncryptionAndIntegrationCRUD(t *testing.T) {
 		t.Fatalf("expected a single integration row, got %d", rowCount)
 	}
 
-	if err := DeleteGitHubIntegration(1, 7); err != nil {
+	if err := services.DeleteIntegration(provider, 1, 7); err != nil {
 		t.Fatalf("failed to delete integration: %v", err)
 	}
 	if err := database.DB.QueryRow(
@@ -161,11 +127,10 @@ func TestGitHubTokenEncryptionAndIntegrationCRUD(t *testing.T) {
 }
 
 func TestFetchAssignedIssuesAndListAccessibleReposWithLocalGitHubServer(t *testing.T) {
-	cleanup := setupGitHubCoverageTestDB(t)
-	defer cleanup()
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 7, 1)
 
 	originalConfig := githubOAuthConfig
-	originalKey := tokenEncryptionKey
 	originalDefaultTransport := http.DefaultTransport
 	originalDefaultClientTransport := http.DefaultClient.Transport
 	t.Setenv("GITHUB_CLIENT_ID", "client-id")
@@ -173,7 +138,6 @@ func TestFetchAssignedIssuesAndListAccessibleReposWithLocalGitHubServer(t *testi
 	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
 	t.Cleanup(func() {
 		githubOAuthConfig = originalConfig
-		tokenEncryptionKey = originalKey
 		http.DefaultTransport = originalDefaultTransport
 		http.DefaultClient.Transport = originalDefaultClientTransport
 	})
@@ -182,11 +146,11 @@ func TestFetchAssignedIssuesAndListAccessibleReposWithLocalGitHubServer(t *testi
 		t.Fatalf("failed to initialize GitHub service: %v", err)
 	}
 
-	if err := SaveGitHubIntegration(1, 7, &oauth2.Token{
+	if err := services.SaveIntegration(githubProvider{}, 1, 7, &services.Grant{Token: &oauth2.Token{
 		AccessToken:  "access-token",
 		RefreshToken: "refresh-token",
 		Expiry:       time.Now().Add(time.Hour),
-	}); err != nil {
+	}}); err != nil {
 		t.Fatalf("failed to seed integration: %v", err)
 	}
 
@@ -1,15 +1,11 @@
-package services
+package github
 
 import (
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"database/sql"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,20 +13,18 @@ import (
 	"strings"
 	"time"
 
-	"sentinent-backend/database"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 )
 
-var (
-	githubOAuthConfig  *oauth2.Config
-	tokenEncryptionKey []byte
-)
+var githubOAuthConfig *oauth2.Config
 
 func IsGitHubConfigured() bool {
-	return githubOAuthConfig != nil && len(tokenEncryptionKey) > 0
+	return githubOAuthConfig != nil && services.TokenEncryptionConfigured()
 }
 
 // GitHubIssue represents a GitHub issue or PR
@@ -59,26 +53,12 @@ type GitHubIssue struct {
 func InitGitHubService() error {
 	clientID := strings.TrimSpace(os.Getenv("GITHUB_CLIENT_ID"))
 	clientSecret := strings.TrimSpace(os.Getenv("GITHUB_CLIENT_SECRET"))
-	encryptionKey := strings.TrimSpace(os.Getenv("TOKEN_ENCRYPTION_KEY"))
-
-	if encryptionKey != "" {
-		// Ensure key is 32 bytes for AES-256
-		tokenEncryptionKey = []byte(encryptionKey)
-		if len(tokenEncryptionKey) < 32 {
-			// Pad key to 32 bytes
-			paddedKey := make([]byte, 32)
-			copy(paddedKey, tokenEncryptionKey)
-			tokenEncryptionKey = paddedKey
-		} else if len(tokenEncryptionKey) > 32 {
-			tokenEncryptionKey = tokenEncryptionKey[:32]
-		}
-	}
 
 	if clientID == "" || clientSecret == "" {
 		return fmt.Errorf("GITHUB_CLIENT_ID and GITHUB_CLIENT_SECRET must be set")
 	}
 
-	if encryptionKey == "" {
+	if !services.TokenEncryptionConfigured() {
 		return fmt.Errorf("TOKEN_ENCRYPTION_KEY must be set")
 	}
 
@@ -108,171 +88,10 @@ func ExchangeGitHubCode(code string) (*oauth2.Token, error) {
 	return githubOAuthConfig.Exchange(context.Background(), code)
 }
 
-// EncryptToken encrypts a token using AES-GCM
-func EncryptToken(plaintext string) (string, error) {
-	block, err := aes.NewCipher(tokenEncryptionKey)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
-}
-
-// DecryptToken decrypts a token using AES-GCM
-func DecryptToken(ciphertext string) (string, error) {
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
-	if err != nil {
-		return "", err
-	}
-
-	block, err := aes.NewCipher(tokenEncryptionKey)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
-	}
-
-	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
-	if err != nil {
-		return "", err
-	}
-
-	return string(plaintext), nil
-}
-
-// SaveGitHubIntegration saves the GitHub integration for a user
-func SaveGitHubIntegration(userID, workspaceID int, token *oauth2.Token) error {
-	encryptedToken, err := EncryptToken(token.AccessToken)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt token: %w", err)
-	}
-
-	var encryptedRefreshToken string
-	if token.RefreshToken != "" {
-		encryptedRefreshToken, err = EncryptToken(token.RefreshToken)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt refresh token: %w", err)
-		}
-	}
-
-	result, err := database.DB.Exec(
-		`UPDATE external_integrations
-		 SET access_token = ?, refresh_token = ?, expires_at = ?, updated_at = CURRENT_TIMESTAMP
-		 WHERE user_id = ? AND provider = 'github' AND workspace_id = ?`,
-		encryptedToken, encryptedRefreshToken, token.Expiry, userID, workspaceID,
-	)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected > 0 {
-		return nil
-	}
-
-	_, err = database.DB.Exec(
-		`INSERT INTO external_integrations
-		 (user_id, workspace_id, provider, access_token, refresh_token, expires_at, metadata, updated_at)
-		 VALUES (?, ?, 'github', ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
-		userID, workspaceID, encryptedToken, encryptedRefreshToken, token.Expiry, "",
-	)
-	return err
-}
-
-// GetGitHubIntegration retrieves the GitHub integration for a user
-func GetGitHubIntegration(userID, workspaceID int) (*models.ExternalIntegration, error) {
-	var integration models.ExternalIntegration
-	var storedWorkspaceID sql.NullInt64
-	var metadata sql.NullString
-	var expiresAt *time.Time
-
-	err := database.DB.QueryRow(
-		`SELECT id, user_id, workspace_id, provider, access_token, refresh_token, expires_at, metadata, created_at, updated_at
-		FROM external_integrations
-		WHERE user_id = ? AND provider = 'github' AND workspace_id = ?`,
-		userID, workspaceID,
-	).Scan(
-		&integration.ID,
-		&integration.UserID,
-		&storedWorkspaceID,
-		&integration.Provider,
-		&integration.AccessToken,
-		&integration.RefreshToken,
-		&expiresAt,
-		&metadata,
-		&integration.CreatedAt,
-		&integration.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if storedWorkspaceID.Valid {
-		integration.WorkspaceID = int(storedWorkspaceID.Int64)
-	}
-	if metadata.Valid {
-		integration.Metadata = metadata.String
-	}
-	integration.ExpiresAt = expiresAt
-	return &integration, nil
-}
-
-// DeleteGitHubIntegration removes the GitHub integration for a user
-func DeleteGitHubIntegration(userID, workspaceID int) error {
-	_, err := database.DB.Exec(
-		"DELETE FROM external_integrations WHERE user_id = ? AND provider = 'github' AND workspace_id = ?",
-		userID, workspaceID,
-	)
-	return err
-}
-
 // GetGitHubClient creates an HTTP client with the user's GitHub token
 func GetGitHubClient(userID, workspaceID int) (*http.Client, error) {
-	integration, err := GetGitHubIntegration(userID, workspaceID)
-	if err != nil {
-		return nil, fmt.Errorf("GitHub integration not found: %w", err)
-	}
-
-	accessToken, err := DecryptToken(integration.AccessToken)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt token: %w", err)
-	}
-
-	token := &oauth2.Token{
-		AccessToken: accessToken,
-	}
-
-	// Pass a base HTTP client with a 30 s timeout so that every GitHub API
-	// call made through this client (repos, issues, comments …) is bounded.
-	// Without this, oauth2.NewClient uses http.DefaultClient which has no
-	// timeout and can hang indefinitely on a slow or rate-limited response.
-	baseClient := &http.Client{Timeout: 30 * time.Second}
-	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, baseClient)
-	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)), nil
+	client, _, err := services.IntegrationHTTPClient(githubProvider{}, userID, workspaceID)
+	return client, err
 }
 
 // FetchAssignedIssues fetches issues assigned to the user
@@ -301,6 +120,38 @@ func FetchRepoIssues(userID, workspaceID int, repoFullName string) ([]GitHubIssu
 	})
 }
 
+// FetchGitHubIssue fetches a single issue or PR by repository and number
+func FetchGitHubIssue(userID, workspaceID int, repoFullName string, number int) (*GitHubIssue, error) {
+	client, err := GetGitHubClient(userID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("https://api.github.com/repos/%s/issues/%d", repoFullName, number)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, githubAPIError(resp)
+	}
+
+	var issue GitHubIssue
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return nil, err
+	}
+	issue.Repository.FullName = repoFullName
+	return &issue, nil
+}
+
 // fetchGitHubIssues fetches issues or PRs from GitHub API with pagination
 func fetchGitHubIssues(client *http.Client, endpoint string, params map[string]string) ([]GitHubIssue, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
@@ -334,9 +185,9 @@ func fetchGitHubIssues(client *http.Client, endpoint string, params map[string]s
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
+			err := githubAPIError(resp)
 			resp.Body.Close()
-			return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+			return nil, err
 		}
 
 		var issues []GitHubIssue
@@ -389,10 +240,11 @@ func splitGitHubIssuesAndPullRequests(items []GitHubIssue) ([]GitHubIssue, []Git
 }
 
 // SyncGitHubSignals syncs GitHub issues and PRs to signals
-func SyncGitHubSignals(userID, workspaceID int) error {
-	integration, err := GetGitHubIntegration(userID, workspaceID)
+func SyncGitHubSignals(userID, workspaceID int) (services.SyncStats, error) {
+	var stats services.SyncStats
+	integration, err := services.GetIntegration(githubProvider{}, userID, workspaceID)
 	if err != nil {
-		return fmt.Errorf("failed to get integration: %w", err)
+		return stats, fmt.Errorf("failed to get integration: %w", err)
 	}
 
 	var metadata map[string]interface{}
@@ -404,6 +256,9 @@ func SyncGitHubSignals(userID, workspaceID int) error {
 
 	// 1. Fetch assigned issues (standard behavior)
 	assigned, err := FetchAssignedIssues(userID, workspaceID)
+	if services.IsReauthRequired(err) {
+		return stats, err
+	}
 	if err == nil {
 		allItems = append(allItems, assigned...)
 	}
@@ -412,6 +267,9 @@ func SyncGitHubSignals(userID, workspaceID int) error {
 	selectedRepoIDs, _ := metadata["selected_repo_ids"].([]interface{})
 	if len(selectedRepoIDs) > 0 {
 		repos, err := ListAccessibleRepos(userID, workspaceID)
+		if services.IsReauthRequired(err) {
+			return stats, err
+		}
 		if err == nil {
 			for _, repoIDInterface := range selectedRepoIDs {
 				var id int64
@@ -435,6 +293,9 @@ func SyncGitHubSignals(userID, workspaceID int) error {
 						fullName, ok := r["full_name"].(string)
 						if ok {
 							repoIssues, err := FetchRepoIssues(userID, workspaceID, fullName)
+							if services.IsReauthRequired(err) {
+								return stats, err
+							}
 							if err == nil {
 								allItems = append(allItems, repoIssues...)
 							}
@@ -458,22 +319,27 @@ func SyncGitHubSignals(userID, workspaceID int) error {
 	}
 
 	issues, prs := splitGitHubIssuesAndPullRequests(uniqueItems)
+	stats.ItemsFetched = len(uniqueItems)
 
 	// Save issues as signals
 	for _, issue := range issues {
 		if err := saveGitHubSignal(userID, workspaceID, issue, "issue"); err != nil {
 			fmt.Printf("Failed to save issue signal: %v\n", err)
+			continue
 		}
+		stats.ItemsUpserted++
 	}
 
 	// Save PRs as signals
 	for _, pr := range prs {
 		if err := saveGitHubSignal(userID, workspaceID, pr, "pull_request"); err != nil {
 			fmt.Printf("Failed to save PR signal: %v\n", err)
+			continue
 		}
+		stats.ItemsUpserted++
 	}
 
-	return nil
+	return stats, nil
 }
 
 // saveGitHubSignal saves a GitHub issue/PR as a signal
@@ -497,133 +363,29 @@ func saveGitHubSignal(userID, workspaceID int, issue GitHubIssue, issueType stri
 		return err
 	}
 
-	_, err = database.DB.Exec(
-		`INSERT INTO signals
-		(user_id, workspace_id, source_type, source_id, external_id, title, content, body, url, author, status, source_metadata, received_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-		ON CONFLICT(user_id, source_type, source_id) DO UPDATE SET
-		external_id = excluded.external_id,
-		title = excluded.title,
-		content = excluded.content,
-		body = excluded.body,
-		url = excluded.url,
-		author = excluded.author,
-		status = excluded.status,
-		source_metadata = excluded.source_metadata,
-		received_at = excluded.received_at,
-		updated_at = CURRENT_TIMESTAMP`,
-		userID,
-		workspaceID,
-		models.SourceTypeGitHub,
-		strconv.FormatInt(issue.ID, 10),
-		strconv.FormatInt(issue.ID, 10),
-		issue.Title,
-		issue.Body,
-		issue.Body,
-		issue.HTMLURL,
-		"",
-		issue.State,
-		string(metadataJSON),
-		issue.UpdatedAt,
-	)
-
-	return err
-}
-
-// GetUserSignals retrieves signals for a user with optional filtering
-func GetUserSignals(userID int, filter *models.SignalFilter) ([]models.Signal, error) {
-	query := `SELECT s.id, s.user_id, s.workspace_id, s.source_type, s.source_id, s.external_id,
-		s.title, s.content, s.author, s.body, s.url, COALESCE(ss.status, s.status) as status,
-		s.source_metadata, s.received_at, s.created_at, s.updated_at
-		FROM signals s
-		LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = ?
-		WHERE s.user_id = ?`
-	args := []interface{}{userID, userID}
-
-	if filter != nil && filter.SourceType != "" {
-		query += " AND s.source_type = ?"
-		args = append(args, filter.SourceType)
-	}
-
-	if filter != nil && filter.Status != "" {
-		query += " AND COALESCE(ss.status, s.status) = ?"
-		args = append(args, filter.Status)
-	}
-
-	query += " ORDER BY s.updated_at DESC"
-
-	rows, err := database.DB.Query(query, args...)
+	sourceID := strconv.FormatInt(issue.ID, 10)
+	created, err := repository.Default().Signals.UpsertSource(repository.SourceSignal{
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		SourceType:  models.SourceTypeGitHub,
+		SourceID:    sourceID,
+		ExternalID:  sourceID,
+		Title:       issue.Title,
+		Content:     issue.Body,
+		Body:        issue.Body,
+		URL:         issue.HTMLURL,
+		Status:      issue.State,
+		Metadata:    string(metadataJSON),
+		ReceivedAt:  issue.UpdatedAt,
+	}, repository.SourceExternalID, repository.SourceTitle, repository.SourceContent, repository.SourceBody,
+		repository.SourceURL, repository.SourceAuthor, repository.SourceStatus, repository.SourceMetadata,
+		repository.SourceReceivedAt)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	signals := make([]models.Signal, 0)
-	for rows.Next() {
-		var signal models.Signal
-		var workspaceID sql.NullInt64
-		var externalID sql.NullString
-		var content sql.NullString
-		var author sql.NullString
-		var body sql.NullString
-		var url sql.NullString
-		var metadataJSON sql.NullString
-		var receivedAt sql.NullTime
-
-		err := rows.Scan(
-			&signal.ID,
-			&signal.UserID,
-			&workspaceID,
-			&signal.SourceType,
-			&signal.SourceID,
-			&externalID,
-			&signal.Title,
-			&content,
-			&author,
-			&body,
-			&url,
-			&signal.Status,
-			&metadataJSON,
-			&receivedAt,
-			&signal.CreatedAt,
-			&signal.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		if workspaceID.Valid {
-			signal.WorkspaceID = int(workspaceID.Int64)
-		}
-		if externalID.Valid {
-			signal.ExternalID = externalID.String
-		}
-		if content.Valid {
-			signal.Content = content.String
-		}
-		if author.Valid {
-			signal.Author = author.String
-		}
-		if body.Valid {
-			signal.Body = body.String
-		}
-		if url.Valid {
-			signal.URL = url.String
-		}
-		if receivedAt.Valid {
-			signal.ReceivedAt = receivedAt.Time
-		}
-		if metadataJSON.Valid && metadataJSON.String != "" {
-			var metadata models.GitHubMetadata
-			if err := json.Unmarshal([]byte(metadataJSON.String), &metadata); err == nil {
-				signal.SourceMetadata = &metadata
-			}
-		}
-
-		signals = append(signals, signal)
+		return err
 	}
 
-	return signals, rows.Err()
+	services.SignalUpserted(workspaceID, models.SourceTypeGitHub, sourceID, created)
+	return nil
 }
 
 // ListAccessibleRepos lists repositories accessible to the user
@@ -661,7 +423,7 @@ func ListAccessibleRepos(userID, workspaceID int) ([]map[string]interface{}, err
 		resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(body))
+			return nil, githubStatusError(resp.StatusCode, body)
 		}
 
 		var repos []map[string]interface{}
@@ -710,8 +472,7 @@ func AddGitHubComment(userID, workspaceID int, repo string, number int, body str
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(respBody))
+		return githubAPIError(resp)
 	}
 
 	return nil
@@ -742,8 +503,7 @@ func UpdateGitHubIssueState(userID, workspaceID int, repo string, number int, st
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("GitHub API error: %d - %s", resp.StatusCode, string(respBody))
+		return githubAPIError(resp)
 	}
 
 	// Update the state field inside source_metadata for the matching signal.
@@ -752,19 +512,35 @@ func UpdateGitHubIssueState(userID, workspaceID int, repo string, number int, st
 	if state == "closed" {
 		oldState = "open"
 	}
-	_, _ = database.DB.Exec(
-		`UPDATE signals
-		 SET source_metadata = replace(source_metadata, ?, ?),
-		     updated_at      = CURRENT_TIMESTAMP
-		 WHERE user_id       = ?
-		   AND workspace_id  = ?
-		   AND source_type   = 'github'
-		   AND source_metadata LIKE ?`,
+	signalIDs, err := repository.Default().Signals.ReplaceInMetadata(
+		workspaceID,
+		models.SourceTypeGitHub,
+		fmt.Sprintf(`%%"number":%d%%`, number),
 		fmt.Sprintf(`"state":"%s"`, oldState),
 		fmt.Sprintf(`"state":"%s"`, state),
-		userID, workspaceID,
-		fmt.Sprintf(`%%"number":%d%%`, number),
 	)
+	if err != nil {
+		log.Printf("Failed to update GitHub signal state: %v", err)
+		return nil
+	}
+	for _, signalID := range signalIDs {
+		services.PublishSignalUpdated(workspaceID, signalID)
+	}
 
 	return nil
 }
+
+// githubAPIError reads a failed GitHub response into an error. A 401 means
+// the token was revoked or expired.
+func githubAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	return githubStatusError(resp.StatusCode, body)
+}
+
+func githubStatusError(statusCode int, body []byte) error {
+	err := fmt.Errorf("GitHub API error: %d - %s", statusCode, string(body))
+	if statusCode == http.StatusUnauthorized {
+		return &services.ReauthRequiredError{Provider: "GitHub", Err: err}
+	}
+	return err
+}
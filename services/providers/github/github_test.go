@@ -1,6 +1,11 @@
-package services
+package github
 
-import "testing"
+import (
+	"fmt"
+	"net/http"
+	"sentinent-backend/services"
+	"testing"
+)
 
 func TestSplitGitHubIssuesAndPullRequests(t *testing.T) {
 	items := []GitHubIssue{
@@ -29,3 +34,13 @@ func TestSplitGitHubIssuesAndPullRequests(t *testing.T) {
 		t.Fatalf("expected PR id 2, got %d", prs[0].ID)
 	}
 }
+
+func TestGitHubAuthFailuresRequireReauth(t *testing.T) {
+	err := fmt.Errorf("failed to fetch issues: %w", githubStatusError(http.StatusUnauthorized, []byte(`{"message":"Bad credentials"}`)))
+	if !services.IsReauthRequired(err) {
+		t.Errorf("expected a GitHub 401 to require re-authorization, got %v", err)
+	}
+	if services.IsReauthRequired(githubStatusError(http.StatusForbidden, nil)) {
+		t.Error("expected a GitHub 403 not to require re-authorization")
+	}
+}
@@ -0,0 +1,158 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sentinent-backend/services"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+func init() {
+	services.RegisterProvider(githubProvider{})
+}
+
+// githubProvider connects GitHub accounts per workspace and syncs assigned
+// issues and pull requests plus those of selected repositories.
+type githubProvider struct{}
+
+func (githubProvider) Name() string                  { return "github" }
+func (githubProvider) DisplayName() string           { return "GitHub" }
+func (githubProvider) Scope() services.ProviderScope { return services.ScopeWorkspace }
+func (githubProvider) Init() error                   { return InitGitHubService() }
+func (githubProvider) Configured() bool              { return IsGitHubConfigured() }
+
+func (githubProvider) AuthURL(state, redirectURI string) string {
+	return GetGitHubAuthURL(state)
+}
+
+func (githubProvider) Exchange(ctx context.Context, code, redirectURI string) (*services.Grant, error) {
+	token, err := ExchangeGitHubCode(code)
+	if err != nil {
+		return nil, err
+	}
+	return &services.Grant{Token: token}, nil
+}
+
+// RefreshToken returns the token as is; GitHub OAuth app tokens do not expire.
+func (githubProvider) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	return services.StaticToken(token)
+}
+
+func (githubProvider) Sync(userID, workspaceID int) (services.SyncStats, error) {
+	return SyncGitHubSignals(userID, workspaceID)
+}
+
+func (githubProvider) Actions() []services.ProviderAction {
+	return []services.ProviderAction{
+		{Path: "repos", Handle: githubReposAction},
+		{Path: "issues/", Handle: githubIssueAction},
+	}
+}
+
+// githubReposAction lists the repositories the connected account can access,
+// or with PATCH stores which of them are synced.
+func githubReposAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !services.RequireActionWorkspace(w, action) {
+		return
+	}
+
+	if r.Method == http.MethodPatch {
+		var req struct {
+			RepoIDs []int `json:"repo_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		services.UpdateActionMetadata(w, githubProvider{}, action, func(metadata map[string]interface{}) {
+			metadata["selected_repo_ids"] = req.RepoIDs
+		})
+		return
+	}
+
+	repos, err := ListAccessibleRepos(action.UserID, action.WorkspaceID)
+	if err != nil {
+		http.Error(w, "Failed to fetch repos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(repos)
+}
+
+// githubIssueAction handles issues/{number}/comments (POST) and
+// issues/{number}/state (PATCH).
+func githubIssueAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	// URL format: /api/integrations/github/issues/{number}/{comments|state}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/integrations/github/issues/"), "/")
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "comments":
+	case r.Method == http.MethodPatch && len(parts) == 2 && parts[1] == "state":
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !services.RequireActionWorkspace(w, action) {
+		return
+	}
+
+	number, err := strconv.Atoi(parts[0])
+	if err != nil || number <= 0 {
+		http.Error(w, "Invalid issue number", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Repo  string `json:"repo"`
+		Body  string `json:"body"`
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Repo = strings.TrimSpace(req.Repo)
+	if !isValidGitHubRepoFullName(req.Repo) {
+		http.Error(w, "Invalid GitHub repository", http.StatusBadRequest)
+		return
+	}
+
+	if parts[1] == "comments" {
+		req.Body = strings.TrimSpace(req.Body)
+		if req.Body == "" {
+			http.Error(w, "Comment body is required", http.StatusBadRequest)
+			return
+		}
+		if err := AddGitHubComment(action.UserID, action.WorkspaceID, req.Repo, number, req.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	req.State = strings.TrimSpace(req.State)
+	if req.State != "open" && req.State != "closed" {
+		http.Error(w, "Invalid GitHub issue state", http.StatusBadRequest)
+		return
+	}
+	if err := UpdateGitHubIssueState(action.UserID, action.WorkspaceID, req.Repo, number, req.State); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func isValidGitHubRepoFullName(repo string) bool {
+	owner, name, ok := strings.Cut(repo, "/")
+	return ok && strings.TrimSpace(owner) != "" && strings.TrimSpace(name) != "" &&
+		!strings.ContainsAny(owner, " \t\r\n") && !strings.ContainsAny(name, " \t\r\n/")
+}
@@ -0,0 +1,305 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/utils"
+)
+
+const githubWebhookSecretEnv = "GITHUB_WEBHOOK_SECRET"
+
+var errGitHubWebhookSecretMissing = errors.New("github webhook secret is not configured")
+
+// githubWebhookPullRequest is the pull_request object delivered by GitHub
+// webhooks. Its ID is the pull request ID, not the issue ID that the issues
+// API (and therefore SyncGitHubSignals) uses as the signal source ID.
+type githubWebhookPullRequest struct {
+	ID        int64     `json:"id"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	State     string    `json:"state"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Labels    []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+type githubWebhookPayload struct {
+	Action      string                    `json:"action"`
+	Issue       *GitHubIssue              `json:"issue"`
+	PullRequest *githubWebhookPullRequest `json:"pull_request"`
+	Repository  struct {
+		ID       int64  `json:"id"`
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+type githubWebhookTarget struct {
+	userID      int
+	workspaceID int
+}
+
+var (
+	// fetchGitHubIssueFunc resolves the canonical issue for a pull request when
+	// no signal exists yet. Tests replace it to avoid calling GitHub.
+	fetchGitHubIssueFunc = FetchGitHubIssue
+	// githubProcessWebhookFunc processes verified deliveries; tests replace it
+	// to check routing without a database.
+	githubProcessWebhookFunc = ProcessGitHubWebhook
+)
+
+// HandleWebhook receives deliveries at /api/webhooks/github.
+func (githubProvider) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	eventType := r.Header.Get("X-GitHub-Event")
+	if eventType == "" {
+		http.Error(w, "Missing event type", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateGitHubWebhookSignature(r, body); err != nil {
+		if errors.Is(err, errGitHubWebhookSecretMissing) {
+			http.Error(w, "GitHub webhook secret not configured", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	if !json.Valid(body) {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	switch eventType {
+	case "issues", "pull_request", "issue_comment", "pull_request_review":
+		if err := githubProcessWebhookFunc(eventType, body); err != nil {
+			log.Printf("Failed to process GitHub %s webhook: %v", eventType, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func validateGitHubWebhookSignature(r *http.Request, body []byte) error {
+	secret := strings.TrimSpace(os.Getenv(githubWebhookSecretEnv))
+	if secret == "" {
+		if utils.IsProductionEnv() {
+			return errGitHubWebhookSecretMissing
+		}
+		return nil
+	}
+
+	signature := strings.TrimSpace(r.Header.Get("X-Hub-Signature-256"))
+	if !strings.HasPrefix(signature, "sha256=") {
+		return errors.New("missing sha256 signature")
+	}
+
+	actual, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	if _, err := mac.Write(body); err != nil {
+		return err
+	}
+
+	if !hmac.Equal(actual, mac.Sum(nil)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// ProcessGitHubWebhook upserts the issue or pull request referenced by a
+// GitHub webhook delivery for every integration that tracks its repository.
+func ProcessGitHubWebhook(eventType string, body []byte) error {
+	var payload githubWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("decode %s payload: %w", eventType, err)
+	}
+	if payload.Repository.FullName == "" {
+		return nil
+	}
+
+	switch eventType {
+	case "issues":
+		if payload.Issue == nil || payload.Action == "deleted" || payload.Action == "transferred" {
+			return nil
+		}
+		return upsertGitHubWebhookIssue(payload, *payload.Issue)
+	case "issue_comment":
+		// Comments on pull requests arrive with the issue representation of
+		// the PR, so the same path serves both.
+		if payload.Issue == nil {
+			return nil
+		}
+		return upsertGitHubWebhookIssue(payload, *payload.Issue)
+	case "pull_request", "pull_request_review":
+		if payload.PullRequest == nil {
+			return nil
+		}
+		return upsertGitHubWebhookPullRequest(payload)
+	default:
+		return nil
+	}
+}
+
+func upsertGitHubWebhookIssue(payload githubWebhookPayload, issue GitHubIssue) error {
+	issue.Repository.FullName = payload.Repository.FullName
+	issueType := "issue"
+	if issue.PullRequest != nil {
+		issueType = "pull_request"
+	}
+
+	targets, err := findGitHubWebhookTargets(payload.Repository.ID, payload.Repository.FullName, issue.Number)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if err := saveGitHubSignal(target.userID, target.workspaceID, issue, issueType); err != nil {
+			log.Printf("Failed to save GitHub webhook signal for user %d workspace %d: %v", target.userID, target.workspaceID, err)
+		}
+	}
+	return nil
+}
+
+func upsertGitHubWebhookPullRequest(payload githubWebhookPayload) error {
+	pr := payload.PullRequest
+
+	targets, err := findGitHubWebhookTargets(payload.Repository.ID, payload.Repository.FullName, pr.Number)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		issue := GitHubIssue{
+			Number:    pr.Number,
+			Title:     pr.Title,
+			Body:      pr.Body,
+			State:     pr.State,
+			HTMLURL:   pr.HTMLURL,
+			CreatedAt: pr.CreatedAt,
+			UpdatedAt: pr.UpdatedAt,
+			Labels:    pr.Labels,
+			PullRequest: &struct {
+				URL string `json:"url"`
+			}{URL: pr.HTMLURL},
+		}
+		issue.Repository.FullName = payload.Repository.FullName
+
+		issueID, err := lookupGitHubSignalSourceID(target, payload.Repository.FullName, pr.Number)
+		if err != nil {
+			log.Printf("Failed to look up GitHub signal for %s#%d: %v", payload.Repository.FullName, pr.Number, err)
+			continue
+		}
+		if issueID == 0 {
+			canonical, err := fetchGitHubIssueFunc(target.userID, target.workspaceID, payload.Repository.FullName, pr.Number)
+			if err != nil {
+				log.Printf("Failed to resolve GitHub issue for %s#%d: %v", payload.Repository.FullName, pr.Number, err)
+				continue
+			}
+			issueID = canonical.ID
+		}
+		issue.ID = issueID
+
+		if err := saveGitHubSignal(target.userID, target.workspaceID, issue, "pull_request"); err != nil {
+			log.Printf("Failed to save GitHub webhook signal for user %d workspace %d: %v", target.userID, target.workspaceID, err)
+		}
+	}
+	return nil
+}
+
+// findGitHubWebhookTargets returns the user/workspace pairs that should see an
+// update for the given issue: integrations that selected the repository, plus
+// any that already hold a signal for it (for example from assigned issues).
+func findGitHubWebhookTargets(repoID int64, repoFullName string, number int) ([]githubWebhookTarget, error) {
+	integrations, err := repository.Default().Integrations.ListByProvider("github")
+	if err != nil {
+		return nil, fmt.Errorf("query GitHub integrations: %w", err)
+	}
+
+	candidates := make([]githubWebhookTarget, 0, len(integrations))
+	selected := make(map[githubWebhookTarget]bool)
+	for _, integration := range integrations {
+		target := githubWebhookTarget{userID: integration.UserID, workspaceID: integration.WorkspaceID}
+		candidates = append(candidates, target)
+
+		var metadata map[string]interface{}
+		if integration.Metadata != "" {
+			_ = json.Unmarshal([]byte(integration.Metadata), &metadata)
+		}
+		selectedRepoIDs, _ := metadata["selected_repo_ids"].([]interface{})
+		for _, value := range selectedRepoIDs {
+			if id, ok := value.(float64); ok && int64(id) == repoID {
+				selected[target] = true
+				break
+			}
+		}
+	}
+
+	targets := make([]githubWebhookTarget, 0, len(candidates))
+	for _, target := range candidates {
+		if selected[target] {
+			targets = append(targets, target)
+			continue
+		}
+		sourceID, err := lookupGitHubSignalSourceID(target, repoFullName, number)
+		if err != nil {
+			return nil, err
+		}
+		if sourceID != 0 {
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// lookupGitHubSignalSourceID finds the issue ID an existing signal was stored
+// under, matching on repository and number from its metadata. The metadata is
+// decoded here rather than in SQL so the lookup works on every backend.
+func lookupGitHubSignalSourceID(target githubWebhookTarget, repoFullName string, number int) (int64, error) {
+	sources, err := repository.Default().Signals.Sources(target.workspaceID, models.SourceTypeGitHub)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, source := range sources {
+		var metadata models.GitHubMetadata
+		if json.Unmarshal([]byte(source.Metadata), &metadata) != nil {
+			continue
+		}
+		if metadata.Repository == repoFullName && metadata.Number == number {
+			return strconv.ParseInt(source.SourceID, 10, 64)
+		}
+	}
+	return 0, nil
+}
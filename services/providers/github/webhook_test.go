@@ -0,0 +1,217 @@
+package github
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"sentinent-backend/services/internal/servicestest"
+	"testing"
+)
+
+func TestProcessGitHubWebhookUpsertsSignalsForSelectedRepos(t *testing.T) {
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 10, 1)
+	servicestest.AddWorkspace(t, 20, 2)
+
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata) VALUES
+		 (1, 10, 'github', 'token', '{"selected_repo_ids":[42]}'),
+		 (2, 20, 'github', 'token', '{"selected_repo_ids":[99]}')`,
+	); err != nil {
+		t.Fatalf("failed to seed integrations: %v", err)
+	}
+
+	opened := `{"action":"opened","repository":{"id":42,"full_name":"acme/api"},
+		"issue":{"id":500,"number":7,"title":"Crash on login","body":"Stack trace","state":"open","html_url":"https://github.com/acme/api/issues/7","updated_at":"2024-03-01T10:00:00Z","labels":[{"name":"bug"}]}}`
+	if err := ProcessGitHubWebhook("issues", []byte(opened)); err != nil {
+		t.Fatalf("ProcessGitHubWebhook returned error: %v", err)
+	}
+
+	closed := `{"action":"closed","repository":{"id":42,"full_name":"acme/api"},
+		"issue":{"id":500,"number":7,"title":"Crash on login","body":"Stack trace","state":"closed","html_url":"https://github.com/acme/api/issues/7","updated_at":"2024-03-02T10:00:00Z"}}`
+	if err := ProcessGitHubWebhook("issues", []byte(closed)); err != nil {
+		t.Fatalf("ProcessGitHubWebhook returned error: %v", err)
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals").Scan(&count); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected one signal for the selected repo, got %d", count)
+	}
+
+	var userID, workspaceID int
+	var sourceID, status, metadataJSON string
+	if err := database.DB.QueryRow(
+		"SELECT user_id, workspace_id, source_id, status, source_metadata FROM signals",
+	).Scan(&userID, &workspaceID, &sourceID, &status, &metadataJSON); err != nil {
+		t.Fatalf("failed to load signal: %v", err)
+	}
+	if userID != 1 || workspaceID != 10 || sourceID != "500" || status != "closed" {
+		t.Fatalf("unexpected signal: user=%d workspace=%d source_id=%s status=%s", userID, workspaceID, sourceID, status)
+	}
+
+	var metadata models.GitHubMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata: %v", err)
+	}
+	if metadata.Repository != "acme/api" || metadata.Number != 7 || metadata.State != "closed" || metadata.Type != "issue" {
+		t.Fatalf("unexpected metadata: %+v", metadata)
+	}
+}
+
+func TestProcessGitHubWebhookReusesIssueIDForPullRequestEvents(t *testing.T) {
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 10, 1)
+	servicestest.AddWorkspace(t, 30, 3)
+
+	// User 3 did not select the repo but already has the PR from assigned issues.
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata) VALUES
+		 (1, 10, 'github', 'token', '{"selected_repo_ids":[42]}'),
+		 (3, 30, 'github', 'token', '{}')`,
+	); err != nil {
+		t.Fatalf("failed to seed integrations: %v", err)
+	}
+	if _, err := database.DB.Exec(
+		`INSERT INTO signals (user_id, workspace_id, source_type, source_id, external_id, title, status, source_metadata)
+		 VALUES (3, 30, 'github', '900', '900', 'Old title', 'open', '{"repository":"acme/api","number":12,"state":"open","type":"pull_request"}')`,
+	); err != nil {
+		t.Fatalf("failed to seed signal: %v", err)
+	}
+
+	originalFetch := fetchGitHubIssueFunc
+	var fetched []int
+	fetchGitHubIssueFunc = func(userID, workspaceID int, repoFullName string, number int) (*GitHubIssue, error) {
+		fetched = append(fetched, userID)
+		if repoFullName != "acme/api" || number != 12 {
+			return nil, errors.New("unexpected issue lookup")
+		}
+		return &GitHubIssue{ID: 900, Number: 12}, nil
+	}
+	t.Cleanup(func() { fetchGitHubIssueFunc = originalFetch })
+
+	review := `{"action":"submitted","repository":{"id":42,"full_name":"acme/api"},
+		"pull_request":{"id":7777,"number":12,"title":"Add retries","state":"closed","html_url":"https://github.com/acme/api/pull/12","updated_at":"2024-03-02T10:00:00Z"}}`
+	if err := ProcessGitHubWebhook("pull_request_review", []byte(review)); err != nil {
+		t.Fatalf("ProcessGitHubWebhook returned error: %v", err)
+	}
+
+	if len(fetched) != 1 || fetched[0] != 1 {
+		t.Fatalf("expected only the user without a signal to resolve the issue ID, got %v", fetched)
+	}
+
+	rows, err := database.DB.Query("SELECT user_id, source_id, title, status FROM signals ORDER BY user_id")
+	if err != nil {
+		t.Fatalf("failed to query signals: %v", err)
+	}
+	defer rows.Close()
+
+	got := 0
+	for rows.Next() {
+		var userID int
+		var sourceID, title, status string
+		if err := rows.Scan(&userID, &sourceID, &title, &status); err != nil {
+			t.Fatalf("failed to scan signal: %v", err)
+		}
+		if sourceID != "900" || title != "Add retries" || status != "closed" {
+			t.Fatalf("unexpected signal for user %d: source_id=%s title=%q status=%s", userID, sourceID, title, status)
+		}
+		got++
+	}
+	if got != 2 {
+		t.Fatalf("expected signals for both users, got %d", got)
+	}
+}
+
+func TestGitHubHandleWebhookHandlesIssueAndPullRequestEvents(t *testing.T) {
+	t.Setenv(githubWebhookSecretEnv, "")
+
+	originalProcess := githubProcessWebhookFunc
+	var processed []string
+	githubProcessWebhookFunc = func(eventType string, body []byte) error {
+		processed = append(processed, eventType)
+		return nil
+	}
+	t.Cleanup(func() { githubProcessWebhookFunc = originalProcess })
+
+	issueReq := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewBufferString(`{"action":"opened","issue":{"id":1,"title":"Issue"}}`))
+	issueReq.Header.Set("X-GitHub-Event", "issues")
+	issueRR := httptest.NewRecorder()
+	githubProvider{}.HandleWebhook(issueRR, issueReq)
+
+	if issueRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from issues webhook, got %d: %s", issueRR.Code, issueRR.Body.String())
+	}
+
+	prReq := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewBufferString(`{"action":"opened","pull_request":{"id":2,"title":"PR"}}`))
+	prReq.Header.Set("X-GitHub-Event", "pull_request")
+	prRR := httptest.NewRecorder()
+	githubProvider{}.HandleWebhook(prRR, prReq)
+
+	if prRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from pull request webhook, got %d: %s", prRR.Code, prRR.Body.String())
+	}
+
+	pingReq := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewBufferString(`{"zen":"Keep it simple."}`))
+	pingReq.Header.Set("X-GitHub-Event", "ping")
+	pingRR := httptest.NewRecorder()
+	githubProvider{}.HandleWebhook(pingRR, pingReq)
+
+	if pingRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from ping webhook, got %d: %s", pingRR.Code, pingRR.Body.String())
+	}
+	if len(processed) != 2 || processed[0] != "issues" || processed[1] != "pull_request" {
+		t.Fatalf("expected issue and pull request events to be processed, got %v", processed)
+	}
+}
+
+func TestGitHubHandleWebhookVerifiesConfiguredSignature(t *testing.T) {
+	t.Setenv(githubWebhookSecretEnv, "webhook-secret")
+
+	originalProcess := githubProcessWebhookFunc
+	githubProcessWebhookFunc = func(eventType string, body []byte) error { return nil }
+	t.Cleanup(func() { githubProcessWebhookFunc = originalProcess })
+
+	body := []byte(`{"action":"opened","issue":{"id":1,"title":"Issue"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "issues")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+signWebhookBody(body, "webhook-secret"))
+
+	rr := httptest.NewRecorder()
+	githubProvider{}.HandleWebhook(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 from signed webhook, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGitHubHandleWebhookRejectsInvalidSignature(t *testing.T) {
+	t.Setenv(githubWebhookSecretEnv, "webhook-secret")
+
+	body := []byte(`{"action":"opened","issue":{"id":1,"title":"Issue"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/github", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "issues")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+signWebhookBody(body, "wrong-secret"))
+
+	rr := httptest.NewRecorder()
+	githubProvider{}.HandleWebhook(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 from invalid webhook signature, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func signWebhookBody(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
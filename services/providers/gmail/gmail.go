@@ -0,0 +1,400 @@
+package gmail
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	GmailAPIBaseURL       = "https://gmail.googleapis.com/gmail/v1"
+	gmailSyncMaxThreads   = 50
+	gmailInitialSyncQuery = "in:inbox newer_than:7d"
+)
+
+var (
+	gmailOAuthConfig *oauth2.Config
+
+	// newGmailAPIClient builds the Gmail API client used by SyncGmailSignals.
+	// Tests replace it to avoid talking to Google.
+	newGmailAPIClient = func(httpClient services.HTTPDoer) gmailAPIClient {
+		return &GmailClient{HTTPClient: httpClient, BaseURL: GmailAPIBaseURL}
+	}
+)
+
+type gmailAPIClient interface {
+	ListThreads(query, pageToken string, maxResults int) ([]GmailThreadRef, string, error)
+	GetThread(threadID string) (*GmailThread, error)
+}
+
+// GmailClient handles interactions with the Gmail REST API
+type GmailClient struct {
+	HTTPClient services.HTTPDoer
+	BaseURL    string
+}
+
+// GmailThreadRef is a thread entry returned by users.threads.list
+type GmailThreadRef struct {
+	ID        string `json:"id"`
+	Snippet   string `json:"snippet"`
+	HistoryID string `json:"historyId"`
+}
+
+// GmailThread represents a thread returned by users.threads.get
+type GmailThread struct {
+	ID        string         `json:"id"`
+	HistoryID string         `json:"historyId"`
+	Messages  []GmailMessage `json:"messages"`
+}
+
+// GmailMessage represents a single message inside a Gmail thread
+type GmailMessage struct {
+	ID           string   `json:"id"`
+	ThreadID     string   `json:"threadId"`
+	LabelIDs     []string `json:"labelIds"`
+	Snippet      string   `json:"snippet"`
+	InternalDate string   `json:"internalDate"`
+	Payload      struct {
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+	} `json:"payload"`
+}
+
+// Header returns the value of the named message header, if present
+func (m GmailMessage) Header(name string) string {
+	for _, header := range m.Payload.Headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// ReceivedAt converts the Gmail internalDate (epoch milliseconds) to a time
+func (m GmailMessage) ReceivedAt() time.Time {
+	millis, err := strconv.ParseInt(m.InternalDate, 10, 64)
+	if err != nil || millis <= 0 {
+		return time.Now()
+	}
+	return time.UnixMilli(millis)
+}
+
+// InitGmailService initializes the Gmail OAuth configuration used for token refresh
+func InitGmailService() error {
+	clientID := strings.TrimSpace(os.Getenv("GOOGLE_CLIENT_ID"))
+	clientSecret := strings.TrimSpace(os.Getenv("GOOGLE_CLIENT_SECRET"))
+
+	if clientID == "" || clientSecret == "" {
+		return fmt.Errorf("GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET must be set")
+	}
+
+	gmailOAuthConfig = &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+			TokenURL: "https://oauth2.googleapis.com/token",
+		},
+		Scopes: []string{
+			"https://www.googleapis.com/auth/gmail.readonly",
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
+		},
+	}
+
+	return nil
+}
+
+// IsGmailConfigured checks if Gmail OAuth is configured
+func IsGmailConfigured() bool {
+	return gmailOAuthConfig != nil
+}
+
+// ListThreads lists one page of inbox threads matching a Gmail search query,
+// newest first, and returns the token of the next page.
+func (c *GmailClient) ListThreads(query, pageToken string, maxResults int) ([]GmailThreadRef, string, error) {
+	u, err := url.Parse(c.BaseURL + "/users/me/threads")
+	if err != nil {
+		return nil, "", err
+	}
+	q := u.Query()
+	if query != "" {
+		q.Set("q", query)
+	}
+	if pageToken != "" {
+		q.Set("pageToken", pageToken)
+	}
+	if maxResults > 0 {
+		q.Set("maxResults", strconv.Itoa(maxResults))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("Gmail API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Threads       []GmailThreadRef `json:"threads"`
+		NextPageToken string           `json:"nextPageToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", err
+	}
+
+	return result.Threads, result.NextPageToken, nil
+}
+
+// GetThread fetches a thread with message metadata (headers and labels only)
+func (c *GmailClient) GetThread(threadID string) (*GmailThread, error) {
+	u, err := url.Parse(c.BaseURL + "/users/me/threads/" + url.PathEscape(threadID))
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("format", "metadata")
+	for _, header := range []string{"From", "To", "Subject"} {
+		q.Add("metadataHeaders", header)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Gmail API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var thread GmailThread
+	if err := json.NewDecoder(resp.Body).Decode(&thread); err != nil {
+		return nil, err
+	}
+
+	return &thread, nil
+}
+
+// GetGmailClient creates an HTTP client with the user's Gmail token, refreshing if necessary
+func GetGmailClient(userID int) (*http.Client, error) {
+	if gmailOAuthConfig == nil {
+		return nil, fmt.Errorf("Gmail OAuth not initialized")
+	}
+	client, _, err := services.IntegrationHTTPClient(gmailProvider{}, userID, 0)
+	return client, err
+}
+
+// SyncGmailSignals fetches recent inbox threads and saves them as signals
+func SyncGmailSignals(userID int) (services.SyncStats, error) {
+	var stats services.SyncStats
+	integration, err := services.GetIntegration(gmailProvider{}, userID, 0)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get integration: %w", err)
+	}
+
+	httpClient, err := GetGmailClient(userID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get Gmail client: %w", err)
+	}
+	client := newGmailAPIClient(httpClient)
+
+	metadata := map[string]interface{}{}
+	if integration.Metadata != "" {
+		_ = json.Unmarshal([]byte(integration.Metadata), &metadata)
+	}
+
+	// Only ask Gmail for threads with activity since the previous run.
+	query := gmailInitialSyncQuery
+	var lastSync int64
+	if value, ok := metadata["last_sync"].(float64); ok && value > 0 {
+		lastSync = int64(value)
+		query = fmt.Sprintf("in:inbox after:%d", lastSync)
+	}
+
+	syncStartedAt := time.Now()
+	threads, err := listGmailThreads(client, query)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list Gmail threads: %w", err)
+	}
+	stats.ItemsFetched = len(threads)
+
+	// Threads are listed newest first. last_sync only moves past threads
+	// older than the oldest one that failed, so the next sync retries it.
+	watermark := syncStartedAt.Unix()
+	var storedSinceFailure int64
+	failed := false
+	for _, ref := range threads {
+		thread, err := client.GetThread(ref.ID)
+		if err == nil {
+			err = saveGmailThreadAsSignal(userID, thread)
+		}
+		if err != nil {
+			log.Printf("Failed to sync Gmail thread %s: %v", ref.ID, err)
+			stats.ItemsFailed++
+			failed = true
+			storedSinceFailure = 0
+			continue
+		}
+		stats.ItemsUpserted++
+		if activity := gmailThreadActivity(thread); activity > storedSinceFailure {
+			storedSinceFailure = activity
+		}
+	}
+	if failed {
+		watermark = storedSinceFailure
+	}
+	if watermark <= lastSync {
+		return stats, nil
+	}
+
+	// Gmail is connected per user, so the integration has no workspace.
+	err = repository.Default().Integrations.UpdateMetadata(userID, gmailProvider{}.Name(), nil, func(metadata map[string]interface{}) {
+		metadata["last_sync"] = watermark
+	})
+	return stats, err
+}
+
+// listGmailThreads pages through every thread matching query.
+func listGmailThreads(client gmailAPIClient, query string) ([]GmailThreadRef, error) {
+	var threads []GmailThreadRef
+	pageToken := ""
+	for {
+		page, next, err := client.ListThreads(query, pageToken, gmailSyncMaxThreads)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, page...)
+		if next == "" {
+			return threads, nil
+		}
+		pageToken = next
+	}
+}
+
+// gmailThreadActivity returns the Unix time of a thread's newest message.
+func gmailThreadActivity(thread *GmailThread) int64 {
+	var newest int64
+	if thread == nil {
+		return newest
+	}
+	for _, msg := range thread.Messages {
+		if received := msg.ReceivedAt().Unix(); received > newest {
+			newest = received
+		}
+	}
+	return newest
+}
+
+// saveGmailThreadAsSignal upserts a thread as a signal, keyed on the Gmail thread ID
+func saveGmailThreadAsSignal(userID int, thread *GmailThread) error {
+	if thread == nil || len(thread.Messages) == 0 {
+		return nil
+	}
+
+	// The newest message carries the current subject, labels and participants.
+	latest := thread.Messages[len(thread.Messages)-1]
+
+	metadata := models.GmailMetadata{
+		ThreadID:     thread.ID,
+		MessageID:    latest.ID,
+		Labels:       latest.LabelIDs,
+		From:         latest.Header("From"),
+		To:           splitGmailAddressList(latest.Header("To")),
+		MessageCount: len(thread.Messages),
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	title := strings.TrimSpace(thread.Messages[0].Header("Subject"))
+	if title == "" {
+		title = "(no subject)"
+	}
+
+	_, err = repository.Default().Signals.UpsertSource(repository.SourceSignal{
+		UserID:     userID,
+		SourceType: models.SourceTypeGmail,
+		SourceID:   thread.ID,
+		ExternalID: latest.ID,
+		Title:      title,
+		Content:    latest.Snippet,
+		Body:       latest.Snippet,
+		URL:        "https://mail.google.com/mail/u/0/#inbox/" + thread.ID,
+		Author:     gmailSenderName(latest.Header("From")),
+		Status:     models.SignalStatusUnread,
+		Metadata:   string(metadataJSON),
+		ReceivedAt: latest.ReceivedAt(),
+	}, repository.SourceExternalID, repository.SourceTitle, repository.SourceContent, repository.SourceBody,
+		repository.SourceURL, repository.SourceAuthor, repository.SourceMetadata, repository.SourceReceivedAt)
+	return err
+}
+
+func splitGmailAddressList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	if addresses, err := mail.ParseAddressList(value); err == nil {
+		result := make([]string, 0, len(addresses))
+		for _, address := range addresses {
+			result = append(result, address.Address)
+		}
+		return result
+	}
+
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func gmailSenderName(from string) string {
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return from
+	}
+	if address.Name != "" {
+		return address.Name
+	}
+	return address.Address
+}
@@ -0,0 +1,258 @@
+package gmail
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"sentinent-backend/services"
+	"sentinent-backend/services/internal/servicestest"
+	"sentinent-backend/utils"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type mockGmailAPIClient struct {
+	threads map[string]*GmailThread
+	// pages lists thread IDs page by page; without it every thread is
+	// returned on one page.
+	pages   [][]string
+	failing map[string]bool
+	queries []string
+}
+
+func (m *mockGmailAPIClient) ListThreads(query, pageToken string, maxResults int) ([]GmailThreadRef, string, error) {
+	if pageToken == "" {
+		m.queries = append(m.queries, query)
+	}
+	if m.pages == nil {
+		refs := make([]GmailThreadRef, 0, len(m.threads))
+		for id := range m.threads {
+			refs = append(refs, GmailThreadRef{ID: id})
+		}
+		return refs, "", nil
+	}
+
+	page := 0
+	if pageToken != "" {
+		page, _ = strconv.Atoi(pageToken)
+	}
+	var refs []GmailThreadRef
+	for _, id := range m.pages[page] {
+		refs = append(refs, GmailThreadRef{ID: id})
+	}
+	next := ""
+	if page+1 < len(m.pages) {
+		next = strconv.Itoa(page + 1)
+	}
+	return refs, next, nil
+}
+
+func (m *mockGmailAPIClient) GetThread(threadID string) (*GmailThread, error) {
+	if m.failing[threadID] {
+		return nil, fmt.Errorf("thread %s unavailable", threadID)
+	}
+	return m.threads[threadID], nil
+}
+
+func newTestGmailMessage(id, threadID, subject, from, to, snippet string, labels []string) GmailMessage {
+	msg := GmailMessage{
+		ID:           id,
+		ThreadID:     threadID,
+		LabelIDs:     labels,
+		Snippet:      snippet,
+		InternalDate: "1700000000000",
+	}
+	for name, value := range map[string]string{"Subject": subject, "From": from, "To": to} {
+		msg.Payload.Headers = append(msg.Payload.Headers, struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}{Name: name, Value: value})
+	}
+	return msg
+}
+
+// seedGmailIntegration stores a Gmail integration for user 1 and makes
+// SyncGmailSignals use mock.
+func seedGmailIntegration(t *testing.T, mock *mockGmailAPIClient) {
+	t.Helper()
+
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		t.Fatalf("failed to create encryptor: %v", err)
+	}
+	encryptedAccess, _ := encryptor.Encrypt("gmail-access-token")
+	encryptedRefresh, _ := encryptor.Encrypt("gmail-refresh-token")
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, refresh_token, expires_at, metadata)
+		 VALUES (1, NULL, 'gmail', ?, ?, ?, '{"email":"me@example.com"}')`,
+		encryptedAccess, encryptedRefresh, time.Now().Add(time.Hour),
+	); err != nil {
+		t.Fatalf("failed to seed gmail integration: %v", err)
+	}
+
+	originalConfig := gmailOAuthConfig
+	originalFactory := newGmailAPIClient
+	gmailOAuthConfig = &oauth2.Config{ClientID: "id", ClientSecret: "secret"}
+	newGmailAPIClient = func(httpClient services.HTTPDoer) gmailAPIClient { return mock }
+	t.Cleanup(func() {
+		gmailOAuthConfig = originalConfig
+		newGmailAPIClient = originalFactory
+	})
+}
+
+func TestSyncGmailSignalsSavesThreadsAndDeduplicates(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	mock := &mockGmailAPIClient{threads: map[string]*GmailThread{
+		"thread-1": {ID: "thread-1", Messages: []GmailMessage{
+			newTestGmailMessage("msg-1", "thread-1", "Quarterly plan", "Alice <alice@example.com>", "me@example.com", "First message", []string{"INBOX"}),
+			newTestGmailMessage("msg-2", "thread-1", "Re: Quarterly plan", "Bob <bob@example.com>", "me@example.com, carol@example.com", "Latest reply", []string{"INBOX", "UNREAD"}),
+		}},
+	}}
+	seedGmailIntegration(t, mock)
+
+	if _, err := SyncGmailSignals(1); err != nil {
+		t.Fatalf("SyncGmailSignals returned error: %v", err)
+	}
+	if _, err := SyncGmailSignals(1); err != nil {
+		t.Fatalf("second SyncGmailSignals returned error: %v", err)
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals WHERE source_type = 'gmail'").Scan(&count); err != nil {
+		t.Fatalf("failed to count gmail signals: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected thread to be stored once, got %d signals", count)
+	}
+
+	var title, author, externalID, metadataJSON string
+	var workspaceID sql.NullInt64
+	if err := database.DB.QueryRow(
+		"SELECT title, author, external_id, source_metadata, workspace_id FROM signals WHERE source_id = 'thread-1'",
+	).Scan(&title, &author, &externalID, &metadataJSON, &workspaceID); err != nil {
+		t.Fatalf("failed to load gmail signal: %v", err)
+	}
+	if title != "Quarterly plan" || author != "Bob" || externalID != "msg-2" {
+		t.Fatalf("unexpected gmail signal: title=%q author=%q external_id=%q", title, author, externalID)
+	}
+	if workspaceID.Valid {
+		t.Fatalf("expected gmail signal without workspace, got %d", workspaceID.Int64)
+	}
+
+	var metadata models.GmailMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to decode gmail metadata: %v", err)
+	}
+	if metadata.ThreadID != "thread-1" || len(metadata.Labels) != 2 || len(metadata.To) != 2 || metadata.MessageCount != 2 {
+		t.Fatalf("unexpected gmail metadata: %+v", metadata)
+	}
+
+	if len(mock.queries) != 2 || mock.queries[0] != gmailInitialSyncQuery || mock.queries[1] == gmailInitialSyncQuery {
+		t.Fatalf("expected incremental query after first sync, got %v", mock.queries)
+	}
+}
+
+func TestSyncGmailSignalsPagesAndKeepsLastSyncBeforeFailedThread(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	thread := func(id string, receivedAt int64) *GmailThread {
+		msg := newTestGmailMessage(id+"-msg", id, "Subject "+id, "Alice <alice@example.com>", "me@example.com", "Body", []string{"INBOX"})
+		msg.InternalDate = strconv.FormatInt(receivedAt*1000, 10)
+		return &GmailThread{ID: id, Messages: []GmailMessage{msg}}
+	}
+	mock := &mockGmailAPIClient{
+		threads: map[string]*GmailThread{
+			"newest": thread("newest", 1700003000),
+			"broken": thread("broken", 1700002000),
+			"oldest": thread("oldest", 1700001000),
+		},
+		pages:   [][]string{{"newest", "broken"}, {"oldest"}},
+		failing: map[string]bool{"broken": true},
+	}
+	seedGmailIntegration(t, mock)
+
+	stats, err := SyncGmailSignals(1)
+	if err != nil {
+		t.Fatalf("SyncGmailSignals returned error: %v", err)
+	}
+	if stats.ItemsFetched != 3 || stats.ItemsUpserted != 2 || stats.ItemsFailed != 1 {
+		t.Fatalf("expected 3 fetched, 2 upserted and 1 failed thread, got %+v", stats)
+	}
+
+	var metadataJSON string
+	if err := database.DB.QueryRow("SELECT metadata FROM external_integrations WHERE provider = 'gmail'").Scan(&metadataJSON); err != nil {
+		t.Fatalf("failed to load gmail metadata: %v", err)
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to decode gmail metadata: %v", err)
+	}
+	if lastSync, _ := metadata["last_sync"].(float64); int64(lastSync) != 1700001000 {
+		t.Fatalf("expected last_sync to stop before the failed thread, got %v", metadata["last_sync"])
+	}
+
+	delete(mock.failing, "broken")
+	if _, err := SyncGmailSignals(1); err != nil {
+		t.Fatalf("second SyncGmailSignals returned error: %v", err)
+	}
+	if mock.queries[1] != "in:inbox after:1700001000" {
+		t.Fatalf("expected the retry to query from the checkpoint, got %q", mock.queries[1])
+	}
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals WHERE source_type = 'gmail'").Scan(&count); err != nil {
+		t.Fatalf("failed to count gmail signals: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected all 3 threads after the retry, got %d", count)
+	}
+}
+
+func TestGmailClientListsAndFetchesThreads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/me/threads":
+			if r.URL.Query().Get("q") != "in:inbox" {
+				t.Errorf("unexpected query %q", r.URL.Query().Get("q"))
+			}
+			_, _ = w.Write([]byte(`{"threads":[{"id":"t1","snippet":"hello"}],"nextPageToken":"page-2"}`))
+		case "/users/me/threads/t1":
+			if r.URL.Query().Get("format") != "metadata" {
+				t.Errorf("expected metadata format, got %q", r.URL.Query().Get("format"))
+			}
+			_, _ = w.Write([]byte(`{"id":"t1","messages":[{"id":"m1","threadId":"t1","labelIds":["INBOX"],"internalDate":"1700000000000","payload":{"headers":[{"name":"Subject","value":"Hi"}]}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := &GmailClient{HTTPClient: server.Client(), BaseURL: server.URL}
+
+	refs, next, err := client.ListThreads("in:inbox", "", 10)
+	if err != nil {
+		t.Fatalf("ListThreads returned error: %v", err)
+	}
+	if len(refs) != 1 || refs[0].ID != "t1" || next != "page-2" {
+		t.Fatalf("unexpected thread refs: %+v next=%q", refs, next)
+	}
+
+	thread, err := client.GetThread("t1")
+	if err != nil {
+		t.Fatalf("GetThread returned error: %v", err)
+	}
+	if len(thread.Messages) != 1 || thread.Messages[0].Header("subject") != "Hi" {
+		t.Fatalf("unexpected thread: %+v", thread)
+	}
+	if !thread.Messages[0].ReceivedAt().Equal(time.UnixMilli(1700000000000)) {
+		t.Fatalf("unexpected received time %v", thread.Messages[0].ReceivedAt())
+	}
+}
@@ -0,0 +1,130 @@
+package gmail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sentinent-backend/services"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const gmailUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
+func init() {
+	services.RegisterProvider(gmailProvider{})
+}
+
+// gmailProvider connects a user's Google account once for all of their
+// workspaces and syncs recent inbox threads.
+type gmailProvider struct{}
+
+type gmailProfile struct {
+	Email         string `json:"email"`
+	Name          string `json:"name"`
+	VerifiedEmail bool   `json:"verified_email"`
+}
+
+func (gmailProvider) Name() string                  { return "gmail" }
+func (gmailProvider) DisplayName() string           { return "Gmail" }
+func (gmailProvider) Scope() services.ProviderScope { return services.ScopeUser }
+func (gmailProvider) Init() error                   { return InitGmailService() }
+
+func (gmailProvider) Configured() bool {
+	return IsGmailConfigured() && services.TokenEncryptionConfigured()
+}
+
+func (gmailProvider) AuthURL(state, redirectURI string) string {
+	config := gmailRedirectConfig(redirectURI)
+	if config == nil {
+		return ""
+	}
+	return config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("prompt", "consent"))
+}
+
+// Exchange also fetches the Google profile so the connected address can be
+// shown to the user.
+func (gmailProvider) Exchange(ctx context.Context, code, redirectURI string) (*services.Grant, error) {
+	config := gmailRedirectConfig(redirectURI)
+	if config == nil {
+		return nil, fmt.Errorf("Gmail OAuth not initialized")
+	}
+	token, err := config.Exchange(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := fetchGmailProfile(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Gmail profile: %w", err)
+	}
+
+	metadata := map[string]interface{}{
+		"email":          profile.Email,
+		"name":           profile.Name,
+		"verified_email": profile.VerifiedEmail,
+	}
+	if scope, ok := token.Extra("scope").(string); ok && scope != "" {
+		metadata["scope"] = scope
+	}
+	return &services.Grant{Token: token, Metadata: metadata}, nil
+}
+
+func (gmailProvider) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	if gmailOAuthConfig == nil {
+		return nil, fmt.Errorf("Gmail OAuth not initialized")
+	}
+	return gmailOAuthConfig.TokenSource(ctx, token).Token()
+}
+
+func (gmailProvider) Sync(userID, workspaceID int) (services.SyncStats, error) {
+	return SyncGmailSignals(userID)
+}
+
+// gmailRedirectConfig returns the OAuth config with its callback set, letting
+// GOOGLE_REDIRECT_URI override the one derived from the request.
+func gmailRedirectConfig(redirectURI string) *oauth2.Config {
+	if gmailOAuthConfig == nil {
+		return nil
+	}
+	config := *gmailOAuthConfig
+	config.RedirectURL = redirectURI
+	if uri := strings.TrimSpace(os.Getenv("GOOGLE_REDIRECT_URI")); uri != "" {
+		config.RedirectURL = uri
+	}
+	return &config
+}
+
+func fetchGmailProfile(ctx context.Context, token *oauth2.Token) (*gmailProfile, error) {
+	if token == nil || token.AccessToken == "" {
+		return nil, fmt.Errorf("missing access token")
+	}
+
+	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(token))
+	req, err := http.NewRequest(http.MethodGet, gmailUserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gmail userinfo request failed with status %d", resp.StatusCode)
+	}
+
+	var profile gmailProfile
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(profile.Email) == "" {
+		return nil, fmt.Errorf("gmail profile did not include an email address")
+	}
+	return &profile, nil
+}
@@ -1,6 +1,7 @@
-package services
+package jira
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 )
@@ -45,3 +46,15 @@ func TestFormatDescriptionTruncatesLongText(t *testing.T) {
 		t.Fatalf("expected 500 character truncation with suffix, got length %d", len(got))
 	}
 }
+
+func TestJiraADFDocumentSplitsParagraphsAndLines(t *testing.T) {
+	doc, _ := json.Marshal(jiraADFDocument("Decided:\r\nship it\n\n\n\nOwner: ops"))
+
+	want := `{"content":[` +
+		`{"content":[{"text":"Decided:","type":"text"},{"type":"hardBreak"},{"text":"ship it","type":"text"}],"type":"paragraph"},` +
+		`{"content":[{"text":"Owner: ops","type":"text"}],"type":"paragraph"}` +
+		`],"type":"doc","version":1}`
+	if string(doc) != want {
+		t.Fatalf("unexpected ADF document:\n got %s\nwant %s", doc, want)
+	}
+}
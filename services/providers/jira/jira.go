@@ -0,0 +1,913 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	jiraOAuthConfig *oauth2.Config
+)
+
+// AtlassianResource represents an accessible Atlassian Cloud site
+type AtlassianResource struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	AvatarURL string   `json:"avatarUrl"`
+}
+
+// JiraIssue represents an issue retrieved from Jira REST API
+type JiraIssue struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Self   string `json:"self,omitempty"`
+	Fields struct {
+		Summary     string      `json:"summary"`
+		Description interface{} `json:"description"`
+		Status      struct {
+			Name string `json:"name"`
+		} `json:"status"`
+		Project struct {
+			Key  string `json:"key"`
+			Name string `json:"name"`
+		} `json:"project"`
+		Priority *struct {
+			Name string `json:"name"`
+		} `json:"priority"`
+		Issuetype struct {
+			Name string `json:"name"`
+		} `json:"issuetype"`
+		Assignee *struct {
+			AccountID   string `json:"accountId,omitempty"`
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Reporter *struct {
+			AccountID   string `json:"accountId,omitempty"`
+			DisplayName string `json:"displayName"`
+		} `json:"reporter"`
+		Labels  []string `json:"labels"`
+		Updated string   `json:"updated"`
+		Created string   `json:"created"`
+	} `json:"fields"`
+}
+
+// InitJiraService initializes the Jira OAuth configuration
+func InitJiraService() error {
+	clientID := strings.TrimSpace(os.Getenv("JIRA_CLIENT_ID"))
+	clientSecret := strings.TrimSpace(os.Getenv("JIRA_CLIENT_SECRET"))
+	baseURL := strings.TrimSpace(os.Getenv("API_BASE_URL"))
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	if clientID == "" || clientSecret == "" {
+		return fmt.Errorf("JIRA_CLIENT_ID and JIRA_CLIENT_SECRET must be set")
+	}
+
+	jiraOAuthConfig = &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  baseURL + "/api/integrations/jira/callback",
+		Scopes:       []string{"read:jira-work", "write:jira-work", "read:jira-user", "offline_access"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://auth.atlassian.com/authorize",
+			TokenURL: "https://auth.atlassian.com/oauth/token",
+		},
+	}
+
+	return nil
+}
+
+// IsJiraConfigured checks if Jira OAuth is configured
+func IsJiraConfigured() bool {
+	return jiraOAuthConfig != nil
+}
+
+// GetJiraAuthURL returns the OAuth authorization URL
+func GetJiraAuthURL(state string) string {
+	if jiraOAuthConfig == nil {
+		return ""
+	}
+	return jiraOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.SetAuthURLParam("audience", "api.atlassian.com"), oauth2.SetAuthURLParam("prompt", "consent"))
+}
+
+// ExchangeJiraCode exchanges the authorization code for an access token
+func ExchangeJiraCode(code string) (*oauth2.Token, error) {
+	if jiraOAuthConfig == nil {
+		return nil, fmt.Errorf("Jira OAuth not initialized")
+	}
+	return jiraOAuthConfig.Exchange(context.Background(), code)
+}
+
+// GetJiraClient creates an HTTP client with the user's Jira token, refreshing if necessary
+func GetJiraClient(userID, workspaceID int) (*http.Client, *oauth2.Token, error) {
+	return services.IntegrationHTTPClient(jiraProvider{}, userID, workspaceID)
+}
+
+// FetchAtlassianResources gets the accessible Cloud IDs for the user
+func FetchAtlassianResources(client *http.Client) ([]AtlassianResource, error) {
+	req, err := http.NewRequest("GET", "https://api.atlassian.com/oauth/token/accessible-resources", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, jiraAPIError(resp, "Atlassian")
+	}
+
+	var resources []AtlassianResource
+	if err := json.NewDecoder(resp.Body).Decode(&resources); err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// jiraSearchPageSize is the number of issues requested per search page.
+const jiraSearchPageSize = 100
+
+// jiraIssueFields are the issue fields read when storing issues as signals
+var jiraIssueFields = []string{
+	"summary",
+	"description",
+	"status",
+	"project",
+	"priority",
+	"issuetype",
+	"assignee",
+	"reporter",
+	"labels",
+	"updated",
+	"created",
+}
+
+// FetchJiraIssues requests every issue matching jql from a specific Jira cloud
+// site, following nextPageToken until the last page
+func FetchJiraIssues(client *http.Client, cloudId string, jql string) ([]JiraIssue, error) {
+	var issues []JiraIssue
+	pageToken := ""
+	for {
+		page, next, err := fetchJiraIssuesPage(client, cloudId, jql, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page...)
+		if next == "" {
+			return issues, nil
+		}
+		pageToken = next
+	}
+}
+
+func fetchJiraIssuesPage(client *http.Client, cloudId, jql, pageToken string) ([]JiraIssue, string, error) {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/search/jql", cloudId)
+
+	search := map[string]interface{}{
+		"jql":        jql,
+		"maxResults": jiraSearchPageSize,
+		"fields":     jiraIssueFields,
+	}
+	if pageToken != "" {
+		search["nextPageToken"] = pageToken
+	}
+	requestBody, _ := json.Marshal(search)
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", jiraAPIError(resp, "Jira")
+	}
+
+	var result struct {
+		Issues        []JiraIssue `json:"issues"`
+		NextPageToken string      `json:"nextPageToken"`
+		IsLast        bool        `json:"isLast"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", err
+	}
+
+	if result.IsLast {
+		return result.Issues, "", nil
+	}
+	return result.Issues, result.NextPageToken, nil
+}
+
+// JiraProject is a project on a Jira cloud site
+type JiraProject struct {
+	ID   string `json:"id"`
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// FetchJiraProjects lists every project visible on a Jira cloud site
+func FetchJiraProjects(client *http.Client, cloudId string) ([]JiraProject, error) {
+	var projects []JiraProject
+	for {
+		apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/project/search?startAt=%d&maxResults=50", cloudId, len(projects))
+		req, err := http.NewRequest("GET", apiURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := jiraAPIError(resp, "Jira")
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var result struct {
+			Values []JiraProject `json:"values"`
+			IsLast bool          `json:"isLast"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, result.Values...)
+		if result.IsLast || len(result.Values) == 0 {
+			return projects, nil
+		}
+	}
+}
+
+// ValidateJiraJQL asks a Jira cloud site to parse a query and returns the
+// problems it reports, if any
+func ValidateJiraJQL(client *http.Client, cloudId string, jql string) ([]string, error) {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/jql/parse?validation=strict", cloudId)
+
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"queries": []string{jql},
+	})
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, jiraAPIError(resp, "Jira")
+	}
+
+	var result struct {
+		Queries []struct {
+			Errors []string `json:"errors"`
+		} `json:"queries"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, query := range result.Queries {
+		problems = append(problems, query.Errors...)
+	}
+	return problems, nil
+}
+
+// parseADFToText recursively extracts raw text from an Atlassian Document Format (ADF) object
+func parseADFToText(node interface{}) string {
+	if node == nil {
+		return ""
+	}
+
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		// Attempt to handle slices if it's an array of nodes
+		if arr, isArr := node.([]interface{}); isArr {
+			var sb strings.Builder
+			for _, child := range arr {
+				childText := parseADFToText(child)
+				if childText != "" {
+					sb.WriteString(childText)
+				}
+			}
+			return sb.String()
+		}
+		return ""
+	}
+
+	nodeType, _ := m["type"].(string)
+
+	var sb strings.Builder
+
+	if nodeType == "text" {
+		if text, ok := m["text"].(string); ok {
+			sb.WriteString(text)
+		}
+	}
+
+	if contentObj, exists := m["content"]; exists {
+		if contentArr, ok := contentObj.([]interface{}); ok {
+			for _, child := range contentArr {
+				childText := parseADFToText(child)
+				if childText != "" {
+					sb.WriteString(childText)
+				}
+			}
+		}
+	}
+
+	// Add newlines after block elements for readability
+	if nodeType == "paragraph" || nodeType == "heading" || nodeType == "listItem" || nodeType == "codeBlock" {
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// formatDescription extracts text from Jira ADF (Atlassian Document Format)
+func formatDescription(desc interface{}) string {
+	if desc == nil {
+		return ""
+	}
+
+	text := strings.TrimSpace(parseADFToText(desc))
+
+	if len(text) > 500 {
+		return text[:500] + "..."
+	}
+	if text == "" {
+		// Fallback to JSON if parsing failed completely
+		bytes, err := json.Marshal(desc)
+		if err == nil {
+			str := string(bytes)
+			if len(str) > 500 {
+				return str[:500] + "..."
+			}
+			return str
+		}
+	}
+	return text
+}
+
+// GetJiraCloudID returns the first accessible cloud ID
+func GetJiraCloudID(client *http.Client) (string, error) {
+	resources, err := FetchAtlassianResources(client)
+	if err != nil {
+		return "", err
+	}
+	if len(resources) == 0 {
+		return "", fmt.Errorf("no accessible Atlassian resources found")
+	}
+	return resources[0].ID, nil
+}
+
+// JiraUser represents the Jira account behind an OAuth token
+type JiraUser struct {
+	AccountID   string `json:"accountId"`
+	DisplayName string `json:"displayName"`
+}
+
+// FetchJiraCurrentUser returns the account the token acts as on a cloud site
+func FetchJiraCurrentUser(client *http.Client, cloudId string) (*JiraUser, error) {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/myself", cloudId)
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, jiraAPIError(resp, "Jira")
+	}
+
+	var user JiraUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// JiraTransition represents an available transition for a Jira issue
+type JiraTransition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+// GetAvailableTransitions fetches transitions for a specific issue
+func GetAvailableTransitions(client *http.Client, cloudId string, issueKey string) ([]JiraTransition, error) {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/issue/%s/transitions", cloudId, issueKey)
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, jiraAPIError(resp, "Jira")
+	}
+
+	var result struct {
+		Transitions []JiraTransition `json:"transitions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result.Transitions, nil
+}
+
+// PerformTransition executes a status change on a specific issue
+func PerformTransition(client *http.Client, cloudId string, issueKey string, transitionID string) error {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/issue/%s/transitions", cloudId, issueKey)
+
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"transition": map[string]string{
+			"id": transitionID,
+		},
+	})
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 204 No Content is expected for successful transition
+	if resp.StatusCode != http.StatusNoContent {
+		return jiraAPIError(resp, "Jira")
+	}
+
+	return nil
+}
+
+// AddJiraComment adds a comment to a specific issue
+func AddJiraComment(client *http.Client, cloudId string, issueKey string, commentText string) error {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/issue/%s/comment", cloudId, issueKey)
+
+	// Jira API v3 expects ADF for comments.
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"body": jiraADFDocument(commentText),
+	})
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return jiraAPIError(resp, "Jira")
+	}
+
+	return nil
+}
+
+// jiraADFDocument converts plain text to an Atlassian Document Format
+// document. Blank lines separate paragraphs and single newlines become hard
+// breaks.
+func jiraADFDocument(text string) map[string]interface{} {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	paragraphs := []map[string]interface{}{}
+	for _, block := range strings.Split(text, "\n\n") {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		content := []map[string]interface{}{}
+		for i, line := range strings.Split(block, "\n") {
+			if i > 0 {
+				content = append(content, map[string]interface{}{"type": "hardBreak"})
+			}
+			if line != "" {
+				content = append(content, map[string]interface{}{"type": "text", "text": line})
+			}
+		}
+		paragraphs = append(paragraphs, map[string]interface{}{
+			"type":    "paragraph",
+			"content": content,
+		})
+	}
+
+	return map[string]interface{}{
+		"version": 1,
+		"type":    "doc",
+		"content": paragraphs,
+	}
+}
+
+// JiraIssueInput describes an issue to create. Description is plain text and
+// is sent as ADF; IssueType defaults to Task.
+type JiraIssueInput struct {
+	ProjectKey  string   `json:"project_key"`
+	IssueType   string   `json:"issue_type"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	AssigneeID  string   `json:"assignee_account_id"`
+	Priority    string   `json:"priority"`
+	Labels      []string `json:"labels"`
+}
+
+// JiraIssueUpdate lists the fields to change on an issue. Nil fields are left
+// alone, an empty AssigneeID unassigns the issue, and Labels replaces the
+// labels while AddLabels and RemoveLabels edit them.
+type JiraIssueUpdate struct {
+	Summary      *string   `json:"summary"`
+	Description  *string   `json:"description"`
+	AssigneeID   *string   `json:"assignee_account_id"`
+	Priority     *string   `json:"priority"`
+	Labels       *[]string `json:"labels"`
+	AddLabels    []string  `json:"add_labels"`
+	RemoveLabels []string  `json:"remove_labels"`
+}
+
+// Empty reports whether the update changes nothing
+func (u JiraIssueUpdate) Empty() bool {
+	return u.Summary == nil && u.Description == nil && u.AssigneeID == nil && u.Priority == nil &&
+		u.Labels == nil && len(u.AddLabels) == 0 && len(u.RemoveLabels) == 0
+}
+
+// CreateJiraIssue creates an issue on a Jira cloud site and returns its key
+func CreateJiraIssue(client *http.Client, cloudId string, input JiraIssueInput) (string, error) {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/issue", cloudId)
+
+	issueType := input.IssueType
+	if issueType == "" {
+		issueType = "Task"
+	}
+	fields := map[string]interface{}{
+		"project":   map[string]string{"key": input.ProjectKey},
+		"issuetype": map[string]string{"name": issueType},
+		"summary":   input.Summary,
+	}
+	if strings.TrimSpace(input.Description) != "" {
+		fields["description"] = jiraADFDocument(input.Description)
+	}
+	if input.AssigneeID != "" {
+		fields["assignee"] = map[string]string{"accountId": input.AssigneeID}
+	}
+	if input.Priority != "" {
+		fields["priority"] = map[string]string{"name": input.Priority}
+	}
+	if len(input.Labels) > 0 {
+		fields["labels"] = input.Labels
+	}
+
+	requestBody, _ := json.Marshal(map[string]interface{}{"fields": fields})
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", jiraAPIError(resp, "Jira")
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+// UpdateJiraIssue edits fields on an existing issue
+func UpdateJiraIssue(client *http.Client, cloudId string, issueKey string, update JiraIssueUpdate) error {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/issue/%s", cloudId, issueKey)
+
+	fields := map[string]interface{}{}
+	if update.Summary != nil {
+		fields["summary"] = *update.Summary
+	}
+	if update.Description != nil {
+		if strings.TrimSpace(*update.Description) == "" {
+			fields["description"] = nil
+		} else {
+			fields["description"] = jiraADFDocument(*update.Description)
+		}
+	}
+	if update.AssigneeID != nil {
+		if *update.AssigneeID == "" {
+			fields["assignee"] = nil
+		} else {
+			fields["assignee"] = map[string]string{"accountId": *update.AssigneeID}
+		}
+	}
+	if update.Priority != nil {
+		fields["priority"] = map[string]string{"name": *update.Priority}
+	}
+	if update.Labels != nil {
+		fields["labels"] = *update.Labels
+	}
+
+	body := map[string]interface{}{"fields": fields}
+	var labelOps []map[string]string
+	for _, label := range update.AddLabels {
+		labelOps = append(labelOps, map[string]string{"add": label})
+	}
+	for _, label := range update.RemoveLabels {
+		labelOps = append(labelOps, map[string]string{"remove": label})
+	}
+	if len(labelOps) > 0 {
+		body["update"] = map[string]interface{}{"labels": labelOps}
+	}
+
+	requestBody, _ := json.Marshal(body)
+
+	req, err := http.NewRequest("PUT", apiURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return jiraAPIError(resp, "Jira")
+	}
+
+	return nil
+}
+
+// FetchJiraIssue reads a single issue with the fields stored on its signal
+func FetchJiraIssue(client *http.Client, cloudId string, issueKey string) (*JiraIssue, error) {
+	apiURL := fmt.Sprintf("https://api.atlassian.com/ex/jira/%s/rest/api/3/issue/%s?fields=%s",
+		cloudId, issueKey, strings.Join(jiraIssueFields, ","))
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, jiraAPIError(resp, "Jira")
+	}
+
+	var issue JiraIssue
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+func parseJiraDate(dateStr string) time.Time {
+	t, err := time.Parse("2006-01-02T15:04:05.000-0700", dateStr)
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
+// SyncJiraSignals fetches and saves the Jira issues matching the workspace's
+// query from each selected site
+func SyncJiraSignals(userID, workspaceID int) (services.SyncStats, error) {
+	var stats services.SyncStats
+	client, _, err := GetJiraClient(userID, workspaceID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get Jira client: %w", err)
+	}
+	integration, err := services.GetIntegration(jiraProvider{}, userID, workspaceID)
+	if err != nil {
+		return stats, fmt.Errorf("failed to load Jira integration: %w", err)
+	}
+	settings := loadJiraSettings(integration.Metadata)
+
+	resources, err := FetchAtlassianResources(client)
+	if err != nil {
+		return stats, fmt.Errorf("no accessible Atlassian resources found: %w", err)
+	}
+	sites, err := jiraSitesToSync(resources, settings)
+	if err != nil {
+		return stats, err
+	}
+
+	// Remember the sites and account so webhook deliveries can be routed back
+	// to this integration. The account is the same on every site.
+	accountID := ""
+	if currentUser, err := FetchJiraCurrentUser(client, sites[0].resource.ID); err == nil {
+		accountID = currentUser.AccountID
+	} else {
+		fmt.Printf("Warning: failed to fetch Jira account: %v\n", err)
+	}
+	if err := saveJiraSiteMetadata(userID, workspaceID, sites, accountID); err != nil {
+		fmt.Printf("Warning: failed to save Jira site metadata: %v\n", err)
+	}
+
+	var syncErr error
+	for _, site := range sites {
+		issues, err := FetchJiraIssues(client, site.resource.ID, buildJiraJQL(settings.JQL, site.projectKeys))
+		if err != nil {
+			err = fmt.Errorf("failed to fetch Jira issues from %s: %w", site.resource.Name, err)
+			if services.IsReauthRequired(err) {
+				return stats, err
+			}
+			// Keep syncing the other sites; the run still reports the failure.
+			if syncErr == nil {
+				syncErr = err
+			}
+			continue
+		}
+		stats.ItemsFetched += len(issues)
+
+		for _, issue := range issues {
+			if err := saveJiraIssueAsSignal(userID, workspaceID, issue, site.resource.ID, site.resource.URL); err != nil {
+				fmt.Printf("Failed to save Jira signal: %v\n", err)
+				continue
+			}
+			stats.ItemsUpserted++
+		}
+	}
+
+	return stats, syncErr
+}
+
+// saveJiraSiteMetadata records the synced sites along with the connected
+// account ID, preserving any other metadata keys. It replaces the single-site
+// cloud_id and cloud_url keys written by earlier versions.
+func saveJiraSiteMetadata(userID, workspaceID int, sites []jiraSyncSite, accountID string) error {
+	synced := make([]jiraSyncedSite, 0, len(sites))
+	for _, site := range sites {
+		synced = append(synced, jiraSyncedSite{
+			CloudID:     site.resource.ID,
+			CloudURL:    site.resource.URL,
+			Name:        site.resource.Name,
+			ProjectKeys: site.projectKeys,
+		})
+	}
+
+	return repository.Default().Integrations.UpdateMetadata(userID, "jira", &workspaceID, func(metadata map[string]interface{}) {
+		metadata["synced_sites"] = synced
+		delete(metadata, "cloud_id")
+		delete(metadata, "cloud_url")
+		delete(metadata, "site_name")
+		if accountID != "" {
+			metadata["account_id"] = accountID
+		}
+	})
+}
+
+// jiraSignalSourceID identifies an issue's signal. Issue IDs are only unique
+// within a site, so the site's cloud ID is part of it.
+func jiraSignalSourceID(cloudID, issueID string) string {
+	return cloudID + ":" + issueID
+}
+
+func saveJiraIssueAsSignal(userID, workspaceID int, issue JiraIssue, cloudID, cloudURL string) error {
+	priorityName := ""
+	if issue.Fields.Priority != nil {
+		priorityName = issue.Fields.Priority.Name
+	}
+
+	assigneeName := ""
+	if issue.Fields.Assignee != nil {
+		assigneeName = issue.Fields.Assignee.DisplayName
+	}
+
+	reporterName := ""
+	if issue.Fields.Reporter != nil {
+		reporterName = issue.Fields.Reporter.DisplayName
+	}
+
+	metadata := models.JiraMetadata{
+		ProjectKey:   issue.Fields.Project.Key,
+		IssueType:    issue.Fields.Issuetype.Name,
+		Priority:     priorityName,
+		Status:       issue.Fields.Status.Name,
+		IssueKey:     issue.Key,
+		AssigneeName: assigneeName,
+		Labels:       issue.Fields.Labels,
+	}
+
+	metadataJSON, _ := json.Marshal(metadata)
+
+	url := fmt.Sprintf("%s/browse/%s", cloudURL, issue.Key)
+	createdAt := parseJiraDate(issue.Fields.Created)
+	updatedAt := parseJiraDate(issue.Fields.Updated)
+
+	sourceID := jiraSignalSourceID(cloudID, issue.ID)
+	created, err := repository.Default().Signals.UpsertSource(repository.SourceSignal{
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		SourceType:  models.SourceTypeJira,
+		SourceID:    sourceID,
+		ExternalID:  issue.Key,
+		Title:       fmt.Sprintf("[%s] %s", issue.Key, issue.Fields.Summary),
+		Content:     formatDescription(issue.Fields.Description),
+		URL:         url,
+		Author:      reporterName,
+		Status:      issue.Fields.Status.Name,
+		Metadata:    string(metadataJSON),
+		ReceivedAt:  createdAt,
+		UpdatedAt:   updatedAt,
+	}, repository.SourceExternalID, repository.SourceTitle, repository.SourceContent, repository.SourceURL,
+		repository.SourceAuthor, repository.SourceStatus, repository.SourceMetadata)
+	if err != nil {
+		return err
+	}
+
+	services.SignalUpserted(workspaceID, models.SourceTypeJira, sourceID, created)
+	return nil
+}
+
+// jiraAPIError reads a failed Atlassian response into an error. A 401 means
+// the access was revoked.
+func jiraAPIError(resp *http.Response, api string) error {
+	body, _ := io.ReadAll(resp.Body)
+	err := fmt.Errorf("%s API error: %d - %s", api, resp.StatusCode, string(body))
+	if resp.StatusCode == http.StatusUnauthorized {
+		return &services.ReauthRequiredError{Provider: "Jira", Err: err}
+	}
+	return err
+}
@@ -0,0 +1,321 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sentinent-backend/services"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+func init() {
+	services.RegisterProvider(jiraProvider{})
+}
+
+// jiraProvider connects Atlassian accounts per workspace and syncs the Jira
+// issues the account is assigned to or reported.
+type jiraProvider struct{}
+
+func (jiraProvider) Name() string                  { return "jira" }
+func (jiraProvider) DisplayName() string           { return "Jira" }
+func (jiraProvider) Scope() services.ProviderScope { return services.ScopeWorkspace }
+func (jiraProvider) Init() error                   { return InitJiraService() }
+func (jiraProvider) Configured() bool              { return IsJiraConfigured() }
+
+// AuthURL ignores redirectURI; the callback is derived from API_BASE_URL in
+// InitJiraService because Atlassian requires it to match the app settings.
+func (jiraProvider) AuthURL(state, redirectURI string) string {
+	return GetJiraAuthURL(state)
+}
+
+func (jiraProvider) Exchange(ctx context.Context, code, redirectURI string) (*services.Grant, error) {
+	token, err := ExchangeJiraCode(code)
+	if err != nil {
+		return nil, err
+	}
+	return &services.Grant{Token: token}, nil
+}
+
+func (jiraProvider) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	if jiraOAuthConfig == nil {
+		return nil, fmt.Errorf("Jira OAuth not initialized")
+	}
+	return jiraOAuthConfig.TokenSource(ctx, token).Token()
+}
+
+func (jiraProvider) Sync(userID, workspaceID int) (services.SyncStats, error) {
+	return SyncJiraSignals(userID, workspaceID)
+}
+
+func (jiraProvider) Actions() []services.ProviderAction {
+	return []services.ProviderAction{
+		{Path: "projects", Handle: jiraProjectsAction},
+		{Path: "settings", Handle: jiraSettingsAction},
+		{Path: "issues", Handle: jiraCreateIssueAction},
+		{Path: "issues/", Handle: jiraIssueAction},
+	}
+}
+
+// jiraProjectsAction lists the Atlassian sites the connected account can
+// access, each with its projects.
+func jiraProjectsAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !services.RequireActionWorkspace(w, action) {
+		return
+	}
+
+	client, _, err := GetJiraClient(action.UserID, action.WorkspaceID)
+	if err != nil {
+		http.Error(w, "Failed to get Jira client: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resources, err := FetchAtlassianResources(client)
+	if err != nil {
+		http.Error(w, "Failed to fetch Atlassian resources: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sites := make([]jiraSiteProjects, 0, len(resources))
+	for _, resource := range resources {
+		projects, err := FetchJiraProjects(client, resource.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch Jira projects: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if projects == nil {
+			projects = []JiraProject{}
+		}
+		sites = append(sites, jiraSiteProjects{AtlassianResource: resource, Projects: projects})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sites)
+}
+
+// jiraActionSite picks the site an issue action runs against: the cloud_id
+// query parameter, else the first selected site, else the first accessible
+// one. It writes the error response and returns false when there is none.
+func jiraActionSite(w http.ResponseWriter, r *http.Request, client *http.Client, action services.ActionContext) (AtlassianResource, bool) {
+	resources, err := FetchAtlassianResources(client)
+	if err != nil {
+		http.Error(w, "Failed to fetch Atlassian resources: "+err.Error(), http.StatusInternalServerError)
+		return AtlassianResource{}, false
+	}
+	if len(resources) == 0 {
+		http.Error(w, "No accessible Jira sites", http.StatusBadRequest)
+		return AtlassianResource{}, false
+	}
+
+	cloudID := r.URL.Query().Get("cloud_id")
+	if cloudID == "" {
+		if integration, err := services.GetIntegration(jiraProvider{}, action.UserID, action.WorkspaceID); err == nil {
+			if settings := loadJiraSettings(integration.Metadata); len(settings.Sites) > 0 {
+				cloudID = settings.Sites[0].CloudID
+			}
+		}
+	}
+	if cloudID == "" {
+		return resources[0], true
+	}
+
+	for _, resource := range resources {
+		if resource.ID == cloudID {
+			return resource, true
+		}
+	}
+	http.Error(w, "Jira site "+cloudID+" is not accessible", http.StatusBadRequest)
+	return AtlassianResource{}, false
+}
+
+// upsertJiraIssueSignal reads an issue back from Jira and stores it as a
+// signal, so changes made from Sentinent show up without waiting for a sync.
+func upsertJiraIssueSignal(client *http.Client, action services.ActionContext, site AtlassianResource, issueKey string) (*JiraIssue, error) {
+	issue, err := FetchJiraIssue(client, site.ID, issueKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveJiraIssueAsSignal(action.UserID, action.WorkspaceID, *issue, site.ID, site.URL); err != nil {
+		return nil, err
+	}
+	return issue, nil
+}
+
+// jiraCreateIssueAction creates an issue (POST) on the selected site and
+// stores it as a signal straight away.
+func jiraCreateIssueAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !services.RequireActionWorkspace(w, action) {
+		return
+	}
+
+	var input JiraIssueInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	input.ProjectKey = strings.TrimSpace(input.ProjectKey)
+	input.IssueType = strings.TrimSpace(input.IssueType)
+	input.Summary = strings.TrimSpace(input.Summary)
+	if input.ProjectKey == "" {
+		http.Error(w, "project_key is required", http.StatusBadRequest)
+		return
+	}
+	if input.Summary == "" {
+		http.Error(w, "summary is required", http.StatusBadRequest)
+		return
+	}
+
+	client, _, err := GetJiraClient(action.UserID, action.WorkspaceID)
+	if err != nil {
+		http.Error(w, "Failed to get Jira client: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	site, ok := jiraActionSite(w, r, client, action)
+	if !ok {
+		return
+	}
+
+	issueKey, err := CreateJiraIssue(client, site.ID, input)
+	if err != nil {
+		http.Error(w, "Failed to create issue: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	issue, err := upsertJiraIssueSignal(client, action, site, issueKey)
+	if err != nil {
+		http.Error(w, "Issue "+issueKey+" was created but could not be stored: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(issue)
+}
+
+// jiraIssueAction handles issues/{key} (PATCH), issues/{key}/transitions
+// (GET, POST) and issues/{key}/comments (POST). The optional cloud_id query
+// parameter names the issue's site; it defaults to the first selected or
+// accessible site.
+func jiraIssueAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	if !services.RequireActionWorkspace(w, action) {
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/integrations/jira/issues/"), "/")
+	parts := strings.Split(path, "/")
+	if parts[0] == "" || len(parts) > 2 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	client, _, err := GetJiraClient(action.UserID, action.WorkspaceID)
+	if err != nil {
+		http.Error(w, "Failed to get Jira client: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	site, ok := jiraActionSite(w, r, client, action)
+	if !ok {
+		return
+	}
+	cloudId := site.ID
+
+	issueKey := parts[0]
+	if len(parts) == 1 {
+		if r.Method != http.MethodPatch {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var update JiraIssueUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if update.Empty() {
+			http.Error(w, "No fields to update", http.StatusBadRequest)
+			return
+		}
+		if update.Summary != nil && strings.TrimSpace(*update.Summary) == "" {
+			http.Error(w, "summary must not be empty", http.StatusBadRequest)
+			return
+		}
+		if err := UpdateJiraIssue(client, cloudId, issueKey, update); err != nil {
+			http.Error(w, "Failed to update issue: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		issue, err := upsertJiraIssueSignal(client, action, site, issueKey)
+		if err != nil {
+			http.Error(w, "Issue "+issueKey+" was updated but could not be stored: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(issue)
+		return
+	}
+
+	switch parts[1] {
+	case "transitions":
+		if r.Method == http.MethodGet {
+			transitions, err := GetAvailableTransitions(client, cloudId, issueKey)
+			if err != nil {
+				http.Error(w, "Failed to fetch transitions: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(transitions)
+			return
+		} else if r.Method == http.MethodPost {
+			var reqBody struct {
+				TransitionID string `json:"transitionId"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := PerformTransition(client, cloudId, issueKey, reqBody.TransitionID); err != nil {
+				http.Error(w, "Failed to perform transition: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			// Queue a sync to reflect the change quickly
+			if integration, err := services.GetIntegration(jiraProvider{}, action.UserID, action.WorkspaceID); err == nil {
+				if _, err := services.EnqueueSync(*integration); err != nil {
+					log.Printf("Failed to queue Jira sync after transition: %v", err)
+				}
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	case "comments":
+		if r.Method == http.MethodPost {
+			var reqBody struct {
+				Body string `json:"body"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := AddJiraComment(client, cloudId, issueKey, reqBody.Body); err != nil {
+				http.Error(w, "Failed to add comment: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+	}
+
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,125 @@
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"sentinent-backend/services"
+)
+
+func loadJiraSignal(t *testing.T, sourceID string) (string, string, models.JiraMetadata) {
+	t.Helper()
+
+	var title, url, rawMetadata string
+	if err := database.DB.QueryRow(
+		"SELECT title, url, source_metadata FROM signals WHERE source_type = 'jira' AND source_id = ?",
+		sourceID,
+	).Scan(&title, &url, &rawMetadata); err != nil {
+		t.Fatalf("failed to load Jira signal %s: %v", sourceID, err)
+	}
+	var metadata models.JiraMetadata
+	if err := json.Unmarshal([]byte(rawMetadata), &metadata); err != nil {
+		t.Fatalf("failed to decode Jira metadata: %v", err)
+	}
+	return title, url, metadata
+}
+
+func TestJiraCreateIssueActionStoresSignal(t *testing.T) {
+	fake := setupJiraSettingsTest(t, `{}`)
+	action := services.ActionContext{UserID: 1, WorkspaceID: 10}
+
+	create := func(query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/integrations/jira/issues?workspace_id=10"+query, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		jiraCreateIssueAction(rr, req, action)
+		return rr
+	}
+
+	if rr := create("", `{"project_key":"OPS"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a summary, got %d", rr.Code)
+	}
+	if rr := create("&cloud_id=cloud-9", `{"project_key":"OPS","summary":"Follow up"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an inaccessible site, got %d", rr.Code)
+	}
+
+	rr := create("&cloud_id=cloud-2", `{"project_key":"OPS","summary":"Roll out the new pricing","description":"Decided in review.\n\nOwner: billing",
+		"assignee_account_id":"acc-7","priority":"High","labels":["decision"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created JiraIssue
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode created issue: %v", err)
+	}
+	if created.Key != "OPS-50" {
+		t.Fatalf("unexpected created issue %+v", created)
+	}
+
+	stored := fake.issues["OPS-50"]
+	fields := stored["fields"].(map[string]any)
+	if stored["cloud_id"] != "cloud-2" || fields["issuetype"].(map[string]any)["name"] != "Task" ||
+		fields["assignee"].(map[string]any)["accountId"] != "acc-7" || fields["priority"].(map[string]any)["name"] != "High" {
+		t.Fatalf("unexpected issue sent to Jira: %v", stored)
+	}
+	if paragraphs := fields["description"].(map[string]any)["content"].([]any); len(paragraphs) != 2 {
+		t.Fatalf("expected the description as two ADF paragraphs, got %v", fields["description"])
+	}
+
+	title, url, metadata := loadJiraSignal(t, "cloud-2:10050")
+	if title != "[OPS-50] Roll out the new pricing" || url != "https://labs.atlassian.net/browse/OPS-50" {
+		t.Fatalf("unexpected signal title=%q url=%q", title, url)
+	}
+	if metadata.Priority != "High" || strings.Join(metadata.Labels, ",") != "decision" {
+		t.Fatalf("unexpected signal metadata %+v", metadata)
+	}
+}
+
+func TestJiraIssueActionEditsFields(t *testing.T) {
+	fake := setupJiraSettingsTest(t, `{}`)
+	action := services.ActionContext{UserID: 1, WorkspaceID: 10}
+
+	createReq := httptest.NewRequest(http.MethodPost, "/api/integrations/jira/issues?workspace_id=10",
+		bytes.NewBufferString(`{"project_key":"OPS","summary":"Draft","assignee_account_id":"acc-7","labels":["decision"]}`))
+	createRR := httptest.NewRecorder()
+	jiraCreateIssueAction(createRR, createReq, action)
+	if createRR.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", createRR.Code, createRR.Body.String())
+	}
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/integrations/jira/issues/OPS-50?workspace_id=10", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		jiraIssueAction(rr, req, action)
+		return rr
+	}
+
+	if rr := patch(`{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty update, got %d", rr.Code)
+	}
+	rr := patch(`{"summary":"Roll out pricing","assignee_account_id":"","add_labels":["follow-up"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if len(fake.edits) != 1 {
+		t.Fatalf("expected one edit sent to Jira, got %d", len(fake.edits))
+	}
+	edit := fake.edits[0]
+	if assignee, ok := edit["fields"].(map[string]any)["assignee"]; !ok || assignee != nil {
+		t.Fatalf("expected the assignee to be cleared, got %v", edit)
+	}
+	if _, ok := edit["fields"].(map[string]any)["labels"]; ok {
+		t.Fatalf("expected add_labels to leave existing labels alone, got %v", edit)
+	}
+
+	title, _, metadata := loadJiraSignal(t, "cloud-1:10050")
+	if title != "[OPS-50] Roll out pricing" || metadata.AssigneeName != "" || strings.Join(metadata.Labels, ",") != "decision,follow-up" {
+		t.Fatalf("expected the signal to reflect the edit, got title=%q metadata=%+v", title, metadata)
+	}
+}
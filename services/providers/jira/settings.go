@@ -0,0 +1,204 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sentinent-backend/services"
+	"strings"
+)
+
+// defaultJiraJQL is synced when a workspace has not set its own query.
+const defaultJiraJQL = "assignee = currentUser() OR reporter = currentUser()"
+
+// jiraSettings is a workspace's choice of Jira sites, projects and query. It
+// is stored in the integration metadata under "sites" and "jql".
+type jiraSettings struct {
+	Sites []jiraSiteSettings `json:"sites"`
+	JQL   string             `json:"jql"`
+}
+
+// jiraSiteSettings selects a site and, optionally, the projects synced from it.
+type jiraSiteSettings struct {
+	CloudID     string   `json:"cloud_id"`
+	ProjectKeys []string `json:"project_keys,omitempty"`
+}
+
+// jiraSyncedSite records a site read by the last sync, so webhook deliveries
+// can be routed back to the integration. Stored under "synced_sites".
+type jiraSyncedSite struct {
+	CloudID     string   `json:"cloud_id"`
+	CloudURL    string   `json:"cloud_url"`
+	Name        string   `json:"name,omitempty"`
+	ProjectKeys []string `json:"project_keys,omitempty"`
+}
+
+// jiraSyncSite is an accessible site to sync and its selected projects.
+type jiraSyncSite struct {
+	resource    AtlassianResource
+	projectKeys []string
+}
+
+// jiraSiteProjects is one entry of the projects action: a site and the
+// projects that can be selected on it.
+type jiraSiteProjects struct {
+	AtlassianResource
+	Projects []JiraProject `json:"projects"`
+}
+
+func loadJiraSettings(metadataJSON string) jiraSettings {
+	var settings jiraSettings
+	if metadataJSON != "" {
+		_ = json.Unmarshal([]byte(metadataJSON), &settings)
+	}
+	return settings
+}
+
+// jiraSitesToSync pairs the selected sites with the accessible ones. Without a
+// selection the first accessible site is synced.
+func jiraSitesToSync(resources []AtlassianResource, settings jiraSettings) ([]jiraSyncSite, error) {
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no accessible Atlassian resources found")
+	}
+	if len(settings.Sites) == 0 {
+		return []jiraSyncSite{{resource: resources[0]}}, nil
+	}
+
+	accessible := make(map[string]AtlassianResource, len(resources))
+	for _, resource := range resources {
+		accessible[resource.ID] = resource
+	}
+
+	sites := make([]jiraSyncSite, 0, len(settings.Sites))
+	for _, selected := range settings.Sites {
+		resource, ok := accessible[selected.CloudID]
+		if !ok {
+			log.Printf("Skipping Jira site %s: no longer accessible", selected.CloudID)
+			continue
+		}
+		sites = append(sites, jiraSyncSite{resource: resource, projectKeys: selected.ProjectKeys})
+	}
+	if len(sites) == 0 {
+		return nil, fmt.Errorf("none of the selected Jira sites are accessible")
+	}
+	return sites, nil
+}
+
+// buildJiraJQL narrows the workspace query, or the default one, to the
+// selected projects. An ORDER BY clause in the query is kept at the end.
+func buildJiraJQL(custom string, projectKeys []string) string {
+	query := strings.TrimSpace(custom)
+	if query == "" {
+		query = defaultJiraJQL
+	}
+
+	orderBy := "ORDER BY updated DESC"
+	if i := strings.LastIndex(strings.ToLower(query), "order by"); i >= 0 {
+		orderBy = strings.TrimSpace(query[i:])
+		query = strings.TrimSpace(query[:i])
+	}
+
+	if len(projectKeys) > 0 {
+		quoted := make([]string, len(projectKeys))
+		for i, key := range projectKeys {
+			quoted[i] = `"` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+		}
+		projects := "project in (" + strings.Join(quoted, ", ") + ")"
+		if query == "" {
+			query = projects
+		} else {
+			query = "(" + query + ") AND " + projects
+		}
+	}
+
+	return strings.TrimSpace(query + " " + orderBy)
+}
+
+// jiraSettingsAction returns (GET) or replaces (PATCH) the workspace's Jira
+// sites, projects and query. The query is checked by each selected site
+// before it is saved.
+func jiraSettingsAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !services.RequireActionWorkspace(w, action) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		integration, err := services.GetIntegration(jiraProvider{}, action.UserID, action.WorkspaceID)
+		if err != nil {
+			http.Error(w, "Integration not found", http.StatusNotFound)
+			return
+		}
+		settings := loadJiraSettings(integration.Metadata)
+		if settings.Sites == nil {
+			settings.Sites = []jiraSiteSettings{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(settings)
+		return
+	}
+
+	var settings jiraSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	settings.JQL = strings.TrimSpace(settings.JQL)
+	for _, site := range settings.Sites {
+		if strings.TrimSpace(site.CloudID) == "" {
+			http.Error(w, "cloud_id is required for each site", http.StatusBadRequest)
+			return
+		}
+	}
+
+	client, _, err := GetJiraClient(action.UserID, action.WorkspaceID)
+	if err != nil {
+		http.Error(w, "Failed to get Jira client: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resources, err := FetchAtlassianResources(client)
+	if err != nil {
+		http.Error(w, "Failed to fetch Atlassian resources: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accessible := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		accessible[resource.ID] = true
+	}
+	for _, site := range settings.Sites {
+		if !accessible[site.CloudID] {
+			http.Error(w, "Jira site "+site.CloudID+" is not accessible", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sites, err := jiraSitesToSync(resources, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, site := range sites {
+		if settings.JQL == "" && len(site.projectKeys) == 0 {
+			continue
+		}
+		problems, err := ValidateJiraJQL(client, site.resource.ID, buildJiraJQL(settings.JQL, site.projectKeys))
+		if err != nil {
+			http.Error(w, "Failed to validate JQL: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(problems) > 0 {
+			http.Error(w, "Invalid JQL for "+site.resource.Name+": "+strings.Join(problems, "; "), http.StatusBadRequest)
+			return
+		}
+	}
+
+	services.UpdateActionMetadata(w, jiraProvider{}, action, func(metadata map[string]interface{}) {
+		metadata["sites"] = settings.Sites
+		metadata["jql"] = settings.JQL
+	})
+}
@@ -0,0 +1,314 @@
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sentinent-backend/database"
+	"sentinent-backend/services"
+	"sentinent-backend/services/internal/servicestest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeAtlassian serves the Atlassian endpoints used by the Jira sync for two
+// sites, and records the JQL each site was searched with. Issues created or
+// edited through the issue API are kept in issues by key.
+type fakeAtlassian struct {
+	mu       sync.Mutex
+	searches map[string][]string
+	issues   map[string]map[string]any
+	edits    []map[string]any
+}
+
+func (f *fakeAtlassian) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/oauth/token/accessible-resources" {
+		_ = json.NewEncoder(w).Encode([]AtlassianResource{
+			{ID: "cloud-1", URL: "https://acme.atlassian.net", Name: "Acme"},
+			{ID: "cloud-2", URL: "https://labs.atlassian.net", Name: "Labs"},
+		})
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/ex/jira/"), "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	cloudID, api := parts[0], "/"+parts[1]
+
+	if strings.HasPrefix(api, "/rest/api/3/issue") {
+		f.serveIssue(w, r, cloudID, strings.TrimPrefix(strings.TrimPrefix(api, "/rest/api/3/issue"), "/"))
+		return
+	}
+
+	switch api {
+	case "/rest/api/3/myself":
+		_ = json.NewEncoder(w).Encode(JiraUser{AccountID: "acc-1"})
+	case "/rest/api/3/project/search":
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"values": []JiraProject{{ID: "1", Key: "OPS", Name: "Operations"}},
+			"isLast": true,
+		})
+	case "/rest/api/3/jql/parse":
+		var req struct {
+			Queries []string `json:"queries"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		errors := []string{}
+		if strings.Contains(req.Queries[0], "bogus") {
+			errors = append(errors, "Field 'bogus' does not exist.")
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"queries": []map[string]any{{"query": req.Queries[0], "errors": errors}},
+		})
+	case "/rest/api/3/search/jql":
+		var req struct {
+			JQL           string `json:"jql"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		f.searches[cloudID] = append(f.searches[cloudID], req.JQL)
+		f.mu.Unlock()
+
+		issue := func(id, key string) map[string]any {
+			return map[string]any{"id": id, "key": key, "fields": map[string]any{
+				"summary": "Issue " + key, "status": map[string]any{"name": "To Do"},
+				"project": map[string]any{"key": strings.Split(key, "-")[0]},
+				"created": "2024-03-01T10:00:00.000+0000",
+				"updated": "2024-03-01T10:00:00.000+0000",
+			}}
+		}
+		switch {
+		case cloudID == "cloud-1" && req.NextPageToken == "":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"issues":        []any{issue("10001", "OPS-1"), issue("10002", "OPS-2")},
+				"nextPageToken": "page-2",
+			})
+		case cloudID == "cloud-1":
+			_ = json.NewEncoder(w).Encode(map[string]any{"issues": []any{issue("10003", "OPS-3")}, "isLast": true})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]any{"issues": []any{issue("10001", "LAB-1")}, "isLast": true})
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAtlassian) serveIssue(w http.ResponseWriter, r *http.Request, cloudID, key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && key == "":
+		var req struct {
+			Fields map[string]any `json:"fields"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		key = fmt.Sprintf("OPS-%d", 50+len(f.issues))
+		fields := req.Fields
+		fields["status"] = map[string]any{"name": "To Do"}
+		fields["created"] = "2024-03-01T10:00:00.000+0000"
+		fields["updated"] = "2024-03-01T10:00:00.000+0000"
+		f.issues[key] = map[string]any{"id": fmt.Sprintf("100%d", 50+len(f.issues)), "key": key, "cloud_id": cloudID, "fields": fields}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": f.issues[key]["id"], "key": key})
+	case r.Method == http.MethodGet && f.issues[key] != nil:
+		_ = json.NewEncoder(w).Encode(f.issues[key])
+	case r.Method == http.MethodPut && f.issues[key] != nil:
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.edits = append(f.edits, req)
+		fields := f.issues[key]["fields"].(map[string]any)
+		for name, value := range req["fields"].(map[string]any) {
+			fields[name] = value
+		}
+		if update, ok := req["update"].(map[string]any); ok {
+			labels, _ := fields["labels"].([]any)
+			for _, op := range update["labels"].([]any) {
+				if label, ok := op.(map[string]any)["add"]; ok {
+					labels = append(labels, label)
+				}
+			}
+			fields["labels"] = labels
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// atlassianRewriteTransport sends requests for the Atlassian APIs to the
+// fake server.
+type atlassianRewriteTransport struct {
+	base   http.RoundTripper
+	target *url.URL
+}
+
+func (t atlassianRewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	clone.URL.Scheme = t.target.Scheme
+	clone.URL.Host = t.target.Host
+	clone.Host = t.target.Host
+	return t.base.RoundTrip(clone)
+}
+
+func setupJiraSettingsTest(t *testing.T, metadata string) *fakeAtlassian {
+	t.Helper()
+
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 10, 1)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	t.Setenv("JIRA_CLIENT_ID", "jira-client")
+	t.Setenv("JIRA_CLIENT_SECRET", "jira-secret")
+	originalConfig := jiraOAuthConfig
+	t.Cleanup(func() { jiraOAuthConfig = originalConfig })
+	if err := InitJiraService(); err != nil {
+		t.Fatalf("failed to initialize Jira service: %v", err)
+	}
+
+	if err := services.SaveIntegration(jiraProvider{}, 1, 10, &services.Grant{Token: &oauth2.Token{
+		AccessToken: "jira-token",
+		Expiry:      time.Now().Add(time.Hour),
+	}}); err != nil {
+		t.Fatalf("failed to seed Jira integration: %v", err)
+	}
+	if _, err := database.DB.Exec("UPDATE external_integrations SET metadata = ? WHERE provider = 'jira'", metadata); err != nil {
+		t.Fatalf("failed to seed Jira metadata: %v", err)
+	}
+
+	fake := &fakeAtlassian{searches: make(map[string][]string), issues: make(map[string]map[string]any)}
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server url: %v", err)
+	}
+
+	originalDefaultTransport := http.DefaultTransport
+	t.Cleanup(func() { http.DefaultTransport = originalDefaultTransport })
+	http.DefaultTransport = atlassianRewriteTransport{base: server.Client().Transport, target: target}
+	return fake
+}
+
+func TestBuildJiraJQL(t *testing.T) {
+	tests := []struct {
+		custom   string
+		projects []string
+		want     string
+	}{
+		{"", nil, defaultJiraJQL + " ORDER BY updated DESC"},
+		{"", []string{"OPS", "ENG"}, `(` + defaultJiraJQL + `) AND project in ("OPS", "ENG") ORDER BY updated DESC`},
+		{"labels = urgent order by created ASC", []string{"OPS"}, `(labels = urgent) AND project in ("OPS") order by created ASC`},
+		{"ORDER BY priority DESC", []string{"OPS"}, `project in ("OPS") ORDER BY priority DESC`},
+	}
+	for _, test := range tests {
+		if got := buildJiraJQL(test.custom, test.projects); got != test.want {
+			t.Errorf("buildJiraJQL(%q, %v) = %q, want %q", test.custom, test.projects, got, test.want)
+		}
+	}
+}
+
+func TestSyncJiraSignalsPagesEachSelectedSite(t *testing.T) {
+	fake := setupJiraSettingsTest(t,
+		`{"sites":[{"cloud_id":"cloud-1","project_keys":["OPS"]},{"cloud_id":"cloud-2"}],"jql":"labels = urgent"}`)
+
+	stats, err := SyncJiraSignals(1, 10)
+	if err != nil {
+		t.Fatalf("SyncJiraSignals returned error: %v", err)
+	}
+	if stats.ItemsFetched != 4 || stats.ItemsUpserted != 4 {
+		t.Fatalf("expected both pages of both sites, got %+v", stats)
+	}
+
+	if got := fake.searches["cloud-1"]; len(got) != 2 || got[0] != `(labels = urgent) AND project in ("OPS") ORDER BY updated DESC` {
+		t.Fatalf("unexpected cloud-1 searches %v", got)
+	}
+	if got := fake.searches["cloud-2"]; len(got) != 1 || got[0] != "labels = urgent ORDER BY updated DESC" {
+		t.Fatalf("unexpected cloud-2 searches %v", got)
+	}
+
+	var sourceIDs []string
+	rows, err := database.DB.Query("SELECT source_id FROM signals WHERE source_type = 'jira' ORDER BY source_id")
+	if err != nil {
+		t.Fatalf("failed to query signals: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sourceID string
+		if err := rows.Scan(&sourceID); err != nil {
+			t.Fatalf("failed to scan signal: %v", err)
+		}
+		sourceIDs = append(sourceIDs, sourceID)
+	}
+	if strings.Join(sourceIDs, ",") != "cloud-1:10001,cloud-1:10002,cloud-1:10003,cloud-2:10001" {
+		t.Fatalf("unexpected Jira source IDs %v", sourceIDs)
+	}
+
+	integration, err := services.GetIntegration(jiraProvider{}, 1, 10)
+	if err != nil {
+		t.Fatalf("failed to load integration: %v", err)
+	}
+	var metadata struct {
+		SyncedSites []jiraSyncedSite `json:"synced_sites"`
+		AccountID   string           `json:"account_id"`
+	}
+	if err := json.Unmarshal([]byte(integration.Metadata), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata: %v", err)
+	}
+	if len(metadata.SyncedSites) != 2 || metadata.SyncedSites[1].CloudURL != "https://labs.atlassian.net" || metadata.AccountID != "acc-1" {
+		t.Fatalf("expected both synced sites recorded, got %s", integration.Metadata)
+	}
+}
+
+func TestJiraSettingsActionValidatesBeforeSaving(t *testing.T) {
+	setupJiraSettingsTest(t, `{}`)
+	action := services.ActionContext{UserID: 1, WorkspaceID: 10}
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/integrations/jira/settings?workspace_id=10", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		jiraSettingsAction(rr, req, action)
+		return rr
+	}
+
+	if rr := patch(`{"sites":[{"cloud_id":"cloud-9"}]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an inaccessible site, got %d", rr.Code)
+	}
+	if rr := patch(`{"sites":[{"cloud_id":"cloud-1"}],"jql":"bogus = 1"}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "bogus") {
+		t.Fatalf("expected 400 with Jira's JQL error, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := patch(`{"sites":[{"cloud_id":"cloud-1","project_keys":["OPS"]}],"jql":"labels = urgent"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/integrations/jira/settings?workspace_id=10", nil)
+	rr := httptest.NewRecorder()
+	jiraSettingsAction(rr, req, action)
+	var settings jiraSettings
+	if err := json.Unmarshal(rr.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("failed to decode settings: %v", err)
+	}
+	if len(settings.Sites) != 1 || settings.Sites[0].ProjectKeys[0] != "OPS" || settings.JQL != "labels = urgent" {
+		t.Fatalf("unexpected saved settings %+v", settings)
+	}
+
+	projectsReq := httptest.NewRequest(http.MethodGet, "/api/integrations/jira/projects?workspace_id=10", nil)
+	projectsRR := httptest.NewRecorder()
+	jiraProjectsAction(projectsRR, projectsReq, action)
+	var sites []jiraSiteProjects
+	if err := json.Unmarshal(projectsRR.Body.Bytes(), &sites); err != nil {
+		t.Fatalf("failed to decode projects: %v", err)
+	}
+	if len(sites) != 2 || sites[0].ID != "cloud-1" || len(sites[0].Projects) != 1 || sites[0].Projects[0].Key != "OPS" {
+		t.Fatalf("expected sites with their projects, got %+v", sites)
+	}
+}
@@ -0,0 +1,410 @@
+package jira
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
+	"sentinent-backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jiraWebhookSecretEnv = "JIRA_WEBHOOK_SECRET"
+
+	// jiraWebhookClockSkew is how far exp and iat may be off from our clock.
+	jiraWebhookClockSkew = time.Minute
+)
+
+var (
+	errJiraWebhookSecretMissing = errors.New("jira webhook secret is not configured")
+	jiraProcessWebhookFunc      = ProcessJiraWebhook
+)
+
+type jiraWebhookPayload struct {
+	WebhookEvent string     `json:"webhookEvent"`
+	Timestamp    int64      `json:"timestamp"`
+	Issue        *JiraIssue `json:"issue"`
+	Comment      *struct {
+		ID      string `json:"id"`
+		Created string `json:"created"`
+		Updated string `json:"updated"`
+	} `json:"comment"`
+}
+
+type jiraWebhookTarget struct {
+	userID      int
+	workspaceID int
+	cloudID     string
+	cloudURL    string
+	accountID   string
+	projectKeys []string
+	customJQL   bool
+}
+
+// HandleWebhook receives Jira issue and comment events at /api/webhooks/jira.
+// The optional cloud_id query parameter identifies the site the webhook was
+// registered on.
+func (jiraProvider) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateJiraWebhookRequest(r, body); err != nil {
+		if errors.Is(err, errJiraWebhookSecretMissing) {
+			http.Error(w, "Jira webhook secret not configured", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	if !json.Valid(body) {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	cloudID := strings.TrimSpace(r.URL.Query().Get("cloud_id"))
+	if err := jiraProcessWebhookFunc(cloudID, body); err != nil {
+		log.Printf("Failed to process Jira webhook: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// validateJiraWebhookRequest accepts either an X-Hub-Signature HMAC of the body
+// or an HS256 JWT in the Authorization header, both keyed by the shared secret.
+func validateJiraWebhookRequest(r *http.Request, body []byte) error {
+	secret := strings.TrimSpace(os.Getenv(jiraWebhookSecretEnv))
+	if secret == "" {
+		if utils.IsProductionEnv() {
+			return errJiraWebhookSecretMissing
+		}
+		return nil
+	}
+
+	if signature := strings.TrimSpace(r.Header.Get("X-Hub-Signature")); signature != "" {
+		if !strings.HasPrefix(signature, "sha256=") {
+			return errors.New("unsupported signature algorithm")
+		}
+		actual, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		if _, err := mac.Write(body); err != nil {
+			return err
+		}
+		if !hmac.Equal(actual, mac.Sum(nil)) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	tokenString := ""
+	switch {
+	case strings.HasPrefix(authHeader, "JWT "):
+		tokenString = strings.TrimPrefix(authHeader, "JWT ")
+	case strings.HasPrefix(authHeader, "Bearer "):
+		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+	default:
+		return errors.New("missing webhook signature")
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(strings.TrimSpace(tokenString), claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(jiraWebhookClockSkew),
+	)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid webhook token")
+	}
+	if issuedAt, err := claims.GetIssuedAt(); err != nil || issuedAt == nil {
+		return errors.New("webhook token has no issued-at time")
+	}
+	// Atlassian binds each token to its request with a query string hash, so a
+	// captured token cannot be replayed against another URL.
+	qsh, _ := claims["qsh"].(string)
+	if !hmac.Equal([]byte(qsh), []byte(jiraQueryStringHash(r))) {
+		return errors.New("webhook token query hash mismatch")
+	}
+	return nil
+}
+
+// jiraQueryStringHash computes the qsh claim Atlassian signs for a request:
+// the SHA-256 of its method, path and sorted query, excluding the jwt
+// parameter.
+func jiraQueryStringHash(r *http.Request) string {
+	path := r.URL.Path
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	query := r.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		if name != "jwt" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		values := make([]string, 0, len(query[name]))
+		for _, value := range query[name] {
+			values = append(values, jiraPercentEncode(value))
+		}
+		sort.Strings(values)
+		params = append(params, jiraPercentEncode(name)+"="+strings.Join(values, ","))
+	}
+
+	canonical := strings.ToUpper(r.Method) + "&" + strings.ReplaceAll(path, "&", "%26") + "&" + strings.Join(params, "&")
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// jiraPercentEncode encodes a query component as RFC 3986 requires.
+func jiraPercentEncode(value string) string {
+	encoded := url.QueryEscape(value)
+	return strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").Replace(encoded)
+}
+
+// ProcessJiraWebhook applies a Jira webhook delivery to the signals of every
+// integration connected to the originating site. cloudID may be empty, in
+// which case the site is matched on the issue's self URL.
+func ProcessJiraWebhook(cloudID string, body []byte) error {
+	var payload jiraWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("decode Jira webhook payload: %w", err)
+	}
+	if payload.Issue == nil || payload.Issue.ID == "" {
+		return nil
+	}
+
+	switch payload.WebhookEvent {
+	case "jira:issue_created", "jira:issue_updated", "jira:issue_deleted", "comment_created":
+	default:
+		return nil
+	}
+
+	targets, err := findJiraWebhookTargets(cloudID, jiraSiteURL(payload.Issue.Self))
+	if err != nil {
+		return err
+	}
+
+	issue := *payload.Issue
+	for _, target := range targets {
+		var err error
+		switch payload.WebhookEvent {
+		case "jira:issue_created", "jira:issue_updated":
+			err = upsertJiraWebhookIssue(target, issue)
+		case "jira:issue_deleted":
+			err = archiveJiraSignal(target, issue.ID)
+		case "comment_created":
+			err = touchJiraSignal(target, issue.ID, jiraCommentTime(payload))
+		}
+		if err != nil {
+			log.Printf("Failed to apply Jira %s for user %d workspace %d: %v", payload.WebhookEvent, target.userID, target.workspaceID, err)
+		}
+	}
+	return nil
+}
+
+// upsertJiraWebhookIssue refreshes an existing signal, or creates one when the
+// issue matches the default sync JQL: it involves the connected account and
+// is in a selected project. A custom JQL cannot be evaluated here, so those
+// integrations only get updates until the next sync.
+func upsertJiraWebhookIssue(target jiraWebhookTarget, issue JiraIssue) error {
+	signalID, err := findJiraSignalID(target, issue.ID)
+	if err != nil {
+		return err
+	}
+	if signalID == 0 && !jiraWebhookShouldCreate(target, issue) {
+		return nil
+	}
+	return saveJiraIssueAsSignal(target.userID, target.workspaceID, issue, target.cloudID, target.cloudURL)
+}
+
+func jiraWebhookShouldCreate(target jiraWebhookTarget, issue JiraIssue) bool {
+	if target.customJQL || !jiraIssueInvolvesAccount(issue, target.accountID) {
+		return false
+	}
+	if len(target.projectKeys) == 0 {
+		return true
+	}
+	for _, key := range target.projectKeys {
+		if key == issue.Fields.Project.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveJiraSignal archives the signal for a deleted issue for every member
+// of the workspace.
+func archiveJiraSignal(target jiraWebhookTarget, issueID string) error {
+	signalID, err := findJiraSignalID(target, issueID)
+	if err != nil || signalID == 0 {
+		return err
+	}
+	if err := repository.Default().Signals.ArchiveForAll(signalID); err != nil {
+		return err
+	}
+	services.PublishSignalArchivedForAll(target.workspaceID, signalID)
+	return nil
+}
+
+// touchJiraSignal bumps an existing signal for a new comment. Comment payloads
+// only carry a subset of issue fields, so the stored issue is left untouched.
+func touchJiraSignal(target jiraWebhookTarget, issueID string, at time.Time) error {
+	signalID, err := findJiraSignalID(target, issueID)
+	if err != nil || signalID == 0 {
+		return err
+	}
+	if err := repository.Default().Signals.Touch(signalID, at); err != nil {
+		return err
+	}
+	services.PublishSignalUpdated(target.workspaceID, signalID)
+	return nil
+}
+
+func findJiraSignalID(target jiraWebhookTarget, issueID string) (int, error) {
+	signal, err := repository.Default().Signals.FindBySource(
+		target.workspaceID, models.SourceTypeJira, jiraSignalSourceID(target.cloudID, issueID),
+	)
+	if err == repository.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return signal.ID, nil
+}
+
+// findJiraWebhookTargets returns the Jira integrations that synced the site
+// with the given cloud ID, or the site URL when no cloud ID is supplied.
+func findJiraWebhookTargets(cloudID, siteURL string) ([]jiraWebhookTarget, error) {
+	if cloudID == "" && siteURL == "" {
+		return nil, nil
+	}
+
+	integrations, err := repository.Default().Integrations.ListByProvider("jira")
+	if err != nil {
+		return nil, fmt.Errorf("query Jira integrations: %w", err)
+	}
+
+	targets := make([]jiraWebhookTarget, 0)
+	for _, integration := range integrations {
+		var metadata struct {
+			SyncedSites []jiraSyncedSite `json:"synced_sites"`
+			CloudID     string           `json:"cloud_id"`
+			CloudURL    string           `json:"cloud_url"`
+			AccountID   string           `json:"account_id"`
+			JQL         string           `json:"jql"`
+		}
+		if integration.Metadata != "" {
+			_ = json.Unmarshal([]byte(integration.Metadata), &metadata)
+		}
+
+		// Integrations last synced by an earlier version recorded one site.
+		sites := metadata.SyncedSites
+		if len(sites) == 0 && metadata.CloudID != "" {
+			sites = []jiraSyncedSite{{CloudID: metadata.CloudID, CloudURL: metadata.CloudURL}}
+		}
+
+		for _, site := range sites {
+			siteCloudURL := strings.TrimRight(site.CloudURL, "/")
+			matched := false
+			if cloudID != "" {
+				matched = site.CloudID == cloudID
+			} else {
+				matched = siteCloudURL != "" && siteCloudURL == siteURL
+			}
+			if !matched {
+				continue
+			}
+
+			if siteCloudURL == "" {
+				siteCloudURL = siteURL
+			}
+			targets = append(targets, jiraWebhookTarget{
+				userID:      integration.UserID,
+				workspaceID: integration.WorkspaceID,
+				cloudID:     site.CloudID,
+				cloudURL:    siteCloudURL,
+				accountID:   metadata.AccountID,
+				projectKeys: site.ProjectKeys,
+				customJQL:   strings.TrimSpace(metadata.JQL) != "",
+			})
+		}
+	}
+	return targets, nil
+}
+
+func jiraIssueInvolvesAccount(issue JiraIssue, accountID string) bool {
+	if accountID == "" {
+		return false
+	}
+	if issue.Fields.Assignee != nil && issue.Fields.Assignee.AccountID == accountID {
+		return true
+	}
+	return issue.Fields.Reporter != nil && issue.Fields.Reporter.AccountID == accountID
+}
+
+// jiraSiteURL extracts the site base URL from an issue self link such as
+// https://acme.atlassian.net/rest/api/2/issue/10001.
+func jiraSiteURL(self string) string {
+	u, err := url.Parse(self)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func jiraCommentTime(payload jiraWebhookPayload) time.Time {
+	if payload.Comment != nil {
+		if payload.Comment.Updated != "" {
+			return parseJiraDate(payload.Comment.Updated)
+		}
+		if payload.Comment.Created != "" {
+			return parseJiraDate(payload.Comment.Created)
+		}
+	}
+	if payload.Timestamp > 0 {
+		return time.UnixMilli(payload.Timestamp)
+	}
+	return time.Now()
+}
@@ -0,0 +1,281 @@
+package jira
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/services/internal/servicestest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func setupJiraWebhookTestDB(t *testing.T) {
+	t.Helper()
+
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 10, 1)
+	servicestest.AddWorkspace(t, 20, 2)
+	dbtest.Seed(t,
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata) VALUES
+		 (1, 10, 'jira', 'token', '{"cloud_id":"cloud-1","cloud_url":"https://acme.atlassian.net","account_id":"acc-1"}'),
+		 (2, 20, 'jira', 'token', '{"cloud_id":"cloud-2","cloud_url":"https://other.atlassian.net","account_id":"acc-1"}')`,
+	)
+}
+
+func TestProcessJiraWebhookUpsertsAndArchivesSignals(t *testing.T) {
+	setupJiraWebhookTestDB(t)
+
+	created := `{"webhookEvent":"jira:issue_created","issue":{"id":"10001","key":"OPS-1","self":"https://acme.atlassian.net/rest/api/2/issue/10001",
+		"fields":{"summary":"Database failover","status":{"name":"To Do"},"project":{"key":"OPS"},"issuetype":{"name":"Bug"},
+		"assignee":{"accountId":"acc-1","displayName":"Ada"},"reporter":{"accountId":"acc-2","displayName":"Grace"},
+		"created":"2024-03-01T10:00:00.000+0000","updated":"2024-03-01T10:00:00.000+0000"}}}`
+	if err := ProcessJiraWebhook("cloud-1", []byte(created)); err != nil {
+		t.Fatalf("ProcessJiraWebhook returned error: %v", err)
+	}
+
+	updated := `{"webhookEvent":"jira:issue_updated","issue":{"id":"10001","key":"OPS-1","self":"https://acme.atlassian.net/rest/api/2/issue/10001",
+		"fields":{"summary":"Database failover","status":{"name":"In Progress"},"project":{"key":"OPS"},"issuetype":{"name":"Bug"},
+		"assignee":{"accountId":"acc-3","displayName":"Linus"},"reporter":{"accountId":"acc-2","displayName":"Grace"},
+		"created":"2024-03-01T10:00:00.000+0000","updated":"2024-03-02T10:00:00.000+0000"}}}`
+	// No cloud ID: the site is resolved from the issue self link.
+	if err := ProcessJiraWebhook("", []byte(updated)); err != nil {
+		t.Fatalf("ProcessJiraWebhook returned error: %v", err)
+	}
+
+	var userID, workspaceID int
+	var title, status, url string
+	if err := database.DB.QueryRow(
+		"SELECT user_id, workspace_id, title, status, url FROM signals WHERE source_type = 'jira'",
+	).Scan(&userID, &workspaceID, &title, &status, &url); err != nil {
+		t.Fatalf("failed to load Jira signal: %v", err)
+	}
+	if userID != 1 || workspaceID != 10 || title != "[OPS-1] Database failover" || status != "In Progress" {
+		t.Fatalf("unexpected Jira signal: user=%d workspace=%d title=%q status=%q", userID, workspaceID, title, status)
+	}
+	if url != "https://acme.atlassian.net/browse/OPS-1" {
+		t.Fatalf("unexpected Jira signal URL %q", url)
+	}
+
+	deleted := `{"webhookEvent":"jira:issue_deleted","issue":{"id":"10001","key":"OPS-1","self":"https://acme.atlassian.net/rest/api/2/issue/10001"}}`
+	if err := ProcessJiraWebhook("cloud-1", []byte(deleted)); err != nil {
+		t.Fatalf("ProcessJiraWebhook returned error: %v", err)
+	}
+
+	var archivedStatus string
+	if err := database.DB.QueryRow(
+		"SELECT COALESCE(ss.status, s.status) FROM signals s LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = 1 WHERE s.source_id = 'cloud-1:10001'",
+	).Scan(&archivedStatus); err != nil {
+		t.Fatalf("failed to load signal status: %v", err)
+	}
+	if archivedStatus != "archived" {
+		t.Fatalf("expected deleted issue to be archived, got %q", archivedStatus)
+	}
+}
+
+func TestProcessJiraWebhookRoutesEachSyncedSite(t *testing.T) {
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 10, 1)
+	servicestest.AddWorkspace(t, 20, 2)
+
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata) VALUES
+		 (1, 10, 'jira', 'token', '{"account_id":"acc-1","synced_sites":[
+			{"cloud_id":"cloud-1","cloud_url":"https://acme.atlassian.net","project_keys":["OPS"]},
+			{"cloud_id":"cloud-2","cloud_url":"https://labs.atlassian.net"}]}'),
+		 (2, 20, 'jira', 'token', '{"account_id":"acc-1","jql":"project = OPS","synced_sites":[
+			{"cloud_id":"cloud-1","cloud_url":"https://acme.atlassian.net"}]}')`,
+	); err != nil {
+		t.Fatalf("failed to seed Jira integrations: %v", err)
+	}
+
+	// Issue IDs are only unique within a site.
+	issue := func(site, id, project string) string {
+		return `{"webhookEvent":"jira:issue_created","issue":{"id":"` + id + `","key":"` + project + `-1","self":"https://` + site + `.atlassian.net/rest/api/2/issue/` + id + `",
+			"fields":{"summary":"Assigned","status":{"name":"To Do"},"project":{"key":"` + project + `"},"issuetype":{"name":"Task"},
+			"assignee":{"accountId":"acc-1","displayName":"Ada"}}}}`
+	}
+	for _, delivery := range []struct{ cloudID, body string }{
+		{"cloud-1", issue("acme", "10001", "OPS")},
+		{"cloud-1", issue("acme", "10002", "ENG")},
+		{"cloud-2", issue("labs", "10001", "LAB")},
+	} {
+		if err := ProcessJiraWebhook(delivery.cloudID, []byte(delivery.body)); err != nil {
+			t.Fatalf("ProcessJiraWebhook returned error: %v", err)
+		}
+	}
+
+	rows, err := database.DB.Query("SELECT user_id, source_id, external_id FROM signals ORDER BY user_id, source_id")
+	if err != nil {
+		t.Fatalf("failed to query signals: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var userID int
+		var sourceID, key string
+		if err := rows.Scan(&userID, &sourceID, &key); err != nil {
+			t.Fatalf("failed to scan signal: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%d %s %s", userID, sourceID, key))
+	}
+
+	// User 1 only selected OPS on the first site; user 2 has a custom JQL, so
+	// new issues wait for the next sync.
+	want := []string{"1 cloud-1:10001 OPS-1", "1 cloud-2:10001 LAB-1"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected signals %v, got %v", want, got)
+	}
+}
+
+func TestProcessJiraWebhookIgnoresUnrelatedIssues(t *testing.T) {
+	setupJiraWebhookTestDB(t)
+
+	unrelated := `{"webhookEvent":"jira:issue_created","issue":{"id":"10002","key":"OPS-2","self":"https://acme.atlassian.net/rest/api/2/issue/10002",
+		"fields":{"summary":"Someone else's task","status":{"name":"To Do"},"project":{"key":"OPS"},"issuetype":{"name":"Task"},
+		"assignee":{"accountId":"acc-9","displayName":"Other"}}}}`
+	if err := ProcessJiraWebhook("cloud-1", []byte(unrelated)); err != nil {
+		t.Fatalf("ProcessJiraWebhook returned error: %v", err)
+	}
+	if err := ProcessJiraWebhook("unknown-cloud", []byte(unrelated)); err != nil {
+		t.Fatalf("ProcessJiraWebhook returned error: %v", err)
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals").Scan(&count); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no signals for unrelated issues, got %d", count)
+	}
+}
+
+func TestJiraHandleWebhookAcceptsSignedDeliveries(t *testing.T) {
+	t.Setenv(jiraWebhookSecretEnv, "jira-secret")
+
+	originalProcess := jiraProcessWebhookFunc
+	var cloudIDs []string
+	jiraProcessWebhookFunc = func(cloudID string, body []byte) error {
+		cloudIDs = append(cloudIDs, cloudID)
+		return nil
+	}
+	t.Cleanup(func() { jiraProcessWebhookFunc = originalProcess })
+
+	body := []byte(`{"webhookEvent":"jira:issue_updated","issue":{"id":"10001","key":"OPS-1"}}`)
+
+	hmacReq := httptest.NewRequest(http.MethodPost, "/api/webhooks/jira?cloud_id=cloud-1", bytes.NewReader(body))
+	hmacReq.Header.Set("X-Hub-Signature", "sha256="+signWebhookBody(body, "jira-secret"))
+	hmacRR := httptest.NewRecorder()
+	jiraProvider{}.HandleWebhook(hmacRR, hmacReq)
+	if hmacRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from HMAC-signed webhook, got %d: %s", hmacRR.Code, hmacRR.Body.String())
+	}
+
+	jwtReq := httptest.NewRequest(http.MethodPost, "/api/webhooks/jira", bytes.NewReader(body))
+	jwtReq.Header.Set("Authorization", "JWT "+signJiraWebhookToken(t, jwt.SigningMethodHS256, "jira-secret", jiraWebhookClaims(jwtReq)))
+	jwtRR := httptest.NewRecorder()
+	jiraProvider{}.HandleWebhook(jwtRR, jwtReq)
+	if jwtRR.Code != http.StatusOK {
+		t.Fatalf("expected 200 from JWT-signed webhook, got %d: %s", jwtRR.Code, jwtRR.Body.String())
+	}
+
+	if len(cloudIDs) != 2 || cloudIDs[0] != "cloud-1" || cloudIDs[1] != "" {
+		t.Fatalf("unexpected processed cloud IDs: %v", cloudIDs)
+	}
+}
+
+func TestJiraHandleWebhookRejectsUnsignedDeliveries(t *testing.T) {
+	t.Setenv(jiraWebhookSecretEnv, "jira-secret")
+
+	originalProcess := jiraProcessWebhookFunc
+	jiraProcessWebhookFunc = func(cloudID string, body []byte) error {
+		t.Fatal("webhook should not be processed")
+		return nil
+	}
+	t.Cleanup(func() { jiraProcessWebhookFunc = originalProcess })
+
+	body := []byte(`{"webhookEvent":"jira:issue_deleted","issue":{"id":"10001"}}`)
+
+	cases := map[string]func(*http.Request){
+		"missing signature": func(r *http.Request) {},
+		"wrong hmac": func(r *http.Request) {
+			r.Header.Set("X-Hub-Signature", "sha256="+signWebhookBody(body, "other-secret"))
+		},
+		"wrong jwt": func(r *http.Request) {
+			r.Header.Set("Authorization", "JWT "+signJiraWebhookToken(t, jwt.SigningMethodHS256, "other-secret", jiraWebhookClaims(r)))
+		},
+		"jwt without exp": func(r *http.Request) {
+			claims := jiraWebhookClaims(r)
+			delete(claims, "exp")
+			r.Header.Set("Authorization", "JWT "+signJiraWebhookToken(t, jwt.SigningMethodHS256, "jira-secret", claims))
+		},
+		"jwt without iat": func(r *http.Request) {
+			claims := jiraWebhookClaims(r)
+			delete(claims, "iat")
+			r.Header.Set("Authorization", "JWT "+signJiraWebhookToken(t, jwt.SigningMethodHS256, "jira-secret", claims))
+		},
+		"jwt for another request": func(r *http.Request) {
+			other := httptest.NewRequest(http.MethodPost, "/api/webhooks/jira?cloud_id=cloud-2", nil)
+			r.Header.Set("Authorization", "JWT "+signJiraWebhookToken(t, jwt.SigningMethodHS256, "jira-secret", jiraWebhookClaims(other)))
+		},
+		"jwt signed with HS512": func(r *http.Request) {
+			r.Header.Set("Authorization", "JWT "+signJiraWebhookToken(t, jwt.SigningMethodHS512, "jira-secret", jiraWebhookClaims(r)))
+		},
+	}
+	for name, prepare := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks/jira", bytes.NewReader(body))
+		prepare(req)
+		rr := httptest.NewRecorder()
+		jiraProvider{}.HandleWebhook(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestJiraQueryStringHashCanonicalizesQuery(t *testing.T) {
+	a := httptest.NewRequest(http.MethodPost, "/api/webhooks/jira/?b=2&a=x%20y&a=w&jwt=ignored", nil)
+	b := httptest.NewRequest(http.MethodPost, "/api/webhooks/jira?a=w&a=x+y&b=2", nil)
+	if jiraQueryStringHash(a) != jiraQueryStringHash(b) {
+		t.Fatal("expected equivalent requests to hash the same")
+	}
+
+	sum := sha256.Sum256([]byte("POST&/api/webhooks/jira&a=w,x%20y&b=2"))
+	if got := jiraQueryStringHash(a); got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected query string hash %s", got)
+	}
+}
+
+// jiraWebhookClaims returns valid Atlassian claims for r.
+func jiraWebhookClaims(r *http.Request) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss": "jira",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+		"qsh": jiraQueryStringHash(r),
+	}
+}
+
+func signJiraWebhookToken(t *testing.T, method jwt.SigningMethod, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign webhook token: %v", err)
+	}
+	return token
+}
+
+func signWebhookBody(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,606 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
+
+	"golang.org/x/oauth2"
+)
+
+const slackOAuthScopes = "channels:history,channels:read,chat:write,users:read,groups:read,im:history,groups:history,channels:join"
+
+const (
+	// slackCheckpointsKey holds, in integration metadata, the timestamp of the
+	// newest stored message of each channel.
+	slackCheckpointsKey = "channel_checkpoints"
+
+	defaultSlackBackfillDays = 30
+	slackRateLimitRetries    = 3
+)
+
+func init() {
+	services.RegisterProvider(&slackProvider{client: NewSlackClient()})
+}
+
+// slackAPIClient is the subset of SlackClient the provider uses.
+type slackAPIClient interface {
+	slackSyncClient
+	ExchangeCodeForToken(clientID, clientSecret, code, redirectURI string) (*SlackOAuthResponse, error)
+	PostMessage(accessToken, channelID, text, threadTS string) (*SlackMessage, *RateLimitInfo, error)
+}
+
+type slackSyncClient interface {
+	GetChannels(accessToken string) ([]SlackChannel, *RateLimitInfo, error)
+	GetMessages(accessToken, channelID string, limit int, oldest, cursor string) ([]SlackMessage, string, *RateLimitInfo, error)
+	GetReplies(accessToken, channelID, threadTS string) ([]SlackMessage, *RateLimitInfo, error)
+	GetUserInfo(accessToken, userID string) (*SlackUserResponse, *RateLimitInfo, error)
+}
+
+// slackProvider connects a Slack workspace per Sentinent workspace and syncs
+// messages from the selected channels.
+type slackProvider struct {
+	client       slackAPIClient
+	clientID     string
+	clientSecret string
+}
+
+func (p *slackProvider) Name() string                  { return "slack" }
+func (p *slackProvider) DisplayName() string           { return "Slack" }
+func (p *slackProvider) Scope() services.ProviderScope { return services.ScopeWorkspace }
+
+func (p *slackProvider) Init() error {
+	p.clientID = strings.TrimSpace(os.Getenv("SLACK_CLIENT_ID"))
+	p.clientSecret = strings.TrimSpace(os.Getenv("SLACK_CLIENT_SECRET"))
+	if p.clientID == "" || p.clientSecret == "" {
+		return fmt.Errorf("SLACK_CLIENT_ID and SLACK_CLIENT_SECRET must be set")
+	}
+	if !services.TokenEncryptionConfigured() {
+		return fmt.Errorf("TOKEN_ENCRYPTION_KEY must be set")
+	}
+	return nil
+}
+
+func (p *slackProvider) Configured() bool {
+	return p.clientID != "" && p.clientSecret != "" && services.TokenEncryptionConfigured()
+}
+
+// RemoteCallback reports that Slack callbacks often arrive through a tunnel
+// set as SLACK_REDIRECT_URI, without the state cookie.
+func (p *slackProvider) RemoteCallback() bool { return true }
+
+func (p *slackProvider) AuthURL(state, redirectURI string) string {
+	return "https://slack.com/oauth/v2/authorize?" +
+		"client_id=" + p.clientID +
+		"&scope=" + slackOAuthScopes +
+		"&redirect_uri=" + slackRedirectURI(redirectURI) +
+		"&state=" + state
+}
+
+func (p *slackProvider) Exchange(ctx context.Context, code, redirectURI string) (*services.Grant, error) {
+	oauthResp, err := p.client.ExchangeCodeForToken(p.clientID, p.clientSecret, code, slackRedirectURI(redirectURI))
+	if err != nil {
+		return nil, err
+	}
+	return &services.Grant{
+		Token: &oauth2.Token{AccessToken: oauthResp.AccessToken},
+		Metadata: map[string]interface{}{
+			"team_id":     oauthResp.Team.ID,
+			"team_name":   oauthResp.Team.Name,
+			"bot_user_id": oauthResp.BotUserID,
+			"app_id":      oauthResp.AppID,
+			"scope":       oauthResp.Scope,
+		},
+	}, nil
+}
+
+// RefreshToken returns the token as is; Slack bot tokens do not expire.
+func (p *slackProvider) RefreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
+	return services.StaticToken(token)
+}
+
+func (p *slackProvider) Sync(userID, workspaceID int) (services.SyncStats, error) {
+	integration, token, err := p.integrationToken(userID, workspaceID)
+	if err != nil {
+		return services.SyncStats{}, err
+	}
+	return syncSlackIntegration(p.client, integration, token.AccessToken)
+}
+
+func (p *slackProvider) Actions() []services.ProviderAction {
+	return []services.ProviderAction{
+		{Path: "channels", Handle: p.channelsAction},
+		{Path: "reply", Handle: p.replyAction},
+	}
+}
+
+func (p *slackProvider) integrationToken(userID, workspaceID int) (*models.ExternalIntegration, *oauth2.Token, error) {
+	integration, err := services.GetIntegration(p, userID, workspaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	token, err := services.IntegrationToken(context.Background(), p, integration)
+	if err != nil {
+		return nil, nil, err
+	}
+	return integration, token, nil
+}
+
+// slackRedirectURI lets SLACK_REDIRECT_URI override the callback derived from
+// the request.
+func slackRedirectURI(redirectURI string) string {
+	if uri := strings.TrimSpace(os.Getenv("SLACK_REDIRECT_URI")); uri != "" {
+		return uri
+	}
+	return redirectURI
+}
+
+// channelsAction lists the channels of the integration named by
+// integration_id, or with PATCH stores which channels are synced.
+func (p *slackProvider) channelsAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.Configured() {
+		http.Error(w, "Slack integration not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method == http.MethodPatch {
+		if !services.RequireActionWorkspace(w, action) {
+			return
+		}
+		var req struct {
+			ChannelIDs   []string `json:"channel_ids"`
+			BackfillDays *int     `json:"backfill_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.BackfillDays != nil && *req.BackfillDays < 0 {
+			http.Error(w, "backfill_days must not be negative", http.StatusBadRequest)
+			return
+		}
+		services.UpdateActionMetadata(w, p, action, func(metadata map[string]interface{}) {
+			metadata["selected_channels"] = req.ChannelIDs
+			if req.BackfillDays != nil {
+				metadata["backfill_days"] = *req.BackfillDays
+			}
+			// Forget channels that are no longer synced, so selecting one
+			// again backfills it instead of catching up from an old checkpoint.
+			if checkpoints, ok := metadata[slackCheckpointsKey].(map[string]interface{}); ok && len(req.ChannelIDs) > 0 {
+				selected := make(map[string]bool, len(req.ChannelIDs))
+				for _, channelID := range req.ChannelIDs {
+					selected[channelID] = true
+				}
+				for channelID := range checkpoints {
+					if !selected[channelID] {
+						delete(checkpoints, channelID)
+					}
+				}
+			}
+		})
+		return
+	}
+
+	integrationIDStr := r.URL.Query().Get("integration_id")
+	if integrationIDStr == "" {
+		http.Error(w, "integration_id is required", http.StatusBadRequest)
+		return
+	}
+
+	integrationID, err := strconv.Atoi(integrationIDStr)
+	if err != nil {
+		http.Error(w, "Invalid integration_id", http.StatusBadRequest)
+		return
+	}
+
+	integration, err := repository.Default().Integrations.GetByID(integrationID)
+	if err == nil && (integration.UserID != action.UserID || integration.Provider != p.Name()) {
+		err = repository.ErrNotFound
+	}
+	if err == repository.ErrNotFound {
+		http.Error(w, "Integration not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch integration", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := services.IntegrationToken(r.Context(), p, integration)
+	if err != nil {
+		http.Error(w, "Failed to decrypt token", http.StatusInternalServerError)
+		return
+	}
+
+	channels, rateLimit, err := p.client.GetChannels(token.AccessToken)
+	if err != nil {
+		if rateLimit != nil && rateLimit.IsRateLimited() {
+			http.Error(w, "Rate limited by Slack API", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "Failed to fetch channels: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"channels": channels})
+}
+
+// replyAction posts a message, optionally in a thread, as the connected bot.
+func (p *slackProvider) replyAction(w http.ResponseWriter, r *http.Request, action services.ActionContext) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !services.RequireActionWorkspace(w, action) {
+		return
+	}
+
+	var req struct {
+		ChannelID string `json:"channel_id"`
+		ThreadTS  string `json:"thread_ts"`
+		Text      string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	integration, err := services.GetIntegration(p, action.UserID, action.WorkspaceID)
+	if err != nil {
+		http.Error(w, "Integration not found", http.StatusNotFound)
+		return
+	}
+	token, err := services.IntegrationToken(r.Context(), p, integration)
+	if err != nil {
+		http.Error(w, "Failed to decrypt token", http.StatusInternalServerError)
+		return
+	}
+
+	posted, rateLimit, err := p.client.PostMessage(token.AccessToken, req.ChannelID, req.Text, req.ThreadTS)
+	if err != nil {
+		if rateLimit != nil && rateLimit.IsRateLimited() {
+			http.Error(w, "Rate limited", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "Failed to post message: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Show the reply on the thread's signal right away instead of waiting for
+	// the webhook or the next sync.
+	if posted.IsThreadReply() {
+		target := slackWebhookTarget{userID: action.UserID, workspaceID: action.WorkspaceID}
+		author, _ := slackUserName(p.client, token.AccessToken, posted.User)
+		if err := addSlackReply(target, req.ChannelID, posted.ThreadTS, slackReply(*posted, author)); err != nil {
+			log.Printf("Failed to attach Slack reply to thread %s: %v", posted.ThreadTS, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// syncSlackIntegration syncs messages from Slack
+func syncSlackIntegration(client slackSyncClient, integration *models.ExternalIntegration, accessToken string) (services.SyncStats, error) {
+	var stats services.SyncStats
+
+	// Parse metadata to get selected channels
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(integration.Metadata), &metadata); err != nil {
+		return stats, fmt.Errorf("failed to parse metadata for integration %d: %w", integration.ID, err)
+	}
+
+	// Get channels to monitor (stored in metadata as "selected_channels")
+	var channels []string
+	if selectedChannels, ok := metadata["selected_channels"].([]interface{}); ok {
+		for _, ch := range selectedChannels {
+			if chStr, ok := ch.(string); ok {
+				channels = append(channels, chStr)
+			}
+		}
+	}
+
+	// If no channels selected, fetch all channels
+	if len(channels) == 0 {
+		slackChannels, rateLimit, err := client.GetChannels(accessToken)
+		if err != nil {
+			if rateLimit != nil && rateLimit.IsRateLimited() {
+				log.Printf("Rate limited by Slack API, waiting %v", rateLimit.WaitDuration())
+				time.Sleep(rateLimit.WaitDuration())
+			}
+			if isSlackAuthError(err) {
+				return stats, &services.ReauthRequiredError{Provider: "Slack", Err: err}
+			}
+			return stats, fmt.Errorf("failed to fetch Slack channels: %w", err)
+		}
+
+		for _, ch := range slackChannels {
+			channels = append(channels, ch.ID)
+		}
+	}
+
+	// Each channel resumes from its own checkpoint; channels without one are
+	// new and are backfilled over the configured window.
+	checkpoints := slackChannelCheckpoints(metadata)
+	backfillOldest := fmt.Sprintf("%d.000000", time.Now().Add(-slackBackfillWindow(metadata)).Unix())
+
+	// Cache for user names to avoid redundant API calls across channels
+	userCache := make(map[string]string)
+	authorName := func(userID string) string {
+		if cachedName, ok := userCache[userID]; ok {
+			return cachedName
+		}
+		name, ok := slackUserName(client, accessToken, userID)
+		if ok {
+			userCache[userID] = name
+		}
+		return name
+	}
+
+	// Fetch messages from each channel
+	for _, channelID := range channels {
+		oldest, ok := checkpoints[channelID]
+		if !ok {
+			oldest = backfillOldest
+		}
+		messages, rateLimit, err := fetchSlackHistory(client, accessToken, channelID, oldest)
+		if err != nil {
+			if rateLimit != nil && rateLimit.IsRateLimited() {
+				log.Printf("Rate limited by Slack API on channel %s, skipping until the next sync", channelID)
+				continue
+			}
+			if isSlackAuthError(err) {
+				return stats, &services.ReauthRequiredError{Provider: "Slack", Err: err}
+			}
+			if IsSlackAPIError(err, "not_in_channel") {
+				log.Printf("Skipping Slack channel %s during sync: %v", channelID, err)
+				continue
+			}
+			log.Printf("Failed to fetch messages from channel %s: %v", channelID, err)
+			stats.ItemsFailed++
+			continue
+		}
+
+		// Fetch threads first; each message is stored with its replies.
+		threads := make(map[string][]models.SlackReply)
+		for _, msg := range messages {
+			if msg.ReplyCount == 0 || msg.IsThreadReply() {
+				continue
+			}
+			replies, _, err := client.GetReplies(accessToken, channelID, msg.TS)
+			if err != nil {
+				if isSlackAuthError(err) {
+					return stats, &services.ReauthRequiredError{Provider: "Slack", Err: err}
+				}
+				log.Printf("Failed to fetch Slack thread %s in channel %s: %v", msg.TS, channelID, err)
+				stats.ItemsFailed++
+				continue
+			}
+			for _, reply := range replies {
+				if reply.TS == msg.TS || reply.User == "" {
+					continue
+				}
+				stats.ItemsFetched++
+				threads[msg.TS] = append(threads[msg.TS], slackReply(reply, authorName(reply.User)))
+			}
+		}
+
+		// Process and store messages. handled holds the timestamps that need no
+		// retry: stored messages and ones deliberately skipped. failedTS is the
+		// oldest message that could not be stored.
+		var handled []string
+		var failedTS string
+		failed := func(ts string) {
+			if failedTS == "" || slackTSAfter(failedTS, ts) {
+				failedTS = ts
+			}
+		}
+		for _, msg := range messages {
+			// Thread replies are stored on their parent message.
+			if msg.Type != "message" || msg.User == "" || msg.IsThreadReply() {
+				handled = append(handled, msg.TS)
+				continue
+			}
+			stats.ItemsFetched++
+
+			sourceID := buildSlackSignalSourceID(channelID, msg.TS)
+
+			msgMetadata := slackMessageMetadata(channelID, msg)
+			msgMetadata.Replies = threads[msg.TS]
+			metadataJSON, _ := json.Marshal(msgMetadata)
+
+			title := truncate(msg.Text, 100)
+			if title == "" {
+				title = "Slack Message"
+			}
+
+			created, err := repository.Default().Signals.UpsertSource(repository.SourceSignal{
+				UserID:      integration.UserID,
+				WorkspaceID: integration.WorkspaceID,
+				SourceType:  models.SourceTypeSlack,
+				SourceID:    sourceID,
+				ExternalID:  msg.TS,
+				Title:       title,
+				Content:     msg.Text,
+				Body:        msg.Text,
+				Author:      authorName(msg.User),
+				Status:      models.SignalStatusUnread,
+				Metadata:    string(metadataJSON),
+				ReceivedAt:  time.Unix(msg.Timestamp, 0),
+			}, slackRefreshColumns...)
+			if err != nil {
+				log.Printf("Failed to store Slack signal %s: %v", sourceID, err)
+				stats.ItemsFailed++
+				failed(msg.TS)
+				continue
+			}
+			services.SignalUpserted(integration.WorkspaceID, models.SourceTypeSlack, sourceID, created)
+			stats.ItemsUpserted++
+			handled = append(handled, msg.TS)
+		}
+
+		// Only advance past messages that were stored, and never past one
+		// that failed, so the next sync fetches it again.
+		checkpoint := slackCheckpointBefore(oldest, handled, failedTS)
+		if checkpoint != oldest {
+			if err := saveSlackChannelCheckpoint(integration, channelID, checkpoint); err != nil {
+				log.Printf("Failed to save checkpoint for channel %s: %v", channelID, err)
+			}
+		}
+
+		// Respect rate limits only when Slack actually returned limit metadata.
+		if rateLimit != nil && rateLimit.Limit > 0 && rateLimit.Remaining < 5 {
+			time.Sleep(time.Duration(60/rateLimit.Limit) * time.Second)
+		}
+	}
+
+	log.Printf("Synced Slack integration %d, channels: %d", integration.ID, len(channels))
+	return stats, nil
+}
+
+// truncate truncates a string to maxLen characters
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
+
+func buildSlackSignalSourceID(channelID, messageTS string) string {
+	return channelID + ":" + messageTS
+}
+
+// slackUserName returns the name Slack shows for userID: the real name, else
+// the handle, else the ID itself. ok is false when the lookup failed, so
+// callers don't cache the fallback.
+func slackUserName(client slackSyncClient, accessToken, userID string) (string, bool) {
+	userResp, _, err := client.GetUserInfo(accessToken, userID)
+	if err != nil || userResp == nil {
+		return userID, false
+	}
+	if userResp.User.RealName != "" {
+		return userResp.User.RealName, true
+	}
+	if userResp.User.Name != "" {
+		return userResp.User.Name, true
+	}
+	return userID, true
+}
+
+// fetchSlackHistory pages through a channel's messages newer than oldest,
+// waiting out rate limits between pages.
+func fetchSlackHistory(client slackSyncClient, accessToken, channelID, oldest string) ([]SlackMessage, *RateLimitInfo, error) {
+	var messages []SlackMessage
+	cursor := ""
+	retries := 0
+	for {
+		page, next, rateLimit, err := client.GetMessages(accessToken, channelID, slackPageSize, oldest, cursor)
+		if err != nil {
+			if rateLimit != nil && rateLimit.IsRateLimited() && retries < slackRateLimitRetries {
+				retries++
+				log.Printf("Rate limited by Slack API, waiting %v", rateLimit.WaitDuration())
+				time.Sleep(rateLimit.WaitDuration())
+				continue
+			}
+			return nil, rateLimit, err
+		}
+		messages = append(messages, page...)
+		if next == "" {
+			return messages, rateLimit, nil
+		}
+		cursor = next
+	}
+}
+
+// slackChannelCheckpoints returns the timestamp of the newest stored message
+// of each synced channel.
+func slackChannelCheckpoints(metadata map[string]interface{}) map[string]string {
+	checkpoints := make(map[string]string)
+	stored, _ := metadata[slackCheckpointsKey].(map[string]interface{})
+	for channelID, ts := range stored {
+		if tsStr, ok := ts.(string); ok && tsStr != "" {
+			checkpoints[channelID] = tsStr
+		}
+	}
+	return checkpoints
+}
+
+// slackBackfillWindow is how much history is fetched for a channel that has no
+// checkpoint yet: the integration's backfill_days, then SLACK_BACKFILL_DAYS,
+// then 30 days.
+func slackBackfillWindow(metadata map[string]interface{}) time.Duration {
+	days := defaultSlackBackfillDays
+	if value, err := strconv.Atoi(strings.TrimSpace(os.Getenv("SLACK_BACKFILL_DAYS"))); err == nil && value >= 0 {
+		days = value
+	}
+	if value, ok := metadata["backfill_days"].(float64); ok && value >= 0 {
+		days = int(value)
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// saveSlackChannelCheckpoint records the newest stored message of a channel.
+// The metadata is reloaded so channel selections saved during the sync are
+// kept.
+func saveSlackChannelCheckpoint(integration *models.ExternalIntegration, channelID, ts string) error {
+	workspaceID := integration.WorkspaceID
+	return repository.Default().Integrations.UpdateMetadata(integration.UserID, "slack", &workspaceID, func(metadata map[string]interface{}) {
+		checkpoints, _ := metadata[slackCheckpointsKey].(map[string]interface{})
+		if checkpoints == nil {
+			checkpoints = make(map[string]interface{})
+		}
+		checkpoints[channelID] = ts
+		metadata[slackCheckpointsKey] = checkpoints
+
+		// last_sync stays the newest message across all channels.
+		if seconds, err := strconv.ParseFloat(ts, 64); err == nil {
+			if lastSync, _ := metadata["last_sync"].(float64); seconds > lastSync {
+				metadata["last_sync"] = seconds
+			}
+		}
+	})
+}
+
+// slackCheckpointBefore returns the channel checkpoint to save after a sync:
+// the newest handled timestamp that is older than failedTS, or oldest when no
+// such timestamp is newer than oldest. An empty failedTS means nothing failed.
+func slackCheckpointBefore(oldest string, handled []string, failedTS string) string {
+	checkpoint := oldest
+	for _, ts := range handled {
+		if failedTS != "" && !slackTSAfter(failedTS, ts) {
+			continue
+		}
+		if slackTSAfter(ts, checkpoint) {
+			checkpoint = ts
+		}
+	}
+	return checkpoint
+}
+
+// slackTSAfter reports whether Slack timestamp a is newer than b.
+func slackTSAfter(a, b string) bool {
+	aSeconds, err := strconv.ParseFloat(a, 64)
+	if err != nil {
+		return false
+	}
+	bSeconds, err := strconv.ParseFloat(b, 64)
+	if err != nil {
+		return true
+	}
+	return aSeconds > bSeconds
+}
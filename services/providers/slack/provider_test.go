@@ -0,0 +1,48 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+	"testing"
+)
+
+type recordingSlackExchangeClient struct {
+	mockSlackSyncClient
+	redirectURI string
+}
+
+func (c *recordingSlackExchangeClient) ExchangeCodeForToken(clientID, clientSecret, code, redirectURI string) (*SlackOAuthResponse, error) {
+	c.redirectURI = redirectURI
+	resp := &SlackOAuthResponse{OK: true, AccessToken: "slack-token", BotUserID: "B123"}
+	resp.Team.ID = "T123"
+	return resp, nil
+}
+
+func TestSlackProviderUsesConfiguredRedirectURI(t *testing.T) {
+	t.Setenv("SLACK_REDIRECT_URI", "https://example.ngrok.app/api/integrations/slack/callback")
+
+	client := &recordingSlackExchangeClient{}
+	provider := &slackProvider{client: client, clientID: "client-id", clientSecret: "client-secret"}
+
+	authURL, err := url.Parse(provider.AuthURL("state-value", "http://localhost:8080/api/integrations/slack/callback"))
+	if err != nil {
+		t.Fatalf("failed to parse auth URL: %v", err)
+	}
+	if authURL.Query().Get("redirect_uri") != "https://example.ngrok.app/api/integrations/slack/callback" {
+		t.Fatalf("unexpected redirect URI in auth URL: %q", authURL.Query().Get("redirect_uri"))
+	}
+	if authURL.Query().Get("state") != "state-value" {
+		t.Fatalf("expected state in auth URL, got %q", authURL.Query().Get("state"))
+	}
+
+	grant, err := provider.Exchange(context.Background(), "code", "http://localhost:8080/api/integrations/slack/callback")
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	if client.redirectURI != "https://example.ngrok.app/api/integrations/slack/callback" {
+		t.Fatalf("unexpected redirect URI sent to Slack: %q", client.redirectURI)
+	}
+	if grant.Token.AccessToken != "slack-token" || grant.Metadata["team_id"] != "T123" {
+		t.Fatalf("unexpected grant: %+v", grant)
+	}
+}
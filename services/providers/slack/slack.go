@@ -1,9 +1,8 @@
-package services
+package slack
 
 import (
 	"crypto/hmac"
 	"crypto/sha256"
-	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -11,6 +10,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
 	"strconv"
 	"strings"
 	"time"
@@ -18,16 +20,28 @@ import (
 
 const (
 	SlackAPIBaseURL = "https://slack.com/api"
+
+	// slackPageSize is the page size requested from paginated Slack methods;
+	// Slack recommends no more than 200.
+	slackPageSize = 200
 )
 
-// HTTPDoer abstracts the HTTP client for testability.
-type HTTPDoer interface {
-	Do(req *http.Request) (*http.Response, error)
+// slackAuthErrorCodes are the Slack API errors that mean the token is no
+// longer usable.
+var slackAuthErrorCodes = []string{"invalid_auth", "token_revoked", "token_expired", "account_inactive", "not_authed"}
+
+func isSlackAuthError(err error) bool {
+	for _, code := range slackAuthErrorCodes {
+		if IsSlackAPIError(err, code) {
+			return true
+		}
+	}
+	return false
 }
 
 // SlackClient handles interactions with the Slack API
 type SlackClient struct {
-	HTTPClient HTTPDoer
+	HTTPClient services.HTTPDoer
 	BaseURL    string
 }
 
@@ -68,26 +82,58 @@ type SlackChannel struct {
 
 // SlackChannelsResponse represents the response from conversations.list
 type SlackChannelsResponse struct {
-	OK       bool           `json:"ok"`
-	Channels []SlackChannel `json:"channels"`
-	Error    string         `json:"error"`
+	OK               bool                  `json:"ok"`
+	Channels         []SlackChannel        `json:"channels"`
+	Error            string                `json:"error"`
+	ResponseMetadata SlackResponseMetadata `json:"response_metadata"`
+}
+
+// SlackResponseMetadata carries the cursor of the next page of a paginated
+// Slack response. NextCursor is empty on the last page.
+type SlackResponseMetadata struct {
+	NextCursor string `json:"next_cursor"`
 }
 
 // SlackMessage represents a Slack message
 type SlackMessage struct {
-	Type      string `json:"type"`
-	User      string `json:"user"`
-	Text      string `json:"text"`
-	TS        string `json:"ts"`
-	Timestamp int64
+	Type       string          `json:"type"`
+	SubType    string          `json:"subtype,omitempty"`
+	User       string          `json:"user"`
+	Text       string          `json:"text"`
+	TS         string          `json:"ts"`
+	ThreadTS   string          `json:"thread_ts,omitempty"`
+	ReplyCount int             `json:"reply_count,omitempty"`
+	Reactions  []SlackReaction `json:"reactions,omitempty"`
+	Edited     *SlackEdit      `json:"edited,omitempty"`
+	Timestamp  int64
+}
+
+// SlackReaction is one emoji reaction on a message.
+type SlackReaction struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Users []string `json:"users,omitempty"`
+}
+
+// SlackEdit records the last edit of a message.
+type SlackEdit struct {
+	User string `json:"user"`
+	TS   string `json:"ts"`
+}
+
+// IsThreadReply reports whether the message is a reply inside another
+// message's thread.
+func (m SlackMessage) IsThreadReply() bool {
+	return m.ThreadTS != "" && m.ThreadTS != m.TS
 }
 
 // SlackMessagesResponse represents the response from conversations.history
 type SlackMessagesResponse struct {
-	OK       bool           `json:"ok"`
-	Messages []SlackMessage `json:"messages"`
-	HasMore  bool           `json:"has_more"`
-	Error    string         `json:"error"`
+	OK               bool                  `json:"ok"`
+	Messages         []SlackMessage        `json:"messages"`
+	HasMore          bool                  `json:"has_more"`
+	Error            string                `json:"error"`
+	ResponseMetadata SlackResponseMetadata `json:"response_metadata"`
 }
 
 // SlackUserResponse represents the response from users.info
@@ -154,22 +200,43 @@ func (c *SlackClient) ExchangeCodeForToken(clientID, clientSecret, code, redirec
 	return &oauthResp, nil
 }
 
-// GetChannels retrieves the list of channels for a workspace
+// GetChannels retrieves the list of channels for a workspace, following
+// cursors until every page has been read
 func (c *SlackClient) GetChannels(accessToken string) ([]SlackChannel, *RateLimitInfo, error) {
+	var channels []SlackChannel
+	cursor := ""
+	for {
+		page, next, rateLimit, err := c.getChannelsPage(accessToken, cursor)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+		channels = append(channels, page...)
+		if next == "" {
+			return channels, rateLimit, nil
+		}
+		cursor = next
+	}
+}
+
+func (c *SlackClient) getChannelsPage(accessToken, cursor string) ([]SlackChannel, string, *RateLimitInfo, error) {
 	req, err := http.NewRequest("GET", c.BaseURL+"/conversations.list", nil)
 	if err != nil {
-		return nil, nil, err
+		return nil, "", nil, err
 	}
 
 	q := req.URL.Query()
 	q.Add("types", "public_channel,private_channel")
 	q.Add("exclude_archived", "true")
+	q.Add("limit", strconv.Itoa(slackPageSize))
+	if cursor != "" {
+		q.Add("cursor", cursor)
+	}
 	req.URL.RawQuery = q.Encode()
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, "", nil, err
 	}
 	defer resp.Body.Close()
 
@@ -177,29 +244,25 @@ func (c *SlackClient) GetChannels(accessToken string) ([]SlackChannel, *RateLimi
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, rateLimit, err
+		return nil, "", rateLimit, err
 	}
 
 	var channelsResp SlackChannelsResponse
 	if err := json.Unmarshal(body, &channelsResp); err != nil {
-		return nil, rateLimit, err
+		return nil, "", rateLimit, err
 	}
 
 	if !channelsResp.OK {
-		return nil, rateLimit, &SlackAPIError{Code: channelsResp.Error}
+		return nil, "", rateLimit, &SlackAPIError{Code: channelsResp.Error}
 	}
 
-	return channelsResp.Channels, rateLimit, nil
+	return channelsResp.Channels, channelsResp.ResponseMetadata.NextCursor, rateLimit, nil
 }
 
-// GetMessages retrieves messages from a channel
-func (c *SlackClient) GetMessages(accessToken, channelID string, limit int, oldest string) ([]SlackMessage, *RateLimitInfo, error) {
-	req, err := http.NewRequest("GET", c.BaseURL+"/conversations.history", nil)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	q := req.URL.Query()
+// GetMessages retrieves one page of messages from a channel, newest first,
+// and returns the cursor of the next page, which is empty on the last page
+func (c *SlackClient) GetMessages(accessToken, channelID string, limit int, oldest, cursor string) ([]SlackMessage, string, *RateLimitInfo, error) {
+	q := url.Values{}
 	q.Add("channel", channelID)
 	if limit > 0 {
 		q.Add("limit", strconv.Itoa(limit))
@@ -207,12 +270,48 @@ func (c *SlackClient) GetMessages(accessToken, channelID string, limit int, olde
 	if oldest != "" {
 		q.Add("oldest", oldest)
 	}
+	if cursor != "" {
+		q.Add("cursor", cursor)
+	}
+	return c.fetchMessages(accessToken, "/conversations.history", q)
+}
+
+// GetReplies retrieves a thread, starting with its parent message, following
+// cursors until every page has been read
+func (c *SlackClient) GetReplies(accessToken, channelID, threadTS string) ([]SlackMessage, *RateLimitInfo, error) {
+	var messages []SlackMessage
+	cursor := ""
+	for {
+		q := url.Values{}
+		q.Add("channel", channelID)
+		q.Add("ts", threadTS)
+		q.Add("limit", strconv.Itoa(slackPageSize))
+		if cursor != "" {
+			q.Add("cursor", cursor)
+		}
+		page, next, rateLimit, err := c.fetchMessages(accessToken, "/conversations.replies", q)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+		messages = append(messages, page...)
+		if next == "" {
+			return messages, rateLimit, nil
+		}
+		cursor = next
+	}
+}
+
+func (c *SlackClient) fetchMessages(accessToken, endpoint string, q url.Values) ([]SlackMessage, string, *RateLimitInfo, error) {
+	req, err := http.NewRequest("GET", c.BaseURL+endpoint, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
 	req.URL.RawQuery = q.Encode()
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, "", nil, err
 	}
 	defer resp.Body.Close()
 
@@ -220,31 +319,31 @@ func (c *SlackClient) GetMessages(accessToken, channelID string, limit int, olde
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, rateLimit, err
+		return nil, "", rateLimit, err
 	}
 
 	var messagesResp SlackMessagesResponse
 	if err := json.Unmarshal(body, &messagesResp); err != nil {
-		return nil, rateLimit, err
+		return nil, "", rateLimit, err
 	}
 
 	if !messagesResp.OK {
-		return nil, rateLimit, &SlackAPIError{Code: messagesResp.Error}
+		return nil, "", rateLimit, &SlackAPIError{Code: messagesResp.Error}
 	}
 
-	// Convert timestamps
 	for i := range messagesResp.Messages {
-		if ts := messagesResp.Messages[i].TS; ts != "" {
-			// Parse Slack timestamp (seconds.microseconds)
-			parts := strings.Split(ts, ".")
-			if len(parts) > 0 {
-				sec, _ := strconv.ParseInt(parts[0], 10, 64)
-				messagesResp.Messages[i].Timestamp = sec
-			}
-		}
+		messagesResp.Messages[i].Timestamp = parseSlackTimestamp(messagesResp.Messages[i].TS)
 	}
 
-	return messagesResp.Messages, rateLimit, nil
+	return messagesResp.Messages, messagesResp.ResponseMetadata.NextCursor, rateLimit, nil
+}
+
+// parseSlackTimestamp returns the seconds of a Slack timestamp
+// (seconds.microseconds).
+func parseSlackTimestamp(ts string) int64 {
+	seconds, _, _ := strings.Cut(ts, ".")
+	sec, _ := strconv.ParseInt(seconds, 10, 64)
+	return sec
 }
 
 // GetUserInfo retrieves user information
@@ -325,8 +424,9 @@ func (c *SlackClient) MarkMessageAsRead(accessToken, channelID, timestamp string
 	return rateLimit, nil
 }
 
-// PostMessage posts a message to a channel, optionally in a thread
-func (c *SlackClient) PostMessage(accessToken, channelID, text, threadTS string) (*RateLimitInfo, error) {
+// PostMessage posts a message to a channel, optionally in a thread, and
+// returns the posted message
+func (c *SlackClient) PostMessage(accessToken, channelID, text, threadTS string) (*SlackMessage, *RateLimitInfo, error) {
 	data := url.Values{}
 	data.Set("channel", channelID)
 	data.Set("text", text)
@@ -336,14 +436,14 @@ func (c *SlackClient) PostMessage(accessToken, channelID, text, threadTS string)
 
 	req, err := http.NewRequest("POST", c.BaseURL+"/chat.postMessage", strings.NewReader(data.Encode()))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
@@ -351,22 +451,32 @@ func (c *SlackClient) PostMessage(accessToken, channelID, text, threadTS string)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return rateLimit, err
+		return nil, rateLimit, err
 	}
 
 	var result struct {
-		OK    bool   `json:"ok"`
-		Error string `json:"error"`
+		OK      bool         `json:"ok"`
+		Error   string       `json:"error"`
+		TS      string       `json:"ts"`
+		Message SlackMessage `json:"message"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return rateLimit, err
+		return nil, rateLimit, err
 	}
 
 	if !result.OK {
-		return rateLimit, &SlackAPIError{Code: result.Error}
+		return nil, rateLimit, &SlackAPIError{Code: result.Error}
 	}
 
-	return rateLimit, nil
+	posted := result.Message
+	if posted.TS == "" {
+		posted.TS = result.TS
+	}
+	if posted.ThreadTS == "" {
+		posted.ThreadTS = threadTS
+	}
+	posted.Timestamp = parseSlackTimestamp(posted.TS)
+	return &posted, rateLimit, nil
 }
 
 // extractRateLimit extracts rate limit headers from the response
@@ -426,14 +536,26 @@ type SlackWebhookEvent struct {
 	Challenge   string          `json:"challenge"`
 }
 
-// SlackMessageEvent represents a message event from Slack
+// SlackMessageEvent represents a message or reaction event from Slack.
+// Edits carry the new message in Message, deletes carry DeletedTS and the
+// removed PreviousMessage, and reactions name the reacted-to message in Item.
 type SlackMessageEvent struct {
-	Type     string `json:"type"`
-	Channel  string `json:"channel"`
-	User     string `json:"user"`
-	Text     string `json:"text"`
-	TS       string `json:"ts"`
-	ThreadTS string `json:"thread_ts"`
+	Type            string        `json:"type"`
+	SubType         string        `json:"subtype"`
+	Channel         string        `json:"channel"`
+	User            string        `json:"user"`
+	Text            string        `json:"text"`
+	TS              string        `json:"ts"`
+	ThreadTS        string        `json:"thread_ts"`
+	Message         *SlackMessage `json:"message"`
+	PreviousMessage *SlackMessage `json:"previous_message"`
+	DeletedTS       string        `json:"deleted_ts"`
+	Reaction        string        `json:"reaction"`
+	Item            *struct {
+		Type    string `json:"type"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	} `json:"item"`
 }
 
 // ValidateWebhookRequest validates that a webhook request is from Slack
@@ -475,38 +597,76 @@ func (c *SlackClient) ValidateWebhookRequest(body []byte, signature, timestamp,
 }
 
 // SaveSlackSignal saves a Slack message as a signal in the database
-func SaveSlackSignal(db *sql.DB, userID, workspaceID int, msg SlackMessage, channelID string, authorName string) error {
+func SaveSlackSignal(userID, workspaceID int, msg SlackMessage, channelID string, authorName string) error {
 	sourceID := channelID + ":" + msg.TS
 
-	// Store metadata
-	msgMetadata := map[string]interface{}{
-		"channel_id": channelID,
-		"ts":         msg.TS,
-		"user_id":    msg.User,
-	}
-	metadataJSON, _ := json.Marshal(msgMetadata)
+	metadataJSON, _ := json.Marshal(slackMessageMetadata(channelID, msg))
 
 	title := truncateString(msg.Text, 100)
 	if title == "" {
 		title = "Slack Message"
 	}
 
-	// Use UPSERT to handle duplicates and updates
-	_, err := db.Exec(
-		`INSERT INTO signals 
-		 (user_id, workspace_id, source_type, source_id, external_id, title, content, body, author, status, source_metadata, received_at, updated_at)
-		 VALUES (?, ?, 'slack', ?, ?, ?, ?, ?, ?, 'unread', ?, ?, CURRENT_TIMESTAMP)
-		 ON CONFLICT(user_id, source_type, source_id) DO UPDATE SET
-			title = excluded.title,
-			content = excluded.content,
-			body = excluded.body,
-			author = excluded.author,
-			source_metadata = excluded.source_metadata,
-			updated_at = CURRENT_TIMESTAMP`,
-		userID, workspaceID, sourceID, msg.TS, title, msg.Text, msg.Text, authorName,
-		string(metadataJSON), time.Unix(msg.Timestamp, 0),
-	)
-	return err
+	created, err := repository.Default().Signals.UpsertSource(repository.SourceSignal{
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		SourceType:  models.SourceTypeSlack,
+		SourceID:    sourceID,
+		ExternalID:  msg.TS,
+		Title:       title,
+		Content:     msg.Text,
+		Body:        msg.Text,
+		Author:      authorName,
+		Status:      models.SignalStatusUnread,
+		Metadata:    string(metadataJSON),
+		ReceivedAt:  time.Unix(msg.Timestamp, 0),
+	}, slackRefreshColumns...)
+	if err != nil {
+		return err
+	}
+
+	services.SignalUpserted(workspaceID, models.SourceTypeSlack, sourceID, created)
+	return nil
+}
+
+// slackRefreshColumns are the columns rewritten when a stored message is seen
+// again. The external ID is the message timestamp and never changes.
+var slackRefreshColumns = []repository.SourceColumn{
+	repository.SourceTitle, repository.SourceContent, repository.SourceBody,
+	repository.SourceAuthor, repository.SourceMetadata,
+}
+
+// slackMessageMetadata builds the metadata stored with a message signal.
+func slackMessageMetadata(channelID string, msg SlackMessage) models.SlackMetadata {
+	metadata := models.SlackMetadata{
+		ChannelID:  channelID,
+		TS:         msg.TS,
+		UserID:     msg.User,
+		ReplyCount: msg.ReplyCount,
+	}
+	if len(msg.Reactions) > 0 {
+		metadata.Reactions = make(map[string]int, len(msg.Reactions))
+		for _, reaction := range msg.Reactions {
+			metadata.Reactions[reaction.Name] = reaction.Count
+		}
+	}
+	if msg.Edited != nil {
+		metadata.EditedTS = msg.Edited.TS
+	}
+	return metadata
+}
+
+func slackReply(msg SlackMessage, author string) models.SlackReply {
+	reply := models.SlackReply{
+		TS:     msg.TS,
+		UserID: msg.User,
+		Author: author,
+		Text:   msg.Text,
+	}
+	if msg.Edited != nil {
+		reply.EditedTS = msg.Edited.TS
+	}
+	return reply
 }
 
 func truncateString(s string, maxLen int) string {
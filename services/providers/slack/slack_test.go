@@ -1,4 +1,4 @@
-package services
+package slack
 
 import (
 	"crypto/hmac"
@@ -133,6 +133,69 @@ func TestSlackClient_GetChannels_ExcludesArchived(t *testing.T) {
 	}
 }
 
+func TestSlackClient_GetChannels_FollowsCursor(t *testing.T) {
+	var cursors []string
+	client := &SlackClient{
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				cursor := req.URL.Query().Get("cursor")
+				cursors = append(cursors, cursor)
+				if cursor == "" {
+					return newMockResponse(200, map[string]interface{}{
+						"ok":                true,
+						"channels":          []map[string]interface{}{{"id": "C1", "name": "general"}},
+						"response_metadata": map[string]interface{}{"next_cursor": "page2"},
+					}, nil)
+				}
+				return newMockResponse(200, map[string]interface{}{
+					"ok":                true,
+					"channels":          []map[string]interface{}{{"id": "C2", "name": "random"}},
+					"response_metadata": map[string]interface{}{"next_cursor": ""},
+				}, nil)
+			},
+		},
+		BaseURL: SlackAPIBaseURL,
+	}
+
+	channels, _, err := client.GetChannels("test-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(channels) != 2 || channels[1].ID != "C2" {
+		t.Fatalf("expected channels from both pages, got %+v", channels)
+	}
+	if len(cursors) != 2 || cursors[1] != "page2" {
+		t.Fatalf("expected the second request to send the cursor, got %v", cursors)
+	}
+}
+
+func TestSlackClient_GetMessages_ReturnsNextCursor(t *testing.T) {
+	client := &SlackClient{
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Query().Get("cursor") != "abc" {
+					t.Errorf("expected cursor=abc, got %q", req.URL.Query().Get("cursor"))
+				}
+				return newMockResponse(200, map[string]interface{}{
+					"ok":                true,
+					"messages":          []map[string]interface{}{{"type": "message", "user": "U1", "text": "hello", "ts": "1234567890.123"}},
+					"has_more":          true,
+					"response_metadata": map[string]interface{}{"next_cursor": "def"},
+				}, nil)
+			},
+		},
+		BaseURL: SlackAPIBaseURL,
+	}
+
+	messages, next, _, err := client.GetMessages("test-token", "C123", 200, "", "abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 1 || next != "def" {
+		t.Fatalf("expected one message and next cursor def, got %d messages and %q", len(messages), next)
+	}
+}
+
 func TestSlackClient_GetMessages_ReturnsMessages(t *testing.T) {
 	client := &SlackClient{
 		HTTPClient: &mockHTTPClient{
@@ -156,7 +219,7 @@ func TestSlackClient_GetMessages_ReturnsMessages(t *testing.T) {
 		BaseURL: SlackAPIBaseURL,
 	}
 
-	messages, _, err := client.GetMessages("test-token", "C123", 10, "")
+	messages, _, _, err := client.GetMessages("test-token", "C123", 10, "", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -186,7 +249,7 @@ func TestSlackClient_GetMessages_WithOldestParameter(t *testing.T) {
 		BaseURL: SlackAPIBaseURL,
 	}
 
-	_, _, _ = client.GetMessages("test-token", "C123", 0, "1234567890.000")
+	_, _, _, _ = client.GetMessages("test-token", "C123", 0, "1234567890.000", "")
 	if !strings.Contains(capturedURL, "oldest=1234567890.000") {
 		t.Errorf("expected oldest parameter in URL: %s", capturedURL)
 	}
@@ -205,7 +268,7 @@ func TestSlackClient_GetMessages_InvalidChannel(t *testing.T) {
 		BaseURL: SlackAPIBaseURL,
 	}
 
-	_, _, err := client.GetMessages("test-token", "C999", 10, "")
+	_, _, _, err := client.GetMessages("test-token", "C999", 10, "", "")
 	if err == nil {
 		t.Fatal("expected error for channel_not_found")
 	}
@@ -214,6 +277,67 @@ func TestSlackClient_GetMessages_InvalidChannel(t *testing.T) {
 	}
 }
 
+func TestSlackClient_GetReplies_ReturnsThread(t *testing.T) {
+	client := &SlackClient{
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path != "/api/conversations.replies" {
+					t.Errorf("expected path /api/conversations.replies, got %s", req.URL.Path)
+				}
+				if req.URL.Query().Get("ts") != "1234567890.123" {
+					t.Errorf("expected ts=1234567890.123")
+				}
+				return newMockResponse(200, map[string]interface{}{
+					"ok": true,
+					"messages": []map[string]interface{}{
+						{"type": "message", "user": "U1", "text": "parent", "ts": "1234567890.123", "thread_ts": "1234567890.123", "reply_count": 1},
+						{"type": "message", "user": "U2", "text": "reply", "ts": "1234567891.456", "thread_ts": "1234567890.123",
+							"reactions": []map[string]interface{}{{"name": "thumbsup", "count": 2, "users": []string{"U1", "U3"}}}},
+					},
+				}, nil)
+			},
+		},
+		BaseURL: SlackAPIBaseURL,
+	}
+
+	messages, _, err := client.GetReplies("test-token", "C123", "1234567890.123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].IsThreadReply() || !messages[1].IsThreadReply() {
+		t.Fatalf("expected only the second message to be a reply: %+v", messages)
+	}
+	if messages[0].ReplyCount != 1 || len(messages[1].Reactions) != 1 || messages[1].Reactions[0].Count != 2 {
+		t.Fatalf("unexpected thread data: %+v", messages)
+	}
+}
+
+func TestSlackClient_PostMessage_ReturnsPostedMessage(t *testing.T) {
+	client := &SlackClient{
+		HTTPClient: &mockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return newMockResponse(200, map[string]interface{}{
+					"ok":      true,
+					"ts":      "1234567899.000",
+					"message": map[string]interface{}{"type": "message", "user": "UBOT", "text": "On it"},
+				}, nil)
+			},
+		},
+		BaseURL: SlackAPIBaseURL,
+	}
+
+	posted, _, err := client.PostMessage("test-token", "C123", "On it", "1234567890.123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if posted.TS != "1234567899.000" || posted.ThreadTS != "1234567890.123" || posted.User != "UBOT" {
+		t.Fatalf("unexpected posted message: %+v", posted)
+	}
+}
+
 func TestSlackClient_GetUserInfo_ReturnsUser(t *testing.T) {
 	client := &SlackClient{
 		HTTPClient: &mockHTTPClient{
@@ -551,7 +675,7 @@ func TestGetMessages_InvalidLimit(t *testing.T) {
 		BaseURL: SlackAPIBaseURL,
 	}
 
-	_, _, _ = client.GetMessages("test-token", "C123", 0, "")
+	_, _, _, _ = client.GetMessages("test-token", "C123", 0, "", "")
 }
 
 func TestIsSlackAPIError_Helper(t *testing.T) {
@@ -0,0 +1,494 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/models"
+	"sentinent-backend/services"
+	"sentinent-backend/services/internal/servicestest"
+	"sentinent-backend/utils"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type mockSlackSyncClient struct {
+	channels        []SlackChannel
+	messages        []SlackMessage
+	channelMessages map[string][]SlackMessage
+	pageSize        int
+	oldest          map[string]string
+	replies         map[string][]SlackMessage
+	msgErr          error
+	posted          []SlackMessage
+	userNames       map[string]string
+}
+
+func (m *mockSlackSyncClient) GetChannels(accessToken string) ([]SlackChannel, *RateLimitInfo, error) {
+	return m.channels, nil, nil
+}
+
+func (m *mockSlackSyncClient) GetMessages(accessToken, channelID string, limit int, oldest, cursor string) ([]SlackMessage, string, *RateLimitInfo, error) {
+	if m.oldest == nil {
+		m.oldest = make(map[string]string)
+	}
+	m.oldest[channelID] = oldest
+	if m.msgErr != nil {
+		return nil, "", nil, m.msgErr
+	}
+	messages := m.messages
+	if m.channelMessages != nil {
+		messages = m.channelMessages[channelID]
+	}
+	if m.pageSize == 0 {
+		return messages, "", nil, nil
+	}
+
+	// Pages are addressed by the index of their first message.
+	start, _ := strconv.Atoi(cursor)
+	end := start + m.pageSize
+	if end >= len(messages) {
+		return messages[start:], "", nil, nil
+	}
+	return messages[start:end], strconv.Itoa(end), nil, nil
+}
+
+func (m *mockSlackSyncClient) GetReplies(accessToken, channelID, threadTS string) ([]SlackMessage, *RateLimitInfo, error) {
+	return m.replies[threadTS], nil, nil
+}
+
+func (m *mockSlackSyncClient) GetUserInfo(accessToken, userID string) (*SlackUserResponse, *RateLimitInfo, error) {
+	name, ok := m.userNames[userID]
+	if !ok {
+		return nil, nil, nil
+	}
+	userResp := &SlackUserResponse{OK: true}
+	userResp.User.ID = userID
+	userResp.User.RealName = name
+	return userResp, nil, nil
+}
+
+func (m *mockSlackSyncClient) ExchangeCodeForToken(clientID, clientSecret, code, redirectURI string) (*SlackOAuthResponse, error) {
+	return nil, nil
+}
+
+func (m *mockSlackSyncClient) PostMessage(accessToken, channelID, text, threadTS string) (*SlackMessage, *RateLimitInfo, error) {
+	msg := SlackMessage{Type: "message", User: "UBOT", Text: text, TS: fmt.Sprintf("1710000100.%06d", len(m.posted)), ThreadTS: threadTS}
+	m.posted = append(m.posted, msg)
+	return &msg, nil, nil
+}
+
+func TestSyncSlackIntegrationStoresMultipleMessagesPerChannelWithoutDuplicates(t *testing.T) {
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 7, 42)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/conversations.history":
+			_ = json.NewEncoder(w).Encode(SlackMessagesResponse{
+				OK: true,
+				Messages: []SlackMessage{
+					{Type: "message", User: "U1", Text: "First message", TS: "1710000000.000100"},
+					{Type: "message", User: "U2", Text: "Second message", TS: "1710000001.000200"},
+				},
+			})
+		case "/users.info":
+			userID := r.URL.Query().Get("user")
+			name := "Unknown"
+			if userID == "U1" {
+				name = "Alice"
+			}
+			if userID == "U2" {
+				name = "Bob"
+			}
+			_ = json.NewEncoder(w).Encode(SlackUserResponse{
+				OK: true,
+				User: struct {
+					ID       string `json:"id"`
+					Name     string `json:"name"`
+					RealName string `json:"real_name"`
+				}{
+					ID:       userID,
+					RealName: name,
+				},
+			})
+		default:
+			t.Fatalf("unexpected Slack API path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := &SlackClient{
+		HTTPClient: server.Client(),
+		BaseURL:    server.URL,
+	}
+
+	integration := &models.ExternalIntegration{
+		ID:          1,
+		UserID:      42,
+		WorkspaceID: 7,
+		Provider:    "slack",
+		Metadata:    `{"selected_channels":["C123"]}`,
+	}
+
+	if _, err := syncSlackIntegration(client, integration, "test-token"); err != nil {
+		t.Fatalf("first Slack sync returned error: %v", err)
+	}
+	stats, err := syncSlackIntegration(client, integration, "test-token")
+	if err != nil {
+		t.Fatalf("second Slack sync returned error: %v", err)
+	}
+	if stats.ItemsFetched != 2 || stats.ItemsUpserted != 2 {
+		t.Fatalf("expected 2 fetched and upserted items, got %+v", stats)
+	}
+
+	var total int
+	if err := database.DB.QueryRow(
+		`SELECT COUNT(*) FROM signals WHERE user_id = ? AND workspace_id = ? AND source_type = ?`,
+		42, 7, models.SourceTypeSlack,
+	).Scan(&total); err != nil {
+		t.Fatalf("failed to count synced signals: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("expected 2 synced signals, got %d", total)
+	}
+
+	rows, err := database.DB.Query(
+		`SELECT source_id, external_id FROM signals WHERE user_id = ? AND source_type = ? ORDER BY source_id`,
+		42, models.SourceTypeSlack,
+	)
+	if err != nil {
+		t.Fatalf("failed to query synced signals: %v", err)
+	}
+	defer rows.Close()
+
+	var rowsRead int
+	for rows.Next() {
+		var sourceID string
+		var externalID string
+		if err := rows.Scan(&sourceID, &externalID); err != nil {
+			t.Fatalf("failed to scan synced signal: %v", err)
+		}
+		rowsRead++
+		if sourceID != buildSlackSignalSourceID("C123", externalID) {
+			t.Fatalf("expected source_id to include channel and message ts, got %q for external_id %q", sourceID, externalID)
+		}
+	}
+	if rowsRead != 2 {
+		t.Fatalf("expected to inspect 2 rows, got %d", rowsRead)
+	}
+}
+
+func TestSlackProviderSyncRecordsLastSync(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		t.Fatalf("failed to create encryptor: %v", err)
+	}
+
+	encryptedToken, err := encryptor.Encrypt("slack-token")
+	if err != nil {
+		t.Fatalf("failed to encrypt token: %v", err)
+	}
+
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (?, ?, 'slack', ?, '{}')`,
+		1, 1, encryptedToken,
+	); err != nil {
+		t.Fatalf("failed to seed integration: %v", err)
+	}
+
+	provider := &slackProvider{
+		client: &mockSlackSyncClient{
+			channels: []SlackChannel{{ID: "C123", Name: "general"}},
+			messages: []SlackMessage{{Type: "message", User: "U1", Text: "Hello", TS: fmt.Sprintf("%d.000100", time.Now().Unix())}},
+		},
+		clientID:     "slack-client",
+		clientSecret: "slack-secret",
+	}
+	if _, err := provider.Sync(1, 1); err != nil {
+		t.Fatalf("Slack sync returned error: %v", err)
+	}
+
+	var metadataJSON string
+	if err := database.DB.QueryRow(
+		"SELECT metadata FROM external_integrations WHERE provider = 'slack'",
+	).Scan(&metadataJSON); err != nil {
+		t.Fatalf("failed to query integration metadata: %v", err)
+	}
+
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to unmarshal metadata: %v", err)
+	}
+	if _, ok := metadata["last_sync"]; !ok {
+		t.Fatalf("expected last_sync in metadata, got %s", metadataJSON)
+	}
+}
+
+func TestSyncSlackIntegrationSkipsNotInChannelErrors(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	dbtest.Exec(t,
+		`INSERT INTO external_integrations (id, user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (?, ?, ?, 'slack', ?, '{}')`,
+		1, 1, 1, "ignored",
+	)
+
+	client := &mockSlackSyncClient{
+		channels: []SlackChannel{{ID: "C123", Name: "general"}},
+		msgErr:   &SlackAPIError{Code: "not_in_channel"},
+	}
+
+	integration := &models.ExternalIntegration{
+		ID:          1,
+		UserID:      1,
+		WorkspaceID: 1,
+		Provider:    "slack",
+		Metadata:    "{}",
+	}
+
+	if _, err := syncSlackIntegration(client, integration, "slack-token"); err != nil {
+		t.Fatalf("Slack sync returned error: %v", err)
+	}
+
+	var metadataJSON string
+	if err := database.DB.QueryRow(
+		"SELECT metadata FROM external_integrations WHERE id = 1",
+	).Scan(&metadataJSON); err != nil {
+		t.Fatalf("failed to query integration metadata: %v", err)
+	}
+
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to unmarshal metadata: %v", err)
+	}
+	if _, ok := metadata["last_sync"]; ok {
+		t.Fatalf("expected last_sync NOT to be updated after skipping inaccessible channel, got %s", metadataJSON)
+	}
+	if !IsSlackAPIError(client.msgErr, "not_in_channel") {
+		t.Fatal("expected not_in_channel to be recognized as a Slack API error")
+	}
+}
+
+func TestSyncSlackIntegrationAttachesThreadRepliesAndReactions(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	dbtest.Exec(t,
+		`INSERT INTO external_integrations (id, user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (?, ?, ?, 'slack', ?, '{}')`,
+		1, 1, 1, "ignored",
+	)
+
+	parent := SlackMessage{
+		Type:       "message",
+		User:       "U1",
+		Text:       "Deploy tonight?",
+		TS:         "1710000000.000100",
+		ThreadTS:   "1710000000.000100",
+		ReplyCount: 1,
+		Reactions:  []SlackReaction{{Name: "eyes", Count: 2}},
+	}
+	reply := SlackMessage{Type: "message", User: "U2", Text: "Yes, after 6pm", TS: "1710000050.000200", ThreadTS: parent.TS}
+	client := &mockSlackSyncClient{
+		channels: []SlackChannel{{ID: "C123", Name: "general"}},
+		// conversations.history can include broadcast replies alongside parents.
+		messages: []SlackMessage{reply, parent},
+		replies:  map[string][]SlackMessage{parent.TS: {parent, reply}},
+	}
+	integration := &models.ExternalIntegration{ID: 1, UserID: 1, WorkspaceID: 1, Provider: "slack", Metadata: "{}"}
+
+	stats, err := syncSlackIntegration(client, integration, "slack-token")
+	if err != nil {
+		t.Fatalf("Slack sync returned error: %v", err)
+	}
+	if stats.ItemsUpserted != 1 {
+		t.Fatalf("expected only the thread parent to be stored, got %+v", stats)
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals WHERE source_type = 'slack'").Scan(&count); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected thread replies not to be stored as signals, got %d signals", count)
+	}
+
+	metadata := loadSlackMetadata(t, "C123:"+parent.TS)
+	if metadata.ReplyCount != 1 || len(metadata.Replies) != 1 || metadata.Replies[0].Text != reply.Text {
+		t.Fatalf("expected thread reply on parent signal, got %+v", metadata)
+	}
+	if metadata.Reactions["eyes"] != 2 {
+		t.Fatalf("expected reaction counts in metadata, got %+v", metadata.Reactions)
+	}
+}
+
+func TestSyncSlackIntegrationPagesHistoryAndCheckpointsEachChannel(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	now := time.Now().Unix()
+	checkpoint := fmt.Sprintf("%d.000000", now-3600)
+	dbtest.Exec(t,
+		`INSERT INTO external_integrations (id, user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (1, 1, 1, 'slack', 'ignored', ?)`,
+		`{"selected_channels":["C1","C2"],"backfill_days":7,"channel_checkpoints":{"C1":"`+checkpoint+`"}}`,
+	)
+
+	// Slack returns history newest first.
+	busy := make([]SlackMessage, 0, 5)
+	for i := 5; i >= 1; i-- {
+		busy = append(busy, SlackMessage{Type: "message", User: "U1", Text: fmt.Sprintf("message %d", i), TS: fmt.Sprintf("%d.%06d", now-60, i)})
+	}
+	client := &mockSlackSyncClient{
+		channelMessages: map[string][]SlackMessage{
+			"C1": busy,
+			"C2": {{Type: "message", User: "U2", Text: "first", TS: fmt.Sprintf("%d.000001", now-86400)}},
+		},
+		pageSize: 2,
+	}
+
+	var integration models.ExternalIntegration
+	if err := database.DB.QueryRow("SELECT metadata FROM external_integrations WHERE id = 1").Scan(&integration.Metadata); err != nil {
+		t.Fatalf("failed to load metadata: %v", err)
+	}
+	integration.ID, integration.UserID, integration.WorkspaceID, integration.Provider = 1, 1, 1, "slack"
+
+	stats, err := syncSlackIntegration(client, &integration, "slack-token")
+	if err != nil {
+		t.Fatalf("Slack sync returned error: %v", err)
+	}
+	if stats.ItemsUpserted != 6 {
+		t.Fatalf("expected every page to be stored, got %+v", stats)
+	}
+
+	if client.oldest["C1"] != checkpoint {
+		t.Fatalf("expected C1 to resume from its checkpoint, got %q", client.oldest["C1"])
+	}
+	backfillFrom, err := strconv.ParseFloat(client.oldest["C2"], 64)
+	if err != nil {
+		t.Fatalf("expected a backfill start for C2, got %q", client.oldest["C2"])
+	}
+	if want := float64(now - 7*86400); backfillFrom < want-60 || backfillFrom > want+60 {
+		t.Fatalf("expected C2 to be backfilled over 7 days, got oldest %q", client.oldest["C2"])
+	}
+
+	var metadataJSON string
+	if err := database.DB.QueryRow("SELECT metadata FROM external_integrations WHERE id = 1").Scan(&metadataJSON); err != nil {
+		t.Fatalf("failed to query integration metadata: %v", err)
+	}
+	var metadata struct {
+		SelectedChannels []string          `json:"selected_channels"`
+		Checkpoints      map[string]string `json:"channel_checkpoints"`
+		LastSync         float64           `json:"last_sync"`
+	}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to unmarshal metadata: %v", err)
+	}
+	if metadata.Checkpoints["C1"] != busy[0].TS || metadata.Checkpoints["C2"] != client.channelMessages["C2"][0].TS {
+		t.Fatalf("expected per-channel checkpoints at the newest messages, got %s", metadataJSON)
+	}
+	if len(metadata.SelectedChannels) != 2 || metadata.LastSync < float64(now-60) {
+		t.Fatalf("expected selection kept and last_sync advanced, got %s", metadataJSON)
+	}
+}
+
+func TestSyncSlackIntegrationKeepsCheckpointBeforeFailedMessage(t *testing.T) {
+	servicestest.SeededDB(t)
+	if database.CurrentDialect() == database.DialectPostgres {
+		t.Skip("the failed insert is injected with a SQLite trigger")
+	}
+
+	now := time.Now().Unix()
+	dbtest.Seed(t,
+		`INSERT INTO external_integrations (id, user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (1, 1, 1, 'slack', 'ignored', '{"selected_channels":["C1"]}')`,
+	)
+	// Storing message 3 fails once.
+	if _, err := database.DB.Exec(
+		`CREATE TRIGGER fail_message_3 BEFORE INSERT ON signals WHEN NEW.content = 'message 3'
+		 BEGIN SELECT RAISE(ABORT, 'disk full'); END`,
+	); err != nil {
+		t.Fatalf("failed to create trigger: %v", err)
+	}
+
+	messages := make([]SlackMessage, 0, 5)
+	for i := 5; i >= 1; i-- {
+		messages = append(messages, SlackMessage{Type: "message", User: "U1", Text: fmt.Sprintf("message %d", i), TS: fmt.Sprintf("%d.%06d", now-60, i)})
+	}
+	client := &mockSlackSyncClient{channelMessages: map[string][]SlackMessage{"C1": messages}}
+	integration := models.ExternalIntegration{ID: 1, UserID: 1, WorkspaceID: 1, Provider: "slack", Metadata: `{"selected_channels":["C1"]}`}
+
+	stats, err := syncSlackIntegration(client, &integration, "slack-token")
+	if err != nil {
+		t.Fatalf("Slack sync returned error: %v", err)
+	}
+	if stats.ItemsUpserted != 4 || stats.ItemsFailed != 1 {
+		t.Fatalf("expected 4 stored and 1 failed message, got %+v", stats)
+	}
+	var metadataJSON string
+	if err := database.DB.QueryRow("SELECT metadata FROM external_integrations WHERE id = 1").Scan(&metadataJSON); err != nil {
+		t.Fatalf("failed to query integration metadata: %v", err)
+	}
+	var metadata struct {
+		Checkpoints map[string]string `json:"channel_checkpoints"`
+	}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to unmarshal metadata: %v", err)
+	}
+	if metadata.Checkpoints["C1"] != messages[3].TS {
+		t.Fatalf("expected the checkpoint to stop before the failed message, got %s", metadataJSON)
+	}
+
+	if _, err := database.DB.Exec(`DROP TRIGGER fail_message_3`); err != nil {
+		t.Fatalf("failed to drop trigger: %v", err)
+	}
+	integration.Metadata = metadataJSON
+	if _, err := syncSlackIntegration(client, &integration, "slack-token"); err != nil {
+		t.Fatalf("Slack sync returned error: %v", err)
+	}
+	if client.oldest["C1"] != messages[3].TS {
+		t.Fatalf("expected the next sync to resume before the failed message, got %q", client.oldest["C1"])
+	}
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals WHERE source_type = 'slack'").Scan(&count); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if count != 5 {
+		t.Fatalf("expected the failed message to be stored on retry, got %d signals", count)
+	}
+}
+
+func loadSlackMetadata(t *testing.T, sourceID string) models.SlackMetadata {
+	t.Helper()
+
+	var metadataJSON string
+	if err := database.DB.QueryRow(
+		"SELECT source_metadata FROM signals WHERE source_type = 'slack' AND source_id = ?", sourceID,
+	).Scan(&metadataJSON); err != nil {
+		t.Fatalf("failed to load signal %s: %v", sourceID, err)
+	}
+
+	var metadata models.SlackMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata %s: %v", metadataJSON, err)
+	}
+	return metadata
+}
+
+func TestSlackAuthFailuresRequireReauth(t *testing.T) {
+	client := &mockSlackSyncClient{msgErr: &SlackAPIError{Code: "token_revoked"}}
+	integration := &models.ExternalIntegration{ID: 1, Metadata: `{"selected_channels":["C1"]}`}
+	if _, err := syncSlackIntegration(client, integration, "token"); !services.IsReauthRequired(err) {
+		t.Errorf("expected token_revoked to require re-authorization, got %v", err)
+	}
+	if isSlackAuthError(&SlackAPIError{Code: "not_in_channel"}) {
+		t.Error("expected not_in_channel not to require re-authorization")
+	}
+}
@@ -0,0 +1,329 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services"
+	"sentinent-backend/utils"
+)
+
+const slackSigningSecretEnv = "SLACK_SIGNING_SECRET"
+
+var errSlackSigningSecretMissing = errors.New("slack signing secret is not configured")
+
+// slackProcessEventFunc applies a Slack event; tests replace it.
+var slackProcessEventFunc = processSlackEvent
+
+type slackWebhookTarget struct {
+	userID      int
+	workspaceID int
+}
+
+// HandleWebhook receives Slack Events API deliveries at /api/webhooks/slack.
+func (p *slackProvider) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateSlackWebhookRequest(r, body); err != nil {
+		log.Printf("Slack webhook signature validation failed: %v", err)
+		if errors.Is(err, errSlackSigningSecretMissing) {
+			http.Error(w, "Slack signing secret not configured", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event SlackWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Handle URL verification challenge
+	if event.Type == "url_verification" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(event.Challenge))
+		return
+	}
+
+	// Handle event callbacks
+	if event.Type == "event_callback" {
+		var innerEvent SlackMessageEvent
+		if err := json.Unmarshal(event.Event, &innerEvent); err == nil {
+			switch innerEvent.Type {
+			case "message", "reaction_added", "reaction_removed":
+				go slackProcessEventFunc(event.TeamID, innerEvent)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// validateSlackWebhookRequest checks the request signature against
+// SLACK_SIGNING_SECRET. Without a secret, requests are only accepted outside
+// production.
+func validateSlackWebhookRequest(r *http.Request, body []byte) error {
+	signingSecret := strings.TrimSpace(os.Getenv(slackSigningSecretEnv))
+	if signingSecret == "" {
+		if utils.IsProductionEnv() {
+			return errSlackSigningSecretMissing
+		}
+		return nil
+	}
+
+	signature := r.Header.Get("X-Slack-Signature")
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	return (&SlackClient{}).ValidateWebhookRequest(body, signature, timestamp, signingSecret)
+}
+
+// processSlackEvent applies a message, edit, delete or reaction event to the
+// signals of every integration connected to the team that syncs the channel.
+func processSlackEvent(teamID string, event SlackMessageEvent) {
+	channelID := event.Channel
+	if event.Item != nil {
+		channelID = event.Item.Channel
+	}
+
+	targets, err := findSlackWebhookTargets(teamID, channelID)
+	if err != nil {
+		log.Printf("Failed to query integrations for Slack webhook: %v", err)
+		return
+	}
+
+	for _, target := range targets {
+		if err := applySlackEvent(target, channelID, event); err != nil {
+			log.Printf("Failed to apply Slack %s event for user %d workspace %d: %v", event.Type, target.userID, target.workspaceID, err)
+		}
+	}
+}
+
+func applySlackEvent(target slackWebhookTarget, channelID string, event SlackMessageEvent) error {
+	switch event.Type {
+	case "reaction_added", "reaction_removed":
+		if event.Item == nil || event.Item.Type != "message" {
+			return nil
+		}
+		delta := 1
+		if event.Type == "reaction_removed" {
+			delta = -1
+		}
+		return adjustSlackReaction(target, channelID, event.Item.TS, event.Reaction, delta)
+	case "message":
+	default:
+		return nil
+	}
+
+	switch event.SubType {
+	case "message_changed":
+		if event.Message == nil {
+			return nil
+		}
+		return applySlackEdit(target, channelID, *event.Message)
+	case "message_deleted":
+		return applySlackDelete(target, channelID, event.DeletedTS, event.PreviousMessage)
+	}
+
+	if event.User == "" {
+		return nil
+	}
+	msg := SlackMessage{
+		Type:      "message",
+		User:      event.User,
+		Text:      event.Text,
+		TS:        event.TS,
+		ThreadTS:  event.ThreadTS,
+		Timestamp: parseSlackTimestamp(event.TS),
+	}
+	if msg.IsThreadReply() {
+		return addSlackReply(target, channelID, msg.ThreadTS, slackReply(msg, event.User))
+	}
+	return SaveSlackSignal(target.userID, target.workspaceID, msg, channelID, event.User)
+}
+
+// addSlackReply attaches a reply to its thread's signal, replacing an earlier
+// copy of the same reply.
+func addSlackReply(target slackWebhookTarget, channelID, threadTS string, reply models.SlackReply) error {
+	return updateSlackSignal(target, channelID, threadTS, func(metadata *models.SlackMetadata, text *string) {
+		for i := range metadata.Replies {
+			if metadata.Replies[i].TS == reply.TS {
+				metadata.Replies[i] = reply
+				return
+			}
+		}
+		metadata.Replies = append(metadata.Replies, reply)
+		if metadata.ReplyCount < len(metadata.Replies) {
+			metadata.ReplyCount = len(metadata.Replies)
+		}
+	})
+}
+
+// applySlackEdit updates the stored text of an edited message or thread reply.
+func applySlackEdit(target slackWebhookTarget, channelID string, msg SlackMessage) error {
+	editedTS := ""
+	if msg.Edited != nil {
+		editedTS = msg.Edited.TS
+	}
+
+	if msg.IsThreadReply() {
+		return updateSlackSignal(target, channelID, msg.ThreadTS, func(metadata *models.SlackMetadata, text *string) {
+			for i := range metadata.Replies {
+				if metadata.Replies[i].TS == msg.TS {
+					metadata.Replies[i].Text = msg.Text
+					metadata.Replies[i].EditedTS = editedTS
+				}
+			}
+		})
+	}
+
+	return updateSlackSignal(target, channelID, msg.TS, func(metadata *models.SlackMetadata, text *string) {
+		*text = msg.Text
+		if editedTS != "" {
+			metadata.EditedTS = editedTS
+		}
+		// Slack also reports new replies as a change to the parent.
+		if msg.ReplyCount > metadata.ReplyCount {
+			metadata.ReplyCount = msg.ReplyCount
+		}
+		if msg.Reactions != nil {
+			metadata.Reactions = slackMessageMetadata(channelID, msg).Reactions
+		}
+	})
+}
+
+// applySlackDelete removes a deleted thread reply from its thread, or archives
+// the signal of a deleted message.
+func applySlackDelete(target slackWebhookTarget, channelID, deletedTS string, previous *SlackMessage) error {
+	if previous != nil && previous.IsThreadReply() {
+		return updateSlackSignal(target, channelID, previous.ThreadTS, func(metadata *models.SlackMetadata, text *string) {
+			replies := metadata.Replies[:0]
+			for _, reply := range metadata.Replies {
+				if reply.TS != deletedTS {
+					replies = append(replies, reply)
+				}
+			}
+			if removed := len(metadata.Replies) - len(replies); removed > 0 && metadata.ReplyCount >= removed {
+				metadata.ReplyCount -= removed
+			}
+			metadata.Replies = replies
+		})
+	}
+
+	signal, err := repository.Default().Signals.FindBySource(
+		target.workspaceID, models.SourceTypeSlack, buildSlackSignalSourceID(channelID, deletedTS),
+	)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := repository.Default().Signals.ArchiveForAll(signal.ID); err != nil {
+		return err
+	}
+	services.PublishSignalArchivedForAll(target.workspaceID, signal.ID)
+	return nil
+}
+
+// adjustSlackReaction changes the count of one reaction on a stored message.
+func adjustSlackReaction(target slackWebhookTarget, channelID, ts, reaction string, delta int) error {
+	if reaction == "" {
+		return nil
+	}
+	return updateSlackSignal(target, channelID, ts, func(metadata *models.SlackMetadata, text *string) {
+		if metadata.Reactions == nil {
+			metadata.Reactions = make(map[string]int)
+		}
+		metadata.Reactions[reaction] += delta
+		if metadata.Reactions[reaction] <= 0 {
+			delete(metadata.Reactions, reaction)
+		}
+	})
+}
+
+// updateSlackSignal loads the workspace's signal for a message, lets mutate change
+// its metadata and text, and saves it. Messages that were never stored are
+// ignored.
+func updateSlackSignal(target slackWebhookTarget, channelID, ts string, mutate func(metadata *models.SlackMetadata, text *string)) error {
+	signalID, err := repository.Default().Signals.EditSource(
+		target.workspaceID, models.SourceTypeSlack, buildSlackSignalSourceID(channelID, ts),
+		func(text, metadataJSON string) (string, string, string, error) {
+			var metadata models.SlackMetadata
+			if metadataJSON != "" {
+				if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+					return "", "", "", err
+				}
+			}
+			mutate(&metadata, &text)
+
+			encoded, err := json.Marshal(metadata)
+			if err != nil {
+				return "", "", "", err
+			}
+			title := truncateString(text, 100)
+			if title == "" {
+				title = "Slack Message"
+			}
+			return title, text, string(encoded), nil
+		},
+	)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	services.PublishSignalUpdated(target.workspaceID, signalID)
+	return nil
+}
+
+// findSlackWebhookTargets returns the Slack integrations connected to the team
+// that sync the channel. Integrations without selected channels sync all of
+// them.
+func findSlackWebhookTargets(teamID, channelID string) ([]slackWebhookTarget, error) {
+	integrations, err := repository.Default().Integrations.ListByProvider("slack")
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]slackWebhookTarget, 0)
+	for _, integration := range integrations {
+		target := slackWebhookTarget{userID: integration.UserID, workspaceID: integration.WorkspaceID}
+
+		var metadata map[string]interface{}
+		json.Unmarshal([]byte(integration.Metadata), &metadata)
+		if metadata["team_id"] != teamID {
+			continue
+		}
+
+		selectedChannels, ok := metadata["selected_channels"].([]interface{})
+		isChannelSelected := !ok || len(selectedChannels) == 0
+		for _, ch := range selectedChannels {
+			if chStr, ok := ch.(string); ok && chStr == channelID {
+				isChannelSelected = true
+				break
+			}
+		}
+		if isChannelSelected {
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,255 @@
+package slack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/database/dbtest"
+	"sentinent-backend/services"
+	"sentinent-backend/services/internal/servicestest"
+	"sentinent-backend/utils"
+	"testing"
+	"time"
+)
+
+func TestValidateWebhookRequestAcceptsValidSlackSignature(t *testing.T) {
+	client := NewSlackClient()
+	body := []byte(`{"type":"event_callback"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	signature := signSlackWebhookBody(body, timestamp, "slack-secret")
+
+	if err := client.ValidateWebhookRequest(body, signature, timestamp, "slack-secret"); err != nil {
+		t.Fatalf("expected valid Slack signature, got %v", err)
+	}
+}
+
+func TestValidateWebhookRequestRejectsInvalidSlackSignature(t *testing.T) {
+	client := NewSlackClient()
+	body := []byte(`{"type":"event_callback"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	signature := signSlackWebhookBody(body, timestamp, "wrong-secret")
+
+	if err := client.ValidateWebhookRequest(body, signature, timestamp, "slack-secret"); err == nil {
+		t.Fatal("expected invalid Slack signature to be rejected")
+	}
+}
+
+func TestValidateWebhookRequestRejectsStaleSlackTimestamp(t *testing.T) {
+	client := NewSlackClient()
+	body := []byte(`{"type":"event_callback"}`)
+	timestamp := fmt.Sprintf("%d", time.Now().Add(-10*time.Minute).Unix())
+	signature := signSlackWebhookBody(body, timestamp, "slack-secret")
+
+	if err := client.ValidateWebhookRequest(body, signature, timestamp, "slack-secret"); err == nil {
+		t.Fatal("expected stale Slack timestamp to be rejected")
+	}
+}
+
+func TestHandleWebhookRequiresSigningSecretInProduction(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("SLACK_SIGNING_SECRET", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/slack", bytes.NewBufferString(`{"type":"url_verification","challenge":"abc"}`))
+	rr := httptest.NewRecorder()
+	(&slackProvider{}).HandleWebhook(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a signing secret in production, got %d", rr.Code)
+	}
+
+	t.Setenv("APP_ENV", "development")
+	rr = httptest.NewRecorder()
+	(&slackProvider{}).HandleWebhook(rr, httptest.NewRequest(http.MethodPost, "/api/webhooks/slack", bytes.NewBufferString(`{"type":"url_verification","challenge":"abc"}`)))
+	if rr.Code != http.StatusOK || rr.Body.String() != "abc" {
+		t.Fatalf("expected unsigned requests outside production to be accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func signSlackWebhookBody(body []byte, timestamp, secret string) string {
+	baseString := fmt.Sprintf("v0:%s:%s", timestamp, string(body))
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(baseString))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func setupSlackWebhookTestDB(t *testing.T) {
+	t.Helper()
+
+	servicestest.SeededDB(t)
+	servicestest.AddWorkspace(t, 2, 2)
+	dbtest.Seed(t,
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata) VALUES
+		 (1, 1, 'slack', 'token', '{"team_id":"T1","selected_channels":["C123"]}'),
+		 (2, 2, 'slack', 'token', '{"team_id":"T2"}')`,
+	)
+}
+
+func processSlackEventJSON(t *testing.T, teamID, payload string) {
+	t.Helper()
+
+	var event SlackMessageEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("failed to decode event %s: %v", payload, err)
+	}
+	processSlackEvent(teamID, event)
+}
+
+func TestProcessSlackEventAppliesEditsRepliesAndReactions(t *testing.T) {
+	setupSlackWebhookTestDB(t)
+
+	processSlackEventJSON(t, "T1", `{"type":"message","channel":"C123","user":"U1","text":"Deploy tonight?","ts":"1710000000.000100"}`)
+	processSlackEventJSON(t, "T1", `{"type":"message","subtype":"message_changed","channel":"C123",
+		"message":{"type":"message","user":"U1","text":"Deploy tonight at 8?","ts":"1710000000.000100","edited":{"user":"U1","ts":"1710000010.000000"}}}`)
+	processSlackEventJSON(t, "T1", `{"type":"message","channel":"C123","user":"U2","text":"Sounds good","ts":"1710000020.000200","thread_ts":"1710000000.000100"}`)
+	processSlackEventJSON(t, "T1", `{"type":"message","subtype":"message_changed","channel":"C123",
+		"message":{"type":"message","user":"U2","text":"Sounds good to me","ts":"1710000020.000200","thread_ts":"1710000000.000100","edited":{"user":"U2","ts":"1710000030.000000"}}}`)
+	for _, reaction := range []string{"reaction_added", "reaction_added", "reaction_removed"} {
+		processSlackEventJSON(t, "T1", `{"type":"`+reaction+`","user":"U3","reaction":"rocket","item":{"type":"message","channel":"C123","ts":"1710000000.000100"}}`)
+	}
+	processSlackEventJSON(t, "T1", `{"type":"reaction_added","user":"U3","reaction":"eyes","item":{"type":"message","channel":"C123","ts":"1710000000.000100"}}`)
+
+	var body string
+	if err := database.DB.QueryRow(
+		"SELECT body FROM signals WHERE source_id = 'C123:1710000000.000100'",
+	).Scan(&body); err != nil {
+		t.Fatalf("failed to load Slack signal: %v", err)
+	}
+	if body != "Deploy tonight at 8?" {
+		t.Fatalf("expected edited text, got %q", body)
+	}
+
+	metadata := loadSlackMetadata(t, "C123:1710000000.000100")
+	if metadata.EditedTS != "1710000010.000000" {
+		t.Fatalf("expected edit timestamp, got %+v", metadata)
+	}
+	if metadata.ReplyCount != 1 || len(metadata.Replies) != 1 || metadata.Replies[0].Text != "Sounds good to me" || metadata.Replies[0].EditedTS == "" {
+		t.Fatalf("expected edited thread reply, got %+v", metadata.Replies)
+	}
+	if metadata.Reactions["rocket"] != 1 || metadata.Reactions["eyes"] != 1 {
+		t.Fatalf("unexpected reaction counts %+v", metadata.Reactions)
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals").Scan(&count); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected the thread reply to stay on its parent, got %d signals", count)
+	}
+}
+
+func TestProcessSlackEventHandlesDeletes(t *testing.T) {
+	setupSlackWebhookTestDB(t)
+
+	processSlackEventJSON(t, "T1", `{"type":"message","channel":"C123","user":"U1","text":"Deploy tonight?","ts":"1710000000.000100"}`)
+	processSlackEventJSON(t, "T1", `{"type":"message","channel":"C123","user":"U2","text":"Oops","ts":"1710000020.000200","thread_ts":"1710000000.000100"}`)
+	processSlackEventJSON(t, "T1", `{"type":"message","subtype":"message_deleted","channel":"C123","deleted_ts":"1710000020.000200",
+		"previous_message":{"type":"message","user":"U2","text":"Oops","ts":"1710000020.000200","thread_ts":"1710000000.000100"}}`)
+
+	metadata := loadSlackMetadata(t, "C123:1710000000.000100")
+	if metadata.ReplyCount != 0 || len(metadata.Replies) != 0 {
+		t.Fatalf("expected deleted reply to be removed, got %+v", metadata)
+	}
+
+	processSlackEventJSON(t, "T1", `{"type":"message","subtype":"message_deleted","channel":"C123","deleted_ts":"1710000000.000100",
+		"previous_message":{"type":"message","user":"U1","text":"Deploy tonight?","ts":"1710000000.000100"}}`)
+
+	var status string
+	if err := database.DB.QueryRow(
+		"SELECT COALESCE(ss.status, s.status) FROM signals s LEFT JOIN signal_status ss ON s.id = ss.signal_id AND ss.user_id = 1 WHERE s.source_id = 'C123:1710000000.000100'",
+	).Scan(&status); err != nil {
+		t.Fatalf("failed to load signal status: %v", err)
+	}
+	if status != "archived" {
+		t.Fatalf("expected deleted message to be archived, got %q", status)
+	}
+}
+
+func TestProcessSlackEventIgnoresUnselectedChannels(t *testing.T) {
+	setupSlackWebhookTestDB(t)
+
+	processSlackEventJSON(t, "T1", `{"type":"message","channel":"C999","user":"U1","text":"Elsewhere","ts":"1710000000.000100"}`)
+	processSlackEventJSON(t, "T3", `{"type":"message","channel":"C123","user":"U1","text":"Other team","ts":"1710000000.000200"}`)
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals").Scan(&count); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no signals for unselected channels or unknown teams, got %d", count)
+	}
+}
+
+func TestProcessSlackEventSharesSignalAcrossWorkspaceMembers(t *testing.T) {
+	setupSlackWebhookTestDB(t)
+
+	// A second member of workspace 1 connected the same Slack channel.
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (2, 1, 'slack', 'token', '{"team_id":"T1","selected_channels":["C123"]}')`,
+	); err != nil {
+		t.Fatalf("failed to seed second Slack integration: %v", err)
+	}
+
+	processSlackEventJSON(t, "T1", `{"type":"message","channel":"C123","user":"U1","text":"Deploy tonight?","ts":"1710000000.000100"}`)
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM signals WHERE workspace_id = 1").Scan(&count); err != nil {
+		t.Fatalf("failed to count signals: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected one shared signal for the workspace, got %d", count)
+	}
+}
+
+func TestSlackReplyActionAttachesReplyToThreadSignal(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		t.Fatalf("failed to create encryptor: %v", err)
+	}
+	encryptedToken, err := encryptor.Encrypt("slack-token")
+	if err != nil {
+		t.Fatalf("failed to encrypt token: %v", err)
+	}
+	if _, err := database.DB.Exec(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata)
+		 VALUES (1, 1, 'slack', ?, '{"team_id":"T1"}')`,
+		encryptedToken,
+	); err != nil {
+		t.Fatalf("failed to seed integration: %v", err)
+	}
+	if err := SaveSlackSignal(1, 1, SlackMessage{Type: "message", User: "U1", Text: "Deploy tonight?", TS: "1710000000.000100"}, "C123", "Ada"); err != nil {
+		t.Fatalf("failed to seed Slack signal: %v", err)
+	}
+
+	client := &mockSlackSyncClient{userNames: map[string]string{"UBOT": "Sentinent Bot"}}
+	provider := &slackProvider{client: client}
+	req := httptest.NewRequest(http.MethodPost, "/api/integrations/slack/reply?workspace_id=1",
+		bytes.NewBufferString(`{"channel_id":"C123","thread_ts":"1710000000.000100","text":"On it"}`))
+	rr := httptest.NewRecorder()
+	provider.replyAction(rr, req, services.ActionContext{UserID: 1, WorkspaceID: 1})
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(client.posted) != 1 {
+		t.Fatalf("expected one posted message, got %d", len(client.posted))
+	}
+
+	metadata := loadSlackMetadata(t, "C123:1710000000.000100")
+	if len(metadata.Replies) != 1 || metadata.Replies[0].Text != "On it" || metadata.ReplyCount != 1 {
+		t.Fatalf("expected posted reply on thread signal, got %+v", metadata)
+	}
+	if metadata.Replies[0].Author != "Sentinent Bot" || metadata.Replies[0].UserID != "UBOT" {
+		t.Fatalf("expected reply author to be the Slack display name, got %+v", metadata.Replies[0])
+	}
+}
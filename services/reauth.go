@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ReauthRequiredError reports that a provider rejected an integration's
+// credentials. Syncing cannot succeed again until the user reconnects.
+type ReauthRequiredError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ReauthRequiredError) Error() string {
+	return fmt.Sprintf("%s authorization rejected: %v", e.Provider, e.Err)
+}
+
+func (e *ReauthRequiredError) Unwrap() error {
+	return e.Err
+}
+
+// IsReauthRequired reports whether err was caused by rejected credentials.
+func IsReauthRequired(err error) bool {
+	var reauthErr *ReauthRequiredError
+	return errors.As(err, &reauthErr)
+}
+
+// isTokenRefreshRejected reports whether a token refresh failed because the
+// provider refused the refresh token, rather than for a transient reason.
+func isTokenRefreshRejected(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	if retrieveErr.ErrorCode == "invalid_grant" || retrieveErr.ErrorCode == "unauthorized_client" {
+		return true
+	}
+	return retrieveErr.Response != nil &&
+		(retrieveErr.Response.StatusCode == http.StatusBadRequest || retrieveErr.Response.StatusCode == http.StatusUnauthorized)
+}
+
+// sendReauthEmail delivers the reconnect notice; tests replace it.
+var sendReauthEmail = SendReauthRequiredEmail
+
+// flagReauthRequired marks the integration as needing the user to reconnect
+// and, the first time, emails the owner a reconnect link.
+func flagReauthRequired(job *models.SyncJob, provider Provider, cause error, now time.Time) {
+	store := repository.Default()
+	marked, err := store.Integrations.MarkReauthRequired(job.IntegrationID, cause.Error(), now)
+	if err != nil {
+		log.Printf("Failed to flag %s integration %d for re-authorization: %v", provider.DisplayName(), job.IntegrationID, err)
+		return
+	}
+	if !marked {
+		return
+	}
+	log.Printf("%s integration %d needs re-authorization: %v", provider.DisplayName(), job.IntegrationID, cause)
+
+	email, err := store.Users.Email(job.UserID)
+	if err != nil {
+		log.Printf("Failed to look up owner of integration %d: %v", job.IntegrationID, err)
+		return
+	}
+	if err := sendReauthEmail(email, provider.DisplayName(), reconnectURL(provider, job.WorkspaceID)); err != nil {
+		log.Printf("Failed to send re-authorization email for integration %d: %v", job.IntegrationID, err)
+	}
+}
+
+// reconnectURL links to the frontend page where the user reconnects the
+// provider.
+func reconnectURL(provider Provider, workspaceID int) string {
+	baseURL := strings.TrimRight(strings.TrimSpace(os.Getenv("FRONTEND_BASE_URL")), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:4200"
+	}
+	query := url.Values{"reconnect": {provider.Name()}}
+	if provider.Scope() == ScopeWorkspace && workspaceID != 0 {
+		query.Set("workspace_id", strconv.Itoa(workspaceID))
+	}
+	return baseURL + "/integrations?" + query.Encode()
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"strings"
+	"time"
+)
+
+const (
+	// ruleDryRunPageSize is how many signals a dry run reads at a time.
+	ruleDryRunPageSize = 200
+	// ruleDryRunSampleSize caps the matching signals a dry run returns.
+	ruleDryRunSampleSize = 50
+)
+
+// RuleMatcher tests signals against a rule's conditions.
+type RuleMatcher struct {
+	conditions models.RuleConditions
+	title      *regexp.Regexp
+	content    *regexp.Regexp
+}
+
+// NewRuleMatcher compiles a rule's conditions. It fails on an invalid pattern.
+func NewRuleMatcher(conditions models.RuleConditions) (*RuleMatcher, error) {
+	matcher := &RuleMatcher{conditions: conditions}
+	var err error
+	if conditions.TitlePattern != "" {
+		if matcher.title, err = regexp.Compile(conditions.TitlePattern); err != nil {
+			return nil, fmt.Errorf("Invalid title_pattern: %v", err)
+		}
+	}
+	if conditions.ContentPattern != "" {
+		if matcher.content, err = regexp.Compile(conditions.ContentPattern); err != nil {
+			return nil, fmt.Errorf("Invalid content_pattern: %v", err)
+		}
+	}
+	return matcher, nil
+}
+
+// Matches reports whether signal meets every condition. Source metadata must
+// already be decoded, as the repository returns it.
+func (m *RuleMatcher) Matches(signal *models.Signal) bool {
+	c := m.conditions
+	if len(c.SourceTypes) > 0 && !containsFold(c.SourceTypes, signal.SourceType) {
+		return false
+	}
+	if len(c.Authors) > 0 && !containsFold(c.Authors, signal.Author) {
+		return false
+	}
+	if m.title != nil && !m.title.MatchString(signal.Title) {
+		return false
+	}
+	if m.content != nil && !m.content.MatchString(signal.Content) && !m.content.MatchString(signal.Body) {
+		return false
+	}
+	if len(c.GitHubLabels) > 0 {
+		metadata, ok := signal.SourceMetadata.(*models.GitHubMetadata)
+		if !ok || !containsAnyFold(c.GitHubLabels, metadata.Labels) {
+			return false
+		}
+	}
+	if len(c.JiraPriorities) > 0 {
+		metadata, ok := signal.SourceMetadata.(*models.JiraMetadata)
+		if !ok || !containsFold(c.JiraPriorities, metadata.Priority) {
+			return false
+		}
+	}
+	if len(c.SlackChannels) > 0 {
+		metadata, ok := signal.SourceMetadata.(*models.SlackMetadata)
+		if !ok || !containsFold(c.SlackChannels, metadata.ChannelID) {
+			return false
+		}
+	}
+	return true
+}
+
+// applySignalRules runs the workspace's enabled rules against a signal that
+// was just stored. A rule acts on a signal only the first time it matches, so
+// syncing the item again leaves alone whatever people changed since.
+func applySignalRules(workspaceID int, sourceType, sourceID string) error {
+	store := repository.Default()
+	rules, err := store.Rules.ListEnabled(workspaceID)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	signal, err := store.Signals.FindBySource(workspaceID, sourceType, sourceID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, rule := range rules {
+		matcher, err := NewRuleMatcher(rule.Conditions)
+		if err != nil {
+			log.Printf("Skipping signal rule %d: %v", rule.ID, err)
+			continue
+		}
+		if !matcher.Matches(signal) {
+			continue
+		}
+		first, err := store.Rules.RecordMatch(rule.ID, signal.ID, now)
+		if err != nil {
+			return err
+		}
+		if !first {
+			continue
+		}
+		// One broken action, such as an assignee who left, must not stop
+		// the rules after it.
+		if err := applyRuleActions(store, rule, signal.ID, now); err != nil {
+			log.Printf("Signal rule %d failed on signal %d: %v", rule.ID, signal.ID, err)
+		}
+	}
+	return nil
+}
+
+// logSignalRules applies rules after an upsert. Rules never fail the sync
+// that stored the signal, so errors are only logged.
+func logSignalRules(workspaceID int, sourceType, sourceID string) {
+	if err := applySignalRules(workspaceID, sourceType, sourceID); err != nil {
+		log.Printf("Failed to apply rules to %s signal %s: %v", sourceType, sourceID, err)
+	}
+}
+
+func applyRuleActions(store *repository.Store, rule models.SignalRule, signalID int, now time.Time) error {
+	actions := rule.Actions
+	if len(actions.AddLabels) > 0 {
+		if err := store.Signals.AddLabels(signalID, actions.AddLabels); err != nil {
+			return err
+		}
+	}
+	if actions.Priority != "" {
+		if err := store.Signals.SetPriority(signalID, actions.Priority); err != nil {
+			return err
+		}
+	}
+	if actions.AssigneeID != nil {
+		if err := store.Signals.SetAssignee(signalID, *actions.AssigneeID); err != nil {
+			return err
+		}
+	}
+	if actions.DecisionID != nil {
+		decision, err := store.Decisions.Get(rule.WorkspaceID, *actions.DecisionID)
+		if err != nil {
+			return fmt.Errorf("decision %d: %w", *actions.DecisionID, err)
+		}
+		if decision.Status == models.DecisionStatusOpen {
+			if _, err := store.Decisions.LinkSignal(decision.ID, signalID, rule.CreatedBy, now); err != nil {
+				return err
+			}
+		}
+	}
+	if actions.Archive {
+		if err := store.Signals.ArchiveByDefault(signalID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DryRunSignalRule reports which of the workspace's existing signals the
+// conditions match, without changing anything. It reads every signal userID
+// can see in the workspace, newest first.
+func DryRunSignalRule(userID, workspaceID int, conditions models.RuleConditions) (*models.RuleDryRunResponse, error) {
+	matcher, err := NewRuleMatcher(conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	store := repository.Default()
+	result := &models.RuleDryRunResponse{Signals: make([]models.Signal, 0)}
+	filter := models.SignalFilter{WorkspaceID: workspaceID, SourceTypes: conditions.SourceTypes, Limit: ruleDryRunPageSize}
+	for {
+		page, err := store.Signals.List(userID, filter)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page.Signals {
+			result.Scanned++
+			if !matcher.Matches(&page.Signals[i]) {
+				continue
+			}
+			result.Matched++
+			if len(result.Signals) < ruleDryRunSampleSize {
+				result.Signals = append(result.Signals, page.Signals[i])
+			}
+		}
+		if page.NextCursor == "" {
+			return result, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnyFold(values, candidates []string) bool {
+	for _, candidate := range candidates {
+		if containsFold(values, candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"encoding/json"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/services/internal/servicestest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// storeTestSignal stores a GitHub issue in workspace 1 the way a provider
+// sync does, running the workspace's rules and publishing its events.
+func storeTestSignal(t *testing.T, id int64, title string, labels ...string) {
+	t.Helper()
+
+	sourceID := strconv.FormatInt(id, 10)
+	metadata, _ := json.Marshal(models.GitHubMetadata{Repository: "acme/api", Number: int(id), State: "open", Labels: labels})
+	created, err := repository.Default().Signals.UpsertSource(repository.SourceSignal{
+		UserID:      1,
+		WorkspaceID: 1,
+		SourceType:  models.SourceTypeGitHub,
+		SourceID:    sourceID,
+		ExternalID:  sourceID,
+		Title:       title,
+		Status:      "open",
+		Metadata:    string(metadata),
+		ReceivedAt:  time.Now(),
+	}, repository.SourceTitle, repository.SourceStatus, repository.SourceMetadata)
+	if err != nil {
+		t.Fatalf("UpsertSource returned error: %v", err)
+	}
+	SignalUpserted(1, models.SourceTypeGitHub, sourceID, created)
+}
+
+func TestRuleMatcher(t *testing.T) {
+	matcher, err := NewRuleMatcher(models.RuleConditions{
+		SourceTypes:  []string{models.SourceTypeGitHub},
+		TitlePattern: `(?i)crash`,
+		GitHubLabels: []string{"BUG"},
+	})
+	if err != nil {
+		t.Fatalf("NewRuleMatcher returned error: %v", err)
+	}
+
+	signal := &models.Signal{
+		SourceType:     models.SourceTypeGitHub,
+		Title:          "Crash on login",
+		SourceMetadata: &models.GitHubMetadata{Labels: []string{"bug", "backend"}},
+	}
+	if !matcher.Matches(signal) {
+		t.Fatal("expected the signal to match")
+	}
+	signal.Title = "Slow login"
+	if matcher.Matches(signal) {
+		t.Fatal("expected the title pattern to reject the signal")
+	}
+	signal.Title = "Crash on login"
+	signal.SourceMetadata = &models.JiraMetadata{Priority: "High"}
+	if matcher.Matches(signal) {
+		t.Fatal("expected GitHub label conditions to reject other metadata")
+	}
+
+	if _, err := NewRuleMatcher(models.RuleConditions{ContentPattern: "("}); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestSignalUpsertedAppliesRulesOnce(t *testing.T) {
+	store := servicestest.SeededDB(t)
+
+	memberID, decisionID := 2, 1
+	if _, err := store.Rules.Create(1, 1, models.SignalRuleRequest{
+		Name:       "Bugs",
+		Conditions: models.RuleConditions{GitHubLabels: []string{"bug"}},
+		Actions: models.RuleActions{
+			AddLabels:  []string{"triage"},
+			Priority:   models.SignalPriorityHigh,
+			AssigneeID: &memberID,
+			DecisionID: &decisionID,
+		},
+	}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if _, err := store.Rules.Create(1, 1, models.SignalRuleRequest{
+		Name:       "Chores",
+		Position:   1,
+		Conditions: models.RuleConditions{TitlePattern: `^chore:`},
+		Actions:    models.RuleActions{Archive: true},
+	}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	storeTestSignal(t, 10, "Crash on login", "bug")
+	storeTestSignal(t, 11, "chore: bump deps")
+
+	bug, err := store.Signals.FindBySource(1, models.SourceTypeGitHub, "10")
+	if err != nil {
+		t.Fatalf("FindBySource returned error: %v", err)
+	}
+	if len(bug.Labels) != 1 || bug.Labels[0] != "triage" || bug.Priority != models.SignalPriorityHigh {
+		t.Fatalf("expected the bug rule's labels and priority, got %v %q", bug.Labels, bug.Priority)
+	}
+	if bug.AssigneeID == nil || *bug.AssigneeID != memberID {
+		t.Fatalf("expected the bug to be assigned to the member, got %v", bug.AssigneeID)
+	}
+	links, err := store.Decisions.LinkedSignals(1, decisionID)
+	if err != nil || len(links) != 1 || links[0].ID != bug.ID || links[0].LinkedBy != 1 {
+		t.Fatalf("expected the bug linked to the decision, got %+v (err=%v)", links, err)
+	}
+
+	chore, err := store.Signals.FindBySource(1, models.SourceTypeGitHub, "11")
+	if err != nil {
+		t.Fatalf("FindBySource returned error: %v", err)
+	}
+	if chore.Status != models.SignalStatusArchived || len(chore.Labels) != 0 {
+		t.Fatalf("expected only the chore rule to apply, got status=%q labels=%v", chore.Status, chore.Labels)
+	}
+
+	// Someone changes the bug by hand; the next sync must not undo it, and the
+	// archived chore stays archived.
+	if err := store.Signals.SetPriority(bug.ID, models.SignalPriorityLow); err != nil {
+		t.Fatalf("SetPriority returned error: %v", err)
+	}
+	storeTestSignal(t, 10, "Crash on login", "bug")
+	storeTestSignal(t, 11, "chore: bump deps")
+	if bug, err = store.Signals.FindBySource(1, models.SourceTypeGitHub, "10"); err != nil || bug.Priority != models.SignalPriorityLow {
+		t.Fatalf("expected the manual priority to survive a re-sync, got %+v (err=%v)", bug, err)
+	}
+	if chore, err = store.Signals.FindBySource(1, models.SourceTypeGitHub, "11"); err != nil || chore.Status != models.SignalStatusArchived {
+		t.Fatalf("expected the chore to stay archived, got %+v (err=%v)", chore, err)
+	}
+}
+
+func TestDryRunSignalRule(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	for i, title := range []string{"Crash on login", "Crash on logout", "Slow search"} {
+		storeTestSignal(t, int64(i+1), title)
+	}
+
+	result, err := DryRunSignalRule(2, 1, models.RuleConditions{TitlePattern: "^Crash"})
+	if err != nil {
+		t.Fatalf("DryRunSignalRule returned error: %v", err)
+	}
+	if result.Scanned != 3 || result.Matched != 2 || len(result.Signals) != 2 {
+		t.Fatalf("expected 2 of 3 signals to match, got %+v", result)
+	}
+
+	if result, err = DryRunSignalRule(2, 1, models.RuleConditions{SourceTypes: []string{models.SourceTypeJira}}); err != nil || result.Scanned != 0 {
+		t.Fatalf("expected no Jira signals to scan, got %+v (err=%v)", result, err)
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"log"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sync"
+	"time"
+)
+
+// SnoozeService returns snoozed signals to unread once their snooze expires.
+// Snoozes are checked every interval, so a signal wakes at most one interval
+// late.
+type SnoozeService struct {
+	now      func() time.Time
+	ticker   *time.Ticker
+	stopChan chan bool
+	wg       sync.WaitGroup
+}
+
+// NewSnoozeService creates a new SnoozeService
+func NewSnoozeService() *SnoozeService {
+	return &SnoozeService{
+		now:      func() time.Time { return time.Now().UTC() },
+		stopChan: make(chan bool),
+	}
+}
+
+// Start begins checking for expired snoozes
+func (s *SnoozeService) Start(interval time.Duration) {
+	s.ticker = time.NewTicker(interval)
+	s.wg.Add(1)
+	go s.run()
+	log.Printf("Snooze service started with interval: %v", interval)
+}
+
+// Stop stops the background checks and waits for a running one to finish.
+func (s *SnoozeService) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+		close(s.stopChan)
+		s.wg.Wait()
+	}
+}
+
+func (s *SnoozeService) run() {
+	defer s.wg.Done()
+	s.wakeExpired()
+	for {
+		select {
+		case <-s.ticker.C:
+			s.wakeExpired()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// wakeExpired returns every expired snooze to unread and tells the snoozing
+// members' streams.
+func (s *SnoozeService) wakeExpired() int {
+	store := repository.Default()
+	woken, err := store.Signals.WakeSnoozed(s.now())
+	if err != nil {
+		log.Printf("Failed to wake snoozed signals: %v", err)
+		return 0
+	}
+	if len(woken) == 0 {
+		return 0
+	}
+	log.Printf("Woke %d snoozed signals", len(woken))
+
+	signalIDs := make([]int, len(woken))
+	for i, snooze := range woken {
+		signalIDs[i] = snooze.SignalID
+	}
+	workspaces, err := store.Signals.WorkspaceIDs(signalIDs)
+	if err != nil {
+		log.Printf("Failed to load signal workspaces for events: %v", err)
+		return len(woken)
+	}
+	for _, snooze := range woken {
+		if workspaceID, ok := workspaces[snooze.SignalID]; ok {
+			signalBus.Publish(models.SignalEvent{
+				Type:        models.SignalEventStatusChanged,
+				WorkspaceID: workspaceID,
+				SignalID:    snooze.SignalID,
+				UserID:      snooze.UserID,
+				Status:      models.SignalStatusUnread,
+			})
+		}
+	}
+	return len(woken)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"sentinent-backend/services/internal/servicestest"
+	"testing"
+	"time"
+)
+
+func TestSnoozeServiceWakesExpiredSnoozes(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	statements := []string{
+		`INSERT INTO signals (id, user_id, workspace_id, source_type, source_id, title) VALUES
+		 (1, 1, 1, 'slack', 'C1:1.000100', 'Expired'),
+		 (2, 1, 1, 'slack', 'C1:1.000200', 'Still sleeping')`,
+	}
+	for _, statement := range statements {
+		if _, err := database.DB.Exec(statement); err != nil {
+			t.Fatalf("failed to prepare %q: %v", statement, err)
+		}
+	}
+	for signalID, until := range map[int]time.Time{1: now.Add(-time.Minute), 2: now.Add(time.Hour)} {
+		if _, err := database.DB.Exec(
+			`INSERT INTO signal_status (signal_id, user_id, status, snoozed_until) VALUES (?, 1, ?, ?)`,
+			signalID, models.SignalStatusSnoozed, until,
+		); err != nil {
+			t.Fatalf("failed to seed snooze: %v", err)
+		}
+	}
+
+	service := NewSnoozeService()
+	service.now = func() time.Time { return now }
+	if woken := service.wakeExpired(); woken != 1 {
+		t.Fatalf("expected one snooze to expire, got %d", woken)
+	}
+
+	statuses := map[int]string{}
+	rows, err := database.DB.Query("SELECT signal_id, status FROM signal_status")
+	if err != nil {
+		t.Fatalf("failed to load statuses: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var signalID int
+		var status string
+		if err := rows.Scan(&signalID, &status); err != nil {
+			t.Fatalf("failed to scan status: %v", err)
+		}
+		statuses[signalID] = status
+	}
+	if statuses[1] != models.SignalStatusUnread || statuses[2] != models.SignalStatusSnoozed {
+		t.Fatalf("unexpected statuses after waking: %+v", statuses)
+	}
+}
@@ -1,36 +1,76 @@
 package services
 
 import (
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"sentinent-backend/database"
 	"sentinent-backend/models"
+	"sentinent-backend/repository"
 	"sentinent-backend/utils"
-	"strconv"
+	"sync"
 	"time"
 )
 
-// SyncService handles background synchronization of external integrations
+const (
+	defaultSyncWorkers     = 4
+	defaultSyncMaxAttempts = 5
+	syncRetryBaseDelay     = 30 * time.Second
+	syncRetryMaxDelay      = 30 * time.Minute
+	syncJobPollInterval    = 5 * time.Second
+	// syncJobLease is how long a running job stays claimed without a heartbeat
+	// from its worker. After that the worker is assumed dead and the job is
+	// queued again.
+	syncJobLease = 2 * time.Minute
+	// syncJobHeartbeat is how often a worker renews the lease of its job.
+	syncJobHeartbeat = 30 * time.Second
+)
+
+// syncJobsQueued wakes idle workers when a job is enqueued, so manual syncs do
+// not wait for the next poll.
+var syncJobsQueued = make(chan struct{}, 1)
+
+// EnqueueSync queues a sync of the integration and returns its job. If the
+// integration already has a pending or running job, that job is returned.
+// Integrations waiting for the user to reconnect return
+// ErrIntegrationNeedsReauth.
+func EnqueueSync(integration models.ExternalIntegration) (*models.SyncJob, error) {
+	if integration.ReauthRequiredAt != nil {
+		return nil, ErrIntegrationNeedsReauth
+	}
+	job, created, err := repository.Default().SyncJobs.Enqueue(integration, defaultSyncMaxAttempts, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		select {
+		case syncJobsQueued <- struct{}{}:
+		default:
+		}
+	}
+	return job, nil
+}
+
+// SyncService handles background synchronization of external integrations.
+// Every interval it queues a job per integration; a fixed pool of workers runs
+// the queued jobs, retrying failures with exponential backoff.
 type SyncService struct {
-	slackClient    slackSyncClient
-	tokenEncryptor *utils.TokenEncryptor
+	// lookupProvider resolves an integration's provider; tests replace it.
+	lookupProvider func(name string) (Provider, bool)
+	workers        int
+	heartbeat      time.Duration
+	now            func() time.Time
 	ticker         *time.Ticker
 	stopChan       chan bool
-}
-
-type slackSyncClient interface {
-	GetChannels(accessToken string) ([]SlackChannel, *RateLimitInfo, error)
-	GetMessages(accessToken, channelID string, limit int, oldest string) ([]SlackMessage, *RateLimitInfo, error)
-	GetUserInfo(accessToken, userID string) (*SlackUserResponse, *RateLimitInfo, error)
+	wg             sync.WaitGroup
 }
 
 // NewSyncService creates a new SyncService
-func NewSyncService(encryptor *utils.TokenEncryptor) *SyncService {
+func NewSyncService() *SyncService {
 	return &SyncService{
-		slackClient:    NewSlackClient(),
-		tokenEncryptor: encryptor,
+		lookupProvider: LookupProvider,
+		workers:        defaultSyncWorkers,
+		heartbeat:      syncJobHeartbeat,
+		now:            func() time.Time { return time.Now().UTC() },
 		stopChan:       make(chan bool),
 	}
 }
@@ -38,347 +78,222 @@ func NewSyncService(encryptor *utils.TokenEncryptor) *SyncService {
 // Start begins the background sync process
 func (s *SyncService) Start(interval time.Duration) {
 	s.ticker = time.NewTicker(interval)
+	s.wg.Add(1)
 	go s.run()
-	log.Printf("Sync service started with interval: %v", interval)
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	log.Printf("Sync service started with interval: %v and %d workers", interval, s.workers)
 }
 
-// Stop stops the background sync process
+// Stop stops the background sync process and waits for running jobs to finish.
 func (s *SyncService) Stop() {
 	if s.ticker != nil {
 		s.ticker.Stop()
 		close(s.stopChan)
+		s.wg.Wait()
 	}
 }
 
 func (s *SyncService) run() {
+	defer s.wg.Done()
+	s.enqueueAllIntegrations()
 	for {
 		select {
 		case <-s.ticker.C:
-			s.syncAllIntegrations()
+			s.enqueueAllIntegrations()
 		case <-s.stopChan:
 			return
 		}
 	}
 }
 
-// syncAllIntegrations syncs all active integrations
-func (s *SyncService) syncAllIntegrations() {
-	rows, err := database.DB.Query(
-		"SELECT id, user_id, workspace_id, provider, access_token, metadata FROM external_integrations",
-	)
-	if err != nil {
-		log.Printf("Failed to fetch integrations: %v", err)
-		return
-	}
-	defer rows.Close()
-
-	type syncRecord struct {
-		integration    models.ExternalIntegration
-		encryptedToken string
-	}
-
-	records := make([]syncRecord, 0)
-	for rows.Next() {
-		var integration models.ExternalIntegration
-		var encryptedToken string
-		var workspaceID sql.NullInt64
-		err := rows.Scan(
-			&integration.ID, &integration.UserID, &workspaceID,
-			&integration.Provider, &encryptedToken, &integration.Metadata,
-		)
-		if err != nil {
-			continue
+func (s *SyncService) work() {
+	defer s.wg.Done()
+	poll := time.NewTicker(syncJobPollInterval)
+	defer poll.Stop()
+	for {
+		for s.runNextJob() {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+			}
 		}
-		if workspaceID.Valid {
-			integration.WorkspaceID = int(workspaceID.Int64)
+		select {
+		case <-syncJobsQueued:
+		case <-poll.C:
+		case <-s.stopChan:
+			return
 		}
-		records = append(records, syncRecord{
-			integration:    integration,
-			encryptedToken: encryptedToken,
-		})
 	}
+}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Failed while iterating integrations: %v", err)
+// enqueueAllIntegrations queues a sync for every stored integration whose
+// provider is configured. Integrations that still have a job in flight keep it.
+// Integrations waiting for the user to reconnect are skipped, and so are ones
+// whose last job was dead-lettered less than syncRetryMaxDelay ago: retrying
+// them on every tick would undo the backoff. A manual sync or reconnecting
+// queues their next job sooner.
+func (s *SyncService) enqueueAllIntegrations() {
+	jobs := repository.Default().SyncJobs
+	requeued, buried, err := jobs.RequeueExpired(errSyncLeaseExpired.Error(), s.now())
+	if err != nil {
+		log.Printf("Failed to requeue expired sync jobs: %v", err)
+	} else if requeued > 0 || buried > 0 {
+		log.Printf("Sync jobs whose worker stopped: %d requeued, %d dead-lettered", requeued, buried)
+	}
+
+	integrations, err := repository.Default().Integrations.All()
+	if err != nil {
+		log.Printf("Failed to fetch integrations: %v", err)
 		return
 	}
-	if err := rows.Close(); err != nil {
-		log.Printf("Failed to close integration rows: %v", err)
+	dead, err := jobs.DeadLettered(s.now().Add(-syncRetryMaxDelay))
+	if err != nil {
+		log.Printf("Failed to fetch dead-lettered sync jobs: %v", err)
 		return
 	}
 
-	for _, record := range records {
-		// Decrypt token
-		accessToken, err := s.tokenEncryptor.Decrypt(record.encryptedToken)
-		if err != nil {
-			log.Printf("Failed to decrypt token for integration %d: %v", record.integration.ID, err)
+	for _, integration := range integrations {
+		if integration.ReauthRequiredAt != nil || dead[integration.ID] {
 			continue
 		}
-
-		switch record.integration.Provider {
-		case "slack":
-			s.syncSlackIntegration(&record.integration, accessToken)
-		case "github":
-			// GitHub sync uses its own token management via GetGitHubClient
-			go func(userID, workspaceID int) {
-				if err := SyncGitHubSignals(userID, workspaceID); err != nil {
-					log.Printf("Background GitHub sync error for user %d workspace %d: %v", userID, workspaceID, err)
-				}
-			}(record.integration.UserID, record.integration.WorkspaceID)
-		case "jira":
-			// Jira sync uses its own token management via GetJiraClient (with refresh)
-			go func(userID, workspaceID int) {
-				if err := SyncJiraSignals(userID, workspaceID); err != nil {
-					log.Printf("Background Jira sync error for user %d workspace %d: %v", userID, workspaceID, err)
-				}
-			}(record.integration.UserID, record.integration.WorkspaceID)
-		default:
-			log.Printf("Unknown provider: %s", record.integration.Provider)
+		provider, ok := s.lookupProvider(integration.Provider)
+		if !ok {
+			log.Printf("Unknown provider: %s", integration.Provider)
+			continue
+		}
+		if !provider.Configured() {
+			continue
+		}
+		if _, _, err := jobs.Enqueue(integration, defaultSyncMaxAttempts, s.now()); err != nil {
+			log.Printf("Failed to enqueue %s sync for integration %d: %v", provider.DisplayName(), integration.ID, err)
 		}
 	}
 }
 
-// syncSlackIntegration syncs messages from Slack
-func (s *SyncService) syncSlackIntegration(integration *models.ExternalIntegration, accessToken string) {
-	// Parse metadata to get selected channels
-	var metadata map[string]interface{}
-	if err := json.Unmarshal([]byte(integration.Metadata), &metadata); err != nil {
-		log.Printf("Failed to parse metadata for integration %d: %v", integration.ID, err)
-		return
+// runNextJob claims and runs one due job. It returns false when there was
+// nothing to run.
+func (s *SyncService) runNextJob() bool {
+	jobs := repository.Default().SyncJobs
+	job, err := jobs.ClaimNext(s.now(), s.now().Add(syncJobLease))
+	if err != nil {
+		if err != repository.ErrNotFound {
+			log.Printf("Failed to claim sync job: %v", err)
+		}
+		return false
 	}
 
-	// Get channels to monitor (stored in metadata as "selected_channels")
-	var channels []string
-	if selectedChannels, ok := metadata["selected_channels"].([]interface{}); ok {
-		for _, ch := range selectedChannels {
-			if chStr, ok := ch.(string); ok {
-				channels = append(channels, chStr)
-			}
-		}
+	runs := repository.Default().SyncRuns
+	runID, err := runs.Start(*job, s.now())
+	if err != nil {
+		log.Printf("Failed to record start of sync job %d: %v", job.ID, err)
 	}
 
-	// If no channels selected, fetch all channels
-	if len(channels) == 0 {
-		slackChannels, rateLimit, err := s.slackClient.GetChannels(accessToken)
-		if err != nil {
-			if rateLimit != nil && rateLimit.IsRateLimited() {
-				log.Printf("Rate limited by Slack API, waiting %v", rateLimit.WaitDuration())
-				time.Sleep(rateLimit.WaitDuration())
-			}
-			log.Printf("Failed to fetch Slack channels: %v", err)
-			return
+	stopHeartbeat := s.keepLease(job)
+	stats, runErr := s.runJob(job)
+	stopHeartbeat()
+	if runID != 0 {
+		message := ""
+		if runErr != nil {
+			message = runErr.Error()
 		}
-
-		for _, ch := range slackChannels {
-			channels = append(channels, ch.ID)
+		if err := runs.Finish(runID, stats.ItemsFetched, stats.ItemsUpserted, stats.ItemsFailed, message, s.now()); err != nil {
+			log.Printf("Failed to record end of sync job %d: %v", job.ID, err)
 		}
 	}
 
-	// Get last sync timestamp
-	var lastSync float64
-	if lastSyncVal, ok := metadata["last_sync"]; ok {
-		switch v := lastSyncVal.(type) {
-		case float64:
-			lastSync = v
-		case string:
-			lastSync, _ = strconv.ParseFloat(v, 64)
+	if IsReauthRequired(runErr) {
+		if provider, ok := s.lookupProvider(job.Provider); ok {
+			flagReauthRequired(job, provider, runErr, s.now())
 		}
 	}
 
-	// Cache for user names to avoid redundant API calls across channels
-	userCache := make(map[string]string)
-	var maxTS float64
-
-	// Fetch messages from each channel
-	for _, channelID := range channels {
-		var oldest string
-		if lastSync > 0 {
-			oldest = fmt.Sprintf("%.6f", lastSync)
-		}
-		messages, rateLimit, err := s.slackClient.GetMessages(accessToken, channelID, 100, oldest)
-		if err != nil {
-			if rateLimit != nil && rateLimit.IsRateLimited() {
-				log.Printf("Rate limited by Slack API, waiting %v", rateLimit.WaitDuration())
-				time.Sleep(rateLimit.WaitDuration())
-				continue
-			}
-			if IsSlackAPIError(err, "not_in_channel") {
-				log.Printf("Skipping Slack channel %s during sync: %v", channelID, err)
-				continue
-			}
-			log.Printf("Failed to fetch messages from channel %s: %v", channelID, err)
-			continue
-		}
-
-		// Use a transaction for all messages in this channel for speed
-		tx, err := database.DB.Begin()
-		if err != nil {
-			log.Printf("Failed to start transaction for channel %s: %v", channelID, err)
-			continue
-		}
-
-		// Process and store messages
-		for _, msg := range messages {
-			if msg.Type != "message" || msg.User == "" {
-				continue
-			}
+	if runErr == nil {
+		err = jobs.Complete(job.ID, job.Attempts, s.now())
+	} else if job.Attempts >= job.MaxAttempts || errors.Is(runErr, errIntegrationGone) ||
+		errors.Is(runErr, ErrIntegrationNeedsReauth) || IsReauthRequired(runErr) {
+		log.Printf("Sync job %d (%s) dead-lettered after %d attempts: %v", job.ID, job.Provider, job.Attempts, runErr)
+		err = jobs.Bury(job.ID, job.Attempts, runErr.Error(), s.now())
+	} else {
+		log.Printf("Sync job %d (%s) attempt %d failed: %v", job.ID, job.Provider, job.Attempts, runErr)
+		err = jobs.Retry(job.ID, job.Attempts, runErr.Error(), s.now().Add(syncRetryDelay(job.Attempts)), s.now())
+	}
+	if err == repository.ErrNotFound {
+		log.Printf("Sync job %d lost its lease before attempt %d finished; its result was dropped", job.ID, job.Attempts)
+	} else if err != nil {
+		log.Printf("Failed to record result of sync job %d: %v", job.ID, err)
+	}
+	return true
+}
 
-			// Track latest message timestamp across all channels
-			if ts, err := strconv.ParseFloat(msg.TS, 64); err == nil {
-				if ts > maxTS {
-					maxTS = ts
+// keepLease renews the lease of the job's current attempt every heartbeat
+// until the returned function is called.
+func (s *SyncService) keepLease(job *models.SyncJob) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := repository.Default().SyncJobs.Heartbeat(job.ID, job.Attempts, s.now().Add(syncJobLease), s.now())
+				if err == repository.ErrNotFound {
+					log.Printf("Sync job %d lost its lease during attempt %d", job.ID, job.Attempts)
+					return
 				}
-			}
-
-			sourceID := buildSlackSignalSourceID(channelID, msg.TS)
-
-			// Get user info for author name
-			authorName := msg.User
-			if cachedName, ok := userCache[msg.User]; ok {
-				authorName = cachedName
-			} else {
-				// Only fetch if not already in cache
-				userResp, _, err := s.slackClient.GetUserInfo(accessToken, msg.User)
-				if err == nil && userResp != nil {
-					if userResp.User.RealName != "" {
-						authorName = userResp.User.RealName
-					} else if userResp.User.Name != "" {
-						authorName = userResp.User.Name
-					}
-					userCache[msg.User] = authorName
+				if err != nil {
+					log.Printf("Failed to renew lease of sync job %d: %v", job.ID, err)
 				}
-			}
-
-			// Store metadata
-			msgMetadata := map[string]interface{}{
-				"channel_id": channelID,
-				"ts":         msg.TS,
-				"user_id":    msg.User,
-			}
-			metadataJSON, _ := json.Marshal(msgMetadata)
-
-			title := truncate(msg.Text, 100)
-			if title == "" {
-				title = "Slack Message"
-			}
-
-			// Use UPSERT to handle duplicates and updates
-			_, err = tx.Exec(
-				`INSERT INTO signals 
-				 (user_id, workspace_id, source_type, source_id, external_id, title, content, body, author, status, source_metadata, received_at, updated_at)
-				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-				 ON CONFLICT(user_id, source_type, source_id) DO UPDATE SET
-					title = excluded.title,
-					content = excluded.content,
-					body = excluded.body,
-					author = excluded.author,
-					source_metadata = excluded.source_metadata,
-					updated_at = CURRENT_TIMESTAMP`,
-				integration.UserID, integration.WorkspaceID, models.SourceTypeSlack,
-				sourceID, msg.TS, title, msg.Text, msg.Text, authorName,
-				models.SignalStatusUnread, string(metadataJSON), time.Unix(msg.Timestamp, 0),
-			)
-			if err != nil {
-				log.Printf("Failed to prepare upsert for Slack signal: %v", err)
+			case <-done:
+				return
 			}
 		}
-
-		if err := tx.Commit(); err != nil {
-			log.Printf("Failed to commit transaction for channel %s: %v", channelID, err)
-		}
-
-		// Respect rate limits only when Slack actually returned limit metadata.
-		if rateLimit != nil && rateLimit.Limit > 0 && rateLimit.Remaining < 5 {
-			time.Sleep(time.Duration(60/rateLimit.Limit) * time.Second)
-		}
-	}
-
-	if maxTS > 0 {
-		metadata["last_sync"] = maxTS
-		newMetadata, _ := json.Marshal(metadata)
-		_, err := database.DB.Exec(
-			"UPDATE external_integrations SET metadata = ?, updated_at = ? WHERE id = ?",
-			string(newMetadata), time.Now(), integration.ID,
-		)
-		if err != nil {
-			log.Printf("Failed to update last sync timestamp: %v", err)
-		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
 	}
-
-	log.Printf("Synced Slack integration %d, channels: %d", integration.ID, len(channels))
 }
 
-// truncate truncates a string to maxLen characters
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
-}
+var errIntegrationGone = errors.New("integration no longer connected")
 
-func buildSlackSignalSourceID(channelID, messageTS string) string {
-	return channelID + ":" + messageTS
-}
+// errSyncLeaseExpired is recorded on jobs whose worker stopped renewing them.
+var errSyncLeaseExpired = errors.New("sync worker stopped before the job finished")
 
-// ManualSync triggers a manual sync for a specific integration
-func (s *SyncService) ManualSync(integrationID int) error {
-	var integration models.ExternalIntegration
-	var encryptedToken string
-	var workspaceID sql.NullInt64
-	err := database.DB.QueryRow(
-		"SELECT id, user_id, workspace_id, provider, access_token, metadata FROM external_integrations WHERE id = ?",
-		integrationID,
-	).Scan(
-		&integration.ID, &integration.UserID, &workspaceID,
-		&integration.Provider, &encryptedToken, &integration.Metadata,
-	)
-	if workspaceID.Valid {
-		integration.WorkspaceID = int(workspaceID.Int64)
-	}
-	if err != nil {
-		return err
-	}
+// ErrIntegrationNeedsReauth is returned for integrations whose credentials
+// were rejected; they are not synced until the user reconnects.
+var ErrIntegrationNeedsReauth = errors.New("integration needs to be reconnected")
 
-	accessToken, err := s.tokenEncryptor.Decrypt(encryptedToken)
+func (s *SyncService) runJob(job *models.SyncJob) (SyncStats, error) {
+	integration, err := repository.Default().Integrations.GetByID(job.IntegrationID)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt token: %w", err)
-	}
-
-	if integration.Provider == "slack" {
-		s.syncSlackIntegration(&integration, accessToken)
+		if err == repository.ErrNotFound {
+			return SyncStats{}, errIntegrationGone
+		}
+		return SyncStats{}, err
 	}
-
-	return nil
-}
-
-// SyncSlackSignals triggers a manual sync for Slack signals
-func SyncSlackSignals(userID, workspaceID int) error {
-	var integration models.ExternalIntegration
-	var encryptedToken string
-	err := database.DB.QueryRow(
-		`SELECT id, user_id, workspace_id, provider, access_token, metadata 
-		 FROM external_integrations 
-		 WHERE user_id = ? AND workspace_id = ? AND provider = 'slack'`,
-		userID, workspaceID,
-	).Scan(
-		&integration.ID, &integration.UserID, &integration.WorkspaceID,
-		&integration.Provider, &encryptedToken, &integration.Metadata,
-	)
-	if err != nil {
-		return err
+	if integration.ReauthRequiredAt != nil {
+		return SyncStats{}, ErrIntegrationNeedsReauth
 	}
 
-	encryptor, err := utils.NewTokenEncryptor()
-	if err != nil {
-		return err
+	provider, ok := s.lookupProvider(job.Provider)
+	if !ok {
+		return SyncStats{}, fmt.Errorf("unknown provider %q", job.Provider)
 	}
-
-	accessToken, err := encryptor.Decrypt(encryptedToken)
-	if err != nil {
-		return err
+	if !provider.Configured() {
+		return SyncStats{}, fmt.Errorf("%s integration not configured", provider.DisplayName())
 	}
+	return provider.Sync(job.UserID, job.WorkspaceID)
+}
 
-	s := NewSyncService(encryptor)
-	s.syncSlackIntegration(&integration, accessToken)
-	return nil
+// syncRetryDelay is the backoff before the next attempt after attempt failed:
+// the base delay doubled for each earlier failure, capped at the maximum.
+func syncRetryDelay(attempt int) time.Duration {
+	return utils.Backoff(syncRetryBaseDelay, syncRetryMaxDelay, attempt)
 }
@@ -1,286 +1,580 @@
 package services
 
 import (
-	"database/sql"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"net/http/httptest"
-	"path/filepath"
 	"sentinent-backend/database"
 	"sentinent-backend/models"
-	"sentinent-backend/utils"
+	"sentinent-backend/repository"
+	"sentinent-backend/services/internal/servicestest"
 	"testing"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/oauth2"
 )
 
-type mockSlackSyncClient struct {
-	channels []SlackChannel
-	messages []SlackMessage
-	msgErr   error
+// flakyProvider fails its first `failures` syncs. Later syncs succeed but
+// report itemsFailed items they could not store.
+type flakyProvider struct {
+	Provider
+	failures    int
+	itemsFailed int
+	calls       int
 }
 
-func (m *mockSlackSyncClient) GetChannels(accessToken string) ([]SlackChannel, *RateLimitInfo, error) {
-	return m.channels, nil, nil
+func (p *flakyProvider) Name() string        { return "flaky" }
+func (p *flakyProvider) DisplayName() string { return "Flaky" }
+func (p *flakyProvider) Configured() bool    { return true }
+
+func (p *flakyProvider) Sync(userID, workspaceID int) (SyncStats, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return SyncStats{ItemsFetched: 1}, fmt.Errorf("attempt %d failed", p.calls)
+	}
+	return SyncStats{ItemsFetched: 3, ItemsUpserted: 2, ItemsFailed: p.itemsFailed}, nil
 }
 
-func (m *mockSlackSyncClient) GetMessages(accessToken, channelID string, limit int, oldest string) ([]SlackMessage, *RateLimitInfo, error) {
-	return m.messages, nil, m.msgErr
+// blockingProvider signals started when its sync begins and blocks it until
+// release is closed.
+type blockingProvider struct {
+	Provider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Name() string        { return "flaky" }
+func (p *blockingProvider) DisplayName() string { return "Flaky" }
+func (p *blockingProvider) Configured() bool    { return true }
+
+func (p *blockingProvider) Sync(userID, workspaceID int) (SyncStats, error) {
+	close(p.started)
+	<-p.release
+	return SyncStats{}, nil
 }
 
-func (m *mockSlackSyncClient) GetUserInfo(accessToken, userID string) (*SlackUserResponse, *RateLimitInfo, error) {
-	return nil, nil, nil
+func newQueueTestService(provider Provider, now *time.Time) *SyncService {
+	service := NewSyncService()
+	service.lookupProvider = func(name string) (Provider, bool) {
+		if name != provider.Name() {
+			return nil, false
+		}
+		return provider, true
+	}
+	service.now = func() time.Time { return *now }
+	return service
 }
 
-func setupSyncTestDB(t *testing.T) func() {
+func seedQueueIntegration(t *testing.T) models.ExternalIntegration {
 	t.Helper()
 
-	dbPath := filepath.Join(t.TempDir(), "sync-test.db")
-	db, err := sql.Open("sqlite3", dbPath)
+	var id int
+	if err := database.DB.QueryRow(
+		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token) VALUES (1, 1, 'flaky', 'token') RETURNING id`,
+	).Scan(&id); err != nil {
+		t.Fatalf("failed to seed integration: %v", err)
+	}
+	return models.ExternalIntegration{ID: id, UserID: 1, WorkspaceID: 1, Provider: "flaky"}
+}
+
+func TestEnqueueSyncKeepsOneActiveJobPerIntegration(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	integration := seedQueueIntegration(t)
+	first, err := EnqueueSync(integration)
 	if err != nil {
-		t.Fatalf("failed to open sqlite db: %v", err)
-	}
-
-	statements := []string{
-		`CREATE TABLE signals (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			source_type TEXT NOT NULL,
-			source_id TEXT NOT NULL,
-			external_id TEXT,
-			title TEXT NOT NULL,
-			content TEXT,
-			author TEXT,
-			body TEXT,
-			url TEXT,
-			status TEXT DEFAULT 'unread',
-			source_metadata TEXT,
-			received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-		`CREATE UNIQUE INDEX idx_signals_user_source ON signals(user_id, source_type, source_id);`,
-		`CREATE TABLE external_integrations (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			workspace_id INTEGER,
-			provider TEXT NOT NULL,
-			access_token TEXT NOT NULL,
-			refresh_token TEXT,
-			expires_at DATETIME,
-			metadata TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);`,
-	}
-
-	for _, statement := range statements {
-		if _, err := db.Exec(statement); err != nil {
-			t.Fatalf("failed to prepare sync test schema: %v", err)
-		}
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	second, err := EnqueueSync(integration)
+	if err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	if first.ID != second.ID || first.Status != models.SyncJobPending {
+		t.Fatalf("expected the pending job to be reused, got %+v and %+v", first, second)
 	}
 
-	originalDB := database.DB
-	database.DB = db
+	now := time.Now().UTC()
+	service := newQueueTestService(&flakyProvider{}, &now)
+	if !service.runNextJob() {
+		t.Fatal("expected the queued job to run")
+	}
+	third, err := EnqueueSync(integration)
+	if err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	if third.ID == first.ID {
+		t.Fatal("expected a new job once the previous one finished")
+	}
+}
+
+func TestSyncServiceRetriesWithBackoffThenSucceeds(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	integration := seedQueueIntegration(t)
+	job, err := EnqueueSync(integration)
+	if err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+
+	now := time.Now().UTC()
+	provider := &flakyProvider{failures: 2}
+	service := newQueueTestService(provider, &now)
+
+	for attempt, delay := range []time.Duration{syncRetryBaseDelay, 2 * syncRetryBaseDelay} {
+		if !service.runNextJob() {
+			t.Fatalf("expected attempt %d to run", attempt+1)
+		}
+		stored, err := repository.Default().SyncJobs.Get(job.ID)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if stored.Status != models.SyncJobPending || stored.Attempts != attempt+1 || stored.LastError == "" {
+			t.Fatalf("expected a pending retry after attempt %d, got %+v", attempt+1, stored)
+		}
+		if !stored.RunAt.Equal(now.Add(delay)) {
+			t.Fatalf("expected retry at %v, got %v", now.Add(delay), stored.RunAt)
+		}
+		if service.runNextJob() {
+			t.Fatal("expected no job to be due before the backoff elapses")
+		}
+		now = now.Add(delay)
+	}
 
-	return func() {
-		database.DB = originalDB
-		_ = db.Close()
+	if !service.runNextJob() {
+		t.Fatal("expected the final attempt to run")
+	}
+	stored, err := repository.Default().SyncJobs.Get(job.ID)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if stored.Status != models.SyncJobSucceeded || stored.Attempts != 3 || stored.FinishedAt == nil {
+		t.Fatalf("expected the job to succeed on attempt 3, got %+v", stored)
 	}
 }
 
-func TestSyncSlackIntegrationStoresMultipleMessagesPerChannelWithoutDuplicates(t *testing.T) {
-	cleanup := setupSyncTestDB(t)
-	defer cleanup()
-
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case "/conversations.history":
-			_ = json.NewEncoder(w).Encode(SlackMessagesResponse{
-				OK: true,
-				Messages: []SlackMessage{
-					{Type: "message", User: "U1", Text: "First message", TS: "1710000000.000100"},
-					{Type: "message", User: "U2", Text: "Second message", TS: "1710000001.000200"},
-				},
-			})
-		case "/users.info":
-			userID := r.URL.Query().Get("user")
-			name := "Unknown"
-			if userID == "U1" {
-				name = "Alice"
-			}
-			if userID == "U2" {
-				name = "Bob"
-			}
-			_ = json.NewEncoder(w).Encode(SlackUserResponse{
-				OK: true,
-				User: struct {
-					ID       string `json:"id"`
-					Name     string `json:"name"`
-					RealName string `json:"real_name"`
-				}{
-					ID:       userID,
-					RealName: name,
-				},
-			})
-		default:
-			t.Fatalf("unexpected Slack API path: %s", r.URL.Path)
+func TestSyncServiceDeadLettersAfterMaxAttempts(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	integration := seedQueueIntegration(t)
+	job, err := EnqueueSync(integration)
+	if err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+
+	now := time.Now().UTC()
+	service := newQueueTestService(&flakyProvider{failures: defaultSyncMaxAttempts}, &now)
+	for attempt := 1; attempt <= defaultSyncMaxAttempts; attempt++ {
+		if attempt > 1 {
+			now = now.Add(syncRetryMaxDelay)
+		}
+		if !service.runNextJob() {
+			t.Fatalf("expected attempt %d to run", attempt)
 		}
-	}))
-	defer server.Close()
+	}
+	if service.runNextJob() {
+		t.Fatal("expected a dead job not to run again")
+	}
 
-	service := &SyncService{
-		slackClient: &SlackClient{
-			HTTPClient: server.Client(),
-			BaseURL:    server.URL,
-		},
+	stored, err := repository.Default().SyncJobs.Get(job.ID)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if stored.Status != models.SyncJobDead || stored.Attempts != defaultSyncMaxAttempts {
+		t.Fatalf("expected a dead job after %d attempts, got %+v", defaultSyncMaxAttempts, stored)
 	}
 
-	integration := &models.ExternalIntegration{
-		ID:          1,
-		UserID:      42,
-		WorkspaceID: 7,
-		Provider:    "slack",
-		Metadata:    `{"selected_channels":["C123"]}`,
+	health, err := IntegrationHealth(&integration)
+	if err != nil {
+		t.Fatalf("IntegrationHealth returned error: %v", err)
+	}
+	if health.Status != models.IntegrationFailing || health.DeadLetteredAt == nil ||
+		health.NextRetryAt == nil || !health.NextRetryAt.Equal(now.Add(syncRetryMaxDelay)) {
+		t.Fatalf("expected failing health until %v, got %+v", now.Add(syncRetryMaxDelay), health)
 	}
 
-	service.syncSlackIntegration(integration, "test-token")
-	service.syncSlackIntegration(integration, "test-token")
+	// The scheduler leaves a buried integration alone until it cools down; a
+	// manual sync retries it sooner.
+	service.enqueueAllIntegrations()
+	if service.runNextJob() {
+		t.Fatal("expected the scheduler not to re-enqueue a dead-lettered integration")
+	}
+	retried, err := EnqueueSync(integration)
+	if err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	if retried.ID == job.ID || !service.runNextJob() {
+		t.Fatalf("expected a manual sync to queue and run a new job, got %+v", retried)
+	}
+	service.enqueueAllIntegrations()
+	if !service.runNextJob() {
+		t.Fatal("expected the scheduler to resume once a job succeeded")
+	}
+}
 
-	var total int
-	if err := database.DB.QueryRow(
-		`SELECT COUNT(*) FROM signals WHERE user_id = ? AND workspace_id = ? AND source_type = ?`,
-		42, 7, models.SourceTypeSlack,
-	).Scan(&total); err != nil {
-		t.Fatalf("failed to count synced signals: %v", err)
+func TestSyncServiceRetriesDeadLetteredIntegrationAfterCooldown(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	integration := seedQueueIntegration(t)
+	if _, err := EnqueueSync(integration); err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
 	}
-	if total != 2 {
-		t.Fatalf("expected 2 synced signals, got %d", total)
+	now := time.Now().UTC()
+	provider := &flakyProvider{failures: 1}
+	service := newQueueTestService(provider, &now)
+	if _, err := database.DB.Exec("UPDATE sync_jobs SET max_attempts = 1"); err != nil {
+		t.Fatalf("failed to lower max_attempts: %v", err)
 	}
+	if !service.runNextJob() {
+		t.Fatal("expected the queued job to run")
+	}
+
+	now = now.Add(syncRetryMaxDelay - time.Second)
+	service.enqueueAllIntegrations()
+	if service.runNextJob() {
+		t.Fatal("expected the dead-lettered integration to wait for its cooldown")
+	}
+
+	now = now.Add(2 * time.Second)
+	service.enqueueAllIntegrations()
+	if !service.runNextJob() || provider.calls != 2 {
+		t.Fatalf("expected the scheduler to retry after the cooldown, got %d syncs", provider.calls)
+	}
+	health, err := IntegrationHealth(&integration)
+	if err != nil {
+		t.Fatalf("IntegrationHealth returned error: %v", err)
+	}
+	if health.Status != models.IntegrationHealthy || health.DeadLetteredAt != nil {
+		t.Fatalf("expected healthy after the retry succeeded, got %+v", health)
+	}
+}
 
-	rows, err := database.DB.Query(
-		`SELECT source_id, external_id FROM signals WHERE user_id = ? AND source_type = ? ORDER BY source_id`,
-		42, models.SourceTypeSlack,
-	)
+func TestSyncServiceRequeuesJobsWhoseLeaseExpired(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	integration := seedQueueIntegration(t)
+	job, err := EnqueueSync(integration)
 	if err != nil {
-		t.Fatalf("failed to query synced signals: %v", err)
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	now := time.Now().UTC()
+	service := newQueueTestService(&flakyProvider{}, &now)
+
+	// A worker claims the job and dies without finishing it.
+	jobs := repository.Default().SyncJobs
+	if _, err := jobs.ClaimNext(now, now.Add(syncJobLease)); err != nil {
+		t.Fatalf("ClaimNext returned error: %v", err)
+	}
+
+	now = now.Add(syncJobLease - time.Second)
+	service.enqueueAllIntegrations()
+	if stored, err := jobs.Get(job.ID); err != nil || stored.Status != models.SyncJobRunning {
+		t.Fatalf("expected the job to keep running within its lease, got %+v (err=%v)", stored, err)
 	}
-	defer rows.Close()
 
-	var rowsRead int
-	for rows.Next() {
-		var sourceID string
-		var externalID string
-		if err := rows.Scan(&sourceID, &externalID); err != nil {
-			t.Fatalf("failed to scan synced signal: %v", err)
+	now = now.Add(2 * time.Second)
+	service.enqueueAllIntegrations()
+	if !service.runNextJob() {
+		t.Fatal("expected the expired job to be requeued and run")
+	}
+	stored, err := jobs.Get(job.ID)
+	if err != nil || stored.Status != models.SyncJobSucceeded || stored.Attempts != 2 {
+		t.Fatalf("expected the job to succeed on its second attempt, got %+v (err=%v)", stored, err)
+	}
+}
+
+func TestSyncServiceHeartbeatRenewsLease(t *testing.T) {
+	servicestest.SeededDB(t)
+
+	integration := seedQueueIntegration(t)
+	job, err := EnqueueSync(integration)
+	if err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	now := time.Now().UTC()
+	provider := &blockingProvider{started: make(chan struct{}), release: make(chan struct{})}
+	service := newQueueTestService(provider, &now)
+	service.now = func() time.Time { return time.Now().UTC() }
+	service.heartbeat = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.runNextJob()
+	}()
+	<-provider.started
+	claimed, err := repository.Default().SyncJobs.Get(job.ID)
+	if err != nil || claimed.LeaseExpiresAt == nil {
+		t.Fatalf("expected the running job to hold a lease, got %+v (err=%v)", claimed, err)
+	}
+
+	renewed := false
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		stored, err := repository.Default().SyncJobs.Get(job.ID)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
 		}
-		rowsRead++
-		if sourceID != buildSlackSignalSourceID("C123", externalID) {
-			t.Fatalf("expected source_id to include channel and message ts, got %q for external_id %q", sourceID, externalID)
+		if stored.LeaseExpiresAt != nil && stored.LeaseExpiresAt.After(*claimed.LeaseExpiresAt) {
+			renewed = true
+			break
 		}
 	}
-	if rowsRead != 2 {
-		t.Fatalf("expected to inspect 2 rows, got %d", rowsRead)
+	close(provider.release)
+	<-done
+	if !renewed {
+		t.Fatal("expected the worker to renew the job's lease while it ran")
 	}
 }
 
-func TestSyncAllIntegrationsUpdatesSlackMetadata(t *testing.T) {
-	cleanup := setupSyncTestDB(t)
-	defer cleanup()
+func TestSyncServiceDeadLettersJobsOfDisconnectedIntegrations(t *testing.T) {
+	servicestest.SeededDB(t)
 
-	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
-	encryptor, err := utils.NewTokenEncryptor()
+	integration := seedQueueIntegration(t)
+	job, err := EnqueueSync(integration)
 	if err != nil {
-		t.Fatalf("failed to create encryptor: %v", err)
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	if _, err := database.DB.Exec("DELETE FROM external_integrations WHERE id = ?", integration.ID); err != nil {
+		t.Fatalf("failed to delete integration: %v", err)
+	}
+
+	now := time.Now().UTC()
+	provider := &flakyProvider{}
+	service := newQueueTestService(provider, &now)
+	if !service.runNextJob() {
+		t.Fatal("expected the queued job to run")
 	}
 
-	encryptedToken, err := encryptor.Encrypt("slack-token")
+	stored, err := repository.Default().SyncJobs.Get(job.ID)
 	if err != nil {
-		t.Fatalf("failed to encrypt token: %v", err)
+		t.Fatalf("Get returned error: %v", err)
 	}
+	if stored.Status != models.SyncJobDead || provider.calls != 0 {
+		t.Fatalf("expected the job to be dead without syncing, got %+v after %d syncs", stored, provider.calls)
+	}
+}
 
-	if _, err := database.DB.Exec(
-		`INSERT INTO external_integrations (user_id, workspace_id, provider, access_token, metadata)
-		 VALUES (?, ?, 'slack', ?, '{}')`,
-		1, 1, encryptedToken,
-	); err != nil {
-		t.Fatalf("failed to seed integration: %v", err)
+func TestSyncRetryDelayDoublesUpToMaximum(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  syncRetryBaseDelay,
+		2:  2 * syncRetryBaseDelay,
+		3:  4 * syncRetryBaseDelay,
+		20: syncRetryMaxDelay,
+	}
+	for attempt, want := range cases {
+		if got := syncRetryDelay(attempt); got != want {
+			t.Fatalf("syncRetryDelay(%d) = %v, want %v", attempt, got, want)
+		}
 	}
+}
+
+func TestSyncServiceRecordsRunsAndReportsHealth(t *testing.T) {
+	servicestest.SeededDB(t)
 
-	service := NewSyncService(encryptor)
-	service.slackClient = &mockSlackSyncClient{
-		channels: []SlackChannel{{ID: "C123", Name: "general"}},
-		messages: []SlackMessage{{Type: "message", User: "U1", Text: "Hello", TS: "1710000000.000100"}},
+	integration := seedQueueIntegration(t)
+	stored, err := repository.Default().Integrations.GetByID(integration.ID)
+	if err != nil {
+		t.Fatalf("GetByID returned error: %v", err)
+	}
+	health, err := IntegrationHealth(stored)
+	if err != nil {
+		t.Fatalf("IntegrationHealth returned error: %v", err)
+	}
+	if health.Status != models.IntegrationNeverSynced || health.LastRun != nil {
+		t.Fatalf("expected a never synced integration, got %+v", health)
 	}
 
-	service.syncAllIntegrations()
+	if _, err := EnqueueSync(integration); err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	now := time.Now().UTC()
+	service := newQueueTestService(&flakyProvider{failures: 1}, &now)
+	if !service.runNextJob() {
+		t.Fatal("expected the queued job to run")
+	}
 
-	var metadataJSON string
-	if err := database.DB.QueryRow(
-		"SELECT metadata FROM external_integrations WHERE provider = 'slack'",
-	).Scan(&metadataJSON); err != nil {
-		t.Fatalf("failed to query integration metadata: %v", err)
+	health, err = IntegrationHealth(stored)
+	if err != nil {
+		t.Fatalf("IntegrationHealth returned error: %v", err)
+	}
+	if health.Status != models.IntegrationDegraded || health.ConsecutiveFailures != 1 ||
+		health.LastError != "attempt 1 failed" || health.LastErrorAt == nil || health.LastSuccessAt != nil {
+		t.Fatalf("expected a degraded integration after a failed run, got %+v", health)
+	}
+
+	now = now.Add(syncRetryBaseDelay)
+	if !service.runNextJob() {
+		t.Fatal("expected the retry to run")
+	}
+	health, err = IntegrationHealth(stored)
+	if err != nil {
+		t.Fatalf("IntegrationHealth returned error: %v", err)
+	}
+	if health.Status != models.IntegrationHealthy || health.ConsecutiveFailures != 0 ||
+		health.ItemsFetched != 3 || health.ItemsUpserted != 2 || health.LastSuccessAt == nil || health.LastError == "" {
+		t.Fatalf("expected a healthy integration after the retry succeeded, got %+v", health)
 	}
 
-	var metadata map[string]any
-	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
-		t.Fatalf("failed to unmarshal metadata: %v", err)
+	var runs int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM sync_runs WHERE integration_id = ?", integration.ID).Scan(&runs); err != nil {
+		t.Fatalf("failed to count sync runs: %v", err)
 	}
-	if _, ok := metadata["last_sync"]; !ok {
-		t.Fatalf("expected last_sync in metadata, got %s", metadataJSON)
+	if runs != 2 {
+		t.Fatalf("expected one run per attempt, got %d", runs)
 	}
 }
 
-func TestSyncSlackIntegrationSkipsNotInChannelErrors(t *testing.T) {
-	cleanup := setupSyncTestDB(t)
-	defer cleanup()
+func TestSyncServiceReportsPartialFailuresAsDegraded(t *testing.T) {
+	servicestest.SeededDB(t)
 
-	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
-	encryptor, err := utils.NewTokenEncryptor()
+	integration := seedQueueIntegration(t)
+	job, err := EnqueueSync(integration)
 	if err != nil {
-		t.Fatalf("failed to create encryptor: %v", err)
+		t.Fatalf("EnqueueSync returned error: %v", err)
+	}
+	now := time.Now().UTC()
+	service := newQueueTestService(&flakyProvider{itemsFailed: 1}, &now)
+	if !service.runNextJob() {
+		t.Fatal("expected the queued job to run")
 	}
 
-	if _, err := database.DB.Exec(
-		`INSERT INTO external_integrations (id, user_id, workspace_id, provider, access_token, metadata)
-		 VALUES (?, ?, ?, 'slack', ?, '{}')`,
-		1, 1, 1, "ignored",
-	); err != nil {
-		t.Fatalf("failed to seed integration: %v", err)
+	stored, err := repository.Default().SyncJobs.Get(job.ID)
+	if err != nil || stored.Status != models.SyncJobSucceeded {
+		t.Fatalf("expected a partial failure not to be retried, got %+v (err=%v)", stored, err)
+	}
+	health, err := IntegrationHealth(&integration)
+	if err != nil {
+		t.Fatalf("IntegrationHealth returned error: %v", err)
 	}
+	if health.Status != models.IntegrationDegraded || health.ItemsFailed != 1 || health.LastRun == nil ||
+		health.LastRun.Status != models.SyncRunSucceeded || health.LastRun.ItemsFailed != 1 {
+		t.Fatalf("expected a degraded integration after a partly failed run, got %+v", health)
+	}
+}
 
-	service := NewSyncService(encryptor)
-	service.slackClient = &mockSlackSyncClient{
-		channels: []SlackChannel{{ID: "C123", Name: "general"}},
-		msgErr:   &SlackAPIError{Code: "not_in_channel"},
+func TestIntegrationHealthStatusRequiresReauthForExpiredToken(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	health := &models.IntegrationHealth{ConsecutiveFailures: 2}
+
+	integration := &models.ExternalIntegration{ExpiresAt: &expired}
+	if got := integrationHealthStatus(integration, health, now); got != models.IntegrationReauthRequired {
+		t.Fatalf("expected reauth_required for an expired token, got %q", got)
+	}
+	integration.RefreshToken = "refresh"
+	if got := integrationHealthStatus(integration, health, now); got != models.IntegrationDegraded {
+		t.Fatalf("expected a refreshable token to fall back to degraded, got %q", got)
 	}
+}
+
+// revokedProvider fails every sync because its token was revoked.
+type revokedProvider struct {
+	flakyProvider
+}
+
+func (p *revokedProvider) Scope() ProviderScope { return ScopeWorkspace }
+
+func (p *revokedProvider) Sync(userID, workspaceID int) (SyncStats, error) {
+	p.calls++
+	return SyncStats{}, &ReauthRequiredError{Provider: "Flaky", Err: errors.New("token_revoked")}
+}
+
+func TestSyncServiceFlagsRevokedIntegrationAndEmailsOwnerOnce(t *testing.T) {
+	servicestest.SeededDB(t)
+	t.Setenv("FRONTEND_BASE_URL", "https://app.example.com/")
+
+	type sentEmail struct{ to, provider, link string }
+	var sent []sentEmail
+	originalSend := sendReauthEmail
+	sendReauthEmail = func(toEmail, providerName, reconnectURL string) error {
+		sent = append(sent, sentEmail{toEmail, providerName, reconnectURL})
+		return nil
+	}
+	t.Cleanup(func() { sendReauthEmail = originalSend })
 
-	integration := &models.ExternalIntegration{
-		ID:          1,
-		UserID:      1,
-		WorkspaceID: 1,
-		Provider:    "slack",
-		Metadata:    "{}",
+	integration := seedQueueIntegration(t)
+	job, err := EnqueueSync(integration)
+	if err != nil {
+		t.Fatalf("EnqueueSync returned error: %v", err)
 	}
 
-	service.syncSlackIntegration(integration, "slack-token")
+	now := time.Now().UTC()
+	provider := &revokedProvider{}
+	service := newQueueTestService(provider, &now)
+	if !service.runNextJob() {
+		t.Fatal("expected the queued job to run")
+	}
 
-	var metadataJSON string
-	if err := database.DB.QueryRow(
-		"SELECT metadata FROM external_integrations WHERE id = 1",
-	).Scan(&metadataJSON); err != nil {
-		t.Fatalf("failed to query integration metadata: %v", err)
+	stored, err := repository.Default().SyncJobs.Get(job.ID)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if stored.Status != models.SyncJobDead || stored.Attempts != 1 {
+		t.Fatalf("expected a revoked token to dead-letter the job without retries, got %+v", stored)
+	}
+
+	flagged, err := repository.Default().Integrations.GetByID(integration.ID)
+	if err != nil {
+		t.Fatalf("GetByID returned error: %v", err)
+	}
+	if flagged.ReauthRequiredAt == nil || flagged.ReauthReason == "" {
+		t.Fatalf("expected the integration to need re-authorization, got %+v", flagged)
+	}
+	want := sentEmail{"owner@example.com", "Flaky", "https://app.example.com/integrations?reconnect=flaky&workspace_id=1"}
+	if len(sent) != 1 || sent[0] != want {
+		t.Fatalf("expected one reconnect email %+v, got %+v", want, sent)
+	}
+
+	health, err := IntegrationHealth(flagged)
+	if err != nil {
+		t.Fatalf("IntegrationHealth returned error: %v", err)
+	}
+	if health.Status != models.IntegrationReauthRequired {
+		t.Fatalf("expected reauth_required health, got %q", health.Status)
+	}
+
+	service.enqueueAllIntegrations()
+	if service.runNextJob() {
+		t.Fatal("expected the scheduler to skip an integration that needs re-authorization")
+	}
+	if _, err := EnqueueSync(*flagged); err != ErrIntegrationNeedsReauth {
+		t.Fatalf("expected ErrIntegrationNeedsReauth, got %v", err)
+	}
+	if provider.calls != 1 || len(sent) != 1 {
+		t.Fatalf("expected no further syncs or emails, got %d syncs and %d emails", provider.calls, len(sent))
 	}
 
-	var metadata map[string]any
-	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
-		t.Fatalf("failed to unmarshal metadata: %v", err)
+	workspaceID := 1
+	if err := repository.Default().Integrations.Upsert(1, "flaky", &workspaceID, repository.IntegrationTokens{AccessToken: "new-token"}, nil); err != nil {
+		t.Fatalf("Upsert returned error: %v", err)
 	}
-	if _, ok := metadata["last_sync"]; ok {
-		t.Fatalf("expected last_sync NOT to be updated after skipping inaccessible channel, got %s", metadataJSON)
+	reconnected, err := repository.Default().Integrations.GetByID(integration.ID)
+	if err != nil {
+		t.Fatalf("GetByID returned error: %v", err)
+	}
+	if reconnected.ReauthRequiredAt != nil || reconnected.ReauthReason != "" {
+		t.Fatalf("expected reconnecting to clear the flag, got %+v", reconnected)
+	}
+	// The OAuth callback queues a sync once the user reconnects.
+	if _, err := EnqueueSync(*reconnected); err != nil {
+		t.Fatalf("expected the reconnected integration to be queued, got %v", err)
 	}
-	if !IsSlackAPIError(service.slackClient.(*mockSlackSyncClient).msgErr, "not_in_channel") {
-		t.Fatal("expected not_in_channel to be recognized as a Slack API error")
+	now = time.Now().UTC()
+	if !service.runNextJob() {
+		t.Fatal("expected the reconnected integration to be synced again")
+	}
+}
+
+func TestTokenRefreshRejectionRequiresReauth(t *testing.T) {
+	rejected := fmt.Errorf("failed to get/refresh token: %w", &oauth2.RetrieveError{ErrorCode: "invalid_grant"})
+	if !isTokenRefreshRejected(rejected) {
+		t.Fatalf("expected invalid_grant to require re-authorization")
+	}
+	if !IsReauthRequired(&ReauthRequiredError{Provider: "Jira", Err: rejected}) {
+		t.Error("expected a rejected refresh to surface as a re-authorization error")
+	}
+
+	transient := &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}
+	if isTokenRefreshRejected(transient) {
+		t.Error("expected a 503 from the token endpoint not to require re-authorization")
 	}
 }
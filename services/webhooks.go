@@ -0,0 +1,339 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sentinent-backend/models"
+	"sentinent-backend/repository"
+	"sentinent-backend/utils"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookMaxAttempts    = 8
+	webhookRetryBaseDelay = 30 * time.Second
+	webhookRetryMaxDelay  = time.Hour
+	webhookTimeout        = 10 * time.Second
+	// webhookDeliveryLease is how long a claimed delivery is held before it is
+	// assumed to belong to a sender that died and is tried again.
+	webhookDeliveryLease = 2 * time.Minute
+	webhookDeliveryBatch = 20
+	// webhookDeliveryWorkers is how many deliveries are sent at once, so one
+	// slow endpoint does not hold up the rest of a batch.
+	webhookDeliveryWorkers = 4
+	webhookErrorLimit      = 500
+)
+
+// webhookDeliveriesQueued wakes the delivery loop when an event is enqueued,
+// so deliveries do not wait for the next poll.
+var webhookDeliveriesQueued = make(chan struct{}, 1)
+
+var errWebhookDisabled = errors.New("webhook is disabled")
+
+// ErrWebhookAddressNotAllowed is returned for webhook targets on the server's
+// own or internal network.
+var ErrWebhookAddressNotAllowed = errors.New("webhook address is not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range, which some clouds use for
+// their metadata services.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// WebhookAddressAllowed reports whether webhooks may be sent to ip. Loopback,
+// private, link-local, multicast and unspecified addresses are refused, so a
+// webhook cannot reach the server itself, its network or a metadata service.
+func WebhookAddressAllowed(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// webhookDialControl refuses connections to addresses WebhookAddressAllowed
+// rejects. It runs after DNS resolution, so a host that resolved to a public
+// address when the webhook was saved cannot be pointed inside later.
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !WebhookAddressAllowed(ip) {
+		return ErrWebhookAddressNotAllowed
+	}
+	return nil
+}
+
+// newWebhookHTTPClient returns the client deliveries are sent with. It dials
+// only allowed addresses, ignores proxy settings so the check sees the real
+// target, and does not follow redirects: a 3xx response is a failed attempt.
+func newWebhookHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func wakeWebhookDeliveries() {
+	select {
+	case webhookDeliveriesQueued <- struct{}{}:
+	default:
+	}
+}
+
+// EnqueueWebhookEvent queues a delivery of the event to every enabled webhook
+// in the workspace that subscribes to it. Failures are logged: a webhook must
+// never fail the change that raised the event.
+func EnqueueWebhookEvent(workspaceID int, eventType string, data interface{}) {
+	store := repository.Default()
+	webhooks, err := store.Webhooks.ListSubscribed(workspaceID, eventType)
+	if err != nil {
+		log.Printf("Failed to load webhooks for %s in workspace %d: %v", eventType, workspaceID, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	eventID, err := newWebhookEventID()
+	if err != nil {
+		log.Printf("Failed to create webhook event id: %v", err)
+		return
+	}
+	now := time.Now().UTC()
+	payload, err := json.Marshal(models.WebhookPayload{
+		ID:          eventID,
+		Type:        eventType,
+		WorkspaceID: workspaceID,
+		OccurredAt:  now,
+		Data:        data,
+	})
+	if err != nil {
+		log.Printf("Failed to encode %s webhook payload: %v", eventType, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if _, err := store.Webhooks.Enqueue(webhook.ID, eventID, eventType, payload, nil, now); err != nil {
+			log.Printf("Failed to queue %s delivery to webhook %d: %v", eventType, webhook.ID, err)
+		}
+	}
+	wakeWebhookDeliveries()
+}
+
+// RedeliverWebhook queues the delivery's payload to be sent again, unchanged,
+// and returns the new delivery's id.
+func RedeliverWebhook(delivery models.WebhookDelivery) (int, error) {
+	deliveryID, err := repository.Default().Webhooks.Enqueue(
+		delivery.WebhookID, delivery.EventID, delivery.EventType, delivery.Payload, &delivery.ID, time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	wakeWebhookDeliveries()
+	return deliveryID, nil
+}
+
+func newWebhookEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// signWebhookPayload returns the X-Hub-Signature-256 value for body: the hex
+// HMAC-SHA256 of the body under the webhook's secret, as GitHub signs its own.
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookService sends queued webhook deliveries, retrying failures with
+// exponential backoff.
+type WebhookService struct {
+	HTTPClient HTTPDoer
+	now        func() time.Time
+	ticker     *time.Ticker
+	stopChan   chan bool
+	wg         sync.WaitGroup
+}
+
+// NewWebhookService creates a new WebhookService
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		HTTPClient: newWebhookHTTPClient(),
+		now:        func() time.Time { return time.Now().UTC() },
+		stopChan:   make(chan bool),
+	}
+}
+
+// Start begins sending deliveries, polling for due retries every interval.
+func (s *WebhookService) Start(interval time.Duration) {
+	s.ticker = time.NewTicker(interval)
+	s.wg.Add(1)
+	go s.run()
+	log.Printf("Webhook service started with interval: %v", interval)
+}
+
+// Stop stops sending deliveries and waits for those in flight to finish.
+func (s *WebhookService) Stop() {
+	if s.ticker != nil {
+		s.ticker.Stop()
+		close(s.stopChan)
+		s.wg.Wait()
+	}
+}
+
+func (s *WebhookService) run() {
+	defer s.wg.Done()
+	for {
+		for s.deliverDue() {
+			select {
+			case <-s.stopChan:
+				return
+			default:
+			}
+		}
+		select {
+		case <-webhookDeliveriesQueued:
+		case <-s.ticker.C:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// deliverDue sends a batch of due deliveries, several at a time, and reports
+// whether more may be waiting.
+func (s *WebhookService) deliverDue() bool {
+	deliveries, err := repository.Default().Webhooks.DueDeliveries(s.now(), webhookDeliveryBatch)
+	if err != nil {
+		log.Printf("Failed to load due webhook deliveries: %v", err)
+		return false
+	}
+
+	queue := make(chan models.WebhookDelivery)
+	var workers sync.WaitGroup
+	for i := 0; i < webhookDeliveryWorkers && i < len(deliveries); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for delivery := range queue {
+				s.deliver(delivery)
+			}
+		}()
+	}
+	for _, delivery := range deliveries {
+		queue <- delivery
+	}
+	close(queue)
+	workers.Wait()
+	return len(deliveries) == webhookDeliveryBatch
+}
+
+// deliver makes one attempt at a delivery and records the outcome.
+func (s *WebhookService) deliver(delivery models.WebhookDelivery) {
+	webhooks := repository.Default().Webhooks
+	now := s.now()
+	if err := webhooks.ClaimDelivery(delivery.ID, now, now.Add(webhookDeliveryLease)); err != nil {
+		if err != repository.ErrNotFound {
+			log.Printf("Failed to claim webhook delivery %d: %v", delivery.ID, err)
+		}
+		return
+	}
+	attempt := delivery.Attempts + 1
+
+	responseStatus, err := s.send(delivery)
+	if err == nil {
+		if err := webhooks.MarkDelivered(delivery.ID, responseStatus, s.now()); err != nil {
+			log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	message := err.Error()
+	if len(message) > webhookErrorLimit {
+		message = message[:webhookErrorLimit]
+	}
+	var status *int
+	if responseStatus != 0 {
+		status = &responseStatus
+	}
+	var nextAttemptAt *time.Time
+	if attempt < webhookMaxAttempts && err != errWebhookDisabled {
+		next := s.now().Add(webhookRetryDelay(attempt))
+		nextAttemptAt = &next
+	} else {
+		log.Printf("Webhook delivery %d failed after %d attempts: %v", delivery.ID, attempt, err)
+	}
+	if err := webhooks.MarkAttemptFailed(delivery.ID, status, message, nextAttemptAt); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts the delivery's payload and returns the response status. Only a
+// 2xx response counts as delivered.
+func (s *WebhookService) send(delivery models.WebhookDelivery) (int, error) {
+	target, err := repository.Default().Webhooks.Target(delivery.WebhookID)
+	if err != nil {
+		return 0, fmt.Errorf("load webhook: %w", err)
+	}
+	if !target.Enabled {
+		return 0, errWebhookDisabled
+	}
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		return 0, fmt.Errorf("load webhook secret: %w", err)
+	}
+	secret, err := encryptor.Decrypt(target.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("load webhook secret: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Sentinent-Webhooks")
+	req.Header.Set("X-Hub-Signature-256", signWebhookPayload(secret, delivery.Payload))
+	req.Header.Set("X-Sentinent-Event", delivery.EventType)
+	req.Header.Set("X-Sentinent-Delivery", delivery.EventID)
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookRetryDelay is the backoff before the next attempt after attempt
+// failed: the base delay doubled for each earlier failure, capped at the
+// maximum.
+func webhookRetryDelay(attempt int) time.Duration {
+	return utils.Backoff(webhookRetryBaseDelay, webhookRetryMaxDelay, attempt)
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sentinent-backend/database"
+	"sentinent-backend/models"
+	"sentinent-backend/services/internal/servicestest"
+	"sentinent-backend/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliveriesAreSignedAndRetried(t *testing.T) {
+	store := servicestest.SeededDB(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+
+	var mu sync.Mutex
+	var received []models.WebhookPayload
+	flakyCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		// Receivers can check the signature the way GitHub's is checked.
+		mac := hmac.New(sha256.New, []byte("shared-secret"))
+		mac.Write(body)
+		if signature := r.Header.Get("X-Hub-Signature-256"); signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("expected a valid signature, got %q", signature)
+		}
+		var payload models.WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		if r.Header.Get("X-Sentinent-Event") != payload.Type || r.Header.Get("X-Sentinent-Delivery") != payload.ID {
+			t.Errorf("expected event headers to match the payload, got %v", r.Header)
+		}
+		received = append(received, payload)
+
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		flakyCalls++
+		if flakyCalls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		t.Fatalf("NewTokenEncryptor returned error: %v", err)
+	}
+	secret, err := encryptor.Encrypt("shared-secret")
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	flaky, err := store.Webhooks.Create(1, 1, models.WebhookRequest{
+		URL:    server.URL + "/flaky",
+		Events: []string{models.WebhookEventSignalCreated},
+	}, secret)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	down, err := store.Webhooks.Create(1, 1, models.WebhookRequest{
+		URL:    server.URL + "/down",
+		Events: []string{models.WebhookEventDecisionClosed},
+	}, secret)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	// Only a newly created signal is announced.
+	storeTestSignal(t, 10, "Crash on login")
+	storeTestSignal(t, 10, "Crash on login (regression)")
+	EnqueueWebhookEvent(1, models.WebhookEventDecisionClosed, map[string]int{"id": 1})
+
+	now := time.Now().UTC()
+	service := NewWebhookService()
+	service.HTTPClient = server.Client()
+	service.now = func() time.Time { return now }
+
+	service.deliverDue()
+	deliveries, err := store.Webhooks.ListDeliveries(flaky, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected one signal.created delivery, got %+v (err=%v)", deliveries, err)
+	}
+	retry := deliveries[0]
+	if retry.Status != models.WebhookDeliveryPending || retry.Attempts != 1 || *retry.ResponseStatus != http.StatusInternalServerError ||
+		!retry.NextAttemptAt.Equal(now.Add(webhookRetryBaseDelay)) {
+		t.Fatalf("expected the failed attempt to be retried after the base delay, got %+v", retry)
+	}
+
+	// Nothing is resent before the backoff runs out.
+	service.deliverDue()
+	if flakyCalls != 1 {
+		t.Fatalf("expected the retry to wait, got %d calls", flakyCalls)
+	}
+	now = now.Add(webhookRetryBaseDelay)
+	service.deliverDue()
+	delivered, err := store.Webhooks.GetDelivery(flaky, retry.ID)
+	if err != nil || delivered.Status != models.WebhookDeliveryDelivered || delivered.Attempts != 2 || *delivered.ResponseStatus != http.StatusNoContent {
+		t.Fatalf("expected the retry to be delivered, got %+v (err=%v)", delivered, err)
+	}
+
+	// A delivery that keeps failing is given up on after the last attempt.
+	failing, err := store.Webhooks.ListDeliveries(down, 10)
+	if err != nil || len(failing) != 1 {
+		t.Fatalf("expected one decision.closed delivery, got %+v (err=%v)", failing, err)
+	}
+	if _, err := database.DB.Exec(
+		`UPDATE webhook_deliveries SET attempts = ?, next_attempt_at = ? WHERE id = ?`,
+		webhookMaxAttempts-1, now, failing[0].ID,
+	); err != nil {
+		t.Fatalf("failed to age delivery: %v", err)
+	}
+	service.deliverDue()
+	failed, err := store.Webhooks.GetDelivery(down, failing[0].ID)
+	if err != nil || failed.Status != models.WebhookDeliveryFailed || failed.Attempts != webhookMaxAttempts || failed.NextAttemptAt != nil {
+		t.Fatalf("expected the delivery to fail for good, got %+v (err=%v)", failed, err)
+	}
+
+	// A redelivery resends the same event.
+	redeliveryID, err := RedeliverWebhook(*failed)
+	if err != nil {
+		t.Fatalf("RedeliverWebhook returned error: %v", err)
+	}
+	redelivery, err := store.Webhooks.GetDelivery(down, redeliveryID)
+	if err != nil || redelivery.EventID != failed.EventID || *redelivery.RedeliveryOf != failed.ID || string(redelivery.Payload) != string(failed.Payload) {
+		t.Fatalf("expected a copy of the failed delivery, got %+v (err=%v)", redelivery, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var signalAttempts []models.WebhookPayload
+	for _, payload := range received {
+		if payload.Type == models.WebhookEventSignalCreated {
+			signalAttempts = append(signalAttempts, payload)
+		}
+	}
+	if len(received) != 5 || len(signalAttempts) != 2 || signalAttempts[0].WorkspaceID != 1 || signalAttempts[1].ID != signalAttempts[0].ID {
+		t.Fatalf("expected the same signed event on each attempt, got %+v", received)
+	}
+}
+
+func TestWebhookAddressAllowed(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.0.1":      false,
+		"fd00::1":          false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"100.100.100.200":  false,
+		"0.0.0.0":          false,
+		"::":               false,
+		"224.0.0.1":        false,
+	}
+	for address, want := range cases {
+		if got := WebhookAddressAllowed(net.ParseIP(address)); got != want {
+			t.Errorf("WebhookAddressAllowed(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestWebhookDeliveriesRefuseInternalAddressesAndRedirects(t *testing.T) {
+	store := servicestest.SeededDB(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		t.Fatalf("NewTokenEncryptor returned error: %v", err)
+	}
+	secret, err := encryptor.Encrypt("shared-secret")
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	webhookID, err := store.Webhooks.Create(1, 1, models.WebhookRequest{
+		URL:    server.URL + "/redirect",
+		Events: []string{models.WebhookEventDecisionClosed},
+	}, secret)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	EnqueueWebhookEvent(1, models.WebhookEventDecisionClosed, map[string]int{"id": 1})
+
+	// The test server listens on loopback, which the delivery client refuses
+	// to dial.
+	service := NewWebhookService()
+	service.deliverDue()
+	deliveries, err := store.Webhooks.ListDeliveries(webhookID, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %+v (err=%v)", deliveries, err)
+	}
+	refused := deliveries[0]
+	if refused.Attempts != 1 || refused.ResponseStatus != nil ||
+		!strings.Contains(refused.LastError, ErrWebhookAddressNotAllowed.Error()) {
+		t.Fatalf("expected the loopback delivery to be refused, got %+v", refused)
+	}
+	mu.Lock()
+	if len(paths) != 0 {
+		t.Fatalf("expected nothing to reach the server, got %v", paths)
+	}
+	mu.Unlock()
+
+	// Redirects are not followed even to an allowed address.
+	client := newWebhookHTTPClient()
+	client.Transport.(*http.Transport).DialContext = (&net.Dialer{}).DialContext
+	service.HTTPClient = client
+	if _, err := database.DB.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?`, time.Now().UTC(), refused.ID); err != nil {
+		t.Fatalf("failed to make delivery due: %v", err)
+	}
+	service.deliverDue()
+	redirected, err := store.Webhooks.GetDelivery(webhookID, refused.ID)
+	if err != nil || redirected.Attempts != 2 || redirected.ResponseStatus == nil || *redirected.ResponseStatus != http.StatusFound {
+		t.Fatalf("expected the redirect to count as a failed attempt, got %+v (err=%v)", redirected, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 1 || paths[0] != "/redirect" {
+		t.Fatalf("expected the redirect not to be followed, got %v", paths)
+	}
+}
+
+func TestWebhookDeliveriesAreSentConcurrently(t *testing.T) {
+	store := servicestest.SeededDB(t)
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "test-encryption-key-32-bytes-long!")
+
+	// Each endpoint answers only once all of them are waiting, which a
+	// sender working through the batch one at a time never sees.
+	const endpoints = 3
+	var arrived sync.WaitGroup
+	arrived.Add(endpoints)
+	allArrived := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(allArrived)
+	}()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+		select {
+		case <-allArrived:
+			w.WriteHeader(http.StatusNoContent)
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	defer server.Close()
+
+	encryptor, err := utils.NewTokenEncryptor()
+	if err != nil {
+		t.Fatalf("NewTokenEncryptor returned error: %v", err)
+	}
+	secret, err := encryptor.Encrypt("shared-secret")
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	var webhookIDs []int
+	for i := 0; i < endpoints; i++ {
+		webhookID, err := store.Webhooks.Create(1, 1, models.WebhookRequest{
+			URL:    server.URL,
+			Events: []string{models.WebhookEventDecisionClosed},
+		}, secret)
+		if err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		webhookIDs = append(webhookIDs, webhookID)
+	}
+	EnqueueWebhookEvent(1, models.WebhookEventDecisionClosed, map[string]int{"id": 1})
+
+	service := NewWebhookService()
+	service.HTTPClient = server.Client()
+	service.deliverDue()
+	for _, webhookID := range webhookIDs {
+		deliveries, err := store.Webhooks.ListDeliveries(webhookID, 10)
+		if err != nil || len(deliveries) != 1 || deliveries[0].Status != models.WebhookDeliveryDelivered {
+			t.Fatalf("expected webhook %d to be delivered, got %+v (err=%v)", webhookID, deliveries, err)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		1: 30 * time.Second,
+		2: time.Minute,
+		4: 4 * time.Minute,
+		7: 32 * time.Minute,
+		9: time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := webhookRetryDelay(attempt); got != want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package utils
+
+import "time"
+
+// Backoff returns the delay for the given step of an exponential backoff: base
+// for the first step, doubled for each step after it, capped at max.
+func Backoff(base, max time.Duration, step int) time.Duration {
+	delay := base
+	for i := 1; i < step && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		step     int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{100, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(time.Second, 5*time.Second, tt.step); got != tt.expected {
+			t.Errorf("Backoff(1s, 5s, %d) = %v; want %v", tt.step, got, tt.expected)
+		}
+	}
+}
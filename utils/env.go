@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// IsProductionEnv reports whether APP_ENV names a production deployment.
+func IsProductionEnv() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV"))) {
+	case "production", "prod":
+		return true
+	default:
+		return false
+	}
+}
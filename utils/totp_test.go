@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 test key from RFC 6238 appendix B, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits.
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode returned error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("expected code %s at %d, got %s", want, unix, got)
+		}
+	}
+}
+
+func TestMatchTOTPAcceptsAdjacentStepsOnly(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TOTPStep(now)
+
+	previous, _ := TOTPCode(rfc6238Secret, step-1)
+	if matched, ok := MatchTOTP(rfc6238Secret, previous, now); !ok || matched != step-1 {
+		t.Fatalf("expected previous step code to match step %d, got %d %v", step-1, matched, ok)
+	}
+
+	stale, _ := TOTPCode(rfc6238Secret, step-2)
+	if _, ok := MatchTOTP(rfc6238Secret, stale, now); ok {
+		t.Fatal("expected code from two steps ago to be rejected")
+	}
+	if _, ok := MatchTOTP(rfc6238Secret, "12345", now); ok {
+		t.Fatal("expected short code to be rejected")
+	}
+}
+
+func TestGenerateTOTPSecretAndProvisioningURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret returned error: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("expected 32 character base32 secret, got %q", secret)
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Fatalf("generated secret is not usable: %v", err)
+	}
+
+	uri := TOTPProvisioningURI(secret, "Sentinent", "user@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Sentinent:user@example.com?") {
+		t.Fatalf("unexpected provisioning URI: %s", uri)
+	}
+	if !strings.Contains(uri, "secret="+secret) || !strings.Contains(uri, "issuer=Sentinent") {
+		t.Fatalf("provisioning URI is missing parameters: %s", uri)
+	}
+}